@@ -87,6 +87,27 @@ func (e *FieldNotFoundError) Is(err error) bool {
 	return ok
 }
 
+// InvalidAccessError represents a failure to read or write a field because
+// the access pattern it matched doesn't allow it.
+type InvalidAccessError struct {
+	// Field is the aspect name that was requested.
+	Field string
+	// Write is true if the rejected operation was a write.
+	Write bool
+}
+
+func (e *InvalidAccessError) Error() string {
+	if e.Write {
+		return fmt.Sprintf("cannot set field %q: path is not writeable", e.Field)
+	}
+	return fmt.Sprintf("cannot get field %q: path is not readable", e.Field)
+}
+
+func (e *InvalidAccessError) Is(err error) bool {
+	_, ok := err.(*InvalidAccessError)
+	return ok
+}
+
 // DataBag controls access to the aspect data storage.
 type DataBag interface {
 	Get(path string, value interface{}) error
@@ -139,6 +160,33 @@ func NewAspectBundle(name string, aspects map[string]interface{}, schema Schema)
 	return aspectBundle, nil
 }
 
+// ParseAspectBundle parses the definition of an aspect bundle, a JSON object
+// holding the "aspects" of the bundle with their access patterns and the
+// "schema" the databag of the bundle must conform to.
+func ParseAspectBundle(name string, definition []byte) (*Bundle, error) {
+	var def struct {
+		Aspects map[string][]map[string]string `json:"aspects"`
+		Schema  json.RawMessage                `json:"schema"`
+	}
+	if err := json.Unmarshal(definition, &def); err != nil {
+		return nil, fmt.Errorf("cannot parse aspects bundle definition: %w", err)
+	}
+	if len(def.Schema) == 0 {
+		return nil, errors.New(`cannot define aspects bundle: no schema`)
+	}
+
+	schema, err := ParseJSONSchema(def.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("cannot define aspects bundle: %w", err)
+	}
+
+	aspects := make(map[string]interface{}, len(def.Aspects))
+	for name, accessPatterns := range def.Aspects {
+		aspects[name] = accessPatterns
+	}
+	return NewAspectBundle(name, aspects, schema)
+}
+
 func newAspect(bundle *Bundle, name string, aspectPatterns []map[string]string) (*Aspect, error) {
 	aspect := &Aspect{
 		Name:           name,
@@ -268,8 +316,47 @@ type Aspect struct {
 	bundle         *Bundle
 }
 
-// Set sets the named aspect to a specified value.
+// Set sets the named aspect to a specified value. The resulting data is
+// validated against the bundle's schema and, if it doesn't conform, the
+// databag is left unchanged.
 func (a *Aspect) Set(databag DataBag, name string, value interface{}) error {
+	path, err := a.writePath(name)
+	if err != nil {
+		return err
+	}
+
+	// keep the previous value so it can be restored if the new data
+	// doesn't conform to the schema
+	var prevValue interface{}
+	var prev json.RawMessage
+	if err := databag.Get(path, &prev); err == nil {
+		prevValue = prev
+	} else if !errors.Is(err, &FieldNotFoundError{}) {
+		return err
+	}
+
+	if err := databag.Set(path, value); err != nil {
+		return err
+	}
+
+	data, err := databag.Data()
+	if err != nil {
+		return err
+	}
+
+	if err := a.bundle.schema.Validate(data); err != nil {
+		if restoreErr := databag.Set(path, prevValue); restoreErr != nil {
+			return fmt.Errorf("%v (cannot restore previous value: %v)", err, restoreErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// writePath returns the databag path that the named aspect maps to, provided
+// it can be written.
+func (a *Aspect) writePath(name string) (string, error) {
 	nameSubkeys := strings.Split(name, ".")
 	for _, accessPatt := range a.accessPatterns {
 		placeholders, ok := accessPatt.match(nameSubkeys)
@@ -279,26 +366,17 @@ func (a *Aspect) Set(databag DataBag, name string, value interface{}) error {
 
 		path, err := accessPatt.getPath(placeholders)
 		if err != nil {
-			return err
+			return "", err
 		}
 
 		if !accessPatt.isWriteable() {
-			return fmt.Errorf("cannot set field %q: path is not writeable", name)
+			return "", &InvalidAccessError{Field: name, Write: true}
 		}
 
-		if err := databag.Set(path, value); err != nil {
-			return err
-		}
-
-		data, err := databag.Data()
-		if err != nil {
-			return err
-		}
-
-		return a.bundle.schema.Validate(data)
+		return path, nil
 	}
 
-	return &FieldNotFoundError{fmt.Sprintf("cannot set field %q: not found", name)}
+	return "", &FieldNotFoundError{fmt.Sprintf("cannot set field %q: not found", name)}
 }
 
 // Get returns the aspect value identified by the name. If either the named aspect
@@ -317,7 +395,7 @@ func (a *Aspect) Get(databag DataBag, name string, value interface{}) error {
 		}
 
 		if !accessPatt.isReadable() {
-			return fmt.Errorf("cannot get field %q: path is not readable", name)
+			return &InvalidAccessError{Field: name}
 		}
 
 		if err := databag.Get(path, value); err != nil {
@@ -634,18 +712,14 @@ func (s JSONDataBag) Data() ([]byte, error) {
 	return json.Marshal(s)
 }
 
-// JSONSchema is the Schema implementation corresponding to JSONDataBag and it's
-// able to validate its data.
-type JSONSchema struct{}
-
-// NewJSONSchema returns a Schema able to validate a JSONDataBag's data.
-func NewJSONSchema() JSONSchema {
-	return JSONSchema{}
-}
-
-// Validate validates that the specified data can be encoded into JSON.
-func (s JSONSchema) Validate(jsonData []byte) error {
-	// the top-level is always an object
-	var data map[string]json.RawMessage
-	return json.Unmarshal(jsonData, &data)
+// Copy returns a copy of the databag that can be modified without affecting
+// the original.
+func (s JSONDataBag) Copy() JSONDataBag {
+	// nested levels are re-encoded on every write, so sharing the raw
+	// values is safe
+	bagCopy := make(JSONDataBag, len(s))
+	for k, v := range s {
+		bagCopy[k] = v
+	}
+	return bagCopy
 }
@@ -20,6 +20,7 @@
 package aspects_test
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -72,6 +73,50 @@ func (*aspectSuite) TestNewAspectBundle(c *C) {
 	c.Check(aspectBundle, Not(IsNil))
 }
 
+func (*aspectSuite) TestParseAspectBundle(c *C) {
+	aspectBundle, err := aspects.ParseAspectBundle("foo", []byte(`{
+	"aspects": {
+		"bar": [
+			{"name": "a", "path": "b", "access": "read-write"}
+		]
+	},
+	"schema": {
+		"type": "object",
+		"properties": {
+			"b": {"type": "integer", "minimum": 1}
+		}
+	}
+}`))
+	c.Assert(err, IsNil)
+	c.Check(aspectBundle.Name, Equals, "foo")
+
+	asp := aspectBundle.Aspect("bar")
+	c.Assert(asp, NotNil)
+	databag := aspects.NewJSONDataBag()
+	c.Check(asp.Set(databag, "a", 2), IsNil)
+	c.Check(asp.Set(databag, "a", 0), ErrorMatches, `cannot accept element in "b": .*`)
+	var value int
+	c.Assert(asp.Get(databag, "a", &value), IsNil)
+	c.Check(value, Equals, 2)
+}
+
+func (*aspectSuite) TestParseAspectBundleErrors(c *C) {
+	for _, t := range []struct {
+		definition string
+		err        string
+	}{
+		{`{`, `cannot parse aspects bundle definition: .*`},
+		{`{"aspects": {"bar": [{"name": 1}]}, "schema": {}}`, `cannot parse aspects bundle definition: .*`},
+		{`{"aspects": {"bar": [{"name": "a", "path": "b"}]}}`, `cannot define aspects bundle: no schema`},
+		{`{"aspects": {"bar": [{"name": "a", "path": "b"}]}, "schema": {"type": "string"}}`, `cannot define aspects bundle: cannot parse schema: top level must be of type "object"`},
+		{`{"schema": {}}`, `cannot define aspects bundle: no aspects`},
+		{`{"aspects": {"bar": []}, "schema": {}}`, `cannot define aspect "bar": no access patterns found`},
+	} {
+		_, err := aspects.ParseAspectBundle("foo", []byte(t.definition))
+		c.Check(err, ErrorMatches, t.err, Commentf(t.definition))
+	}
+}
+
 func (s *aspectSuite) TestAccessTypes(c *C) {
 	type testcase struct {
 		access string
@@ -262,6 +307,7 @@ func (s *aspectSuite) TestAspectsAccessControl(c *C) {
 		err := aspect.Set(databag, t.name, "thing")
 		if t.setErr != "" {
 			c.Assert(err.Error(), Equals, t.setErr, cmt)
+			c.Assert(errors.Is(err, &aspects.InvalidAccessError{}), Equals, true, cmt)
 		} else {
 			c.Assert(err, IsNil, cmt)
 		}
@@ -0,0 +1,459 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package aspects
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/snapcore/snapd/jsonutil"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// ValidationError represents a failure to validate data against a schema.
+// Path identifies the offending element with a dotted path (e.g.,
+// "wifi.ssids[1]") and is empty for the top-level element.
+type ValidationError struct {
+	Path string
+	Err  error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("cannot accept top level element: %v", e.Err)
+	}
+	return fmt.Sprintf("cannot accept element in %q: %v", e.Path, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ValidationError) Is(err error) bool {
+	_, ok := err.(*ValidationError)
+	return ok
+}
+
+// JSONSchema is the Schema implementation corresponding to JSONDataBag and it's
+// able to validate its data.
+type JSONSchema struct {
+	// root is nil if the schema accepts any object
+	root *schemaNode
+}
+
+// NewJSONSchema returns a Schema able to validate a JSONDataBag's data. The
+// schema accepts any JSON object.
+func NewJSONSchema() JSONSchema {
+	return JSONSchema{}
+}
+
+// ParseJSONSchema parses the schema of an aspect bundle definition. It
+// supports the following subset of JSON Schema keywords:
+//   - "type": a type name or a list of type names out of "object", "array",
+//     "string", "integer", "number", "boolean" and "null"
+//   - "enum": a list of the values that are allowed
+//   - "minimum"/"maximum": inclusive bounds of numbers
+//   - "minLength"/"maxLength": bounds of the length of strings
+//   - "properties": schemas for the keys of an object
+//   - "required": keys that an object must have
+//   - "additionalProperties": whether keys without a schema are allowed
+//   - "items": schema for all elements of an array
+//   - "minItems"/"maxItems": bounds of the length of arrays
+//
+// The "$schema", "title" and "description" annotations are ignored. Any other
+// keyword is rejected. The top-level of the schema must describe an object.
+func ParseJSONSchema(raw []byte) (JSONSchema, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+
+	var rawRoot rawSchemaNode
+	if err := dec.Decode(&rawRoot); err != nil {
+		return JSONSchema{}, fmt.Errorf("cannot parse schema: %w", err)
+	}
+	if dec.More() {
+		return JSONSchema{}, errors.New("cannot parse schema: unexpected data after the schema")
+	}
+
+	root, err := rawRoot.compile("")
+	if err != nil {
+		return JSONSchema{}, err
+	}
+
+	if len(root.types) != 0 && !strutil.ListContains(root.types, "object") {
+		return JSONSchema{}, errors.New(`cannot parse schema: top level must be of type "object"`)
+	}
+
+	return JSONSchema{root: root}, nil
+}
+
+// Validate validates that the specified data is a JSON object that conforms
+// to the schema.
+func (s JSONSchema) Validate(jsonData []byte) error {
+	var data interface{}
+	if err := jsonutil.DecodeWithNumber(bytes.NewReader(jsonData), &data); err != nil {
+		return err
+	}
+
+	// the top-level is always an object
+	if _, ok := data.(map[string]interface{}); !ok {
+		return &ValidationError{Err: fmt.Errorf("expected object but got %s", jsonType(data))}
+	}
+
+	if s.root == nil {
+		return nil
+	}
+
+	return s.root.validate("", data)
+}
+
+var knownSchemaTypes = []string{"object", "array", "string", "integer", "number", "boolean", "null"}
+
+// schemaTypes holds the value of the "type" keyword which can be either a
+// single type name or a list of them.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New(`"type" must be a string or a list of strings`)
+	}
+	*t = list
+	return nil
+}
+
+type rawSchemaNode struct {
+	Schema      string `json:"$schema"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+
+	Type schemaTypes   `json:"type"`
+	Enum []interface{} `json:"enum"`
+
+	Minimum *json.Number `json:"minimum"`
+	Maximum *json.Number `json:"maximum"`
+
+	MinLength *int `json:"minLength"`
+	MaxLength *int `json:"maxLength"`
+
+	Properties           map[string]*rawSchemaNode `json:"properties"`
+	Required             []string                  `json:"required"`
+	AdditionalProperties *bool                     `json:"additionalProperties"`
+
+	Items    *rawSchemaNode `json:"items"`
+	MinItems *int           `json:"minItems"`
+	MaxItems *int           `json:"maxItems"`
+}
+
+// compile checks the raw schema is consistent and converts it into a
+// schemaNode. The path is the dotted path of the data the schema applies to
+// and is only used for error reporting.
+func (r *rawSchemaNode) compile(path string) (*schemaNode, error) {
+	invalid := func(format string, v ...interface{}) error {
+		msg := fmt.Sprintf(format, v...)
+		if path == "" {
+			return fmt.Errorf("cannot parse schema: %s", msg)
+		}
+		return fmt.Errorf("cannot parse schema for %q: %s", path, msg)
+	}
+
+	for _, typ := range r.Type {
+		if !strutil.ListContains(knownSchemaTypes, typ) {
+			return nil, invalid("unknown type %q", typ)
+		}
+	}
+
+	node := &schemaNode{
+		types:                r.Type,
+		enum:                 r.Enum,
+		required:             r.Required,
+		additionalProperties: r.AdditionalProperties == nil || *r.AdditionalProperties,
+	}
+
+	var err error
+	if node.minimum, err = parseBound(r.Minimum); err != nil {
+		return nil, invalid(`invalid "minimum": %v`, err)
+	}
+	if node.maximum, err = parseBound(r.Maximum); err != nil {
+		return nil, invalid(`invalid "maximum": %v`, err)
+	}
+	if node.minimum != nil && node.maximum != nil && *node.minimum > *node.maximum {
+		return nil, invalid(`"minimum" cannot be greater than "maximum"`)
+	}
+
+	for _, l := range []struct {
+		name     string
+		min, max *int
+	}{
+		{"Length", r.MinLength, r.MaxLength},
+		{"Items", r.MinItems, r.MaxItems},
+	} {
+		if (l.min != nil && *l.min < 0) || (l.max != nil && *l.max < 0) {
+			return nil, invalid(`"min%[1]s" and "max%[1]s" cannot be negative`, l.name)
+		}
+		if l.min != nil && l.max != nil && *l.min > *l.max {
+			return nil, invalid(`"min%[1]s" cannot be greater than "max%[1]s"`, l.name)
+		}
+	}
+	node.minLength, node.maxLength = r.MinLength, r.MaxLength
+	node.minItems, node.maxItems = r.MinItems, r.MaxItems
+
+	if len(r.Properties) > 0 {
+		node.properties = make(map[string]*schemaNode, len(r.Properties))
+		for key, rawProp := range r.Properties {
+			if rawProp == nil {
+				return nil, invalid("schema of key %q cannot be null", key)
+			}
+
+			prop, err := rawProp.compile(joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			node.properties[key] = prop
+		}
+	}
+
+	if r.Items != nil {
+		if node.items, err = r.Items.compile(path + "[]"); err != nil {
+			return nil, err
+		}
+	}
+
+	return node, nil
+}
+
+func parseBound(n *json.Number) (*float64, error) {
+	if n == nil {
+		return nil, nil
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// schemaNode validates a single element of the data and, recursively, its
+// children.
+type schemaNode struct {
+	// types is empty if any type is accepted
+	types []string
+	enum  []interface{}
+
+	minimum, maximum *float64
+
+	minLength, maxLength *int
+
+	properties           map[string]*schemaNode
+	required             []string
+	additionalProperties bool
+
+	items              *schemaNode
+	minItems, maxItems *int
+}
+
+func (n *schemaNode) validate(path string, value interface{}) error {
+	fail := func(format string, v ...interface{}) error {
+		return &ValidationError{Path: path, Err: fmt.Errorf(format, v...)}
+	}
+
+	typ := jsonType(value)
+	if len(n.types) != 0 && !n.acceptsType(typ) {
+		return fail("expected %s but got %s", strings.Join(n.types, " or "), typ)
+	}
+
+	if len(n.enum) != 0 && !n.inEnum(value) {
+		allowed := make([]string, 0, len(n.enum))
+		for _, v := range n.enum {
+			allowed = append(allowed, encodeValue(v))
+		}
+		return fail("%s is not one of the allowed values: %s", encodeValue(value), strings.Join(allowed, ", "))
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return fail("cannot parse number %s: %v", v, err)
+		}
+		if n.minimum != nil && f < *n.minimum {
+			return fail("%s is less than the minimum %s", v, formatFloat(*n.minimum))
+		}
+		if n.maximum != nil && f > *n.maximum {
+			return fail("%s is greater than the maximum %s", v, formatFloat(*n.maximum))
+		}
+
+	case string:
+		length := utf8.RuneCountInString(v)
+		if n.minLength != nil && length < *n.minLength {
+			return fail("string is shorter than the minimum length %d", *n.minLength)
+		}
+		if n.maxLength != nil && length > *n.maxLength {
+			return fail("string is longer than the maximum length %d", *n.maxLength)
+		}
+
+	case []interface{}:
+		if n.minItems != nil && len(v) < *n.minItems {
+			return fail("array has fewer than %d items", *n.minItems)
+		}
+		if n.maxItems != nil && len(v) > *n.maxItems {
+			return fail("array has more than %d items", *n.maxItems)
+		}
+		if n.items != nil {
+			for i, item := range v {
+				if err := n.items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+
+	case map[string]interface{}:
+		for _, key := range n.required {
+			if _, ok := v[key]; !ok {
+				return fail("missing required key %q", key)
+			}
+		}
+
+		// check keys in a stable order so errors are predictable
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			prop, ok := n.properties[key]
+			if !ok {
+				if !n.additionalProperties {
+					return fail("unexpected key %q", key)
+				}
+				continue
+			}
+
+			if err := prop.validate(joinPath(path, key), v[key]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (n *schemaNode) acceptsType(typ string) bool {
+	// integers are also numbers
+	return strutil.ListContains(n.types, typ) || (typ == "integer" && strutil.ListContains(n.types, "number"))
+}
+
+func (n *schemaNode) inEnum(value interface{}) bool {
+	normValue := normalizeNumbers(value)
+	for _, allowed := range n.enum {
+		if reflect.DeepEqual(normalizeNumbers(allowed), normValue) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeNumbers returns a copy of the value with json.Numbers converted into
+// float64 so that equal numbers compare as such regardless of their encoding.
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v
+	case []interface{}:
+		norm := make([]interface{}, len(v))
+		for i, item := range v {
+			norm[i] = normalizeNumbers(item)
+		}
+		return norm
+	case map[string]interface{}:
+		norm := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			norm[key] = normalizeNumbers(item)
+		}
+		return norm
+	default:
+		return value
+	}
+}
+
+// jsonType returns the JSON schema type name of a value decoded with
+// json.Numbers.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func encodeValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,222 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package aspects_test
+
+import (
+	"errors"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/aspects"
+)
+
+type schemaSuite struct{}
+
+var _ = Suite(&schemaSuite{})
+
+const testSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"description": "network settings",
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "maxLength": 5},
+		"port": {"type": "integer", "minimum": 1, "maximum": 65535},
+		"ratio": {"type": "number", "minimum": 0, "maximum": 1.5},
+		"enabled": {"type": "boolean"},
+		"mode": {"enum": ["a", "b", 3]},
+		"optional": {"type": ["string", "null"]},
+		"any": {},
+		"servers": {
+			"type": "array",
+			"minItems": 1,
+			"maxItems": 2,
+			"items": {
+				"type": "object",
+				"properties": {
+					"host": {"type": "string"}
+				},
+				"required": ["host"],
+				"additionalProperties": false
+			}
+		},
+		"nested": {
+			"type": "object",
+			"properties": {
+				"deeper": {
+					"type": "object",
+					"properties": {
+						"leaf": {"type": "integer"}
+					}
+				}
+			}
+		}
+	},
+	"required": ["name"]
+}`
+
+func (*schemaSuite) TestValidateHappy(c *C) {
+	schema, err := aspects.ParseJSONSchema([]byte(testSchema))
+	c.Assert(err, IsNil)
+
+	for _, data := range []string{
+		`{"name": "a"}`,
+		`{"name": "abcde", "port": 1, "ratio": 1.5, "enabled": false}`,
+		`{"name": "a", "port": 65535, "ratio": 0, "mode": "b"}`,
+		`{"name": "a", "port": 8.0, "mode": 3.0}`,
+		`{"name": "a", "optional": null, "any": [1, {"b": null}]}`,
+		`{"name": "a", "optional": "x", "unknown": 1}`,
+		`{"name": "a", "servers": [{"host": "a"}, {"host": "b"}]}`,
+		`{"name": "a", "nested": {"deeper": {"leaf": 1, "other": true}}}`,
+		`{"name": "ñññññ"}`,
+	} {
+		c.Check(schema.Validate([]byte(data)), IsNil, Commentf("data: %s", data))
+	}
+}
+
+func (*schemaSuite) TestValidateUnhappy(c *C) {
+	schema, err := aspects.ParseJSONSchema([]byte(testSchema))
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		data string
+		err  string
+	}{
+		{`[]`, `cannot accept top level element: expected object but got array`},
+		{`{}`, `cannot accept top level element: missing required key "name"`},
+		{`{"name": 1}`, `cannot accept element in "name": expected string but got integer`},
+		{`{"name": ""}`, `cannot accept element in "name": string is shorter than the minimum length 1`},
+		{`{"name": "abcdef"}`, `cannot accept element in "name": string is longer than the maximum length 5`},
+		{`{"name": "a", "port": 1.5}`, `cannot accept element in "port": expected integer but got number`},
+		{`{"name": "a", "port": 0}`, `cannot accept element in "port": 0 is less than the minimum 1`},
+		{`{"name": "a", "port": 65536}`, `cannot accept element in "port": 65536 is greater than the maximum 65535`},
+		{`{"name": "a", "ratio": 1.6}`, `cannot accept element in "ratio": 1.6 is greater than the maximum 1.5`},
+		{`{"name": "a", "ratio": "1"}`, `cannot accept element in "ratio": expected number but got string`},
+		{`{"name": "a", "enabled": "true"}`, `cannot accept element in "enabled": expected boolean but got string`},
+		{`{"name": "a", "mode": "c"}`, `cannot accept element in "mode": "c" is not one of the allowed values: "a", "b", 3`},
+		{`{"name": "a", "optional": 1}`, `cannot accept element in "optional": expected string or null but got integer`},
+		{`{"name": "a", "servers": []}`, `cannot accept element in "servers": array has fewer than 1 items`},
+		{`{"name": "a", "servers": [{"host": "a"}, {"host": "b"}, {"host": "c"}]}`, `cannot accept element in "servers": array has more than 2 items`},
+		{`{"name": "a", "servers": [{"host": "a"}, {}]}`, `cannot accept element in "servers\[1\]": missing required key "host"`},
+		{`{"name": "a", "servers": [{"host": "a", "port": 1}]}`, `cannot accept element in "servers\[0\]": unexpected key "port"`},
+		{`{"name": "a", "servers": [{"host": 1}]}`, `cannot accept element in "servers\[0\].host": expected string but got integer`},
+		{`{"name": "a", "nested": {"deeper": {"leaf": "1"}}}`, `cannot accept element in "nested.deeper.leaf": expected integer but got string`},
+	} {
+		err := schema.Validate([]byte(t.data))
+		c.Check(err, ErrorMatches, t.err, Commentf("data: %s", t.data))
+		c.Check(errors.Is(err, &aspects.ValidationError{}), Equals, true, Commentf("data: %s", t.data))
+	}
+}
+
+func (*schemaSuite) TestValidationErrorPath(c *C) {
+	schema, err := aspects.ParseJSONSchema([]byte(testSchema))
+	c.Assert(err, IsNil)
+
+	err = schema.Validate([]byte(`{"name": "a", "nested": {"deeper": {"leaf": "1"}}}`))
+	var valErr *aspects.ValidationError
+	c.Assert(errors.As(err, &valErr), Equals, true)
+	c.Check(valErr.Path, Equals, "nested.deeper.leaf")
+	c.Check(valErr.Err, ErrorMatches, "expected integer but got string")
+}
+
+func (*schemaSuite) TestValidateInvalidJSON(c *C) {
+	schema, err := aspects.ParseJSONSchema([]byte(testSchema))
+	c.Assert(err, IsNil)
+
+	err = schema.Validate([]byte(`{"name": `))
+	c.Assert(err, ErrorMatches, "unexpected EOF")
+}
+
+func (*schemaSuite) TestEmptySchemaAcceptsAnyObject(c *C) {
+	for _, schema := range []aspects.JSONSchema{aspects.NewJSONSchema(), mustParseSchema(c, `{}`)} {
+		c.Check(schema.Validate([]byte(`{"a": [1, "b", {"c": null}]}`)), IsNil)
+		c.Check(schema.Validate([]byte(`{}`)), IsNil)
+		c.Check(schema.Validate([]byte(`"foo"`)), ErrorMatches, "cannot accept top level element: expected object but got string")
+	}
+}
+
+func mustParseSchema(c *C, raw string) aspects.JSONSchema {
+	schema, err := aspects.ParseJSONSchema([]byte(raw))
+	c.Assert(err, IsNil)
+	return schema
+}
+
+func (*schemaSuite) TestParseSchemaErrors(c *C) {
+	for _, t := range []struct {
+		schema string
+		err    string
+	}{
+		{`{`, `cannot parse schema: unexpected EOF`},
+		{`{} {}`, `cannot parse schema: unexpected data after the schema`},
+		{`{"foo": 1}`, `cannot parse schema: json: unknown field "foo"`},
+		{`{"type": 1}`, `cannot parse schema: "type" must be a string or a list of strings`},
+		{`{"type": "string"}`, `cannot parse schema: top level must be of type "object"`},
+		{`{"type": "foo"}`, `cannot parse schema: unknown type "foo"`},
+		{`{"minimum": 2, "maximum": 1}`, `cannot parse schema: "minimum" cannot be greater than "maximum"`},
+		{`{"minLength": -1}`, `cannot parse schema: "minLength" and "maxLength" cannot be negative`},
+		{`{"minItems": 3, "maxItems": 2}`, `cannot parse schema: "minItems" cannot be greater than "maxItems"`},
+		{`{"properties": {"a": {"properties": {"b": {"type": "bar"}}}}}`, `cannot parse schema for "a.b": unknown type "bar"`},
+		{`{"properties": {"a": {"items": {"minLength": 2, "maxLength": 1}}}}`, `cannot parse schema for "a\[\]": "minLength" cannot be greater than "maxLength"`},
+		{`{"properties": {"a": null}}`, `cannot parse schema: schema of key "a" cannot be null`},
+	} {
+		_, err := aspects.ParseJSONSchema([]byte(t.schema))
+		c.Check(err, ErrorMatches, t.err, Commentf("schema: %s", t.schema))
+	}
+}
+
+func (*schemaSuite) TestAspectSetValidatesSchema(c *C) {
+	schema := mustParseSchema(c, `{
+	"properties": {
+		"wifi": {
+			"properties": {
+				"ssid": {"type": "string"},
+				"ssids": {"type": "array", "items": {"type": "string"}}
+			}
+		}
+	}
+}`)
+	aspectBundle, err := aspects.NewAspectBundle("bundle", map[string]interface{}{
+		"foo": []map[string]string{
+			{"name": "ssid", "path": "wifi.ssid"},
+			{"name": "ssids", "path": "wifi.ssids"},
+		},
+	}, schema)
+	c.Assert(err, IsNil)
+
+	aspect := aspectBundle.Aspect("foo")
+	databag := aspects.NewJSONDataBag()
+
+	err = aspect.Set(databag, "ssid", "my-network")
+	c.Assert(err, IsNil)
+
+	err = aspect.Set(databag, "ssid", 1)
+	c.Assert(err, ErrorMatches, `cannot accept element in "wifi.ssid": expected string but got integer`)
+
+	err = aspect.Set(databag, "ssids", []interface{}{"a", true})
+	c.Assert(err, ErrorMatches, `cannot accept element in "wifi.ssids\[1\]": expected string but got boolean`)
+
+	// rejected values aren't kept in the databag
+	var ssid string
+	c.Assert(aspect.Get(databag, "ssid", &ssid), IsNil)
+	c.Check(ssid, Equals, "my-network")
+
+	var ssids []string
+	err = aspect.Get(databag, "ssids", &ssids)
+	c.Assert(err, FitsTypeOf, &aspects.FieldNotFoundError{})
+}
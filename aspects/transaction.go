@@ -0,0 +1,123 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package aspects
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Transaction stages writes to the aspects of a bundle on top of a databag.
+// The staged data is only validated against the bundle's schema when the
+// changes are applied, so related fields can be changed together even if the
+// intermediate data wouldn't be valid.
+type Transaction struct {
+	bundle  *Bundle
+	staged  JSONDataBag
+	changes []stagedChange
+}
+
+type stagedChange struct {
+	path  string
+	value interface{}
+}
+
+// NewTransaction returns a transaction that stages writes on top of a copy of
+// the databag. The databag itself isn't modified.
+func (b *Bundle) NewTransaction(databag JSONDataBag) *Transaction {
+	if databag == nil {
+		databag = NewJSONDataBag()
+	}
+
+	return &Transaction{
+		bundle: b,
+		staged: databag.Copy(),
+	}
+}
+
+// Set stages setting the named field of the aspect to the value. A nil value
+// unsets the field. Access control is checked right away, but the schema is
+// only checked once the changes are applied.
+func (t *Transaction) Set(aspect *Aspect, name string, value interface{}) error {
+	if aspect.bundle != t.bundle {
+		return fmt.Errorf("internal error: aspect %q doesn't belong to bundle %q", aspect.Name, t.bundle.Name)
+	}
+
+	path, err := aspect.writePath(name)
+	if err != nil {
+		return err
+	}
+
+	if err := t.staged.Set(path, value); err != nil {
+		return err
+	}
+
+	t.changes = append(t.changes, stagedChange{path: path, value: value})
+	return nil
+}
+
+// Get reads the named field of the aspect, including any staged changes.
+func (t *Transaction) Get(aspect *Aspect, name string, value interface{}) error {
+	return aspect.Get(t.staged, name, value)
+}
+
+// Changes returns the sorted databag paths written to by the transaction.
+func (t *Transaction) Changes() []string {
+	seen := make(map[string]bool, len(t.changes))
+	var paths []string
+	for _, change := range t.changes {
+		if seen[change.path] {
+			continue
+		}
+		seen[change.path] = true
+		paths = append(paths, change.path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Apply applies the staged changes, in the order they were made, on top of a
+// copy of the databag and validates the result against the bundle's schema.
+// The databag may differ from the one the transaction was created with, e.g.,
+// if it was modified concurrently. If everything succeeds, the new databag is
+// returned; the original is never modified.
+func (t *Transaction) Apply(databag JSONDataBag) (JSONDataBag, error) {
+	if databag == nil {
+		databag = NewJSONDataBag()
+	}
+
+	result := databag.Copy()
+	for _, change := range t.changes {
+		if err := result.Set(change.path, change.value); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := result.Data()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.bundle.schema.Validate(data); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
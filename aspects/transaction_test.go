@@ -0,0 +1,183 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package aspects_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/aspects"
+)
+
+type transactionSuite struct {
+	bundle *aspects.Bundle
+}
+
+var _ = Suite(&transactionSuite{})
+
+func (s *transactionSuite) SetUpTest(c *C) {
+	schema := mustParseSchema(c, `{
+	"properties": {
+		"net": {
+			"properties": {
+				"address": {"type": "string"},
+				"netmask": {"type": "integer", "minimum": 0, "maximum": 32}
+			},
+			"required": ["address", "netmask"]
+		}
+	}
+}`)
+
+	var err error
+	s.bundle, err = aspects.NewAspectBundle("bundle", map[string]interface{}{
+		"setup": []map[string]string{
+			{"name": "address", "path": "net.address"},
+			{"name": "netmask", "path": "net.netmask"},
+			{"name": "status", "path": "status", "access": "read"},
+		},
+		"other": []map[string]string{
+			{"name": "foo", "path": "foo"},
+		},
+	}, schema)
+	c.Assert(err, IsNil)
+}
+
+func (s *transactionSuite) TestSetSeveralFieldsAtOnce(c *C) {
+	aspect := s.bundle.Aspect("setup")
+	databag := aspects.NewJSONDataBag()
+
+	// setting one field at a time would violate the required keys
+	err := aspect.Set(databag, "address", "192.168.0.2")
+	c.Assert(err, ErrorMatches, `cannot accept element in "net": missing required key "netmask"`)
+
+	tx := s.bundle.NewTransaction(databag)
+	c.Assert(tx.Set(aspect, "address", "192.168.0.2"), IsNil)
+	c.Assert(tx.Set(aspect, "netmask", 24), IsNil)
+
+	// staged changes can be read back
+	var address string
+	c.Assert(tx.Get(aspect, "address", &address), IsNil)
+	c.Check(address, Equals, "192.168.0.2")
+
+	// but the original databag is untouched
+	err = aspect.Get(databag, "address", &address)
+	c.Assert(err, FitsTypeOf, &aspects.FieldNotFoundError{})
+
+	result, err := tx.Apply(databag)
+	c.Assert(err, IsNil)
+
+	var netmask int
+	c.Assert(aspect.Get(result, "netmask", &netmask), IsNil)
+	c.Check(netmask, Equals, 24)
+	c.Assert(aspect.Get(result, "address", &address), IsNil)
+	c.Check(address, Equals, "192.168.0.2")
+
+	err = aspect.Get(databag, "address", &address)
+	c.Assert(err, FitsTypeOf, &aspects.FieldNotFoundError{})
+}
+
+func (s *transactionSuite) TestApplyFailsAsAWhole(c *C) {
+	aspect := s.bundle.Aspect("setup")
+	databag := aspects.NewJSONDataBag()
+
+	tx := s.bundle.NewTransaction(databag)
+	c.Assert(tx.Set(aspect, "address", "192.168.0.2"), IsNil)
+	c.Assert(tx.Set(aspect, "netmask", 33), IsNil)
+
+	result, err := tx.Apply(databag)
+	c.Assert(err, ErrorMatches, `cannot accept element in "net.netmask": 33 is greater than the maximum 32`)
+	c.Check(result, IsNil)
+
+	data, err := databag.Data()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "{}")
+}
+
+func (s *transactionSuite) TestApplyOnConcurrentlyModifiedDatabag(c *C) {
+	setup := s.bundle.Aspect("setup")
+	other := s.bundle.Aspect("other")
+
+	tx := s.bundle.NewTransaction(aspects.NewJSONDataBag())
+	c.Assert(tx.Set(setup, "address", "192.168.0.2"), IsNil)
+	c.Assert(tx.Set(setup, "netmask", 24), IsNil)
+
+	// the databag was changed by someone else in the meantime
+	current := aspects.NewJSONDataBag()
+	c.Assert(other.Set(current, "foo", "bar"), IsNil)
+
+	result, err := tx.Apply(current)
+	c.Assert(err, IsNil)
+
+	var foo string
+	c.Assert(other.Get(result, "foo", &foo), IsNil)
+	c.Check(foo, Equals, "bar")
+
+	var address string
+	c.Assert(setup.Get(result, "address", &address), IsNil)
+	c.Check(address, Equals, "192.168.0.2")
+}
+
+func (s *transactionSuite) TestUnset(c *C) {
+	other := s.bundle.Aspect("other")
+	databag := aspects.NewJSONDataBag()
+	c.Assert(other.Set(databag, "foo", "bar"), IsNil)
+
+	tx := s.bundle.NewTransaction(databag)
+	c.Assert(tx.Set(other, "foo", nil), IsNil)
+
+	result, err := tx.Apply(databag)
+	c.Assert(err, IsNil)
+
+	var foo string
+	err = other.Get(result, "foo", &foo)
+	c.Assert(err, FitsTypeOf, &aspects.FieldNotFoundError{})
+}
+
+func (s *transactionSuite) TestChanges(c *C) {
+	setup := s.bundle.Aspect("setup")
+	other := s.bundle.Aspect("other")
+
+	tx := s.bundle.NewTransaction(nil)
+	c.Check(tx.Changes(), HasLen, 0)
+
+	c.Assert(tx.Set(setup, "netmask", 24), IsNil)
+	c.Assert(tx.Set(other, "foo", "bar"), IsNil)
+	c.Assert(tx.Set(setup, "address", "192.168.0.2"), IsNil)
+	c.Assert(tx.Set(setup, "netmask", 16), IsNil)
+
+	c.Check(tx.Changes(), DeepEquals, []string{"foo", "net.address", "net.netmask"})
+}
+
+func (s *transactionSuite) TestSetErrors(c *C) {
+	tx := s.bundle.NewTransaction(nil)
+
+	err := tx.Set(s.bundle.Aspect("setup"), "status", "foo")
+	c.Assert(err, ErrorMatches, `cannot set field "status": path is not writeable`)
+
+	err = tx.Set(s.bundle.Aspect("setup"), "unknown", "foo")
+	c.Assert(err, ErrorMatches, `cannot set field "unknown": not found`)
+
+	otherBundle, err := aspects.NewAspectBundle("other-bundle", map[string]interface{}{
+		"setup": []map[string]string{{"name": "foo", "path": "foo"}},
+	}, aspects.NewJSONSchema())
+	c.Assert(err, IsNil)
+
+	err = tx.Set(otherBundle.Aspect("setup"), "foo", "bar")
+	c.Assert(err, ErrorMatches, `internal error: aspect "setup" doesn't belong to bundle "bundle"`)
+}
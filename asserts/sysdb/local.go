@@ -0,0 +1,160 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sysdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+)
+
+// trustedRootsOwner is the uid that must own the local trusted roots.
+var trustedRootsOwner uint32 = 0
+
+// LocalTrusted returns the account and account-key assertions from the
+// *.assert files in dirs.SnapTrustedRootsDir. They extend the compiled-in
+// trusted set but never replace compiled-in assertions with the same primary
+// key. This way root keys can be rotated by adding new account-keys without
+// rebuilding snapd, while the compiled-in ones cannot be tampered with. The
+// directory and the files in it must be owned by root and not writable by
+// anyone else. An error is returned only if the directory is not, files that
+// are not or that cannot be decoded are logged and ignored.
+func LocalTrusted() ([]asserts.Assertion, error) {
+	dir := dirs.SnapTrustedRootsDir
+	if err := checkTrustedRootsPath(dir, true); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Glob returns the files sorted
+	files, err := filepath.Glob(filepath.Join(dir, "*.assert"))
+	if err != nil {
+		return nil, err
+	}
+
+	var trusted []asserts.Assertion
+	for _, fn := range files {
+		// a bad file must not keep the assertion database, and so
+		// snapd, from being opened
+		if err := checkTrustedRootsPath(fn, false); err != nil {
+			logger.Noticef("ignoring trusted roots file: %v", err)
+			continue
+		}
+		as, err := decodeTrustedRoots(fn)
+		if err != nil {
+			logger.Noticef("ignoring trusted roots file: %v", err)
+			continue
+		}
+		trusted = append(trusted, as...)
+	}
+	return trusted, nil
+}
+
+func checkTrustedRootsPath(path string, isDir bool) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if isDir && !fi.IsDir() {
+		return fmt.Errorf("trusted roots location is not a directory: %s", path)
+	}
+	if !isDir && !fi.Mode().IsRegular() {
+		return fmt.Errorf("trusted roots file is not a regular file: %s", path)
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("cannot get the owner of trusted roots: %s", path)
+	}
+	if st.Uid != trustedRootsOwner {
+		return fmt.Errorf("trusted roots unexpectedly not owned by root: %s", path)
+	}
+	if fi.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("trusted roots unexpectedly writable by group or others: %s", path)
+	}
+	return nil
+}
+
+func decodeTrustedRoots(fn string) ([]asserts.Assertion, error) {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	var trusted []asserts.Assertion
+	dec := asserts.NewDecoder(bytes.NewReader(data))
+	for {
+		a, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode trusted roots from %s: %v", fn, err)
+		}
+		switch a.Type() {
+		case asserts.AccountType, asserts.AccountKeyType:
+		default:
+			return nil, fmt.Errorf("cannot use %s assertion from %s as trusted, only account and account-key assertions are supported", a.Type().Name, fn)
+		}
+		trusted = append(trusted, a)
+	}
+	return trusted, nil
+}
+
+// mergeTrusted returns the trusted assertions extended with the local ones.
+// A local assertion with the same primary key as a trusted one is ignored, so
+// that local files cannot replace compiled-in assertions. Among local
+// assertions with the same primary key the highest revision is used.
+func mergeTrusted(trusted, local []asserts.Assertion) []asserts.Assertion {
+	merged := make([]asserts.Assertion, len(trusted), len(trusted)+len(local))
+	copy(merged, trusted)
+
+	index := make(map[string]int, len(merged))
+	for i, a := range merged {
+		index[a.Ref().Unique()] = i
+	}
+	for _, a := range local {
+		key := a.Ref().Unique()
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, a)
+			continue
+		}
+		if i < len(trusted) {
+			logger.Noticef("ignoring local trusted %s assertion %v: cannot replace a compiled-in trusted assertion", a.Type().Name, a.Ref().PrimaryKey)
+			continue
+		}
+		if a.Revision() > merged[i].Revision() {
+			merged[i] = a
+		}
+	}
+	return merged
+}
@@ -40,10 +40,15 @@ func openDatabaseAt(path string, cfg *asserts.DatabaseConfig) (*asserts.Database
 }
 
 // OpenAt opens a system assertion database at the given location with
-// the trusted assertions set configured.
+// the trusted assertions set configured, including the local trusted roots.
 func OpenAt(path string) (*asserts.Database, error) {
+	local, err := LocalTrusted()
+	if err != nil {
+		return nil, err
+	}
+
 	cfg := &asserts.DatabaseConfig{
-		Trusted:         Trusted(),
+		Trusted:         mergeTrusted(Trusted(), local),
 		OtherPredefined: Generic(),
 	}
 	return openDatabaseAt(path, cfg)
@@ -20,6 +20,8 @@
 package sysdb_test
 
 import (
+	"bytes"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"syscall"
@@ -31,11 +33,15 @@ import (
 	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/asserts/sysdb"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
 )
 
 func TestSysDB(t *testing.T) { TestingT(t) }
 
 type sysDBSuite struct {
+	rootKey      asserts.PrivateKey
+	signingDB    *assertstest.SigningDB
+	trustedAcct  *asserts.Account
 	extraTrusted []asserts.Assertion
 	extraGeneric []asserts.Assertion
 	otherModel   *asserts.Model
@@ -63,6 +69,9 @@ func (sdbs *sysDBSuite) SetUpTest(c *C) {
 		"until":      "2500-11-20T15:04:00Z",
 	}, pk.PublicKey(), "")
 
+	sdbs.rootKey = pk
+	sdbs.signingDB = signingDB
+	sdbs.trustedAcct = trustedAcct
 	sdbs.extraTrusted = []asserts.Assertion{trustedAcct, trustedAccKey}
 
 	otherAcct := assertstest.NewAccount(signingDB, "gener1c", map[string]interface{}{
@@ -213,3 +222,153 @@ func (sdbs *sysDBSuite) TestOpenSysDatabaseKeypairManagerOpenFail(c *C) {
 	c.Assert(err, ErrorMatches, "assert storage root unexpectedly world-writable: .*")
 	c.Check(db, IsNil)
 }
+
+func (sdbs *sysDBSuite) writeTrustedRoots(c *C, name string, as ...asserts.Assertion) {
+	c.Assert(os.MkdirAll(dirs.SnapTrustedRootsDir, 0755), IsNil)
+	buf := &bytes.Buffer{}
+	enc := asserts.NewEncoder(buf)
+	for _, a := range as {
+		c.Assert(enc.Encode(a), IsNil)
+	}
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapTrustedRootsDir, name), buf.Bytes(), 0644), IsNil)
+}
+
+func (sdbs *sysDBSuite) TestOpenSysDatabaseLocalTrusted(c *C) {
+	restore := sysdb.MockTrustedRootsOwner(uint32(os.Getuid()))
+	defer restore()
+
+	sdbs.writeTrustedRoots(c, "can0nical.assert", sdbs.extraTrusted...)
+	// other files are ignored
+	sdbs.writeTrustedRoots(c, "README", sdbs.probeAssert)
+
+	local, err := sysdb.LocalTrusted()
+	c.Assert(err, IsNil)
+	c.Check(local, DeepEquals, sdbs.extraTrusted)
+
+	db, err := sysdb.Open()
+	c.Assert(err, IsNil)
+
+	err = db.Check(sdbs.probeAssert)
+	c.Check(err, IsNil)
+}
+
+func (sdbs *sysDBSuite) TestOpenSysDatabaseNoLocalTrusted(c *C) {
+	local, err := sysdb.LocalTrusted()
+	c.Assert(err, IsNil)
+	c.Check(local, HasLen, 0)
+}
+
+func (sdbs *sysDBSuite) TestOpenSysDatabaseLocalTrustedRotation(c *C) {
+	restore := sysdb.MockTrustedRootsOwner(uint32(os.Getuid()))
+	defer restore()
+	restore = sysdb.InjectTrusted(sdbs.extraTrusted)
+	defer restore()
+
+	// a new root key, vouched for by the old one
+	newPk, _ := assertstest.GenerateKey(752)
+	newAccKey := assertstest.NewAccountKey(sdbs.signingDB, sdbs.trustedAcct, map[string]interface{}{
+		"name":  "root-2",
+		"since": "2015-11-20T15:04:00Z",
+	}, newPk.PublicKey(), "")
+	sdbs.writeTrustedRoots(c, "can0nical.assert", newAccKey)
+
+	db, err := sysdb.Open()
+	c.Assert(err, IsNil)
+
+	// both the old and the new root keys are trusted
+	err = db.Check(sdbs.probeAssert)
+	c.Check(err, IsNil)
+
+	newSigningDB := assertstest.NewSigningDB("can0nical", newPk)
+	probe := assertstest.NewAccount(newSigningDB, "probe", nil, "")
+	err = db.Check(probe)
+	c.Check(err, IsNil)
+}
+
+func (sdbs *sysDBSuite) TestOpenSysDatabaseLocalTrustedCannotReplaceCompiledIn(c *C) {
+	restore := sysdb.MockTrustedRootsOwner(uint32(os.Getuid()))
+	defer restore()
+	restore = sysdb.InjectTrusted(sdbs.extraTrusted)
+	defer restore()
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	// a higher revision of the compiled-in root key, revoking it
+	revokedAccKey := assertstest.NewAccountKey(sdbs.signingDB, sdbs.trustedAcct, map[string]interface{}{
+		"revision": "1",
+		"since":    "2015-11-20T15:04:00Z",
+		"until":    "2016-11-20T15:04:00Z",
+	}, sdbs.rootKey.PublicKey(), "")
+	sdbs.writeTrustedRoots(c, "can0nical.assert", revokedAccKey)
+
+	db, err := sysdb.Open()
+	c.Assert(err, IsNil)
+
+	// the compiled-in root key is still used
+	err = db.Check(sdbs.probeAssert)
+	c.Check(err, IsNil)
+	c.Check(logbuf.String(), Matches, `(?s).*ignoring local trusted account-key assertion \[.*\]: cannot replace a compiled-in trusted assertion\n`)
+}
+
+func (sdbs *sysDBSuite) TestOpenSysDatabaseLocalTrustedHighestLocalRevision(c *C) {
+	restore := sysdb.MockTrustedRootsOwner(uint32(os.Getuid()))
+	defer restore()
+
+	// the root key is only trusted from the local files, where a later
+	// revision revokes it
+	revokedAccKey := assertstest.NewAccountKey(sdbs.signingDB, sdbs.trustedAcct, map[string]interface{}{
+		"revision": "1",
+		"since":    "2015-11-20T15:04:00Z",
+		"until":    "2016-11-20T15:04:00Z",
+	}, sdbs.rootKey.PublicKey(), "")
+	sdbs.writeTrustedRoots(c, "00-can0nical.assert", revokedAccKey)
+	sdbs.writeTrustedRoots(c, "10-can0nical.assert", sdbs.extraTrusted...)
+
+	db, err := sysdb.Open()
+	c.Assert(err, IsNil)
+
+	err = db.Check(sdbs.probeAssert)
+	c.Check(err, ErrorMatches, `assertion is signed with expired public key .*`)
+}
+
+func (sdbs *sysDBSuite) TestOpenSysDatabaseLocalTrustedBadFilesIgnored(c *C) {
+	restore := sysdb.MockTrustedRootsOwner(uint32(os.Getuid()))
+	defer restore()
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	// the valid file is still used
+	sdbs.writeTrustedRoots(c, "can0nical.assert", sdbs.extraTrusted...)
+	sdbs.writeTrustedRoots(c, "model.assert", sdbs.otherModel)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapTrustedRootsDir, "garbage.assert"), []byte("garbage"), 0644), IsNil)
+	sdbs.writeTrustedRoots(c, "writable.assert", sdbs.extraTrusted...)
+	c.Assert(os.Chmod(filepath.Join(dirs.SnapTrustedRootsDir, "writable.assert"), 0666), IsNil)
+
+	local, err := sysdb.LocalTrusted()
+	c.Assert(err, IsNil)
+	c.Check(local, DeepEquals, sdbs.extraTrusted)
+
+	db, err := sysdb.Open()
+	c.Assert(err, IsNil)
+	c.Check(db.Check(sdbs.probeAssert), IsNil)
+
+	c.Check(logbuf.String(), Matches, `(?s).*ignoring trusted roots file: cannot decode trusted roots from .*/garbage.assert: .*`)
+	c.Check(logbuf.String(), Matches, `(?s).*ignoring trusted roots file: cannot use model assertion from .*/model.assert as trusted, only account and account-key assertions are supported.*`)
+	c.Check(logbuf.String(), Matches, `(?s).*ignoring trusted roots file: trusted roots unexpectedly writable by group or others: .*/writable.assert.*`)
+}
+
+func (sdbs *sysDBSuite) TestOpenSysDatabaseLocalTrustedDirErrors(c *C) {
+	restore := sysdb.MockTrustedRootsOwner(uint32(os.Getuid()))
+	defer restore()
+
+	sdbs.writeTrustedRoots(c, "can0nical.assert", sdbs.extraTrusted...)
+	c.Assert(os.Chmod(dirs.SnapTrustedRootsDir, 0777), IsNil)
+	_, err := sysdb.Open()
+	c.Check(err, ErrorMatches, `trusted roots unexpectedly writable by group or others: .*/trusted-roots`)
+
+	c.Assert(os.Chmod(dirs.SnapTrustedRootsDir, 0755), IsNil)
+	restore = sysdb.MockTrustedRootsOwner(uint32(os.Getuid()) + 1)
+	defer restore()
+	_, err = sysdb.Open()
+	c.Check(err, ErrorMatches, `trusted roots unexpectedly not owned by root: .*/trusted-roots`)
+}
@@ -55,6 +55,11 @@ type AppInfo struct {
 	Active      bool             `json:"active,omitempty"`
 	CommonID    string           `json:"common-id,omitempty"`
 	Activators  []AppActivator   `json:"activators,omitempty"`
+	// Restarts is the number of automatic restarts of the service
+	// observed by snapd.
+	Restarts int `json:"restarts,omitempty"`
+	// Failed is set when snapd stopped the service for crash-looping.
+	Failed bool `json:"failed,omitempty"`
 }
 
 // IsService returns true if the application is a background daemon.
@@ -0,0 +1,55 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// AspectGet asks for the values of the given fields of the aspect identified
+// by aspectID, which takes the form <account>/<bundle>/<aspect>.
+//
+// Note that the values may include json.Numbers.
+func (client *Client) AspectGet(aspectID string, fields []string) (result map[string]interface{}, err error) {
+	query := url.Values{}
+	query.Set("fields", strings.Join(fields, ","))
+
+	_, err = client.doSync("GET", "/v2/aspects/"+aspectID, query, nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// AspectSet sets the fields of the aspect identified by aspectID, which takes
+// the form <account>/<bundle>/<aspect>. A nil value unsets the field.
+func (client *Client) AspectSet(aspectID string, values map[string]interface{}) error {
+	b, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.doSync("PUT", "/v2/aspects/"+aspectID, nil, nil, bytes.NewReader(b), nil)
+	return err
+}
@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+
+	"gopkg.in/check.v1"
+)
+
+func (cs *clientSuite) TestClientAspectGet(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {"ssid": "foo", "count": 1234567890}
+	}`
+	value, err := cs.cli.AspectGet("system/network/wifi-setup", []string{"ssid", "count"})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/aspects/system/network/wifi-setup")
+	c.Check(cs.req.URL.Query().Get("fields"), check.Equals, "ssid,count")
+	c.Check(value, check.DeepEquals, map[string]interface{}{
+		"ssid":  "foo",
+		"count": json.Number("1234567890"),
+	})
+}
+
+func (cs *clientSuite) TestClientAspectGetError(c *check.C) {
+	cs.status = 404
+	cs.rsp = `{
+		"type": "error",
+		"status-code": 404,
+		"result": {"message": "aspect system/network/wifi-setup not found"}
+	}`
+	_, err := cs.cli.AspectGet("system/network/wifi-setup", []string{"ssid"})
+	c.Assert(err, check.ErrorMatches, "aspect system/network/wifi-setup not found")
+}
+
+func (cs *clientSuite) TestClientAspectSet(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": null
+	}`
+	err := cs.cli.AspectSet("system/network/wifi-setup", map[string]interface{}{"ssid": "foo", "psk": nil})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "PUT")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/aspects/system/network/wifi-setup")
+
+	var body map[string]interface{}
+	err = json.NewDecoder(cs.req.Body).Decode(&body)
+	c.Assert(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"ssid": "foo",
+		"psk":  nil,
+	})
+}
@@ -39,10 +39,25 @@ type Change struct {
 
 	SpawnTime time.Time `json:"spawn-time,omitempty"`
 	ReadyTime time.Time `json:"ready-time,omitempty"`
+	// NotBefore is the time before which the change is held, if it
+	// was scheduled.
+	NotBefore time.Time `json:"not-before,omitempty"`
+
+	RequestedBy *ChangeRequester `json:"requested-by,omitempty"`
 
 	data map[string]*json.RawMessage
 }
 
+// ChangeRequester is who asked for a change.
+type ChangeRequester struct {
+	// UID is the uid of the process that made the request.
+	UID uint32 `json:"uid"`
+	// UserID and Username identify the snapd user the request was
+	// authenticated as, if any.
+	UserID   int    `json:"user-id,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
 var ErrNoData = fmt.Errorf("data entry not found")
 
 // Get unmarshals into value the kind-specific data with the provided key.
@@ -162,3 +177,50 @@ func (client *Client) Changes(opts *ChangesOptions) ([]*Change, error) {
 
 	return chgs, err
 }
+
+// ChangeHistoryOptions selects the archived changes returned by
+// ChangeHistory.
+type ChangeHistoryOptions struct {
+	SnapName string // if empty, no filtering by name is done
+	Kind     string
+	Status   string
+	// RequestedBy restricts the changes to the ones requested by the
+	// uid, if it is a number, or else by the snapd user with the name.
+	RequestedBy string
+	// Since and Until restrict the changes to the ones spawned in the
+	// time range, either can be zero to leave the range open.
+	Since time.Time
+	Until time.Time
+}
+
+// ChangeHistory returns the changes archived once they were pruned from
+// the state, from the oldest to the newest.
+func (client *Client) ChangeHistory(opts *ChangeHistoryOptions) ([]*Change, error) {
+	query := url.Values{}
+	if opts != nil {
+		if opts.SnapName != "" {
+			query.Set("for", opts.SnapName)
+		}
+		if opts.Kind != "" {
+			query.Set("kind", opts.Kind)
+		}
+		if opts.Status != "" {
+			query.Set("status", opts.Status)
+		}
+		if opts.RequestedBy != "" {
+			query.Set("requested-by", opts.RequestedBy)
+		}
+		if !opts.Since.IsZero() {
+			query.Set("since", opts.Since.Format(time.RFC3339))
+		}
+		if !opts.Until.IsZero() {
+			query.Set("until", opts.Until.Format(time.RFC3339))
+		}
+	}
+
+	var chgs []*Change
+	if _, err := client.doSync("GET", "/v2/changes/history", query, nil, nil, &chgs); err != nil {
+		return nil, err
+	}
+	return chgs, nil
+}
@@ -61,6 +61,22 @@ func (cs *clientSuite) TestClientChange(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestClientChangeNotBefore(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+  "id":   "uno",
+  "kind": "foo",
+  "summary": "...",
+  "status": "Do",
+  "ready": false,
+  "spawn-time": "2016-04-21T01:02:03Z",
+  "not-before": "2016-04-22T01:00:00Z"
+}}`
+
+	chg, err := cs.cli.Change("uno")
+	c.Assert(err, check.IsNil)
+	c.Check(chg.NotBefore, check.Equals, time.Date(2016, 04, 22, 1, 0, 0, 0, time.UTC))
+}
+
 func (cs *clientSuite) TestClientChangeData(c *check.C) {
 	cs.rsp = `{"type": "sync", "result": {
   "id":   "uno",
@@ -232,3 +248,58 @@ func (cs *clientSuite) TestClientAbort(c *check.C) {
 
 	c.Assert(string(body), check.Equals, "{\"action\":\"abort\"}\n")
 }
+
+func (cs *clientSuite) TestClientChangeHistory(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{
+  "id":   "uno",
+  "kind": "foo",
+  "summary": "...",
+  "status": "Error",
+  "ready": true,
+  "err": "boom",
+  "spawn-time": "2023-01-02T03:04:05Z",
+  "ready-time": "2023-01-02T03:04:06Z",
+  "requested-by": {"uid": 1000, "user-id": 1, "username": "user"},
+  "tasks": [{"kind": "bar", "summary": "...", "status": "Error", "log": ["2023-01-02T03:04:06Z ERROR boom"]}]
+}]}`
+
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		opts  *client.ChangeHistoryOptions
+		query string
+	}{
+		{nil, ""},
+		{&client.ChangeHistoryOptions{SnapName: "foo"}, "for=foo"},
+		{&client.ChangeHistoryOptions{Kind: "refresh-snap", Status: "Error"}, "kind=refresh-snap&status=Error"},
+		{&client.ChangeHistoryOptions{RequestedBy: "1000"}, "requested-by=1000"},
+		{&client.ChangeHistoryOptions{Since: since, Until: until}, "since=2023-01-01T00%3A00%3A00Z&until=2023-02-01T00%3A00%3A00Z"},
+	} {
+		chgs, err := cs.cli.ChangeHistory(tc.opts)
+		c.Assert(err, check.IsNil)
+		c.Check(cs.req.Method, check.Equals, "GET")
+		c.Check(cs.req.URL.Path, check.Equals, "/v2/changes/history")
+		c.Check(cs.req.URL.RawQuery, check.Equals, tc.query)
+		c.Check(chgs, check.DeepEquals, []*client.Change{{
+			ID:        "uno",
+			Kind:      "foo",
+			Summary:   "...",
+			Status:    "Error",
+			Ready:     true,
+			Err:       "boom",
+			SpawnTime: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+			ReadyTime: time.Date(2023, 1, 2, 3, 4, 6, 0, time.UTC),
+			RequestedBy: &client.ChangeRequester{
+				UID:      1000,
+				UserID:   1,
+				Username: "user",
+			},
+			Tasks: []*client.Task{{
+				Kind:    "bar",
+				Summary: "...",
+				Status:  "Error",
+				Log:     []string{"2023-01-02T03:04:06Z ERROR boom"},
+			}},
+		}})
+	}
+}
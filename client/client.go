@@ -684,6 +684,8 @@ type SysInfo struct {
 	KernelVersion  string `json:"kernel-version,omitempty"`
 	Architecture   string `json:"architecture,omitempty"`
 	Virtualization string `json:"virtualization,omitempty"`
+	// Registration is "disabled" if the device runs without registering
+	Registration string `json:"registration,omitempty"`
 
 	Refresh         RefreshInfo         `json:"refresh,omitempty"`
 	Confinement     string              `json:"confinement"`
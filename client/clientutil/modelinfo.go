@@ -87,6 +87,9 @@ type PrintModelAssertionOptions struct {
 	// without any prior processing, which means if set, it will serialize
 	// the entire assertion as-is.
 	Assertion bool
+	// RegistrationDisabled indicates that the device runs without
+	// registering, so it is not expected to have a serial assertion.
+	RegistrationDisabled bool
 }
 
 func fmtTime(t time.Time, abs bool) string {
@@ -317,7 +320,11 @@ func PrintModelAssertion(w *tabwriter.Writer, modelAssertion asserts.Model, seri
 		} else {
 			serial = "-"
 		}
-		serial += " (device not registered yet)"
+		if opts.RegistrationDisabled {
+			serial += " (device registration disabled)"
+		} else {
+			serial += " (device not registered yet)"
+		}
 	} else {
 		serial = serialAssertion.HeaderString("serial")
 	}
@@ -22,6 +22,7 @@
 package clientutil
 
 import (
+	"fmt"
 	"sort"
 	"strings"
 
@@ -96,7 +97,7 @@ func ClientAppInfoNotes(app *client.AppInfo) string {
 		return "-"
 	}
 
-	var notes = make([]string, 0, 4)
+	var notes = make([]string, 0, 5)
 	if app.DaemonScope == snap.UserDaemon {
 		notes = append(notes, "user")
 	}
@@ -120,6 +121,9 @@ func ClientAppInfoNotes(app *client.AppInfo) string {
 	if seenDbus {
 		notes = append(notes, "dbus-activated")
 	}
+	if app.Restarts > 0 {
+		notes = append(notes, fmt.Sprintf("restarts=%d", app.Restarts))
+	}
 	if len(notes) == 0 {
 		return "-"
 	}
@@ -325,4 +325,10 @@ func (*cmdSuite) TestAppStatusNotes(c *C) {
 		},
 	}
 	c.Check(clientutil.ClientAppInfoNotes(&ai), Equals, "user,timer-activated,socket-activated,dbus-activated")
+
+	ai = client.AppInfo{
+		Daemon:   "simple",
+		Restarts: 3,
+	}
+	c.Check(clientutil.ClientAppInfoNotes(&ai), Equals, "restarts=3")
 }
@@ -33,6 +33,7 @@ import (
 
 type remodelData struct {
 	NewModel string `json:"new-model"`
+	DryRun   bool   `json:"dry-run,omitempty"`
 }
 
 // Remodel tries to remodel the system with the given assertion data
@@ -50,6 +51,27 @@ func (client *Client) Remodel(b []byte) (changeID string, err error) {
 	return client.doAsync("POST", "/v2/model", nil, headers, bytes.NewReader(data))
 }
 
+// RemodelPlan returns what remodeling the system with the given assertion
+// data would do, without doing it.
+func (client *Client) RemodelPlan(b []byte) (*Plan, error) {
+	data, err := json.Marshal(&remodelData{
+		NewModel: string(b),
+		DryRun:   true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal remodel data: %v", err)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	var plan Plan
+	if _, err := client.doSync("POST", "/v2/model", nil, headers, bytes.NewReader(data), &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
 // CurrentModelAssertion returns the current model assertion
 func (client *Client) CurrentModelAssertion() (*asserts.Model, error) {
 	assert, err := currentAssertion(client, "/v2/model")
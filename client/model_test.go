@@ -29,6 +29,8 @@ import (
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
 )
 
 const happyModelAssertionResponse = `type: model
@@ -129,6 +131,48 @@ func (cs *clientSuite) TestClientRemodel(c *C) {
 	c.Check(jsonBody["new-model"], Equals, string(remodelJsonData))
 }
 
+func (cs *clientSuite) TestClientRemodelPlan(c *C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"snaps": [{"name": "foo", "action": "refresh", "revision": "5"}, {"name": "bar", "action": "install", "revision": "2", "auto-connections-unknown": true}],
+			"auto-connections": ["foo:network core:network"],
+			"reboot-required": true
+		}
+	}`
+	remodelJsonData := []byte(`{"new-model": "some-model"}`)
+	plan, err := cs.cli.RemodelPlan(remodelJsonData)
+	c.Assert(err, IsNil)
+	c.Check(cs.req.Method, Equals, "POST")
+	c.Check(cs.req.URL.Path, Equals, "/v2/model")
+	c.Assert(cs.req.Header.Get("Content-Type"), Equals, "application/json")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, IsNil)
+	var jsonBody map[string]interface{}
+	err = json.Unmarshal(body, &jsonBody)
+	c.Assert(err, IsNil)
+	c.Check(jsonBody, DeepEquals, map[string]interface{}{
+		"new-model": string(remodelJsonData),
+		"dry-run":   true,
+	})
+	c.Check(plan, DeepEquals, &client.Plan{
+		Snaps: []*client.PlannedSnap{{
+			Name:     "foo",
+			Action:   "refresh",
+			Revision: snap.R(5),
+		}, {
+			Name:                   "bar",
+			Action:                 "install",
+			Revision:               snap.R(2),
+			AutoConnectionsUnknown: true,
+		}},
+		AutoConnections: []string{"foo:network core:network"},
+		RebootRequired:  true,
+	})
+}
+
 func (cs *clientSuite) TestClientGetModelHappy(c *C) {
 	cs.status = 200
 	cs.rsp = happyModelAssertionResponse
@@ -0,0 +1,85 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A Notice records an event, such as a change to the configuration of a snap
+// or to the data of an aspect bundle.
+type Notice struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	Key      string    `json:"key"`
+	Occurred time.Time `json:"occurred"`
+	// ChangeID is the ID of the change that caused the event, if any.
+	ChangeID string `json:"change-id,omitempty"`
+	// ChangedKeys are the keys affected by the event.
+	ChangedKeys []string `json:"changed-keys,omitempty"`
+}
+
+// NoticesOptions selects the notices to return. Zero fields match everything.
+type NoticesOptions struct {
+	// Types selects notices of any of these types.
+	Types []string
+	// Keys selects notices with any of these keys.
+	Keys []string
+	// After selects notices added after the notice with this ID.
+	After string
+	// Timeout makes snapd wait up to this long for a matching notice
+	// if there isn't one already.
+	Timeout time.Duration
+}
+
+// Notices returns the notices matching the options, optionally waiting for
+// new ones.
+func (client *Client) Notices(opts *NoticesOptions) ([]*Notice, error) {
+	if opts == nil {
+		opts = &NoticesOptions{}
+	}
+
+	q := make(url.Values)
+	if len(opts.Types) > 0 {
+		q.Set("types", strings.Join(opts.Types, ","))
+	}
+	if len(opts.Keys) > 0 {
+		q.Set("keys", strings.Join(opts.Keys, ","))
+	}
+	if opts.After != "" {
+		q.Set("after", opts.After)
+	}
+
+	var doOpts *doOptions
+	if opts.Timeout > 0 {
+		q.Set("timeout", opts.Timeout.String())
+		// leave snapd enough time to answer after waiting
+		doOpts = &doOptions{
+			Timeout: opts.Timeout + doTimeout,
+			Retry:   doRetry,
+		}
+	}
+
+	var notices []*Notice
+	_, err := client.doSyncWithOpts("GET", "/v2/notices", q, nil, nil, &notices, doOpts)
+	return notices, err
+}
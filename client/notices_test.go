@@ -0,0 +1,104 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"net/url"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestNotices(c *check.C) {
+	cs.rsp = `{
+		"result": [
+		    {
+			"id": "1",
+			"type": "snap-config",
+			"key": "foo",
+			"occurred": "2023-09-19T12:41:18.505007495Z",
+			"change-id": "42",
+			"changed-keys": ["a.b", "c"]
+		    },
+		    {
+			"id": "2",
+			"type": "aspect",
+			"key": "system/network",
+			"occurred": "2023-09-19T12:44:19.680362867Z",
+			"changed-keys": ["wifi.ssid"]
+		    }
+		],
+		"status": "OK",
+		"status-code": 200,
+		"type": "sync"
+	}`
+
+	notices, err := cs.cli.Notices(nil)
+	c.Assert(err, check.IsNil)
+	c.Check(notices, check.DeepEquals, []*client.Notice{
+		{
+			ID:          "1",
+			Type:        "snap-config",
+			Key:         "foo",
+			Occurred:    time.Date(2023, 9, 19, 12, 41, 18, 505007495, time.UTC),
+			ChangeID:    "42",
+			ChangedKeys: []string{"a.b", "c"},
+		},
+		{
+			ID:          "2",
+			Type:        "aspect",
+			Key:         "system/network",
+			Occurred:    time.Date(2023, 9, 19, 12, 44, 19, 680362867, time.UTC),
+			ChangedKeys: []string{"wifi.ssid"},
+		},
+	})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/notices")
+	c.Check(cs.req.URL.Query(), check.HasLen, 0)
+}
+
+func (cs *clientSuite) TestNoticesOptions(c *check.C) {
+	cs.rsp = `{"type": "sync", "status-code": 200, "result": []}`
+
+	notices, err := cs.cli.Notices(&client.NoticesOptions{
+		Types:   []string{"snap-config", "aspect"},
+		Keys:    []string{"foo", "bar"},
+		After:   "3",
+		Timeout: 30 * time.Second,
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(notices, check.HasLen, 0)
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"types":   []string{"snap-config,aspect"},
+		"keys":    []string{"foo,bar"},
+		"after":   []string{"3"},
+		"timeout": []string{"30s"},
+	})
+}
+
+func (cs *clientSuite) TestNoticesError(c *check.C) {
+	cs.status = 400
+	cs.rsp = `{"type": "error", "result": {"message": "invalid notice type \"foo\""}}`
+
+	_, err := cs.cli.Notices(&client.NoticesOptions{Types: []string{"foo"}})
+	c.Assert(err, check.ErrorMatches, `invalid notice type "foo"`)
+}
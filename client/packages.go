@@ -137,6 +137,9 @@ type FindOptions struct {
 	Section string
 	Private bool
 	Scope   string
+	// Architectures optionally lists the acceptable architectures in
+	// order of preference.
+	Architectures []string
 
 	Refresh bool
 }
@@ -237,6 +240,9 @@ func (client *Client) Find(opts *FindOptions) ([]*Snap, *ResultInfo, error) {
 	if opts.Scope != "" {
 		q.Set("scope", opts.Scope)
 	}
+	if len(opts.Architectures) > 0 {
+		q.Set("architectures", strings.Join(opts.Architectures, ","))
+	}
 
 	return client.snapsFromPath("/v2/find", q)
 }
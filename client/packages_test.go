@@ -120,6 +120,19 @@ func (cs *clientSuite) TestClientFindWithScopeSetsQuery(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestClientFindWithArchitecturesSetsQuery(c *check.C) {
+	_, _, _ = cs.cli.Find(&client.FindOptions{
+		Query:         "foo",
+		Architectures: []string{"arm64", "armhf"},
+	})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/find")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"q":             []string{"foo"},
+		"architectures": []string{"arm64,armhf"},
+	})
+}
+
 func (cs *clientSuite) TestClientSnapsInvalidSnapsJSON(c *check.C) {
 	cs.rsp = `{
 		"type": "sync",
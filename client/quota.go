@@ -66,12 +66,80 @@ type QuotaJournalValues struct {
 	*QuotaJournalRate
 }
 
+// QuotaIOValues holds pointers as a zero limit removes it from a group
+type QuotaIOValues struct {
+	ReadBandwidth  *quantity.Size `json:"read-bandwidth,omitempty"`
+	WriteBandwidth *quantity.Size `json:"write-bandwidth,omitempty"`
+	ReadIOPS       *int           `json:"read-iops,omitempty"`
+	WriteIOPS      *int           `json:"write-iops,omitempty"`
+	Weight         *int           `json:"weight,omitempty"`
+}
+
+// QuotaNetworkValues holds pointers as a zero limit removes it from a group
+type QuotaNetworkValues struct {
+	IngressBandwidth *quantity.Size `json:"ingress-bandwidth,omitempty"`
+	EgressBandwidth  *quantity.Size `json:"egress-bandwidth,omitempty"`
+}
+
 type QuotaValues struct {
 	Memory  quantity.Size       `json:"memory,omitempty"`
 	CPU     *QuotaCPUValues     `json:"cpu,omitempty"`
 	CPUSet  *QuotaCPUSetValues  `json:"cpu-set,omitempty"`
 	Threads int                 `json:"threads,omitempty"`
 	Journal *QuotaJournalValues `json:"journal,omitempty"`
+	IO      *QuotaIOValues      `json:"io,omitempty"`
+	Network *QuotaNetworkValues `json:"network,omitempty"`
+
+	MemoryHigh quantity.Size `json:"memory-high,omitempty"`
+	// MemorySwap is a pointer as a zero limit disables swap
+	MemorySwap *quantity.Size `json:"memory-swap,omitempty"`
+	OOMPolicy  string         `json:"oom-policy,omitempty"`
+}
+
+// QuotaResourceUsage is the resource usage of a quota group, or of a snap
+// app or hook, at some point in time. The rates are averaged over the time
+// since the previous sample.
+type QuotaResourceUsage struct {
+	// CPUPercentage is the cpu time used relative to the time passed, 100
+	// meaning one cpu fully used.
+	CPUPercentage float64       `json:"cpu-percentage"`
+	Memory        quantity.Size `json:"memory"`
+	MemoryPeak    quantity.Size `json:"memory-peak,omitempty"`
+	// IOReadRate and IOWriteRate are in bytes per second.
+	IOReadRate  quantity.Size `json:"io-read-rate"`
+	IOWriteRate quantity.Size `json:"io-write-rate"`
+	// IOReadOpsRate and IOWriteOpsRate are in operations per second.
+	IOReadOpsRate  float64 `json:"io-read-ops-rate"`
+	IOWriteOpsRate float64 `json:"io-write-ops-rate"`
+	Tasks          int     `json:"tasks"`
+}
+
+type QuotaUsageSample struct {
+	Time time.Time `json:"time"`
+	QuotaResourceUsage
+}
+
+type QuotaAppUsage struct {
+	Snap string `json:"snap"`
+	// App is the name of the app, or hook.<name> for hooks.
+	App string `json:"app"`
+	QuotaResourceUsage
+}
+
+type QuotaGroupUsage struct {
+	GroupName string `json:"group-name"`
+	// Apps is the usage of the running apps and hooks of the group as of
+	// the latest sample, sorted by name.
+	Apps []QuotaAppUsage `json:"apps,omitempty"`
+	// History is the usage of the whole group, oldest sample first.
+	History []QuotaUsageSample `json:"history,omitempty"`
+}
+
+// SnapUsage is the resource usage of all the running apps and hooks of a
+// snap as of the latest sample, whether the snap is in a quota group or not.
+type SnapUsage struct {
+	Snap string `json:"snap"`
+	QuotaResourceUsage
 }
 
 type EnsureQuotaOptions struct {
@@ -162,3 +230,30 @@ func (client *Client) Quotas() ([]*QuotaGroupResult, error) {
 
 	return res, nil
 }
+
+// QuotaGroupUsage returns the recent resource usage of the given quota group
+// and of the apps in it.
+func (client *Client) QuotaGroupUsage(groupName string) (*QuotaGroupUsage, error) {
+	if groupName == "" {
+		return nil, fmt.Errorf("cannot get quota group usage without a name")
+	}
+
+	var res *QuotaGroupUsage
+	path := fmt.Sprintf("/v2/quotas/%s/usage", groupName)
+	if _, err := client.doSync("GET", path, nil, nil, nil, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// SnapsUsage returns the latest resource usage of each snap with running
+// apps or hooks, sorted by snap name.
+func (client *Client) SnapsUsage() ([]*SnapUsage, error) {
+	var res []*SnapUsage
+	if _, err := client.doSync("GET", "/v2/usage", nil, nil, nil, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
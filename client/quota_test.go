@@ -162,6 +162,73 @@ func (cs *clientSuite) TestGetQuotaGroupError(c *check.C) {
 	c.Check(err, check.ErrorMatches, `server error: "Internal Server Error"`)
 }
 
+func (cs *clientSuite) TestQuotaGroupUsageInvalidName(c *check.C) {
+	_, err := cs.cli.QuotaGroupUsage("")
+	c.Assert(err, check.ErrorMatches, `cannot get quota group usage without a name`)
+}
+
+func (cs *clientSuite) TestQuotaGroupUsage(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name":"foo",
+			"apps": [{"snap":"snap-a","app":"svc1","cpu-percentage":12.5,"memory":1000,"io-read-rate":0,"io-write-rate":10,"io-read-ops-rate":0,"io-write-ops-rate":1,"tasks":2}],
+			"history": [{"time":"2023-01-02T03:04:05Z","cpu-percentage":12.5,"memory":1000,"io-read-rate":0,"io-write-rate":10,"io-read-ops-rate":0,"io-write-ops-rate":1,"tasks":2}]
+		}
+	}`
+
+	usage, err := cs.cli.QuotaGroupUsage("foo")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/quotas/foo/usage")
+	resourceUsage := client.QuotaResourceUsage{
+		CPUPercentage:  12.5,
+		Memory:         1000,
+		IOWriteRate:    10,
+		IOWriteOpsRate: 1,
+		Tasks:          2,
+	}
+	c.Check(usage, check.DeepEquals, &client.QuotaGroupUsage{
+		GroupName: "foo",
+		Apps: []client.QuotaAppUsage{
+			{Snap: "snap-a", App: "svc1", QuotaResourceUsage: resourceUsage},
+		},
+		History: []client.QuotaUsageSample{
+			{Time: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), QuotaResourceUsage: resourceUsage},
+		},
+	})
+}
+
+func (cs *clientSuite) TestSnapsUsage(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [
+			{"snap":"snap-a","cpu-percentage":12.5,"memory":1000,"io-read-rate":0,"io-write-rate":10,"io-read-ops-rate":0,"io-write-ops-rate":1,"tasks":2},
+			{"snap":"snap-b","cpu-percentage":0,"memory":5000,"io-read-rate":0,"io-write-rate":0,"io-read-ops-rate":0,"io-write-ops-rate":0,"tasks":4}
+		]
+	}`
+
+	usage, err := cs.cli.SnapsUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/usage")
+	c.Check(usage, check.DeepEquals, []*client.SnapUsage{{
+		Snap: "snap-a",
+		QuotaResourceUsage: client.QuotaResourceUsage{
+			CPUPercentage:  12.5,
+			Memory:         1000,
+			IOWriteRate:    10,
+			IOWriteOpsRate: 1,
+			Tasks:          2,
+		},
+	}, {
+		Snap:               "snap-b",
+		QuotaResourceUsage: client.QuotaResourceUsage{Memory: 5000, Tasks: 4},
+	}})
+}
+
 func (cs *clientSuite) TestRemoveQuotaGroup(c *check.C) {
 	cs.status = 202
 	cs.rsp = `{
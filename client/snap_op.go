@@ -28,6 +28,8 @@ import (
 	"mime/multipart"
 	"os"
 	"path/filepath"
+
+	"github.com/snapcore/snapd/snap"
 )
 
 // TransactionType says whether we want to treat each snap separately
@@ -61,8 +63,13 @@ type SnapOptions struct {
 	ValidationSets   []string        `json:"validation-sets,omitempty"`
 	Time             string          `json:"time,omitempty"`
 	HoldLevel        string          `json:"hold-level,omitempty"`
+	Architectures    []string        `json:"architectures,omitempty"`
+	// Priority is the priority of the change, one of "low", "normal"
+	// or "high", for installs and refreshes from the store.
+	Priority string `json:"priority,omitempty"`
 
-	Users []string `json:"users,omitempty"`
+	Users   []string `json:"users,omitempty"`
+	Encrypt bool     `json:"encrypt,omitempty"`
 }
 
 func writeFieldBool(mw *multipart.Writer, key string, val bool) error {
@@ -132,6 +139,10 @@ type multiActionData struct {
 	ValidationSets []string        `json:"validation-sets,omitempty"`
 	Time           string          `json:"time,omitempty"`
 	HoldLevel      string          `json:"hold-level,omitempty"`
+	Encrypt        bool            `json:"encrypt,omitempty"`
+	Priority       string          `json:"priority,omitempty"`
+	DryRun         bool            `json:"dry-run,omitempty"`
+	Architectures  []string        `json:"architectures,omitempty"`
 }
 
 // Install adds the snap with the given name from the given channel (or
@@ -199,7 +210,13 @@ func (client *Client) Switch(name string, options *SnapOptions) (changeID string
 
 // SnapshotMany snapshots many snaps (all, if names empty) for many users (all, if users is empty).
 func (client *Client) SnapshotMany(names []string, users []string) (setID uint64, changeID string, err error) {
-	result, changeID, err := client.doMultiSnapActionFull("snapshot", names, &SnapOptions{Users: users})
+	return client.SnapshotManyWithOptions(names, &SnapOptions{Users: users})
+}
+
+// SnapshotManyWithOptions is like SnapshotMany but takes the users, and
+// whether to encrypt the snapshots, from the given options.
+func (client *Client) SnapshotManyWithOptions(names []string, options *SnapOptions) (setID uint64, changeID string, err error) {
+	result, changeID, err := client.doMultiSnapActionFull("snapshot", names, options)
 	if err != nil {
 		return 0, "", err
 	}
@@ -244,7 +261,60 @@ func (client *Client) doMultiSnapAction(actionName string, snaps []string, optio
 	return changeID, err
 }
 
-func (client *Client) doMultiSnapActionFull(actionName string, snaps []string, options *SnapOptions) (result json.RawMessage, changeID string, err error) {
+// PlannedSnap describes what a snap operation would do to a snap.
+type PlannedSnap struct {
+	Name string `json:"name"`
+	// Action is one of "install", "refresh" or "remove".
+	Action       string        `json:"action"`
+	Revision     snap.Revision `json:"revision"`
+	Channel      string        `json:"channel,omitempty"`
+	Type         string        `json:"type,omitempty"`
+	DownloadSize int64         `json:"download-size,omitempty"`
+	// AutoConnectionsUnknown is set if the auto-connections of the snap
+	// are only known once it is downloaded, as its plugs and slots or its
+	// snap declaration are not available yet. They are then not part of
+	// the AutoConnections of the plan.
+	AutoConnectionsUnknown bool `json:"auto-connections-unknown,omitempty"`
+}
+
+// Plan describes what a snap operation would do, as found without doing it.
+type Plan struct {
+	Snaps []*PlannedSnap `json:"snaps,omitempty"`
+	// Prerequisites are the bases and default content providers that
+	// would be installed along.
+	Prerequisites []string `json:"prerequisites,omitempty"`
+	// AutoConnections are the connections that would be made
+	// automatically for the installed and refreshed revisions of the
+	// snaps, leaving out the snaps with AutoConnectionsUnknown set.
+	AutoConnections   []string `json:"auto-connections,omitempty"`
+	RestartedServices []string `json:"restarted-services,omitempty"`
+	RebootRequired    bool     `json:"reboot-required,omitempty"`
+	DownloadSize      int64    `json:"download-size,omitempty"`
+}
+
+// Plan returns what installing, refreshing or removing the snaps with the
+// given names would do, without doing it. Refreshing without names plans
+// the refresh of all snaps.
+func (client *Client) Plan(actionName string, names []string, options *SnapOptions) (*Plan, error) {
+	action := newMultiActionData(actionName, names, options)
+	action.DryRun = true
+	data, err := json.Marshal(&action)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal multi-snap action: %s", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	var plan Plan
+	if _, err := client.doSync("POST", "/v2/snaps", nil, headers, bytes.NewBuffer(data), &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func newMultiActionData(actionName string, snaps []string, options *SnapOptions) multiActionData {
 	action := multiActionData{
 		Action: actionName,
 		Snaps:  snaps,
@@ -258,8 +328,15 @@ func (client *Client) doMultiSnapActionFull(actionName string, snaps []string, o
 		action.ValidationSets = options.ValidationSets
 		action.Time = options.Time
 		action.HoldLevel = options.HoldLevel
+		action.Encrypt = options.Encrypt
+		action.Priority = options.Priority
+		action.Architectures = options.Architectures
 	}
+	return action
+}
 
+func (client *Client) doMultiSnapActionFull(actionName string, snaps []string, options *SnapOptions) (result json.RawMessage, changeID string, err error) {
+	action := newMultiActionData(actionName, snaps, options)
 	data, err := json.Marshal(&action)
 	if err != nil {
 		return nil, "", fmt.Errorf("cannot marshal multi-snap action: %s", err)
@@ -417,6 +494,8 @@ type snapRevisionOptions struct {
 	Channel   string `json:"channel,omitempty"`
 	Revision  string `json:"revision,omitempty"`
 	CohortKey string `json:"cohort-key,omitempty"`
+
+	Architectures []string `json:"architectures,omitempty"`
 }
 
 type downloadAction struct {
@@ -451,9 +530,10 @@ func (client *Client) Download(name string, options *DownloadOptions) (dlInfo *D
 	action := downloadAction{
 		SnapName: name,
 		snapRevisionOptions: snapRevisionOptions{
-			Channel:   options.Channel,
-			CohortKey: options.CohortKey,
-			Revision:  options.Revision,
+			Channel:       options.Channel,
+			CohortKey:     options.CohortKey,
+			Revision:      options.Revision,
+			Architectures: options.Architectures,
 		},
 		HeaderPeek:  options.HeaderPeek,
 		ResumeToken: options.ResumeToken,
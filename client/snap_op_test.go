@@ -33,6 +33,7 @@ import (
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -253,6 +254,28 @@ func (cs *clientSuite) TestClientMultiOpSnapIgnoreRunning(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientMultiOpSnapArchitectures(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	for _, op := range []func(*client.Client, []string, *client.SnapOptions) (string, error){
+		(*client.Client).InstallMany,
+		(*client.Client).RefreshMany,
+	} {
+		_, err := op(cs.cli, []string{"foo", "bar"}, &client.SnapOptions{Architectures: []string{"arm64", "armhf"}})
+		c.Assert(err, check.IsNil)
+
+		body, err := ioutil.ReadAll(cs.req.Body)
+		c.Assert(err, check.IsNil)
+		jsonBody := make(map[string]interface{})
+		c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+		c.Check(jsonBody["architectures"], check.DeepEquals, []interface{}{"arm64", "armhf"})
+	}
+}
+
 func (cs *clientSuite) TestClientMultiSnapshot(c *check.C) {
 	// Note body is essentially the same as TestClientMultiOpSnap; keep in sync
 	cs.status = 202
@@ -279,6 +302,35 @@ func (cs *clientSuite) TestClientMultiSnapshot(c *check.C) {
 	c.Check(changeID, check.Equals, "d728")
 }
 
+func (cs *clientSuite) TestClientMultiSnapshotEncrypted(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+                "result": {"set-id": 42},
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	setID, changeID, err := cs.cli.SnapshotManyWithOptions([]string{pkgName}, &client.SnapOptions{
+		Users:   []string{"user1"},
+		Encrypt: true,
+	})
+	c.Assert(err, check.IsNil)
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	jsonBody := make(map[string]interface{})
+	err = json.Unmarshal(body, &jsonBody)
+	c.Assert(err, check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":  "snapshot",
+		"snaps":   []interface{}{pkgName},
+		"users":   []interface{}{"user1"},
+		"encrypt": true,
+	})
+	c.Check(setID, check.Equals, uint64(42))
+	c.Check(changeID, check.Equals, "d728")
+}
+
 func (cs *clientSuite) TestClientOpInstallPath(c *check.C) {
 	cs.status = 202
 	cs.rsp = `{
@@ -615,6 +667,71 @@ func (cs *clientSuite) TestClientOpInstallTransactional(c *check.C) {
 		"(?s).*Content-Disposition: form-data; name=\"transaction\"\r\n\r\nall-snaps\r\n.*")
 }
 
+func (cs *clientSuite) TestClientOpInstallWithPriority(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"change": "66b3",
+		"status-code": 202,
+		"type": "async"
+	}`
+
+	opts := client.SnapOptions{
+		Priority: "high",
+	}
+
+	_, err := cs.cli.Install("foo", &opts)
+	c.Assert(err, check.IsNil)
+	var jsonBody map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&jsonBody), check.IsNil)
+	c.Check(jsonBody["priority"], check.Equals, "high")
+
+	_, err = cs.cli.InstallMany([]string{"foo", "bar"}, &opts)
+	c.Assert(err, check.IsNil)
+	jsonBody = nil
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&jsonBody), check.IsNil)
+	c.Check(jsonBody["priority"], check.Equals, "high")
+}
+
+func (cs *clientSuite) TestClientPlan(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {
+			"snaps": [{"name": "foo", "action": "refresh", "revision": "12", "channel": "stable", "type": "app", "download-size": 1024}],
+			"prerequisites": ["core22"],
+			"auto-connections": ["foo:network core:network"],
+			"restarted-services": ["foo.svc"],
+			"download-size": 1024
+		}
+	}`
+
+	plan, err := cs.cli.Plan("refresh", []string{"foo"}, &client.SnapOptions{Priority: "low"})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	var jsonBody map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":   "refresh",
+		"snaps":    []interface{}{"foo"},
+		"priority": "low",
+		"dry-run":  true,
+	})
+	c.Check(plan, check.DeepEquals, &client.Plan{
+		Snaps: []*client.PlannedSnap{{
+			Name:         "foo",
+			Action:       "refresh",
+			Revision:     snap.R(12),
+			Channel:      "stable",
+			Type:         "app",
+			DownloadSize: 1024,
+		}},
+		Prerequisites:     []string{"core22"},
+		AutoConnections:   []string{"foo:network core:network"},
+		RestartedServices: []string{"foo.svc"},
+		DownloadSize:      1024,
+	})
+}
+
 func formToMap(c *check.C, mr *multipart.Reader) map[string]string {
 	formData := map[string]string{}
 	for {
@@ -691,19 +808,20 @@ func (cs *clientSuite) TestClientOpTryModeDangerous(c *check.C) {
 
 func (cs *clientSuite) TestSnapOptionsSerialises(c *check.C) {
 	tests := map[string]client.SnapOptions{
-		"{}":                         {},
-		`{"channel":"edge"}`:         {Channel: "edge"},
-		`{"revision":"42"}`:          {Revision: "42"},
-		`{"cohort-key":"what"}`:      {CohortKey: "what"},
-		`{"leave-cohort":true}`:      {LeaveCohort: true},
-		`{"devmode":true}`:           {DevMode: true},
-		`{"jailmode":true}`:          {JailMode: true},
-		`{"classic":true}`:           {Classic: true},
-		`{"dangerous":true}`:         {Dangerous: true},
-		`{"ignore-validation":true}`: {IgnoreValidation: true},
-		`{"unaliased":true}`:         {Unaliased: true},
-		`{"purge":true}`:             {Purge: true},
-		`{"amend":true}`:             {Amend: true},
+		"{}":                                  {},
+		`{"channel":"edge"}`:                  {Channel: "edge"},
+		`{"revision":"42"}`:                   {Revision: "42"},
+		`{"cohort-key":"what"}`:               {CohortKey: "what"},
+		`{"leave-cohort":true}`:               {LeaveCohort: true},
+		`{"devmode":true}`:                    {DevMode: true},
+		`{"jailmode":true}`:                   {JailMode: true},
+		`{"classic":true}`:                    {Classic: true},
+		`{"dangerous":true}`:                  {Dangerous: true},
+		`{"ignore-validation":true}`:          {IgnoreValidation: true},
+		`{"unaliased":true}`:                  {Unaliased: true},
+		`{"purge":true}`:                      {Purge: true},
+		`{"amend":true}`:                      {Amend: true},
+		`{"architectures":["armhf","arm64"]}`: {Architectures: []string{"armhf", "arm64"}},
 	}
 	for expected, opts := range tests {
 		buf, err := json.Marshal(&opts)
@@ -81,6 +81,9 @@ type Snapshot struct {
 	// dynamic snapshot options
 	Options *snap.SnapshotOptions `json:"options,omitempty"`
 
+	// set if the snapshot's data and metadata are encrypted at rest
+	Encrypted bool `json:"encrypted,omitempty"`
+
 	// if the snapshot failed to open this will be the reason why
 	Broken string `json:"broken,omitempty"`
 
@@ -89,6 +92,10 @@ type Snapshot struct {
 	// newer snapd just updates this flag on the fly for snapshots
 	// returned by List().
 	Auto bool `json:"auto,omitempty"`
+
+	// set if the snapshot was created following snapshots.schedule; like
+	// Auto, this is set on the fly for snapshots returned by List().
+	Scheduled bool `json:"scheduled,omitempty"`
 }
 
 // IsValid checks whether the snapshot is missing information that
@@ -104,6 +111,7 @@ func (sh *Snapshot) ContentHash() ([]byte, error) {
 	sh2.SetID = 0
 	sh2.Time = time.Time{}
 	sh2.Auto = false
+	sh2.Scheduled = false
 	sh2.Options = nil
 	h := sha256.New()
 	enc := json.NewEncoder(h)
@@ -277,3 +285,45 @@ func (client *Client) SnapshotImport(exportStream io.Reader, size int64) (Snapsh
 
 	return importSet, nil
 }
+
+// RemoteSnapshot is an exported snapshot set stored on the snapshot target.
+type RemoteSnapshot struct {
+	// Name identifies the export on the target
+	Name string `json:"name"`
+	// SetID is the ID the set had when it was uploaded, if the export
+	// was uploaded by snapd
+	SetID uint64    `json:"set-id,omitempty"`
+	Size  int64     `json:"size"`
+	Time  time.Time `json:"time"`
+}
+
+type snapshotTargetAction struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+}
+
+// RemoteSnapshots lists the exported snapshot sets on the snapshot target
+// configured with snapshots.target.
+func (client *Client) RemoteSnapshots() ([]RemoteSnapshot, error) {
+	var snapshots []RemoteSnapshot
+	_, err := client.doSync("GET", "/v2/snapshot-target", nil, nil, nil, &snapshots)
+	return snapshots, err
+}
+
+// SnapshotImportFromTarget imports the named exported snapshot set from the
+// snapshot target.
+func (client *Client) SnapshotImportFromTarget(name string) (SnapshotImportSet, error) {
+	data, err := json.Marshal(&snapshotTargetAction{Action: "import", Name: name})
+	if err != nil {
+		return SnapshotImportSet{}, fmt.Errorf("cannot marshal snapshot target action: %v", err)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	var importSet SnapshotImportSet
+	if _, err := client.doSync("POST", "/v2/snapshot-target", nil, headers, bytes.NewBuffer(data), &importSet); err != nil {
+		return importSet, err
+	}
+	return importSet, nil
+}
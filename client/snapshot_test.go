@@ -219,6 +219,34 @@ func (cs *clientSuite) TestClientSnapshotImport(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientRemoteSnapshots(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": [{"name": "snapshot-1-20230102T030405Z.tar", "set-id": 1, "size": 42, "time": "2023-01-02T03:04:05Z"}, {"name": "other.tar", "size": 7, "time": "2023-01-03T00:00:00Z"}]
+}`
+	snapshots, err := cs.cli.RemoteSnapshots()
+	c.Assert(err, check.IsNil)
+	c.Check(snapshots, check.DeepEquals, []client.RemoteSnapshot{
+		{Name: "snapshot-1-20230102T030405Z.tar", SetID: 1, Size: 42, Time: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{Name: "other.tar", Size: 7, Time: time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)},
+	})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshot-target")
+}
+
+func (cs *clientSuite) TestClientSnapshotImportFromTarget(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"set-id": 42, "snaps": ["bar", "foo"]}}`
+	importSet, err := cs.cli.SnapshotImportFromTarget("snapshot-1-20230102T030405Z.tar")
+	c.Assert(err, check.IsNil)
+	c.Check(importSet, check.DeepEquals, client.SnapshotImportSet{ID: 42, Snaps: []string{"bar", "foo"}})
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshot-target")
+	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
+	d, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(d), check.Equals, `{"action":"import","name":"snapshot-1-20230102T030405Z.tar"}`)
+}
+
 func (cs *clientSuite) TestClientSnapshotContentHash(c *check.C) {
 	now := time.Now()
 	revno := snap.R(1)
@@ -120,6 +120,10 @@ func findCommand(app *snap.AppInfo, command string) (string, error) {
 		cmd = app.ReloadCommand
 	case "post-stop":
 		cmd = app.PostStopCommand
+	case "health-probe":
+		if app.HealthProbe != nil {
+			cmd = app.HealthProbe.Command
+		}
 	case "", "gdb", "gdbserver":
 		cmd = app.Command
 	default:
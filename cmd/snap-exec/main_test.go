@@ -65,6 +65,8 @@ apps:
   stop-command: stop-app
   post-stop-command: post-stop-app
   completer: you/complete/me
+  health-probe:
+   command: check-app
   environment:
    BASE_PATH: /some/path
    LD_LIBRARY_PATH: ${BASE_PATH}/lib
@@ -124,6 +126,7 @@ func (s *snapExecSuite) TestFindCommand(c *C) {
 		{cmd: "", expected: `run-app cmd-arg1 $SNAP_DATA`},
 		{cmd: "stop", expected: "stop-app"},
 		{cmd: "post-stop", expected: "post-stop-app"},
+		{cmd: "health-probe", expected: "check-app"},
 	} {
 		cmd, err := snapExec.FindCommand(info.Apps["app"], t.cmd)
 		c.Check(err, IsNil)
@@ -137,6 +140,9 @@ func (s *snapExecSuite) TestFindCommandInvalidCommand(c *C) {
 
 	_, err = snapExec.FindCommand(info.Apps["app"], "xxx")
 	c.Check(err, ErrorMatches, `cannot use "xxx" command`)
+
+	_, err = snapExec.FindCommand(info.Apps["app2"], "health-probe")
+	c.Check(err, ErrorMatches, `no "health-probe" command found for "app2"`)
 }
 
 func (s *snapExecSuite) TestFindCommandNoCommand(c *C) {
@@ -30,7 +30,8 @@ type cmdAbort struct{ changeIDMixin }
 var shortAbortHelp = i18n.G("Abort a pending change")
 
 var longAbortHelp = i18n.G(`
-The abort command attempts to abort a change that still has pending tasks.
+The abort command attempts to abort a change that still has pending tasks,
+including a change scheduled to start later.
 `)
 
 func init() {
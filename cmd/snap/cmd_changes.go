@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 
@@ -34,6 +35,13 @@ var shortChangesHelp = i18n.G("List system changes")
 var shortTasksHelp = i18n.G("List a change's tasks")
 var longChangesHelp = i18n.G(`
 The changes command displays a summary of system changes performed recently.
+
+Changes scheduled to start later are shown with the Scheduled status and the
+time they are held until, --scheduled shows only those.
+
+With --history it displays instead the changes that were archived once they
+became too old to be kept, optionally filtered by kind, status, the user who
+requested them and the time range they were started in.
 `)
 var longTasksHelp = i18n.G(`
 The tasks command displays a summary of tasks associated with an individual
@@ -43,7 +51,14 @@ change.
 type cmdChanges struct {
 	clientMixin
 	timeMixin
-	Positional struct {
+	Scheduled   bool   `long:"scheduled"`
+	History     bool   `long:"history"`
+	Kind        string `long:"kind"`
+	Status      string `long:"status"`
+	RequestedBy string `long:"requested-by"`
+	Since       string `long:"since"`
+	Until       string `long:"until"`
+	Positional  struct {
 		Snap string `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
 }
@@ -55,7 +70,22 @@ type cmdTasks struct {
 
 func init() {
 	addCommand("changes", shortChangesHelp, longChangesHelp,
-		func() flags.Commander { return &cmdChanges{} }, timeDescs, nil)
+		func() flags.Commander { return &cmdChanges{} }, timeDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"scheduled": i18n.G("Show only the changes scheduled to start later"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"history": i18n.G("Show the archived changes instead of the recent ones"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"kind": i18n.G("Show only the archived changes of the given kind"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"status": i18n.G("Show only the archived changes with the given status"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"requested-by": i18n.G("Show only the archived changes requested by the given uid or snapd user name"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"since": i18n.G("Show only the archived changes started at or after the given date or RFC3339 time"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"until": i18n.G("Show only the archived changes started before the given date or RFC3339 time"),
+		}), nil)
 	addCommand("tasks", shortTasksHelp, longTasksHelp,
 		func() flags.Commander { return &cmdTasks{} },
 		changeIDMixinOptDesc.also(timeDescs),
@@ -96,15 +126,36 @@ func (c *cmdChanges) Execute(args []string) error {
 		return nil
 	}
 
-	opts := client.ChangesOptions{
-		SnapName: c.Positional.Snap,
-		Selector: client.ChangesAll,
+	if c.Scheduled && c.History {
+		return fmt.Errorf(i18n.G("cannot use --scheduled with --history"))
 	}
 
-	changes, err := queryChanges(c.client, &opts)
+	var changes []*client.Change
+	var err error
+	if c.History {
+		changes, err = c.queryChangeHistory()
+	} else {
+		if c.Kind != "" || c.Status != "" || c.RequestedBy != "" || c.Since != "" || c.Until != "" {
+			return fmt.Errorf(i18n.G("--kind, --status, --requested-by, --since and --until can only be used with --history"))
+		}
+		opts := client.ChangesOptions{
+			SnapName: c.Positional.Snap,
+			Selector: client.ChangesAll,
+		}
+		changes, err = queryChanges(c.client, &opts)
+	}
 	if err != nil {
 		return err
 	}
+	if c.Scheduled {
+		scheduled := changes[:0]
+		for _, chg := range changes {
+			if isScheduled(chg) {
+				scheduled = append(scheduled, chg)
+			}
+		}
+		changes = scheduled
+	}
 
 	if len(changes) == 0 {
 		fmt.Fprintln(Stderr, i18n.G("no changes found"))
@@ -122,7 +173,13 @@ func (c *cmdChanges) Execute(args []string) error {
 		if chg.ReadyTime.IsZero() {
 			readyTime = "-"
 		}
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", chg.ID, chg.Status, spawnTime, readyTime, chg.Summary)
+		status, summary := chg.Status, chg.Summary
+		if isScheduled(chg) {
+			status = i18n.G("Scheduled")
+			// TRANSLATORS: %s is the summary of a change, %s the time it is held until
+			summary = fmt.Sprintf(i18n.G("%s (not before %s)"), summary, c.fmtTime(chg.NotBefore))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", chg.ID, status, spawnTime, readyTime, summary)
 	}
 
 	w.Flush()
@@ -131,6 +188,54 @@ func (c *cmdChanges) Execute(args []string) error {
 	return nil
 }
 
+// isScheduled returns whether the change is held until a later time.
+func isScheduled(chg *client.Change) bool {
+	return chg.Status == "Do" && chg.NotBefore.After(timeNow())
+}
+
+// parseHistoryTime parses the time given to --since and --until, either a
+// date or an RFC3339 time.
+func parseHistoryTime(opt, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		// TRANSLATORS: the first %s is the option, the second the value given to it
+		return time.Time{}, fmt.Errorf(i18n.G("invalid %s %q: expected a date (YYYY-MM-DD) or an RFC3339 time"), opt, value)
+	}
+	return t, nil
+}
+
+func (c *cmdChanges) queryChangeHistory() ([]*client.Change, error) {
+	since, err := parseHistoryTime("--since", c.Since)
+	if err != nil {
+		return nil, err
+	}
+	until, err := parseHistoryTime("--until", c.Until)
+	if err != nil {
+		return nil, err
+	}
+	chgs, err := c.client.ChangeHistory(&client.ChangeHistoryOptions{
+		SnapName:    c.Positional.Snap,
+		Kind:        c.Kind,
+		Status:      c.Status,
+		RequestedBy: c.RequestedBy,
+		Since:       since,
+		Until:       until,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := warnMaintenance(c.client); err != nil {
+		return nil, err
+	}
+	return chgs, nil
+}
+
 func (c *cmdTasks) Execute([]string) error {
 	chid, err := c.GetChangeID()
 	if err != nil {
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -250,3 +251,110 @@ func (s *SnapSuite) TestNoChanges(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Check(s.Stderr(), check.Equals, "no changes found\n")
 }
+
+func (s *SnapSuite) TestChangesHistory(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/history")
+			q := r.URL.Query()
+			c.Check(q.Get("for"), check.Equals, "foo")
+			c.Check(q.Get("kind"), check.Equals, "install-snap")
+			c.Check(q.Get("status"), check.Equals, "Done")
+			c.Check(q.Get("requested-by"), check.Equals, "1000")
+			since, err := time.Parse(time.RFC3339, q.Get("since"))
+			c.Assert(err, check.IsNil)
+			c.Check(since.Equal(time.Date(2016, 1, 1, 0, 0, 0, 0, time.Local)), check.Equals, true)
+			c.Check(q.Get("until"), check.Equals, "2016-05-01T10:00:00Z")
+			fmt.Fprintln(w, mockChangesJSON)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--history", "--abs-time", "--kind=install-snap", "--status=Done", "--requested-by=1000", "--since=2016-01-01", "--until=2016-05-01T10:00:00Z", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?ms)ID +Status +Spawn +Ready +Summary
+four +Do +2015-02-21T01:02:03Z +2015-02-21T01:02:04Z +\.\.\.
+three +Do +2016-01-21T01:02:03Z +2016-01-21T01:02:04Z +\.\.\.
+one +Do +2016-03-21T01:02:03Z +2016-03-21T01:02:04Z +\.\.\.
+two +Do +2016-04-21T01:02:03Z +2016-04-21T01:02:04Z +\.\.\.
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 1)
+}
+
+var mockScheduledChangesJSON = `{"type": "sync", "result": [
+  {
+    "id":   "one",
+    "kind": "refresh-snap",
+    "summary": "Refresh foo",
+    "status": "Do",
+    "ready": false,
+    "spawn-time": "2016-04-21T01:02:03Z",
+    "not-before": "2016-04-22T03:00:00Z"
+  },
+  {
+    "id":   "two",
+    "kind": "install-snap",
+    "summary": "Install bar",
+    "status": "Doing",
+    "ready": false,
+    "spawn-time": "2016-04-21T02:02:03Z"
+  },
+  {
+    "id":   "three",
+    "kind": "service-control",
+    "summary": "Running service command",
+    "status": "Doing",
+    "ready": false,
+    "spawn-time": "2016-04-20T01:02:03Z",
+    "not-before": "2016-04-21T01:00:00Z"
+  }
+]}`
+
+func (s *SnapSuite) TestChangesScheduled(c *check.C) {
+	defer snap.MockTimeNow(func() time.Time { return time.Date(2016, 4, 21, 3, 0, 0, 0, time.UTC) })()
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/changes")
+		fmt.Fprintln(w, mockScheduledChangesJSON)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--abs-time"})
+	c.Assert(err, check.IsNil)
+	// a change that already started is not shown as scheduled
+	c.Check(s.Stdout(), check.Matches, `(?ms)ID +Status +Spawn +Ready +Summary
+three +Doing +2016-04-20T01:02:03Z +- +Running service command
+one +Scheduled +2016-04-21T01:02:03Z +- +Refresh foo \(not before 2016-04-22T03:00:00Z\)
+two +Doing +2016-04-21T02:02:03Z +- +Install bar
+`)
+	s.ResetStdStreams()
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--abs-time", "--scheduled"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Matches, `(?ms)ID +Status +Spawn +Ready +Summary
+one +Scheduled +2016-04-21T01:02:03Z +- +Refresh foo \(not before 2016-04-22T03:00:00Z\)
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--scheduled", "--history"})
+	c.Check(err, check.ErrorMatches, "cannot use --scheduled with --history")
+}
+
+func (s *SnapSuite) TestChangesHistoryErrors(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--kind=install-snap"})
+	c.Check(err, check.ErrorMatches, "--kind, --status, --requested-by, --since and --until can only be used with --history")
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--requested-by=1000"})
+	c.Check(err, check.ErrorMatches, "--kind, --status, --requested-by, --since and --until can only be used with --history")
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--history", "--since=yesterday"})
+	c.Check(err, check.ErrorMatches, `invalid --since "yesterday": expected a date \(YYYY-MM-DD\) or an RFC3339 time`)
+}
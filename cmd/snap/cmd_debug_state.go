@@ -20,9 +20,12 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -32,10 +35,12 @@ import (
 
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/overlord/ifacestate/schema"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/statejournal"
 	"github.com/snapcore/snapd/strutil"
 )
 
@@ -75,13 +80,57 @@ func loadState(path string) (*state.State, error) {
 	if path == "" {
 		path = "state.json"
 	}
-	r, err := os.Open(path)
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read the state file: %s", err)
 	}
-	defer r.Close()
+	data, err = replayStateJournal(path, data)
+	if err != nil {
+		return nil, err
+	}
+	return state.ReadState(nil, bytes.NewReader(data))
+}
 
-	return state.ReadState(nil, r)
+// replayStateJournal returns the state from the state journal next to the
+// state file, if there is one. With the state journal enabled the state file
+// is only written when the journal is compacted and when snapd stops, the
+// journal holds the latest state unless the state file was written since
+// by a snapd without the journal.
+func replayStateJournal(statePath string, stateData []byte) ([]byte, error) {
+	journalPath := filepath.Join(filepath.Dir(statePath), filepath.Base(dirs.SnapStateJournalFileUnder("/")))
+	f, err := os.Open(journalPath)
+	if os.IsNotExist(err) {
+		return stateData, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the state journal: %v", err)
+	}
+	defer f.Close()
+
+	j, err := statejournal.Read(f)
+	if err != nil {
+		if journalNewer(f, statePath) {
+			return nil, fmt.Errorf("%v; the state journal is newer than the state file, refusing to show outdated state", err)
+		}
+		return stateData, nil
+	}
+	if j.StateFileSum != statejournal.StateFileSum(stateData) {
+		// the state file was written since the journal was started
+		return stateData, nil
+	}
+	return statejournal.JoinState(j.Entries)
+}
+
+func journalNewer(journal *os.File, statePath string) bool {
+	jst, err := journal.Stat()
+	if err != nil {
+		return true
+	}
+	st, err := os.Stat(statePath)
+	if err != nil {
+		return true
+	}
+	return jst.ModTime().After(st.ModTime())
 }
 
 func init() {
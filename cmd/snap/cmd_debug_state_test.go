@@ -22,12 +22,15 @@ package main_test
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"time"
 
 	. "gopkg.in/check.v1"
 
 	main "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/overlord/statejournal"
+	"github.com/snapcore/snapd/testutil"
 )
 
 var stateJSON = []byte(`
@@ -236,6 +239,67 @@ func (s *SnapSuite) TestDebugChanges(c *C) {
 	c.Check(s.Stderr(), Equals, "")
 }
 
+func writeStateJournal(c *C, path string, stateData []byte, sum string, recs ...*statejournal.Record) {
+	entries, err := statejournal.SplitState(stateData)
+	c.Assert(err, IsNil)
+	line, err := statejournal.EncodeRecord(&statejournal.Record{Full: true, Set: entries, StateFileSum: sum})
+	c.Assert(err, IsNil)
+	for _, rec := range recs {
+		l, err := statejournal.EncodeRecord(rec)
+		c.Assert(err, IsNil)
+		line = append(line, l...)
+	}
+	c.Assert(ioutil.WriteFile(path, line, 0600), IsNil)
+}
+
+func (s *SnapSuite) TestDebugChangesReplaysJournal(c *C) {
+	dir := c.MkDir()
+	stateFile := filepath.Join(dir, "state.json")
+	c.Assert(ioutil.WriteFile(stateFile, stateJSON, 0644), IsNil)
+	writeStateJournal(c, filepath.Join(dir, "state.journal"), stateJSON, statejournal.StateFileSum(stateJSON),
+		&statejournal.Record{Delete: []string{"changes/9"}})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--changes", stateFile})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Not(testutil.Contains), "install-snap")
+	c.Check(s.Stdout(), testutil.Contains, "revert-snap")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestDebugChangesStateFileNewerThanJournal(c *C) {
+	dir := c.MkDir()
+	stateFile := filepath.Join(dir, "state.json")
+	c.Assert(ioutil.WriteFile(stateFile, stateJSON, 0644), IsNil)
+	// the state file was written since by a snapd without the journal
+	writeStateJournal(c, filepath.Join(dir, "state.journal"), stateJSON, "other-sum",
+		&statejournal.Record{Delete: []string{"changes/9"}})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--changes", stateFile})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), testutil.Contains, "install-snap")
+	c.Check(s.Stdout(), testutil.Contains, "revert-snap")
+}
+
+func (s *SnapSuite) TestDebugChangesUnreadableNewerJournal(c *C) {
+	dir := c.MkDir()
+	stateFile := filepath.Join(dir, "state.json")
+	c.Assert(ioutil.WriteFile(stateFile, stateJSON, 0644), IsNil)
+	journalFile := filepath.Join(dir, "state.journal")
+	c.Assert(ioutil.WriteFile(journalFile, nil, 0600), IsNil)
+	later := time.Now().Add(time.Minute)
+	c.Assert(os.Chtimes(journalFile, later, later), IsNil)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--changes", stateFile})
+	c.Assert(err, ErrorMatches, "cannot read the state journal: journal is empty or corrupted; the state journal is newer than the state file, refusing to show outdated state")
+
+	// an older unreadable journal is ignored
+	earlier := time.Now().Add(-time.Hour)
+	c.Assert(os.Chtimes(journalFile, earlier, earlier), IsNil)
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--changes", stateFile})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), testutil.Contains, "install-snap")
+}
+
 func (s *SnapSuite) TestDebugChangesMissingState(c *C) {
 	_, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--changes", "/missing-state.json"})
 	c.Check(err, ErrorMatches, "cannot read the state file: open /missing-state.json: no such file or directory")
@@ -153,6 +153,7 @@ type cmdFind struct {
 	clientMixin
 	Private    bool        `long:"private"`
 	Narrow     bool        `long:"narrow"`
+	Arch       string      `long:"arch"`
 	Section    SectionName `long:"section" optional:"true" optional-value:"show-all-sections-please" default:"no-section-specified" default-mask:"-"`
 	Positional struct {
 		Query []string
@@ -169,6 +170,8 @@ func init() {
 		// TRANSLATORS: This should not start with a lowercase letter.
 		"narrow": i18n.G("Only search for snaps in “stable”."),
 		// TRANSLATORS: This should not start with a lowercase letter.
+		"arch": i18n.G("Search for snaps available for any of the given comma-separated architectures"),
+		// TRANSLATORS: This should not start with a lowercase letter.
 		"section": i18n.G("Restrict the search to a given section."),
 	}), []argDesc{{
 		// TRANSLATORS: This needs to begin with < and end with >
@@ -224,10 +227,16 @@ func (x *cmdFind) Execute(args []string) error {
 		}
 	}
 
+	archs, err := parseArchitectures(x.Arch)
+	if err != nil {
+		return err
+	}
+
 	opts := &client.FindOptions{
-		Query:   query,
-		Section: string(x.Section),
-		Private: x.Private,
+		Query:         query,
+		Section:       string(x.Section),
+		Private:       x.Private,
+		Architectures: archs,
 	}
 
 	if !x.Narrow {
@@ -373,6 +373,41 @@ hello-huge +1.0 +noise +- +a really big snap
 	c.Check(s.Stderr(), check.Equals, "")
 }
 
+func (s *SnapSuite) TestFindHelloArch(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/find")
+			q := r.URL.Query()
+			c.Check(q, check.HasLen, 3)
+			c.Check(q.Get("q"), check.Equals, "hello")
+			c.Check(q.Get("scope"), check.Equals, "wide")
+			c.Check(q.Get("architectures"), check.Equals, "arm64,armhf")
+			fmt.Fprint(w, findHelloJSON)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"find", "--arch", "arm64, armhf", "hello"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?ms)Name +Version +Publisher +Notes +Summary
+hello +2.10 .*`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestFindBadArch(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"find", "--arch", "arm64,", "hello"})
+	c.Assert(err, check.ErrorMatches, `invalid architecture list "arm64,"`)
+}
+
 const findPricedJSON = `
 {
   "type": "sync",
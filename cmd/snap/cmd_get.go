@@ -48,6 +48,12 @@ Nested values may be retrieved via a dotted path:
 
     $ snap get snap-name author.name
     frank
+
+With --aspect, the fields of the aspect identified by
+<account>/<bundle>/<aspect> are printed instead:
+
+    $ snap get --aspect system/network/wifi-setup ssid
+    my-network
 `)
 
 type cmdGet struct {
@@ -60,6 +66,7 @@ type cmdGet struct {
 	Typed    bool `short:"t"`
 	Document bool `short:"d"`
 	List     bool `short:"l"`
+	Aspect   bool `long:"aspect"`
 }
 
 func init() {
@@ -71,6 +78,8 @@ func init() {
 			"l": i18n.G("Always return list, even with single key"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"t": i18n.G("Strict typing with nulls and quoted strings"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"aspect": i18n.G("Get fields of the aspect <account>/<bundle>/<aspect> instead of a snap's configuration"),
 		}, []argDesc{
 			{
 				name: "<snap>",
@@ -225,6 +234,22 @@ func (x *cmdGet) outputDefault(conf map[string]interface{}, snapName string, con
 
 }
 
+// validateAspectID checks that the ID has the form <account>/<bundle>/<aspect>.
+func validateAspectID(id string) error {
+	parts := strings.Split(id, "/")
+	if len(parts) != 3 {
+		return fmt.Errorf(i18n.G("invalid aspect ID %q: must be in the format <account>/<bundle>/<aspect>"), id)
+	}
+
+	for _, part := range parts {
+		if part == "" {
+			return fmt.Errorf(i18n.G("invalid aspect ID %q: must be in the format <account>/<bundle>/<aspect>"), id)
+		}
+	}
+
+	return nil
+}
+
 func (x *cmdGet) Execute(args []string) error {
 	if len(args) > 0 {
 		// TRANSLATORS: the %s is the list of extra arguments
@@ -242,7 +267,19 @@ func (x *cmdGet) Execute(args []string) error {
 	snapName := string(x.Positional.Snap)
 	confKeys := x.Positional.Keys
 
-	conf, err := x.client.Conf(snapName, confKeys)
+	var conf map[string]interface{}
+	var err error
+	if x.Aspect {
+		if err := validateAspectID(snapName); err != nil {
+			return err
+		}
+		if len(confKeys) == 0 {
+			return fmt.Errorf(i18n.G("cannot get aspect %s: no fields were specified"), snapName)
+		}
+		conf, err = x.client.AspectGet(snapName, confKeys)
+	} else {
+		conf, err = x.client.Conf(snapName, confKeys)
+	}
 	if err != nil {
 		return err
 	}
@@ -224,3 +224,44 @@ func (s *SnapSuite) mockGetEmptyConfigServer(c *C) {
 		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {}}`)
 	})
 }
+
+var getAspectTests = []getCmdArgs{{
+	args:   "get --aspect system/network/wifi-setup ssid",
+	stdout: "my-network\n",
+}, {
+	args:   "get --aspect -l system/network/wifi-setup ssid status",
+	stdout: "Key     Value\nssid    my-network\nstatus  online\n",
+}, {
+	args:   "get --aspect -d system/network/wifi-setup ssid",
+	stdout: "{\n\t\"ssid\": \"my-network\"\n}\n",
+}, {
+	args:  "get --aspect system/network/wifi-setup",
+	error: `cannot get aspect system/network/wifi-setup: no fields were specified`,
+}, {
+	args:  "get --aspect system/network ssid",
+	error: `invalid aspect ID "system/network": must be in the format <account>/<bundle>/<aspect>`,
+}, {
+	args:  "get --aspect system//wifi-setup ssid",
+	error: `invalid aspect ID "system//wifi-setup": must be in the format <account>/<bundle>/<aspect>`,
+}}
+
+func (s *SnapSuite) TestSnapGetAspect(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/aspects/system/network/wifi-setup" {
+			c.Errorf("unexpected path %q", r.URL.Path)
+			return
+		}
+
+		c.Check(r.Method, Equals, "GET")
+		query := r.URL.Query()
+		switch query.Get("fields") {
+		case "ssid":
+			fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"ssid":"my-network"}}`)
+		case "ssid,status":
+			fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"ssid":"my-network","status":"online"}}`)
+		default:
+			c.Errorf("unexpected fields %q", query.Get("fields"))
+		}
+	})
+	s.runTests(getAspectTests, c)
+}
@@ -265,7 +265,7 @@ var helpCategories = []helpCategory{
 	}, {
 		Label:       i18n.G("Quota Groups"),
 		Description: i18n.G("Manage quota groups for snaps"),
-		Commands:    []string{"set-quota", "remove-quota", "quotas", "quota"},
+		Commands:    []string{"set-quota", "remove-quota", "quotas", "quota", "top"},
 	}, {
 		Label:       i18n.G("Validation Sets"),
 		Description: i18n.G("Manage validation sets"),
@@ -132,6 +132,17 @@ func (x *cmdModel) Execute(args []string) error {
 		}
 	}
 
+	// without a serial assertion, check whether the device is meant to
+	// run without registering at all
+	registrationDisabled := false
+	if client.IsAssertionNotFoundError(serialErr) && !x.Assertion {
+		sysInfo, err := x.client.SysInfo()
+		if err != nil {
+			return err
+		}
+		registrationDisabled = sysInfo.Registration == "disabled"
+	}
+
 	termWidth, _ := termSize()
 	termWidth -= 3
 	if termWidth > 100 {
@@ -148,6 +159,10 @@ func (x *cmdModel) Execute(args []string) error {
 		// return a devNotReady error
 		fmt.Fprintf(w, "brand-id:\t%s\n", modelAssertion.HeaderString("brand-id"))
 		fmt.Fprintf(w, "model:\t%s\n", modelAssertion.HeaderString("model"))
+		if registrationDisabled {
+			fmt.Fprintf(w, "serial:\t%s (device registration disabled)\n", x.getEscapes().dash)
+			return w.Flush()
+		}
 		w.Flush()
 		return errNoSerial
 	}
@@ -161,6 +176,8 @@ func (x *cmdModel) Execute(args []string) error {
 		AbsTime:   x.AbsTime,
 		Verbose:   x.Verbose,
 		Assertion: x.Assertion,
+
+		RegistrationDisabled: registrationDisabled,
 	}
 	if x.Serial {
 		if err := clientutil.PrintSerialAssertionYAML(w, *serialAssertion, modelFormatter, opts); err != nil {
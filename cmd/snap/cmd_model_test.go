@@ -276,7 +276,18 @@ func simpleAssertionAccountResponder(body string) checkResponder {
 	}
 }
 
+func simpleSysInfoResponder(result string) checkResponder {
+	return func(c *check.C, w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		fmt.Fprintf(w, `{"type": "sync", "status-code": 200, "result": %s}`, result)
+	}
+}
+
 func makeHappyTestServerHandler(c *check.C, modelResp, serialResp, accountResp checkResponder) func(w http.ResponseWriter, r *http.Request) {
+	return makeTestServerHandlerWithSysInfo(c, modelResp, serialResp, accountResp, simpleSysInfoResponder(`{}`))
+}
+
+func makeTestServerHandlerWithSysInfo(c *check.C, modelResp, serialResp, accountResp, sysInfoResp checkResponder) func(w http.ResponseWriter, r *http.Request) {
 	var nModelSerial, nModel, nKnown int
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -304,6 +315,8 @@ func makeHappyTestServerHandler(c *check.C, modelResp, serialResp, accountResp c
 				c.Fatalf("expected to get 1 request for /v2/model, now on %d", nKnown+1)
 			}
 			nKnown++
+		case "/v2/system-info":
+			sysInfoResp(c, w, r)
 		default:
 			c.Fatalf("unexpected request to %s", r.URL.Path)
 		}
@@ -339,6 +352,43 @@ model:     test-model
 `[1:])
 }
 
+func (s *SnapSuite) TestNoSerialRegistrationDisabled(c *check.C) {
+	s.RedirectClientToTestServer(
+		makeTestServerHandlerWithSysInfo(
+			c,
+			simpleHappyResponder(happyModelAssertionResponse),
+			simpleUnhappyResponder(noSerialAssertionYetResponse),
+			simpleAssertionAccountResponder(happyAccountAssertionResponse),
+			simpleSysInfoResponder(`{"registration": "disabled"}`),
+		))
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"model", "--serial"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `
+brand-id:  mememe
+model:     test-model
+serial:    -- (device registration disabled)
+`[1:])
+	s.ResetStdStreams()
+
+	s.RedirectClientToTestServer(
+		makeTestServerHandlerWithSysInfo(
+			c,
+			simpleHappyResponder(happyModelAssertionResponse),
+			simpleUnhappyResponder(noSerialAssertionYetResponse),
+			simpleAssertionAccountResponder(happyAccountAssertionResponse),
+			simpleSysInfoResponder(`{"registration": "disabled"}`),
+		))
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"model"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `
+brand   MeMeMe (meuser**)
+model   test-model
+serial  - (device registration disabled)
+`[1:])
+}
+
 func (s *SnapSuite) TestModel(c *check.C) {
 
 	for _, tt := range []struct {
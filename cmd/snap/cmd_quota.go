@@ -85,6 +85,19 @@ with the remove-quota command and recreated with a lower limit. Increasing the
 memory limit for a quota group does not restart any services associated with 
 snaps in the quota group.
 
+The memory high limit is a soft limit, lower than the memory limit: above it
+the snaps in the group are slowed down and their memory is reclaimed, but they
+are not killed. The memory swap limit is the amount of swap the snaps in the
+group may use, and setting it to 0 disables swap for them. Both can be changed
+after being set on a group and require cgroup v2.
+
+The oom policy decides what happens to a service of the group when the kernel
+kills one of its processes for running out of memory: "kill-one" only kills
+that process, "kill-group" kills all the processes of the service and
+"restart-service" stops the service and starts it again. Sub-groups without
+an oom policy use the one of their parent group. Processes killed for running
+out of memory are reported as warnings.
+
 The CPU limit for a quota group can be both increased and decreased after being
 set on a quota group. The CPU limit can be specified as a single percentage which
 means that the quota group is allowed an overall percentage of the CPU resources. Setting
@@ -103,6 +116,19 @@ The journal limits can be increased and decreased after being set on a group.
 Setting a journal limit will cause the snaps in the group to be put into the same
 journal namespace. This will affect the behaviour of the log command.
 
+The io limits can be increased and decreased after being set on a group. The
+bandwidth limits are given in bytes per second and the iops limits in operations
+per second; they apply to the disk holding the snap data. The io weight is a
+relative share of the disk between 1 and 10000, used when the disk is contended.
+Limits which are not given are left unchanged and setting a limit to 0, or 0B
+for the bandwidth ones, removes it. The io limits require cgroup v2.
+
+The network limits can be increased and decreased after being set on a group.
+They are given in bytes per second, for the traffic received (ingress) and sent
+(egress) by the snaps in the group, and traffic over the limits is dropped.
+Setting a network limit to 0B removes it. The network limits require cgroup v2,
+linux 5.7 or later and the nft tool.
+
 New quotas can be set on existing quota groups, but existing quotas cannot be removed
 from a quota group, without removing and recreating the entire group.
 
@@ -116,13 +142,23 @@ func init() {
 	addCommand("set-quota", shortSetQuotaHelp, longSetQuotaHelp,
 		func() flags.Commander { return &cmdSetQuota{} },
 		waitDescs.also(map[string]string{
-			"memory":             i18n.G("Memory quota"),
-			"cpu":                i18n.G("CPU quota"),
-			"cpu-set":            i18n.G("CPU set quota"),
-			"threads":            i18n.G("Threads quota"),
-			"journal-size":       i18n.G("Journal size quota"),
-			"journal-rate-limit": i18n.G("Journal rate limit as <message count>/<message period>"),
-			"parent":             i18n.G("Parent quota group"),
+			"memory":                    i18n.G("Memory quota"),
+			"memory-high":               i18n.G("Memory soft limit, above which the snaps are throttled"),
+			"memory-swap":               i18n.G("Swap quota, 0 disables swap"),
+			"oom-policy":                i18n.G("Action when out of memory: kill-one, kill-group or restart-service"),
+			"cpu":                       i18n.G("CPU quota"),
+			"cpu-set":                   i18n.G("CPU set quota"),
+			"threads":                   i18n.G("Threads quota"),
+			"journal-size":              i18n.G("Journal size quota"),
+			"journal-rate-limit":        i18n.G("Journal rate limit as <message count>/<message period>"),
+			"io-read-bandwidth":         i18n.G("Disk read bandwidth quota per second"),
+			"io-write-bandwidth":        i18n.G("Disk write bandwidth quota per second"),
+			"io-read-iops":              i18n.G("Disk read operations quota per second"),
+			"io-write-iops":             i18n.G("Disk write operations quota per second"),
+			"io-weight":                 i18n.G("Relative disk weight quota between 1 and 10000"),
+			"network-ingress-bandwidth": i18n.G("Network receive bandwidth quota per second"),
+			"network-egress-bandwidth":  i18n.G("Network send bandwidth quota per second"),
+			"parent":                    i18n.G("Parent quota group"),
 		}), nil)
 	addCommand("quota", shortQuotaHelp, longQuotaHelp, func() flags.Commander { return &cmdQuota{} }, nil, nil)
 	addCommand("quotas", shortQuotasHelp, longQuotasHelp, func() flags.Commander { return &cmdQuotas{} }, nil, nil)
@@ -133,11 +169,21 @@ type cmdSetQuota struct {
 	waitMixin
 
 	MemoryMax        string `long:"memory" optional:"true"`
+	MemoryHigh       string `long:"memory-high" optional:"true"`
+	MemorySwap       string `long:"memory-swap" optional:"true"`
+	OOMPolicy        string `long:"oom-policy" optional:"true" choice:"kill-one" choice:"kill-group" choice:"restart-service"`
 	CPUMax           string `long:"cpu" optional:"true"`
 	CPUSet           string `long:"cpu-set" optional:"true"`
 	ThreadsMax       string `long:"threads" optional:"true"`
 	JournalSizeMax   string `long:"journal-size" optional:"true"`
 	JournalRateLimit string `long:"journal-rate-limit" optional:"true"`
+	IOReadBandwidth  string `long:"io-read-bandwidth" optional:"true"`
+	IOWriteBandwidth string `long:"io-write-bandwidth" optional:"true"`
+	IOReadIOPS       string `long:"io-read-iops" optional:"true"`
+	IOWriteIOPS      string `long:"io-write-iops" optional:"true"`
+	IOWeight         string `long:"io-weight" optional:"true"`
+	NetworkIngress   string `long:"network-ingress-bandwidth" optional:"true"`
+	NetworkEgress    string `long:"network-egress-bandwidth" optional:"true"`
 	Parent           string `long:"parent" optional:"true"`
 	Positional       struct {
 		GroupName string        `positional-arg-name:"<group-name>" required:"true"`
@@ -205,6 +251,25 @@ func (x *cmdSetQuota) parseQuotas() (*client.QuotaValues, error) {
 		quotaValues.Memory = quantity.Size(value)
 	}
 
+	if x.MemoryHigh != "" {
+		value, err := strutil.ParseByteSize(x.MemoryHigh)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse memory high limit %q: %v", x.MemoryHigh, err)
+		}
+		quotaValues.MemoryHigh = quantity.Size(value)
+	}
+
+	if x.MemorySwap != "" {
+		value, err := strutil.ParseByteSize(x.MemorySwap)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse memory swap limit %q: %v", x.MemorySwap, err)
+		}
+		swap := quantity.Size(value)
+		quotaValues.MemorySwap = &swap
+	}
+
+	quotaValues.OOMPolicy = x.OOMPolicy
+
 	if x.CPUMax != "" {
 		countValue, percentageValue, err := parseCpuQuota(x.CPUMax)
 		if err != nil {
@@ -266,12 +331,109 @@ func (x *cmdSetQuota) parseQuotas() (*client.QuotaValues, error) {
 		}
 	}
 
+	if x.hasIOQuotaSet() {
+		ioValues, err := x.parseIOQuotas()
+		if err != nil {
+			return nil, err
+		}
+		quotaValues.IO = ioValues
+	}
+
+	if x.hasNetworkQuotaSet() {
+		networkValues, err := x.parseNetworkQuotas()
+		if err != nil {
+			return nil, err
+		}
+		quotaValues.Network = networkValues
+	}
+
 	return &quotaValues, nil
 }
 
+func (x *cmdSetQuota) parseIOQuotas() (*client.QuotaIOValues, error) {
+	var ioValues client.QuotaIOValues
+
+	for _, bw := range []struct {
+		name  string
+		value string
+		size  **quantity.Size
+	}{
+		{"read bandwidth", x.IOReadBandwidth, &ioValues.ReadBandwidth},
+		{"write bandwidth", x.IOWriteBandwidth, &ioValues.WriteBandwidth},
+	} {
+		if bw.value == "" {
+			continue
+		}
+		value, err := strutil.ParseByteSize(bw.value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse io %s %q: %v", bw.name, bw.value, err)
+		}
+		size := quantity.Size(value)
+		*bw.size = &size
+	}
+
+	for _, n := range []struct {
+		name  string
+		value string
+		count **int
+	}{
+		{"read iops", x.IOReadIOPS, &ioValues.ReadIOPS},
+		{"write iops", x.IOWriteIOPS, &ioValues.WriteIOPS},
+		{"weight", x.IOWeight, &ioValues.Weight},
+	} {
+		if n.value == "" {
+			continue
+		}
+		value, err := strconv.ParseUint(n.value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("cannot use io %s value %q", n.name, n.value)
+		}
+		count := int(value)
+		*n.count = &count
+	}
+
+	return &ioValues, nil
+}
+
+func (x *cmdSetQuota) parseNetworkQuotas() (*client.QuotaNetworkValues, error) {
+	var networkValues client.QuotaNetworkValues
+
+	for _, bw := range []struct {
+		name  string
+		value string
+		size  **quantity.Size
+	}{
+		{"ingress bandwidth", x.NetworkIngress, &networkValues.IngressBandwidth},
+		{"egress bandwidth", x.NetworkEgress, &networkValues.EgressBandwidth},
+	} {
+		if bw.value == "" {
+			continue
+		}
+		value, err := strutil.ParseByteSize(bw.value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse network %s %q: %v", bw.name, bw.value, err)
+		}
+		size := quantity.Size(value)
+		*bw.size = &size
+	}
+
+	return &networkValues, nil
+}
+
+func (x *cmdSetQuota) hasNetworkQuotaSet() bool {
+	return x.NetworkIngress != "" || x.NetworkEgress != ""
+}
+
+func (x *cmdSetQuota) hasIOQuotaSet() bool {
+	return x.IOReadBandwidth != "" || x.IOWriteBandwidth != "" ||
+		x.IOReadIOPS != "" || x.IOWriteIOPS != "" || x.IOWeight != ""
+}
+
 func (x *cmdSetQuota) hasQuotaSet() bool {
-	return x.MemoryMax != "" || x.CPUMax != "" || x.CPUSet != "" ||
-		x.ThreadsMax != "" || x.JournalSizeMax != "" || x.JournalRateLimit != ""
+	return x.MemoryMax != "" || x.MemoryHigh != "" || x.MemorySwap != "" || x.OOMPolicy != "" ||
+		x.CPUMax != "" || x.CPUSet != "" ||
+		x.ThreadsMax != "" || x.JournalSizeMax != "" || x.JournalRateLimit != "" ||
+		x.hasIOQuotaSet() || x.hasNetworkQuotaSet()
 }
 
 func (x *cmdSetQuota) splitSnapsAndServices() (snaps []string, services []string) {
@@ -416,6 +578,17 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 		val := strings.TrimSpace(fmtSize(int64(group.Constraints.Memory)))
 		fmt.Fprintf(w, "  memory:\t%s\n", val)
 	}
+	if group.Constraints.MemoryHigh != 0 {
+		val := strings.TrimSpace(fmtSize(int64(group.Constraints.MemoryHigh)))
+		fmt.Fprintf(w, "  memory-high:\t%s\n", val)
+	}
+	if group.Constraints.MemorySwap != nil {
+		val := strings.TrimSpace(fmtSize(int64(*group.Constraints.MemorySwap)))
+		fmt.Fprintf(w, "  memory-swap:\t%s\n", val)
+	}
+	if group.Constraints.OOMPolicy != "" {
+		fmt.Fprintf(w, "  oom-policy:\t%s\n", group.Constraints.OOMPolicy)
+	}
 	if group.Constraints.CPU != nil {
 		fmt.Fprintf(w, "  cpu-count:\t%d\n", group.Constraints.CPU.Count)
 		fmt.Fprintf(w, "  cpu-percentage:\t%d\n", group.Constraints.CPU.Percentage)
@@ -438,6 +611,32 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 				group.Constraints.Journal.RatePeriod)
 		}
 	}
+	if group.Constraints.IO != nil {
+		io := group.Constraints.IO
+		if io.ReadBandwidth != nil {
+			fmt.Fprintf(w, "  io-read-bandwidth:\t%s/s\n", strings.TrimSpace(fmtSize(int64(*io.ReadBandwidth))))
+		}
+		if io.WriteBandwidth != nil {
+			fmt.Fprintf(w, "  io-write-bandwidth:\t%s/s\n", strings.TrimSpace(fmtSize(int64(*io.WriteBandwidth))))
+		}
+		if io.ReadIOPS != nil {
+			fmt.Fprintf(w, "  io-read-iops:\t%d\n", *io.ReadIOPS)
+		}
+		if io.WriteIOPS != nil {
+			fmt.Fprintf(w, "  io-write-iops:\t%d\n", *io.WriteIOPS)
+		}
+		if io.Weight != nil {
+			fmt.Fprintf(w, "  io-weight:\t%d\n", *io.Weight)
+		}
+	}
+	if network := group.Constraints.Network; network != nil {
+		if network.IngressBandwidth != nil {
+			fmt.Fprintf(w, "  network-ingress-bandwidth:\t%s/s\n", strings.TrimSpace(fmtSize(int64(*network.IngressBandwidth))))
+		}
+		if network.EgressBandwidth != nil {
+			fmt.Fprintf(w, "  network-egress-bandwidth:\t%s/s\n", strings.TrimSpace(fmtSize(int64(*network.EgressBandwidth))))
+		}
+	}
 
 	memoryUsage := "0B"
 	currentThreads := 0
@@ -528,6 +727,17 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 			grpConstraints = append(grpConstraints, "memory="+strings.TrimSpace(fmtSize(int64(q.Constraints.Memory))))
 		}
 
+		// format memory pressure constraints as memory-high=N,memory-swap=N,oom-policy=x
+		if q.Constraints.MemoryHigh != 0 {
+			grpConstraints = append(grpConstraints, "memory-high="+strings.TrimSpace(fmtSize(int64(q.Constraints.MemoryHigh))))
+		}
+		if q.Constraints.MemorySwap != nil {
+			grpConstraints = append(grpConstraints, "memory-swap="+strings.TrimSpace(fmtSize(int64(*q.Constraints.MemorySwap))))
+		}
+		if q.Constraints.OOMPolicy != "" {
+			grpConstraints = append(grpConstraints, "oom-policy="+q.Constraints.OOMPolicy)
+		}
+
 		// format cpu constraint as cpu=NxM%,cpu-set=x,y,z
 		if q.Constraints.CPU != nil {
 			if q.Constraints.CPU.Count != 0 {
@@ -560,6 +770,35 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 			}
 		}
 
+		// format io constraint as io-read-bandwidth=xMB/s,io-read-iops=N,...
+		if io := q.Constraints.IO; io != nil {
+			if io.ReadBandwidth != nil {
+				grpConstraints = append(grpConstraints, "io-read-bandwidth="+strings.TrimSpace(fmtSize(int64(*io.ReadBandwidth)))+"/s")
+			}
+			if io.WriteBandwidth != nil {
+				grpConstraints = append(grpConstraints, "io-write-bandwidth="+strings.TrimSpace(fmtSize(int64(*io.WriteBandwidth)))+"/s")
+			}
+			if io.ReadIOPS != nil {
+				grpConstraints = append(grpConstraints, "io-read-iops="+strconv.Itoa(*io.ReadIOPS))
+			}
+			if io.WriteIOPS != nil {
+				grpConstraints = append(grpConstraints, "io-write-iops="+strconv.Itoa(*io.WriteIOPS))
+			}
+			if io.Weight != nil {
+				grpConstraints = append(grpConstraints, "io-weight="+strconv.Itoa(*io.Weight))
+			}
+		}
+
+		// format network constraint as network-ingress-bandwidth=xMB/s,...
+		if network := q.Constraints.Network; network != nil {
+			if network.IngressBandwidth != nil {
+				grpConstraints = append(grpConstraints, "network-ingress-bandwidth="+strings.TrimSpace(fmtSize(int64(*network.IngressBandwidth)))+"/s")
+			}
+			if network.EgressBandwidth != nil {
+				grpConstraints = append(grpConstraints, "network-egress-bandwidth="+strings.TrimSpace(fmtSize(int64(*network.EgressBandwidth)))+"/s")
+			}
+		}
+
 		// format current resource values as memory=N,threads=N
 		var grpCurrent []string
 		if q.Current != nil {
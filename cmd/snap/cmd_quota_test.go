@@ -259,6 +259,44 @@ func (s *quotaSuite) TestParseQuotas(c *check.C) {
 	}
 }
 
+func (s *quotaSuite) TestParseIOQuotas(c *check.C) {
+	for _, testData := range []struct {
+		readBandwidth  string
+		writeBandwidth string
+		readIOPS       string
+		writeIOPS      string
+		weight         string
+
+		quotas string
+		err    string
+	}{
+		{readBandwidth: "10MB", quotas: `{"io":{"read-bandwidth":10000000}}`},
+		{writeBandwidth: "1KB", readIOPS: "100", quotas: `{"io":{"write-bandwidth":1000,"read-iops":100}}`},
+		{writeIOPS: "20", weight: "500", quotas: `{"io":{"write-iops":20,"weight":500}}`},
+		// zero limits are kept to remove the limits
+		{readBandwidth: "0B", weight: "0", quotas: `{"io":{"read-bandwidth":0,"weight":0}}`},
+
+		// Error cases
+		{readBandwidth: "10", err: `cannot parse io read bandwidth "10": cannot parse "10": need a number with a unit as input`},
+		{writeBandwidth: "x", err: `cannot parse io write bandwidth "x": .*`},
+		{readIOPS: "-1", err: `cannot use io read iops value "-1"`},
+		{weight: "heavy", err: `cannot use io weight value "heavy"`},
+	} {
+		quotas, err := main.ParseIOQuotaValues(testData.readBandwidth, testData.writeBandwidth,
+			testData.readIOPS, testData.writeIOPS, testData.weight)
+		testLabel := check.Commentf("%v", testData)
+		if testData.err == "" {
+			c.Check(err, check.IsNil, testLabel)
+			var jsonQuota bytes.Buffer
+			err := json.NewEncoder(&jsonQuota).Encode(quotas)
+			c.Assert(err, check.IsNil, testLabel)
+			c.Check(strings.TrimSpace(jsonQuota.String()), check.Equals, testData.quotas, testLabel)
+		} else {
+			c.Check(err, check.ErrorMatches, testData.err, testLabel)
+		}
+	}
+}
+
 func (s *quotaSuite) TestSetQuotaInvalidArgs(c *check.C) {
 	for _, args := range []struct {
 		args []string
@@ -510,6 +548,161 @@ current:
 	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
 }
 
+func (s *quotaSuite) TestParseNetworkQuotas(c *check.C) {
+	for _, testData := range []struct {
+		ingress string
+		egress  string
+
+		quotas string
+		err    string
+	}{
+		{ingress: "10MB", quotas: `{"network":{"ingress-bandwidth":10000000}}`},
+		{ingress: "1KB", egress: "2MB", quotas: `{"network":{"ingress-bandwidth":1000,"egress-bandwidth":2000000}}`},
+		{egress: "0B", quotas: `{"network":{"egress-bandwidth":0}}`},
+
+		// Error cases
+		{egress: "10", err: `cannot parse network egress bandwidth "10": cannot parse "10": need a number with a unit as input`},
+		{ingress: "x", err: `cannot parse network ingress bandwidth "x": .*`},
+	} {
+		quotas, err := main.ParseNetworkQuotaValues(testData.ingress, testData.egress)
+		testLabel := check.Commentf("%v", testData)
+		if testData.err == "" {
+			c.Check(err, check.IsNil, testLabel)
+			var jsonQuota bytes.Buffer
+			err := json.NewEncoder(&jsonQuota).Encode(quotas)
+			c.Assert(err, check.IsNil, testLabel)
+			c.Check(strings.TrimSpace(jsonQuota.String()), check.Equals, testData.quotas, testLabel)
+		} else {
+			c.Check(err, check.ErrorMatches, testData.err, testLabel)
+		}
+	}
+}
+
+func (s *quotaSuite) TestNetworkQuotaGroupSimple(c *check.C) {
+	const jsonTemplate = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name": "foo",
+			"constraints": {"network":{"ingress-bandwidth":10000000,"egress-bandwidth":1000000}}
+		}
+	}`
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupHandler(c, jsonTemplate))
+
+	outputTemplate := `
+name:  foo
+constraints:
+  network-ingress-bandwidth:  10.0MB/s
+  network-egress-bandwidth:   1.00MB/s
+current:
+`[1:]
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, outputTemplate)
+	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
+}
+
+func (s *quotaSuite) TestParseMemoryPressureQuotas(c *check.C) {
+	for _, testData := range []struct {
+		high      string
+		swap      string
+		oomPolicy string
+
+		quotas string
+		err    string
+	}{
+		{high: "10MB", quotas: `{"memory-high":10000000}`},
+		{swap: "0B", oomPolicy: "kill-group", quotas: `{"memory-swap":0,"oom-policy":"kill-group"}`},
+		{high: "1GB", swap: "2GB", quotas: `{"memory-high":1000000000,"memory-swap":2000000000}`},
+
+		// Error cases
+		{high: "10", err: `cannot parse memory high limit "10": cannot parse "10": need a number with a unit as input`},
+		{swap: "x", err: `cannot parse memory swap limit "x": .*`},
+	} {
+		quotas, err := main.ParseMemoryPressureQuotaValues(testData.high, testData.swap, testData.oomPolicy)
+		testLabel := check.Commentf("%v", testData)
+		if testData.err == "" {
+			c.Check(err, check.IsNil, testLabel)
+			var jsonQuota bytes.Buffer
+			err := json.NewEncoder(&jsonQuota).Encode(quotas)
+			c.Assert(err, check.IsNil, testLabel)
+			c.Check(strings.TrimSpace(jsonQuota.String()), check.Equals, testData.quotas, testLabel)
+		} else {
+			c.Check(err, check.ErrorMatches, testData.err, testLabel)
+		}
+	}
+}
+
+func (s *quotaSuite) TestSetQuotaInvalidOOMPolicy(c *check.C) {
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"set-quota", "foo", "--oom-policy=kill-all"})
+	c.Assert(err, check.ErrorMatches, `Invalid value .kill-all. for option .--oom-policy.*`)
+}
+
+func (s *quotaSuite) TestMemoryPressureQuotaGroupSimple(c *check.C) {
+	const jsonTemplate = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name": "foo",
+			"constraints": {"memory":2000000000,"memory-high":1000000000,"memory-swap":0,"oom-policy":"restart-service"},
+			"current": {"memory": 500}
+		}
+	}`
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupHandler(c, jsonTemplate))
+
+	outputTemplate := `
+name:  foo
+constraints:
+  memory:       2.00GB
+  memory-high:  1.00GB
+  memory-swap:  0B
+  oom-policy:   restart-service
+current:
+  memory:  500B
+`[1:]
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, outputTemplate)
+	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
+}
+
+func (s *quotaSuite) TestIOQuotaGroupSimple(c *check.C) {
+	const jsonTemplate = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name": "foo",
+			"constraints": {"io":{"read-bandwidth":10000000,"write-iops":200,"weight":50}}
+		}
+	}`
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupHandler(c, jsonTemplate))
+
+	outputTemplate := `
+name:  foo
+constraints:
+  io-read-bandwidth:  10.0MB/s
+  io-write-iops:      200
+  io-weight:          50
+current:
+`[1:]
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, outputTemplate)
+	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
+}
+
 func (s *quotaSuite) TestSetQuotaGroupCreateNew(c *check.C) {
 	const postJSON = `{"type": "async", "status-code": 202,"change":"42", "result": []}`
 	fakeHandlerOpts := fakeQuotaGroupPostHandlerOpts{
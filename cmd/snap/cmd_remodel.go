@@ -36,11 +36,17 @@ revision or a full new model.
 
 In the process it applies any implied changes to the device: new required
 snaps, new kernel or gadget etc.
+
+With --dry-run the remodel is not performed; instead the snaps that would be
+installed or refreshed, the connections that would be made, whether a reboot
+would be needed and the size of the download are shown. This is not supported
+for remodels to a different brand or model.
 `)
 )
 
 type cmdRemodel struct {
 	waitMixin
+	DryRun         bool `long:"dry-run"`
 	RemodelOptions struct {
 		NewModelFile flags.Filename
 	} `positional-args:"true" required:"true"`
@@ -52,7 +58,10 @@ func init() {
 		longRemodelHelp,
 		func() flags.Commander {
 			return &cmdRemodel{}
-		}, nil, []argDesc{{
+		}, waitDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"dry-run": i18n.G("Show what the remodel would do without remodeling"),
+		}), []argDesc{{
 			// TRANSLATORS: This needs to begin with < and end with >
 			name: i18n.G("<new model file>"),
 			// TRANSLATORS: This should not start with a lowercase letter.
@@ -70,6 +79,18 @@ func (x *cmdRemodel) Execute(args []string) error {
 	if err != nil {
 		return err
 	}
+	if x.DryRun {
+		plan, err := x.client.RemodelPlan(modelData)
+		if err != nil {
+			return fmt.Errorf("cannot remodel: %v", err)
+		}
+		if len(plan.Snaps) == 0 {
+			fmt.Fprintln(Stderr, i18n.G("No snaps would be changed."))
+			return nil
+		}
+		return writePlan(plan)
+	}
+
 	changeID, err := x.client.Remodel(modelData)
 	if err != nil {
 		return fmt.Errorf("cannot remodel: %v", err)
@@ -0,0 +1,101 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+type remodelSuite struct {
+	BaseSnapSuite
+
+	modelFile string
+}
+
+var _ = check.Suite(&remodelSuite{})
+
+func (s *remodelSuite) SetUpTest(c *check.C) {
+	s.BaseSnapSuite.SetUpTest(c)
+
+	s.modelFile = filepath.Join(c.MkDir(), "new-model")
+	c.Assert(ioutil.WriteFile(s.modelFile, []byte("some-model"), 0644), check.IsNil)
+}
+
+func (s *remodelSuite) TestRemodelDryRun(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/model")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"new-model": "some-model",
+			"dry-run":   true,
+		})
+		fmt.Fprintln(w, `{"type": "sync", "result": {
+			"snaps": [
+				{"name": "new-kernel", "action": "install", "revision": "3", "channel": "20/stable", "type": "kernel", "download-size": 2048000, "auto-connections-unknown": true}
+			],
+			"reboot-required": true,
+			"download-size": 2048000
+		}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"remodel", "--dry-run", s.modelFile})
+	c.Assert(err, check.IsNil)
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, `Name        Action   Rev  Tracking   Download
+new-kernel  install  3    20/stable  2MB
+
+prerequisites:       -
+auto-connections:    -
+restarted-services:  -
+reboot-required:     yes
+download-size:       2MB
+
+Auto-connections of "new-kernel" are only known once downloaded and are not
+listed.
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *remodelSuite) TestRemodelDryRunNothingToDo(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": {}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"remodel", "--dry-run", s.modelFile})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No snaps would be changed.\n")
+}
+
+func (s *remodelSuite) TestRemodelDryRunError(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprintln(w, `{"type": "error", "status-code": 400, "result": {"message": "cannot remodel device: cannot plan a remodel to a different brand or model"}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"remodel", "--dry-run", s.modelFile})
+	c.Check(err, check.ErrorMatches, "cannot remodel: cannot remodel device: cannot plan a remodel to a different brand or model")
+}
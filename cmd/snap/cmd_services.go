@@ -147,6 +147,9 @@ func (s *svcStatus) Execute(args []string) error {
 			current = "-"
 		} else if svc.Active {
 			current = i18n.G("active")
+		} else if svc.Failed {
+			// stopped by snapd for crash-looping
+			current = i18n.G("failed")
 		}
 		fmt.Fprintf(w, "%s.%s\t%s\t%s\t%s\n", svc.Snap, svc.Name, startup, current, clientutil.ClientAppInfoNotes(svc))
 	}
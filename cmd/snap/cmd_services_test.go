@@ -240,6 +240,56 @@ foo.zed  enabled  active    -
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *appOpSuite) TestAppStatusRestarts(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.URL.Path, check.Equals, "/v2/apps")
+			c.Check(r.Method, check.Equals, "GET")
+			w.WriteHeader(200)
+			enc := json.NewEncoder(w)
+			enc.Encode(map[string]interface{}{
+				"type": "sync",
+				"result": []map[string]interface{}{
+					{
+						"snap":         "foo",
+						"name":         "bar",
+						"daemon":       "simple",
+						"daemon-scope": "system",
+						"enabled":      true,
+						"restarts":     6,
+						"failed":       true,
+					}, {
+						"snap":         "foo",
+						"name":         "baz",
+						"daemon":       "simple",
+						"daemon-scope": "system",
+						"active":       true,
+						"enabled":      true,
+						"restarts":     2,
+					},
+				},
+				"status":      "OK",
+				"status-code": 200,
+			})
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"services"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `Service  Startup  Current  Notes
+foo.bar  enabled  failed   restarts=6
+foo.baz  enabled  active   restarts=2
+`)
+	c.Check(n, check.Equals, 1)
+}
+
 func (s *appOpSuite) TestServiceCompletion(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
@@ -44,6 +44,11 @@ Nested values may be modified via a dotted path:
 
 Configuration option may be unset with exclamation mark:
     $ snap set snap-name author!
+
+With --aspect, the fields of the aspect identified by
+<account>/<bundle>/<aspect> are changed instead:
+
+    $ snap set --aspect system/network/wifi-setup ssid=my-network
 `)
 
 type cmdSet struct {
@@ -55,6 +60,7 @@ type cmdSet struct {
 
 	Typed  bool `short:"t"`
 	String bool `short:"s"`
+	Aspect bool `long:"aspect"`
 }
 
 func init() {
@@ -64,6 +70,8 @@ func init() {
 			"t": i18n.G("Parse the value strictly as JSON document"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"s": i18n.G("Parse the value as a string"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"aspect": i18n.G("Set fields of the aspect <account>/<bundle>/<aspect> instead of a snap's configuration"),
 		}), []argDesc{
 			{
 				name: "<snap>",
@@ -112,6 +120,14 @@ func (x *cmdSet) Execute(args []string) error {
 	}
 
 	snapName := string(x.Positional.Snap)
+	if x.Aspect {
+		if err := validateAspectID(snapName); err != nil {
+			return err
+		}
+		// aspects are set synchronously, there is no change to wait for
+		return x.client.AspectSet(snapName, patchValues)
+	}
+
 	id, err := x.client.SetConf(snapName, patchValues)
 	if err != nil {
 		return err
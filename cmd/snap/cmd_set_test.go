@@ -157,3 +157,26 @@ func (s *snapSetSuite) mockSetConfigServer(c *check.C, expectedValue interface{}
 		}
 	})
 }
+
+func (s *snapSetSuite) TestSnapSetAspect(c *check.C) {
+	var calls int
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		c.Check(r.URL.Path, check.Equals, "/v2/aspects/system/network/wifi-setup")
+		c.Check(r.Method, check.Equals, "PUT")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"ssid":     "my-network",
+			"password": nil,
+		})
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": null}`)
+	})
+
+	_, err := snapset.Parser(snapset.Client()).ParseArgs([]string{"set", "--aspect", "system/network/wifi-setup", "ssid=my-network", "password!"})
+	c.Assert(err, check.IsNil)
+	c.Check(calls, check.Equals, 1)
+}
+
+func (s *snapSetSuite) TestSnapSetAspectInvalidID(c *check.C) {
+	_, err := snapset.Parser(snapset.Client()).ParseArgs([]string{"set", "--aspect", "system/network", "ssid=my-network"})
+	c.Assert(err, check.ErrorMatches, `invalid aspect ID "system/network": must be in the format <account>/<bundle>/<aspect>`)
+}
@@ -99,6 +99,11 @@ requests from 'snap refresh target-snap'.
 When snaps are specified --hold is effective on both their auto-refreshes
 and general refresh requests from 'snap refresh'. However, specific snap
 requests from 'snap refresh target-snap' remain unblocked and will proceed.
+
+With --dry-run the refresh is not performed; instead the snaps that would be
+refreshed, the prerequisites that would be installed, the connections that
+would be made, the services that would be restarted, whether a reboot would be
+needed and the size of the download are shown.
 `)
 
 var longTryHelp = i18n.G(`
@@ -494,10 +499,12 @@ type cmdInstall struct {
 	Name string `long:"name"`
 
 	Cohort           string                 `long:"cohort"`
+	Arch             string                 `long:"arch"`
 	IgnoreValidation bool                   `long:"ignore-validation"`
 	IgnoreRunning    bool                   `long:"ignore-running" hidden:"yes"`
 	Transaction      client.TransactionType `long:"transaction" default:"per-snap" choice:"all-snaps" choice:"per-snap"`
 	QuotaGroupName   string                 `long:"quota-group"`
+	Priority         string                 `long:"priority" choice:"low" choice:"normal" choice:"high"`
 	Positional       struct {
 		Snaps []remoteSnapName `positional-arg-name:"<snap>" required:"1"`
 	} `positional-args:"yes" required:"yes"`
@@ -565,6 +572,9 @@ func (x *cmdInstall) installMany(names []string, opts *client.SnapOptions) error
 	var err error
 
 	if isLocal {
+		if len(opts.Architectures) > 0 {
+			return errors.New(i18n.G("cannot specify architectures for local snaps"))
+		}
 		// don't log the request's body because the encoded snap is large
 		x.client.SetMayLogBody(false)
 		changeID, err = x.client.InstallPathMany(names, opts)
@@ -637,6 +647,11 @@ func (x *cmdInstall) Execute([]string) error {
 		return err
 	}
 
+	archs, err := x.architectures()
+	if err != nil {
+		return err
+	}
+
 	dangerous := x.Dangerous || x.ForceDangerous
 	opts := &client.SnapOptions{
 		Channel:          x.Channel,
@@ -644,15 +659,20 @@ func (x *cmdInstall) Execute([]string) error {
 		Dangerous:        dangerous,
 		Unaliased:        x.Unaliased,
 		CohortKey:        x.Cohort,
+		Architectures:    archs,
 		IgnoreValidation: x.IgnoreValidation,
 		IgnoreRunning:    x.IgnoreRunning,
 		Transaction:      x.Transaction,
 		QuotaGroupName:   x.QuotaGroupName,
+		Priority:         x.Priority,
 	}
 	x.setModes(opts)
 
 	names := remoteSnapNames(x.Positional.Snaps)
 	for _, name := range names {
+		if x.Priority != "" && isLocalSnap(name) {
+			return errors.New(i18n.G("cannot use --priority when installing local snaps"))
+		}
 		if len(name) == 0 {
 			return errors.New(i18n.G("cannot install snap with empty name"))
 		}
@@ -668,13 +688,33 @@ func (x *cmdInstall) Execute([]string) error {
 	if x.IgnoreValidation {
 		return errors.New(i18n.G("a single snap name must be specified when ignoring validation"))
 	}
-
 	if x.Name != "" {
 		return errors.New(i18n.G("cannot use instance name when installing multiple snaps"))
 	}
 	return x.installMany(names, opts)
 }
 
+// architectures returns the architectures given with --arch, in order of
+// preference.
+func (x *cmdInstall) architectures() ([]string, error) {
+	return parseArchitectures(x.Arch)
+}
+
+// parseArchitectures parses a comma-separated list of architectures.
+func parseArchitectures(list string) ([]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+	archs := strings.Split(list, ",")
+	for i, arch := range archs {
+		archs[i] = strings.TrimSpace(arch)
+		if archs[i] == "" {
+			return nil, fmt.Errorf(i18n.G("invalid architecture list %q"), list)
+		}
+	}
+	return archs, nil
+}
+
 type cmdRefresh struct {
 	colorMixin
 	timeMixin
@@ -693,6 +733,7 @@ type cmdRefresh struct {
 	Transaction      client.TransactionType `long:"transaction" default:"per-snap" choice:"all-snaps" choice:"per-snap"`
 	Hold             string                 `long:"hold" optional:"yes" optional-value:"forever"`
 	Unhold           bool                   `long:"unhold"`
+	DryRun           bool                   `long:"dry-run"`
 	Positional       struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -860,7 +901,7 @@ func (x *cmdRefresh) Execute([]string) error {
 
 	otherFlags := x.Amend || x.Revision != "" || x.Cohort != "" ||
 		x.LeaveCohort || x.List || x.Time || x.IgnoreValidation || x.IgnoreRunning ||
-		x.Transaction != client.TransactionPerSnap
+		x.Transaction != client.TransactionPerSnap || x.DryRun
 
 	if x.Hold != "" && (x.Unhold || otherFlags) {
 		return errors.New(i18n.G("cannot use --hold with other flags"))
@@ -873,6 +914,15 @@ func (x *cmdRefresh) Execute([]string) error {
 	}
 
 	names := installedSnapNames(x.Positional.Snaps)
+	if x.DryRun {
+		if x.asksForMode() || x.asksForChannel() || x.Amend || x.Revision != "" || x.Cohort != "" || x.LeaveCohort || x.IgnoreValidation {
+			return errors.New(i18n.G("cannot use --dry-run with mode, channel, revision, cohort, amend or ignore-validation flags"))
+		}
+		return x.showPlan(names, &client.SnapOptions{
+			IgnoreRunning: x.IgnoreRunning,
+			Transaction:   x.Transaction,
+		})
+	}
 	if len(names) == 1 {
 		opts := &client.SnapOptions{
 			Amend:            x.Amend,
@@ -905,6 +955,70 @@ func (x *cmdRefresh) Execute([]string) error {
 	return x.refreshMany(names, opts)
 }
 
+func (x *cmdRefresh) showPlan(names []string, opts *client.SnapOptions) error {
+	plan, err := x.client.Plan("refresh", names, opts)
+	if err != nil {
+		return err
+	}
+	if len(plan.Snaps) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("All snaps up to date."))
+		return nil
+	}
+	return writePlan(plan)
+}
+
+// writePlan shows what a snap operation would do, as returned by a dry-run.
+func writePlan(plan *client.Plan) error {
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Name\tAction\tRev\tTracking\tDownload"))
+	for _, planned := range plan.Snaps {
+		download := "-"
+		if planned.DownloadSize > 0 {
+			download = strutil.SizeToStr(planned.DownloadSize)
+		}
+		channel := planned.Channel
+		if channel == "" {
+			channel = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", planned.Name, planned.Action, planned.Revision, channel, download)
+	}
+	w.Flush()
+
+	fmt.Fprintln(Stdout)
+	w = tabWriter()
+	listOrNone := func(l []string) string {
+		if len(l) == 0 {
+			return "-"
+		}
+		return strings.Join(l, ", ")
+	}
+	fmt.Fprintf(w, i18n.G("prerequisites:\t%s\n"), listOrNone(plan.Prerequisites))
+	fmt.Fprintf(w, i18n.G("auto-connections:\t%s\n"), listOrNone(plan.AutoConnections))
+	fmt.Fprintf(w, i18n.G("restarted-services:\t%s\n"), listOrNone(plan.RestartedServices))
+	reboot := i18n.G("no")
+	if plan.RebootRequired {
+		reboot = i18n.G("yes")
+	}
+	fmt.Fprintf(w, i18n.G("reboot-required:\t%s\n"), reboot)
+	fmt.Fprintf(w, i18n.G("download-size:\t%s\n"), strutil.SizeToStr(plan.DownloadSize))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var unknown []string
+	for _, planned := range plan.Snaps {
+		if planned.AutoConnectionsUnknown {
+			unknown = append(unknown, planned.Name)
+		}
+	}
+	if len(unknown) > 0 {
+		fmt.Fprintln(Stdout)
+		// TRANSLATORS: %s is a list of snap names
+		fmt.Fprintln(Stdout, fill(fmt.Sprintf(i18n.G("Auto-connections of %s are only known once downloaded and are not listed."), strutil.Quoted(unknown)), 0))
+	}
+	return nil
+}
+
 func (x *cmdRefresh) holdRefreshes() (err error) {
 	var opts client.SnapOptions
 
@@ -1270,6 +1384,8 @@ func init() {
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"cohort": i18n.G("Install the snap in the given cohort"),
 			// TRANSLATORS: This should not start with a lowercase letter.
+			"arch": i18n.G("Install the snap for the first of the given comma-separated architectures it is available for"),
+			// TRANSLATORS: This should not start with a lowercase letter.
 			"ignore-validation": i18n.G("Ignore validation by other snaps blocking the installation"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"ignore-running": i18n.G("Ignore running hooks or applications blocking the installation"),
@@ -1277,6 +1393,8 @@ func init() {
 			"transaction": i18n.G("Have one transaction per-snap or one for all the specified snaps"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"quota-group": i18n.G("Add the snap to a quota group on install"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"priority": i18n.G("Install with the given priority (low, normal or high) over other changes, such as auto-refreshes"),
 		}), nil)
 	addCommand("refresh", shortRefreshHelp, longRefreshHelp, func() flags.Commander { return &cmdRefresh{} },
 		colorDescs.also(waitDescs).also(channelDescs).also(modeDescs).also(timeDescs).also(map[string]string{
@@ -1302,6 +1420,8 @@ func init() {
 			"hold": i18n.G("Hold refreshes for a specified duration (or forever, if no value is specified)"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"unhold": i18n.G("Remove refresh hold"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"dry-run": i18n.G("Show what the refresh would do without refreshing"),
 		}), nil)
 	addCommand("try", shortTryHelp, longTryHelp, func() flags.Commander { return &cmdTry{} }, waitDescs.also(modeDescs), nil)
 	addCommand("enable", shortEnableHelp, longEnableHelp, func() flags.Commander { return &cmdEnable{} }, waitDescs, nil)
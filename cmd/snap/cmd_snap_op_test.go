@@ -242,6 +242,32 @@ func (s *SnapOpSuite) TestInstall(c *check.C) {
 	c.Check(s.srv.n, check.Equals, s.srv.total)
 }
 
+func (s *SnapOpSuite) TestInstallArch(c *check.C) {
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":        "install",
+			"architectures": []interface{}{"armhf", "arm64"},
+			"transaction":   string(client.TransactionPerSnap),
+		})
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"install", "--arch", "armhf, arm64", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo 1.0 from Bar installed`)
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestInstallArchInvalid(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"install", "--arch", "armhf,,arm64", "foo"})
+	c.Assert(err, check.ErrorMatches, `invalid architecture list "armhf,,arm64"`)
+}
+
 func (s *SnapOpSuite) TestInstallIgnoreRunning(c *check.C) {
 	s.srv.checker = func(r *http.Request) {
 		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
@@ -475,6 +501,34 @@ func (s *SnapOpSuite) TestInstallQuotaGroup(c *check.C) {
 	c.Check(s.srv.n, check.Equals, s.srv.total)
 }
 
+func (s *SnapOpSuite) TestInstallPriority(c *check.C) {
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":      "install",
+			"priority":    "high",
+			"transaction": string(client.TransactionPerSnap),
+		})
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"install", "--priority", "high", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo 1.0 from Bar installed`)
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestInstallPriorityErrors(c *check.C) {
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"install", "--priority", "urgent", "foo"})
+	c.Check(err, check.ErrorMatches, `Invalid value .urgent. for option .--priority.*`)
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"install", "--priority", "high", "./foo.snap"})
+	c.Check(err, check.ErrorMatches, "cannot use --priority when installing local snaps")
+}
+
 func (s *SnapOpSuite) TestInstallClassic(c *check.C) {
 	s.srv.checker = func(r *http.Request) {
 		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
@@ -2106,6 +2160,72 @@ func (s *SnapOpSuite) TestRefreshManyIgnoreRunning(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *SnapOpSuite) TestRefreshDryRun(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":      "refresh",
+			"snaps":       []interface{}{"foo", "bar"},
+			"transaction": "per-snap",
+			"dry-run":     true,
+		})
+		fmt.Fprintln(w, `{"type": "sync", "result": {
+			"snaps": [
+				{"name": "foo", "action": "refresh", "revision": "12", "channel": "latest/stable", "type": "app", "download-size": 2048000},
+				{"name": "bar", "action": "refresh", "revision": "3", "channel": "latest/edge", "type": "kernel", "download-size": 1024000, "auto-connections-unknown": true}
+			],
+			"prerequisites": ["core22"],
+			"auto-connections": ["foo:network core:network"],
+			"restarted-services": ["foo.svc1", "foo.svc2"],
+			"reboot-required": true,
+			"download-size": 3072000
+		}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--dry-run", "foo", "bar"})
+	c.Assert(err, check.IsNil)
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, `Name  Action   Rev  Tracking       Download
+foo   refresh  12   latest/stable  2MB
+bar   refresh  3    latest/edge    1MB
+
+prerequisites:       core22
+auto-connections:    foo:network core:network
+restarted-services:  foo.svc1, foo.svc2
+reboot-required:     yes
+download-size:       3MB
+
+Auto-connections of "bar" are only known once downloaded and are not listed.
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapOpSuite) TestRefreshDryRunNothingToDo(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": {}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--dry-run"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "All snaps up to date.\n")
+}
+
+func (s *SnapOpSuite) TestRefreshDryRunErrors(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	for _, args := range [][]string{
+		{"refresh", "--dry-run", "--beta", "foo"},
+		{"refresh", "--dry-run", "--revision=3", "foo"},
+		{"refresh", "--dry-run", "--amend", "foo"},
+	} {
+		_, err := snap.Parser(snap.Client()).ParseArgs(args)
+		c.Check(err, check.ErrorMatches, "cannot use --dry-run with mode, channel, revision, cohort, amend or ignore-validation flags", check.Commentf("%v", args))
+	}
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--dry-run", "--hold"})
+	c.Check(err, check.ErrorMatches, "cannot use --hold with other flags")
+}
+
 func (s *SnapOpSuite) TestRefreshManyChannel(c *check.C) {
 	s.RedirectClientToTestServer(nil)
 	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--beta", "one", "two"})
@@ -2626,6 +2746,29 @@ func (s *SnapOpSuite) TestInstallManyChannel(c *check.C) {
 	c.Assert(err, check.ErrorMatches, `a single snap name is needed to specify channel flags`)
 }
 
+func (s *SnapOpSuite) TestInstallManyArch(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":        "install",
+			"snaps":         []interface{}{"one", "two"},
+			"transaction":   string(client.TransactionPerSnap),
+			"architectures": []interface{}{"arm64", "armhf"},
+		})
+		w.WriteHeader(202)
+		fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"install", "--no-wait", "--arch", "arm64,armhf", "one", "two"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "42\n")
+}
+
+func (s *SnapOpSuite) TestInstallManyLocalArch(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"install", "--arch", "arm64", "./one.snap", "./two.snap"})
+	c.Assert(err, check.ErrorMatches, `cannot specify architectures for local snaps`)
+}
+
 func (s *SnapOpSuite) TestInstallManyMode(c *check.C) {
 	s.RedirectClientToTestServer(nil)
 	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"install", "--classic", "one", "two"})
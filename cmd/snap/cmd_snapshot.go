@@ -28,6 +28,7 @@ import (
 
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/strutil/quantity"
@@ -50,6 +51,9 @@ var (
 var longSavedHelp = i18n.G(`
 The saved command displays a list of snapshots that have been created
 previously with the 'save' command.
+
+With --target, the snapshot sets uploaded to the snapshot target set with
+the snapshots.target system option are listed instead.
 `)
 var longSaveHelp = i18n.G(`
 The save command creates a snapshot of the current user, system and
@@ -62,6 +66,10 @@ for which users, or a combination of these.
 If a snap is included in a save operation, excluding its system and
 configuration data from the snapshot is not currently possible. This
 restriction may be lifted in the future.
+
+Snapshots are encrypted if the snapshots.encryption system option is set.
+With --encrypt, snapshots are encrypted even if it is not set, using a key
+protected by the device encryption.
 `)
 var longForgetHelp = i18n.G(`
 The forget command deletes a snapshot. This operation can not be
@@ -109,18 +117,29 @@ Export a snapshot to the given filename.
 var longImportSnapshotHelp = i18n.G(`
 Import an exported snapshot set to the system. The snapshot is imported
 with a new snapshot ID and can be restored using the restore command.
+
+With --target, the given name is that of a snapshot set on the snapshot
+target set with the snapshots.target system option (see 'snap saved --target').
 `)
 
 type savedCmd struct {
 	clientMixin
 	durationMixin
 	ID         snapshotID `long:"id"`
+	Target     bool       `long:"target"`
 	Positional struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
 }
 
 func (x *savedCmd) Execute([]string) error {
+	if x.Target {
+		if x.ID != "" || len(x.Positional.Snaps) > 0 {
+			return fmt.Errorf(i18n.G("cannot filter snapshots on the snapshot target"))
+		}
+		return x.listTarget()
+	}
+
 	var setID uint64
 	var err error
 	if x.ID != "" {
@@ -160,6 +179,12 @@ func (x *savedCmd) Execute([]string) error {
 			if sh.Auto {
 				notes = append(notes, "auto")
 			}
+			if sh.Scheduled {
+				notes = append(notes, "scheduled")
+			}
+			if sh.Encrypted {
+				notes = append(notes, "encrypted")
+			}
 			if sh.Broken != "" {
 				notes = append(notes, "broken: "+sh.Broken)
 			}
@@ -175,10 +200,41 @@ func (x *savedCmd) Execute([]string) error {
 	return nil
 }
 
+func (x *savedCmd) listTarget() error {
+	list, err := x.client.RemoteSnapshots()
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("No snapshots found on the snapshot target."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+		i18n.G("Name"),
+		// TRANSLATORS: 'Set' as in group or bag of things
+		i18n.G("Set"),
+		// TRANSLATORS: 'Age' as in how old something is
+		i18n.G("Age"),
+		i18n.G("Size"))
+	for _, sh := range list {
+		setID := "-"
+		if sh.SetID != 0 {
+			setID = strconv.FormatUint(sh.SetID, 10)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", sh.Name, setID, x.fmtDuration(sh.Time), fmtSize(sh.Size))
+	}
+	return nil
+}
+
 type saveCmd struct {
 	waitMixin
 	durationMixin
 	Users      string `long:"users"`
+	Encrypt    bool   `long:"encrypt"`
 	Positional struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -186,8 +242,11 @@ type saveCmd struct {
 
 func (x *saveCmd) Execute([]string) error {
 	snaps := installedSnapNames(x.Positional.Snaps)
-	users := strutil.CommaSeparatedList(x.Users)
-	setID, changeID, err := x.client.SnapshotMany(snaps, users)
+	opts := &client.SnapOptions{
+		Users:   strutil.CommaSeparatedList(x.Users),
+		Encrypt: x.Encrypt,
+	}
+	setID, changeID, err := x.client.SnapshotManyWithOptions(snaps, opts)
 	if err != nil {
 		return err
 	}
@@ -329,6 +388,8 @@ func init() {
 		durationDescs.also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"id": i18n.G("Show only a specific snapshot."),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"target": i18n.G("List the snapshots on the snapshot target"),
 		}),
 		nil)
 
@@ -340,6 +401,8 @@ func init() {
 		}, durationDescs.also(waitDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"users": i18n.G("Snapshot data of only specific users (comma-separated) (default: all users)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"encrypt": i18n.G("Encrypt the snapshot"),
 		}), nil)
 
 	addCommand("restore",
@@ -423,11 +486,14 @@ func init() {
 		longImportSnapshotHelp,
 		func() flags.Commander {
 			return &importSnapshotCmd{}
-		}, nil, []argDesc{
+		}, durationDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"target": i18n.G("Import the named snapshot from the snapshot target"),
+		}), []argDesc{
 			{
 				name: "<filename>",
 				// TRANSLATORS: This should not start with a lowercase letter.
-				desc: i18n.G("Name of the snapshot export file to use"),
+				desc: i18n.G("Name of the snapshot export file, or of the snapshot on the snapshot target, to use"),
 			},
 		})
 }
@@ -488,24 +554,35 @@ func (x *exportSnapshotCmd) Execute([]string) (err error) {
 type importSnapshotCmd struct {
 	clientMixin
 	durationMixin
+	Target     bool `long:"target"`
 	Positional struct {
 		Filename string `long:"filename"`
 	} `positional-args:"yes" required:"yes"`
 }
 
-func (x *importSnapshotCmd) Execute([]string) error {
+func (x *importSnapshotCmd) importFile() (client.SnapshotImportSet, error) {
 	filename := x.Positional.Filename
 	f, err := os.Open(filename)
 	if err != nil {
-		return fmt.Errorf("error accessing file: %v", err)
+		return client.SnapshotImportSet{}, fmt.Errorf("error accessing file: %v", err)
 	}
 	defer f.Close()
 	st, err := f.Stat()
 	if err != nil {
-		return fmt.Errorf("cannot stat file: %v", err)
+		return client.SnapshotImportSet{}, fmt.Errorf("cannot stat file: %v", err)
 	}
 
-	importSet, err := x.client.SnapshotImport(f, st.Size())
+	return x.client.SnapshotImport(f, st.Size())
+}
+
+func (x *importSnapshotCmd) Execute([]string) error {
+	var importSet client.SnapshotImportSet
+	var err error
+	if x.Target {
+		importSet, err = x.client.SnapshotImportFromTarget(x.Positional.Filename)
+	} else {
+		importSet, err = x.importFile()
+	}
 	if err != nil {
 		return err
 	}
@@ -44,9 +44,21 @@ var snapshotsTests = []getCmdArgs{{
 }, {
 	args:   "saved --id=3",
 	stdout: "Set  Snap  Age    Version  Rev   Size    Notes\n3    htop  .*  2        1168      1B  auto\n",
+}, {
+	args:   "saved --id=4",
+	stdout: "Set  Snap  Age    Version  Rev   Size    Notes\n4    htop  .*  2        1168      1B  encrypted\n",
+}, {
+	args:   "saved --id=5",
+	stdout: "Set  Snap  Age    Version  Rev   Size    Notes\n5    htop  .*  2        1168      1B  scheduled\n",
 }, {
 	args:   "saved",
 	stdout: "Set  Snap  Age    Version  Rev   Size    Notes\n1    htop  .*  2        1168      1B  -\n",
+}, {
+	args:   "saved --target",
+	stdout: "Name                             Set  Age    Size\nsnapshot-1-20230102T030405Z.tar  1    .*  1000B\nother.tar                        -    .*     7B\n",
+}, {
+	args:  "saved --target --id=1",
+	error: "cannot filter snapshots on the snapshot target",
 }, {
 	args:  "forget x",
 	error: `invalid argument for snapshot set id: expected a non-negative integer argument \(see 'snap help saved'\)`,
@@ -101,6 +113,39 @@ func (s *SnapSuite) TestSnapSnaphotsTest(c *C) {
 	}
 }
 
+func (s *SnapSuite) TestSnapshotSaveEncrypt(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		switch n {
+		case 1:
+			c.Check(r.Method, Equals, "POST")
+			c.Check(r.URL.Path, Equals, "/v2/snaps")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action":  "snapshot",
+				"snaps":   []interface{}{"htop"},
+				"encrypt": true,
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9", "result": {"set-id": 4}}`)
+		case 2:
+			c.Check(r.URL.Path, Equals, "/v2/changes/9")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {}}}`)
+		case 3:
+			c.Check(r.URL.Path, Equals, "/v2/snapshots")
+			c.Check(r.URL.Query().Get("set"), Equals, "4")
+			fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":4,"snapshots":[{"set":4,"time":%q,"snap":"htop","revision":"1168","encrypted":true,"version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, time.Now().Format(time.RFC3339))
+		default:
+			c.Fatalf("unexpected request: %v", r)
+		}
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"save", "--encrypt", "htop"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), testutil.MatchesWrapped, "Set  Snap  Age    Version  Rev   Size    Notes\n4    htop  .*  2        1168      1B  encrypted\n")
+	c.Check(n, Equals, 3)
+}
+
 func (s *SnapSuite) TestSnapshotExportHappy(c *C) {
 	s.mockSnapshotsServer(c)
 
@@ -124,6 +169,14 @@ func (s *SnapSuite) mockSnapshotsServer(c *C) {
 					fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":3,"snapshots":[{"set":3,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","auto":true,"epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, snapshotTime)
 					return
 				}
+				if r.URL.Query().Get("set") == "4" {
+					fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":4,"snapshots":[{"set":4,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","encrypted":true,"epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, snapshotTime)
+					return
+				}
+				if r.URL.Query().Get("set") == "5" {
+					fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":5,"snapshots":[{"set":5,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","scheduled":true,"epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, snapshotTime)
+					return
+				}
 				fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":1,"snapshots":[{"set":1,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, snapshotTime)
 			}
 			if r.Method == "POST" {
@@ -135,6 +188,18 @@ func (s *SnapSuite) mockSnapshotsServer(c *C) {
 					fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9"}`)
 				}
 			}
+		case "/v2/snapshot-target":
+			if r.Method == "GET" {
+				snapshotTime := time.Now().AddDate(0, -1, 0).Format(time.RFC3339)
+				fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"name":"snapshot-1-20230102T030405Z.tar","set-id":1,"size":1000,"time":%q},{"name":"other.tar","size":7,"time":%q}]}`, snapshotTime, snapshotTime)
+			}
+			if r.Method == "POST" {
+				c.Check(r.Header.Get("Content-Type"), Equals, "application/json")
+				body, err := ioutil.ReadAll(r.Body)
+				c.Check(err, IsNil)
+				c.Check(string(body), Equals, `{"action":"import","name":"snapshot-1-20230102T030405Z.tar"}`)
+				fmt.Fprintln(w, `{"type": "sync", "result": {"set-id": 42, "snaps": ["htop"]}}`)
+			}
 		case "/v2/changes/9":
 			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {}}}`)
 		case "/v2/snapshots/1/export":
@@ -167,3 +232,15 @@ Set  Snap  Age    Version  Rev   Size    Notes
 1    htop  %-6s 2        1168      1B  -
 `, ageStr))
 }
+
+func (s *SnapSuite) TestSnapshotImportFromTarget(c *C) {
+	s.mockSnapshotsServer(c)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"import-snapshot", "--target", "snapshot-1-20230102T030405Z.tar"})
+	c.Check(err, IsNil)
+	c.Check(s.Stderr(), testutil.EqualsWrapped, "")
+	c.Check(s.Stdout(), testutil.MatchesWrapped, `Imported snapshot as #42
+Set  Snap  Age    Version  Rev   Size    Notes
+1    htop  .*  2        1168      1B  -
+`)
+}
@@ -0,0 +1,187 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortTopHelp = i18n.G("Show resource usage of snaps")
+var longTopHelp = i18n.G(`
+The top command shows the recent resource usage of snaps.
+
+Without arguments, the current usage of each snap with running apps or hooks
+is shown, whether the snap is in a quota group or not. With --quota-groups,
+the current usage of each quota group is shown instead. When a quota group is
+given, the current usage of each of the running apps and hooks in the group is
+shown, and with --history the usage of the whole group over the last few
+minutes.
+
+The resource usage is sampled periodically by snapd, and is only available on
+systems using cgroup v2.
+`)
+
+type cmdTop struct {
+	clientMixin
+
+	History     bool `long:"history"`
+	QuotaGroups bool `long:"quota-groups"`
+
+	Positional struct {
+		GroupName string `positional-arg-name:"<group-name>"`
+	} `positional-args:"yes"`
+}
+
+func init() {
+	addCommand("top", shortTopHelp, longTopHelp, func() flags.Commander { return &cmdTop{} },
+		map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"history": i18n.G("Show the usage history of the quota group"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"quota-groups": i18n.G("Show the usage of each quota group instead of each snap"),
+		}, nil)
+}
+
+func (x *cmdTop) Execute(args []string) error {
+	if len(args) != 0 {
+		return ErrExtraArgs
+	}
+	if x.Positional.GroupName == "" {
+		if x.History {
+			return fmt.Errorf(i18n.G("cannot use --history without a quota group"))
+		}
+		if x.QuotaGroups {
+			return x.showGroups()
+		}
+		return x.showSnaps()
+	}
+	if x.QuotaGroups {
+		return fmt.Errorf(i18n.G("cannot use --quota-groups with a quota group"))
+	}
+
+	usage, err := x.client.QuotaGroupUsage(x.Positional.GroupName)
+	if err != nil {
+		return err
+	}
+	if x.History {
+		return showUsageHistory(usage)
+	}
+	return showAppsUsage(usage)
+}
+
+func (x *cmdTop) showSnaps() error {
+	usage, err := x.client.SnapsUsage()
+	if err != nil {
+		return err
+	}
+	if len(usage) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("No running snap apps or hooks."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Snap\tCPU%\tMemory\tDisk read\tDisk write\tTasks"))
+	for _, snapUsage := range usage {
+		fmt.Fprintf(w, "%s\t", snapUsage.Snap)
+		writeResourceUsage(w, &snapUsage.QuotaResourceUsage)
+	}
+	return nil
+}
+
+func (x *cmdTop) showGroups() error {
+	groups, err := x.client.Quotas()
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("No quota groups defined."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Quota\tCPU%\tMemory\tDisk read\tDisk write\tTasks"))
+	return processQuotaGroupsTree(groups, func(q *client.QuotaGroupResult) error {
+		usage, err := x.client.QuotaGroupUsage(q.GroupName)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t", q.GroupName)
+		if len(usage.History) == 0 {
+			fmt.Fprintln(w, "-\t-\t-\t-\t-")
+			return nil
+		}
+		writeResourceUsage(w, &usage.History[len(usage.History)-1].QuotaResourceUsage)
+		return nil
+	})
+}
+
+func showAppsUsage(usage *client.QuotaGroupUsage) error {
+	if len(usage.Apps) == 0 {
+		fmt.Fprintf(Stdout, i18n.G("No running apps in quota group %q.\n"), usage.GroupName)
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Snap\tApp\tCPU%\tMemory\tDisk read\tDisk write\tTasks"))
+	for _, app := range usage.Apps {
+		fmt.Fprintf(w, "%s\t%s\t", app.Snap, app.App)
+		writeResourceUsage(w, &app.QuotaResourceUsage)
+	}
+	return nil
+}
+
+func showUsageHistory(usage *client.QuotaGroupUsage) error {
+	if len(usage.History) == 0 {
+		fmt.Fprintf(Stdout, i18n.G("No usage recorded for quota group %q yet.\n"), usage.GroupName)
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Time\tCPU%\tMemory\tDisk read\tDisk write\tTasks"))
+	for _, sample := range usage.History {
+		fmt.Fprintf(w, "%s\t", sample.Time.Local().Format("15:04:05"))
+		writeResourceUsage(w, &sample.QuotaResourceUsage)
+	}
+	return nil
+}
+
+func writeResourceUsage(w io.Writer, usage *client.QuotaResourceUsage) {
+	fmt.Fprintf(w, "%.1f%%\t%s\t%s/s\t%s/s\t%d\n",
+		usage.CPUPercentage,
+		strings.TrimSpace(fmtSize(int64(usage.Memory))),
+		strings.TrimSpace(fmtSize(int64(usage.IOReadRate))),
+		strings.TrimSpace(fmtSize(int64(usage.IOWriteRate))),
+		usage.Tasks)
+}
@@ -0,0 +1,177 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	main "github.com/snapcore/snapd/cmd/snap"
+)
+
+type topSuite struct {
+	BaseSnapSuite
+}
+
+var _ = check.Suite(&topSuite{})
+
+const topFooUsage = `{"type": "sync", "status-code": 200, "result": {
+	"group-name": "foo",
+	"apps": [
+		{"snap": "snap-a", "app": "hook.configure", "cpu-percentage": 2.5, "memory": 10000, "tasks": 1},
+		{"snap": "snap-a", "app": "svc1", "cpu-percentage": 20, "memory": 2000000, "io-read-rate": 1000, "io-write-rate": 30000, "tasks": 2}
+	],
+	"history": [
+		{"time": "2023-01-02T03:04:05Z", "memory": 100, "tasks": 1},
+		{"time": "2023-01-02T03:04:15Z", "cpu-percentage": 22.5, "memory": 2010000, "io-read-rate": 1000, "io-write-rate": 30000, "tasks": 3}
+	]}}`
+
+func (s *topSuite) mockServer(c *check.C, bodies map[string]string) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		body, ok := bodies[r.URL.Path]
+		if !ok {
+			c.Fatalf("unexpected request to %q", r.URL.Path)
+		}
+		fmt.Fprintln(w, body)
+	})
+}
+
+func (s *topSuite) TestTopGroup(c *check.C) {
+	s.mockServer(c, map[string]string{"/v2/quotas/foo/usage": topFooUsage})
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"top", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `
+Snap    App             CPU%   Memory  Disk read  Disk write  Tasks
+snap-a  hook.configure  2.5%   10.0kB  0B/s       0B/s        1
+snap-a  svc1            20.0%  2.00MB  1000B/s    30.0kB/s    2
+`[1:])
+}
+
+func (s *topSuite) TestTopGroupHistory(c *check.C) {
+	s.mockServer(c, map[string]string{"/v2/quotas/foo/usage": topFooUsage})
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"top", "--history", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	t0 := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC).Local().Format("15:04:05")
+	t1 := time.Date(2023, 1, 2, 3, 4, 15, 0, time.UTC).Local().Format("15:04:05")
+	c.Check(s.Stdout(), check.Equals, fmt.Sprintf(`
+Time      CPU%%   Memory  Disk read  Disk write  Tasks
+%s  0.0%%   100B    0B/s       0B/s        1
+%s  22.5%%  2.01MB  1000B/s    30.0kB/s    3
+`[1:], t0, t1))
+}
+
+func (s *topSuite) TestTopGroupNothingRunning(c *check.C) {
+	s.mockServer(c, map[string]string{
+		"/v2/quotas/foo/usage": `{"type": "sync", "status-code": 200, "result": {"group-name": "foo"}}`,
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"top", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "No running apps in quota group \"foo\".\n")
+	s.ResetStdStreams()
+
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"top", "--history", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "No usage recorded for quota group \"foo\" yet.\n")
+}
+
+func (s *topSuite) TestTopAllGroups(c *check.C) {
+	s.mockServer(c, map[string]string{
+		"/v2/quotas": `{"type": "sync", "status-code": 200, "result": [
+			{"group-name": "foo", "constraints": {"memory": 1000}},
+			{"group-name": "bar", "constraints": {"memory": 1000}}
+		]}`,
+		"/v2/quotas/foo/usage": topFooUsage,
+		"/v2/quotas/bar/usage": `{"type": "sync", "status-code": 200, "result": {"group-name": "bar"}}`,
+	})
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"top", "--quota-groups"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, `
+Quota  CPU%   Memory  Disk read  Disk write  Tasks
+bar    -      -       -          -           -
+foo    22.5%  2.01MB  1000B/s    30.0kB/s    3
+`[1:])
+}
+
+func (s *topSuite) TestTopAllGroupsNoneDefined(c *check.C) {
+	s.mockServer(c, map[string]string{
+		"/v2/quotas": `{"type": "sync", "status-code": 200, "result": []}`,
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"top", "--quota-groups"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "No quota groups defined.\n")
+}
+
+func (s *topSuite) TestTopSnaps(c *check.C) {
+	// no quota groups are needed to show the usage of snaps
+	s.mockServer(c, map[string]string{
+		"/v2/usage": `{"type": "sync", "status-code": 200, "result": [
+			{"snap": "snap-a", "cpu-percentage": 22.5, "memory": 2010000, "io-read-rate": 1000, "io-write-rate": 30000, "tasks": 3},
+			{"snap": "snap-b", "memory": 5000, "tasks": 4}
+		]}`,
+	})
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"top"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `
+Snap    CPU%   Memory  Disk read  Disk write  Tasks
+snap-a  22.5%  2.01MB  1000B/s    30.0kB/s    3
+snap-b  0.0%   5000B   0B/s       0B/s        4
+`[1:])
+}
+
+func (s *topSuite) TestTopSnapsNothingRunning(c *check.C) {
+	s.mockServer(c, map[string]string{
+		"/v2/usage": `{"type": "sync", "status-code": 200, "result": []}`,
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"top"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "No running snap apps or hooks.\n")
+}
+
+func (s *topSuite) TestTopErrors(c *check.C) {
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"top", "--history"})
+	c.Check(err, check.ErrorMatches, "cannot use --history without a quota group")
+
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"top", "--quota-groups", "foo"})
+	c.Check(err, check.ErrorMatches, "cannot use --quota-groups with a quota group")
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprintln(w, `{"type": "error", "status-code": 400, "result": {"message": "cannot get usage of quota group \"foo\": resource usage is only collected with cgroup version 2"}}`)
+	})
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"top", "foo"})
+	c.Check(err, check.ErrorMatches, `cannot get usage of quota group "foo": resource usage is only collected with cgroup version 2`)
+}
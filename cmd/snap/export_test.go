@@ -491,6 +491,37 @@ func ParseQuotaValues(maxMemory, cpuMax, cpuSet, threadsMax, journalSizeMax, jou
 	return quotas.parseQuotas()
 }
 
+func ParseIOQuotaValues(readBandwidth, writeBandwidth, readIOPS, writeIOPS, weight string) (*client.QuotaValues, error) {
+	var quotas cmdSetQuota
+
+	quotas.IOReadBandwidth = readBandwidth
+	quotas.IOWriteBandwidth = writeBandwidth
+	quotas.IOReadIOPS = readIOPS
+	quotas.IOWriteIOPS = writeIOPS
+	quotas.IOWeight = weight
+
+	return quotas.parseQuotas()
+}
+
+func ParseNetworkQuotaValues(ingressBandwidth, egressBandwidth string) (*client.QuotaValues, error) {
+	var quotas cmdSetQuota
+
+	quotas.NetworkIngress = ingressBandwidth
+	quotas.NetworkEgress = egressBandwidth
+
+	return quotas.parseQuotas()
+}
+
+func ParseMemoryPressureQuotaValues(memoryHigh, memorySwap, oomPolicy string) (*client.QuotaValues, error) {
+	var quotas cmdSetQuota
+
+	quotas.MemoryHigh = memoryHigh
+	quotas.MemorySwap = memorySwap
+	quotas.OOMPolicy = oomPolicy
+
+	return quotas.parseQuotas()
+}
+
 func MockSeedWriterReadManifest(f func(manifestFile string) (*seedwriter.Manifest, error)) (restore func()) {
 	restore = testutil.Backup(&seedwriterReadManifest)
 	seedwriterReadManifest = f
@@ -30,6 +30,7 @@ import (
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/strutil"
@@ -50,6 +51,7 @@ var api = []*Command{
 	interfacesCmd,
 	assertsCmd,
 	assertsFindManyCmd,
+	changeHistoryCmd,
 	stateChangeCmd,
 	stateChangesCmd,
 	createUserCmd,
@@ -67,6 +69,7 @@ var api = []*Command{
 	debugCmd,
 	snapshotCmd,
 	snapshotExportCmd,
+	snapshotTargetCmd,
 	connectionsCmd,
 	modelCmd,
 	cohortsCmd,
@@ -81,6 +84,10 @@ var api = []*Command{
 	systemRecoveryKeysCmd,
 	quotaGroupsCmd,
 	quotaGroupInfoCmd,
+	quotaGroupUsageCmd,
+	snapsUsageCmd,
+	aspectsCmd,
+	noticesCmd,
 }
 
 const (
@@ -150,6 +157,9 @@ var (
 	snapstateHoldRefreshesBySystem          = snapstate.HoldRefreshesBySystem
 	snapstateLongestGatingHold              = snapstate.LongestGatingHold
 	snapstateSystemHold                     = snapstate.SystemHold
+	snapstatePlanTaskSets                   = snapstate.PlanTaskSets
+
+	ifacestateAutoConnectCandidates = ifacestate.AutoConnectCandidates
 
 	configstateConfigureInstalled = configstate.ConfigureInstalled
 
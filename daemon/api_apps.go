@@ -26,6 +26,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/snapcore/snapd/client/clientutil"
 	"github.com/snapcore/snapd/overlord/auth"
@@ -77,6 +78,14 @@ func getAppsInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 		return InternalError("%v", err)
 	}
 
+	st := c.d.overlord.State()
+	st.Lock()
+	err = servicestate.DecorateWithRestarts(st, clientAppInfos)
+	st.Unlock()
+	if err != nil {
+		return InternalError("%v", err)
+	}
+
 	return SyncResponse(clientAppInfos)
 }
 
@@ -223,17 +232,31 @@ func getLogs(c *Command, r *http.Request, user *auth.UserState) Response {
 
 var servicestateControl = servicestate.Control
 
+// appInstruction is a service operation, optionally held until a given time.
+// The held operation keeps the snaps of the services from being changed
+// otherwise, including by auto-refresh, until it is done.
+type appInstruction struct {
+	servicestate.Instruction
+	NotBefore time.Time `json:"not-before"`
+}
+
 func postApps(c *Command, r *http.Request, user *auth.UserState) Response {
-	var inst servicestate.Instruction
+	var appInst appInstruction
 	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&inst); err != nil {
+	if err := decoder.Decode(&appInst); err != nil {
 		return BadRequest("cannot decode request body into service operation: %v", err)
 	}
+	inst := appInst.Instruction
 	// XXX: decoder.More()
 	if len(inst.Names) == 0 {
 		// on POST, don't allow empty to mean all
 		return BadRequest("cannot perform operation on services without a list of services to operate on")
 	}
+	if !appInst.NotBefore.IsZero() {
+		if err := validateNotBefore(appInst.NotBefore); err != nil {
+			return BadRequest("%v", err)
+		}
+	}
 
 	st := c.d.overlord.State()
 	appInfos, rspe := appInfosFor(st, inst.Names, appInfoOptions{service: true})
@@ -262,6 +285,9 @@ func postApps(c *Command, r *http.Request, user *auth.UserState) Response {
 	// names received in the request can be snap or snap.app, we need to
 	// extract the actual snap names before associating them with a change
 	chg := newChange(st, "service-control", "Running service command", tss, namesToSnapNames(&inst))
+	if !appInst.NotBefore.IsZero() {
+		chg.SetNotBefore(appInst.NotBefore)
+	}
 	st.EnsureBefore(0)
 	return AsyncResponse(nil, chg.ID())
 }
@@ -32,6 +32,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -326,6 +327,47 @@ NeedDaemonReload=no
 	c.Check(sort.StringsAreSorted(appNames), check.Equals, true)
 }
 
+func (s *appsSuite) TestGetAppsInfoRestarts(c *check.C) {
+	for _, name := range []string{"snap-a.svc1", "snap-a.svc2"} {
+		s.SysctlBufs = append(s.SysctlBufs, []byte(fmt.Sprintf(`
+Id=snap.%s.service
+Names=snap.%[1]s.service
+Type=simple
+ActiveState=inactive
+UnitFileState=enabled
+NeedDaemonReload=no
+`[1:], name)))
+	}
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	st.Set("service-restarts", map[string]interface{}{
+		"snap-a.svc1": map[string]interface{}{"restarts": 7, "n-restarts": 7, "failed": true},
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/apps?names=snap-a", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.DeepEquals, []client.AppInfo{{
+		Snap:        "snap-a",
+		Name:        "svc1",
+		Daemon:      "simple",
+		DaemonScope: snap.SystemDaemon,
+		Enabled:     true,
+		Restarts:    7,
+		Failed:      true,
+	}, {
+		Snap:        "snap-a",
+		Name:        "svc2",
+		Daemon:      "simple",
+		DaemonScope: snap.SystemDaemon,
+		Enabled:     true,
+	}})
+}
+
 func (s *appsSuite) TestGetAppsInfoBadSelect(c *check.C) {
 	req, err := http.NewRequest("GET", "/v2/apps?select=potato", nil)
 	c.Assert(err, check.IsNil)
@@ -581,6 +623,47 @@ func (s *appsSuite) TestPostAppsDisableNow(c *check.C) {
 	s.testPostApps(c, inst, expected)
 }
 
+func (s *appsSuite) TestPostAppsNotBefore(c *check.C) {
+	notBefore := time.Now().Add(time.Hour).Truncate(time.Second)
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"action": "restart", "names": ["snap-a.svc2"], "not-before": %q}`, notBefore.Format(time.RFC3339)))
+	req, err := http.NewRequest("POST", "/v2/apps", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.NotBefore().Equal(notBefore), check.Equals, true)
+	c.Assert(chg.Tasks(), check.HasLen, 1)
+	c.Check(chg.Tasks()[0].AtTime().Equal(notBefore), check.Equals, true)
+	c.Check(chg.Status(), check.Equals, state.DoStatus)
+	c.Check(s.serviceControlCalls, check.DeepEquals, []serviceControlArgs{
+		{action: "restart", names: []string{"snap-a.svc2"}},
+	})
+}
+
+func (s *appsSuite) TestPostAppsNotBeforeErrors(c *check.C) {
+	for _, tc := range []struct {
+		notBefore time.Time
+		err       string
+	}{
+		{time.Now().Add(-time.Minute), "not-before must be in the future: .*"},
+		{time.Now().Add(8 * 24 * time.Hour), "not-before cannot be more than 7 days from now: .*"},
+	} {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"action": "restart", "names": ["snap-a.svc2"], "not-before": %q}`, tc.notBefore.Format(time.RFC3339)))
+		req, err := http.NewRequest("POST", "/v2/apps", buf)
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Matches, tc.err)
+	}
+	c.Check(s.serviceControlCalls, check.HasLen, 0)
+}
+
 func (s *appsSuite) TestPostAppsBadJSON(c *check.C) {
 	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBufferString(`'junk`))
 	c.Assert(err, check.IsNil)
@@ -0,0 +1,136 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/snapcore/snapd/aspects"
+	"github.com/snapcore/snapd/jsonutil"
+	"github.com/snapcore/snapd/overlord/aspectstate"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	aspectsCmd = &Command{
+		Path:        "/v2/aspects/{account}/{bundle}/{aspect}",
+		GET:         getAspect,
+		PUT:         setAspect,
+		ReadAccess:  authenticatedAccess{},
+		WriteAccess: authenticatedAccess{Polkit: polkitActionManage},
+	}
+)
+
+var (
+	aspectstateGet            = aspectstate.Get
+	aspectstateNewTransaction = aspectstate.NewTransaction
+)
+
+func getAspect(c *Command, r *http.Request, _ *auth.UserState) Response {
+	vars := muxVars(r)
+	account, bundleName, aspect := vars["account"], vars["bundle"], vars["aspect"]
+
+	fields := strutil.CommaSeparatedList(r.URL.Query().Get("fields"))
+	if len(fields) == 0 {
+		return BadRequest("cannot get aspect: no fields were specified")
+	}
+
+	st := c.d.overlord.State()
+	results := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		var value interface{}
+		if err := aspectstateGet(st, account, bundleName, aspect, field, &value); err != nil {
+			// with several fields, return whatever could be found
+			if errors.Is(err, &aspects.FieldNotFoundError{}) && len(fields) > 1 {
+				continue
+			}
+			return aspectErrorToResponse(err)
+		}
+
+		results[field] = value
+	}
+
+	if len(results) == 0 {
+		return NotFound("cannot get fields %s of aspect %s/%s/%s: not found",
+			strutil.Quoted(fields), account, bundleName, aspect)
+	}
+
+	return SyncResponse(results)
+}
+
+func setAspect(c *Command, r *http.Request, _ *auth.UserState) Response {
+	vars := muxVars(r)
+	account, bundleName, aspect := vars["account"], vars["bundle"], vars["aspect"]
+
+	var values map[string]interface{}
+	if err := jsonutil.DecodeWithNumber(r.Body, &values); err != nil {
+		return BadRequest("cannot decode aspect request body: %v", err)
+	}
+
+	if len(values) == 0 {
+		return BadRequest("cannot set aspect: no fields were specified")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	// all fields are committed together or not at all
+	tx, err := aspectstateNewTransaction(st, account, bundleName)
+	if err != nil {
+		return aspectErrorToResponse(err)
+	}
+
+	// stage the fields in a stable order so errors are predictable
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		if err := tx.Set(aspect, field, values[field]); err != nil {
+			return aspectErrorToResponse(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return aspectErrorToResponse(err)
+	}
+
+	return SyncResponse(nil)
+}
+
+func aspectErrorToResponse(err error) Response {
+	switch {
+	case errors.Is(err, &aspects.AspectNotFoundError{}), errors.Is(err, &aspects.FieldNotFoundError{}):
+		return NotFound(err.Error())
+	case errors.Is(err, &aspects.InvalidAccessError{}):
+		// not using Forbidden as that would ask the client to log in
+		return &apiError{Status: 403, Message: err.Error()}
+	case errors.Is(err, &aspects.ValidationError{}):
+		return BadRequest(err.Error())
+	default:
+		return InternalError(err.Error())
+	}
+}
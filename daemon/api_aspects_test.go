@@ -0,0 +1,262 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/aspects"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var _ = check.Suite(&aspectsSuite{})
+
+type aspectsSuite struct {
+	apiBaseSuite
+}
+
+func (s *aspectsSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectReadAccess(daemon.AuthenticatedAccess{})
+	s.expectWriteAccess(daemon.AuthenticatedAccess{Polkit: "io.snapcraft.snapd.manage"})
+}
+
+func (s *aspectsSuite) setDatabag(c *check.C, databag aspects.JSONDataBag) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	st.Set("aspect-databags", map[string]map[string]aspects.JSONDataBag{
+		"system": {"network": databag},
+	})
+}
+
+func (s *aspectsSuite) TestGetAspect(c *check.C) {
+	s.daemon(c)
+
+	databag := aspects.NewJSONDataBag()
+	c.Assert(databag.Set("wifi.ssid", "foo"), check.IsNil)
+	c.Assert(databag.Set("wifi.status", "online"), check.IsNil)
+	s.setDatabag(c, databag)
+
+	req, err := http.NewRequest("GET", "/v2/aspects/system/network/wifi-setup?fields=ssid,status", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{"ssid": "foo", "status": "online"})
+}
+
+func (s *aspectsSuite) TestGetAspectPartialResult(c *check.C) {
+	s.daemon(c)
+
+	databag := aspects.NewJSONDataBag()
+	c.Assert(databag.Set("wifi.ssid", "foo"), check.IsNil)
+	s.setDatabag(c, databag)
+
+	req, err := http.NewRequest("GET", "/v2/aspects/system/network/wifi-setup?fields=ssid,status", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{"ssid": "foo"})
+}
+
+func (s *aspectsSuite) TestGetAspectNoFields(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/aspects/system/network/wifi-setup", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "cannot get aspect: no fields were specified")
+}
+
+func (s *aspectsSuite) TestGetAspectNotFound(c *check.C) {
+	s.daemon(c)
+	s.setDatabag(c, aspects.NewJSONDataBag())
+
+	for _, t := range []struct {
+		url string
+		msg string
+	}{
+		{
+			url: "/v2/aspects/system/network/other-aspect?fields=ssid",
+			msg: "aspect system/network/other-aspect not found",
+		},
+		{
+			url: "/v2/aspects/system/network/wifi-setup?fields=ssid",
+			msg: `cannot get field "ssid": no value was found under "wifi"`,
+		},
+		{
+			url: "/v2/aspects/system/network/wifi-setup?fields=ssid,status",
+			msg: `cannot get fields "ssid", "status" of aspect system/network/wifi-setup: not found`,
+		},
+	} {
+		req, err := http.NewRequest("GET", t.url, nil)
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 404, check.Commentf(t.url))
+		c.Check(rspe.Message, check.Equals, t.msg, check.Commentf(t.url))
+	}
+}
+
+func (s *aspectsSuite) TestGetAspectNotReadable(c *check.C) {
+	s.daemon(c)
+
+	databag := aspects.NewJSONDataBag()
+	c.Assert(databag.Set("wifi.psk", "secret"), check.IsNil)
+	s.setDatabag(c, databag)
+
+	req, err := http.NewRequest("GET", "/v2/aspects/system/network/wifi-setup?fields=password", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 403)
+	c.Check(rspe.Kind, check.Equals, client.ErrorKind(""))
+	c.Check(rspe.Message, check.Equals, `cannot get field "password": path is not readable`)
+}
+
+func (s *aspectsSuite) TestGetAspectInternalError(c *check.C) {
+	s.daemon(c)
+
+	restore := daemon.MockAspectstateGet(func(_ *state.State, _, _, _, _ string, _ interface{}) error {
+		return errors.New("boom")
+	})
+	defer restore()
+
+	req, err := http.NewRequest("GET", "/v2/aspects/system/network/wifi-setup?fields=ssid", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 500)
+	c.Check(rspe.Message, check.Equals, "boom")
+}
+
+func (s *aspectsSuite) TestSetAspect(c *check.C) {
+	d := s.daemon(c)
+
+	body := bytes.NewBufferString(`{"ssid": "foo", "password": "secret"}`)
+	req, err := http.NewRequest("PUT", "/v2/aspects/system/network/wifi-setup", body)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+
+	var databags map[string]map[string]aspects.JSONDataBag
+	c.Assert(st.Get("aspect-databags", &databags), check.IsNil)
+
+	var ssid, psk string
+	c.Assert(databags["system"]["network"].Get("wifi.ssid", &ssid), check.IsNil)
+	c.Check(ssid, check.Equals, "foo")
+	c.Assert(databags["system"]["network"].Get("wifi.psk", &psk), check.IsNil)
+	c.Check(psk, check.Equals, "secret")
+}
+
+func (s *aspectsSuite) TestSetAspectAtomic(c *check.C) {
+	d := s.daemon(c)
+
+	databag := aspects.NewJSONDataBag()
+	c.Assert(databag.Set("wifi.ssid", "foo"), check.IsNil)
+	s.setDatabag(c, databag)
+
+	// the valid "password" is staged before the invalid "ssid" is rejected
+	body := bytes.NewBufferString(`{"password": "secret", "ssid": 1}`)
+	req, err := http.NewRequest("PUT", "/v2/aspects/system/network/wifi-setup", body)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot accept element in "wifi.ssid": expected string but got integer`)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+
+	var databags map[string]map[string]aspects.JSONDataBag
+	c.Assert(st.Get("aspect-databags", &databags), check.IsNil)
+	c.Check(databags["system"]["network"], check.DeepEquals, databag)
+}
+
+func (s *aspectsSuite) TestSetAspectErrors(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct {
+		url    string
+		body   string
+		status int
+		msg    string
+	}{
+		{
+			url:    "/v2/aspects/system/network/wifi-setup",
+			body:   `{`,
+			status: 400,
+			msg:    "cannot decode aspect request body: unexpected EOF",
+		},
+		{
+			url:    "/v2/aspects/system/network/wifi-setup",
+			body:   `{}`,
+			status: 400,
+			msg:    "cannot set aspect: no fields were specified",
+		},
+		{
+			url:    "/v2/aspects/system/network/other-aspect",
+			body:   `{"ssid": "foo"}`,
+			status: 404,
+			msg:    "aspect system/network/other-aspect not found",
+		},
+		{
+			url:    "/v2/aspects/system/network/wifi-setup",
+			body:   `{"other-field": "foo"}`,
+			status: 404,
+			msg:    `cannot set field "other-field": not found`,
+		},
+		{
+			url:    "/v2/aspects/system/network/wifi-setup",
+			body:   `{"status": "foo"}`,
+			status: 403,
+			msg:    `cannot set field "status": path is not writeable`,
+		},
+		{
+			url:    "/v2/aspects/system/network/wifi-setup",
+			body:   `{"ssid": 1}`,
+			status: 400,
+			msg:    `cannot accept element in "wifi.ssid": expected string but got integer`,
+		},
+	} {
+		req, err := http.NewRequest("PUT", t.url, bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, t.status, check.Commentf(t.body))
+		c.Check(rspe.Message, check.Equals, t.msg, check.Commentf(t.body))
+	}
+}
@@ -71,6 +71,7 @@ type apiBaseSuite struct {
 	err               error
 	vars              map[string]string
 	storeSearch       store.Search
+	storeSnapSpec     store.SnapSpec
 	suggestedCurrency string
 	d                 *daemon.Daemon
 	user              *auth.UserState
@@ -109,6 +110,7 @@ func (s *apiBaseSuite) SnapInfo(ctx context.Context, spec store.SnapSpec, user *
 	s.pokeStateLock()
 	s.user = user
 	s.ctx = ctx
+	s.storeSnapSpec = spec
 	if len(s.rsnaps) > 0 {
 		return s.rsnaps[0], s.err
 	}
@@ -212,6 +214,7 @@ func (s *apiBaseSuite) SetUpTest(c *check.C) {
 	s.rsnaps = nil
 	s.suggestedCurrency = ""
 	s.storeSearch = store.Search{}
+	s.storeSnapSpec = store.SnapSpec{}
 	s.err = nil
 	s.vars = nil
 	s.user = nil
@@ -119,11 +119,12 @@ func streamOneSnap(c *Command, action snapDownloadAction, user *auth.UserState)
 	if action.ResumeToken == "" {
 		var info *snap.Info
 		actions := []*store.SnapAction{{
-			Action:       "download",
-			InstanceName: action.SnapName,
-			Revision:     action.Revision,
-			CohortKey:    action.CohortKey,
-			Channel:      action.Channel,
+			Action:        "download",
+			InstanceName:  action.SnapName,
+			Revision:      action.Revision,
+			CohortKey:     action.CohortKey,
+			Channel:       action.Channel,
+			Architectures: action.Architectures,
 		}}
 		results, _, err := theStore.SnapAction(context.TODO(), nil, actions, nil, user, nil)
 		if err != nil {
@@ -33,6 +33,7 @@ import (
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/strutil"
 )
 
 var (
@@ -58,6 +59,11 @@ func searchStore(c *Command, r *http.Request, user *auth.UserState) Response {
 	private := false
 	prefix := false
 
+	var architectures []string
+	if archs := query.Get("architectures"); archs != "" {
+		architectures = strutil.CommaSeparatedList(archs)
+	}
+
 	if sel := query.Get("select"); sel != "" {
 		switch sel {
 		case "refresh":
@@ -70,12 +76,19 @@ func searchStore(c *Command, r *http.Request, user *auth.UserState) Response {
 			if q != "" {
 				return BadRequest("cannot use 'q' with 'select=refresh'")
 			}
+			if len(architectures) > 0 {
+				return BadRequest("cannot use 'architectures' with 'select=refresh'")
+			}
 			return storeUpdates(c, r, user)
 		case "private":
 			private = true
 		}
 	}
 
+	if err := validateArchitectures(architectures); err != nil {
+		return BadRequest(err.Error())
+	}
+
 	if name != "" {
 		if q != "" {
 			return BadRequest("cannot use 'q' and 'name' together")
@@ -85,7 +98,7 @@ func searchStore(c *Command, r *http.Request, user *auth.UserState) Response {
 		}
 
 		if name[len(name)-1] != '*' {
-			return findOne(c, r, user, name)
+			return findOne(c, r, user, name, architectures)
 		}
 
 		prefix = true
@@ -112,6 +125,8 @@ func searchStore(c *Command, r *http.Request, user *auth.UserState) Response {
 		Category: category,
 		Private:  private,
 		Scope:    scope,
+
+		Architectures: architectures,
 	}, user)
 	switch err {
 	case nil:
@@ -159,14 +174,15 @@ func searchStore(c *Command, r *http.Request, user *auth.UserState) Response {
 	return sendStorePackages(route, found, fresp)
 }
 
-func findOne(c *Command, r *http.Request, user *auth.UserState, name string) Response {
+func findOne(c *Command, r *http.Request, user *auth.UserState, name string, architectures []string) Response {
 	if err := snap.ValidateName(name); err != nil {
 		return BadRequest(err.Error())
 	}
 
 	theStore := storeFrom(c.d)
 	spec := store.SnapSpec{
-		Name: name,
+		Name:          name,
+		Architectures: architectures,
 	}
 	ctx := store.WithClientUserAgent(r.Context(), r)
 	snapInfo, err := theStore.SnapInfo(ctx, spec, user)
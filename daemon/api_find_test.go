@@ -315,6 +315,55 @@ func (s *findSuite) TestFindByCommonID(c *check.C) {
 	c.Check(s.storeSearch, check.DeepEquals, store.Search{CommonID: "org.foo"})
 }
 
+func (s *findSuite) TestFindArchitectures(c *check.C) {
+	s.daemon(c)
+
+	s.rsnaps = []*snap.Info{}
+
+	req, err := http.NewRequest("GET", "/v2/find?q=foo&architectures=arm64,armhf", nil)
+	c.Assert(err, check.IsNil)
+
+	_ = s.syncReq(c, req, nil)
+
+	c.Check(s.storeSearch, check.DeepEquals, store.Search{
+		Query:         "foo",
+		Architectures: []string{"arm64", "armhf"},
+	})
+}
+
+func (s *findSuite) TestFindOneArchitectures(c *check.C) {
+	s.daemon(c)
+
+	s.rsnaps = []*snap.Info{{
+		SideInfo: snap.SideInfo{
+			RealName: "store",
+		},
+		Architectures: []string{"armhf"},
+	}}
+
+	req, err := http.NewRequest("GET", "/v2/find?name=store&architectures=arm64,armhf", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+
+	c.Check(s.storeSnapSpec, check.DeepEquals, store.SnapSpec{
+		Name:          "store",
+		Architectures: []string{"arm64", "armhf"},
+	})
+	c.Check(snapList(rsp.Result), check.HasLen, 1)
+}
+
+func (s *findSuite) TestFindArchitecturesInvalid(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/find?q=foo&architectures=arm64,armhf,arm64", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot specify architecture "arm64" more than once`)
+}
+
 func (s *findSuite) TestFindOne(c *check.C) {
 	s.daemon(c)
 
@@ -402,7 +451,7 @@ func (s *findSuite) TestFindOneWithAuth(c *check.C) {
 func (s *findSuite) TestFindRefreshNotOther(c *check.C) {
 	s.daemon(c)
 
-	for _, other := range []string{"name", "q", "common-id"} {
+	for _, other := range []string{"name", "q", "common-id", "architectures"} {
 		req, err := http.NewRequest("GET", "/v2/find?select=refresh&"+other+"=foo*", nil)
 		c.Assert(err, check.IsNil)
 
@@ -35,6 +35,7 @@ import (
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/changehistory"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
@@ -70,6 +71,14 @@ var (
 		ReadAccess: openAccess{},
 	}
 
+	// unlike the changes in the state, the archived ones tell who
+	// requested them
+	changeHistoryCmd = &Command{
+		Path:       "/v2/changes/history",
+		GET:        getChangeHistory,
+		ReadAccess: authenticatedAccess{},
+	}
+
 	warningsCmd = &Command{
 		Path:        "/v2/warnings",
 		GET:         getWarnings,
@@ -149,6 +158,14 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 		m["virtualization"] = systemdVirt
 	}
 
+	registrationDisabled, err := devicestate.RegistrationDisabled(st)
+	if err != nil {
+		return InternalError("cannot get device registration mode: %s", err)
+	}
+	if registrationDisabled {
+		m["registration"] = "disabled"
+	}
+
 	// NOTE: Right now we don't have a good way to differentiate if we
 	// only have partial confinement (ala AppArmor disabled and Seccomp
 	// enabled) or no confinement at all. Once we have a better system
@@ -272,6 +289,75 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(chgInfos)
 }
 
+func getChangeHistory(c *Command, r *http.Request, user *auth.UserState) Response {
+	query := r.URL.Query()
+	filter := &changehistory.Filter{
+		SnapName:    query.Get("for"),
+		Kind:        query.Get("kind"),
+		Status:      query.Get("status"),
+		RequestedBy: query.Get("requested-by"),
+	}
+	for _, param := range []struct {
+		name string
+		t    *time.Time
+	}{
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	} {
+		if v := query.Get(param.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return BadRequest("invalid %s parameter: %q", param.name, v)
+			}
+			*param.t = t
+		}
+	}
+
+	chgs, err := changehistory.Query(filter)
+	if err != nil {
+		return InternalError("cannot query change history: %v", err)
+	}
+	chgInfos := make([]*changeInfo, 0, len(chgs))
+	for _, chg := range chgs {
+		chgInfos = append(chgInfos, archivedChange2changeInfo(chg))
+	}
+	return SyncResponse(chgInfos)
+}
+
+func archivedChange2changeInfo(chg *changehistory.Change) *changeInfo {
+	readyTime := chg.ReadyTime
+	chgInfo := &changeInfo{
+		ID:      chg.ID,
+		Kind:    chg.Kind,
+		Summary: chg.Summary,
+		Status:  chg.Status,
+		Ready:   true,
+		Err:     chg.Err,
+
+		SpawnTime: chg.SpawnTime,
+		ReadyTime: &readyTime,
+
+		RequestedBy: chg.RequestedBy,
+	}
+	chgInfo.Tasks = make([]*taskInfo, len(chg.Tasks))
+	for j, t := range chg.Tasks {
+		taskInfo := &taskInfo{
+			ID:        t.ID,
+			Kind:      t.Kind,
+			Summary:   t.Summary,
+			Status:    t.Status,
+			Log:       t.Log,
+			SpawnTime: t.SpawnTime,
+		}
+		if !t.ReadyTime.IsZero() {
+			readyTime := t.ReadyTime
+			taskInfo.ReadyTime = &readyTime
+		}
+		chgInfo.Tasks[j] = taskInfo
+	}
+	return chgInfo
+}
+
 func abortChange(c *Command, r *http.Request, user *auth.UserState) Response {
 	chID := muxVars(r)["id"]
 	state := c.d.overlord.State()
@@ -319,6 +405,9 @@ type changeInfo struct {
 
 	SpawnTime time.Time  `json:"spawn-time,omitempty"`
 	ReadyTime *time.Time `json:"ready-time,omitempty"`
+	NotBefore *time.Time `json:"not-before,omitempty"`
+
+	RequestedBy *changehistory.Requester `json:"requested-by,omitempty"`
 
 	Data map[string]*json.RawMessage `json:"data,omitempty"`
 }
@@ -356,6 +445,10 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 	if !readyTime.IsZero() {
 		chgInfo.ReadyTime = &readyTime
 	}
+	notBefore := chg.NotBefore()
+	if !notBefore.IsZero() {
+		chgInfo.NotBefore = &notBefore
+	}
 	if err := chg.Err(); err != nil {
 		chgInfo.Err = err.Error()
 	}
@@ -386,6 +479,8 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 	}
 	chgInfo.Tasks = taskInfos
 
+	chg.Get("requested-by", &chgInfo.RequestedBy)
+
 	var data map[string]*json.RawMessage
 	if chg.Get("api-data", &data) == nil {
 		chgInfo.Data = data
@@ -27,6 +27,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"time"
 
 	"gopkg.in/check.v1"
@@ -37,6 +39,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces/ifacetest"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/changehistory"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
@@ -333,6 +336,27 @@ func (s *generalSuite) TestSysInfoIsManaged(c *check.C) {
 	c.Check(rsp.Result.(map[string]interface{})["managed"], check.Equals, true)
 }
 
+func (s *generalSuite) TestSysInfoRegistrationDisabled(c *check.C) {
+	d := s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/system-info", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	_, ok := rsp.Result.(map[string]interface{})["registration"]
+	c.Check(ok, check.Equals, false)
+
+	st := d.Overlord().State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "device.registration", "disabled")
+	tr.Commit()
+	st.Unlock()
+
+	rsp = s.syncReq(c, req, nil)
+	c.Check(rsp.Result.(map[string]interface{})["registration"], check.Equals, "disabled")
+}
+
 func (s *generalSuite) TestSysInfoWorksDegraded(c *check.C) {
 	d := s.daemon(c)
 
@@ -539,6 +563,113 @@ func (s *generalSuite) TestStateChangesForSnapNameWithApp(c *check.C) {
 	c.Assert(rec.Code, check.Equals, 200)
 }
 
+func (s *generalSuite) TestChangeHistory(c *check.C) {
+	s.expectReadAccess(daemon.AuthenticatedAccess{})
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapChangeHistoryFile), 0755), check.IsNil)
+
+	// Setup
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Change(ids[0]).SetStatus(state.DoneStatus)
+	restore = state.MockTime(time.Date(2016, 04, 22, 1, 2, 3, 0, time.UTC))
+	chg := st.NewChange("remove", "remove funky-snap-name")
+	chg.Set("snap-names", []string{"funky-snap-name"})
+	chg.Set("requested-by", &changehistory.Requester{UID: 1000, UserID: 1, Username: "user"})
+	chg.SetStatus(state.DoneStatus)
+	restore()
+	st.Change(ids[0]).Set("requested-by", &changehistory.Requester{UID: 0})
+	for _, chg := range []*state.Change{st.Change(ids[0]), st.Change(ids[1]), chg} {
+		c.Assert(changehistory.Archive(chg), check.IsNil)
+	}
+	st.Unlock()
+
+	for _, tc := range []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{ids[0], ids[1], chg.ID()}},
+		{"?for=funky-snap-name", []string{ids[0], chg.ID()}},
+		{"?kind=remove", []string{ids[1], chg.ID()}},
+		{"?status=Error", []string{ids[1]}},
+		{"?for=funky-snap-name&since=2016-04-22T00:00:00Z", []string{chg.ID()}},
+		{"?until=2016-04-22T00:00:00Z", []string{ids[0], ids[1]}},
+		{"?for=other-snap", nil},
+		{"?requested-by=1000", []string{chg.ID()}},
+		{"?requested-by=user", []string{chg.ID()}},
+		{"?requested-by=0", []string{ids[0]}},
+	} {
+		req, err := http.NewRequest("GET", "/v2/changes/history"+tc.query, nil)
+		c.Assert(err, check.IsNil)
+		rsp := s.syncReq(c, req, nil)
+		c.Check(rsp.Status, check.Equals, 200)
+		c.Assert(rsp.Result, check.FitsTypeOf, []*daemon.ChangeInfo(nil))
+		var chgIDs []string
+		for _, chgInfo := range rsp.Result.([]*daemon.ChangeInfo) {
+			chgIDs = append(chgIDs, chgInfo.ID)
+		}
+		c.Check(chgIDs, check.DeepEquals, tc.expected, check.Commentf("%q", tc.query))
+	}
+
+	req, err := http.NewRequest("GET", "/v2/changes/history?for=funky-snap-name&until=2016-04-22T00:00:00Z", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	rec := httptest.NewRecorder()
+	rsp.ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 200)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body["result"], check.DeepEquals, []interface{}{
+		map[string]interface{}{
+			"id":         ids[0],
+			"kind":       "install",
+			"summary":    "install...",
+			"status":     "Done",
+			"ready":      true,
+			"spawn-time": "2016-04-21T01:02:03Z",
+			"ready-time": "2016-04-21T01:02:03Z",
+			"requested-by": map[string]interface{}{
+				"uid": 0.,
+			},
+			"tasks": []interface{}{
+				map[string]interface{}{
+					"id":         ids[2],
+					"kind":       "download",
+					"summary":    "1...",
+					"status":     "Do",
+					"log":        []interface{}{"2016-04-21T01:02:03Z INFO l11", "2016-04-21T01:02:03Z INFO l12"},
+					"progress":   map[string]interface{}{"label": "", "done": 0., "total": 0.},
+					"spawn-time": "2016-04-21T01:02:03Z",
+				},
+				map[string]interface{}{
+					"id":         ids[3],
+					"kind":       "activate",
+					"summary":    "2...",
+					"status":     "Do",
+					"progress":   map[string]interface{}{"label": "", "done": 0., "total": 0.},
+					"spawn-time": "2016-04-21T01:02:03Z",
+				},
+			},
+		},
+	})
+}
+
+func (s *generalSuite) TestChangeHistoryBadTime(c *check.C) {
+	s.expectReadAccess(daemon.AuthenticatedAccess{})
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/changes/history?since=yesterday", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `invalid since parameter: "yesterday"`)
+}
+
 func (s *generalSuite) TestStateChange(c *check.C) {
 	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
 	defer restore()
@@ -599,6 +730,42 @@ func (s *generalSuite) TestStateChange(c *check.C) {
 	})
 }
 
+func (s *generalSuite) TestStateChangeScheduled(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	d := s.daemonWithOverlordMock()
+	st := d.Overlord().State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Change(ids[0]).SetNotBefore(time.Date(2016, 04, 22, 1, 0, 0, 0, time.UTC))
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/changes/"+ids[0], nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	rec := httptest.NewRecorder()
+	rsp.ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 200)
+
+	var body struct {
+		Result map[string]interface{} `json:"result"`
+	}
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &body), check.IsNil)
+	c.Check(body.Result["status"], check.Equals, "Do")
+	c.Check(body.Result["not-before"], check.Equals, "2016-04-22T01:00:00Z")
+
+	// scheduled changes can be aborted before they start
+	s.expectManageAccess()
+	req, err = http.NewRequest("POST", "/v2/changes/"+ids[0], bytes.NewBufferString(`{"action": "abort"}`))
+	c.Assert(err, check.IsNil)
+	s.syncReq(c, req, nil)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Change(ids[0]).Status(), check.Equals, state.HoldStatus)
+}
+
 func (s *generalSuite) expectManageAccess() {
 	s.expectWriteAccess(daemon.AuthenticatedAccess{Polkit: "io.snapcraft.snapd.manage"})
 }
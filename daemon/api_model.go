@@ -29,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/client/clientutil"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
@@ -49,10 +50,14 @@ var (
 	}
 )
 
-var devicestateRemodel = devicestate.Remodel
+var (
+	devicestateRemodel         = devicestate.Remodel
+	devicestateRemodelTaskSets = devicestate.RemodelTaskSets
+)
 
 type postModelData struct {
 	NewModel string `json:"new-model"`
+	DryRun   bool   `json:"dry-run"`
 }
 
 func postModel(c *Command, r *http.Request, _ *auth.UserState) Response {
@@ -75,6 +80,15 @@ func postModel(c *Command, r *http.Request, _ *auth.UserState) Response {
 	st.Lock()
 	defer st.Unlock()
 
+	if data.DryRun {
+		defer snapstate.StartPlanning(st)()
+		tss, err := devicestateRemodelTaskSets(st, newModel)
+		if err != nil {
+			return BadRequest("cannot remodel device: %v", err)
+		}
+		return snapPlan(st, tss)
+	}
+
 	chg, err := devicestateRemodel(st, newModel)
 	if err != nil {
 		return BadRequest("cannot remodel device: %v", err)
@@ -40,7 +40,9 @@ import (
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
 	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 )
 
 var modelDefaults = map[string]interface{}{
@@ -134,6 +136,85 @@ func (s *modelSuite) TestPostRemodel(c *check.C) {
 	c.Assert(soon, check.Equals, 1)
 }
 
+func (s *modelSuite) TestPostRemodelDryRun(c *check.C) {
+	s.expectRootAccess()
+
+	newModel := s.Brands.Model("my-brand", "my-old-model", modelDefaults, map[string]interface{}{
+		"revision": "2",
+	})
+
+	d := s.daemonWithOverlordMockAndStore()
+	st := d.Overlord().State()
+
+	defer daemon.MockDevicestateRemodel(func(st *state.State, nm *asserts.Model) (*state.Change, error) {
+		c.Fatalf("unexpected remodel")
+		return nil, nil
+	})()
+	var tsets []*state.TaskSet
+	defer daemon.MockDevicestateRemodelTaskSets(func(st *state.State, nm *asserts.Model) ([]*state.TaskSet, error) {
+		c.Check(nm, check.DeepEquals, newModel)
+		t1 := st.NewTask("fake-install", "Install new-kernel")
+		t2 := st.NewTask("set-model", "Set new model assertion")
+		tsets = []*state.TaskSet{state.NewTaskSet(t1), state.NewTaskSet(t2)}
+		return tsets, nil
+	})()
+	defer daemon.MockSnapstatePlanTaskSets(func(_ *state.State, tss []*state.TaskSet) (*snapstate.Plan, error) {
+		c.Check(tss, check.DeepEquals, tsets)
+		return &snapstate.Plan{
+			Snaps: []*snapstate.PlannedSnap{
+				{InstanceName: "new-kernel", Action: "install", Revision: snap.R(3), Channel: "20/stable", Type: snap.TypeKernel, DownloadSize: 1024},
+				{InstanceName: "foo", Action: "refresh", Revision: snap.R(5), CurrentRevision: snap.R(4), Type: snap.TypeApp, Info: &snap.Info{SideInfo: snap.SideInfo{RealName: "foo", Revision: snap.R(5)}}},
+			},
+			RebootRequired: true,
+			DownloadSize:   1024,
+		}, nil
+	})()
+	defer daemon.MockIfacestateAutoConnectCandidates(func(_ *state.State, info *snap.Info) ([]string, error) {
+		c.Check(info.InstanceName(), check.Equals, "foo")
+		return []string{"foo:network core:network"}, nil
+	})()
+
+	data, err := json.Marshal(daemon.PostModelData{NewModel: string(asserts.Encode(newModel)), DryRun: true})
+	c.Check(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/model", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, &client.Plan{
+		Snaps: []*client.PlannedSnap{
+			{Name: "new-kernel", Action: "install", Revision: snap.R(3), Channel: "20/stable", Type: "kernel", DownloadSize: 1024, AutoConnectionsUnknown: true},
+			{Name: "foo", Action: "refresh", Revision: snap.R(5), Type: "app"},
+		},
+		AutoConnections: []string{"foo:network core:network"},
+		RebootRequired:  true,
+		DownloadSize:    1024,
+	})
+
+	// nothing was committed
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	c.Check(st.TaskCount(), check.Equals, 0)
+}
+
+func (s *modelSuite) TestPostRemodelDryRunError(c *check.C) {
+	s.expectRootAccess()
+
+	newModel := s.Brands.Model("my-brand", "my-new-model", modelDefaults)
+	s.daemonWithOverlordMockAndStore()
+
+	defer daemon.MockDevicestateRemodelTaskSets(func(st *state.State, nm *asserts.Model) ([]*state.TaskSet, error) {
+		return nil, errors.New("cannot plan a remodel to a different brand or model")
+	})()
+
+	data, err := json.Marshal(daemon.PostModelData{NewModel: string(asserts.Encode(newModel)), DryRun: true})
+	c.Check(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/model", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "cannot remodel device: cannot plan a remodel to a different brand or model")
+}
+
 func (s *modelSuite) TestGetModelNoModelAssertion(c *check.C) {
 
 	d := s.daemonWithOverlordMockAndStore()
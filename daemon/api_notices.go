@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	noticesCmd = &Command{
+		Path:       "/v2/notices",
+		GET:        getNotices,
+		ReadAccess: authenticatedAccess{},
+	}
+)
+
+// maxNoticesTimeout is the longest a client can wait for new notices in a
+// single request.
+var maxNoticesTimeout = 10 * time.Minute
+
+func getNotices(c *Command, r *http.Request, _ *auth.UserState) Response {
+	query := r.URL.Query()
+
+	filter := &state.NoticeFilter{
+		Keys:  strutil.CommaSeparatedList(query.Get("keys")),
+		After: query.Get("after"),
+	}
+	for _, typ := range strutil.CommaSeparatedList(query.Get("types")) {
+		switch noticeType := state.NoticeType(typ); noticeType {
+		case state.SnapConfigNotice, state.AspectNotice:
+			filter.Types = append(filter.Types, noticeType)
+		default:
+			return BadRequest("invalid notice type %q", typ)
+		}
+	}
+
+	var timeout time.Duration
+	if s := query.Get("timeout"); s != "" {
+		var err error
+		timeout, err = time.ParseDuration(s)
+		if err != nil || timeout < 0 {
+			return BadRequest("invalid timeout %q", s)
+		}
+		if timeout > maxNoticesTimeout {
+			return BadRequest("timeout %q is longer than the maximum %s", s, maxNoticesTimeout)
+		}
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var notices []*state.Notice
+	var err error
+	if timeout == 0 {
+		notices, err = st.Notices(filter)
+	} else {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		go func() {
+			// stop waiting if the daemon is shutting down
+			select {
+			case <-c.d.Dying():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		notices, err = st.WaitNotices(ctx, filter)
+	}
+	if errors.Is(err, context.Canceled) {
+		// the client went away or the daemon is stopping, either way there
+		// is nothing new to report
+		notices, err = nil, nil
+	}
+	if err != nil {
+		return BadRequest("cannot get notices: %v", err)
+	}
+
+	if len(notices) == 0 {
+		// no need to confuse the issue
+		return SyncResponse([]*state.Notice{})
+	}
+	return SyncResponse(notices)
+}
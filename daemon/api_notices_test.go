@@ -0,0 +1,160 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var _ = check.Suite(&noticesSuite{})
+
+type noticesSuite struct {
+	apiBaseSuite
+}
+
+func (s *noticesSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectReadAccess(daemon.AuthenticatedAccess{})
+}
+
+func (s *noticesSuite) addNotices(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	st.AddNotice(state.SnapConfigNotice, "foo", &state.AddNoticeOptions{
+		ChangeID:    "3",
+		ChangedKeys: []string{"a.b"},
+	})
+	st.AddNotice(state.AspectNotice, "system/network", &state.AddNoticeOptions{
+		ChangedKeys: []string{"wifi.ssid"},
+	})
+	st.AddNotice(state.SnapConfigNotice, "bar", nil)
+}
+
+func noticeResultIDs(c *check.C, result interface{}) []string {
+	notices, ok := result.([]*state.Notice)
+	c.Assert(ok, check.Equals, true)
+	ids := make([]string, 0, len(notices))
+	for _, n := range notices {
+		ids = append(ids, n.ID())
+	}
+	return ids
+}
+
+func (s *noticesSuite) TestGetNotices(c *check.C) {
+	s.daemon(c)
+	s.addNotices(c)
+
+	req, err := http.NewRequest("GET", "/v2/notices", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	notices, ok := rsp.Result.([]*state.Notice)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(notices, check.HasLen, 3)
+	c.Check(notices[0].Type(), check.Equals, state.SnapConfigNotice)
+	c.Check(notices[0].Key(), check.Equals, "foo")
+	c.Check(notices[0].ChangeID(), check.Equals, "3")
+	c.Check(notices[0].ChangedKeys(), check.DeepEquals, []string{"a.b"})
+	c.Check(notices[1].Type(), check.Equals, state.AspectNotice)
+	c.Check(notices[1].Key(), check.Equals, "system/network")
+}
+
+func (s *noticesSuite) TestGetNoticesFilter(c *check.C) {
+	s.daemon(c)
+	s.addNotices(c)
+
+	for _, t := range []struct {
+		query string
+		ids   []string
+	}{
+		{"?types=snap-config", []string{"1", "3"}},
+		{"?types=aspect,snap-config", []string{"1", "2", "3"}},
+		{"?keys=bar,system/network", []string{"2", "3"}},
+		{"?after=1&types=snap-config", []string{"3"}},
+		{"?after=3", []string{}},
+	} {
+		req, err := http.NewRequest("GET", "/v2/notices"+t.query, nil)
+		c.Assert(err, check.IsNil)
+
+		rsp := s.syncReq(c, req, nil)
+		c.Check(noticeResultIDs(c, rsp.Result), check.DeepEquals, t.ids, check.Commentf(t.query))
+	}
+}
+
+func (s *noticesSuite) TestGetNoticesWait(c *check.C) {
+	s.daemon(c)
+	s.addNotices(c)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		st := s.d.Overlord().State()
+		st.Lock()
+		defer st.Unlock()
+		st.AddNotice(state.AspectNotice, "system/network", nil)
+	}()
+
+	req, err := http.NewRequest("GET", "/v2/notices?after=3&timeout=1m", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(noticeResultIDs(c, rsp.Result), check.DeepEquals, []string{"4"})
+}
+
+func (s *noticesSuite) TestGetNoticesWaitTimeout(c *check.C) {
+	s.daemon(c)
+	s.addNotices(c)
+
+	req, err := http.NewRequest("GET", "/v2/notices?after=3&timeout=10ms", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(noticeResultIDs(c, rsp.Result), check.DeepEquals, []string{})
+}
+
+func (s *noticesSuite) TestGetNoticesErrors(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct {
+		query string
+		msg   string
+	}{
+		{"?types=foo", `invalid notice type "foo"`},
+		{"?timeout=foo", `invalid timeout "foo"`},
+		{"?timeout=-1s", `invalid timeout "-1s"`},
+		{"?timeout=1h", `timeout "1h" is longer than the maximum 10m0s`},
+		{"?after=foo", `cannot get notices: invalid notice ID "foo"`},
+	} {
+		req, err := http.NewRequest("GET", "/v2/notices"+t.query, nil)
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf(t.query))
+		c.Check(rspe.Message, check.Equals, t.msg, check.Commentf(t.query))
+	}
+}
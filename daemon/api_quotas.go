@@ -45,6 +45,17 @@ var (
 		GET:        getQuotaGroupInfo,
 		ReadAccess: openAccess{},
 	}
+	quotaGroupUsageCmd = &Command{
+		Path:       "/v2/quotas/{group}/usage",
+		GET:        getQuotaGroupUsage,
+		ReadAccess: openAccess{},
+	}
+
+	snapsUsageCmd = &Command{
+		Path:       "/v2/usage",
+		GET:        getSnapsUsage,
+		ReadAccess: openAccess{},
+	}
 )
 
 type postQuotaGroupData struct {
@@ -61,6 +72,9 @@ var (
 	servicestateCreateQuota = servicestate.CreateQuota
 	servicestateUpdateQuota = servicestate.UpdateQuota
 	servicestateRemoveQuota = servicestate.RemoveQuota
+
+	servicestateQuotaGroupUsage = (*servicestate.ServiceManager).QuotaGroupUsage
+	servicestateSnapsUsage      = (*servicestate.ServiceManager).SnapsUsage
 )
 
 var getQuotaUsage = func(grp *quota.Group) (*client.QuotaValues, error) {
@@ -110,6 +124,36 @@ func createQuotaValues(grp *quota.Group) *client.QuotaValues {
 			}
 		}
 	}
+	if grp.IOLimit != nil {
+		constraints.IO = &client.QuotaIOValues{}
+		if grp.IOLimit.ReadBandwidth != 0 {
+			constraints.IO.ReadBandwidth = &grp.IOLimit.ReadBandwidth
+		}
+		if grp.IOLimit.WriteBandwidth != 0 {
+			constraints.IO.WriteBandwidth = &grp.IOLimit.WriteBandwidth
+		}
+		if grp.IOLimit.ReadIOPS != 0 {
+			constraints.IO.ReadIOPS = &grp.IOLimit.ReadIOPS
+		}
+		if grp.IOLimit.WriteIOPS != 0 {
+			constraints.IO.WriteIOPS = &grp.IOLimit.WriteIOPS
+		}
+		if grp.IOLimit.Weight != 0 {
+			constraints.IO.Weight = &grp.IOLimit.Weight
+		}
+	}
+	if grp.NetworkLimit != nil {
+		constraints.Network = &client.QuotaNetworkValues{}
+		if grp.NetworkLimit.IngressBandwidth != 0 {
+			constraints.Network.IngressBandwidth = &grp.NetworkLimit.IngressBandwidth
+		}
+		if grp.NetworkLimit.EgressBandwidth != 0 {
+			constraints.Network.EgressBandwidth = &grp.NetworkLimit.EgressBandwidth
+		}
+	}
+	constraints.MemoryHigh = grp.MemoryHighLimit
+	constraints.MemorySwap = grp.MemorySwapLimit
+	constraints.OOMPolicy = string(grp.OOMPolicy)
 	return &constraints
 }
 
@@ -191,6 +235,47 @@ func getQuotaGroupInfo(c *Command, r *http.Request, _ *auth.UserState) Response
 	return SyncResponse(res)
 }
 
+// getQuotaGroupUsage returns the recent resource usage of a single quota
+// group and of the apps and hooks in it.
+func getQuotaGroupUsage(c *Command, r *http.Request, _ *auth.UserState) Response {
+	vars := muxVars(r)
+	groupName := vars["group"]
+	if err := naming.ValidateQuotaGroup(groupName); err != nil {
+		return BadRequest(err.Error())
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	usage, err := servicestateQuotaGroupUsage(c.d.overlord.ServiceManager(), groupName)
+	switch err {
+	case nil:
+		return SyncResponse(usage)
+	case servicestate.ErrQuotaNotFound:
+		return NotFound("cannot find quota group %q", groupName)
+	case servicestate.ErrUsageUnavailable:
+		return BadRequest("cannot get usage of quota group %q: %v", groupName, err)
+	default:
+		return InternalError(err.Error())
+	}
+}
+
+func getSnapsUsage(c *Command, r *http.Request, _ *auth.UserState) Response {
+	usage, err := servicestateSnapsUsage(c.d.overlord.ServiceManager())
+	switch err {
+	case nil:
+		if usage == nil {
+			usage = []client.SnapUsage{}
+		}
+		return SyncResponse(usage)
+	case servicestate.ErrUsageUnavailable:
+		return BadRequest("cannot get usage of snaps: %v", err)
+	default:
+		return InternalError(err.Error())
+	}
+}
+
 func quotaValuesToResources(values client.QuotaValues) quota.Resources {
 	resourcesBuilder := quota.NewResourcesBuilder()
 	if values.Memory != 0 {
@@ -219,6 +304,40 @@ func quotaValuesToResources(values client.QuotaValues) quota.Resources {
 			resourcesBuilder.WithJournalRate(values.Journal.RateCount, values.Journal.RatePeriod)
 		}
 	}
+	if values.IO != nil {
+		if values.IO.ReadBandwidth != nil {
+			resourcesBuilder.WithIOReadBandwidth(*values.IO.ReadBandwidth)
+		}
+		if values.IO.WriteBandwidth != nil {
+			resourcesBuilder.WithIOWriteBandwidth(*values.IO.WriteBandwidth)
+		}
+		if values.IO.ReadIOPS != nil {
+			resourcesBuilder.WithIOReadIOPS(*values.IO.ReadIOPS)
+		}
+		if values.IO.WriteIOPS != nil {
+			resourcesBuilder.WithIOWriteIOPS(*values.IO.WriteIOPS)
+		}
+		if values.IO.Weight != nil {
+			resourcesBuilder.WithIOWeight(*values.IO.Weight)
+		}
+	}
+	if values.Network != nil {
+		if values.Network.IngressBandwidth != nil {
+			resourcesBuilder.WithNetworkIngressBandwidth(*values.Network.IngressBandwidth)
+		}
+		if values.Network.EgressBandwidth != nil {
+			resourcesBuilder.WithNetworkEgressBandwidth(*values.Network.EgressBandwidth)
+		}
+	}
+	if values.MemoryHigh != 0 {
+		resourcesBuilder.WithMemoryHighLimit(values.MemoryHigh)
+	}
+	if values.MemorySwap != nil {
+		resourcesBuilder.WithMemorySwapLimit(*values.MemorySwap)
+	}
+	if values.OOMPolicy != "" {
+		resourcesBuilder.WithOOMPolicy(quota.OOMPolicy(values.OOMPolicy))
+	}
 	return resourcesBuilder.Build()
 }
 
@@ -90,6 +90,12 @@ func (s *apiQuotaSuite) TestCreateQuotaValues(c *check.C) {
 			WithCPUSet([]int{0, 1}).
 			WithJournalRate(150, time.Second).
 			WithJournalSize(quantity.SizeMiB).
+			WithIOReadBandwidth(10*quantity.SizeMiB).
+			WithIOWeight(50).
+			WithNetworkEgressBandwidth(quantity.SizeMiB).
+			WithMemoryHighLimit(800*quantity.SizeKiB).
+			WithMemorySwapLimit(0).
+			WithOOMPolicy(quota.OOMPolicyKillGroup).
 			Build())
 	allGroups, err2 := servicestate.AllQuotas(st)
 	st.Unlock()
@@ -118,6 +124,20 @@ func (s *apiQuotaSuite) TestCreateQuotaValues(c *check.C) {
 			RatePeriod: time.Second,
 		},
 	})
+	readBandwidth := 10 * quantity.SizeMiB
+	weight := 50
+	c.Check(quotaValues.IO, check.DeepEquals, &client.QuotaIOValues{
+		ReadBandwidth: &readBandwidth,
+		Weight:        &weight,
+	})
+	egressBandwidth := quantity.SizeMiB
+	c.Check(quotaValues.Network, check.DeepEquals, &client.QuotaNetworkValues{
+		EgressBandwidth: &egressBandwidth,
+	})
+	c.Check(quotaValues.MemoryHigh, check.Equals, 800*quantity.SizeKiB)
+	c.Assert(quotaValues.MemorySwap, check.NotNil)
+	c.Check(*quotaValues.MemorySwap, check.Equals, quantity.Size(0))
+	c.Check(quotaValues.OOMPolicy, check.Equals, "kill-group")
 }
 
 func (s *apiQuotaSuite) TestPostQuotaUnknownAction(c *check.C) {
@@ -328,6 +348,122 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaCreateJournalRateZeroHappy(c *check.C
 	c.Assert(s.ensureSoonCalled, check.Equals, 1)
 }
 
+func (s *apiQuotaSuite) TestPostEnsureQuotaCreateNetworkHappy(c *check.C) {
+	var createCalled int
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, createOpts servicestate.CreateQuotaOptions) (*state.TaskSet, error) {
+		createCalled++
+		c.Check(name, check.Equals, "booze")
+		c.Check(createOpts.ResourceLimits, check.DeepEquals, quota.NewResourcesBuilder().
+			WithNetworkIngressBandwidth(quantity.SizeMiB).
+			WithNetworkEgressBandwidth(2*quantity.SizeMiB).
+			Build())
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	ingressBandwidth := quantity.SizeMiB
+	egressBandwidth := 2 * quantity.SizeMiB
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:    "ensure",
+		GroupName: "booze",
+		Snaps:     []string{"some-snap"},
+		Constraints: client.QuotaValues{
+			Network: &client.QuotaNetworkValues{
+				IngressBandwidth: &ingressBandwidth,
+				EgressBandwidth:  &egressBandwidth,
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(createCalled, check.Equals, 1)
+}
+
+func (s *apiQuotaSuite) TestPostEnsureQuotaCreateMemoryPressureHappy(c *check.C) {
+	var createCalled int
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, createOpts servicestate.CreateQuotaOptions) (*state.TaskSet, error) {
+		createCalled++
+		c.Check(name, check.Equals, "booze")
+		c.Check(createOpts.ResourceLimits, check.DeepEquals, quota.NewResourcesBuilder().
+			WithMemoryLimit(quantity.SizeGiB).
+			WithMemoryHighLimit(quantity.SizeMiB).
+			WithMemorySwapLimit(0).
+			WithOOMPolicy(quota.OOMPolicyRestartService).
+			Build())
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	noSwap := quantity.Size(0)
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:    "ensure",
+		GroupName: "booze",
+		Snaps:     []string{"some-snap"},
+		Constraints: client.QuotaValues{
+			Memory:     quantity.SizeGiB,
+			MemoryHigh: quantity.SizeMiB,
+			MemorySwap: &noSwap,
+			OOMPolicy:  "restart-service",
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(createCalled, check.Equals, 1)
+}
+
+func (s *apiQuotaSuite) TestPostEnsureQuotaCreateIOHappy(c *check.C) {
+	var createCalled int
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, createOpts servicestate.CreateQuotaOptions) (*state.TaskSet, error) {
+		createCalled++
+		c.Check(name, check.Equals, "booze")
+		c.Check(createOpts.ResourceLimits, check.DeepEquals, quota.NewResourcesBuilder().
+			WithIOReadBandwidth(quantity.SizeMiB).
+			WithIOWriteBandwidth(2*quantity.SizeMiB).
+			WithIOReadIOPS(100).
+			WithIOWriteIOPS(200).
+			WithIOWeight(10).
+			Build())
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	readBandwidth := quantity.SizeMiB
+	writeBandwidth := 2 * quantity.SizeMiB
+	readIOPS, writeIOPS, weight := 100, 200, 10
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:    "ensure",
+		GroupName: "booze",
+		Snaps:     []string{"some-snap"},
+		Constraints: client.QuotaValues{
+			IO: &client.QuotaIOValues{
+				ReadBandwidth:  &readBandwidth,
+				WriteBandwidth: &writeBandwidth,
+				ReadIOPS:       &readIOPS,
+				WriteIOPS:      &writeIOPS,
+				Weight:         &weight,
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(createCalled, check.Equals, 1)
+}
+
 func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateCpuHappy(c *check.C) {
 	st := s.d.Overlord().State()
 	st.Lock()
@@ -488,6 +624,43 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateMemoryHappy(c *check.C) {
 	c.Assert(s.ensureSoonCalled, check.Equals, 1)
 }
 
+func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateRemoveIOAndNetworkLimits(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	err := servicestatetest.MockQuotaInState(st, "ginger-ale", "", nil, nil,
+		quota.NewResourcesBuilder().
+			WithMemoryLimit(quantity.SizeMiB).
+			WithIOReadBandwidth(quantity.SizeMiB).
+			WithIOWeight(50).
+			WithNetworkEgressBandwidth(quantity.SizeMiB).
+			Build())
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	updateCalled := 0
+	r := daemon.MockServicestateUpdateQuota(func(st *state.State, name string, opts servicestate.UpdateQuotaOptions) (*state.TaskSet, error) {
+		updateCalled++
+		c.Assert(name, check.Equals, "ginger-ale")
+		// the zero limits are passed on, to remove them from the group
+		c.Assert(opts, check.DeepEquals, servicestate.UpdateQuotaOptions{
+			NewResourceLimits: quota.NewResourcesBuilder().
+				WithIOReadBandwidth(0).
+				WithNetworkEgressBandwidth(0).
+				Build(),
+		})
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	body := `{"action":"ensure","group-name":"ginger-ale","constraints":{"io":{"read-bandwidth":0},"network":{"egress-bandwidth":0}}}`
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(updateCalled, check.Equals, 1)
+}
+
 func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateConflicts(c *check.C) {
 	st := s.d.Overlord().State()
 	st.Lock()
@@ -868,3 +1041,120 @@ func (s *apiQuotaSuite) TestGetQuotaNotFound(c *check.C) {
 	c.Check(rspe.Message, check.Matches, `cannot find quota group "unknown"`)
 	c.Check(s.ensureSoonCalled, check.Equals, 0)
 }
+
+func (s *apiQuotaSuite) TestGetQuotaUsage(c *check.C) {
+	calls := 0
+	r := daemon.MockServicestateQuotaGroupUsage(func(m *servicestate.ServiceManager, name string) (*client.QuotaGroupUsage, error) {
+		calls++
+		c.Check(m, check.Equals, s.d.Overlord().ServiceManager())
+		c.Check(name, check.Equals, "foo")
+		return &client.QuotaGroupUsage{
+			GroupName: "foo",
+			Apps: []client.QuotaAppUsage{{
+				Snap:               "test-snap",
+				App:                "svc1",
+				QuotaResourceUsage: client.QuotaResourceUsage{CPUPercentage: 12.5, Memory: 1000, Tasks: 2},
+			}},
+		}, nil
+	})
+	defer r()
+
+	req, err := http.NewRequest("GET", "/v2/quotas/foo/usage", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, &client.QuotaGroupUsage{
+		GroupName: "foo",
+		Apps: []client.QuotaAppUsage{{
+			Snap:               "test-snap",
+			App:                "svc1",
+			QuotaResourceUsage: client.QuotaResourceUsage{CPUPercentage: 12.5, Memory: 1000, Tasks: 2},
+		}},
+	})
+	c.Check(calls, check.Equals, 1)
+}
+
+func (s *apiQuotaSuite) TestGetQuotaUsageErrors(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/quotas/000/usage", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Matches, `invalid quota group name: .*`)
+
+	for _, t := range []struct {
+		err     error
+		status  int
+		message string
+	}{
+		{servicestate.ErrQuotaNotFound, 404, `cannot find quota group "foo"`},
+		{servicestate.ErrUsageUnavailable, 400, `cannot get usage of quota group "foo": resource usage is only collected with cgroup version 2`},
+		{fmt.Errorf("boom"), 500, `boom`},
+	} {
+		r := daemon.MockServicestateQuotaGroupUsage(func(m *servicestate.ServiceManager, name string) (*client.QuotaGroupUsage, error) {
+			return nil, t.err
+		})
+		req, err := http.NewRequest("GET", "/v2/quotas/foo/usage", nil)
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, t.status)
+		c.Check(rspe.Message, check.Equals, t.message)
+		r()
+	}
+}
+
+func (s *apiQuotaSuite) TestGetSnapsUsage(c *check.C) {
+	calls := 0
+	r := daemon.MockServicestateSnapsUsage(func(m *servicestate.ServiceManager) ([]client.SnapUsage, error) {
+		calls++
+		c.Check(m, check.Equals, s.d.Overlord().ServiceManager())
+		return []client.SnapUsage{{
+			Snap:               "test-snap",
+			QuotaResourceUsage: client.QuotaResourceUsage{CPUPercentage: 12.5, Memory: 1000, Tasks: 2},
+		}}, nil
+	})
+	defer r()
+
+	req, err := http.NewRequest("GET", "/v2/usage", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, []client.SnapUsage{{
+		Snap:               "test-snap",
+		QuotaResourceUsage: client.QuotaResourceUsage{CPUPercentage: 12.5, Memory: 1000, Tasks: 2},
+	}})
+	c.Check(calls, check.Equals, 1)
+}
+
+func (s *apiQuotaSuite) TestGetSnapsUsageNoneRunning(c *check.C) {
+	r := daemon.MockServicestateSnapsUsage(func(m *servicestate.ServiceManager) ([]client.SnapUsage, error) {
+		return nil, nil
+	})
+	defer r()
+
+	req, err := http.NewRequest("GET", "/v2/usage", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, []client.SnapUsage{})
+}
+
+func (s *apiQuotaSuite) TestGetSnapsUsageErrors(c *check.C) {
+	for _, t := range []struct {
+		err     error
+		status  int
+		message string
+	}{
+		{servicestate.ErrUsageUnavailable, 400, `cannot get usage of snaps: resource usage is only collected with cgroup version 2`},
+		{fmt.Errorf("boom"), 500, `boom`},
+	} {
+		r := daemon.MockServicestateSnapsUsage(func(m *servicestate.ServiceManager) ([]client.SnapUsage, error) {
+			return nil, t.err
+		})
+		req, err := http.NewRequest("GET", "/v2/usage", nil)
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, t.status)
+		c.Check(rspe.Message, check.Equals, t.message)
+		r()
+	}
+}
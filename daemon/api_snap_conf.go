@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/strutil"
@@ -100,6 +101,15 @@ func setSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("cannot decode request body into patch values: %v", err)
 	}
 
+	var passphrase string
+	if snapName == "core" {
+		var err error
+		passphrase, err = takeSnapshotsEncryptionPassphrase(patchValues)
+		if err != nil {
+			return BadRequest("cannot set snapshot encryption passphrase: %v", err)
+		}
+	}
+
 	st := c.d.overlord.State()
 	st.Lock()
 	defer st.Unlock()
@@ -112,6 +122,9 @@ func setSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 		}
 		return errToResponse(err, []string{snapName}, InternalError, "%v")
 	}
+	if passphrase != "" {
+		snapshotstate.SetPendingEncryptionPassphrase(taskset.Tasks()[0], passphrase)
+	}
 
 	summary := fmt.Sprintf("Change configuration of %q snap", snapName)
 	change := newChange(st, "configure-snap", summary, []*state.TaskSet{taskset}, []string{snapName})
@@ -120,3 +133,37 @@ func setSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 
 	return AsyncResponse(nil, change.ID())
 }
+
+// takeSnapshotsEncryptionPassphrase removes snapshots.encryption-passphrase
+// from the patch and returns it, so that it's kept in memory by snapshotstate
+// instead of being part of the configure task, which is written with the
+// state. The passphrase is only used once the configuration is committed.
+func takeSnapshotsEncryptionPassphrase(patch map[string]interface{}) (string, error) {
+	var values []interface{}
+	if value, ok := patch["snapshots.encryption-passphrase"]; ok {
+		values = append(values, value)
+		delete(patch, "snapshots.encryption-passphrase")
+	}
+	if snapshots, ok := patch["snapshots"].(map[string]interface{}); ok {
+		if value, ok := snapshots["encryption-passphrase"]; ok {
+			values = append(values, value)
+			delete(snapshots, "encryption-passphrase")
+		}
+	}
+	var passphrase string
+	for _, value := range values {
+		if value == nil {
+			// unsetting it, it is not kept in the configuration anyway
+			continue
+		}
+		var ok bool
+		passphrase, ok = value.(string)
+		if !ok {
+			return "", fmt.Errorf("snapshots.encryption-passphrase must be a string")
+		}
+		if err := snapshotstate.ValidateEncryptionPassphrase(passphrase); err != nil {
+			return "", err
+		}
+	}
+	return passphrase, nil
+}
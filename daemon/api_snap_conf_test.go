@@ -32,7 +32,10 @@ import (
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -232,6 +235,166 @@ version: 1
 	c.Assert(value, check.Equals, "value")
 }
 
+func (s *snapConfSuite) testSetConfSnapshotsPassphraseNeverStored(c *check.C, journal bool) {
+	const passphrase = "correct horse battery staple"
+
+	stateFiles := []string{dirs.SnapStateFile}
+	if journal {
+		c.Assert(os.MkdirAll(dirs.FeaturesDir, 0755), check.IsNil)
+		c.Assert(ioutil.WriteFile(features.StateJournal.ControlFile(), nil, 0644), check.IsNil)
+		stateFiles = append(stateFiles, dirs.SnapStateJournalFile)
+	}
+
+	d := s.daemon(c)
+	s.mockSnap(c, `
+name: core
+version: 1
+`)
+
+	d.Overlord().Loop()
+	defer d.Overlord().Stop()
+
+	// the journal is compacted when stopping, so the state is checked
+	// while running; the state file is only written along with the whole
+	// state when the journal is used
+	checkNotStored := func(what string) {
+		for _, fn := range stateFiles {
+			data, err := ioutil.ReadFile(fn)
+			c.Assert(err, check.IsNil)
+			if fn == stateFiles[len(stateFiles)-1] {
+				// the checkpoints went there
+				c.Check(strings.Contains(string(data), "deduplicate"), check.Equals, true, check.Commentf("%s: %s", what, fn))
+			}
+			c.Check(strings.Contains(string(data), passphrase), check.Equals, false, check.Commentf("%s: %s", what, fn))
+		}
+	}
+
+	text, err := json.Marshal(map[string]interface{}{
+		"snapshots.encryption-passphrase": passphrase,
+		"snapshots.deduplicate":           "true",
+	})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("PUT", "/v2/snaps/system/conf", bytes.NewBuffer(text))
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	s.req(c, req, nil).ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, 202)
+	// the change with the configuration patch was written
+	checkNotStored("change created")
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Assert(err, check.IsNil)
+	id := body["change"].(string)
+
+	st := d.Overlord().State()
+	st.Lock()
+	chg := st.Change(id)
+	c.Assert(chg, check.NotNil)
+	// the passphrase is kept for the configure task
+	task := chg.Tasks()[0]
+	c.Check(snapshotstate.PendingEncryptionPassphrase(task), check.Equals, passphrase)
+	st.Unlock()
+
+	<-chg.Ready()
+
+	st.Lock()
+	c.Check(chg.Err(), check.IsNil)
+	tr := config.NewTransaction(st)
+	st.Unlock()
+	var dedup string
+	c.Check(tr.Get("core", "snapshots.deduplicate", &dedup), check.IsNil)
+	c.Check(dedup, check.Equals, "true")
+	checkNotStored("change done")
+
+	// and used once the configuration was committed
+	st.Lock()
+	c.Check(snapshotstate.PendingEncryptionPassphrase(task), check.Equals, "")
+	st.Unlock()
+	c.Check(backend.EncryptionPassphrase(), check.Equals, passphrase)
+}
+
+func (s *snapConfSuite) TestSetConfSnapshotsPassphraseNeverStored(c *check.C) {
+	s.testSetConfSnapshotsPassphraseNeverStored(c, false)
+}
+
+func (s *snapConfSuite) TestSetConfSnapshotsPassphraseNeverStoredInJournal(c *check.C) {
+	s.testSetConfSnapshotsPassphraseNeverStored(c, true)
+}
+
+func (s *snapConfSuite) TestSetConfSnapshotsPassphraseNotUsedOnError(c *check.C) {
+	const passphrase = "rejected horse battery staple"
+
+	d := s.daemon(c)
+	s.mockSnap(c, `
+name: core
+version: 1
+`)
+
+	d.Overlord().Loop()
+	defer d.Overlord().Stop()
+
+	text, err := json.Marshal(map[string]interface{}{
+		"snapshots.encryption-passphrase": passphrase,
+		"snapshots.schedule":              "invalid",
+	})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("PUT", "/v2/snaps/system/conf", bytes.NewBuffer(text))
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	s.req(c, req, nil).ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, 202)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Assert(err, check.IsNil)
+	id := body["change"].(string)
+
+	st := d.Overlord().State()
+	st.Lock()
+	chg := st.Change(id)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
+
+	<-chg.Ready()
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(chg.Err(), check.ErrorMatches, "(?s).*cannot parse snapshots.schedule.*")
+	// the passphrase of the failed change is not used
+	c.Check(backend.EncryptionPassphrase(), check.Not(check.Equals), passphrase)
+}
+
+func (s *snapConfSuite) TestSetConfSnapshotsPassphraseErrors(c *check.C) {
+	s.daemon(c)
+	s.mockSnap(c, `
+name: core
+version: 1
+`)
+
+	for _, t := range []struct {
+		patch map[string]interface{}
+		err   string
+	}{
+		{map[string]interface{}{"snapshots.encryption-passphrase": "short"}, "cannot set snapshot encryption passphrase: snapshots.encryption-passphrase must be at least 8 characters long"},
+		{map[string]interface{}{"snapshots": map[string]interface{}{"encryption-passphrase": 42}}, "cannot set snapshot encryption passphrase: snapshots.encryption-passphrase must be a string"},
+	} {
+		text, err := json.Marshal(t.patch)
+		c.Assert(err, check.IsNil)
+		req, err := http.NewRequest("PUT", "/v2/snaps/core/conf", bytes.NewBuffer(text))
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Equals, t.err)
+	}
+
+	// no change was created
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+}
+
 func (s *snapConfSuite) TestSetConfNumber(c *check.C) {
 	d := s.daemon(c)
 	s.mockSnap(c, configYaml)
@@ -29,6 +29,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
@@ -143,15 +144,25 @@ func postSnap(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("unknown action %s", inst.Action)
 	}
 
+	if inst.DryRun {
+		defer snapstate.StartPlanning(st)()
+	}
 	msg, tsets, err := impl(&inst, st)
 	if err != nil {
 		return inst.errToResponse(err)
 	}
+	if inst.DryRun {
+		return snapPlan(st, tsets)
+	}
 
 	chg := newChange(st, inst.Action+"-snap", msg, tsets, inst.Snaps)
 	if len(tsets) == 0 {
 		chg.SetStatus(state.DoneStatus)
 	}
+	chg.SetPriority(inst.changePriority())
+	if !inst.NotBefore.IsZero() {
+		chg.SetNotBefore(inst.NotBefore)
+	}
 
 	if inst.SystemRestartImmediate {
 		chg.Set("system-restart-immediate", true)
@@ -162,12 +173,33 @@ func postSnap(c *Command, r *http.Request, user *auth.UserState) Response {
 	return AsyncResponse(nil, chg.ID())
 }
 
+// maxNotBefore is how far ahead a change can be held with not-before, as
+// the held change keeps its snaps from being changed otherwise.
+const maxNotBefore = 7 * 24 * time.Hour
+
+// validateNotBefore checks that a change is held until a time in the future,
+// at most maxNotBefore from now.
+func validateNotBefore(notBefore time.Time) error {
+	now := time.Now()
+	if notBefore.Before(now) {
+		return fmt.Errorf("not-before must be in the future: %s", notBefore.Format(time.RFC3339))
+	}
+	if notBefore.After(now.Add(maxNotBefore)) {
+		return fmt.Errorf("not-before cannot be more than %d days from now: %s", int(maxNotBefore.Hours()/24), notBefore.Format(time.RFC3339))
+	}
+	return nil
+}
+
 type snapRevisionOptions struct {
 	Channel  string        `json:"channel"`
 	Revision snap.Revision `json:"revision"`
 
 	CohortKey   string `json:"cohort-key"`
 	LeaveCohort bool   `json:"leave-cohort"`
+
+	// Architectures are the acceptable architectures in order of
+	// preference
+	Architectures []string `json:"architectures"`
 }
 
 func (ropt *snapRevisionOptions) validate() error {
@@ -186,6 +218,22 @@ func (ropt *snapRevisionOptions) validate() error {
 			return err
 		}
 	}
+
+	return validateArchitectures(ropt.Architectures)
+}
+
+// validateArchitectures checks a list of preferred architectures.
+func validateArchitectures(architectures []string) error {
+	seen := make(map[string]bool, len(architectures))
+	for _, arch := range architectures {
+		if arch == "" {
+			return fmt.Errorf("cannot specify an empty architecture")
+		}
+		if seen[arch] {
+			return fmt.Errorf("cannot specify architecture %q more than once", arch)
+		}
+		seen[arch] = true
+	}
 	return nil
 }
 
@@ -207,10 +255,18 @@ type snapInstruction struct {
 	Snaps                  []string                         `json:"snaps"`
 	Users                  []string                         `json:"users"`
 	SnapshotOptions        map[string]*snap.SnapshotOptions `json:"snapshot-options"`
+	Encrypt                bool                             `json:"encrypt"`
 	ValidationSets         []string                         `json:"validation-sets"`
 	QuotaGroupName         string                           `json:"quota-group"`
 	Time                   string                           `json:"time"`
 	HoldLevel              string                           `json:"hold-level"`
+	Priority               string                           `json:"priority"`
+	// NotBefore holds the change until the given time. The snaps and
+	// revisions to change are found when the change is created, and the
+	// snaps cannot be changed otherwise, including by auto-refresh,
+	// until the change is done.
+	NotBefore time.Time `json:"not-before"`
+	DryRun    bool      `json:"dry-run"`
 
 	// The fields below should not be unmarshalled into. Do not export them.
 	userID int
@@ -219,13 +275,27 @@ type snapInstruction struct {
 
 func (inst *snapInstruction) revnoOpts() *snapstate.RevisionOptions {
 	return &snapstate.RevisionOptions{
-		Channel:     inst.Channel,
-		Revision:    inst.Revision,
-		CohortKey:   inst.CohortKey,
-		LeaveCohort: inst.LeaveCohort,
+		Channel:       inst.Channel,
+		Revision:      inst.Revision,
+		CohortKey:     inst.CohortKey,
+		LeaveCohort:   inst.LeaveCohort,
+		Architectures: inst.Architectures,
 	}
 }
 
+// manyRevnoOpts returns the revision options of the snaps of a multi-snap
+// operation, which can only select the architectures.
+func (inst *snapInstruction) manyRevnoOpts() []*snapstate.RevisionOptions {
+	if len(inst.Architectures) == 0 {
+		return nil
+	}
+	revOpts := make([]*snapstate.RevisionOptions, len(inst.Snaps))
+	for i := range inst.Snaps {
+		revOpts[i] = &snapstate.RevisionOptions{Architectures: inst.Architectures}
+	}
+	return revOpts
+}
+
 func (inst *snapInstruction) modeFlags() (snapstate.Flags, error) {
 	return modeFlags(inst.DevMode, inst.JailMode, inst.Classic)
 }
@@ -249,6 +319,16 @@ func (inst *snapInstruction) installFlags() (snapstate.Flags, error) {
 	return flags, nil
 }
 
+// changePriority returns the priority of the change for the instruction, it
+// must have been validated.
+func (inst *snapInstruction) changePriority() state.Priority {
+	if inst.Priority == "" {
+		return state.NormalPriority
+	}
+	p, _ := state.ParsePriority(inst.Priority)
+	return p
+}
+
 func (inst *snapInstruction) holdLevel() snapstate.HoldLevel {
 	switch inst.HoldLevel {
 	case "auto-refresh":
@@ -308,6 +388,11 @@ func (inst *snapInstruction) validate() error {
 			return fmt.Errorf("leave-cohort can only be specified for refresh or switch")
 		}
 	}
+	if len(inst.Architectures) > 0 {
+		if inst.Action != "install" && inst.Action != "refresh" {
+			return fmt.Errorf("architectures can only be specified for install or refresh")
+		}
+	}
 	if inst.Action == "install" {
 		for _, snapName := range inst.Snaps {
 			// FIXME: alternatively we could simply mutate *inst
@@ -329,6 +414,14 @@ func (inst *snapInstruction) validate() error {
 	if inst.QuotaGroupName != "" && inst.Action != "install" {
 		return fmt.Errorf("quota-group can only be specified on install")
 	}
+	if inst.Priority != "" {
+		if inst.Action != "install" && inst.Action != "refresh" {
+			return fmt.Errorf("priority can only be specified for install or refresh")
+		}
+		if _, err := state.ParsePriority(inst.Priority); err != nil {
+			return err
+		}
+	}
 
 	if inst.Action == "hold" {
 		if inst.Time == "" {
@@ -345,6 +438,24 @@ func (inst *snapInstruction) validate() error {
 		}
 	}
 
+	if inst.DryRun {
+		if inst.Action != "install" && inst.Action != "refresh" && inst.Action != "remove" {
+			return fmt.Errorf("dry-run can only be specified for install, refresh or remove")
+		}
+		if len(inst.ValidationSets) > 0 {
+			return fmt.Errorf("dry-run cannot be used with validation-sets")
+		}
+	}
+
+	if !inst.NotBefore.IsZero() {
+		if inst.Action == "hold" || inst.Action == "unhold" {
+			return fmt.Errorf("not-before cannot be specified for the %q action", inst.Action)
+		}
+		if err := validateNotBefore(inst.NotBefore); err != nil {
+			return err
+		}
+	}
+
 	if inst.Action != "hold" {
 		if inst.Time != "" {
 			return errors.New(`time can only be specified for the "hold" action`)
@@ -357,6 +468,9 @@ func (inst *snapInstruction) validate() error {
 	if err := inst.validateSnapshotOptions(); err != nil {
 		return err
 	}
+	if inst.Encrypt && inst.Action != "snapshot" {
+		return fmt.Errorf("encrypt can only be specified for snapshot action")
+	}
 
 	if inst.Action == "snapshot" {
 		inst.cleanSnapshotOptions()
@@ -463,7 +577,7 @@ func snapUpdate(inst *snapInstruction, st *state.State) (string, []*state.TaskSe
 }
 
 func snapRemove(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
-	ts, err := snapstate.Remove(st, inst.Snaps[0], inst.Revision, &snapstate.RemoveFlags{Purge: inst.Purge})
+	ts, err := snapstate.Remove(st, inst.Snaps[0], inst.Revision, &snapstate.RemoveFlags{Purge: inst.Purge, DryRun: inst.DryRun})
 	if err != nil {
 		return "", nil, err
 	}
@@ -616,6 +730,60 @@ func postSnaps(c *Command, r *http.Request, user *auth.UserState) Response {
 	return sideloadOrTrySnap(c, r.Body, params["boundary"], user)
 }
 
+// snapPlan returns what the task sets built for a snap operation would do,
+// discarding them.
+func snapPlan(st *state.State, tsets []*state.TaskSet) Response {
+	defer func() {
+		for _, ts := range tsets {
+			st.DiscardTasks(ts.Tasks())
+		}
+	}()
+
+	plan, err := snapstatePlanTaskSets(st, tsets)
+	if err != nil {
+		return InternalError("cannot plan snap operation: %v", err)
+	}
+	result := &client.Plan{
+		Prerequisites:     plan.Prerequisites,
+		RestartedServices: plan.RestartedServices,
+		RebootRequired:    plan.RebootRequired,
+		DownloadSize:      plan.DownloadSize,
+	}
+	for _, planned := range plan.Snaps {
+		plannedSnap := &client.PlannedSnap{
+			Name:         planned.InstanceName,
+			Action:       planned.Action,
+			Revision:     planned.Revision,
+			Channel:      planned.Channel,
+			Type:         string(planned.Type),
+			DownloadSize: planned.DownloadSize,
+		}
+		result.Snaps = append(result.Snaps, plannedSnap)
+		if planned.Action == "remove" {
+			continue
+		}
+		if planned.Info == nil {
+			plannedSnap.AutoConnectionsUnknown = true
+			continue
+		}
+		candidates, err := ifacestateAutoConnectCandidates(st, planned.Info)
+		if errors.Is(err, &asserts.NotFoundError{}) {
+			// the snap declaration is only fetched with the snap
+			plannedSnap.AutoConnectionsUnknown = true
+			continue
+		}
+		if err != nil {
+			return InternalError("cannot plan snap operation: %v", err)
+		}
+		for _, id := range candidates {
+			if !strutil.ListContains(result.AutoConnections, id) {
+				result.AutoConnections = append(result.AutoConnections, id)
+			}
+		}
+	}
+	return SyncResponse(result)
+}
+
 func snapOpMany(c *Command, r *http.Request, user *auth.UserState) Response {
 	route := c.d.router.Get(stateChangeCmd.Path)
 	if route == nil {
@@ -632,6 +800,9 @@ func snapOpMany(c *Command, r *http.Request, user *auth.UserState) Response {
 	if inst.Channel != "" || !inst.Revision.Unset() || inst.DevMode || inst.JailMode || inst.CohortKey != "" || inst.LeaveCohort || inst.Purge {
 		return BadRequest("unsupported option provided for multi-snap operation")
 	}
+	if len(inst.Architectures) > 0 && len(inst.Snaps) == 0 {
+		return BadRequest("cannot specify architectures without snaps")
+	}
 	if err := inst.validate(); err != nil {
 		return BadRequest("%v", err)
 	}
@@ -648,15 +819,25 @@ func snapOpMany(c *Command, r *http.Request, user *auth.UserState) Response {
 	if op == nil {
 		return BadRequest("unsupported multi-snap operation %q", inst.Action)
 	}
+	if inst.DryRun {
+		defer snapstate.StartPlanning(st)()
+	}
 	res, err := op(&inst, st)
 	if err != nil {
 		return inst.errToResponse(err)
 	}
+	if inst.DryRun {
+		return snapPlan(st, res.Tasksets)
+	}
 
 	chg := newChange(st, inst.Action+"-snap", res.Summary, res.Tasksets, res.Affected)
 	if len(res.Tasksets) == 0 {
 		chg.SetStatus(state.DoneStatus)
 	}
+	chg.SetPriority(inst.changePriority())
+	if !inst.NotBefore.IsZero() {
+		chg.SetNotBefore(inst.NotBefore)
+	}
 
 	if inst.SystemRestartImmediate {
 		chg.Set("system-restart-immediate", true)
@@ -701,7 +882,7 @@ func snapInstallMany(inst *snapInstruction, st *state.State) (*snapInstructionRe
 		}
 	}
 	transaction := inst.Transaction
-	installed, tasksets, err := snapstateInstallMany(st, inst.Snaps, nil, inst.userID, &snapstate.Flags{Transaction: transaction})
+	installed, tasksets, err := snapstateInstallMany(st, inst.Snaps, inst.manyRevnoOpts(), inst.userID, &snapstate.Flags{Transaction: transaction})
 	if err != nil {
 		return nil, err
 	}
@@ -739,7 +920,7 @@ func snapUpdateMany(inst *snapInstruction, st *state.State) (*snapInstructionRes
 
 	transaction := inst.Transaction
 	// TODO: use a per-request context
-	updated, tasksets, err := snapstateUpdateMany(context.TODO(), st, inst.Snaps, nil, inst.userID, &snapstate.Flags{
+	updated, tasksets, err := snapstateUpdateMany(context.TODO(), st, inst.Snaps, inst.manyRevnoOpts(), inst.userID, &snapstate.Flags{
 		IgnoreRunning: inst.IgnoreRunning,
 		Transaction:   transaction,
 	})
@@ -842,7 +1023,7 @@ func meetSnapConstraintsForEnforce(inst *snapInstruction, st *state.State, vErr
 }
 
 func snapRemoveMany(inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
-	flags := &snapstate.RemoveFlags{Purge: inst.Purge}
+	flags := &snapstate.RemoveFlags{Purge: inst.Purge, DryRun: inst.DryRun}
 	removed, tasksets, err := snapstateRemoveMany(st, inst.Snaps, flags)
 	if err != nil {
 		return nil, err
@@ -44,7 +44,9 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/sandbox"
@@ -499,6 +501,21 @@ func (s *snapsSuite) TestPostSnapsOptionsUnsupportedActionError(c *check.C) {
 	}
 }
 
+func (s *snapsSuite) TestPostSnapsEncryptUnsupportedActionError(c *check.C) {
+	s.daemon(c)
+
+	for _, action := range []string{"install", "refresh", "remove"} {
+		buf := strings.NewReader(fmt.Sprintf(`{"action": "%s", "snaps":["foo"], "encrypt": true}`, action))
+		req, err := http.NewRequest("POST", "/v2/snaps", buf)
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf("%q", action))
+		c.Check(rspe.Message, check.Equals, "encrypt can only be specified for snapshot action", check.Commentf("%q", action))
+	}
+}
+
 func (s *snapsSuite) TestPostSnapsOptionsOtherErrors(c *check.C) {
 	s.daemon(c)
 	const notListedErr = `cannot use snapshot-options for snap "xyzzy" that is not listed in snaps`
@@ -529,7 +546,7 @@ func (s *snapsSuite) TestPostSnapsOptionsOtherErrors(c *check.C) {
 func (s *snapsSuite) TestPostSnapsOptionsClean(c *check.C) {
 	var snapshotSaveCalled int
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, flags *snapshotstate.SaveFlags) (uint64, []string, *state.TaskSet, error) {
 		snapshotSaveCalled++
 
 		c.Check(snaps, check.HasLen, 3)
@@ -604,6 +621,167 @@ func (s *snapsSuite) testPostSnapsOp(c *check.C, extraJSON, contentType string)
 	return systemRestartImmediate
 }
 
+func (s *snapsSuite) TestPostSnapsDryRun(c *check.C) {
+	fooInfo := &snap.Info{SideInfo: snap.SideInfo{RealName: "foo", Revision: snap.R(12)}}
+	barInfo := &snap.Info{SideInfo: snap.SideInfo{RealName: "bar", Revision: snap.R(3)}}
+	bazInfo := &snap.Info{SideInfo: snap.SideInfo{RealName: "baz", SnapID: "baz-id", Revision: snap.R(1)}}
+	defer daemon.MockAssertstateRefreshSnapAssertions(func(*state.State, int, *assertstate.RefreshAssertionsOptions) error { return nil })()
+	var tsets []*state.TaskSet
+	defer daemon.MockSnapstateUpdateMany(func(_ context.Context, s *state.State, names []string, _ []*snapstate.RevisionOptions, _ int, _ *snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		c.Check(names, check.DeepEquals, []string{"foo", "bar"})
+		t1 := s.NewTask("fake-refresh", "Refreshing foo")
+		t2 := s.NewTask("fake-refresh", "Refreshing bar")
+		tsets = []*state.TaskSet{state.NewTaskSet(t1), state.NewTaskSet(t2)}
+		return []string{"foo", "bar"}, tsets, nil
+	})()
+	defer daemon.MockSnapstatePlanTaskSets(func(_ *state.State, tss []*state.TaskSet) (*snapstate.Plan, error) {
+		c.Check(tss, check.DeepEquals, tsets)
+		return &snapstate.Plan{
+			Snaps: []*snapstate.PlannedSnap{
+				{InstanceName: "foo", Action: "refresh", Revision: snap.R(12), CurrentRevision: snap.R(11), Channel: "stable", Type: snap.TypeApp, DownloadSize: 1024, Info: fooInfo},
+				{InstanceName: "bar", Action: "refresh", Revision: snap.R(3), CurrentRevision: snap.R(2), Channel: "edge", Type: snap.TypeApp, DownloadSize: 512, Info: barInfo},
+				// the snap declaration of a snap to install is
+				// not known yet
+				{InstanceName: "baz", Action: "install", Revision: snap.R(1), Channel: "stable", Type: snap.TypeApp, Info: bazInfo},
+				{InstanceName: "quux", Action: "install", Revision: snap.R(1), Channel: "stable", Type: snap.TypeApp},
+			},
+			Prerequisites:     []string{"core22"},
+			RestartedServices: []string{"foo.svc"},
+			DownloadSize:      1536,
+		}, nil
+	})()
+	defer daemon.MockIfacestateAutoConnectCandidates(func(_ *state.State, info *snap.Info) ([]string, error) {
+		if info == bazInfo {
+			return nil, &asserts.NotFoundError{Type: asserts.SnapDeclarationType}
+		}
+		c.Check(info.Revision, check.Not(check.Equals), snap.R(1))
+		return []string{"bar:network core:network", info.InstanceName() + ":home core:home"}, nil
+	})()
+
+	d := s.daemonWithOverlordMockAndStore()
+
+	buf := bytes.NewBufferString(`{"action": "refresh", "snaps": ["foo", "bar"], "dry-run": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, &client.Plan{
+		Snaps: []*client.PlannedSnap{
+			{Name: "foo", Action: "refresh", Revision: snap.R(12), Channel: "stable", Type: "app", DownloadSize: 1024},
+			{Name: "bar", Action: "refresh", Revision: snap.R(3), Channel: "edge", Type: "app", DownloadSize: 512},
+			{Name: "baz", Action: "install", Revision: snap.R(1), Channel: "stable", Type: "app", AutoConnectionsUnknown: true},
+			{Name: "quux", Action: "install", Revision: snap.R(1), Channel: "stable", Type: "app", AutoConnectionsUnknown: true},
+		},
+		Prerequisites:     []string{"core22"},
+		AutoConnections:   []string{"bar:network core:network", "foo:home core:home", "bar:home core:home"},
+		RestartedServices: []string{"foo.svc"},
+		DownloadSize:      1536,
+	})
+
+	// nothing was committed
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	c.Check(st.TaskCount(), check.Equals, 0)
+}
+
+func (s *snapsSuite) TestPostSnapDryRun(c *check.C) {
+	defer daemon.MockSnapstateInstall(func(ctx context.Context, s *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		t := s.NewTask("fake-install-snap", "Doing a fake install")
+		return state.NewTaskSet(t), nil
+	})()
+	defer daemon.MockSnapstatePlanTaskSets(func(_ *state.State, tss []*state.TaskSet) (*snapstate.Plan, error) {
+		c.Check(tss, check.HasLen, 1)
+		return &snapstate.Plan{
+			Snaps: []*snapstate.PlannedSnap{
+				{InstanceName: "foo", Action: "install", Revision: snap.R(1), Type: snap.TypeKernel, Info: &snap.Info{SideInfo: snap.SideInfo{RealName: "foo", Revision: snap.R(1)}}},
+			},
+			RebootRequired: true,
+		}, nil
+	})()
+	defer daemon.MockIfacestateAutoConnectCandidates(func(*state.State, *snap.Info) ([]string, error) {
+		return nil, nil
+	})()
+
+	d := s.daemonWithOverlordMock()
+
+	buf := bytes.NewBufferString(`{"action": "install", "dry-run": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/foo", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, &client.Plan{
+		Snaps: []*client.PlannedSnap{
+			{Name: "foo", Action: "install", Revision: snap.R(1), Type: "kernel"},
+		},
+		RebootRequired: true,
+	})
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	c.Check(st.TaskCount(), check.Equals, 0)
+}
+
+func (s *snapsSuite) TestPostSnapRemoveDryRunNoSideEffects(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "")
+
+	st := d.Overlord().State()
+	st.Lock()
+	// automatic snapshots are taken on the removal of apps
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(st, "foo", &snapst), check.IsNil)
+	snapst.SnapType = "app"
+	snapstate.Set(st, "foo", &snapst)
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "snapshots.automatic.retention", "48h"), check.IsNil)
+	tr.Commit()
+	st.Unlock()
+
+	buf := bytes.NewBufferString(`{"action": "remove", "dry-run": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/foo", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	plan, ok := rsp.Result.(*client.Plan)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(plan.Snaps, check.HasLen, 1)
+	c.Check(plan.Snaps[0].Action, check.Equals, "remove")
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	c.Check(st.TaskCount(), check.Equals, 0)
+	// no snapshot set ID was allocated
+	var setID uint64
+	c.Check(st.Get("last-snapshot-set-id", &setID), testutil.ErrorIs, state.ErrNoState)
+}
+
+func (s *snapsSuite) TestPostSnapsDryRunErrors(c *check.C) {
+	s.daemonWithOverlordMock()
+
+	for _, tc := range []struct {
+		body   string
+		errMsg string
+	}{
+		{`{"action": "hold", "time": "forever", "hold-level": "general", "dry-run": true}`, "dry-run can only be specified for install, refresh or remove"},
+		{`{"action": "snapshot", "dry-run": true}`, "dry-run can only be specified for install, refresh or remove"},
+		{`{"action": "refresh", "validation-sets": ["foo/bar"], "dry-run": true}`, "dry-run cannot be used with validation-sets"},
+	} {
+		req, err := http.NewRequest("POST", "/v2/snaps", strings.NewReader(tc.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf("%s", tc.body))
+		c.Check(rspe.Message, check.Equals, tc.errMsg, check.Commentf("%s", tc.body))
+	}
+}
+
 func (s *snapsSuite) TestPostSnapsOpInvalidCharset(c *check.C) {
 	s.daemon(c)
 
@@ -778,6 +956,45 @@ func (s *snapsSuite) TestRefreshMany(c *check.C) {
 	c.Check(refreshAssertionsOpts.IsRefreshOfAllSnaps, check.Equals, false)
 }
 
+func (s *snapsSuite) TestRefreshManyArchitectures(c *check.C) {
+	defer daemon.MockAssertstateRefreshSnapAssertions(func(s *state.State, userID int, opts *assertstate.RefreshAssertionsOptions) error {
+		return nil
+	})()
+
+	var calledRevOpts []*snapstate.RevisionOptions
+	defer daemon.MockSnapstateUpdateMany(func(_ context.Context, s *state.State, names []string, revOpts []*snapstate.RevisionOptions, userID int, flags *snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		calledRevOpts = revOpts
+		t := s.NewTask("fake-refresh-2", "Refreshing two")
+		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	})()
+
+	d := s.daemon(c)
+	inst := &daemon.SnapInstruction{Action: "refresh", Snaps: []string{"foo", "bar"}}
+	inst.Architectures = []string{"armhf"}
+	st := d.Overlord().State()
+	st.Lock()
+	_, err := inst.DispatchForMany()(inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(calledRevOpts, check.DeepEquals, []*snapstate.RevisionOptions{
+		{Architectures: []string{"armhf"}},
+		{Architectures: []string{"armhf"}},
+	})
+}
+
+func (s *snapsSuite) TestPostSnapsArchitecturesWithoutSnaps(c *check.C) {
+	s.daemonWithOverlordMockAndStore()
+
+	buf := strings.NewReader(`{"action": "refresh", "architectures": ["armhf"]}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "cannot specify architectures without snaps")
+}
+
 func (s *snapsSuite) TestRefreshManyIgnoreRunning(c *check.C) {
 	defer daemon.MockAssertstateRefreshSnapAssertions(func(s *state.State, userID int, opts *assertstate.RefreshAssertionsOptions) error {
 		return nil
@@ -851,6 +1068,28 @@ func (s *snapsSuite) TestInstallMany(c *check.C) {
 	c.Check(res.Affected, check.DeepEquals, inst.Snaps)
 }
 
+func (s *snapsSuite) TestInstallManyArchitectures(c *check.C) {
+	var calledRevOpts []*snapstate.RevisionOptions
+	defer daemon.MockSnapstateInstallMany(func(s *state.State, names []string, revOpts []*snapstate.RevisionOptions, userID int, _ *snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		calledRevOpts = revOpts
+		t := s.NewTask("fake-install-2", "Install two")
+		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	})()
+
+	d := s.daemon(c)
+	inst := &daemon.SnapInstruction{Action: "install", Snaps: []string{"foo", "bar"}}
+	inst.Architectures = []string{"arm64", "armhf"}
+	st := d.Overlord().State()
+	st.Lock()
+	_, err := inst.DispatchForMany()(inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(calledRevOpts, check.DeepEquals, []*snapstate.RevisionOptions{
+		{Architectures: []string{"arm64", "armhf"}},
+		{Architectures: []string{"arm64", "armhf"}},
+	})
+}
+
 func (s *snapsSuite) TestInstallManyTransactionally(c *check.C) {
 	var calledFlags *snapstate.Flags
 
@@ -1566,6 +1805,38 @@ func (s *snapsSuite) TestPostSnapCohortUnsupportedAction(c *check.C) {
 	}
 }
 
+func (s *snapsSuite) TestPostSnapArchitecturesUnsupportedAction(c *check.C) {
+	s.daemonWithOverlordMock()
+	const expectedErr = "architectures can only be specified for install or refresh"
+
+	for _, action := range []string{"remove", "revert", "switch", "enable", "disable", "xyzzy"} {
+		buf := strings.NewReader(fmt.Sprintf(`{"action": "%s", "architectures": ["amd64"]}`, action))
+		req, err := http.NewRequest("POST", "/v2/snaps/some-snap", buf)
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf("%q", action))
+		c.Check(rspe.Message, check.Equals, expectedErr, check.Commentf("%q", action))
+	}
+}
+
+func (s *snapsSuite) TestPostSnapArchitecturesInvalid(c *check.C) {
+	s.daemonWithOverlordMock()
+
+	for archs, expectedErr := range map[string]string{
+		`[""]`:               "cannot specify an empty architecture",
+		`["arm64", "arm64"]`: `cannot specify architecture "arm64" more than once`,
+	} {
+		buf := strings.NewReader(fmt.Sprintf(`{"action": "install", "architectures": %s}`, archs))
+		req, err := http.NewRequest("POST", "/v2/snaps/some-snap", buf)
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf(archs))
+		c.Check(rspe.Message, check.Equals, expectedErr, check.Commentf(archs))
+	}
+}
+
 func (s *snapsSuite) TestPostSnapQuotaGroupWrongAction(c *check.C) {
 	s.daemonWithOverlordMock()
 	const expectedErr = "quota-group can only be specified on install"
@@ -1581,6 +1852,124 @@ func (s *snapsSuite) TestPostSnapQuotaGroupWrongAction(c *check.C) {
 	}
 }
 
+func (s *snapsSuite) TestPostSnapWithPriority(c *check.C) {
+	d := s.daemonWithOverlordMock()
+
+	defer daemon.MockSnapstateInstall(func(ctx context.Context, s *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		t := s.NewTask("fake-install-snap", "Doing a fake install")
+		return state.NewTaskSet(t), nil
+	})()
+
+	for _, tc := range []struct {
+		extraJSON string
+		priority  state.Priority
+	}{
+		{"", state.NormalPriority},
+		{`, "priority": "high"`, state.HighPriority},
+		{`, "priority": "low"`, state.LowPriority},
+	} {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"action": "install"%s}`, tc.extraJSON))
+		req, err := http.NewRequest("POST", "/v2/snaps/foo", buf)
+		c.Assert(err, check.IsNil)
+
+		rsp := s.asyncReq(c, req, nil)
+
+		st := d.Overlord().State()
+		st.Lock()
+		chg := st.Change(rsp.Change)
+		c.Assert(chg, check.NotNil)
+		c.Check(chg.Priority(), check.Equals, tc.priority)
+		st.Unlock()
+	}
+}
+
+func (s *snapsSuite) TestPostSnapPriorityErrors(c *check.C) {
+	s.daemonWithOverlordMock()
+
+	for _, action := range []string{"remove", "revert", "enable", "disable", "xyzzy"} {
+		buf := strings.NewReader(fmt.Sprintf(`{"action": "%s", "priority": "high"}`, action))
+		req, err := http.NewRequest("POST", "/v2/snaps/some-snap", buf)
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf("%q", action))
+		c.Check(rspe.Message, check.Equals, "priority can only be specified for install or refresh", check.Commentf("%q", action))
+	}
+
+	buf := strings.NewReader(`{"action": "install", "priority": "urgent"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/some-snap", buf)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `invalid change priority "urgent", expected one of: low, normal, high`)
+}
+
+func (s *snapsSuite) TestPostSnapNotBefore(c *check.C) {
+	d := s.daemonWithOverlordMock()
+
+	defer daemon.MockSnapstateInstall(func(ctx context.Context, s *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		t := s.NewTask("fake-install-snap", "Doing a fake install")
+		return state.NewTaskSet(t), nil
+	})()
+
+	notBefore := time.Now().Add(time.Hour).Truncate(time.Second)
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"action": "install", "not-before": %q}`, notBefore.Format(time.RFC3339)))
+	req, err := http.NewRequest("POST", "/v2/snaps/foo", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.asyncReq(c, req, nil)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.NotBefore().Equal(notBefore), check.Equals, true)
+	c.Assert(chg.Tasks(), check.HasLen, 1)
+	c.Check(chg.Tasks()[0].AtTime().Equal(notBefore), check.Equals, true)
+}
+
+func (s *snapsSuite) TestPostSnapNotBeforeErrors(c *check.C) {
+	s.daemonWithOverlordMock()
+
+	for _, action := range []string{"hold", "unhold"} {
+		buf := strings.NewReader(fmt.Sprintf(`{"action": "%s", "time": "forever", "hold-level": "general", "not-before": "2030-01-02T03:04:05Z"}`, action))
+		req, err := http.NewRequest("POST", "/v2/snaps/some-snap", buf)
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf("%q", action))
+		c.Check(rspe.Message, check.Equals, fmt.Sprintf("not-before cannot be specified for the %q action", action))
+	}
+
+	buf := strings.NewReader(`{"action": "install", "not-before": "tomorrow"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/some-snap", buf)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Matches, "cannot decode request body into snap instruction: .*")
+	for _, tc := range []struct {
+		notBefore time.Time
+		err       string
+	}{
+		{time.Now().Add(-time.Minute), "not-before must be in the future: .*"},
+		{time.Now().Add(8 * 24 * time.Hour), "not-before cannot be more than 7 days from now: .*"},
+	} {
+		for _, path := range []string{"/v2/snaps/some-snap", "/v2/snaps"} {
+			body := fmt.Sprintf(`{"action": "refresh", "not-before": %q}`, tc.notBefore.Format(time.RFC3339))
+			if path == "/v2/snaps" {
+				body = fmt.Sprintf(`{"action": "refresh", "snaps": ["some-snap"], "not-before": %q}`, tc.notBefore.Format(time.RFC3339))
+			}
+			req, err := http.NewRequest("POST", path, strings.NewReader(body))
+			c.Assert(err, check.IsNil)
+			req.Header.Set("Content-Type", "application/json")
+			rspe := s.errorReq(c, req, nil)
+			c.Check(rspe.Status, check.Equals, 400)
+			c.Check(rspe.Message, check.Matches, tc.err)
+		}
+	}
+}
+
 func (s *snapsSuite) TestPostSnapLeaveCohortUnsupportedAction(c *check.C) {
 	s.daemonWithOverlordMock()
 	const expectedErr = "leave-cohort can only be specified for refresh or switch"
@@ -1854,6 +2243,31 @@ func (s *snapsSuite) TestInstallCohort(c *check.C) {
 	c.Check(msg, check.Equals, `Install "fake" snap from "…e damned." cohort`)
 }
 
+func (s *snapsSuite) TestInstallArchitectures(c *check.C) {
+	var calledArchs []string
+
+	defer daemon.MockSnapstateInstall(func(ctx context.Context, s *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		calledArchs = opts.Architectures
+
+		t := s.NewTask("fake-install-snap", "Doing a fake install")
+		return state.NewTaskSet(t), nil
+	})()
+
+	d := s.daemon(c)
+	inst := &daemon.SnapInstruction{
+		Action: "install",
+		Snaps:  []string{"fake"},
+	}
+	inst.Architectures = []string{"armhf", "arm64"}
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	_, _, err := inst.Dispatch()(inst, st)
+	c.Check(err, check.IsNil)
+	c.Check(calledArchs, check.DeepEquals, []string{"armhf", "arm64"})
+}
+
 func (s *snapsSuite) TestInstallIgnoreValidation(c *check.C) {
 	var calledFlags snapstate.Flags
 	installQueue := []string{}
@@ -51,14 +51,24 @@ var snapshotExportCmd = &Command{
 	ReadAccess: authenticatedAccess{},
 }
 
+var snapshotTargetCmd = &Command{
+	Path:        "/v2/snapshot-target",
+	GET:         listSnapshotTarget,
+	POST:        changeSnapshotTarget,
+	ReadAccess:  authenticatedAccess{},
+	WriteAccess: authenticatedAccess{Polkit: polkitActionManage},
+}
+
 var (
-	snapshotList    = snapshotstate.List
-	snapshotCheck   = snapshotstate.Check
-	snapshotForget  = snapshotstate.Forget
-	snapshotRestore = snapshotstate.Restore
-	snapshotSave    = snapshotstate.Save
-	snapshotExport  = snapshotstate.Export
-	snapshotImport  = snapshotstate.Import
+	snapshotList             = snapshotstate.List
+	snapshotCheck            = snapshotstate.Check
+	snapshotForget           = snapshotstate.Forget
+	snapshotRestore          = snapshotstate.Restore
+	snapshotSave             = snapshotstate.Save
+	snapshotExport           = snapshotstate.Export
+	snapshotImport           = snapshotstate.Import
+	snapshotTargetSnapshots  = snapshotstate.TargetSnapshots
+	snapshotImportFromTarget = snapshotstate.ImportFromTarget
 )
 
 func listSnapshots(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -217,8 +227,62 @@ func doSnapshotImport(c *Command, r *http.Request, user *auth.UserState) Respons
 	return SyncResponse(result)
 }
 
+// listSnapshotTarget lists the exported snapshot sets on the snapshot
+// target.
+func listSnapshotTarget(c *Command, r *http.Request, user *auth.UserState) Response {
+	snapshots, err := snapshotTargetSnapshots(r.Context(), c.d.overlord.State())
+	if err != nil {
+		if err == snapshotstate.ErrNoSnapshotTarget {
+			return BadRequest("%v", err)
+		}
+		return InternalError("cannot list snapshot target: %v", err)
+	}
+	return SyncResponse(snapshots)
+}
+
+// A snapshotTargetAction is used to request an operation on the snapshot
+// target; keep this in sync with client/snapshotTargetAction.
+type snapshotTargetAction struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+}
+
+// changeSnapshotTarget imports exported snapshot sets from the snapshot
+// target.
+func changeSnapshotTarget(c *Command, r *http.Request, user *auth.UserState) Response {
+	var action snapshotTargetAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&action); err != nil {
+		return BadRequest("cannot decode request body into snapshot target operation: %v", err)
+	}
+	if decoder.More() {
+		return BadRequest("extra content found after snapshot target operation")
+	}
+
+	switch action.Action {
+	case "import":
+		if action.Name == "" {
+			return BadRequest("snapshot target import requires the name of an exported snapshot")
+		}
+	case "":
+		return BadRequest("snapshot target operation requires action")
+	default:
+		return BadRequest("unknown snapshot target operation %q", action.Action)
+	}
+
+	// XXX: check that we have enough space to import the compressed snapshots
+	setID, snapNames, err := snapshotImportFromTarget(r.Context(), c.d.overlord.State(), action.Name)
+	if err != nil {
+		return BadRequest("cannot import %q from snapshot target: %v", action.Name, err)
+	}
+
+	result := map[string]interface{}{"set-id": setID, "snaps": snapNames}
+	return SyncResponse(result)
+}
+
 func snapshotMany(inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
-	setID, snapshotted, ts, err := snapshotSave(st, inst.Snaps, inst.Users, inst.SnapshotOptions)
+	flags := &snapshotstate.SaveFlags{Encrypt: inst.Encrypt}
+	setID, snapshotted, ts, err := snapshotSave(st, inst.Snaps, inst.Users, inst.SnapshotOptions, flags)
 	if err != nil {
 		return nil, err
 	}
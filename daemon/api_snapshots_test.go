@@ -29,6 +29,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -54,7 +55,7 @@ func (s *snapshotSuite) SetUpTest(c *check.C) {
 
 func (s *snapshotSuite) TestSnapshotManyOptionsNone(c *check.C) {
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, flags *snapshotstate.SaveFlags) (uint64, []string, *state.TaskSet, error) {
 		c.Check(snaps, check.HasLen, 2)
 		c.Check(options, check.IsNil)
 		t := s.NewTask("fake-snapshot-2", "Snapshot two")
@@ -75,7 +76,7 @@ func (s *snapshotSuite) TestSnapshotManyOptionsNone(c *check.C) {
 func (s *snapshotSuite) TestSnapshotManyOptionsFull(c *check.C) {
 	var snapshotSaveCalled int
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, flags *snapshotstate.SaveFlags) (uint64, []string, *state.TaskSet, error) {
 		snapshotSaveCalled++
 		c.Check(snaps, check.HasLen, 2)
 		c.Check(options, check.HasLen, 2)
@@ -100,9 +101,29 @@ func (s *snapshotSuite) TestSnapshotManyOptionsFull(c *check.C) {
 	c.Check(snapshotSaveCalled, check.Equals, 1)
 }
 
+func (s *snapshotSuite) TestSnapshotManyEncrypt(c *check.C) {
+	var snapshotSaveCalled int
+	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
+		options map[string]*snap.SnapshotOptions, flags *snapshotstate.SaveFlags) (uint64, []string, *state.TaskSet, error) {
+		snapshotSaveCalled++
+		c.Check(flags, check.DeepEquals, &snapshotstate.SaveFlags{Encrypt: true})
+		t := s.NewTask("fake-snapshot-2", "Snapshot two")
+		return 1, snaps, state.NewTaskSet(t), nil
+	})()
+
+	inst := daemon.MustUnmarshalSnapInstruction(c, `{"action": "snapshot", "snaps": ["foo", "bar"], "encrypt": true}`)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	_, err := inst.DispatchForMany()(inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(snapshotSaveCalled, check.Equals, 1)
+}
+
 func (s *snapshotSuite) TestSnapshotManyError(c *check.C) {
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, flags *snapshotstate.SaveFlags) (uint64, []string, *state.TaskSet, error) {
 		c.Check(snaps, check.HasLen, 2)
 		return 0, nil, nil, &snap.NotInstalledError{Snap: "foo"}
 	})()
@@ -466,3 +487,84 @@ func (s *snapshotSuite) TestImportSnapshotLimits(c *check.C) {
 	c.Check(rsp.Status, check.Equals, 200)
 	c.Check(dataRead, check.Equals, 10)
 }
+
+func (s *snapshotSuite) TestListSnapshotTarget(c *check.C) {
+	snapshots := []client.RemoteSnapshot{
+		{Name: "snapshot-1-20230102T030405Z.tar", SetID: 1, Size: 42, Time: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+	defer daemon.MockSnapshotTargetSnapshots(func(context.Context, *state.State) ([]client.RemoteSnapshot, error) {
+		return snapshots, nil
+	})()
+
+	req, err := http.NewRequest("GET", "/v2/snapshot-target", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, snapshots)
+}
+
+func (s *snapshotSuite) TestListSnapshotTargetErrors(c *check.C) {
+	var listErr error
+	defer daemon.MockSnapshotTargetSnapshots(func(context.Context, *state.State) ([]client.RemoteSnapshot, error) {
+		return nil, listErr
+	})()
+
+	for _, tc := range []struct {
+		err     error
+		status  int
+		message string
+	}{
+		{snapshotstate.ErrNoSnapshotTarget, 400, "snapshots.target is not set"},
+		{errors.New("no"), 500, "cannot list snapshot target: no"},
+	} {
+		listErr = tc.err
+		req, err := http.NewRequest("GET", "/v2/snapshot-target", nil)
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, tc.status)
+		c.Check(rspe.Message, check.Equals, tc.message)
+	}
+}
+
+func (s *snapshotSuite) TestImportSnapshotFromTarget(c *check.C) {
+	var name string
+	defer daemon.MockSnapshotImportFromTarget(func(_ context.Context, _ *state.State, n string) (uint64, []string, error) {
+		name = n
+		return 3, []string{"bar", "foo"}, nil
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/snapshot-target", strings.NewReader(`{"action": "import", "name": "snapshot-1-20230102T030405Z.tar"}`))
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{"set-id": uint64(3), "snaps": []string{"bar", "foo"}})
+	c.Check(name, check.Equals, "snapshot-1-20230102T030405Z.tar")
+}
+
+func (s *snapshotSuite) TestImportSnapshotFromTargetErrors(c *check.C) {
+	defer daemon.MockSnapshotImportFromTarget(func(context.Context, *state.State, string) (uint64, []string, error) {
+		return 0, nil, errors.New("no")
+	})()
+
+	for _, tc := range []struct {
+		body    string
+		message string
+	}{
+		{`{"action": "import", "name": "foo.tar"}`, `cannot import "foo.tar" from snapshot target: no`},
+		{`{"action": "import"}`, "snapshot target import requires the name of an exported snapshot"},
+		{`{"name": "foo.tar"}`, "snapshot target operation requires action"},
+		{`{"action": "upload", "name": "foo.tar"}`, `unknown snapshot target operation "upload"`},
+		{`{"action": "import", "name": "foo.tar"}{}`, "extra content found after snapshot target operation"},
+		{`[]`, `cannot decode request body into snapshot target operation: .*`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/snapshot-target", strings.NewReader(tc.body))
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf(tc.body))
+		c.Check(rspe.Message, check.Matches, tc.message, check.Commentf(tc.body))
+	}
+}
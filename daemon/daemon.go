@@ -43,6 +43,7 @@ import (
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/changehistory"
 	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/standby"
 	"github.com/snapcore/snapd/overlord/state"
@@ -164,6 +165,9 @@ func (c *Command) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		rjson := srsp.JSON()
 
 		st.Lock()
+		if rjson.Type == ResponseTypeAsync && ucred != nil {
+			recordChangeRequester(st, rjson.Change, ucred, user)
+		}
 		_, rst := restart.Pending(st)
 		st.Unlock()
 		rjson.addMaintenanceFromRestartType(rst)
@@ -182,6 +186,21 @@ func (c *Command) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	rsp.ServeHTTP(w, r)
 }
 
+// recordChangeRequester records on the change who requested it, so that it
+// is kept in the change history as well.
+func recordChangeRequester(st *state.State, changeID string, ucred *ucrednet, user *auth.UserState) {
+	chg := st.Change(changeID)
+	if chg == nil {
+		return
+	}
+	requester := &changehistory.Requester{UID: ucred.Uid}
+	if user != nil {
+		requester.UserID = user.ID
+		requester.Username = user.Username
+	}
+	chg.Set("requested-by", requester)
+}
+
 type wrappedWriter struct {
 	w http.ResponseWriter
 	s int
@@ -42,6 +42,7 @@ import (
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/changehistory"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/patch"
@@ -221,6 +222,57 @@ func (s *daemonSuite) TestCommandMethodDispatchRoot(c *check.C) {
 	c.Check(rec.Code, check.Equals, 405)
 }
 
+func (s *daemonSuite) TestCommandRecordsChangeRequester(c *check.C) {
+	d := s.newTestDaemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	authUser, err := auth.NewUser(st, auth.NewUserParams{
+		Username:   "username",
+		Email:      "email@test.com",
+		Macaroon:   "macaroon",
+		Discharges: []string{"discharge"},
+	})
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	cmd := &Command{d: d}
+	cmd.POST = func(*Command, *http.Request, *auth.UserState) Response {
+		st.Lock()
+		defer st.Unlock()
+		chg := st.NewChange("foo", "...")
+		return AsyncResponse(nil, chg.ID())
+	}
+	cmd.WriteAccess = openAccess{}
+
+	for _, tc := range []struct {
+		macaroon  string
+		requester *changehistory.Requester
+	}{
+		{"", &changehistory.Requester{UID: 1001}},
+		{authUser.Macaroon, &changehistory.Requester{UID: 1001, UserID: authUser.ID, Username: "username"}},
+	} {
+		req, err := http.NewRequest("POST", "", nil)
+		c.Assert(err, check.IsNil)
+		req.RemoteAddr = fmt.Sprintf("pid=100;uid=1001;socket=%s;", dirs.SnapdSocket)
+		if tc.macaroon != "" {
+			req.Header.Set("Authorization", fmt.Sprintf(`Macaroon root="%s"`, tc.macaroon))
+		}
+		rec := httptest.NewRecorder()
+		cmd.ServeHTTP(rec, req)
+		c.Assert(rec.Code, check.Equals, 202)
+
+		var rsp respJSON
+		c.Assert(json.Unmarshal(rec.Body.Bytes(), &rsp), check.IsNil)
+		st.Lock()
+		chg := st.Change(rsp.Change)
+		c.Assert(chg, check.NotNil)
+		var requester *changehistory.Requester
+		c.Check(chg.Get("requested-by", &requester), check.IsNil)
+		st.Unlock()
+		c.Check(requester, check.DeepEquals, tc.requester)
+	}
+}
+
 func (s *daemonSuite) TestCommandRestartingState(c *check.C) {
 	d := s.newTestDaemon(c)
 
@@ -33,6 +33,14 @@ func MockDevicestateRemodel(mock func(*state.State, *asserts.Model) (*state.Chan
 	}
 }
 
+func MockDevicestateRemodelTaskSets(mock func(*state.State, *asserts.Model) ([]*state.TaskSet, error)) (restore func()) {
+	oldDevicestateRemodelTaskSets := devicestateRemodelTaskSets
+	devicestateRemodelTaskSets = mock
+	return func() {
+		devicestateRemodelTaskSets = oldDevicestateRemodelTaskSets
+	}
+}
+
 func MockDevicestateDeviceManagerUnregister(mock func(*devicestate.DeviceManager, *devicestate.UnregisterOptions) error) (restore func()) {
 	oldDevicestateDeviceManagerUnregister := devicestateDeviceManagerUnregister
 	devicestateDeviceManagerUnregister = mock
@@ -61,3 +61,19 @@ func MockGetQuotaUsage(f func(grp *quota.Group) (*client.QuotaValues, error)) (r
 		getQuotaUsage = old
 	}
 }
+
+func MockServicestateQuotaGroupUsage(f func(m *servicestate.ServiceManager, name string) (*client.QuotaGroupUsage, error)) (restore func()) {
+	old := servicestateQuotaGroupUsage
+	servicestateQuotaGroupUsage = f
+	return func() {
+		servicestateQuotaGroupUsage = old
+	}
+}
+
+func MockServicestateSnapsUsage(f func(m *servicestate.ServiceManager) ([]client.SnapUsage, error)) (restore func()) {
+	old := servicestateSnapsUsage
+	servicestateSnapsUsage = f
+	return func() {
+		servicestateSnapsUsage = old
+	}
+}
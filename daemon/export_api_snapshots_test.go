@@ -32,7 +32,7 @@ import (
 	"github.com/snapcore/snapd/snap"
 )
 
-func MockSnapshotSave(newSave func(*state.State, []string, []string, map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error)) (restore func()) {
+func MockSnapshotSave(newSave func(*state.State, []string, []string, map[string]*snap.SnapshotOptions, *snapshotstate.SaveFlags) (uint64, []string, *state.TaskSet, error)) (restore func()) {
 	oldSave := snapshotSave
 	snapshotSave = newSave
 	return func() {
@@ -88,6 +88,22 @@ func MockSnapshotImport(newImport func(context.Context, *state.State, io.Reader)
 	}
 }
 
+func MockSnapshotTargetSnapshots(newTargetSnapshots func(context.Context, *state.State) ([]client.RemoteSnapshot, error)) (restore func()) {
+	oldTargetSnapshots := snapshotTargetSnapshots
+	snapshotTargetSnapshots = newTargetSnapshots
+	return func() {
+		snapshotTargetSnapshots = oldTargetSnapshots
+	}
+}
+
+func MockSnapshotImportFromTarget(newImport func(context.Context, *state.State, string) (uint64, []string, error)) (restore func()) {
+	oldImport := snapshotImportFromTarget
+	snapshotImportFromTarget = newImport
+	return func() {
+		snapshotImportFromTarget = oldImport
+	}
+}
+
 func MustUnmarshalSnapInstruction(c *check.C, jinst string) *snapInstruction {
 	var inst snapInstruction
 	if err := json.Unmarshal([]byte(jinst), &inst); err != nil {
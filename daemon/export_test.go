@@ -206,6 +206,18 @@ func MockSnapstateRemoveMany(mock func(*state.State, []string, *snapstate.Remove
 	}
 }
 
+func MockSnapstatePlanTaskSets(mock func(*state.State, []*state.TaskSet) (*snapstate.Plan, error)) (restore func()) {
+	restore = testutil.Backup(&snapstatePlanTaskSets)
+	snapstatePlanTaskSets = mock
+	return restore
+}
+
+func MockIfacestateAutoConnectCandidates(mock func(*state.State, *snap.Info) ([]string, error)) (restore func()) {
+	restore = testutil.Backup(&ifacestateAutoConnectCandidates)
+	ifacestateAutoConnectCandidates = mock
+	return restore
+}
+
 func MockSnapstateInstallPathMany(f func(context.Context, *state.State, []*snap.SideInfo, []string, int, *snapstate.Flags) ([]*state.TaskSet, error)) func() {
 	old := snapstateInstallPathMany
 	snapstateInstallPathMany = f
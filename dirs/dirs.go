@@ -72,12 +72,15 @@ var (
 	SnapAssertsDBDir      string
 	SnapCookieDir         string
 	SnapTrustedAccountKey string
+	SnapTrustedRootsDir   string
 	SnapAssertsSpoolDir   string
 	SnapSeqDir            string
 
-	SnapStateFile     string
-	SnapStateLockFile string
-	SnapSystemKeyFile string
+	SnapStateFile         string
+	SnapStateJournalFile  string
+	SnapStateLockFile     string
+	SnapChangeHistoryFile string
+	SnapSystemKeyFile     string
 
 	SnapRepairDir        string
 	SnapRepairStateFile  string
@@ -268,6 +271,12 @@ func SnapStateFileUnder(rootdir string) string {
 	return filepath.Join(rootdir, snappyDir, "state.json")
 }
 
+// SnapStateJournalFileUnder returns the path to snapd state journal file
+// under rootdir.
+func SnapStateJournalFileUnder(rootdir string) string {
+	return filepath.Join(rootdir, snappyDir, "state.journal")
+}
+
 // SnapStateLockFileUnder returns the path to snapd state lock file under rootdir.
 func SnapStateLockFileUnder(rootdir string) string {
 	return filepath.Join(rootdir, snappyDir, "state.lock")
@@ -392,11 +401,14 @@ func SetRootDir(rootdir string) {
 	SnapSocket = filepath.Join(rootdir, "/run/snapd-snap.socket")
 
 	SnapAssertsDBDir = filepath.Join(rootdir, snappyDir, "assertions")
+	SnapTrustedRootsDir = filepath.Join(rootdir, snappyDir, "trusted-roots")
 	SnapCookieDir = filepath.Join(rootdir, snappyDir, "cookie")
 	SnapAssertsSpoolDir = filepath.Join(rootdir, "run/snapd/auto-import")
 	SnapSeqDir = filepath.Join(rootdir, snappyDir, "sequence")
 
 	SnapStateFile = SnapStateFileUnder(rootdir)
+	SnapStateJournalFile = SnapStateJournalFileUnder(rootdir)
+	SnapChangeHistoryFile = filepath.Join(rootdir, snappyDir, "change-history")
 	SnapStateLockFile = SnapStateLockFileUnder(rootdir)
 	SnapSystemKeyFile = filepath.Join(rootdir, snappyDir, "system-key")
 
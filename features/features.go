@@ -68,6 +68,8 @@ const (
 	//  * journal quotas are still experimental
 	// while guota groups creation and management and memory, cpu, quotas are no longer experimental.
 	QuotaGroups
+	// StateJournal controls persisting the snapd state to a journal of its changes instead of rewriting it as a whole.
+	StateJournal
 
 	// lastFeature is the final known feature, it is only used for testing.
 	lastFeature
@@ -108,6 +110,8 @@ var featureNames = map[SnapdFeature]string{
 	GateAutoRefreshHook: "gate-auto-refresh-hook",
 
 	QuotaGroups: "quota-groups",
+
+	StateJournal: "state-journal",
 }
 
 // featuresEnabledWhenUnset contains a set of features that are enabled when not explicitly configured.
@@ -129,6 +133,9 @@ var featuresExported = map[SnapdFeature]bool{
 	RobustMountNamespaceUpdates:   true,
 	HiddenSnapDataHomeDir:         true,
 	MoveSnapHomeDir:               true,
+
+	// the state journal is enabled before the state is read
+	StateJournal: true,
 }
 
 // String returns the name of a snapd feature.
@@ -32,35 +32,16 @@ func Set(st *state.State, account, bundleName, aspect, field string, value inter
 	st.Lock()
 	defer st.Unlock()
 
-	databag, err := getDatabag(st, account, bundleName)
+	tx, err := NewTransaction(st, account, bundleName)
 	if err != nil {
-		if !errors.Is(err, state.ErrNoState) {
-			return err
-		}
-
-		databag = aspects.NewJSONDataBag()
-	}
-
-	accPatterns := aspecttest.MockWifiSetupAspect()
-	aspectBundle, err := aspects.NewAspectBundle(bundleName, accPatterns, aspects.NewJSONSchema())
-	if err != nil {
-		return err
-	}
-
-	asp := aspectBundle.Aspect(aspect)
-	if asp == nil {
-		return &aspects.AspectNotFoundError{Account: account, BundleName: bundleName, Aspect: aspect}
-	}
-
-	if err := asp.Set(databag, field, value); err != nil {
 		return err
 	}
 
-	if err := updateDatabags(st, account, bundleName, databag); err != nil {
+	if err := tx.Set(aspect, field, value); err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
 
 // Get finds the aspect identified by the account, bundleName and aspect and
@@ -78,8 +59,7 @@ func Get(st *state.State, account, bundleName, aspect, field string, value inter
 		return err
 	}
 
-	accPatterns := aspecttest.MockWifiSetupAspect()
-	aspectBundle, err := aspects.NewAspectBundle(bundleName, accPatterns, aspects.NewJSONSchema())
+	aspectBundle, err := getAspectBundle(account, bundleName)
 	if err != nil {
 		return err
 	}
@@ -96,6 +76,13 @@ func Get(st *state.State, account, bundleName, aspect, field string, value inter
 	return nil
 }
 
+// getAspectBundle returns the aspect bundle parsed from its definition,
+// including the schema its databag must conform to.
+func getAspectBundle(account, bundleName string) (*aspects.Bundle, error) {
+	// TODO: get the definition from aspect-bundle assertions once they exist
+	return aspects.ParseAspectBundle(bundleName, aspecttest.MockWifiSetupBundle())
+}
+
 func updateDatabags(st *state.State, account, bundleName string, databag aspects.JSONDataBag) error {
 	var databags map[string]map[string]aspects.JSONDataBag
 	if err := st.Get("aspect-databags", &databags); err != nil {
@@ -103,9 +90,11 @@ func updateDatabags(st *state.State, account, bundleName string, databag aspects
 			return err
 		}
 
-		databags = map[string]map[string]aspects.JSONDataBag{
-			account: {bundleName: aspects.NewJSONDataBag()},
-		}
+		databags = make(map[string]map[string]aspects.JSONDataBag)
+	}
+
+	if databags[account] == nil {
+		databags[account] = make(map[string]aspects.JSONDataBag)
 	}
 
 	databags[account][bundleName] = databag
@@ -135,3 +135,21 @@ func (s *aspectTestSuite) TestUnsetAspect(c *C) {
 	c.Assert(err, FitsTypeOf, &aspects.FieldNotFoundError{})
 	c.Assert(val, Equals, "")
 }
+
+func (s *aspectTestSuite) TestSetAspectSchemaError(c *C) {
+	err := aspectstate.Set(s.state, "system", "network", "wifi-setup", "ssid", "foo")
+	c.Assert(err, IsNil)
+
+	err = aspectstate.Set(s.state, "system", "network", "wifi-setup", "ssid", 123)
+	c.Assert(err, FitsTypeOf, &aspects.ValidationError{})
+	c.Assert(err, ErrorMatches, `cannot accept element in "wifi.ssid": expected string but got integer`)
+
+	err = aspectstate.Set(s.state, "system", "network", "wifi-setup", "ssids", []interface{}{"foo", ""})
+	c.Assert(err, ErrorMatches, `cannot accept element in "wifi.ssids\[1\]": string is shorter than the minimum length 1`)
+
+	// the stored value wasn't changed
+	var val string
+	err = aspectstate.Get(s.state, "system", "network", "wifi-setup", "ssid", &val)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "foo")
+}
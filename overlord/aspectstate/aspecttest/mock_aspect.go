@@ -18,17 +18,36 @@
 
 package aspecttest
 
-// MockWifiSetupAspect returns some mocked aspect access patterns for the
-// system/network/wifi-setup. This will eventually be replaced by proper
-// aspect assertions.
-func MockWifiSetupAspect() map[string]interface{} {
-	return map[string]interface{}{
-		"wifi-setup": []map[string]string{
+// MockWifiSetupBundle returns a mocked definition of the system/network aspect
+// bundle, with the wifi-setup aspect access patterns and the schema of the
+// databag. This will eventually be replaced by proper aspect assertions.
+func MockWifiSetupBundle() []byte {
+	return []byte(`{
+	"aspects": {
+		"wifi-setup": [
 			{"name": "ssids", "path": "wifi.ssids"},
 			{"name": "ssid", "path": "wifi.ssid", "access": "read-write"},
 			{"name": "password", "path": "wifi.psk", "access": "write"},
 			{"name": "status", "path": "wifi.status", "access": "read"},
-			{"name": "private.{placeholder}", "path": "wifi.{placeholder}"},
-		},
+			{"name": "private.{placeholder}", "path": "wifi.{placeholder}"}
+		]
+	},
+	"schema": {
+		"type": "object",
+		"properties": {
+			"wifi": {
+				"type": "object",
+				"properties": {
+					"ssids": {
+						"type": "array",
+						"items": {"type": "string", "minLength": 1, "maxLength": 32}
+					},
+					"ssid": {"type": "string", "minLength": 1, "maxLength": 32},
+					"psk": {"type": "string", "maxLength": 63},
+					"status": {"type": "string", "enum": ["online", "offline", "connecting"]}
+				}
+			}
+		}
 	}
+}`)
 }
@@ -0,0 +1,127 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package aspectstate
+
+import (
+	"errors"
+
+	"github.com/snapcore/snapd/aspects"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// Transaction groups several writes to the aspects of a bundle. The databag is
+// loaded once and all changes are validated against the bundle's schema and
+// persisted into the state at once when Commit is called. If any change is
+// rejected, none are persisted.
+type Transaction struct {
+	state      *state.State
+	account    string
+	bundleName string
+	bundle     *aspects.Bundle
+	tx         *aspects.Transaction
+}
+
+// NewTransaction creates a new transaction for the aspect bundle identified by
+// the account and bundleName.
+//
+// The provided state must be locked by the caller.
+func NewTransaction(st *state.State, account, bundleName string) (*Transaction, error) {
+	bundle, err := getAspectBundle(account, bundleName)
+	if err != nil {
+		return nil, err
+	}
+
+	databag, err := getDatabag(st, account, bundleName)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+
+	return &Transaction{
+		state:      st,
+		account:    account,
+		bundleName: bundleName,
+		bundle:     bundle,
+		tx:         bundle.NewTransaction(databag),
+	}, nil
+}
+
+// Set stages setting the field of the aspect to the value. A nil value unsets
+// the field.
+func (t *Transaction) Set(aspect, field string, value interface{}) error {
+	asp, err := t.aspect(aspect)
+	if err != nil {
+		return err
+	}
+
+	return t.tx.Set(asp, field, value)
+}
+
+// Unset stages unsetting the field of the aspect.
+func (t *Transaction) Unset(aspect, field string) error {
+	return t.Set(aspect, field, nil)
+}
+
+// Get reads the field of the aspect, including changes staged in the
+// transaction.
+func (t *Transaction) Get(aspect, field string, value interface{}) error {
+	asp, err := t.aspect(aspect)
+	if err != nil {
+		return err
+	}
+
+	return t.tx.Get(asp, field, value)
+}
+
+// Commit applies the staged changes on top of the current databag, validates
+// the result against the bundle's schema and, if valid, persists it into the
+// state, recording an aspect notice with the changed paths. Changes made to
+// the databag since the transaction was created are preserved unless
+// overwritten by the transaction's own changes.
+//
+// The state must be locked by the caller.
+func (t *Transaction) Commit() error {
+	databag, err := getDatabag(t.state, t.account, t.bundleName)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+
+	databag, err = t.tx.Apply(databag)
+	if err != nil {
+		return err
+	}
+
+	if err := updateDatabags(t.state, t.account, t.bundleName, databag); err != nil {
+		return err
+	}
+
+	if changed := t.tx.Changes(); len(changed) > 0 {
+		t.state.AddNotice(state.AspectNotice, t.account+"/"+t.bundleName, &state.AddNoticeOptions{
+			ChangedKeys: changed,
+		})
+	}
+	return nil
+}
+
+func (t *Transaction) aspect(name string) (*aspects.Aspect, error) {
+	asp := t.bundle.Aspect(name)
+	if asp == nil {
+		return nil, &aspects.AspectNotFoundError{Account: t.account, BundleName: t.bundleName, Aspect: name}
+	}
+	return asp, nil
+}
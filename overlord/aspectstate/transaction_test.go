@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package aspectstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/aspects"
+	"github.com/snapcore/snapd/overlord/aspectstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func (s *aspectTestSuite) getDatabag(c *C, account, bundleName string) aspects.JSONDataBag {
+	var databags map[string]map[string]aspects.JSONDataBag
+	err := s.state.Get("aspect-databags", &databags)
+	c.Assert(err, IsNil)
+	return databags[account][bundleName]
+}
+
+func (s *aspectTestSuite) TestTransactionCommit(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tx, err := aspectstate.NewTransaction(s.state, "system", "network")
+	c.Assert(err, IsNil)
+
+	c.Assert(tx.Set("wifi-setup", "ssid", "foo"), IsNil)
+	c.Assert(tx.Set("wifi-setup", "password", "secret"), IsNil)
+	c.Assert(tx.Set("wifi-setup", "ssids", []string{"foo", "bar"}), IsNil)
+
+	var ssid string
+	c.Assert(tx.Get("wifi-setup", "ssid", &ssid), IsNil)
+	c.Check(ssid, Equals, "foo")
+
+	// nothing is written before committing
+	err = s.state.Get("aspect-databags", nil)
+	c.Assert(err, ErrorMatches, "no state entry for key.*")
+
+	c.Assert(tx.Commit(), IsNil)
+
+	databag := s.getDatabag(c, "system", "network")
+	var psk string
+	c.Assert(databag.Get("wifi.psk", &psk), IsNil)
+	c.Check(psk, Equals, "secret")
+	var ssids []string
+	c.Assert(databag.Get("wifi.ssids", &ssids), IsNil)
+	c.Check(ssids, DeepEquals, []string{"foo", "bar"})
+
+	notices, err := s.state.Notices(nil)
+	c.Assert(err, IsNil)
+	c.Assert(notices, HasLen, 1)
+	c.Check(notices[0].Type(), Equals, state.AspectNotice)
+	c.Check(notices[0].Key(), Equals, "system/network")
+	c.Check(notices[0].ChangedKeys(), DeepEquals, []string{"wifi.psk", "wifi.ssid", "wifi.ssids"})
+}
+
+func (s *aspectTestSuite) TestTransactionCommitFailsAsAWhole(c *C) {
+	err := aspectstate.Set(s.state, "system", "network", "wifi-setup", "ssid", "foo")
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tx, err := aspectstate.NewTransaction(s.state, "system", "network")
+	c.Assert(err, IsNil)
+
+	c.Assert(tx.Set("wifi-setup", "password", "secret"), IsNil)
+	c.Assert(tx.Unset("wifi-setup", "ssid"), IsNil)
+	c.Assert(tx.Set("wifi-setup", "ssids", []interface{}{"foo", 1}), IsNil)
+
+	err = tx.Commit()
+	c.Assert(err, ErrorMatches, `cannot accept element in "wifi.ssids\[1\]": expected string but got integer`)
+
+	// only the earlier successful write was notified
+	notices, err := s.state.Notices(nil)
+	c.Assert(err, IsNil)
+	c.Check(notices, HasLen, 1)
+
+	// none of the changes were persisted
+	databag := s.getDatabag(c, "system", "network")
+	data, err := databag.Data()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"wifi":{"ssid":"foo"}}`)
+}
+
+func (s *aspectTestSuite) TestTransactionCommitKeepsConcurrentChanges(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tx, err := aspectstate.NewTransaction(s.state, "system", "network")
+	c.Assert(err, IsNil)
+	c.Assert(tx.Set("wifi-setup", "password", "secret"), IsNil)
+
+	other, err := aspectstate.NewTransaction(s.state, "system", "network")
+	c.Assert(err, IsNil)
+	c.Assert(other.Set("wifi-setup", "ssid", "foo"), IsNil)
+	c.Assert(other.Commit(), IsNil)
+
+	c.Assert(tx.Commit(), IsNil)
+
+	databag := s.getDatabag(c, "system", "network")
+	data, err := databag.Data()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"wifi":{"psk":"secret","ssid":"foo"}}`)
+}
+
+func (s *aspectTestSuite) TestTransactionErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tx, err := aspectstate.NewTransaction(s.state, "system", "network")
+	c.Assert(err, IsNil)
+
+	err = tx.Set("other-aspect", "ssid", "foo")
+	c.Assert(err, FitsTypeOf, &aspects.AspectNotFoundError{})
+	c.Assert(err, ErrorMatches, `aspect system/network/other-aspect not found`)
+
+	err = tx.Set("wifi-setup", "status", "foo")
+	c.Assert(err, FitsTypeOf, &aspects.InvalidAccessError{})
+
+	var ssid string
+	err = tx.Get("other-aspect", "ssid", &ssid)
+	c.Assert(err, FitsTypeOf, &aspects.AspectNotFoundError{})
+}
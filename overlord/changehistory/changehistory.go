@@ -0,0 +1,324 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package changehistory archives the changes pruned from the state, with
+// the logs of their tasks, so that they can be queried later.
+package changehistory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// maxHistorySize is the size of the history above which the oldest
+// changes are dropped until it is half of it.
+var maxHistorySize int64 = 16 * 1024 * 1024
+
+// the history file is only written by snapd, the lock serializes the
+// archiving with the queries
+var historyLock sync.Mutex
+
+// the changes are archived with the state locked, pendingLock protects the
+// records waiting to be written to the history outside of it
+var (
+	pendingLock sync.Mutex
+	pending     [][]byte
+)
+
+// Change is an archived change.
+type Change struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	Summary     string     `json:"summary"`
+	Status      string     `json:"status"`
+	Err         string     `json:"err,omitempty"`
+	SnapNames   []string   `json:"snap-names,omitempty"`
+	RequestedBy *Requester `json:"requested-by,omitempty"`
+	Tasks       []*Task    `json:"tasks,omitempty"`
+	SpawnTime   time.Time  `json:"spawn-time"`
+	ReadyTime   time.Time  `json:"ready-time"`
+}
+
+// Requester is who asked for a change through the API. The daemon keeps it
+// under "requested-by" in the data of the changes it creates.
+type Requester struct {
+	// UID is the uid of the process that made the request.
+	UID uint32 `json:"uid"`
+	// UserID and Username identify the snapd user the request was
+	// authenticated as, if any.
+	UserID   int    `json:"user-id,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// Task is a task of an archived change.
+type Task struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Summary   string    `json:"summary"`
+	Status    string    `json:"status"`
+	Log       []string  `json:"log,omitempty"`
+	SpawnTime time.Time `json:"spawn-time"`
+	ReadyTime time.Time `json:"ready-time"`
+}
+
+// Init makes the changes pruned from the state be archived.
+func Init(st *state.State) {
+	st.RegisterPrunedChangeHandler(func(chg *state.Change) {
+		if err := Archive(chg); err != nil {
+			logger.Noticef("cannot archive change %s: %v", chg.ID(), err)
+		}
+	})
+}
+
+func newChange(chg *state.Change) *Change {
+	archived := &Change{
+		ID:        chg.ID(),
+		Kind:      chg.Kind(),
+		Summary:   chg.Summary(),
+		Status:    chg.Status().String(),
+		SpawnTime: chg.SpawnTime(),
+		ReadyTime: chg.ReadyTime(),
+	}
+	if err := chg.Err(); err != nil {
+		archived.Err = err.Error()
+	}
+	// not all changes are about snaps
+	chg.Get("snap-names", &archived.SnapNames)
+	// nor were all requested through the API
+	chg.Get("requested-by", &archived.RequestedBy)
+	for _, t := range chg.Tasks() {
+		archived.Tasks = append(archived.Tasks, &Task{
+			ID:        t.ID(),
+			Kind:      t.Kind(),
+			Summary:   t.Summary(),
+			Status:    t.Status().String(),
+			Log:       t.Log(),
+			SpawnTime: t.SpawnTime(),
+			ReadyTime: t.ReadyTime(),
+		})
+	}
+	return archived
+}
+
+// Archive records the change for the history, it is written to it by the
+// next Flush or Query. The state must be locked.
+func Archive(chg *state.Change) error {
+	line, err := json.Marshal(newChange(chg))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	pendingLock.Lock()
+	defer pendingLock.Unlock()
+	pending = append(pending, line)
+	return nil
+}
+
+// Flush appends the archived changes to the history. It does not need the
+// state to be locked and should be called without it as it does I/O.
+func Flush() error {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+	return flushPending()
+}
+
+func flushPending() error {
+	pendingLock.Lock()
+	lines := pending
+	pending = nil
+	pendingLock.Unlock()
+	if len(lines) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(dirs.SnapChangeHistoryFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(bytes.Join(lines, nil))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return trimHistory()
+}
+
+// trimHistory drops the oldest changes of the history once it grows larger
+// than maxHistorySize, until it is at most half of it.
+func trimHistory() error {
+	fi, err := os.Stat(dirs.SnapChangeHistoryFile)
+	if err != nil {
+		return err
+	}
+	if fi.Size() <= maxHistorySize {
+		return nil
+	}
+	data, err := ioutil.ReadFile(dirs.SnapChangeHistoryFile)
+	if err != nil {
+		return err
+	}
+	for int64(len(data)) > maxHistorySize/2 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			data = nil
+			break
+		}
+		data = data[i+1:]
+	}
+	return osutil.AtomicWriteFile(dirs.SnapChangeHistoryFile, data, 0600, 0)
+}
+
+// Filter selects archived changes.
+type Filter struct {
+	// SnapName selects the changes affecting the snap.
+	SnapName string
+	// Kind selects the changes of the kind.
+	Kind string
+	// Status selects the changes with the status.
+	Status string
+	// RequestedBy selects the changes requested by the uid, if it is a
+	// number, or else by the snapd user with the name.
+	RequestedBy string
+	// Since and Until select the changes spawned in the time range,
+	// either can be zero to leave the range open.
+	Since time.Time
+	Until time.Time
+}
+
+func (f *Filter) match(chg *Change) bool {
+	if f.Kind != "" && chg.Kind != f.Kind {
+		return false
+	}
+	if f.Status != "" && chg.Status != f.Status {
+		return false
+	}
+	if !f.Since.IsZero() && chg.SpawnTime.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !chg.SpawnTime.Before(f.Until) {
+		return false
+	}
+	if f.RequestedBy != "" && !chg.RequestedBy.is(f.RequestedBy) {
+		return false
+	}
+	if f.SnapName == "" {
+		return true
+	}
+	for _, name := range chg.SnapNames {
+		// the snap-names of service-control changes may include
+		// <snap>.<app>
+		snapName, _ := snap.SplitSnapApp(name)
+		if snapName == f.SnapName {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Requester) is(who string) bool {
+	if r == nil {
+		return false
+	}
+	// usernames cannot be only made of digits
+	if uid, err := strconv.ParseUint(who, 10, 32); err == nil {
+		return r.UID == uint32(uid)
+	}
+	return r.Username == who
+}
+
+// Query returns the archived changes selected by the filter, from the oldest
+// to the newest.
+func Query(filter *Filter) ([]*Change, error) {
+	if filter == nil {
+		filter = &Filter{}
+	}
+
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	// include the changes archived since the last flush
+	if err := flushPending(); err != nil {
+		logger.Noticef("cannot write archived changes to the change history: %v", err)
+	}
+
+	f, err := os.Open(dirs.SnapChangeHistoryFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	// a change archived again because snapd stopped before the state
+	// was saved replaces the earlier copy
+	type changeKey struct {
+		id        string
+		spawnTime time.Time
+	}
+	byKey := make(map[changeKey]*Change)
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var chg Change
+			if jerr := json.Unmarshal(line, &chg); jerr != nil {
+				logger.Debugf("ignoring invalid change history entry: %v", jerr)
+			} else if filter.match(&chg) {
+				byKey[changeKey{chg.ID, chg.SpawnTime.UTC()}] = &chg
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("cannot read change history: %v", err)
+		}
+	}
+
+	chgs := make([]*Change, 0, len(byKey))
+	for _, chg := range byKey {
+		chgs = append(chgs, chg)
+	}
+	sort.Slice(chgs, func(i, j int) bool {
+		if !chgs[i].SpawnTime.Equal(chgs[j].SpawnTime) {
+			return chgs[i].SpawnTime.Before(chgs[j].SpawnTime)
+		}
+		return chgs[i].ID < chgs[j].ID
+	})
+	return chgs, nil
+}
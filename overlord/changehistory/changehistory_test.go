@@ -0,0 +1,256 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package changehistory_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/changehistory"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type historySuite struct {
+	testutil.BaseTest
+
+	st  *state.State
+	now time.Time
+}
+
+var _ = Suite(&historySuite{})
+
+func (s *historySuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapChangeHistoryFile), 0755), IsNil)
+
+	s.st = state.New(nil)
+	changehistory.Init(s.st)
+	s.AddCleanup(changehistory.DropPending)
+	s.now = time.Now()
+}
+
+// addChange adds a ready change that was spawned the given time ago.
+func (s *historySuite) addChange(kind string, snapNames []string, ago time.Duration, fail bool) *state.Change {
+	restore := state.MockTime(s.now.Add(-ago))
+	defer restore()
+
+	chg := s.st.NewChange(kind, fmt.Sprintf("%s of %v", kind, snapNames))
+	if snapNames != nil {
+		chg.Set("snap-names", snapNames)
+	}
+	t := s.st.NewTask("do-something", "Do something")
+	t.Logf("something was done")
+	chg.AddTask(t)
+	if fail {
+		t.Errorf("something failed")
+		t.SetStatus(state.ErrorStatus)
+	} else {
+		t.SetStatus(state.DoneStatus)
+	}
+	chg.Status()
+	return chg
+}
+
+func (s *historySuite) prune() {
+	s.st.Prune(s.now.Add(-24*time.Hour), time.Hour, 2*time.Hour, 100)
+}
+
+func ids(chgs []*changehistory.Change) []string {
+	var l []string
+	for _, chg := range chgs {
+		l = append(l, chg.ID)
+	}
+	return l
+}
+
+func (s *historySuite) TestArchivePrunedChanges(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	chg1 := s.addChange("refresh-snap", []string{"foo"}, 3*time.Hour, false)
+	chg2 := s.addChange("install-snap", []string{"bar"}, 2*time.Hour, true)
+	chg2.Set("requested-by", &changehistory.Requester{UID: 1000, UserID: 1, Username: "user"})
+	// not old enough to be pruned
+	s.addChange("remove-snap", []string{"foo"}, time.Minute, false)
+
+	chgs, err := changehistory.Query(nil)
+	c.Assert(err, IsNil)
+	c.Check(chgs, HasLen, 0)
+
+	s.prune()
+	c.Check(s.st.Changes(), HasLen, 1)
+	// the history is written outside of the state lock
+	c.Check(dirs.SnapChangeHistoryFile, testutil.FileAbsent)
+	c.Assert(changehistory.Flush(), IsNil)
+	c.Check(dirs.SnapChangeHistoryFile, testutil.FilePresent)
+
+	chgs, err = changehistory.Query(nil)
+	c.Assert(err, IsNil)
+	c.Assert(chgs, HasLen, 2)
+	c.Check(ids(chgs), DeepEquals, []string{chg1.ID(), chg2.ID()})
+
+	failed := chgs[1]
+	c.Check(failed.Kind, Equals, "install-snap")
+	c.Check(failed.Summary, Equals, "install-snap of [bar]")
+	c.Check(failed.Status, Equals, "Error")
+	c.Check(failed.Err, Matches, `(?s)cannot perform the following tasks:.*Do something \(something failed\)`)
+	c.Check(failed.SnapNames, DeepEquals, []string{"bar"})
+	c.Check(failed.RequestedBy, DeepEquals, &changehistory.Requester{UID: 1000, UserID: 1, Username: "user"})
+	c.Check(chgs[0].RequestedBy, IsNil)
+	c.Check(failed.SpawnTime.Equal(s.now.Add(-2*time.Hour)), Equals, true)
+	c.Check(failed.ReadyTime.Equal(s.now.Add(-2*time.Hour)), Equals, true)
+	c.Assert(failed.Tasks, HasLen, 1)
+	c.Check(failed.Tasks[0].Kind, Equals, "do-something")
+	c.Check(failed.Tasks[0].Status, Equals, "Error")
+	c.Assert(failed.Tasks[0].Log, HasLen, 2)
+	c.Check(failed.Tasks[0].Log[0], Matches, ".* INFO something was done")
+	c.Check(failed.Tasks[0].Log[1], Matches, ".* ERROR something failed")
+}
+
+func (s *historySuite) TestQueryFilters(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	chg1 := s.addChange("refresh-snap", []string{"foo"}, 5*time.Hour, false)
+	chg2 := s.addChange("service-control", []string{"foo.svc", "bar.svc"}, 4*time.Hour, false)
+	chg3 := s.addChange("install-snap", []string{"bar"}, 3*time.Hour, true)
+	chg4 := s.addChange("refresh-catalog", nil, 2*time.Hour, false)
+	chg1.Set("requested-by", &changehistory.Requester{UID: 0})
+	chg3.Set("requested-by", &changehistory.Requester{UID: 1000, UserID: 1, Username: "user"})
+	s.prune()
+
+	for _, tc := range []struct {
+		filter   changehistory.Filter
+		expected []string
+	}{
+		{changehistory.Filter{}, []string{chg1.ID(), chg2.ID(), chg3.ID(), chg4.ID()}},
+		{changehistory.Filter{SnapName: "foo"}, []string{chg1.ID(), chg2.ID()}},
+		{changehistory.Filter{SnapName: "bar"}, []string{chg2.ID(), chg3.ID()}},
+		{changehistory.Filter{SnapName: "baz"}, nil},
+		{changehistory.Filter{Kind: "refresh-snap"}, []string{chg1.ID()}},
+		{changehistory.Filter{Status: "Error"}, []string{chg3.ID()}},
+		{changehistory.Filter{Since: s.now.Add(-4 * time.Hour)}, []string{chg2.ID(), chg3.ID(), chg4.ID()}},
+		{changehistory.Filter{Until: s.now.Add(-3 * time.Hour)}, []string{chg1.ID(), chg2.ID()}},
+		{changehistory.Filter{SnapName: "bar", Since: s.now.Add(-4 * time.Hour), Until: s.now.Add(-3 * time.Hour)}, []string{chg2.ID()}},
+		{changehistory.Filter{RequestedBy: "0"}, []string{chg1.ID()}},
+		{changehistory.Filter{RequestedBy: "1000"}, []string{chg3.ID()}},
+		{changehistory.Filter{RequestedBy: "user"}, []string{chg3.ID()}},
+		{changehistory.Filter{RequestedBy: "other"}, nil},
+	} {
+		chgs, err := changehistory.Query(&tc.filter)
+		c.Assert(err, IsNil)
+		c.Check(ids(chgs), DeepEquals, tc.expected, Commentf("%+v", tc.filter))
+	}
+}
+
+func (s *historySuite) TestQueryArchivedTwiceAndInvalidEntries(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	chg := s.addChange("refresh-snap", []string{"foo"}, 3*time.Hour, false)
+	c.Assert(changehistory.Archive(chg), IsNil)
+	c.Assert(changehistory.Flush(), IsNil)
+
+	f, err := os.OpenFile(dirs.SnapChangeHistoryFile, os.O_WRONLY|os.O_APPEND, 0600)
+	c.Assert(err, IsNil)
+	_, err = f.WriteString(`{"id": "42", "kind": "incompl` + "\n")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	// archived again because the state was not saved after pruning
+	s.prune()
+
+	chgs, err := changehistory.Query(nil)
+	c.Assert(err, IsNil)
+	c.Check(ids(chgs), DeepEquals, []string{chg.ID()})
+}
+
+func (s *historySuite) TestTrimHistory(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	chg := s.addChange("refresh-snap", []string{"foo"}, 3*time.Hour, false)
+	c.Assert(changehistory.Archive(chg), IsNil)
+	c.Assert(changehistory.Flush(), IsNil)
+	fi, err := os.Stat(dirs.SnapChangeHistoryFile)
+	c.Assert(err, IsNil)
+	// room for two and a half changes
+	maxSize := fi.Size()*5/2 + 1
+	defer changehistory.MockMaxHistorySize(maxSize)()
+
+	var expected []string
+	for i := 0; i < 5; i++ {
+		chg := s.addChange("refresh-snap", []string{"foo"}, time.Duration(2*time.Hour-time.Duration(i)*time.Minute), false)
+		expected = append(expected, chg.ID())
+	}
+	s.prune()
+	c.Assert(changehistory.Flush(), IsNil)
+
+	fi, err = os.Stat(dirs.SnapChangeHistoryFile)
+	c.Assert(err, IsNil)
+	c.Check(fi.Size() <= maxSize, Equals, true)
+
+	// the oldest changes were dropped
+	chgs, err := changehistory.Query(nil)
+	c.Assert(err, IsNil)
+	c.Assert(len(chgs) > 0 && len(chgs) <= 2, Equals, true, Commentf("%d changes", len(chgs)))
+	c.Check(chgs[len(chgs)-1].ID, Equals, expected[4])
+	c.Check(chgs[0].ID, Not(Equals), chg.ID())
+}
+
+func (s *historySuite) TestArchiveError(c *C) {
+	c.Assert(os.RemoveAll(filepath.Dir(dirs.SnapChangeHistoryFile)), IsNil)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	chg := s.addChange("refresh-snap", []string{"foo"}, 3*time.Hour, false)
+	c.Assert(changehistory.Archive(chg), IsNil)
+	err := changehistory.Flush()
+	c.Check(errors.Is(err, os.ErrNotExist), Equals, true)
+
+	// the changes which could not be written are not retried
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapChangeHistoryFile), 0755), IsNil)
+	c.Assert(changehistory.Flush(), IsNil)
+	c.Check(dirs.SnapChangeHistoryFile, testutil.FileAbsent)
+}
+
+func (s *historySuite) TestQueryFlushesArchivedChanges(c *C) {
+	s.st.Lock()
+	chg := s.addChange("refresh-snap", []string{"foo"}, 3*time.Hour, false)
+	c.Assert(changehistory.Archive(chg), IsNil)
+	s.st.Unlock()
+
+	chgs, err := changehistory.Query(nil)
+	c.Assert(err, IsNil)
+	c.Check(ids(chgs), DeepEquals, []string{chg.ID()})
+	c.Check(dirs.SnapChangeHistoryFile, testutil.FilePresent)
+}
@@ -0,0 +1,36 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package changehistory
+
+import (
+	"github.com/snapcore/snapd/testutil"
+)
+
+func MockMaxHistorySize(size int64) (restore func()) {
+	r := testutil.Backup(&maxHistorySize)
+	maxHistorySize = size
+	return r
+}
+
+func DropPending() {
+	pendingLock.Lock()
+	defer pendingLock.Unlock()
+	pending = nil
+}
@@ -44,6 +44,7 @@ type Transaction struct {
 	state    *state.State
 	pristine map[string]map[string]*json.RawMessage // snap => key => value
 	changes  map[string]map[string]interface{}
+	changeID string
 }
 
 // NewTransaction creates a new configuration transaction initialized with the given state.
@@ -95,6 +96,14 @@ func changes(cfgStr string, cfg map[string]interface{}) []string {
 	return out
 }
 
+// SetChangeID sets the ID of the change the transaction is part of. It's
+// recorded in the notices added when the transaction is committed.
+func (t *Transaction) SetChangeID(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.changeID = id
+}
+
 // Changes returns the changing keys associated with this transaction
 func (t *Transaction) Changes() []string {
 	var out []string
@@ -338,6 +347,11 @@ func (t *Transaction) Commit() {
 		applyChanges(config, snapChanges)
 		purgeNulls(config)
 		t.pristine[instanceName] = config
+
+		t.state.AddNotice(state.SnapConfigNotice, instanceName, &state.AddNoticeOptions{
+			ChangeID:    t.changeID,
+			ChangedKeys: snapChangedKeys(instanceName, snapChanges),
+		})
 	}
 
 	t.state.Set("config", t.pristine)
@@ -346,6 +360,17 @@ func (t *Transaction) Commit() {
 	t.changes = make(map[string]map[string]interface{})
 }
 
+// snapChangedKeys returns the sorted keys changed in the snap's configuration,
+// without the snap name prefix.
+func snapChangedKeys(instanceName string, snapChanges map[string]interface{}) []string {
+	keys := changes(instanceName, snapChanges)
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, instanceName+".")
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func applyChanges(config map[string]*json.RawMessage, changes map[string]interface{}) {
 	for k, v := range changes {
 		config[k] = commitChange(config[k], v)
@@ -505,6 +505,36 @@ func (s *transactionSuite) TestCommitOverNilSnapConfig(c *C) {
 	c.Assert(v, Equals, "bar")
 }
 
+func (s *transactionSuite) TestCommitAddsNotices(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	t := config.NewTransaction(s.state)
+	t.SetChangeID("7")
+	c.Assert(t.Set("test-snap", "foo.bar", "baz"), IsNil)
+	c.Assert(t.Set("test-snap", "abc", 1), IsNil)
+	c.Assert(t.Set("other-snap", "x", nil), IsNil)
+	t.Commit()
+
+	notices, err := s.state.Notices(nil)
+	c.Assert(err, IsNil)
+	c.Assert(notices, HasLen, 2)
+	byKey := make(map[string]*state.Notice)
+	for _, n := range notices {
+		c.Check(n.Type(), Equals, state.SnapConfigNotice)
+		c.Check(n.ChangeID(), Equals, "7")
+		byKey[n.Key()] = n
+	}
+	c.Check(byKey["test-snap"].ChangedKeys(), DeepEquals, []string{"abc", "foo.bar"})
+	c.Check(byKey["other-snap"].ChangedKeys(), DeepEquals, []string{"x"})
+
+	// nothing to commit, no notice
+	t.Commit()
+	notices, err = s.state.Notices(nil)
+	c.Assert(err, IsNil)
+	c.Check(notices, HasLen, 2)
+}
+
 func (s *transactionSuite) TestGetUnmarshalError(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -89,3 +89,11 @@ func MockApparmorReloadAllSnapProfiles(f func() error) func() {
 	apparmorReloadAllSnapProfiles = f
 	return r
 }
+
+func MockBackendEncryptionPassphrase(passphrase string) (restore func()) {
+	old := backendEncryptionPassphrase
+	backendEncryptionPassphrase = func() string { return passphrase }
+	return func() {
+		backendEncryptionPassphrase = old
+	}
+}
@@ -38,6 +38,7 @@ func init() {
 	supportedConfigurations["core.refresh.metered"] = true
 	supportedConfigurations["core.refresh.retain"] = true
 	supportedConfigurations["core.refresh.rate-limit"] = true
+	supportedConfigurations["core.refresh.health-grace-period"] = true
 }
 
 func reportOrIgnoreInvalidManageRefreshes(tr RunTransaction, optName string) error {
@@ -146,3 +147,21 @@ func validateRefreshRateLimit(tr RunTransaction) error {
 	}
 	return nil
 }
+
+func validateRefreshHealthGracePeriod(tr RunTransaction) error {
+	gracePeriodStr, err := coreCfg(tr, "refresh.health-grace-period")
+	if err != nil {
+		return err
+	}
+	if gracePeriodStr == "" {
+		return nil
+	}
+	gracePeriod, err := time.ParseDuration(gracePeriodStr)
+	if err != nil {
+		return fmt.Errorf("cannot parse refresh.health-grace-period: %v", err)
+	}
+	if gracePeriod < 0 {
+		return fmt.Errorf("refresh.health-grace-period cannot be negative, got %q", gracePeriodStr)
+	}
+	return nil
+}
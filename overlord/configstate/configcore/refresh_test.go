@@ -221,3 +221,28 @@ func (s *refreshSuite) TestConfigureRefreshRetainInvalid(c *C) {
 	})
 	c.Assert(err, ErrorMatches, `retain must be a number between 2 and 20, not "invalid"`)
 }
+
+func (s *refreshSuite) TestConfigureRefreshHealthGracePeriod(c *C) {
+	for _, tc := range []struct {
+		gracePeriod string
+		err         string
+	}{
+		{"", ""},
+		{"10m", ""},
+		{"0", ""},
+		{"-1m", `refresh.health-grace-period cannot be negative, got "-1m"`},
+		{"ten minutes", `cannot parse refresh.health-grace-period: .*`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"refresh.health-grace-period": tc.gracePeriod,
+			},
+		})
+		if tc.err == "" {
+			c.Check(err, IsNil, Commentf("%q", tc.gracePeriod))
+		} else {
+			c.Check(err, ErrorMatches, tc.err, Commentf("%q", tc.gracePeriod))
+		}
+	}
+}
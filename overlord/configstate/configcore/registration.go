@@ -0,0 +1,44 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.device.registration"] = true
+}
+
+// validateDeviceRegistration checks device.registration, which can be set to
+// "disabled" to run the device without ever registering it and so without a
+// serial assertion.
+func validateDeviceRegistration(tr RunTransaction) error {
+	registration, err := coreCfg(tr, "device.registration")
+	if err != nil {
+		return err
+	}
+	switch registration {
+	case "", "enabled", "disabled":
+		return nil
+	}
+	return fmt.Errorf(`device.registration can only be set to "enabled" or "disabled", not %q`, registration)
+}
@@ -0,0 +1,70 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/snap/naming"
+)
+
+const (
+	restartLimitOpt        = "resilience.restart-limit"
+	snapRestartLimitPrefix = "core.resilience.restart-limits."
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core."+restartLimitOpt] = true
+}
+
+func isSnapRestartLimitChange(chg string) bool {
+	return strings.HasPrefix(chg, snapRestartLimitPrefix)
+}
+
+func validateRestartLimits(tr RunTransaction) error {
+	opts := []string{restartLimitOpt}
+	for _, name := range tr.Changes() {
+		if !isSnapRestartLimitChange(name) {
+			continue
+		}
+		snapName := strings.TrimPrefix(name, snapRestartLimitPrefix)
+		if err := naming.ValidateSnap(snapName); err != nil {
+			return fmt.Errorf("cannot set restart limit of snap %q: %v", snapName, err)
+		}
+		opts = append(opts, strings.TrimPrefix(name, "core."))
+	}
+
+	for _, opt := range opts {
+		limit, err := coreCfg(tr, opt)
+		if err != nil {
+			return err
+		}
+		if limit == "" {
+			continue
+		}
+		if _, err := servicestate.ParseRestartLimit(limit); err != nil {
+			return fmt.Errorf("cannot set %q: %v", opt, err)
+		}
+	}
+	return nil
+}
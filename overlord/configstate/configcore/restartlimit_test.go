@@ -0,0 +1,64 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type restartLimitSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&restartLimitSuite{})
+
+func (s *restartLimitSuite) TestConfigureRestartLimitHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"resilience.restart-limit":          "5/10m",
+			"resilience.restart-limits.foo":     "none",
+			"resilience.restart-limits.foo-bar": "1/1h",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *restartLimitSuite) TestConfigureRestartLimitUnhappy(c *C) {
+	for _, t := range []struct {
+		key, value string
+		err        string
+	}{
+		{"resilience.restart-limit", "5", `cannot set "resilience.restart-limit": restart limit must be of the form <burst>/<period> or "none", got "5"`},
+		{"resilience.restart-limit", "5/1s", `cannot set "resilience.restart-limit": restart limit period must be at least 1m0s, got 1s`},
+		{"resilience.restart-limits.foo", "0/10m", `cannot set "resilience.restart-limits.foo": restart limit burst must be a positive number, got "0"`},
+		{"resilience.restart-limits.123", "5/10m", `cannot set restart limit of snap "123": invalid snap name: "123"`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			changes: map[string]interface{}{
+				t.key: t.value,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%s=%s", t.key, t.value))
+	}
+}
@@ -60,7 +60,21 @@ func init() {
 	validateOnly := &flags{validatedOnlyStateConfig: true}
 	addWithStateHandler(validateRefreshSchedule, nil, validateOnly)
 	addWithStateHandler(validateRefreshRateLimit, nil, validateOnly)
+	addWithStateHandler(validateRefreshHealthGracePeriod, nil, validateOnly)
 	addWithStateHandler(validateAutomaticSnapshotsExpiration, nil, validateOnly)
+	addWithStateHandler(validateSnapshotsDeduplicate, nil, validateOnly)
+	addWithStateHandler(validateSnapshotsSchedule, nil, validateOnly)
+	addWithStateHandler(validateSnapshotsTarget, nil, validateOnly)
+	addWithStateHandler(validateStoreArchitectures, nil, validateOnly)
+	addWithStateHandler(validateDeviceRegistration, nil, validateOnly)
+	// resilience.restart-limit, resilience.restart-limits.*
+	addWithStateHandler(validateRestartLimits, nil, validateOnly)
+
+	// store.local-dir
+	addWithStateHandler(validateStoreLocalDir, handleStoreLocalDir, nil)
+
+	// snapshots.encryption, snapshots.encryption-{key-file,passphrase}
+	addWithStateHandler(validateSnapshotsEncryption, nil, validateOnly)
 
 	// netplan.*
 	addWithStateHandler(validateNetplanSettings, handleNetplanConfiguration, coreOnly)
@@ -157,6 +171,8 @@ func applyHandlers(dev sysconfig.Device, cfg RunTransaction, handlers []configHa
 			if !validCertOption(k) {
 				return fmt.Errorf("cannot set store ssl certificate under name %q: name must only contain word characters or a dash", k)
 			}
+		case isSnapRestartLimitChange(k):
+			// validated by validateRestartLimits
 		case isNetplanChange(k):
 			if release.OnClassic {
 				return fmt.Errorf("cannot set netplan configuration on classic")
@@ -23,12 +23,37 @@ package configcore
 
 import (
 	"fmt"
+	"path/filepath"
+	"strconv"
 	"time"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapshotstate/target"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 func init() {
 	// add supported configuration of this module
 	supportedConfigurations["core.snapshots.automatic.retention"] = true
+	supportedConfigurations["core.snapshots.deduplicate"] = true
+	supportedConfigurations["core.snapshots.encryption"] = true
+	supportedConfigurations["core.snapshots.encryption-key-file"] = true
+	supportedConfigurations["core.snapshots.encryption-passphrase"] = true
+	supportedConfigurations["core.snapshots.schedule"] = true
+	supportedConfigurations["core.snapshots.scheduled.include"] = true
+	supportedConfigurations["core.snapshots.scheduled.exclude"] = true
+	supportedConfigurations["core.snapshots.scheduled.keep-last"] = true
+	supportedConfigurations["core.snapshots.scheduled.keep-daily"] = true
+	supportedConfigurations["core.snapshots.scheduled.keep-weekly"] = true
+	supportedConfigurations["core.snapshots.target"] = true
+	supportedConfigurations["core.snapshots.target-ssh-key-file"] = true
+	supportedConfigurations["core.snapshots.target-ssh-host-key"] = true
+	supportedConfigurations["core.snapshots.target-s3-credentials-file"] = true
+	supportedConfigurations["core.snapshots.target-region"] = true
 }
 
 func validateAutomaticSnapshotsExpiration(tr RunTransaction) error {
@@ -47,3 +72,133 @@ func validateAutomaticSnapshotsExpiration(tr RunTransaction) error {
 	}
 	return nil
 }
+
+func validateSnapshotsDeduplicate(tr RunTransaction) error {
+	return validateBoolFlag(tr, "snapshots.deduplicate")
+}
+
+func validateSnapshotsEncryption(tr RunTransaction) error {
+	encryption, err := coreCfg(tr, "snapshots.encryption")
+	if err != nil {
+		return err
+	}
+	keyFile, err := coreCfg(tr, "snapshots.encryption-key-file")
+	if err != nil {
+		return err
+	}
+	if keyFile != "" && !filepath.IsAbs(keyFile) {
+		return fmt.Errorf("snapshots.encryption-key-file must be an absolute path")
+	}
+	inConfig, err := coreCfg(tr, "snapshots.encryption-passphrase")
+	if err != nil {
+		return err
+	}
+	if inConfig != "" {
+		// the daemon keeps it out of the configuration, which is
+		// stored in the state
+		return fmt.Errorf("snapshots.encryption-passphrase can only be set with snap set")
+	}
+	var passphrase string
+	if t := tr.Task(); t != nil {
+		st := tr.State()
+		st.Lock()
+		passphrase = snapshotstate.PendingEncryptionPassphrase(t)
+		st.Unlock()
+	}
+	if passphrase != "" {
+		if err := snapshotstate.ValidateEncryptionPassphrase(passphrase); err != nil {
+			return err
+		}
+	}
+
+	switch encryption {
+	case "", "none", "sealed":
+	case "key-file":
+		if keyFile == "" {
+			return fmt.Errorf(`snapshots.encryption-key-file must be set when snapshots.encryption is "key-file"`)
+		}
+	case "passphrase":
+		// the passphrase is not kept in the configuration, so it
+		// can only be required when switching to it
+		var pristine interface{}
+		if err := tr.GetPristine("core", "snapshots.encryption", &pristine); err != nil && !config.IsNoOption(err) {
+			return err
+		}
+		if passphrase == "" && pristine != "passphrase" && backendEncryptionPassphrase() == "" {
+			return fmt.Errorf(`snapshots.encryption-passphrase must be set when snapshots.encryption is "passphrase"`)
+		}
+	default:
+		return fmt.Errorf(`snapshots.encryption can only be set to "none", "key-file", "passphrase" or "sealed"`)
+	}
+	return nil
+}
+
+var backendEncryptionPassphrase = backend.EncryptionPassphrase
+
+func validateSnapshotsSchedule(tr RunTransaction) error {
+	schedule, err := coreCfg(tr, "snapshots.schedule")
+	if err != nil {
+		return err
+	}
+	if schedule != "" {
+		if _, err := timeutil.ParseSchedule(schedule); err != nil {
+			return fmt.Errorf("cannot parse snapshots.schedule: %v", err)
+		}
+	}
+
+	for _, opt := range []string{"snapshots.scheduled.include", "snapshots.scheduled.exclude"} {
+		snaps, err := coreCfg(tr, opt)
+		if err != nil {
+			return err
+		}
+		for _, instanceName := range strutil.CommaSeparatedList(snaps) {
+			if err := naming.ValidateInstance(instanceName); err != nil {
+				return fmt.Errorf("cannot set %q: %v", opt, err)
+			}
+		}
+	}
+
+	for _, opt := range []string{"snapshots.scheduled.keep-last", "snapshots.scheduled.keep-daily", "snapshots.scheduled.keep-weekly"} {
+		keep, err := coreCfg(tr, opt)
+		if err != nil {
+			return err
+		}
+		if keep == "" {
+			continue
+		}
+		if _, err := strconv.ParseUint(keep, 10, 31); err != nil {
+			return fmt.Errorf("%s must be a non-negative number, got %q", opt, keep)
+		}
+	}
+	return nil
+}
+
+func validateSnapshotsTarget(tr RunTransaction) error {
+	var cfg target.Config
+	for _, opt := range []struct {
+		key   string
+		value *string
+	}{
+		{"snapshots.target", &cfg.URL},
+		{"snapshots.target-ssh-key-file", &cfg.SSHKeyFile},
+		{"snapshots.target-ssh-host-key", &cfg.SSHHostKey},
+		{"snapshots.target-s3-credentials-file", &cfg.S3CredentialsFile},
+		{"snapshots.target-region", &cfg.Region},
+	} {
+		value, err := coreCfg(tr, opt.key)
+		if err != nil {
+			return err
+		}
+		*opt.value = value
+	}
+	if cfg.SSHKeyFile != "" && !filepath.IsAbs(cfg.SSHKeyFile) {
+		return fmt.Errorf("snapshots.target-ssh-key-file must be an absolute path")
+	}
+	if cfg.S3CredentialsFile != "" && !filepath.IsAbs(cfg.S3CredentialsFile) {
+		return fmt.Errorf("snapshots.target-s3-credentials-file must be an absolute path")
+	}
+	if cfg.URL == "" {
+		return nil
+	}
+	return cfg.Validate()
+}
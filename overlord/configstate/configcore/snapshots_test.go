@@ -25,6 +25,7 @@ import (
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
 )
 
 type snapshotsSuite struct {
@@ -33,6 +34,13 @@ type snapshotsSuite struct {
 
 var _ = Suite(&snapshotsSuite{})
 
+func (s *snapshotsSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+
+	// the backend keeps the passphrases in memory
+	s.AddCleanup(configcore.MockBackendEncryptionPassphrase(""))
+}
+
 func (s *snapshotsSuite) TestConfigureAutomaticSnapshotsExpirationHappy(c *C) {
 	err := configcore.Run(classicDev, &mockConf{
 		state: s.state,
@@ -72,3 +80,196 @@ func (s *refreshSuite) TestConfigureAutomaticSnapshotsExpirationInvalid(c *C) {
 	})
 	c.Assert(err, ErrorMatches, `snapshots.automatic.retention cannot be parsed:.*`)
 }
+
+func (s *snapshotsSuite) TestConfigureSnapshotsEncryptionHappy(c *C) {
+	for _, conf := range []map[string]interface{}{
+		{"snapshots.encryption": "none"},
+		{"snapshots.encryption": "sealed"},
+		{"snapshots.encryption": "key-file", "snapshots.encryption-key-file": "/etc/snapshots.key"},
+		// the key source can be prepared before being used
+		{"snapshots.encryption-key-file": "/etc/snapshots.key"},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf:  conf,
+		})
+		c.Check(err, IsNil, Commentf("%v", conf))
+	}
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsEncryptionInvalid(c *C) {
+	for _, t := range []struct {
+		conf    map[string]interface{}
+		changes map[string]interface{}
+		err     string
+	}{
+		{
+			conf: map[string]interface{}{"snapshots.encryption": "rot13"},
+			err:  `snapshots.encryption can only be set to "none", "key-file", "passphrase" or "sealed"`,
+		}, {
+			conf: map[string]interface{}{"snapshots.encryption": "key-file"},
+			err:  `snapshots.encryption-key-file must be set when snapshots.encryption is "key-file"`,
+		}, {
+			conf: map[string]interface{}{"snapshots.encryption": "key-file", "snapshots.encryption-key-file": "snapshots.key"},
+			err:  `snapshots.encryption-key-file must be an absolute path`,
+		}, {
+			changes: map[string]interface{}{"snapshots.encryption": "passphrase"},
+			err:     `snapshots.encryption-passphrase must be set when snapshots.encryption is "passphrase"`,
+		}, {
+			conf: map[string]interface{}{"snapshots.encryption-passphrase": "correct horse"},
+			err:  `snapshots.encryption-passphrase can only be set with snap set`,
+		},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state:   s.state,
+			conf:    t.conf,
+			changes: t.changes,
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%v %v", t.conf, t.changes))
+	}
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsEncryptionPassphraseSetBefore(c *C) {
+	// a passphrase set by an earlier change is still used
+	defer configcore.MockBackendEncryptionPassphrase("correct horse")()
+
+	err := configcore.Run(classicDev, &mockConf{
+		state:   s.state,
+		changes: map[string]interface{}{"snapshots.encryption": "passphrase"},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsEncryptionPendingPassphrase(c *C) {
+	s.state.Lock()
+	t := s.state.NewTask("run-hook", "")
+	snapshotstate.SetPendingEncryptionPassphrase(t, "correct horse")
+	s.state.Unlock()
+
+	err := configcore.Run(classicDev, &mockConf{
+		state:   s.state,
+		task:    t,
+		changes: map[string]interface{}{"snapshots.encryption": "passphrase"},
+	})
+	c.Assert(err, IsNil)
+
+	// the passphrase is only applied once the configuration is committed
+	s.state.Lock()
+	c.Check(snapshotstate.PendingEncryptionPassphrase(t), Equals, "correct horse")
+	s.state.Unlock()
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsEncryptionPendingPassphraseTooShort(c *C) {
+	s.state.Lock()
+	t := s.state.NewTask("run-hook", "")
+	snapshotstate.SetPendingEncryptionPassphrase(t, "short")
+	s.state.Unlock()
+
+	err := configcore.Run(classicDev, &mockConf{
+		state:   s.state,
+		task:    t,
+		changes: map[string]interface{}{"snapshots.encryption": "passphrase"},
+	})
+	c.Assert(err, ErrorMatches, `snapshots.encryption-passphrase must be at least 8 characters long`)
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsDeduplicate(c *C) {
+	for _, value := range []interface{}{true, false, "true", "false"} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"snapshots.deduplicate": value,
+			},
+		})
+		c.Check(err, IsNil, Commentf("%v", value))
+	}
+
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"snapshots.deduplicate": "yes",
+		},
+	})
+	c.Assert(err, ErrorMatches, `snapshots.deduplicate can only be set to 'true' or 'false'`)
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsScheduleHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"snapshots.schedule":              "mon-fri,02:00-04:00",
+			"snapshots.scheduled.include":     "foo,bar_instance",
+			"snapshots.scheduled.exclude":     "baz",
+			"snapshots.scheduled.keep-last":   3,
+			"snapshots.scheduled.keep-daily":  "7",
+			"snapshots.scheduled.keep-weekly": 0,
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsScheduleInvalid(c *C) {
+	for _, tc := range []struct {
+		opt, value, err string
+	}{
+		{"snapshots.schedule", "nonsense", `cannot parse snapshots.schedule: cannot parse "nonsense": .*`},
+		{"snapshots.scheduled.include", "foo,Bar", `cannot set "snapshots.scheduled.include": invalid snap name: "Bar"`},
+		{"snapshots.scheduled.exclude", "foo_", `cannot set "snapshots.scheduled.exclude": invalid instance key: ""`},
+		{"snapshots.scheduled.keep-last", "-1", `snapshots.scheduled.keep-last must be a non-negative number, got "-1"`},
+		{"snapshots.scheduled.keep-daily", "a", `snapshots.scheduled.keep-daily must be a non-negative number, got "a"`},
+		{"snapshots.scheduled.keep-weekly", "1.5", `snapshots.scheduled.keep-weekly must be a non-negative number, got "1.5"`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				tc.opt: tc.value,
+			},
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%s=%s", tc.opt, tc.value))
+	}
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsTargetHappy(c *C) {
+	for _, conf := range []map[string]interface{}{
+		{"snapshots.target": ""},
+		{"snapshots.target": "/media/backup/snapshots"},
+		{"snapshots.target": "file:///media/backup/snapshots"},
+		{
+			"snapshots.target":              "sftp://backup@backup.example.com/srv/snapshots",
+			"snapshots.target-ssh-key-file": "/root/.ssh/id_ed25519",
+			"snapshots.target-ssh-host-key": "ssh-ed25519 AAAA",
+		},
+		{
+			"snapshots.target":                     "s3://minio.example.com:9000/snapshots/device",
+			"snapshots.target-s3-credentials-file": "/root/.config/snapshots-s3",
+			"snapshots.target-region":              "eu-west-2",
+		},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf:  conf,
+		})
+		c.Check(err, IsNil, Commentf("%v", conf))
+	}
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsTargetInvalid(c *C) {
+	for _, tc := range []struct {
+		conf map[string]interface{}
+		err  string
+	}{
+		{map[string]interface{}{"snapshots.target": "media/backup"}, `invalid snapshot target "media/backup": must be an absolute path`},
+		{map[string]interface{}{"snapshots.target": "ftp://host/dir"}, `invalid snapshot target "ftp://host/dir": unsupported scheme "ftp"`},
+		{map[string]interface{}{"snapshots.target": "sftp://host/dir"}, `invalid snapshot target "sftp://host/dir": must specify a user and a host`},
+		{map[string]interface{}{"snapshots.target": "sftp://backup@host/dir"}, `invalid snapshot target "sftp://backup@host/dir": SFTP targets need an SSH key and the host key of the server`},
+		{map[string]interface{}{"snapshots.target": "s3://minio.example.com/bucket"}, `invalid snapshot target "s3://minio.example.com/bucket": S3 targets need a credentials file`},
+		{map[string]interface{}{"snapshots.target-ssh-key-file": "id_ed25519"}, `snapshots.target-ssh-key-file must be an absolute path`},
+		{map[string]interface{}{"snapshots.target-s3-credentials-file": "s3-credentials"}, `snapshots.target-s3-credentials-file must be an absolute path`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf:  tc.conf,
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%v", tc.conf))
+	}
+}
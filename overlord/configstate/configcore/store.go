@@ -0,0 +1,100 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/strutil"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.store.local-dir"] = true
+	supportedConfigurations["core.store.architectures"] = true
+}
+
+// validateStoreLocalDir checks store.local-dir, which selects the local store
+// backend serving snaps and assertions from a directory. The store is picked
+// when snapd starts so changes take effect after a restart.
+func validateStoreLocalDir(tr RunTransaction) error {
+	dir, err := coreCfg(tr, "store.local-dir")
+	if err != nil {
+		return err
+	}
+	if dir == "" {
+		return nil
+	}
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("store.local-dir must be an absolute path, not %q", dir)
+	}
+	if !osutil.IsDirectory(dir) {
+		return fmt.Errorf("store.local-dir %q is not a directory", dir)
+	}
+	return nil
+}
+
+// handleStoreLocalDir warns that snapd needs to be restarted when
+// store.local-dir changes, the store is only picked when snapd starts.
+func handleStoreLocalDir(tr RunTransaction, opts *fsOnlyContext) error {
+	dir, err := coreCfg(tr, "store.local-dir")
+	if err != nil {
+		return err
+	}
+	var prevDir string
+	if err := tr.GetPristine("core", "store.local-dir", &prevDir); err != nil && !config.IsNoOption(err) {
+		return err
+	}
+	if dir == prevDir {
+		return nil
+	}
+	st := tr.State()
+	st.Lock()
+	defer st.Unlock()
+	st.Warnf("store.local-dir was changed, snapd needs to be restarted to start using the new store")
+	return nil
+}
+
+var validArchitecture = regexp.MustCompile("^[a-z0-9][a-z0-9-]*$")
+
+// validateStoreArchitectures checks store.architectures, the comma-separated
+// list of the architectures of the snap builds to prefer, in order, when a
+// snap is installed or refreshed without asking for architectures.
+func validateStoreArchitectures(tr RunTransaction) error {
+	value, err := coreCfg(tr, "store.architectures")
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, arch := range strutil.CommaSeparatedList(value) {
+		if !validArchitecture.MatchString(arch) {
+			return fmt.Errorf("store.architectures has an invalid architecture %q", arch)
+		}
+		if seen[arch] {
+			return fmt.Errorf("store.architectures lists architecture %q more than once", arch)
+		}
+		seen[arch] = true
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type storeSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&storeSuite{})
+
+func (s *storeSuite) TestConfigureStoreLocalDirHappy(c *C) {
+	for _, dir := range []string{c.MkDir(), ""} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"store.local-dir": dir,
+			},
+		})
+		c.Check(err, IsNil)
+	}
+}
+
+func (s *storeSuite) TestConfigureStoreLocalDirChangedWarns(c *C) {
+	dir := c.MkDir()
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"store.local-dir": dir,
+		},
+	})
+	c.Assert(err, IsNil)
+	s.state.Lock()
+	c.Check(s.state.AllWarnings(), HasLen, 0)
+	s.state.Unlock()
+
+	err = configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"store.local-dir": dir,
+		},
+		changes: map[string]interface{}{
+			"store.local-dir": c.MkDir(),
+		},
+	})
+	c.Assert(err, IsNil)
+	s.state.Lock()
+	defer s.state.Unlock()
+	warns := s.state.AllWarnings()
+	c.Assert(warns, HasLen, 1)
+	c.Check(warns[0].String(), Equals, "store.local-dir was changed, snapd needs to be restarted to start using the new store")
+}
+
+func (s *storeSuite) TestConfigureStoreLocalDirInvalid(c *C) {
+	for _, t := range []struct {
+		dir string
+		err string
+	}{
+		{"relative/dir", `store.local-dir must be an absolute path, not "relative/dir"`},
+		{filepath.Join(c.MkDir(), "missing"), `store.local-dir ".*/missing" is not a directory`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"store.local-dir": t.dir,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
+
+func (s *storeSuite) TestConfigureStoreArchitecturesHappy(c *C) {
+	for _, value := range []string{"arm64,armhf", "armhf", ""} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"store.architectures": value,
+			},
+		})
+		c.Check(err, IsNil)
+	}
+}
+
+func (s *storeSuite) TestConfigureStoreArchitecturesInvalid(c *C) {
+	for _, t := range []struct {
+		value string
+		err   string
+	}{
+		{"arm64,ARMHF", `store.architectures has an invalid architecture "ARMHF"`},
+		{"arm64,arm/hf", `store.architectures has an invalid architecture "arm/hf"`},
+		{"armhf,arm64,armhf", `store.architectures lists architecture "armhf" more than once`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"store.architectures": t.value,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
@@ -26,12 +26,16 @@ import (
 
 	"github.com/snapcore/snapd/overlord/configstate/configcore"
 	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/sysconfig"
 )
 
-var configcoreRun = configcore.Run
+var (
+	configcoreRun                                 = configcore.Run
+	snapshotstateApplyPendingEncryptionPassphrase = snapshotstate.ApplyPendingEncryptionPassphrase
+)
 
 func MockConfigcoreRun(f func(sysconfig.Device, configcore.RunTransaction) error) (restore func()) {
 	origConfigcoreRun := configcoreRun
@@ -63,6 +67,11 @@ func Init(st *state.State, hookManager *hookstate.HookManager) error {
 				return nil, nil, err
 			}
 			rt := configcore.NewRunTransaction(ContextTransaction(ctx), task)
+			// the snapshot encryption passphrase is only kept in
+			// memory, it is used once the configuration is committed
+			ctx.OnDone(func() error {
+				return snapshotstateApplyPendingEncryptionPassphrase(task)
+			})
 			return dev, rt, nil
 		}()
 		if err != nil {
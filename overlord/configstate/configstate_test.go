@@ -22,6 +22,7 @@
 package configstate_test
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -378,6 +379,78 @@ func (s *configcoreHijackSuite) TestHijack(c *C) {
 	c.Check(configcoreRan, Equals, true)
 }
 
+func (s *configcoreHijackSuite) TestHijackAppliesPendingEncryptionPassphraseOnCommit(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts := configstate.Configure(s.state, "core", map[string]interface{}{
+		"witness": true,
+	}, 0)
+	task := ts.Tasks()[0]
+
+	r := configstate.MockConfigcoreRun(func(dev sysconfig.Device, conf configcore.RunTransaction) error {
+		conf.State().Lock()
+		defer conf.State().Unlock()
+		return conf.Set("core", "witness", true)
+	})
+	defer r()
+
+	var applied []string
+	r = configstate.MockSnapshotstateApplyPendingEncryptionPassphrase(func(t *state.Task) error {
+		c.Check(t.ID(), Equals, task.ID())
+		// the configuration was committed already
+		var witness bool
+		c.Check(config.NewTransaction(t.State()).Get("core", "witness", &witness), IsNil)
+		c.Check(witness, Equals, true)
+		applied = append(applied, t.ID())
+		return nil
+	})
+	defer r()
+
+	chg := s.state.NewChange("configure-core", "configure core")
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	err := s.o.Settle(5 * time.Second)
+	s.state.Lock()
+	c.Assert(err, IsNil)
+
+	c.Check(chg.Err(), IsNil)
+	c.Check(applied, DeepEquals, []string{task.ID()})
+}
+
+func (s *configcoreHijackSuite) TestHijackDoesNotApplyPendingEncryptionPassphraseOnError(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts := configstate.Configure(s.state, "core", map[string]interface{}{
+		"witness": true,
+	}, 0)
+
+	r := configstate.MockConfigcoreRun(func(dev sysconfig.Device, conf configcore.RunTransaction) error {
+		return errors.New("invalid configuration")
+	})
+	defer r()
+
+	applied := false
+	r = configstate.MockSnapshotstateApplyPendingEncryptionPassphrase(func(t *state.Task) error {
+		applied = true
+		return nil
+	})
+	defer r()
+
+	chg := s.state.NewChange("configure-core", "configure core")
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	err := s.o.Settle(5 * time.Second)
+	s.state.Lock()
+	c.Assert(err, IsNil)
+
+	c.Check(chg.Err(), ErrorMatches, "(?s).*invalid configuration.*")
+	c.Check(applied, Equals, false)
+}
+
 type miscSuite struct{}
 
 func (s *miscSuite) TestRemappingFuncs(c *C) {
@@ -23,6 +23,7 @@ package configstate
 
 import (
 	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/sysconfig"
 )
 
@@ -44,3 +45,11 @@ func MockConfigcoreEarly(mock func(dev sysconfig.Device, cfg configcore.RunTrans
 		configcoreEarly = old
 	}
 }
+
+func MockSnapshotstateApplyPendingEncryptionPassphrase(f func(t *state.Task) error) (restore func()) {
+	old := snapshotstateApplyPendingEncryptionPassphrase
+	snapshotstateApplyPendingEncryptionPassphrase = f
+	return func() {
+		snapshotstateApplyPendingEncryptionPassphrase = old
+	}
+}
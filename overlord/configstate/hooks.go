@@ -190,6 +190,7 @@ func ContextTransaction(context *hookstate.Context) *config.Transaction {
 	tr = config.NewTransaction(context.State())
 
 	context.OnDone(func() error {
+		tr.SetChangeID(context.ChangeID())
 		tr.Commit()
 		if context.InstanceName() == "core" {
 			// make sure the Ensure logic can process
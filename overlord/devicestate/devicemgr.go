@@ -594,6 +594,13 @@ func (m *DeviceManager) ensureOperational() error {
 	if m.noRegister {
 		return nil
 	}
+	disabled, err := registrationDisabledByConfig(m.state)
+	if err != nil {
+		return err
+	}
+	if disabled {
+		return nil
+	}
 	// noregister marker file is checked below after mostly in-memory checks
 
 	if m.changeInFlight("become-operational") {
@@ -113,6 +113,27 @@ func findSerial(st *state.State, device *auth.DeviceState) (*asserts.Serial, err
 	return a.(*asserts.Serial), nil
 }
 
+// RegistrationDisabled returns whether the device is meant to run without
+// registering, and so without a serial assertion. This is the case when
+// device.registration is set to "disabled", or when registration was blocked
+// until the next reboot while unregistering the device.
+func RegistrationDisabled(st *state.State) (bool, error) {
+	disabled, err := registrationDisabledByConfig(st)
+	if err != nil || disabled {
+		return disabled, err
+	}
+	return osutil.FileExists(filepath.Join(dirs.SnapRunDir, "noregister")), nil
+}
+
+func registrationDisabledByConfig(st *state.State) (bool, error) {
+	tr := config.NewTransaction(st)
+	var registration string
+	if err := tr.Get("core", "device.registration", &registration); err != nil && !config.IsNoOption(err) {
+		return false, err
+	}
+	return registration == "disabled", nil
+}
+
 // auto-refresh
 func canAutoRefresh(st *state.State) (bool, error) {
 	// we need to be seeded first
@@ -130,12 +151,15 @@ func canAutoRefresh(st *state.State) (bool, error) {
 		return false, nil
 	}
 
-	// Syncloud runs no serial vault and deliberately skips registration via
-	// the noregister marker, so ensureOperational never attempts registration
-	// and ensureOperationalAttempts stays at 0. Without this the serial check
-	// below would disable auto-refresh forever. Allow it when registration was
-	// intentionally opted out.
-	if osutil.FileExists(filepath.Join(dirs.SnapRunDir, "noregister")) {
+	// Unregistered devices never attempt registration, so
+	// ensureOperationalAttempts stays at 0 and they never get a
+	// serial. Without this the serial check below would disable
+	// auto-refresh forever.
+	unregistered, err := RegistrationDisabled(st)
+	if err != nil {
+		return false, err
+	}
+	if unregistered {
 		return true, nil
 	}
 
@@ -148,7 +172,7 @@ func canAutoRefresh(st *state.State) (bool, error) {
 
 	// Check model exists, for validity. We always have a model, either
 	// seeded or a generic one that ships with snapd.
-	_, err := findModel(st)
+	_, err = findModel(st)
 	if errors.Is(err, state.ErrNoState) {
 		return false, nil
 	}
@@ -873,37 +897,93 @@ func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Mo
 //   - Make sure this works with Core 20 as well, in the Core 20 case
 //     we must enforce the default-channels from the model as well
 func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
+	current, remodCtx, tss, err := remodelTaskSets(st, new, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg string
+	if current.BrandID() == new.BrandID() && current.Model() == new.Model() {
+		msg = fmt.Sprintf(i18n.G("Refresh model assertion from revision %v to %v"), current.Revision(), new.Revision())
+	} else {
+		msg = fmt.Sprintf(i18n.G("Remodel device to %v/%v (%v)"), new.BrandID(), new.Model(), new.Revision())
+	}
+
+	chg := st.NewChange("remodel", msg)
+	remodCtx.Init(chg)
+	for _, ts := range tss {
+		chg.AddAll(ts)
+	}
+
+	return chg, nil
+}
+
+// RemodelTaskSets returns the task sets a remodel to the new model would
+// run, without adding them to a change, so that what the remodel would do
+// can be found without doing it. The task sets are not meant to be run and
+// apart from their tasks nothing is changed in the state; in particular for
+// a store switch no new store session is set up upfront. Remodels to a
+// different brand or model are not supported, as the snaps they install are
+// only known once the device has a new serial.
+func RemodelTaskSets(st *state.State, new *asserts.Model) ([]*state.TaskSet, error) {
+	current, err := findModel(st)
+	if err != nil {
+		return nil, err
+	}
+	if ClassifyRemodel(current, new) == ReregRemodel {
+		return nil, fmt.Errorf("cannot plan a remodel to a different brand or model")
+	}
+	_, _, tss, err := remodelTaskSets(st, new, true)
+	return tss, err
+}
+
+// remodelTaskSets checks that a remodel to the new model is possible and
+// returns the current model, the remodel context and the task sets of the
+// remodel. With dryRun the task sets are only meant to be inspected and the
+// steps only needed to run them are skipped.
+func remodelTaskSets(st *state.State, new *asserts.Model, dryRun bool) (*asserts.Model, remodelContext, []*state.TaskSet, error) {
 	var seeded bool
 	err := st.Get("seeded", &seeded)
 	if err != nil && !errors.Is(err, state.ErrNoState) {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	if !seeded {
-		return nil, fmt.Errorf("cannot remodel until fully seeded")
+		return nil, nil, nil, fmt.Errorf("cannot remodel until fully seeded")
 	}
 
 	current, err := findModel(st)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	if _, err := findSerial(st, nil); err != nil {
-		if errors.Is(err, state.ErrNoState) {
-			return nil, fmt.Errorf("cannot remodel without a serial")
+		if !errors.Is(err, state.ErrNoState) {
+			return nil, nil, nil, err
+		}
+		unregistered, err := RegistrationDisabled(st)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !unregistered {
+			return nil, nil, nil, fmt.Errorf("cannot remodel without a serial")
+		}
+		// without a serial there is neither re-registration nor
+		// a device session with a different store
+		if ClassifyRemodel(current, new) != UpdateRemodel {
+			return nil, nil, nil, fmt.Errorf("cannot remodel to a different brand, model or store while registration is disabled")
 		}
-		return nil, err
 	}
 
 	if current.Series() != new.Series() {
-		return nil, fmt.Errorf("cannot remodel to different series yet")
+		return nil, nil, nil, fmt.Errorf("cannot remodel to different series yet")
 	}
 
 	// don't allow remodel on classic for now
 	if current.Classic() {
-		return nil, fmt.Errorf("cannot remodel from classic model")
+		return nil, nil, nil, fmt.Errorf("cannot remodel from classic model")
 	}
 	if current.Classic() != new.Classic() {
-		return nil, fmt.Errorf("cannot remodel across classic and non-classic models")
+		return nil, nil, nil, fmt.Errorf("cannot remodel across classic and non-classic models")
 	}
 
 	// TODO:UC20: ensure we never remodel to a lower
@@ -913,9 +993,9 @@ func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
 	if current.Grade() != new.Grade() {
 		if current.Grade() == asserts.ModelGradeUnset && new.Grade() != asserts.ModelGradeUnset {
 			// a case of pre-UC20 -> UC20 remodel
-			return nil, fmt.Errorf("cannot remodel from pre-UC20 to UC20+ models")
+			return nil, nil, nil, fmt.Errorf("cannot remodel from pre-UC20 to UC20+ models")
 		}
-		return nil, fmt.Errorf("cannot remodel from grade %v to grade %v", current.Grade(), new.Grade())
+		return nil, nil, nil, fmt.Errorf("cannot remodel from grade %v to grade %v", current.Grade(), new.Grade())
 	}
 
 	// TODO: we need dedicated assertion language to permit for
@@ -928,24 +1008,24 @@ func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
 	// There are valid use-cases here though, i.e. amd64 machine that
 	// remodels itself to/from i386 (if the HW can do both 32/64 bit)
 	if current.Architecture() != new.Architecture() {
-		return nil, fmt.Errorf("cannot remodel to different architectures yet")
+		return nil, nil, nil, fmt.Errorf("cannot remodel to different architectures yet")
 	}
 
 	// calculate snap differences between the two models
 	// FIXME: this needs work to switch from core->bases
 	if current.Base() == "" && new.Base() != "" {
-		return nil, fmt.Errorf("cannot remodel from core to bases yet")
+		return nil, nil, nil, fmt.Errorf("cannot remodel from core to bases yet")
 	}
 
 	// Do we do this only for the more complicated cases (anything
 	// more than adding required-snaps really)?
 	if err := snapstate.CheckChangeConflictRunExclusively(st, "remodel"); err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	remodCtx, err := remodelCtx(st, current, new)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	var tss []*state.TaskSet
@@ -960,21 +1040,23 @@ func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
 	case StoreSwitchRemodel:
 		sto := remodCtx.Store()
 		if sto == nil {
-			return nil, fmt.Errorf("internal error: a store switch remodeling should have built a store")
+			return nil, nil, nil, fmt.Errorf("internal error: a store switch remodeling should have built a store")
 		}
-		// ensure a new session accounting for the new brand store
-		st.Unlock()
-		err := sto.EnsureDeviceSession()
-		st.Lock()
-		if err != nil {
-			return nil, fmt.Errorf("cannot get a store session based on the new model assertion: %v", err)
+		if !dryRun {
+			// ensure a new session accounting for the new brand store
+			st.Unlock()
+			err := sto.EnsureDeviceSession()
+			st.Lock()
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("cannot get a store session based on the new model assertion: %v", err)
+			}
 		}
 		fallthrough
 	case UpdateRemodel:
 		var err error
 		tss, err = remodelTasks(context.TODO(), st, current, new, remodCtx, "")
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, err
 		}
 	}
 
@@ -983,34 +1065,21 @@ func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
 	// we started
 	current1, err := findModel(st)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	if current.BrandID() != current1.BrandID() || current.Model() != current1.Model() || current.Revision() != current1.Revision() {
-		return nil, &snapstate.ChangeConflictError{Message: fmt.Sprintf("cannot start remodel, clashing with concurrent remodel to %v/%v (%v)", current1.BrandID(), current1.Model(), current1.Revision())}
+		return nil, nil, nil, &snapstate.ChangeConflictError{Message: fmt.Sprintf("cannot start remodel, clashing with concurrent remodel to %v/%v (%v)", current1.BrandID(), current1.Model(), current1.Revision())}
 	}
 	// make sure another unfinished remodel wasn't already setup either
 	if chg := RemodelingChange(st); chg != nil {
-		return nil, &snapstate.ChangeConflictError{
+		return nil, nil, nil, &snapstate.ChangeConflictError{
 			Message:    "cannot start remodel, clashing with concurrent one",
 			ChangeKind: chg.Kind(),
 			ChangeID:   chg.ID(),
 		}
 	}
 
-	var msg string
-	if current.BrandID() == new.BrandID() && current.Model() == new.Model() {
-		msg = fmt.Sprintf(i18n.G("Refresh model assertion from revision %v to %v"), current.Revision(), new.Revision())
-	} else {
-		msg = fmt.Sprintf(i18n.G("Remodel device to %v/%v (%v)"), new.BrandID(), new.Model(), new.Revision())
-	}
-
-	chg := st.NewChange("remodel", msg)
-	remodCtx.Init(chg)
-	for _, ts := range tss {
-		chg.AddAll(ts)
-	}
-
-	return chg, nil
+	return current, remodCtx, tss, nil
 }
 
 // RemodelingChange returns a remodeling change in progress, if there is one
@@ -21,6 +21,7 @@ package devicestate_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -42,6 +43,7 @@ import (
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/assertstate/assertstatetest"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
 	"github.com/snapcore/snapd/overlord/restart"
@@ -274,6 +276,51 @@ func (s *deviceMgrRemodelSuite) TestRemodelRequiresSerial(c *C) {
 	c.Check(err, ErrorMatches, "cannot remodel without a serial")
 }
 
+func (s *deviceMgrRemodelSuite) TestRemodelRegistrationDisabled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded", true)
+
+	// set a model assertion
+	cur := map[string]interface{}{
+		"brand":        "canonical",
+		"model":        "pc-model",
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	}
+	s.makeModelAssertionInState(c, "canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	})
+	// no serial assertion, no serial in state
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc-model",
+	})
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "device.registration", "disabled"), IsNil)
+	tr.Commit()
+
+	for _, t := range []struct {
+		model   string
+		headers map[string]interface{}
+		err     string
+	}{
+		// the missing serial is fine, the remodel fails later
+		{"pc-model", map[string]interface{}{"revision": "2", "architecture": "arm64"}, "cannot remodel to different architectures yet"},
+		{"pc-model", map[string]interface{}{"revision": "2", "store": "brand-store"}, "cannot remodel to a different brand, model or store while registration is disabled"},
+		{"other-model", map[string]interface{}{"model": "other-model"}, "cannot remodel to a different brand, model or store while registration is disabled"},
+	} {
+		mergeMockModelHeaders(cur, t.headers)
+		new := s.brands.Model("canonical", t.model, t.headers)
+		chg, err := devicestate.Remodel(s.state, new)
+		c.Check(chg, IsNil)
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
+
 func (s *deviceMgrRemodelSuite) TestRemodelTasksSwitchGadgetTrack(c *C) {
 	s.testRemodelTasksSwitchTrack(c, "pc", map[string]interface{}{
 		"gadget": "pc=18",
@@ -573,6 +620,179 @@ func (s *deviceMgrRemodelSuite) TestRemodelRequiredSnaps(c *C) {
 	c.Assert(tSetModel.WaitTasks(), DeepEquals, []*state.Task{tDownloadSnap1, tValidateSnap1, tInstallSnap1, tDownloadSnap2, tValidateSnap2, tInstallSnap2})
 }
 
+func (s *deviceMgrRemodelSuite) TestRemodelTaskSets(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded", true)
+	s.state.Set("refresh-privacy-key", "some-privacy-key")
+
+	restore := devicestate.MockSnapstateInstallWithDeviceContext(func(ctx context.Context, st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		c.Check(flags.Required, Equals, true)
+		c.Check(deviceCtx.ForRemodeling(), Equals, true)
+
+		tDownload := s.state.NewTask("fake-download", fmt.Sprintf("Download %s", name))
+		tDownload.Set("snap-setup", &snapstate.SnapSetup{
+			SideInfo: &snap.SideInfo{
+				RealName: name,
+			},
+		})
+		tValidate := s.state.NewTask("validate-snap", fmt.Sprintf("Validate %s", name))
+		tValidate.WaitFor(tDownload)
+		tInstall := s.state.NewTask("fake-install", fmt.Sprintf("Install %s", name))
+		tInstall.WaitFor(tValidate)
+		ts := state.NewTaskSet(tDownload, tValidate, tInstall)
+		ts.MarkEdge(tValidate, snapstate.LastBeforeLocalModificationsEdge)
+		return ts, nil
+	})
+	defer restore()
+
+	s.makeModelAssertionInState(c, "canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"base":         "core18",
+	})
+	s.makeSerialAssertionInState(c, "canonical", "pc-model", "1234")
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand:  "canonical",
+		Model:  "pc-model",
+		Serial: "1234",
+	})
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture":   "amd64",
+		"kernel":         "pc-kernel",
+		"gadget":         "pc",
+		"base":           "core18",
+		"required-snaps": []interface{}{"new-required-snap-1"},
+		"revision":       "1",
+	})
+	tss, err := devicestate.RemodelTaskSets(s.state, new)
+	c.Assert(err, IsNil)
+	c.Assert(tss, HasLen, 2)
+	c.Check(tss[0].Tasks(), HasLen, 3)
+	c.Check(tss[0].Tasks()[0].Summary(), Equals, "Download new-required-snap-1")
+	c.Assert(tss[1].Tasks(), HasLen, 1)
+	c.Check(tss[1].Tasks()[0].Kind(), Equals, "set-model")
+
+	// no change was created
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *deviceMgrRemodelSuite) TestRemodelTaskSetsUnhappy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.makeModelAssertionInState(c, "canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"base":         "core18",
+	})
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc-model",
+	})
+
+	// the checks of Remodel apply
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"base":         "core18",
+		"revision":     "1",
+	})
+	_, err := devicestate.RemodelTaskSets(s.state, new)
+	c.Check(err, ErrorMatches, "cannot remodel until fully seeded")
+
+	s.state.Set("seeded", true)
+	new = s.brands.Model("canonical", "rereg-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"base":         "core18",
+	})
+	_, err = devicestate.RemodelTaskSets(s.state, new)
+	c.Check(err, ErrorMatches, "cannot plan a remodel to a different brand or model")
+}
+
+func (s *deviceMgrRemodelSuite) TestRemodelTaskSetsStoreSwitchNoSideEffects(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded", true)
+	s.state.Set("refresh-privacy-key", "some-privacy-key")
+
+	restore := devicestate.MockSnapstateInstallWithDeviceContext(func(ctx context.Context, st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		tDownload := s.state.NewTask("fake-download", fmt.Sprintf("Download %s", name))
+		tDownload.Set("snap-setup", &snapstate.SnapSetup{
+			SideInfo: &snap.SideInfo{
+				RealName: name,
+			},
+		})
+		tValidate := s.state.NewTask("validate-snap", fmt.Sprintf("Validate %s", name))
+		tValidate.WaitFor(tDownload)
+		tInstall := s.state.NewTask("fake-install", fmt.Sprintf("Install %s", name))
+		tInstall.WaitFor(tValidate)
+		ts := state.NewTaskSet(tDownload, tValidate, tInstall)
+		ts.MarkEdge(tValidate, snapstate.LastBeforeLocalModificationsEdge)
+		return ts, nil
+	})
+	defer restore()
+
+	s.makeModelAssertionInState(c, "canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"base":         "core18",
+	})
+	s.makeSerialAssertionInState(c, "canonical", "pc-model", "1234")
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand:           "canonical",
+		Model:           "pc-model",
+		Serial:          "1234",
+		SessionMacaroon: "prev-session",
+	})
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture":   "amd64",
+		"kernel":         "pc-kernel",
+		"gadget":         "pc",
+		"base":           "core18",
+		"store":          "switched-store",
+		"required-snaps": []interface{}{"new-required-snap-1"},
+		"revision":       "1",
+	})
+
+	freshStore := &freshSessionStore{}
+	s.newFakeStore = func(devBE storecontext.DeviceBackend) snapstate.StoreService {
+		return freshStore
+	}
+
+	stateWithoutIDs := func() map[string]json.RawMessage {
+		b, err := json.Marshal(s.state)
+		c.Assert(err, IsNil)
+		var m map[string]json.RawMessage
+		c.Assert(json.Unmarshal(b, &m), IsNil)
+		// the counters of the discarded tasks are expected to move
+		delete(m, "last-task-id")
+		delete(m, "last-lane-id")
+		return m
+	}
+	before := stateWithoutIDs()
+
+	tss, err := devicestate.RemodelTaskSets(s.state, new)
+	c.Assert(err, IsNil)
+	c.Assert(tss, HasLen, 2)
+	for _, ts := range tss {
+		s.state.DiscardTasks(ts.Tasks())
+	}
+
+	// no store session was set up
+	c.Check(freshStore.ensureDeviceSession, Equals, 0)
+	// and apart from the discarded tasks the state is unchanged
+	c.Check(stateWithoutIDs(), DeepEquals, before)
+}
+
 func (s *deviceMgrRemodelSuite) TestRemodelSwitchKernelTrack(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
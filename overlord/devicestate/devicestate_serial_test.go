@@ -2264,6 +2264,58 @@ func (s *deviceMgrSerialSuite) TestFullDeviceRegistrationBlockedByNoRegister(c *
 	c.Assert(becomeOperational, IsNil)
 }
 
+func (s *deviceMgrSerialSuite) TestFullDeviceRegistrationBlockedByConfig(c *C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+
+	r1 := devicestate.MockKeyLength(testKeyLength)
+	defer r1()
+
+	mockServer := s.mockServer(c, "REQID-1", nil)
+	defer mockServer.Close()
+
+	r2 := devicestate.MockBaseStoreURL(mockServer.URL)
+	defer r2()
+
+	// setup state as will be done by first-boot
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// in this case is just marked seeded without snaps
+	s.state.Set("seeded", true)
+
+	// have a in-progress installation
+	inst := s.state.NewChange("install", "...")
+	task := s.state.NewTask("mount-snap", "...")
+	inst.AddTask(task)
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "device.registration", "disabled"), IsNil)
+	tr.Commit()
+
+	// attempt to run the whole device registration process
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	// registration is disabled
+	becomeOperational := s.findBecomeOperationalChange()
+	c.Assert(becomeOperational, IsNil)
+	c.Check(devicestate.EnsureOperationalAttempts(s.state), Equals, 0)
+
+	// re-enabling registration lets it proceed
+	tr = config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "device.registration", "enabled"), IsNil)
+	tr.Commit()
+
+	s.state.Unlock()
+	s.se.Ensure()
+	s.state.Lock()
+
+	becomeOperational = s.findBecomeOperationalChange()
+	c.Assert(becomeOperational, NotNil)
+}
+
 func (s *deviceMgrSerialSuite) TestDeviceSerialRestoreHappy(c *C) {
 	restore := release.MockOnClassic(false)
 	defer restore()
@@ -1067,6 +1067,41 @@ func (s *deviceMgrSuite) TestCanAutoRefreshNoRegister(c *C) {
 	c.Check(canAutoRefresh(), Equals, true)
 }
 
+func (s *deviceMgrSuite) TestCanAutoRefreshRegistrationDisabled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	canAutoRefresh := func() bool {
+		ok, err := devicestate.CanAutoRefresh(s.state)
+		c.Assert(err, IsNil)
+		return ok
+	}
+
+	// seeded, model, no serial, no registration attempts -> no auto-refresh
+	s.state.Set("seeded", true)
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc",
+	})
+	s.makeModelAssertionInState(c, "canonical", "pc", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	})
+	c.Check(canAutoRefresh(), Equals, false)
+
+	// registration disabled by configuration
+	// -> auto-refresh without a serial or any registration attempts
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "device.registration", "disabled"), IsNil)
+	tr.Commit()
+	c.Check(canAutoRefresh(), Equals, true)
+
+	disabled, err := devicestate.RegistrationDisabled(s.state)
+	c.Assert(err, IsNil)
+	c.Check(disabled, Equals, true)
+}
+
 func (s *deviceMgrSuite) TestCanAutoRefreshOnClassic(c *C) {
 	release.OnClassic = true
 
@@ -116,3 +116,33 @@ func MockSystemdSdNotify(f func(notifyState string) error) (restore func()) {
 		systemdSdNotify = old
 	}
 }
+
+type JournaledStateBackend = journaledStateBackend
+
+func NewJournaledStateBackend(path, statePath string) *JournaledStateBackend {
+	return &journaledStateBackend{
+		path:         path,
+		statePath:    statePath,
+		ensureBefore: func(time.Duration) {},
+	}
+}
+
+func (jsb *journaledStateBackend) ReadState() ([]byte, error) {
+	return jsb.readState()
+}
+
+func (jsb *journaledStateBackend) Open() ([]byte, error) {
+	return jsb.open()
+}
+
+func MockJournalCompactMinSize(size int64) (restore func()) {
+	r := testutil.Backup(&journalCompactMinSize)
+	journalCompactMinSize = size
+	return r
+}
+
+func MockStateJournalEnabled(enabled bool) (restore func()) {
+	r := testutil.Backup(&stateJournalEnabled)
+	stateJournalEnabled = func() bool { return enabled }
+	return r
+}
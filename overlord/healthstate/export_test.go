@@ -21,6 +21,14 @@ package healthstate
 
 import (
 	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
 )
 
 func MockCheckTimeout(t time.Duration) (restore func()) {
@@ -32,3 +40,28 @@ func MockCheckTimeout(t time.Duration) (restore func()) {
 }
 
 var KnownStatuses = knownStatuses
+
+func MockRunProbe(f func(probe *snap.HealthProbeInfo, tomb *tomb.Tomb) error) (restore func()) {
+	r := testutil.Backup(&runProbe)
+	runProbe = f
+	return r
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	r := testutil.Backup(&timeNow)
+	timeNow = f
+	return r
+}
+
+func MockServicestateControl(f func(st *state.State, appInfos []*snap.AppInfo, inst *servicestate.Instruction, flags *servicestate.Flags, context *hookstate.Context) ([]*state.TaskSet, error)) (restore func()) {
+	r := testutil.Backup(&servicestateControl)
+	servicestateControl = f
+	return r
+}
+
+// RunDueProbes runs the probes that are due right away.
+func (m *HealthManager) RunDueProbes() {
+	m.runDueProbes()
+}
+
+var RunProbe = runProbeImpl
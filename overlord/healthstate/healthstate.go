@@ -47,6 +47,7 @@ func init() {
 	}
 
 	snapstate.CheckHealthHook = Hook
+	snapstate.SnapHealthError = healthError
 }
 
 func Hook(st *state.State, snapName string, snapRev snap.Revision) *state.Task {
@@ -165,8 +166,10 @@ func (h *healthHandler) appendHealth(health *HealthState) error {
 }
 
 func appendHealth(ctx *hookstate.Context, health *HealthState) error {
-	st := ctx.State()
+	return setHealth(ctx.State(), ctx.InstanceName(), health)
+}
 
+func setHealth(st *state.State, snapName string, health *HealthState) error {
 	var hs map[string]*HealthState
 	if err := st.Get("health", &hs); err != nil {
 		if !errors.Is(err, state.ErrNoState) {
@@ -174,7 +177,7 @@ func appendHealth(ctx *hookstate.Context, health *HealthState) error {
 		}
 		hs = map[string]*HealthState{}
 	}
-	hs[ctx.InstanceName()] = health
+	hs[snapName] = health
 	st.Set("health", hs)
 
 	return nil
@@ -204,6 +207,22 @@ func All(st *state.State) (map[string]*HealthState, error) {
 	return hs, nil
 }
 
+// healthError returns an error with the message of the health of the given
+// revision of the snap if it is in error status.
+func healthError(st *state.State, snapName string, rev snap.Revision) error {
+	health, err := Get(st, snapName)
+	if err != nil {
+		return err
+	}
+	if health == nil || health.Revision != rev || health.Status != ErrorStatus {
+		return nil
+	}
+	if health.Message == "" {
+		return fmt.Errorf("health status is %s", health.Status)
+	}
+	return errors.New(health.Message)
+}
+
 func Get(st *state.State, snap string) (*HealthState, error) {
 	var hs map[string]json.RawMessage
 	if err := st.Get("health", &hs); err != nil {
@@ -252,3 +252,22 @@ func (s *healthSuite) TestSetFromHookContextEmpty(c *check.C) {
 	// no health in the context -> no health in state
 	c.Check(s.state.Get("health", &hs), testutil.ErrorIs, state.ErrNoState)
 }
+
+func (s *healthSuite) TestSnapHealthError(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(snapstate.SnapHealthError(s.state, "foo", snap.R(2)), check.IsNil)
+
+	s.state.Set("health", map[string]*healthstate.HealthState{
+		"foo": {Revision: snap.R(2), Status: healthstate.ErrorStatus, Message: "database is gone"},
+		"bar": {Revision: snap.R(2), Status: healthstate.ErrorStatus},
+		"baz": {Revision: snap.R(2), Status: healthstate.WaitingStatus, Message: "loading"},
+	})
+	c.Check(snapstate.SnapHealthError(s.state, "foo", snap.R(2)), check.ErrorMatches, "database is gone")
+	c.Check(snapstate.SnapHealthError(s.state, "bar", snap.R(2)), check.ErrorMatches, "health status is error")
+	// only errors count
+	c.Check(snapstate.SnapHealthError(s.state, "baz", snap.R(2)), check.IsNil)
+	// of the given revision
+	c.Check(snapstate.SnapHealthError(s.state, "foo", snap.R(3)), check.IsNil)
+}
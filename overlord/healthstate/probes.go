@@ -0,0 +1,505 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+// the code of the health reported for snaps with failing health probes
+const probeFailedCode = "snapd-probe-failed"
+
+var (
+	probeTick = time.Second
+
+	timeNow  = time.Now
+	runProbe = runProbeImpl
+
+	servicestateControl = servicestate.Control
+)
+
+// HealthManager runs the health probes declared by the services of the
+// installed snaps and updates the health of the snaps accordingly.
+type HealthManager struct {
+	state *state.State
+
+	// probes is the status of the probes of the services, by snap.app name
+	probes map[string]*probeStatus
+	// revisions is the revision of the active snaps the probes were
+	// looked up for
+	revisions map[string]snap.Revision
+
+	// mu protects the fields Ensure hands over to the probes loop
+	mu sync.Mutex
+	// ensuredRevisions is the revision of the active snaps the last
+	// Ensure saw
+	ensuredRevisions map[string]snap.Revision
+	// changedSnaps is the state of the snaps to look up the probes in,
+	// set by Ensure when the active revisions changed
+	changedSnaps map[string]*snapstate.SnapState
+	snapsChanged chan struct{}
+
+	started bool
+	tomb    tomb.Tomb
+}
+
+type probeStatus struct {
+	probe    *snap.HealthProbeInfo
+	revision snap.Revision
+
+	next     time.Time
+	failures int
+	health   HealthStatus
+	err      error
+}
+
+// Manager returns a new health manager.
+func Manager(st *state.State) *HealthManager {
+	return &HealthManager{
+		state:        st,
+		probes:       make(map[string]*probeStatus),
+		revisions:    make(map[string]snap.Revision),
+		snapsChanged: make(chan struct{}, 1),
+	}
+}
+
+// StartUp implements StateStarterUp.StartUp.
+func (m *HealthManager) StartUp() error {
+	m.started = true
+	m.tomb.Go(m.loop)
+	return nil
+}
+
+// Ensure implements StateManager.Ensure. It hands the snaps over to the
+// probes loop when the revisions of the active snaps changed, so that the
+// probes are only looked up again then.
+func (m *HealthManager) Ensure() error {
+	m.state.Lock()
+	allStates, err := snapstate.All(m.state)
+	m.state.Unlock()
+	if err != nil {
+		return err
+	}
+	revisions := make(map[string]snap.Revision, len(allStates))
+	for snapName, snapst := range allStates {
+		if snapst.Active {
+			revisions[snapName] = snapst.Current
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ensuredRevisions != nil && reflect.DeepEqual(revisions, m.ensuredRevisions) {
+		return nil
+	}
+	m.ensuredRevisions = revisions
+	m.changedSnaps = allStates
+	select {
+	case m.snapsChanged <- struct{}{}:
+	default:
+		// the loop was already woken up
+	}
+	return nil
+}
+
+// Stop implements StateStopper.Stop.
+func (m *HealthManager) Stop() {
+	if !m.started {
+		return
+	}
+	m.tomb.Kill(nil)
+	m.tomb.Wait()
+}
+
+func (m *HealthManager) loop() error {
+	// the loop only ticks while there are probes to run
+	var ticker *time.Ticker
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+
+	for {
+		var tick <-chan time.Time
+		if ticker != nil {
+			tick = ticker.C
+		}
+		select {
+		case <-m.snapsChanged:
+			m.runDueProbes()
+		case <-tick:
+			m.runDueProbes()
+		case <-m.tomb.Dying():
+			return nil
+		}
+
+		switch {
+		case len(m.probes) > 0 && ticker == nil:
+			ticker = time.NewTicker(probeTick)
+		case len(m.probes) == 0 && ticker != nil:
+			ticker.Stop()
+			ticker = nil
+		}
+	}
+}
+
+// refreshProbes updates the probes tracked to the ones declared by the
+// services of the current revisions of all active snaps. Probes are first
+// run one interval after they start being tracked. The snaps that had
+// failing probes that are no longer tracked are marked as changed.
+func (m *HealthManager) refreshProbes(now time.Time, allStates map[string]*snapstate.SnapState, changed map[string]bool) {
+	dropped := func(ps *probeStatus) {
+		if ps.health == ErrorStatus {
+			changed[ps.probe.App.Snap.InstanceName()] = true
+		}
+	}
+
+	seen := make(map[string]bool, len(m.probes))
+	revisions := make(map[string]snap.Revision, len(allStates))
+	for snapName, snapst := range allStates {
+		if !snapst.Active {
+			continue
+		}
+		if rev, ok := m.revisions[snapName]; ok && rev == snapst.Current {
+			// avoid reading the snap.yaml of unchanged snaps again
+			revisions[snapName] = rev
+			for name, ps := range m.probes {
+				if ps.probe.App.Snap.InstanceName() == snapName {
+					seen[name] = true
+				}
+			}
+			continue
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			logger.Debugf("cannot get health probes of snap %q: %v", snapName, err)
+			continue
+		}
+		revisions[snapName] = snapst.Current
+		for _, app := range info.Services() {
+			if app.HealthProbe == nil {
+				continue
+			}
+			name := info.InstanceName() + "." + app.Name
+			seen[name] = true
+			if ps := m.probes[name]; ps != nil {
+				if ps.revision == info.Revision {
+					continue
+				}
+				dropped(ps)
+			}
+			m.probes[name] = &probeStatus{
+				probe:    app.HealthProbe,
+				revision: info.Revision,
+				next:     now.Add(app.HealthProbe.EffectiveInterval()),
+			}
+		}
+	}
+	for name, ps := range m.probes {
+		if !seen[name] {
+			dropped(ps)
+			delete(m.probes, name)
+		}
+	}
+	m.revisions = revisions
+}
+
+// activeServices returns which of the services of the given probes are
+// running. Probes of services that are not running are not run.
+func activeServices(due []*probeStatus) map[*probeStatus]bool {
+	sysd := systemd.New(systemd.SystemMode, progress.Null)
+	units := make([]string, len(due))
+	for i, ps := range due {
+		units[i] = ps.probe.App.ServiceName()
+	}
+	active := make(map[*probeStatus]bool, len(due))
+	sts, err := sysd.Status(units)
+	if err != nil {
+		logger.Noticef("cannot get status of services with health probes: %v", err)
+		return active
+	}
+	for i, st := range sts {
+		active[due[i]] = st.Active
+	}
+	return active
+}
+
+// runDueProbes looks up the probes again if the snaps changed, runs the
+// probes that are due concurrently, and reports the changes in the health of
+// the snaps once they are all done.
+func (m *HealthManager) runDueProbes() {
+	now := timeNow()
+	changed := make(map[string]bool)
+	m.mu.Lock()
+	allStates := m.changedSnaps
+	m.changedSnaps = nil
+	m.mu.Unlock()
+	if allStates != nil {
+		m.refreshProbes(now, allStates, changed)
+	}
+
+	var due []*probeStatus
+	for _, ps := range m.probes {
+		if !ps.next.After(now) {
+			due = append(due, ps)
+		}
+	}
+	active := make(map[*probeStatus]bool)
+	if len(due) > 0 {
+		active = activeServices(due)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(due))
+	for i, ps := range due {
+		ps.next = now.Add(ps.probe.EffectiveInterval())
+		if !active[ps] {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, probe *snap.HealthProbeInfo) {
+			defer wg.Done()
+			errs[i] = runProbe(probe, &m.tomb)
+		}(i, ps.probe)
+	}
+	wg.Wait()
+
+	var restart []*snap.AppInfo
+	for i, ps := range due {
+		if !active[ps] {
+			// the failures of a service that was stopped
+			// do not count
+			ps.failures = 0
+			continue
+		}
+		snapName := ps.probe.App.Snap.InstanceName()
+		if errs[i] == nil {
+			ps.failures = 0
+			if ps.health != OkayStatus {
+				ps.health = OkayStatus
+				ps.err = nil
+				changed[snapName] = true
+			}
+			continue
+		}
+		ps.failures++
+		logger.Debugf("health probe of %s.%s failed (%d/%d): %v", snapName, ps.probe.App.Name, ps.failures, ps.probe.EffectiveThreshold(), errs[i])
+		if ps.failures < ps.probe.EffectiveThreshold() {
+			continue
+		}
+		ps.failures = 0
+		if ps.health != ErrorStatus || ps.err.Error() != errs[i].Error() {
+			ps.health = ErrorStatus
+			ps.err = errs[i]
+			changed[snapName] = true
+		}
+		if ps.probe.OnFailure == snap.HealthProbeActionRestart {
+			restart = append(restart, ps.probe.App)
+		}
+	}
+
+	if len(changed) == 0 && len(restart) == 0 {
+		return
+	}
+
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	for snapName := range changed {
+		if err := m.reportHealth(snapName, now); err != nil {
+			logger.Noticef("cannot update health of snap %q: %v", snapName, err)
+		}
+	}
+	for _, app := range restart {
+		if err := m.restartService(app); err != nil {
+			logger.Noticef("cannot restart service %q of snap %q after failed health probe: %v", app.Name, app.Snap.InstanceName(), err)
+		}
+	}
+}
+
+// reportHealth updates the health of the snap from the results of the
+// probes of its services. A failing probe overrides the health reported by
+// the snap, passing probes do not override a health reported by the snap
+// other than the unknown one.
+func (m *HealthManager) reportHealth(snapName string, now time.Time) error {
+	var names []string
+	for name, ps := range m.probes {
+		if ps.probe.App.Snap.InstanceName() == snapName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var failed, passed *probeStatus
+	for _, name := range names {
+		switch ps := m.probes[name]; ps.health {
+		case ErrorStatus:
+			if failed == nil {
+				failed = ps
+			}
+		case OkayStatus:
+			passed = ps
+		}
+	}
+
+	cur, err := Get(m.state, snapName)
+	if err != nil {
+		return err
+	}
+	setByProbes := cur != nil && cur.Code == probeFailedCode
+	switch {
+	case failed != nil:
+		return setHealth(m.state, snapName, &HealthState{
+			Revision:  failed.revision,
+			Timestamp: now,
+			Status:    ErrorStatus,
+			Code:      probeFailedCode,
+			Message:   fmt.Sprintf("health probe of app %q failed: %v", failed.probe.App.Name, failed.err),
+		})
+	case passed != nil:
+		if cur != nil && !setByProbes && cur.Status != UnknownStatus && cur.Revision == passed.revision {
+			return nil
+		}
+		return setHealth(m.state, snapName, &HealthState{
+			Revision:  passed.revision,
+			Timestamp: now,
+			Status:    OkayStatus,
+		})
+	case setByProbes:
+		// the failing probes are gone
+		return setHealth(m.state, snapName, &HealthState{
+			Revision:  cur.Revision,
+			Timestamp: now,
+			Status:    UnknownStatus,
+		})
+	}
+	return nil
+}
+
+func (m *HealthManager) restartService(app *snap.AppInfo) error {
+	name := app.Snap.InstanceName() + "." + app.Name
+	inst := &servicestate.Instruction{
+		Action: "restart",
+		Names:  []string{name},
+	}
+	tss, err := servicestateControl(m.state, []*snap.AppInfo{app}, inst, nil, nil)
+	if err != nil {
+		return err
+	}
+	chg := m.state.NewChange("restart", fmt.Sprintf("Restart service %q after failed health probe", name))
+	for _, ts := range tss {
+		chg.AddAll(ts)
+	}
+	m.state.EnsureBefore(0)
+	return nil
+}
+
+// probeAddress returns the network and address to connect to for the given
+// address of a probe, which follows the format of listen-stream.
+func probeAddress(app *snap.AppInfo, address string) (network, addr string) {
+	switch address[0] {
+	case '/', '$':
+		s := app.Snap
+		path := strings.Replace(address, "$SNAP_DATA", s.DataDir(), -1)
+		path = strings.Replace(path, "$SNAP_COMMON", s.CommonDataDir(), -1)
+		path = strings.Replace(path, "$XDG_RUNTIME_DIR", s.UserXdgRuntimeDir(sys.UserID(0)), -1)
+		return "unix", path
+	case '@':
+		return "unix", address
+	}
+	host, port := "127.0.0.1", address
+	if idx := strings.LastIndex(address, ":"); idx >= 0 {
+		host, port = address[:idx], address[idx+1:]
+	}
+	if host == "[::]" {
+		host = "[::1]"
+	}
+	return "tcp", host + ":" + port
+}
+
+func runProbeImpl(probe *snap.HealthProbeInfo, tomb *tomb.Tomb) error {
+	timeout := probe.EffectiveTimeout()
+	switch {
+	case probe.HTTP != nil:
+		network, addr := probeAddress(probe.App, probe.HTTP.Address)
+		host := addr
+		if network == "unix" {
+			host = "localhost"
+		}
+		client := &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, network, addr)
+				},
+			},
+		}
+		rsp, err := client.Get("http://" + host + probe.HTTP.Path)
+		if err != nil {
+			return err
+		}
+		rsp.Body.Close()
+		if rsp.StatusCode < 200 || rsp.StatusCode >= 400 {
+			return fmt.Errorf("unexpected status %q", rsp.Status)
+		}
+		return nil
+	case probe.TCP != "":
+		network, addr := probeAddress(probe.App, probe.TCP)
+		conn, err := net.DialTimeout(network, addr, timeout)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+		return nil
+	case probe.Command != "":
+		name := probe.App.Snap.InstanceName() + "." + probe.App.Name
+		argv := []string{hookstate.SnapCommand(), "run", "--command=health-probe", name}
+		output, err := osutil.RunAndWait(argv, nil, timeout, tomb)
+		if err != nil {
+			return osutil.OutputErr(output, err)
+		}
+		return nil
+	}
+	return errors.New("internal error: health probe has nothing to run")
+}
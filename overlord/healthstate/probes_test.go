@@ -0,0 +1,355 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type probesSuite struct {
+	testutil.BaseTest
+
+	state *state.State
+	mgr   *healthstate.HealthManager
+
+	now       time.Time
+	active    map[string]bool
+	probeErrs map[string]error
+	// probes run concurrently
+	probedMu  sync.Mutex
+	probed    []string
+	restarted [][]string
+}
+
+var _ = check.Suite(&probesSuite{})
+
+const probesYaml = `name: test-snap
+version: v1
+apps:
+  web:
+    daemon: simple
+    health-probe:
+      http:
+        address: 8080
+        path: /healthz
+      interval: 10s
+      timeout: 1s
+      threshold: 2
+  db:
+    daemon: simple
+    health-probe:
+      tcp: 5432
+      interval: 30s
+      on-failure: restart
+  other:
+    daemon: simple
+`
+
+func (s *probesSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+
+	s.state = overlord.Mock().State()
+	s.mgr = healthstate.Manager(s.state)
+
+	s.now = time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.AddCleanup(healthstate.MockTimeNow(func() time.Time { return s.now }))
+
+	s.active = map[string]bool{
+		"snap.test-snap.web.service": true,
+		"snap.test-snap.db.service":  true,
+	}
+	s.AddCleanup(systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		c.Assert(args[0], check.Equals, "show")
+		var out []string
+		for _, unit := range args[2:] {
+			activeState := "inactive"
+			if s.active[unit] {
+				activeState = "active"
+			}
+			out = append(out, fmt.Sprintf("Id=%s\nNames=%[1]s\nType=simple\nActiveState=%s\nUnitFileState=enabled\nNeedDaemonReload=no\n", unit, activeState))
+		}
+		return []byte(strings.Join(out, "\n")), nil
+	}))
+
+	s.probeErrs = make(map[string]error)
+	s.probed = nil
+	s.AddCleanup(healthstate.MockRunProbe(func(probe *snap.HealthProbeInfo, tomb *tomb.Tomb) error {
+		s.probedMu.Lock()
+		defer s.probedMu.Unlock()
+		s.probed = append(s.probed, probe.App.Name)
+		return s.probeErrs[probe.App.Name]
+	}))
+
+	s.restarted = nil
+	s.AddCleanup(healthstate.MockServicestateControl(func(st *state.State, appInfos []*snap.AppInfo, inst *servicestate.Instruction, flags *servicestate.Flags, context *hookstate.Context) ([]*state.TaskSet, error) {
+		c.Check(inst.Action, check.Equals, "restart")
+		c.Check(appInfos, check.HasLen, 1)
+		s.restarted = append(s.restarted, inst.Names)
+		return []*state.TaskSet{state.NewTaskSet(st.NewTask("service-control", "..."))}, nil
+	}))
+
+	s.state.Lock()
+	sideInfo := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(42)}
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{sideInfo},
+		Current:  snap.R(42),
+		Active:   true,
+		SnapType: "app",
+	})
+	snaptest.MockSnapCurrent(c, probesYaml, sideInfo)
+	s.state.Unlock()
+
+	// start tracking the probes, they run one interval later
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Assert(s.runProbesAfter(0), check.HasLen, 0)
+}
+
+func (s *probesSuite) runProbesAfter(d time.Duration) []string {
+	s.now = s.now.Add(d)
+	s.probed = nil
+	s.mgr.RunDueProbes()
+	sort.Strings(s.probed)
+	return s.probed
+}
+
+func (s *probesSuite) health(c *check.C) *healthstate.HealthState {
+	s.state.Lock()
+	defer s.state.Unlock()
+	health, err := healthstate.Get(s.state, "test-snap")
+	c.Assert(err, check.IsNil)
+	return health
+}
+
+func (s *probesSuite) TestProbesRunAtTheirInterval(c *check.C) {
+	c.Check(s.health(c), check.IsNil)
+
+	c.Check(s.runProbesAfter(10*time.Second), check.DeepEquals, []string{"web"})
+	c.Check(s.health(c), check.DeepEquals, &healthstate.HealthState{
+		Revision:  snap.R(42),
+		Timestamp: s.now,
+		Status:    healthstate.OkayStatus,
+	})
+	c.Check(s.runProbesAfter(5*time.Second), check.HasLen, 0)
+	c.Check(s.runProbesAfter(5*time.Second), check.DeepEquals, []string{"web"})
+	c.Check(s.runProbesAfter(10*time.Second), check.DeepEquals, []string{"db", "web"})
+
+	// probes of stopped services are not run
+	s.active["snap.test-snap.web.service"] = false
+	c.Check(s.runProbesAfter(10*time.Second), check.HasLen, 0)
+}
+
+func (s *probesSuite) TestProbeFailsAfterThreshold(c *check.C) {
+	s.runProbesAfter(10 * time.Second)
+	okay := s.health(c)
+	c.Check(okay.Status, check.Equals, healthstate.OkayStatus)
+
+	s.probeErrs["web"] = errors.New(`unexpected status "503 Service Unavailable"`)
+	s.runProbesAfter(10 * time.Second)
+	c.Check(s.health(c), check.DeepEquals, okay)
+
+	s.runProbesAfter(10 * time.Second)
+	c.Check(s.health(c), check.DeepEquals, &healthstate.HealthState{
+		Revision:  snap.R(42),
+		Timestamp: s.now,
+		Status:    healthstate.ErrorStatus,
+		Code:      "snapd-probe-failed",
+		Message:   `health probe of app "web" failed: unexpected status "503 Service Unavailable"`,
+	})
+	// the service is not restarted without on-failure: restart
+	c.Check(s.restarted, check.HasLen, 0)
+
+	delete(s.probeErrs, "web")
+	s.runProbesAfter(10 * time.Second)
+	c.Check(s.health(c), check.DeepEquals, &healthstate.HealthState{
+		Revision:  snap.R(42),
+		Timestamp: s.now,
+		Status:    healthstate.OkayStatus,
+	})
+}
+
+func (s *probesSuite) TestProbeFailureRestartsService(c *check.C) {
+	s.probeErrs["db"] = errors.New("connection refused")
+	for i := 0; i < 3; i++ {
+		s.runProbesAfter(30 * time.Second)
+	}
+	c.Check(s.restarted, check.DeepEquals, [][]string{{"test-snap.db"}})
+	c.Check(s.health(c).Message, check.Equals, `health probe of app "db" failed: connection refused`)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	chgs := s.state.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	c.Check(chgs[0].Kind(), check.Equals, "restart")
+	c.Check(chgs[0].Summary(), check.Equals, `Restart service "test-snap.db" after failed health probe`)
+}
+
+func (s *probesSuite) TestProbesDoNotOverrideReportedHealth(c *check.C) {
+	s.state.Lock()
+	reported := &healthstate.HealthState{
+		Revision:  snap.R(42),
+		Timestamp: s.now,
+		Status:    healthstate.WaitingStatus,
+		Message:   "still loading",
+	}
+	s.state.Set("health", map[string]*healthstate.HealthState{"test-snap": reported})
+	s.state.Unlock()
+
+	// passing probes keep the health reported by the snap
+	s.runProbesAfter(10 * time.Second)
+	c.Check(s.health(c), check.DeepEquals, reported)
+
+	// failing probes do not
+	s.probeErrs["web"] = errors.New("timeout")
+	s.runProbesAfter(10 * time.Second)
+	s.runProbesAfter(10 * time.Second)
+	c.Check(s.health(c).Status, check.Equals, healthstate.ErrorStatus)
+}
+
+func (s *probesSuite) TestFailedHealthClearedWhenProbeGoes(c *check.C) {
+	s.probeErrs["web"] = errors.New("timeout")
+	s.runProbesAfter(10 * time.Second)
+	s.runProbesAfter(10 * time.Second)
+	c.Check(s.health(c).Status, check.Equals, healthstate.ErrorStatus)
+
+	// the new revision has no probes
+	s.state.Lock()
+	sideInfo := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(43)}
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{{RealName: "test-snap", Revision: snap.R(42)}, sideInfo},
+		Current:  snap.R(43),
+		Active:   true,
+		SnapType: "app",
+	})
+	snaptest.MockSnap(c, "{name: test-snap, version: v2, apps: {web: {daemon: simple}}}", sideInfo)
+	s.state.Unlock()
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+
+	c.Check(s.runProbesAfter(10*time.Second), check.HasLen, 0)
+	c.Check(s.health(c), check.DeepEquals, &healthstate.HealthState{
+		Revision:  snap.R(42),
+		Timestamp: s.now,
+		Status:    healthstate.UnknownStatus,
+	})
+}
+
+func (s *probesSuite) TestProbesLookedUpOnlyWhenSnapsChange(c *check.C) {
+	// the snap.yaml is only read again for a new revision
+	sideInfo := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(42)}
+	snaptest.MockSnap(c, "{name: test-snap, version: v1, apps: {web: {daemon: simple}}}", sideInfo)
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Check(s.runProbesAfter(10*time.Second), check.DeepEquals, []string{"web"})
+
+	// the probes are kept until Ensure finds the snap changed
+	s.state.Lock()
+	snapstate.Set(s.state, "test-snap", nil)
+	s.state.Unlock()
+	c.Check(s.runProbesAfter(10*time.Second), check.DeepEquals, []string{"web"})
+
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Check(s.runProbesAfter(30*time.Second), check.HasLen, 0)
+}
+
+func (s *probesSuite) mockApp(c *check.C, probe string) *snap.AppInfo {
+	info := snaptest.MockInfo(c, "{name: test-snap, version: v1, apps: {svc: {daemon: simple, health-probe: "+probe+"}}}", &snap.SideInfo{Revision: snap.R(1)})
+	return info.Apps["svc"]
+}
+
+func (s *probesSuite) TestRunProbeHTTP(c *check.C) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/healthz")
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	app := s.mockApp(c, fmt.Sprintf("{http: {address: %q, path: /healthz}}", srv.Listener.Addr().String()))
+	c.Check(healthstate.RunProbe(app.HealthProbe, nil), check.IsNil)
+
+	status = http.StatusServiceUnavailable
+	c.Check(healthstate.RunProbe(app.HealthProbe, nil), check.ErrorMatches, `unexpected status "503 Service Unavailable"`)
+}
+
+func (s *probesSuite) TestRunProbeHTTPUnixSocket(c *check.C) {
+	app := s.mockApp(c, "{http: {address: $SNAP_DATA/api.socket, path: /}}")
+	sockPath := filepath.Join(app.Snap.DataDir(), "api.socket")
+	c.Assert(os.MkdirAll(filepath.Dir(sockPath), 0755), check.IsNil)
+	l, err := net.Listen("unix", sockPath)
+	c.Assert(err, check.IsNil)
+	srv := &httptest.Server{
+		Listener: l,
+		Config: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})},
+	}
+	srv.Start()
+	defer srv.Close()
+
+	c.Check(healthstate.RunProbe(app.HealthProbe, nil), check.IsNil)
+}
+
+func (s *probesSuite) TestRunProbeTCP(c *check.C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	addr := l.Addr().String()
+
+	app := s.mockApp(c, fmt.Sprintf("{tcp: %q}", addr))
+	c.Check(healthstate.RunProbe(app.HealthProbe, nil), check.IsNil)
+
+	l.Close()
+	c.Check(healthstate.RunProbe(app.HealthProbe, nil), check.ErrorMatches, ".*connection refused")
+}
+
+func (s *probesSuite) TestRunProbeCommand(c *check.C) {
+	cmd := testutil.MockCommand(c, "snap", `if [ "$4" = fail ]; then echo "not ready"; exit 1; fi`)
+	defer cmd.Restore()
+
+	app := s.mockApp(c, "{command: bin/check}")
+	c.Check(healthstate.RunProbe(app.HealthProbe, &tomb.Tomb{}), check.IsNil)
+	c.Check(cmd.Calls(), check.DeepEquals, [][]string{
+		{"snap", "run", "--command=health-probe", "test-snap.svc"},
+	})
+}
@@ -476,6 +476,12 @@ func snapCmd() string {
 	return filepath.Join(filepath.Dir(exe), "../../bin/snap")
 }
 
+// SnapCommand returns the "snap" command used to run hooks, which is also
+// suitable to run other commands of snaps.
+func SnapCommand() string {
+	return snapCmd()
+}
+
 var defaultHookTimeout = 120 * time.Minute
 
 func runHookAndWait(snapName string, revision snap.Revision, hookName, hookContext string, timeout time.Duration, tomb *tomb.Tomb) ([]byte, error) {
@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 )
@@ -38,6 +39,9 @@ func init() {
 	snapstate.SetupPostRefreshHook = SetupPostRefreshHook
 	snapstate.SetupRemoveHook = SetupRemoveHook
 	snapstate.SetupGateAutoRefreshHook = SetupGateAutoRefreshHook
+	snapshotstate.SetupPreSnapshotHook = SetupPreSnapshotHook
+	snapshotstate.SetupPostSnapshotHook = SetupPostSnapshotHook
+	snapshotstate.SetupPostRestoreHook = SetupPostRestoreHook
 }
 
 func SetupInstallHook(st *state.State, snapName string) *state.Task {
@@ -306,6 +310,47 @@ func SetupRemoveHook(st *state.State, snapName string) *state.Task {
 	return task
 }
 
+func postSnapshotHookSetup(snapName string) *HookSetup {
+	return &HookSetup{
+		Snap:        snapName,
+		Hook:        "post-snapshot",
+		Optional:    true,
+		IgnoreError: true,
+	}
+}
+
+func SetupPreSnapshotHook(st *state.State, snapName string) *state.Task {
+	hooksup := &HookSetup{
+		Snap:     snapName,
+		Hook:     "pre-snapshot",
+		Optional: true,
+	}
+	// if saving the snapshot fails, the snap still needs to resume
+	undo := postSnapshotHookSetup(snapName)
+
+	summary := fmt.Sprintf(i18n.G("Run pre-snapshot hook of %q snap if present"), hooksup.Snap)
+	return HookTaskWithUndo(st, summary, hooksup, undo, nil)
+}
+
+func SetupPostSnapshotHook(st *state.State, snapName string) *state.Task {
+	hooksup := postSnapshotHookSetup(snapName)
+
+	summary := fmt.Sprintf(i18n.G("Run post-snapshot hook of %q snap if present"), hooksup.Snap)
+	return HookTask(st, summary, hooksup, nil)
+}
+
+func SetupPostRestoreHook(st *state.State, snapName string) *state.Task {
+	hooksup := &HookSetup{
+		Snap:        snapName,
+		Hook:        "post-restore",
+		Optional:    true,
+		IgnoreError: true,
+	}
+
+	summary := fmt.Sprintf(i18n.G("Run post-restore hook of %q snap if present"), hooksup.Snap)
+	return HookTask(st, summary, hooksup, nil)
+}
+
 func setupHooks(hookMgr *HookManager) {
 	handlerGenerator := func(context *Context) Handler {
 		return &snapHookHandler{}
@@ -318,5 +363,8 @@ func setupHooks(hookMgr *HookManager) {
 	hookMgr.Register(regexp.MustCompile("^post-refresh$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^pre-refresh$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^remove$"), handlerGenerator)
+	hookMgr.Register(regexp.MustCompile("^pre-snapshot$"), handlerGenerator)
+	hookMgr.Register(regexp.MustCompile("^post-snapshot$"), handlerGenerator)
+	hookMgr.Register(regexp.MustCompile("^post-restore$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^gate-auto-refresh$"), gateAutoRefreshHandlerGenerator)
 }
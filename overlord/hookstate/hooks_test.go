@@ -519,3 +519,48 @@ func (s *gateAutoRefreshHookSuite) TestGateAutorefreshHookErrorHoldErrorLogged(c
 	c.Assert(err, IsNil)
 	c.Check(hint, Equals, runinhibit.HintNotInhibited)
 }
+
+type snapshotHooksSuite struct{}
+
+var _ = Suite(&snapshotHooksSuite{})
+
+func (s *snapshotHooksSuite) TestSetupSnapshotHooks(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	for _, tc := range []struct {
+		setup   func(*state.State, string) *state.Task
+		summary string
+		hooksup hookstate.HookSetup
+		undo    *hookstate.HookSetup
+	}{{
+		setup:   hookstate.SetupPreSnapshotHook,
+		summary: `Run pre-snapshot hook of "some-snap" snap if present`,
+		hooksup: hookstate.HookSetup{Snap: "some-snap", Hook: "pre-snapshot", Optional: true},
+		undo:    &hookstate.HookSetup{Snap: "some-snap", Hook: "post-snapshot", Optional: true, IgnoreError: true},
+	}, {
+		setup:   hookstate.SetupPostSnapshotHook,
+		summary: `Run post-snapshot hook of "some-snap" snap if present`,
+		hooksup: hookstate.HookSetup{Snap: "some-snap", Hook: "post-snapshot", Optional: true, IgnoreError: true},
+	}, {
+		setup:   hookstate.SetupPostRestoreHook,
+		summary: `Run post-restore hook of "some-snap" snap if present`,
+		hooksup: hookstate.HookSetup{Snap: "some-snap", Hook: "post-restore", Optional: true, IgnoreError: true},
+	}} {
+		task := tc.setup(st, "some-snap")
+		c.Check(task.Kind(), Equals, "run-hook")
+		c.Check(task.Summary(), Equals, tc.summary)
+		var hooksup hookstate.HookSetup
+		c.Assert(task.Get("hook-setup", &hooksup), IsNil)
+		c.Check(hooksup, DeepEquals, tc.hooksup)
+		var undo hookstate.HookSetup
+		err := task.Get("undo-hook-setup", &undo)
+		if tc.undo == nil {
+			c.Check(err, testutil.ErrorIs, state.ErrNoState)
+		} else {
+			c.Assert(err, IsNil)
+			c.Check(undo, DeepEquals, *tc.undo)
+		}
+	}
+}
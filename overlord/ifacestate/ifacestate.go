@@ -24,6 +24,7 @@ package ifacestate
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -33,9 +34,11 @@ import (
 	"github.com/snapcore/snapd/interfaces/policy"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
 )
 
 var connectRetryTimeout = time.Second * 5
@@ -522,6 +525,85 @@ func CheckInterfaces(st *state.State, snapInfo *snap.Info, deviceCtx snapstate.D
 	return ic.Check()
 }
 
+// AutoConnectCandidates returns the ids of the connections that would be
+// made automatically for the plugs and slots of the given revision of a snap
+// if it was linked, leaving out the existing and the undesired connections.
+// An asserts.NotFoundError is returned if the snap declaration of the snap is
+// not known yet, as the candidates of snaps from the store can only be found
+// with it.
+func AutoConnectCandidates(st *state.State, info *snap.Info) ([]string, error) {
+	if info.SnapID != "" {
+		if _, err := assertstate.SnapDeclaration(st, info.SnapID); err != nil {
+			return nil, err
+		}
+	}
+	// the implicit slots are added to a copy, the info is not changed
+	withImplicitSlots := *info
+	withImplicitSlots.Slots = make(map[string]*snap.SlotInfo, len(info.Slots))
+	for name, slot := range info.Slots {
+		withImplicitSlots.Slots[name] = slot
+	}
+	if err := addImplicitSlots(st, &withImplicitSlots); err != nil {
+		return nil, err
+	}
+	info = &withImplicitSlots
+
+	repo := ifacerepo.Get(st)
+	deviceCtx, err := snapstate.DeviceCtxFromState(st, nil)
+	if err != nil {
+		return nil, err
+	}
+	autochecker, err := newAutoConnectChecker(st, nil, repo, deviceCtx)
+	if err != nil {
+		return nil, err
+	}
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	addCandidate := func(plug *snap.PlugInfo, slot *snap.SlotInfo) {
+		iface := repo.Interface(plug.Interface)
+		if iface == nil || slot.Interface != plug.Interface {
+			return
+		}
+		id := interfaces.NewConnRef(plug, slot).ID()
+		if _, ok := conns[id]; ok || strutil.ListContains(candidates, id) {
+			return
+		}
+		ok, _, err := autochecker.check(interfaces.NewConnectedPlug(plug, nil, nil), interfaces.NewConnectedSlot(slot, nil, nil))
+		if !ok || err != nil {
+			return
+		}
+		if iface.AutoConnect(plug, slot) {
+			candidates = append(candidates, id)
+		}
+	}
+	// the plugs and slots of the installed revision, if any, are
+	// replaced by the ones of the given revision
+	instanceName := info.InstanceName()
+	for _, plug := range info.Plugs {
+		for _, slot := range repo.AllSlots(plug.Interface) {
+			if slot.Snap.InstanceName() != instanceName {
+				addCandidate(plug, slot)
+			}
+		}
+		for _, slot := range info.Slots {
+			addCandidate(plug, slot)
+		}
+	}
+	for _, slot := range info.Slots {
+		for _, plug := range repo.AllPlugs(slot.Interface) {
+			if plug.Snap.InstanceName() != instanceName {
+				addCandidate(plug, slot)
+			}
+		}
+	}
+	sort.Strings(candidates)
+	return candidates, nil
+}
+
 var once sync.Once
 
 func delayedCrossMgrInit() {
@@ -9305,3 +9305,83 @@ func (s *interfaceManagerSuite) TestOnSnapLinkageChanged(c *C) {
 		Sequence: []*snap.SideInfo{&info.SideInfo},
 	})
 }
+
+func (s *interfaceManagerSuite) TestAutoConnectCandidates(c *C) {
+	s.MockModel(c, nil)
+	coreInfo := s.mockSnap(c, ubuntuCoreSnapYaml)
+	snapInfo := s.mockSnap(c, sampleSnapYaml)
+	_ = s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	candidates, err := ifacestate.AutoConnectCandidates(s.state, snapInfo)
+	c.Assert(err, IsNil)
+	c.Check(candidates, DeepEquals, []string{"snap:network ubuntu-core:network"})
+
+	// the core snap provides the slot of the candidate
+	candidates, err = ifacestate.AutoConnectCandidates(s.state, coreInfo)
+	c.Assert(err, IsNil)
+	c.Check(candidates, DeepEquals, []string{"snap:network ubuntu-core:network"})
+
+	// undesired connections are left out
+	s.state.Set("conns", map[string]interface{}{
+		"snap:network ubuntu-core:network": map[string]interface{}{"interface": "network", "auto": true, "undesired": true},
+	})
+	candidates, err = ifacestate.AutoConnectCandidates(s.state, snapInfo)
+	c.Assert(err, IsNil)
+	c.Check(candidates, HasLen, 0)
+}
+
+func (s *interfaceManagerSuite) TestAutoConnectCandidatesNewRevision(c *C) {
+	s.MockModel(c, nil)
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	s.mockSnap(c, sampleSnapYaml)
+	_ = s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// the plugs of the new revision are used instead of the
+	// installed ones
+	newInfo := snaptest.MockInfo(c, `
+name: snap
+version: 2
+plugs:
+ home:
+`, &snap.SideInfo{Revision: snap.R(2)})
+	candidates, err := ifacestate.AutoConnectCandidates(s.state, newInfo)
+	c.Assert(err, IsNil)
+	c.Check(candidates, DeepEquals, []string{"snap:home ubuntu-core:home"})
+
+	// and a snap that is not installed
+	otherInfo := snaptest.MockInfo(c, `
+name: other
+version: 1
+plugs:
+ network:
+`, &snap.SideInfo{Revision: snap.R(1)})
+	candidates, err = ifacestate.AutoConnectCandidates(s.state, otherInfo)
+	c.Assert(err, IsNil)
+	c.Check(candidates, DeepEquals, []string{"other:network ubuntu-core:network"})
+}
+
+func (s *interfaceManagerSuite) TestAutoConnectCandidatesNoSnapDeclaration(c *C) {
+	s.MockModel(c, nil)
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	_ = s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// the snap declaration of a snap from the store is only known once
+	// the snap is downloaded
+	info := snaptest.MockInfo(c, `
+name: other
+version: 1
+plugs:
+ network:
+`, &snap.SideInfo{Revision: snap.R(1), SnapID: "other-id"})
+	_, err := ifacestate.AutoConnectCandidates(s.state, info)
+	c.Check(errors.Is(err, &asserts.NotFoundError{}), Equals, true)
+}
@@ -0,0 +1,248 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package overlord
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/statejournal"
+)
+
+var (
+	// the journal is compacted once it is larger than both
+	// journalCompactMinSize and journalCompactRatio times the size of
+	// the state it starts with
+	journalCompactMinSize int64 = 1024 * 1024
+	journalCompactRatio   int64 = 4
+)
+
+// journaledStateBackend persists the state as an append-only journal of
+// the entries of the state that changed with every checkpoint, instead of
+// rewriting the whole state, see the statejournal package for its format.
+//
+// The journal is compacted into such a single
+// record by rewriting it atomically once it grows too large. Records are
+// synced to disk before a checkpoint completes, a record that is
+// incomplete or corrupted because of a crash is dropped when the journal
+// is read, together with any records following it.
+//
+// The state file is written along with every record holding the whole
+// state and kept, for the tools and the older snapd versions reading it. The
+// record holds the checksum of the state file, a state file that does not
+// match it was written since by an older snapd and holds the state instead.
+type journaledStateBackend struct {
+	path string
+	// statePath is the path of the state file kept along the journal
+	statePath    string
+	ensureBefore func(d time.Duration)
+
+	// entries holds the entries of the state as last persisted, by key;
+	// it is nil until the journal was read or written
+	entries map[string]json.RawMessage
+	// size is the size of the journal, fullSize the size of the record
+	// with the whole state it starts with
+	size     int64
+	fullSize int64
+	// stateFileSum is the checksum of the state file written with the
+	// record holding the whole state
+	stateFileSum string
+}
+
+// Checkpoint appends a record with the entries of the state that changed
+// since the last checkpoint. The state is still marshalled in whole by the
+// caller and its entries compared to the ones last persisted, what the
+// journal saves is the writing and syncing of the whole state to disk.
+func (jsb *journaledStateBackend) Checkpoint(data []byte) error {
+	entries, err := statejournal.SplitState(data)
+	if err != nil {
+		return err
+	}
+	if jsb.entries == nil || (jsb.size > journalCompactMinSize && jsb.size > journalCompactRatio*jsb.fullSize) {
+		return jsb.writeFull(data, entries)
+	}
+
+	rec := &statejournal.Record{Set: make(map[string]json.RawMessage)}
+	for key, entry := range entries {
+		if old, ok := jsb.entries[key]; !ok || !bytes.Equal(old, entry) {
+			rec.Set[key] = entry
+		}
+	}
+	for key := range jsb.entries {
+		if _, ok := entries[key]; !ok {
+			rec.Delete = append(rec.Delete, key)
+		}
+	}
+	if len(rec.Set) == 0 && len(rec.Delete) == 0 {
+		return nil
+	}
+	sort.Strings(rec.Delete)
+
+	line, err := statejournal.EncodeRecord(rec)
+	if err != nil {
+		return err
+	}
+	if err := appendAndSync(jsb.path, line); err != nil {
+		// the journal may end with an incomplete record now, make
+		// the next checkpoint rewrite it
+		jsb.entries = nil
+		return err
+	}
+	jsb.entries = entries
+	jsb.size += int64(len(line))
+	return nil
+}
+
+func (jsb *journaledStateBackend) EnsureBefore(d time.Duration) {
+	jsb.ensureBefore(d)
+}
+
+// compact replaces the journal with a record holding the whole state,
+// writing the state file along.
+func (jsb *journaledStateBackend) compact(data []byte) error {
+	entries, err := statejournal.SplitState(data)
+	if err != nil {
+		return err
+	}
+	return jsb.writeFull(data, entries)
+}
+
+// writeFull writes the state file and atomically replaces the journal with
+// one holding only a record with the whole state. The state file is written
+// first, so that it is found to be newer than the journal if writing the
+// journal fails.
+func (jsb *journaledStateBackend) writeFull(data []byte, entries map[string]json.RawMessage) error {
+	var sum string
+	if jsb.statePath != "" {
+		if err := osutil.AtomicWriteFile(jsb.statePath, data, 0600, 0); err != nil {
+			return err
+		}
+		sum = statejournal.StateFileSum(data)
+	}
+	line, err := statejournal.EncodeRecord(&statejournal.Record{Full: true, Set: entries, StateFileSum: sum})
+	if err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(jsb.path, line, 0600, 0); err != nil {
+		return err
+	}
+	jsb.entries = entries
+	jsb.size = int64(len(line))
+	jsb.fullSize = jsb.size
+	jsb.stateFileSum = sum
+	return nil
+}
+
+// open returns the serialized state, read from the journal unless the state
+// file was written since by an older snapd or the journal is corrupted, in
+// which case it is read from the state file and the journal is rewritten by
+// the next checkpoint.
+func (jsb *journaledStateBackend) open() ([]byte, error) {
+	data, err := jsb.readState()
+	var corrupted *statejournal.CorruptedError
+	if errors.As(err, &corrupted) && jsb.statePath != "" {
+		stateData, readErr := ioutil.ReadFile(jsb.statePath)
+		if readErr == nil {
+			logger.Noticef("%v, reading the state from the state file", err)
+			jsb.entries = nil
+			return stateData, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if jsb.statePath == "" {
+		return data, nil
+	}
+	stateData, err := ioutil.ReadFile(jsb.statePath)
+	if os.IsNotExist(err) {
+		// write the state file again with the next checkpoint
+		jsb.entries = nil
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the state file: %v", err)
+	}
+	if statejournal.StateFileSum(stateData) != jsb.stateFileSum {
+		logger.Noticef("State file changed since the state journal was written, reading the state from it")
+		jsb.entries = nil
+		return stateData, nil
+	}
+	return data, nil
+}
+
+// readState reads the state from the journal. Records that cannot be read
+// at the end of the journal, left by a crash while appending to it, are
+// dropped.
+func (jsb *journaledStateBackend) readState() ([]byte, error) {
+	f, err := os.OpenFile(jsb.path, os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the state journal: %v", err)
+	}
+	defer f.Close()
+
+	j, err := statejournal.Read(f)
+	if err != nil {
+		return nil, err
+	}
+	if j.Dropped != nil {
+		logger.Noticef("dropping state journal records from offset %d: %v", j.Size, j.Dropped)
+		if err := f.Truncate(j.Size); err != nil {
+			return nil, fmt.Errorf("cannot drop incomplete state journal records: %v", err)
+		}
+		if err := f.Sync(); err != nil {
+			return nil, fmt.Errorf("cannot drop incomplete state journal records: %v", err)
+		}
+	}
+
+	data, err := statejournal.JoinState(j.Entries)
+	if err != nil {
+		return nil, err
+	}
+	jsb.entries = j.Entries
+	jsb.size = j.Size
+	jsb.fullSize = j.FullSize
+	jsb.stateFileSum = j.StateFileSum
+	return data, nil
+}
+
+func appendAndSync(path string, line []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,478 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package overlord_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/patch"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snapdtool"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type journalSuite struct {
+	testutil.BaseTest
+
+	path string
+}
+
+var _ = Suite(&journalSuite{})
+
+func (s *journalSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.path = filepath.Join(c.MkDir(), "state.journal")
+}
+
+func (s *journalSuite) journalLines(c *C) []string {
+	data, err := ioutil.ReadFile(s.path)
+	c.Assert(err, IsNil)
+	return strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// readBack reads the state from the journal with a new backend.
+func (s *journalSuite) readBack(c *C) *state.State {
+	data, err := overlord.NewJournaledStateBackend(s.path, "").ReadState()
+	c.Assert(err, IsNil)
+	st, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	return st
+}
+
+// marshalState returns the serialized state as read back from its
+// serialization, for comparison with the state read from the journal.
+func marshalState(c *C, st *state.State) map[string]interface{} {
+	st.Lock()
+	data, err := st.MarshalJSON()
+	st.Unlock()
+	c.Assert(err, IsNil)
+	st, err = state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	st.Lock()
+	data, err = st.MarshalJSON()
+	st.Unlock()
+	c.Assert(err, IsNil)
+	var m map[string]interface{}
+	c.Assert(json.Unmarshal(data, &m), IsNil)
+	for _, name := range []string{"data", "changes", "tasks"} {
+		// empty collections may be serialized as null
+		if m[name] == nil {
+			m[name] = map[string]interface{}{}
+		}
+	}
+	return m
+}
+
+func (s *journalSuite) TestCheckpointAppendsChangedEntries(c *C) {
+	st := state.New(overlord.NewJournaledStateBackend(s.path, ""))
+	st.Lock()
+	st.Set("big", strings.Repeat("x", 1000))
+	st.Set("counter", 1)
+	st.Unlock()
+
+	lines := s.journalLines(c)
+	c.Assert(lines, HasLen, 1)
+	c.Check(lines[0], testutil.Contains, `"full":true`)
+
+	st.Lock()
+	st.Set("counter", 2)
+	chg := st.NewChange("install", "Install a snap")
+	t := st.NewTask("download", "Download a snap")
+	chg.AddTask(t)
+	st.Warnf("hello")
+	st.Unlock()
+
+	lines = s.journalLines(c)
+	c.Assert(lines, HasLen, 2)
+	// only the entries that changed are journaled
+	c.Check(lines[1], Not(testutil.Contains), "xxx")
+	c.Check(lines[1], testutil.Contains, `"data/counter":2`)
+	c.Check(lines[1], testutil.Contains, fmt.Sprintf(`"changes/%s":`, chg.ID()))
+	c.Check(lines[1], testutil.Contains, fmt.Sprintf(`"tasks/%s":`, t.ID()))
+	c.Check(lines[1], testutil.Contains, `"warnings/hello":`)
+
+	st.Lock()
+	st.Set("big", nil)
+	st.Unlock()
+
+	lines = s.journalLines(c)
+	c.Assert(lines, HasLen, 3)
+	c.Check(lines[2], testutil.Contains, `"delete":["data/big"]`)
+
+	// unlocking without modifications does not write anything
+	st.Lock()
+	st.Unlock()
+	c.Check(s.journalLines(c), HasLen, 3)
+
+	c.Check(marshalState(c, s.readBack(c)), DeepEquals, marshalState(c, st))
+}
+
+func (s *journalSuite) TestReadDropsIncompleteRecords(c *C) {
+	st := state.New(overlord.NewJournaledStateBackend(s.path, ""))
+	st.Lock()
+	st.Set("counter", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("counter", 2)
+	st.Unlock()
+	expected := marshalState(c, st)
+	good, err := ioutil.ReadFile(s.path)
+	c.Assert(err, IsNil)
+
+	for _, garbage := range []string{
+		// torn write
+		`0123abcd {"set":{"data/coun`,
+		// corrupted record
+		`00000000 {"set":{"data/counter":3}}` + "\n",
+	} {
+		c.Assert(ioutil.WriteFile(s.path, append(append([]byte(nil), good...), garbage...), 0600), IsNil)
+
+		c.Check(marshalState(c, s.readBack(c)), DeepEquals, expected)
+		// and the journal was truncated to the complete records
+		c.Check(s.path, testutil.FileEquals, string(good))
+	}
+}
+
+func (s *journalSuite) TestReadErrors(c *C) {
+	jsb := overlord.NewJournaledStateBackend(s.path, "")
+	_, err := jsb.ReadState()
+	c.Check(err, ErrorMatches, "cannot read the state journal: .* no such file or directory")
+
+	c.Assert(ioutil.WriteFile(s.path, []byte("garbage"), 0600), IsNil)
+	_, err = jsb.ReadState()
+	c.Check(err, ErrorMatches, "cannot read the state journal: journal is empty or corrupted")
+
+	rec := `{"set":{"data/a":1}}`
+	c.Assert(ioutil.WriteFile(s.path, []byte(fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE([]byte(rec)), rec)), 0600), IsNil)
+	_, err = jsb.ReadState()
+	c.Check(err, ErrorMatches, "cannot read the state journal: journal does not start with the whole state")
+}
+
+func (s *journalSuite) TestCompaction(c *C) {
+	defer overlord.MockJournalCompactMinSize(0)()
+
+	st := state.New(overlord.NewJournaledStateBackend(s.path, ""))
+	for i := 0; i < 20; i++ {
+		st.Lock()
+		st.Set("counter", i)
+		st.Set(fmt.Sprintf("key-%d", i), strings.Repeat("x", 100))
+		st.Unlock()
+	}
+	// the journal was compacted along the way
+	lines := s.journalLines(c)
+	c.Check(len(lines) < 20, Equals, true, Commentf("%d records", len(lines)))
+	c.Check(lines[0], testutil.Contains, `"full":true`)
+
+	c.Check(marshalState(c, s.readBack(c)), DeepEquals, marshalState(c, st))
+}
+
+func (s *journalSuite) TestStateFileWrittenWithWholeState(c *C) {
+	defer overlord.MockJournalCompactMinSize(0)()
+	statePath := filepath.Join(filepath.Dir(s.path), "state.json")
+
+	st := state.New(overlord.NewJournaledStateBackend(s.path, statePath))
+	st.Lock()
+	st.Set("counter", 1)
+	st.Unlock()
+	c.Check(statePath, testutil.FileContains, `"counter":1`)
+
+	// not rewritten with the records appended to the journal
+	st.Lock()
+	st.Set("counter", 2)
+	st.Unlock()
+	c.Assert(s.journalLines(c), HasLen, 2)
+	c.Check(statePath, testutil.FileContains, `"counter":1`)
+
+	// but with the journal compacted
+	for i := 0; i < 20; i++ {
+		st.Lock()
+		st.Set("counter", i)
+		st.Set(fmt.Sprintf("key-%d", i), strings.Repeat("x", 100))
+		st.Unlock()
+	}
+	c.Check(statePath, testutil.FileContains, `"key-1":`)
+
+	data, err := overlord.NewJournaledStateBackend(s.path, statePath).Open()
+	c.Assert(err, IsNil)
+	c.Check(string(data), testutil.Contains, `"counter":19`)
+}
+
+func (s *journalSuite) TestOpenReadsStateFileWrittenSince(c *C) {
+	statePath := filepath.Join(filepath.Dir(s.path), "state.json")
+
+	st := state.New(overlord.NewJournaledStateBackend(s.path, statePath))
+	st.Lock()
+	st.Set("counter", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("counter", 2)
+	st.Unlock()
+
+	// the state file is written by an older snapd
+	newer := []byte(`{"data":{"counter":3},"changes":{},"tasks":{},"last-change-id":0,"last-task-id":0,"last-lane-id":0}`)
+	c.Assert(ioutil.WriteFile(statePath, newer, 0600), IsNil)
+
+	jsb := overlord.NewJournaledStateBackend(s.path, statePath)
+	data, err := jsb.Open()
+	c.Assert(err, IsNil)
+	c.Check(data, DeepEquals, newer)
+
+	// and the journal is rewritten from it with the next checkpoint
+	st, err = state.ReadState(jsb, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	st.Lock()
+	st.Set("counter", 4)
+	st.Unlock()
+	lines := s.journalLines(c)
+	c.Assert(lines, HasLen, 1)
+	c.Check(lines[0], testutil.Contains, `"data/counter":4`)
+	c.Check(statePath, testutil.FileContains, `"counter":4`)
+
+	// a missing state file is written again
+	c.Assert(os.Remove(statePath), IsNil)
+	jsb = overlord.NewJournaledStateBackend(s.path, statePath)
+	data, err = jsb.Open()
+	c.Assert(err, IsNil)
+	st, err = state.ReadState(jsb, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	st.Lock()
+	st.Set("counter", 5)
+	st.Unlock()
+	c.Check(statePath, testutil.FileContains, `"counter":5`)
+}
+
+func (s *journalSuite) TestContinueJournalAfterRead(c *C) {
+	st := state.New(overlord.NewJournaledStateBackend(s.path, ""))
+	st.Lock()
+	st.Set("counter", 1)
+	st.Unlock()
+
+	jsb := overlord.NewJournaledStateBackend(s.path, "")
+	data, err := jsb.ReadState()
+	c.Assert(err, IsNil)
+	st, err = state.ReadState(jsb, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	st.Lock()
+	st.Set("counter", 2)
+	st.Unlock()
+
+	lines := s.journalLines(c)
+	c.Assert(lines, HasLen, 2)
+	c.Check(lines[1], testutil.Contains, `"data/counter":2`)
+	c.Check(marshalState(c, s.readBack(c)), DeepEquals, marshalState(c, st))
+}
+
+func (ovs *overlordSuite) TestNewMigratesStateToJournalAndBack(c *C) {
+	fakeState := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"patch-sublevel":%d,"patch-sublevel-last-version":%q,"some":"data","refresh-privacy-key":"0123456789ABCDEF"},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`, patch.Level, patch.Sublevel, snapdtool.Version))
+	c.Assert(ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600), IsNil)
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateJournalFile), 0755), IsNil)
+
+	getSome := func(o *overlord.Overlord) string {
+		st := o.State()
+		st.Lock()
+		defer st.Unlock()
+		var some string
+		c.Assert(st.Get("some", &some), IsNil)
+		return some
+	}
+
+	restore := overlord.MockStateJournalEnabled(true)
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	c.Check(getSome(o), Equals, "data")
+	st := o.State()
+	st.Lock()
+	st.Set("some", "more data")
+	st.Unlock()
+	c.Assert(o.Stop(), IsNil)
+	restore()
+
+	c.Check(dirs.SnapStateJournalFile, testutil.FilePresent)
+	// the state file is kept up to date when stopping
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"some":"more data"`)
+
+	// the journal is read back into the state file once disabled
+	o, err = overlord.New(nil)
+	c.Assert(err, IsNil)
+	c.Check(getSome(o), Equals, "more data")
+	c.Assert(o.Stop(), IsNil)
+
+	c.Check(dirs.SnapStateJournalFile, testutil.FileAbsent)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"some":"more data"`)
+}
+
+func (ovs *overlordSuite) TestNewJournalDowngradeAndUpgrade(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateJournalFile), 0755), IsNil)
+
+	setSome := func(o *overlord.Overlord, some string) {
+		st := o.State()
+		st.Lock()
+		defer st.Unlock()
+		st.Set("some", some)
+	}
+	getSome := func(o *overlord.Overlord) string {
+		st := o.State()
+		st.Lock()
+		defer st.Unlock()
+		var some string
+		c.Assert(st.Get("some", &some), IsNil)
+		return some
+	}
+
+	defer overlord.MockStateJournalEnabled(true)()
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	setSome(o, "journaled")
+	c.Assert(o.Stop(), IsNil)
+
+	// an older snapd, unaware of the journal, reads and writes the
+	// state file only
+	f, err := os.Open(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	st, err := state.ReadState(nil, f)
+	f.Close()
+	c.Assert(err, IsNil)
+	st.Lock()
+	var some string
+	c.Assert(st.Get("some", &some), IsNil)
+	c.Check(some, Equals, "journaled")
+	st.Set("some", "written by older snapd")
+	data, err := st.MarshalJSON()
+	st.Unlock()
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapStateFile, data, 0600), IsNil)
+
+	// the state written by the older snapd is used after upgrading
+	// again
+	o, err = overlord.New(nil)
+	c.Assert(err, IsNil)
+	c.Check(getSome(o), Equals, "written by older snapd")
+	setSome(o, "journaled again")
+	c.Assert(o.Stop(), IsNil)
+
+	o, err = overlord.New(nil)
+	c.Assert(err, IsNil)
+	c.Check(getSome(o), Equals, "journaled again")
+	c.Assert(o.Stop(), IsNil)
+}
+
+func (ovs *overlordSuite) TestNewKeepsStateFileNewerThanJournal(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateJournalFile), 0755), IsNil)
+
+	restore := overlord.MockStateJournalEnabled(true)
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	st := o.State()
+	st.Lock()
+	st.Set("some", "journaled")
+	st.Unlock()
+	c.Assert(o.Stop(), IsNil)
+	restore()
+	c.Check(dirs.SnapStateJournalFile, testutil.FilePresent)
+
+	// snapd is reverted, an older snapd writes the state file and
+	// leaves the journal behind
+	f, err := os.Open(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	st, err = state.ReadState(nil, f)
+	f.Close()
+	c.Assert(err, IsNil)
+	st.Lock()
+	st.Set("some", "written by older snapd")
+	data, err := st.MarshalJSON()
+	st.Unlock()
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapStateFile, data, 0600), IsNil)
+
+	// the newer state file is kept and the stale journal removed
+	o, err = overlord.New(nil)
+	c.Assert(err, IsNil)
+	st = o.State()
+	st.Lock()
+	var some string
+	err = st.Get("some", &some)
+	st.Unlock()
+	c.Assert(err, IsNil)
+	c.Check(some, Equals, "written by older snapd")
+	c.Assert(o.Stop(), IsNil)
+
+	c.Check(dirs.SnapStateJournalFile, testutil.FileAbsent)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"some":"written by older snapd"`)
+}
+
+func (ovs *overlordSuite) TestNewCorruptedJournalFallsBackToStateFile(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateJournalFile), 0755), IsNil)
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	for _, enabled := range []bool{true, false} {
+		restore := overlord.MockStateJournalEnabled(true)
+		o, err := overlord.New(nil)
+		c.Assert(err, IsNil)
+		st := o.State()
+		st.Lock()
+		st.Set("some", "journaled")
+		st.Unlock()
+		c.Assert(o.Stop(), IsNil)
+		restore()
+
+		// the record holding the whole state is corrupted
+		c.Assert(ioutil.WriteFile(dirs.SnapStateJournalFile, []byte("00000000 garbage\n"), 0600), IsNil)
+
+		restore = overlord.MockStateJournalEnabled(enabled)
+		o, err = overlord.New(nil)
+		restore()
+		c.Assert(err, IsNil)
+		st = o.State()
+		st.Lock()
+		var some string
+		err = st.Get("some", &some)
+		st.Set("more", "state")
+		st.Unlock()
+		c.Assert(err, IsNil)
+		c.Check(some, Equals, "journaled")
+		c.Assert(o.Stop(), IsNil)
+
+		if enabled {
+			// the journal is rewritten from the state file
+			c.Check(dirs.SnapStateJournalFile, testutil.FileContains, `"full":true`)
+			c.Check(dirs.SnapStateJournalFile, testutil.FileContains, `"data/more":"state"`)
+		} else {
+			c.Check(dirs.SnapStateJournalFile, testutil.FileAbsent)
+		}
+		c.Check(dirs.SnapStateFile, testutil.FileContains, `"more":"state"`)
+		c.Check(logbuf.String(), testutil.Contains, "cannot read the state journal: journal is empty or corrupted")
+
+		c.Assert(os.RemoveAll(dirs.SnapStateJournalFile), IsNil)
+		c.Assert(os.RemoveAll(dirs.SnapStateFile), IsNil)
+	}
+}
@@ -23,8 +23,12 @@
 package overlord
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -36,11 +40,14 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/changehistory"
 	"github.com/snapcore/snapd/overlord/cmdstate"
 	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/configstate/proxyconf"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/healthstate"
@@ -55,9 +62,11 @@ import (
 	// import to register linkNotify callback
 	_ "github.com/snapcore/snapd/overlord/snapstate/agentnotify"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/statejournal"
 	"github.com/snapcore/snapd/overlord/storecontext"
 	"github.com/snapcore/snapd/snapdenv"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/localstore"
 	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/timings"
 )
@@ -87,6 +96,9 @@ var pruneTickerC = func(t *time.Ticker) <-chan time.Time {
 // track of all available state managers and related helpers.
 type Overlord struct {
 	stateFLock *osutil.FileLock
+	// stateJournal is the backend of the state when persisted to a
+	// journal
+	stateJournal *journaledStateBackend
 
 	stateEng *StateEngine
 	// ensure loop
@@ -125,10 +137,18 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 		inited: true,
 	}
 
-	backend := &overlordStateBackend{
+	var backend state.Backend = &overlordStateBackend{
 		path:         dirs.SnapStateFile,
 		ensureBefore: o.ensureBefore,
 	}
+	if stateJournalEnabled() {
+		o.stateJournal = &journaledStateBackend{
+			path:         dirs.SnapStateJournalFile,
+			statePath:    dirs.SnapStateFile,
+			ensureBefore: o.ensureBefore,
+		}
+		backend = o.stateJournal
+	}
 	s, restartMgr, err := o.loadState(backend, restartHandler)
 	if err != nil {
 		return nil, err
@@ -137,6 +157,9 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 	o.stateEng = NewStateEngine(s)
 	o.runner = state.NewTaskRunner(s)
 
+	// archive the pruned changes
+	changehistory.Init(s)
+
 	// any unknown task should be ignored and succeed
 	matchAnyUnknownTask := func(_ *state.Task) bool {
 		return true
@@ -185,6 +208,7 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 		return nil, err
 	}
 	healthstate.Init(hookMgr)
+	o.addManager(healthstate.Manager(s))
 
 	// the shared task runner should be added last!
 	o.stateEng.AddManager(o.runner)
@@ -193,8 +217,14 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 	defer s.Unlock()
 	// setting up the store
 	o.proxyConf = proxyconf.New(s).Conf
-	storeCtx := storecontext.New(s, o.deviceMgr.StoreContextBackend())
-	sto := o.newStoreWithContext(storeCtx)
+	var sto snapstate.StoreService
+	if dir := localStoreDir(s); dir != "" {
+		logger.Noticef("using the local store at %s", dir)
+		sto = localstore.New(dir)
+	} else {
+		storeCtx := storecontext.New(s, o.deviceMgr.StoreContextBackend())
+		sto = o.newStoreWithContext(storeCtx)
+	}
 
 	snapstate.ReplaceStore(s, sto)
 
@@ -283,7 +313,11 @@ func (o *Overlord) loadState(backend state.Backend, restartHandler restart.Handl
 
 	perfTimings := timings.New(map[string]string{"startup": "load-state"})
 
-	if !osutil.FileExists(dirs.SnapStateFile) {
+	r, err := openState(backend)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r == nil {
 		// fail fast, mostly interesting for tests, this dir is setup
 		// by the snapd package
 		stateDir := filepath.Dir(dirs.SnapStateFile)
@@ -298,11 +332,6 @@ func (o *Overlord) loadState(backend state.Backend, restartHandler restart.Handl
 		patch.Init(s)
 		return s, restartMgr, nil
 	}
-
-	r, err := os.Open(dirs.SnapStateFile)
-	if err != nil {
-		return nil, nil, fmt.Errorf("cannot read the state file: %s", err)
-	}
 	defer r.Close()
 
 	var s *state.State
@@ -329,6 +358,70 @@ func (o *Overlord) loadState(backend state.Backend, restartHandler restart.Handl
 	return s, restartMgr, nil
 }
 
+// stateJournalEnabled returns whether the state is persisted to a journal of
+// its changes instead of being rewritten as a whole on every checkpoint, as
+// set with the experimental.state-journal option. The option takes effect
+// when snapd is restarted.
+var stateJournalEnabled = func() bool {
+	return features.StateJournal.IsEnabled()
+}
+
+// openState returns a reader of the serialized state for the backend, or nil
+// if there is no state yet. The state is migrated from the state file to the
+// journal by the first checkpoint of the journaled backend, and back from
+// the journal to the state file when the journal is no longer used, unless
+// the state file was written since the journal.
+func openState(backend state.Backend) (io.ReadCloser, error) {
+	if jsb, ok := backend.(*journaledStateBackend); ok && osutil.FileExists(jsb.path) {
+		data, err := jsb.open()
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	if _, ok := backend.(*overlordStateBackend); ok && osutil.FileExists(dirs.SnapStateJournalFile) {
+		jsb := &journaledStateBackend{path: dirs.SnapStateJournalFile}
+		data, err := jsb.readState()
+		var corrupted *statejournal.CorruptedError
+		if errors.As(err, &corrupted) && osutil.FileExists(dirs.SnapStateFile) {
+			logger.Noticef("%v, dropping the state journal", err)
+			if err := os.Remove(dirs.SnapStateJournalFile); err != nil {
+				return nil, fmt.Errorf("cannot remove the corrupted state journal: %v", err)
+			}
+			return openState(backend)
+		}
+		if err != nil {
+			return nil, err
+		}
+		stateData, err := ioutil.ReadFile(dirs.SnapStateFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("cannot read the state file: %v", err)
+		}
+		if err == nil && statejournal.StateFileSum(stateData) != jsb.stateFileSum {
+			// the state file was written since, by an older snapd
+			logger.Noticef("State file changed since the state journal was written, dropping the state journal")
+			data = stateData
+		} else {
+			logger.Noticef("Migrating the state journal back to the state file")
+			if err := osutil.AtomicWriteFile(dirs.SnapStateFile, data, 0600, 0); err != nil {
+				return nil, fmt.Errorf("cannot migrate the state journal to the state file: %v", err)
+			}
+		}
+		if err := os.Remove(dirs.SnapStateJournalFile); err != nil {
+			return nil, fmt.Errorf("cannot remove the state journal migrated to the state file: %v", err)
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	if !osutil.FileExists(dirs.SnapStateFile) {
+		return nil, nil
+	}
+	r, err := os.Open(dirs.SnapStateFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the state file: %s", err)
+	}
+	return r, nil
+}
+
 func initRestart(s *state.State, curBootID string, restartHandler restart.Handler) (*restart.RestartManager, error) {
 	s.Lock()
 	defer s.Unlock()
@@ -343,6 +436,19 @@ func (o *Overlord) newStoreWithContext(storeCtx store.DeviceAndAuthContext) snap
 	return sto
 }
 
+// localStoreDir returns the directory set with the store.local-dir core
+// option, if any, to serve snaps from instead of the remote store. The state
+// must be locked.
+func localStoreDir(st *state.State) string {
+	var dir string
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "store.local-dir", &dir); err != nil && !config.IsNoOption(err) {
+		logger.Noticef("cannot get store.local-dir option, using the remote store: %v", err)
+		return ""
+	}
+	return dir
+}
+
 // newStore can make new stores for use during remodeling.
 // The device backend will tie them to the remodeling device state.
 func (o *Overlord) newStore(devBE storecontext.DeviceBackend) snapstate.StoreService {
@@ -488,6 +594,11 @@ func (o *Overlord) Loop() {
 				st.Lock()
 				st.Prune(o.startOfOperationTime, pruneWait, abortWait, pruneMaxChanges)
 				st.Unlock()
+				// write the pruned changes to the history outside
+				// of the state lock
+				if err := changehistory.Flush(); err != nil {
+					logger.Noticef("cannot archive pruned changes: %v", err)
+				}
 			}
 		}
 	})
@@ -510,6 +621,20 @@ func (o *Overlord) Stop() error {
 		err = o.loopTomb.Wait()
 	}
 	o.stateEng.Stop()
+	if o.stateJournal != nil {
+		// leave an up to date state file behind for the tools and
+		// the older snapd versions reading it
+		st := o.State()
+		st.Lock()
+		data, cerr := json.Marshal(st)
+		if cerr == nil {
+			cerr = o.stateJournal.compact(data)
+		}
+		st.Unlock()
+		if cerr != nil {
+			logger.Noticef("cannot compact the state journal: %v", cerr)
+		}
+	}
 	if o.stateFLock != nil {
 		// This will also unlock the file
 		o.stateFLock.Close()
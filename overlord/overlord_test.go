@@ -51,6 +51,7 @@ import (
 	"github.com/snapcore/snapd/snapdenv"
 	"github.com/snapcore/snapd/snapdtool"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/localstore"
 	"github.com/snapcore/snapd/testutil"
 	"github.com/snapcore/snapd/timings"
 )
@@ -162,6 +163,24 @@ func (ovs *overlordSuite) TestNewStore(c *C) {
 	c.Check(sto.(*store.Store).CacheDownloads(), Equals, 5)
 }
 
+func (ovs *overlordSuite) TestNewWithLocalStore(c *C) {
+	storeDir := c.MkDir()
+	fakeState := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"patch-sublevel":%d,"patch-sublevel-last-version":%q,"config":{"core":{"store":{"local-dir":%q}}}},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`, patch.Level, patch.Sublevel, snapdtool.Version, storeDir))
+	err := ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600)
+	c.Assert(err, IsNil)
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	s := o.State()
+	s.Lock()
+	defer s.Unlock()
+
+	sto := snapstate.Store(s, nil)
+	c.Assert(sto, FitsTypeOf, &localstore.Store{})
+	c.Check(sto.(*localstore.Store).Dir(), Equals, storeDir)
+}
+
 func (ovs *overlordSuite) TestNewWithGoodState(c *C) {
 	// ensure we don't write state load timing in the state on really
 	// slow architectures (e.g. risc-v)
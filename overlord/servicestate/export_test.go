@@ -20,9 +20,13 @@
 package servicestate
 
 import (
+	"time"
+
 	tomb "gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/sandbox/netquota"
 	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/testutil"
 )
@@ -76,3 +80,51 @@ func MockResourcesCheckFeatureRequirements(f func(*quota.Resources) error) (rest
 	resourcesCheckFeatureRequirements = f
 	return r
 }
+
+var EnsureNetworkQuotas = ensureNetworkQuotas
+
+func MockNetquotaApply(f func(limits []netquota.Limit) error) (restore func()) {
+	r := testutil.Backup(&netquotaApply)
+	netquotaApply = f
+	return r
+}
+
+func MockUsageCollection(version int, statsOfAllSnaps func() (map[string]*cgroup.Stats, error)) (restore func()) {
+	r1 := testutil.Backup(&cgroupVersion, &cgroupStatsOfAllSnaps, &usageSampleInterval)
+	cgroupVersion = func() (int, error) { return version, nil }
+	cgroupStatsOfAllSnaps = statsOfAllSnaps
+	// only sample when asked to by the tests
+	usageSampleInterval = time.Hour
+	return r1
+}
+
+func MockUsageHistoryLength(length int) (restore func()) {
+	r := testutil.Backup(&usageHistoryLength)
+	usageHistoryLength = length
+	return r
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	r := testutil.Backup(&timeNow)
+	timeNow = f
+	return r
+}
+
+// EnableUsageHistory makes the manager keep the usage samples taken with
+// SampleUsage, without sampling in the background.
+func (m *ServiceManager) EnableUsageHistory() {
+	m.usage.mu.Lock()
+	defer m.usage.mu.Unlock()
+	m.usage.running = true
+}
+
+// SampleUsage takes a resource usage sample right away.
+func (m *ServiceManager) SampleUsage() {
+	m.usage.sample()
+}
+
+// CheckServiceRestarts checks the restart counters of all services right
+// away.
+func (m *ServiceManager) CheckServiceRestarts() {
+	m.checkServiceRestarts()
+}
@@ -0,0 +1,186 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// describeSecurityTag returns a human readable description of the app or
+// hook with the given security tag.
+func describeSecurityTag(tag naming.SecurityTag) string {
+	switch t := tag.(type) {
+	case naming.AppSecurityTag:
+		return fmt.Sprintf("app %q of snap %q", t.AppName(), t.InstanceName())
+	case naming.HookSecurityTag:
+		return fmt.Sprintf("hook %q of snap %q", t.HookName(), t.InstanceName())
+	}
+	return fmt.Sprintf("snap %q", tag.InstanceName())
+}
+
+// quotaGroupOfSecurityTag returns the quota group the app or hook with the
+// given security tag runs in, if any. Services in service sub-groups run in
+// those rather than in the group of their snap.
+func quotaGroupOfSecurityTag(tag naming.SecurityTag, allGrps map[string]*quota.Group) *quota.Group {
+	if appTag, ok := tag.(naming.AppSecurityTag); ok {
+		svc := appTag.InstanceName() + "." + appTag.AppName()
+		for _, grp := range allGrps {
+			if strutil.ListContains(grp.Services, svc) {
+				return grp
+			}
+		}
+	}
+	for _, grp := range allGrps {
+		if strutil.ListContains(grp.Snaps, tag.InstanceName()) {
+			return grp
+		}
+	}
+	return nil
+}
+
+// oomKilledTags returns the sorted security tags of the apps and hooks whose
+// processes were killed by the OOM killer between the two samples.
+func oomKilledTags(prev, cur *usageSample) []string {
+	var tags []string
+	for tag, stats := range cur.stats {
+		prevKills := uint64(0)
+		if prevStats := prev.stats[tag]; prevStats != nil {
+			prevKills = prevStats.OOMKills
+		}
+		if counterDelta(prevKills, stats.OOMKills) > 0 {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// readGroupOOMKills returns the number of processes killed by the OOM killer
+// in each of the quota groups whose slice is active. The count of a group
+// includes the processes killed in its sub-groups.
+func readGroupOOMKills(allGrps map[string]*quota.Group) map[string]uint64 {
+	kills := make(map[string]uint64, len(allGrps))
+	for name, grp := range allGrps {
+		path := filepath.Join(dirs.GlobalRootDir, "/sys/fs/cgroup", groupCgroupPath(grp, allGrps))
+		if !osutil.IsDirectory(path) {
+			continue
+		}
+		stats, err := cgroup.ReadStats(path)
+		if err != nil {
+			logger.Debugf("cannot read resource usage of quota group %q: %v", name, err)
+			continue
+		}
+		kills[name] = stats.OOMKills
+	}
+	return kills
+}
+
+// warnOOMKills adds a warning for each app or hook with processes killed by
+// the OOM killer between the two samples. The processes of services that
+// are stopped as a result are only accounted in the slice of their quota
+// group once their cgroup is gone, in which case the warning names the
+// group and the apps that stopped running instead.
+func (m *ServiceManager) warnOOMKills(prev, cur *usageSample) {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	allGrps, err := AllQuotas(m.state)
+	if err != nil {
+		logger.Noticef("cannot check for processes killed out of memory: %v", err)
+		return
+	}
+	groupKills := readGroupOOMKills(allGrps)
+	prevGroupKills := m.groupOOMKills
+	m.groupOOMKills = groupKills
+	if prev == nil {
+		return
+	}
+
+	// groups with kills explained by the kills of their apps, including
+	// the parents that account for them
+	explained := make(map[string]bool)
+	for _, tag := range oomKilledTags(prev, cur) {
+		parsedTag, err := naming.ParseSecurityTag(tag)
+		if err != nil {
+			continue
+		}
+		grp := quotaGroupOfSecurityTag(parsedTag, allGrps)
+		if grp == nil {
+			m.state.Warnf("%s was killed after running out of memory", describeSecurityTag(parsedTag))
+			continue
+		}
+		m.state.Warnf("%s was killed after running out of memory in quota group %q", describeSecurityTag(parsedTag), grp.Name)
+		for g := grp; g != nil; g = allGrps[g.ParentGroup] {
+			explained[g.Name] = true
+		}
+	}
+
+	grew := func(name string) bool {
+		prevKills, ok := prevGroupKills[name]
+		return ok && counterDelta(prevKills, groupKills[name]) > 0
+	}
+	var names []string
+	for name := range groupKills {
+		if grew(name) && !explained[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		grp := allGrps[name]
+		// the kills are reported by the innermost group they happened in
+		innermost := true
+		for _, subName := range grp.SubGroups {
+			if grew(subName) {
+				innermost = false
+				break
+			}
+		}
+		if !innermost {
+			continue
+		}
+
+		match := groupUsageMatcher(grp, allGrps)
+		var stopped []string
+		for _, tag := range matchingTags(prev, match) {
+			if cur.stats[tag] != nil {
+				continue
+			}
+			parsedTag, _ := naming.ParseSecurityTag(tag)
+			stopped = append(stopped, describeSecurityTag(parsedTag))
+		}
+		if len(stopped) == 0 {
+			m.state.Warnf("processes in quota group %q were killed after running out of memory", name)
+			continue
+		}
+		m.state.Warnf("processes in quota group %q were killed after running out of memory, stopping %s", name, strings.Join(stopped, ", "))
+	}
+}
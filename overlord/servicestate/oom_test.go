@@ -0,0 +1,133 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *usageSuite) mockSliceOOMKills(c *C, path string, kills int) {
+	dir := filepath.Join(dirs.GlobalRootDir, "/sys/fs/cgroup", path)
+	c.Assert(os.MkdirAll(dir, 0755), IsNil)
+	content := fmt.Sprintf("low 0\nhigh 0\nmax 3\noom 1\noom_kill %d\n", kills)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "memory.events"), []byte(content), 0644), IsNil)
+}
+
+func (s *usageSuite) warnings() []string {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	var msgs []string
+	for _, w := range s.state.AllWarnings() {
+		msgs = append(msgs, w.String())
+	}
+	sort.Strings(msgs)
+	return msgs
+}
+
+func (s *usageSuite) TestWarnOOMKilledApps(c *C) {
+	s.stats = []map[string]*cgroup.Stats{
+		{
+			"snap.snap-a.svc1":           {OOMKills: 1},
+			"snap.snap-a.app":            {},
+			"snap.snap-c.app":            {OOMKills: 2},
+			"snap.snap-a.hook.configure": {},
+		},
+		{
+			"snap.snap-a.svc1":           {OOMKills: 1},
+			"snap.snap-a.app":            {OOMKills: 1},
+			"snap.snap-c.app":            {OOMKills: 3},
+			"snap.snap-a.hook.configure": {OOMKills: 1},
+			// started after the previous sample
+			"snap.snap-b.svc": {OOMKills: 1},
+		},
+		{
+			"snap.snap-a.svc1": {OOMKills: 2},
+			"snap.snap-a.app":  {OOMKills: 1},
+			"snap.snap-c.app":  {OOMKills: 3},
+			"snap.snap-b.svc":  {OOMKills: 1},
+		},
+	}
+
+	// kills that happened before the first sample are not reported
+	s.mgr.SampleUsage()
+	c.Check(s.warnings(), HasLen, 0)
+
+	s.mgr.SampleUsage()
+	c.Check(s.warnings(), DeepEquals, []string{
+		`app "app" of snap "snap-a" was killed after running out of memory in quota group "foo"`,
+		`app "app" of snap "snap-c" was killed after running out of memory`,
+		`app "svc" of snap "snap-b" was killed after running out of memory in quota group "bar"`,
+		`hook "configure" of snap "snap-a" was killed after running out of memory in quota group "foo"`,
+	})
+
+	s.mgr.SampleUsage()
+	c.Check(s.warnings(), HasLen, 5)
+	c.Check(s.warnings(), testutil.Contains, `app "svc1" of snap "snap-a" was killed after running out of memory in quota group "foo-svc"`)
+}
+
+func (s *usageSuite) TestWarnOOMKilledStoppedServices(c *C) {
+	s.stats = []map[string]*cgroup.Stats{
+		{
+			"snap.snap-a.svc1": {},
+			"snap.snap-a.app":  {},
+			"snap.snap-b.svc":  {},
+		},
+		{
+			// svc1 was stopped after being killed, so its cgroup is gone
+			"snap.snap-a.app": {},
+			"snap.snap-b.svc": {},
+		},
+		{
+			"snap.snap-a.app": {},
+			"snap.snap-b.svc": {OOMKills: 1},
+		},
+	}
+	s.mockSliceOOMKills(c, "snap.foo.slice", 0)
+	s.mockSliceOOMKills(c, "snap.foo.slice/snap.foo-foo\\x2dsvc.slice", 0)
+	s.mockSliceOOMKills(c, "snap.bar.slice", 0)
+	s.mgr.SampleUsage()
+
+	s.mockSliceOOMKills(c, "snap.foo.slice", 1)
+	s.mockSliceOOMKills(c, "snap.foo.slice/snap.foo-foo\\x2dsvc.slice", 1)
+	s.mgr.SampleUsage()
+	// only the innermost group is reported
+	c.Check(s.warnings(), DeepEquals, []string{
+		`processes in quota group "foo-svc" were killed after running out of memory, stopping app "svc1" of snap "snap-a"`,
+	})
+
+	// kills of apps that are still running are not reported twice
+	s.mockSliceOOMKills(c, "snap.bar.slice", 1)
+	s.mgr.SampleUsage()
+	c.Check(s.warnings(), DeepEquals, []string{
+		`app "svc" of snap "snap-b" was killed after running out of memory in quota group "bar"`,
+		`processes in quota group "foo-svc" were killed after running out of memory, stopping app "svc1" of snap "snap-a"`,
+	})
+}
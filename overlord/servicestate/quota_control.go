@@ -98,6 +98,7 @@ func verifyQuotaRequirements(st *state.State, resourceLimits quota.Resources) er
 	// MemoryLimit requires systemd 211, so it's covered by the initial check
 	// CPUQuota requires systemd 213, so no further checks need to be done
 	// TasksMax requires systemd 228, so no further checks need to be done
+	// IO{Read,Write}{Bandwidth,IOPS}Max and IOWeight require systemd 230
 
 	// AllowedCPUs requires systemd 243, so we need to verify the version here
 	if resourceLimits.CPUSet != nil {
@@ -117,6 +118,38 @@ func verifyQuotaRequirements(st *state.State, resourceLimits quota.Resources) er
 			return err
 		}
 	}
+
+	// io quotas are experimental as well
+	if resourceLimits.IO != nil {
+		if err := isExperimentalQuotasAvailable(st, "io"); err != nil {
+			return err
+		}
+	}
+
+	// and so are network quotas
+	if resourceLimits.Network != nil {
+		if err := isExperimentalQuotasAvailable(st, "network"); err != nil {
+			return err
+		}
+	}
+
+	// MemoryHigh requires systemd 231 and MemorySwapMax 232, while
+	// OOMPolicy is only available since 243
+	if resourceLimits.MemoryHigh != nil || resourceLimits.MemorySwap != nil {
+		if err := systemd.EnsureAtLeast(232); err != nil {
+			return fmt.Errorf("cannot use memory high or swap limits with incompatible systemd: %v", err)
+		}
+	}
+	if resourceLimits.OOMPolicy != nil {
+		if err := systemd.EnsureAtLeast(243); err != nil {
+			return fmt.Errorf("cannot use oom policy with incompatible systemd: %v", err)
+		}
+	}
+	if resourceLimits.MemoryHigh != nil || resourceLimits.MemorySwap != nil || resourceLimits.OOMPolicy != nil {
+		if err := isExperimentalQuotasAvailable(st, "memory pressure"); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
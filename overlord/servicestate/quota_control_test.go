@@ -143,7 +143,8 @@ func checkQuotaState(c *C, st *state.State, exp map[string]quotaGroupState) {
 func shouldMentionSlice(resources quota.Resources) bool {
 	if resources.Memory == nil && resources.CPU == nil &&
 		resources.CPUSet == nil && resources.Threads == nil &&
-		resources.Journal == nil {
+		resources.Journal == nil && resources.IO == nil &&
+		resources.Network == nil {
 		return false
 	}
 	return true
@@ -336,6 +337,8 @@ func (s *quotaControlSuite) TestCreateQuotaPerQuotaSystemdTooOld(c *C) {
 
 		{quota.NewResourcesBuilder().WithCPUSet([]int{0, 1}).Build(), 243, `cannot use the cpu-set quota with incompatible systemd: systemd version 242 is too old \(expected at least 243\)`},
 		{quota.NewResourcesBuilder().WithJournalSize(quantity.SizeGiB).Build(), 245, `cannot use journal quota with incompatible systemd: systemd version 244 is too old \(expected at least 245\)`},
+		{quota.NewResourcesBuilder().WithMemorySwapLimit(0).Build(), 232, `cannot use memory high or swap limits with incompatible systemd: systemd version 231 is too old \(expected at least 232\)`},
+		{quota.NewResourcesBuilder().WithOOMPolicy(quota.OOMPolicyKillGroup).Build(), 243, `cannot use oom policy with incompatible systemd: systemd version 242 is too old \(expected at least 243\)`},
 	}
 
 	for _, t := range tests {
@@ -364,6 +367,56 @@ func (s *quotaControlSuite) TestCreateQuotaJournalNotEnabled(c *C) {
 	c.Assert(err, ErrorMatches, `journal quota options are experimental - test it by setting 'experimental.quota-groups' to true`)
 }
 
+func (s *quotaControlSuite) TestCreateQuotaIONotEnabled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "experimental.quota-groups", false)
+	tr.Commit()
+
+	quotaConstraits := quota.NewResourcesBuilder().WithIOReadBandwidth(quantity.SizeMiB).Build()
+	_, err := servicestate.CreateQuota(s.state, "foo", servicestate.CreateQuotaOptions{
+		ResourceLimits: quotaConstraits,
+	})
+	c.Assert(err, ErrorMatches, `io quota options are experimental - test it by setting 'experimental.quota-groups' to true`)
+}
+
+func (s *quotaControlSuite) TestCreateQuotaNetworkNotEnabled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "experimental.quota-groups", false)
+	tr.Commit()
+
+	quotaConstraits := quota.NewResourcesBuilder().WithNetworkEgressBandwidth(quantity.SizeMiB).Build()
+	_, err := servicestate.CreateQuota(s.state, "foo", servicestate.CreateQuotaOptions{
+		ResourceLimits: quotaConstraits,
+	})
+	c.Assert(err, ErrorMatches, `network quota options are experimental - test it by setting 'experimental.quota-groups' to true`)
+}
+
+func (s *quotaControlSuite) TestCreateQuotaMemoryPressureNotEnabled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "experimental.quota-groups", false)
+	tr.Commit()
+
+	for _, resources := range []quota.Resources{
+		quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeMiB).Build(),
+		quota.NewResourcesBuilder().WithMemorySwapLimit(0).Build(),
+		quota.NewResourcesBuilder().WithOOMPolicy(quota.OOMPolicyKillGroup).Build(),
+	} {
+		_, err := servicestate.CreateQuota(s.state, "foo", servicestate.CreateQuotaOptions{
+			ResourceLimits: resources,
+		})
+		c.Check(err, ErrorMatches, `memory pressure quota options are experimental - test it by setting 'experimental.quota-groups' to true`)
+	}
+}
+
 func (s *quotaControlSuite) TestCreateQuotaJournalEnabled(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -22,11 +22,13 @@ package servicestate
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 	"sort"
 	"strings"
 
 	tomb "gopkg.in/tomb.v2"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
@@ -34,6 +36,7 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/sandbox/netquota"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/naming"
 	"github.com/snapcore/snapd/snap/quota"
@@ -110,6 +113,10 @@ func (m *ServiceManager) doQuotaControl(t *state.Task, _ *tomb.Tomb) error {
 			return err
 		}
 
+		// the network limits of all the groups are applied at once, so
+		// they need to be applied again if the group loses its own
+		hadNetworkLimit := allGrps[qc.QuotaName] != nil && allGrps[qc.QuotaName].NetworkLimit != nil
+
 		var grp *quota.Group
 		switch qc.Action {
 		case "create":
@@ -128,7 +135,8 @@ func (m *ServiceManager) doQuotaControl(t *state.Task, _ *tomb.Tomb) error {
 
 		// ensure service and slices on disk and their states are updated
 		opts := &ensureSnapServicesForGroupOptions{
-			allGrps: allGrps,
+			allGrps:             allGrps,
+			networkLimitRemoved: hadNetworkLimit && grp.NetworkLimit == nil,
 		}
 		servicesAffected, err = ensureSnapServicesForGroup(st, t, grp, opts)
 		if err != nil {
@@ -576,6 +584,10 @@ type ensureSnapServicesForGroupOptions struct {
 	// extraSnaps is the set of extra snaps to consider when ensuring services,
 	// mainly only used when snaps are removed from quota groups
 	extraSnaps []string
+
+	// networkLimitRemoved is set when the network limit of the group was
+	// removed, so that the network limits applied previously are replaced
+	networkLimitRemoved bool
 }
 
 func snapServiceNames(info *snap.Info) []string {
@@ -808,6 +820,14 @@ func ensureSnapServicesForGroup(st *state.State, t *state.Task, grp *quota.Group
 		}
 	}
 
+	// the network limits are not enforced by systemd, so they need to be
+	// applied once the slices of the groups exist
+	if (grp.NetworkLimit != nil || opts.networkLimitRemoved) && !ensureOpts.Preseeding {
+		if err := applyNetworkQuotas(systemSysd, allGrps); err != nil {
+			return nil, err
+		}
+	}
+
 	// lastly, lets restart journald services which were affected
 	// by the changes to the quota group
 	if len(journalsToRestart) > 0 {
@@ -855,6 +875,86 @@ func restartSnapServices(st *state.State, appsToRestartBySnap map[*snap.Info][]*
 	return nil
 }
 
+var netquotaApply = netquota.Apply
+
+// groupCgroupPath returns the path of the cgroup of the slice of the group,
+// relative to the root of the unified hierarchy. Slices are nested as their
+// groups are.
+func groupCgroupPath(grp *quota.Group, allGrps map[string]*quota.Group) string {
+	path := grp.SliceFileName()
+	for parent := allGrps[grp.ParentGroup]; parent != nil; parent = allGrps[parent.ParentGroup] {
+		path = filepath.Join(parent.SliceFileName(), path)
+	}
+	return path
+}
+
+// ensureNetworkQuotas applies the network limits of all the given quota
+// groups, replacing the ones applied previously. Groups whose slice is not
+// active are skipped, as their cgroup does not exist yet.
+func ensureNetworkQuotas(allGrps map[string]*quota.Group) error {
+	var limits []netquota.Limit
+	for _, grp := range allGrps {
+		if grp.NetworkLimit == nil {
+			continue
+		}
+		path := groupCgroupPath(grp, allGrps)
+		if !osutil.IsDirectory(filepath.Join(dirs.GlobalRootDir, "/sys/fs/cgroup", path)) {
+			logger.Noticef("cannot apply network quota of group %q yet: slice %s is not active", grp.Name, grp.SliceFileName())
+			continue
+		}
+		limits = append(limits, netquota.Limit{
+			CgroupPath:       path,
+			IngressBandwidth: uint64(grp.NetworkLimit.IngressBandwidth),
+			EgressBandwidth:  uint64(grp.NetworkLimit.EgressBandwidth),
+		})
+	}
+	sort.Slice(limits, func(i, j int) bool {
+		return limits[i].CgroupPath < limits[j].CgroupPath
+	})
+	return netquotaApply(limits)
+}
+
+// applyNetworkQuotas starts the slices of the quota groups with network
+// limits, so that their cgroups exist even before any of their services
+// runs, and applies the limits of all the groups.
+func applyNetworkQuotas(sysd systemd.Systemd, allGrps map[string]*quota.Group) error {
+	var slices []string
+	for _, grp := range allGrps {
+		if grp.NetworkLimit != nil {
+			slices = append(slices, grp.SliceFileName())
+		}
+	}
+	if len(slices) > 0 {
+		sort.Strings(slices)
+		if err := sysd.Start(slices); err != nil {
+			return err
+		}
+	}
+	return ensureNetworkQuotas(allGrps)
+}
+
+// restoreNetworkQuotas applies again the network limits of the quota groups,
+// which do not survive a reboot.
+func restoreNetworkQuotas(st *state.State) error {
+	allGrps, err := AllQuotas(st)
+	if err != nil {
+		return err
+	}
+	hasNetworkLimits := false
+	for _, grp := range allGrps {
+		if grp.NetworkLimit != nil {
+			hasNetworkLimits = true
+			break
+		}
+	}
+	if !hasNetworkLimits || snapdenv.Preseeding() {
+		return nil
+	}
+
+	systemSysd := systemd.New(systemd.SystemMode, progress.Null)
+	return applyNetworkQuotas(systemSysd, allGrps)
+}
+
 // ensureSnapServicesStateForGroup combines ensureSnapServicesForGroup and restartSnapServices.
 // This does not refresh security profiles for snaps in the quota group, which is required
 // for modifications to a journal quota. Currently this function is used when removing a
@@ -36,6 +36,7 @@ import (
 	"github.com/snapcore/snapd/overlord/servicestate/servicestatetest"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/sandbox/netquota"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/snap/snaptest"
@@ -639,6 +640,204 @@ func (s *quotaHandlersSuite) TestQuotaCreate(c *C) {
 	})
 }
 
+func (s *quotaHandlersSuite) TestQuotaCreateRemoveNetworkLimits(c *C) {
+	r := s.mockSystemctlCalls(c, join(
+		// CreateQuota for foo, the slice is started again for the
+		// network limits before the services are restarted
+		[]expectedSystemctl{{expArgs: []string{"daemon-reload"}}},
+		systemctlCallsForSliceStart("foo"),
+		systemctlCallsForSliceStart("foo"),
+		systemctlCallsForServiceRestart("test-snap"),
+
+		// CreateQuota for foo2 - the slice is started for the network
+		// limits even though there are no snaps in it yet
+		[]expectedSystemctl{{expArgs: []string{"start", "snap.foo-foo2.slice", "snap.foo.slice"}}},
+
+		// RemoveQuota for foo2
+		systemctlCallsForSliceStop("foo/foo2"),
+		systemctlCallsForSliceStart("foo"),
+	))
+	defer r()
+
+	var applied [][]netquota.Limit
+	r = servicestate.MockNetquotaApply(func(limits []netquota.Limit) error {
+		applied = append(applied, limits)
+		return nil
+	})
+	defer r()
+
+	// starting the slices creates their cgroups
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/sys/fs/cgroup/snap.foo.slice/snap.foo-foo2.slice"), 0755), IsNil)
+
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	snapstate.Set(s.state, "test-snap", s.testSnapState)
+	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
+
+	err := s.callDoQuotaControl(&servicestate.QuotaControlAction{
+		Action:         "create",
+		QuotaName:      "foo",
+		ResourceLimits: quota.NewResourcesBuilder().WithNetworkEgressBandwidth(quantity.SizeMiB).Build(),
+		AddSnaps:       []string{"test-snap"},
+	})
+	c.Assert(err, IsNil)
+
+	err = s.callDoQuotaControl(&servicestate.QuotaControlAction{
+		Action:         "create",
+		QuotaName:      "foo2",
+		ParentName:     "foo",
+		ResourceLimits: quota.NewResourcesBuilder().WithNetworkIngressBandwidth(quantity.SizeKiB).Build(),
+	})
+	c.Assert(err, IsNil)
+
+	err = s.callDoQuotaControl(&servicestate.QuotaControlAction{
+		Action:    "remove",
+		QuotaName: "foo2",
+	})
+	c.Assert(err, IsNil)
+
+	fooLimit := netquota.Limit{CgroupPath: "snap.foo.slice", EgressBandwidth: uint64(quantity.SizeMiB)}
+	foo2Limit := netquota.Limit{CgroupPath: "snap.foo.slice/snap.foo-foo2.slice", IngressBandwidth: uint64(quantity.SizeKiB)}
+	c.Check(applied, DeepEquals, [][]netquota.Limit{
+		{fooLimit},
+		// the limit of foo2 is applied right away, without waiting
+		// for services in it to start
+		{fooLimit, foo2Limit},
+		{fooLimit},
+	})
+
+	checkQuotaState(c, st, map[string]quotaGroupState{
+		"foo": {
+			ResourceLimits: quota.NewResourcesBuilder().WithNetworkEgressBandwidth(quantity.SizeMiB).Build(),
+			Snaps:          []string{"test-snap"},
+		},
+	})
+}
+
+func (s *quotaHandlersSuite) TestQuotaUpdateRemoveNetworkLimits(c *C) {
+	r := s.mockSystemctlCalls(c, join(
+		// CreateQuota for foo
+		[]expectedSystemctl{{expArgs: []string{"start", "snap.foo.slice"}}},
+		// UpdateQuota for foo, the slice is kept started while the group
+		// has a network limit
+		[]expectedSystemctl{{expArgs: []string{"start", "snap.foo.slice"}}},
+	))
+	defer r()
+
+	var applied [][]netquota.Limit
+	r = servicestate.MockNetquotaApply(func(limits []netquota.Limit) error {
+		applied = append(applied, limits)
+		return nil
+	})
+	defer r()
+
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/sys/fs/cgroup/snap.foo.slice"), 0755), IsNil)
+
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	err := s.callDoQuotaControl(&servicestate.QuotaControlAction{
+		Action:    "create",
+		QuotaName: "foo",
+		ResourceLimits: quota.NewResourcesBuilder().WithThreadLimit(32).
+			WithNetworkIngressBandwidth(quantity.SizeKiB).WithNetworkEgressBandwidth(quantity.SizeMiB).Build(),
+	})
+	c.Assert(err, IsNil)
+
+	// removing one of the limits keeps the other one
+	err = s.callDoQuotaControl(&servicestate.QuotaControlAction{
+		Action:         "update",
+		QuotaName:      "foo",
+		ResourceLimits: quota.NewResourcesBuilder().WithNetworkIngressBandwidth(0).Build(),
+	})
+	c.Assert(err, IsNil)
+
+	// removing the last one drops the network limit of the group
+	err = s.callDoQuotaControl(&servicestate.QuotaControlAction{
+		Action:         "update",
+		QuotaName:      "foo",
+		ResourceLimits: quota.NewResourcesBuilder().WithNetworkEgressBandwidth(0).Build(),
+	})
+	c.Assert(err, IsNil)
+
+	c.Check(applied, DeepEquals, [][]netquota.Limit{
+		{{CgroupPath: "snap.foo.slice", IngressBandwidth: uint64(quantity.SizeKiB), EgressBandwidth: uint64(quantity.SizeMiB)}},
+		{{CgroupPath: "snap.foo.slice", EgressBandwidth: uint64(quantity.SizeMiB)}},
+		nil,
+	})
+
+	checkQuotaState(c, st, map[string]quotaGroupState{
+		"foo": {
+			ResourceLimits: quota.NewResourcesBuilder().WithThreadLimit(32).Build(),
+		},
+	})
+}
+
+func (s *quotaHandlersSuite) TestEnsureNetworkQuotasNestedGroups(c *C) {
+	var applied []netquota.Limit
+	r := servicestate.MockNetquotaApply(func(limits []netquota.Limit) error {
+		applied = limits
+		return nil
+	})
+	defer r()
+
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/sys/fs/cgroup/snap.foo.slice/snap.foo-bar.slice"), 0755), IsNil)
+
+	allGrps := map[string]*quota.Group{
+		"foo": {Name: "foo", SubGroups: []string{"bar"}, MemoryLimit: quantity.SizeGiB},
+		"bar": {Name: "bar", ParentGroup: "foo", NetworkLimit: &quota.GroupQuotaNetwork{IngressBandwidth: 100, EgressBandwidth: 200}},
+	}
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+	_, err := servicestatetest.PatchQuotas(st, allGrps["foo"], allGrps["bar"])
+	c.Assert(err, IsNil)
+	allGrps, err = servicestate.AllQuotas(st)
+	c.Assert(err, IsNil)
+
+	c.Assert(servicestate.EnsureNetworkQuotas(allGrps), IsNil)
+	c.Check(applied, DeepEquals, []netquota.Limit{{
+		CgroupPath:       "snap.foo.slice/snap.foo-bar.slice",
+		IngressBandwidth: 100,
+		EgressBandwidth:  200,
+	}})
+
+	r = servicestate.MockNetquotaApply(func(limits []netquota.Limit) error {
+		return fmt.Errorf("cannot apply network limits: nft failed")
+	})
+	defer r()
+	c.Assert(servicestate.EnsureNetworkQuotas(allGrps), ErrorMatches, "cannot apply network limits: nft failed")
+}
+
+func (s *quotaHandlersSuite) TestRestoreNetworkQuotasOnStartUp(c *C) {
+	var applied []netquota.Limit
+	r := servicestate.MockNetquotaApply(func(limits []netquota.Limit) error {
+		applied = limits
+		return nil
+	})
+	defer r()
+
+	st := s.state
+	st.Lock()
+	_, err := servicestatetest.PatchQuotas(st,
+		&quota.Group{Name: "foo", MemoryLimit: quantity.SizeGiB},
+		&quota.Group{Name: "bar", NetworkLimit: &quota.GroupQuotaNetwork{EgressBandwidth: 200}},
+	)
+	st.Unlock()
+	c.Assert(err, IsNil)
+
+	// the slice is started, which creates its cgroup
+	r = s.mockSystemctlCalls(c, systemctlCallsForSliceStart("bar"))
+	defer r()
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/sys/fs/cgroup/snap.bar.slice"), 0755), IsNil)
+
+	c.Assert(s.mgr.StartUp(), IsNil)
+	c.Check(applied, DeepEquals, []netquota.Limit{{CgroupPath: "snap.bar.slice", EgressBandwidth: 200}})
+}
+
 func (s *quotaHandlersSuite) TestDoCreateSubGroupQuota(c *C) {
 	r := s.mockSystemctlCalls(c, join(
 		// CreateQuota for foo - no systemctl calls since no snaps in it
@@ -0,0 +1,346 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var (
+	restartCheckInterval = time.Minute
+
+	// by default a service restarted 5 times within 10 minutes is
+	// considered to be crash-looping
+	defaultRestartLimit = &RestartLimit{Burst: 5, Period: 10 * time.Minute}
+)
+
+// RestartLimit is the number of automatic restarts of a service within a
+// period of time after which the service is considered to be crash-looping.
+type RestartLimit struct {
+	Burst  int
+	Period time.Duration
+}
+
+func (l *RestartLimit) String() string {
+	return fmt.Sprintf("%d/%s", l.Burst, l.Period)
+}
+
+// ParseRestartLimit parses a restart limit of the form <burst>/<period>, for
+// example "5/10m". The special value "none" disables crash-loop detection,
+// in which case the returned limit is nil.
+func ParseRestartLimit(s string) (*RestartLimit, error) {
+	if s == "none" {
+		return nil, nil
+	}
+	l := strings.SplitN(s, "/", 2)
+	if len(l) != 2 {
+		return nil, fmt.Errorf("restart limit must be of the form <burst>/<period> or \"none\", got %q", s)
+	}
+	burst, err := strconv.Atoi(l[0])
+	if err != nil || burst < 1 {
+		return nil, fmt.Errorf("restart limit burst must be a positive number, got %q", l[0])
+	}
+	period, err := time.ParseDuration(l[1])
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse restart limit period: %v", err)
+	}
+	if period < restartCheckInterval {
+		return nil, fmt.Errorf("restart limit period must be at least %s, got %s", restartCheckInterval, period)
+	}
+	return &RestartLimit{Burst: burst, Period: period}, nil
+}
+
+// restartLimitFor returns the restart limit of the services of the given
+// snap, as configured by resilience.restart-limits.<snap-name> or by
+// resilience.restart-limit for all snaps.
+func restartLimitFor(tr *config.Transaction, snapName string) (*RestartLimit, error) {
+	for _, key := range []string{"resilience.restart-limits." + snapName, "resilience.restart-limit"} {
+		var limitStr string
+		if err := tr.GetMaybe("core", key, &limitStr); err != nil {
+			return nil, err
+		}
+		if limitStr != "" {
+			return ParseRestartLimit(limitStr)
+		}
+	}
+	return defaultRestartLimit, nil
+}
+
+// serviceRestarts tracks the automatic restarts of a service.
+type serviceRestarts struct {
+	// Restarts is the number of automatic restarts observed since snapd
+	// started watching the service.
+	Restarts int `json:"restarts"`
+	// NRestarts is the restart counter of the unit as last seen, systemd
+	// resets it when the service is started explicitly.
+	NRestarts uint64 `json:"n-restarts"`
+	// Recent holds the times at which the restarts within the current
+	// restart limit period were observed.
+	Recent []time.Time `json:"recent,omitempty"`
+	// Failed is set when the service was stopped for crash-looping, until
+	// it is started again.
+	Failed bool `json:"failed,omitempty"`
+}
+
+func allServiceRestarts(st *state.State) (map[string]*serviceRestarts, error) {
+	var restarts map[string]*serviceRestarts
+	if err := st.Get("service-restarts", &restarts); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if restarts == nil {
+		restarts = make(map[string]*serviceRestarts)
+	}
+	return restarts, nil
+}
+
+// DecorateWithRestarts adds the number of automatic restarts observed and
+// whether the service was stopped for crash-looping to the given client app
+// infos of services.
+func DecorateWithRestarts(st *state.State, appInfos []client.AppInfo) error {
+	restarts, err := allServiceRestarts(st)
+	if err != nil {
+		return err
+	}
+	for i := range appInfos {
+		app := &appInfos[i]
+		if !app.IsService() {
+			continue
+		}
+		if r := restarts[app.Snap+"."+app.Name]; r != nil {
+			app.Restarts = r.Restarts
+			app.Failed = r.Failed
+		}
+	}
+	return nil
+}
+
+// restartWatcher checks the restart counters of the services of all snaps
+// periodically.
+type restartWatcher struct {
+	check func()
+
+	started bool
+	tomb    tomb.Tomb
+}
+
+func (rw *restartWatcher) start() {
+	rw.started = true
+	rw.tomb.Go(rw.loop)
+}
+
+func (rw *restartWatcher) stop() {
+	if !rw.started {
+		return
+	}
+	rw.tomb.Kill(nil)
+	rw.tomb.Wait()
+}
+
+func (rw *restartWatcher) loop() error {
+	ticker := time.NewTicker(restartCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			rw.check()
+		case <-rw.tomb.Dying():
+			return nil
+		}
+	}
+}
+
+type watchedService struct {
+	app   *snap.AppInfo
+	limit *RestartLimit
+}
+
+// watchedServices returns the system services of all active snaps, by
+// snap.app name.
+func watchedServices(st *state.State) (map[string]*watchedService, error) {
+	allStates, err := snapstate.All(st)
+	if err != nil {
+		return nil, err
+	}
+	tr := config.NewTransaction(st)
+	svcs := make(map[string]*watchedService)
+	for _, snapst := range allStates {
+		if !snapst.Active {
+			continue
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			return nil, err
+		}
+		limit, err := restartLimitFor(tr, info.SnapName())
+		if err != nil {
+			logger.Noticef("cannot get restart limit of snap %q, using the default: %v", info.InstanceName(), err)
+			limit = defaultRestartLimit
+		}
+		for _, app := range info.Services() {
+			if app.DaemonScope != snap.SystemDaemon {
+				continue
+			}
+			svcs[info.InstanceName()+"."+app.Name] = &watchedService{app: app, limit: limit}
+		}
+	}
+	return svcs, nil
+}
+
+// checkServiceRestarts updates the restarts observed of the services of all
+// snaps, and stops the services that restarted more often than their restart
+// limit allows.
+func (m *ServiceManager) checkServiceRestarts() {
+	m.state.Lock()
+	svcs, err := watchedServices(m.state)
+	m.state.Unlock()
+	if err != nil {
+		logger.Noticef("cannot check for crash-looping services: %v", err)
+		return
+	}
+
+	// talk to systemd without holding the state lock, asking for all
+	// services at once
+	sysd := systemd.New(systemd.SystemMode, progress.Null)
+	names := make([]string, 0, len(svcs))
+	for name := range svcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	serviceNames := make([]string, len(names))
+	for i, name := range names {
+		serviceNames[i] = svcs[name].app.ServiceName()
+	}
+	sts, err := sysd.RestartStatus(serviceNames)
+	if err != nil {
+		// the counter is only available since systemd 235
+		logger.Debugf("cannot get restart status of services: %v", err)
+		return
+	}
+	units := make(map[string]*systemd.RestartStatus, len(names))
+	for i, name := range names {
+		units[name] = sts[i]
+	}
+
+	now := timeNow()
+	var crashLooping []*watchedService
+
+	m.state.Lock()
+	restarts, err := allServiceRestarts(m.state)
+	if err != nil {
+		m.state.Unlock()
+		logger.Noticef("cannot check for crash-looping services: %v", err)
+		return
+	}
+	changed := false
+	for name := range restarts {
+		if svcs[name] == nil {
+			// the snap or the service is gone
+			delete(restarts, name)
+			changed = true
+		}
+	}
+	for _, name := range names {
+		unit := units[name]
+		r := restarts[name]
+		if r == nil {
+			// restarts that happened before the service was
+			// watched are not accounted
+			restarts[name] = &serviceRestarts{NRestarts: unit.Restarts}
+			changed = true
+			continue
+		}
+		if r.NRestarts != unit.Restarts {
+			changed = true
+		}
+		delta := int(counterDelta(r.NRestarts, unit.Restarts))
+		r.NRestarts = unit.Restarts
+		if r.Failed {
+			if !unit.Active {
+				continue
+			}
+			// the service was started again
+			r.Failed = false
+			r.Recent = nil
+			changed = true
+		}
+		r.Restarts += delta
+		for i := 0; i < delta; i++ {
+			r.Recent = append(r.Recent, now)
+		}
+
+		limit := svcs[name].limit
+		if limit == nil {
+			if len(r.Recent) > 0 {
+				r.Recent = nil
+				changed = true
+			}
+			continue
+		}
+		recent := r.Recent[:0]
+		for _, t := range r.Recent {
+			if now.Sub(t) < limit.Period {
+				recent = append(recent, t)
+			}
+		}
+		if len(recent) != len(r.Recent) {
+			changed = true
+		}
+		r.Recent = recent
+		if len(r.Recent) > limit.Burst {
+			r.Failed = true
+			r.Recent = nil
+			changed = true
+			crashLooping = append(crashLooping, svcs[name])
+		}
+	}
+	// only write the state if anything changed, this runs every minute
+	if changed {
+		m.state.Set("service-restarts", restarts)
+	}
+	for _, svc := range crashLooping {
+		app := svc.app
+		m.state.Warnf("service %q of snap %q restarted more than %d times within %s and was stopped, use 'snap start' to start it again", app.Name, app.Snap.InstanceName(), svc.limit.Burst, svc.limit.Period)
+	}
+	m.state.Unlock()
+
+	for _, svc := range crashLooping {
+		app := svc.app
+		if err := sysd.Stop([]string{app.ServiceName()}); err != nil {
+			logger.Noticef("cannot stop crash-looping service %q of snap %q: %v", app.Name, app.Snap.InstanceName(), err)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate_test
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/systemd"
+)
+
+type restartsSuite struct {
+	baseServiceMgrTestSuite
+
+	now       time.Time
+	active    map[string]bool
+	nRestarts map[string]string
+	stopped   []string
+	shows     [][]string
+}
+
+var _ = Suite(&restartsSuite{})
+
+const restartsYaml = `name: test-snap
+version: v1
+apps:
+  svc1:
+    command: bin.sh
+    daemon: simple
+  svc2:
+    command: bin.sh
+    daemon: simple
+  user-svc:
+    command: bin.sh
+    daemon: simple
+    daemon-scope: user
+  app:
+    command: bin.sh
+`
+
+func (s *restartsSuite) SetUpTest(c *C) {
+	s.baseServiceMgrTestSuite.SetUpTest(c)
+
+	s.now = time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.AddCleanup(servicestate.MockTimeNow(func() time.Time { return s.now }))
+
+	s.active = map[string]bool{
+		"snap.test-snap.svc1.service": true,
+		"snap.test-snap.svc2.service": true,
+	}
+	s.nRestarts = map[string]string{
+		"snap.test-snap.svc1.service": "0",
+		"snap.test-snap.svc2.service": "0",
+	}
+	s.stopped = nil
+	s.shows = nil
+	s.AddCleanup(systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		switch {
+		case len(args) >= 2 && args[0] == "show" && args[1] == "--property=Id,ActiveState,NRestarts":
+			s.shows = append(s.shows, args[2:])
+			var out []string
+			for _, unit := range args[2:] {
+				activeState := "inactive"
+				if s.active[unit] {
+					activeState = "active"
+				}
+				out = append(out, fmt.Sprintf("Id=%s\nActiveState=%s\nNRestarts=%s\n", unit, activeState, s.nRestarts[unit]))
+			}
+			return []byte(strings.Join(out, "\n")), nil
+		case len(args) == 2 && args[0] == "stop":
+			s.stopped = append(s.stopped, args[1])
+			s.active[args[1]] = false
+			return nil, nil
+		case len(args) == 3 && args[0] == "show" && args[1] == "--property=ActiveState":
+			return []byte("ActiveState=inactive"), nil
+		}
+		c.Errorf("unexpected systemctl call: %v", args)
+		return nil, fmt.Errorf("broken test")
+	}))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "test-snap", s.testSnapState)
+	snaptest.MockSnapCurrent(c, restartsYaml, s.testSnapSideInfo)
+}
+
+func (s *restartsSuite) restarts(c *C) map[string]client.AppInfo {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	appInfos := []client.AppInfo{
+		{Snap: "test-snap", Name: "svc1", Daemon: "simple"},
+		{Snap: "test-snap", Name: "svc2", Daemon: "simple"},
+		{Snap: "test-snap", Name: "app"},
+	}
+	c.Assert(servicestate.DecorateWithRestarts(s.state, appInfos), IsNil)
+	byName := make(map[string]client.AppInfo, len(appInfos))
+	for _, app := range appInfos {
+		byName[app.Name] = app
+	}
+	return byName
+}
+
+func (s *restartsSuite) warnings() []string {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	var msgs []string
+	for _, w := range s.state.AllWarnings() {
+		msgs = append(msgs, w.String())
+	}
+	return msgs
+}
+
+func (s *restartsSuite) TestCountsRestarts(c *C) {
+	// restarts before snapd started watching are not accounted
+	s.nRestarts["snap.test-snap.svc1.service"] = "2"
+	s.mgr.CheckServiceRestarts()
+	c.Check(s.restarts(c)["svc1"].Restarts, Equals, 0)
+
+	s.now = s.now.Add(time.Minute)
+	s.nRestarts["snap.test-snap.svc1.service"] = "4"
+	s.nRestarts["snap.test-snap.svc2.service"] = "1"
+	s.mgr.CheckServiceRestarts()
+
+	// the counter of svc1 is reset by an explicit restart
+	s.now = s.now.Add(time.Minute)
+	s.nRestarts["snap.test-snap.svc1.service"] = "1"
+	s.mgr.CheckServiceRestarts()
+
+	restarts := s.restarts(c)
+	c.Check(restarts["svc1"].Restarts, Equals, 3)
+	c.Check(restarts["svc1"].Failed, Equals, false)
+	c.Check(restarts["svc2"].Restarts, Equals, 1)
+	c.Check(restarts["app"].Restarts, Equals, 0)
+	c.Check(s.stopped, HasLen, 0)
+	c.Check(s.warnings(), HasLen, 0)
+}
+
+func (s *restartsSuite) TestStopsCrashLoopingService(c *C) {
+	s.mgr.CheckServiceRestarts()
+
+	// 5 restarts within 10 minutes are fine
+	s.now = s.now.Add(time.Minute)
+	s.nRestarts["snap.test-snap.svc1.service"] = "5"
+	s.mgr.CheckServiceRestarts()
+	c.Check(s.stopped, HasLen, 0)
+
+	// older restarts do not count
+	s.now = s.now.Add(10 * time.Minute)
+	s.nRestarts["snap.test-snap.svc1.service"] = "10"
+	s.mgr.CheckServiceRestarts()
+	c.Check(s.stopped, HasLen, 0)
+
+	s.now = s.now.Add(time.Minute)
+	s.nRestarts["snap.test-snap.svc1.service"] = "11"
+	s.mgr.CheckServiceRestarts()
+	c.Check(s.stopped, DeepEquals, []string{"snap.test-snap.svc1.service"})
+	c.Check(s.warnings(), DeepEquals, []string{
+		`service "svc1" of snap "test-snap" restarted more than 5 times within 10m0s and was stopped, use 'snap start' to start it again`,
+	})
+	restarts := s.restarts(c)
+	c.Check(restarts["svc1"].Restarts, Equals, 11)
+	c.Check(restarts["svc1"].Failed, Equals, true)
+	c.Check(restarts["svc2"].Failed, Equals, false)
+
+	// the service stays failed while it is stopped
+	s.now = s.now.Add(time.Minute)
+	s.mgr.CheckServiceRestarts()
+	c.Check(s.restarts(c)["svc1"].Failed, Equals, true)
+
+	// and is no longer failed once started again
+	s.active["snap.test-snap.svc1.service"] = true
+	s.nRestarts["snap.test-snap.svc1.service"] = "0"
+	s.now = s.now.Add(time.Minute)
+	s.mgr.CheckServiceRestarts()
+	restarts = s.restarts(c)
+	c.Check(restarts["svc1"].Restarts, Equals, 11)
+	c.Check(restarts["svc1"].Failed, Equals, false)
+	c.Check(s.stopped, HasLen, 1)
+}
+
+func (s *restartsSuite) TestRestartLimitConfig(c *C) {
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "resilience.restart-limit", "2/5m")
+	tr.Set("core", "resilience.restart-limits.test-snap", "none")
+	tr.Commit()
+	s.state.Unlock()
+
+	s.mgr.CheckServiceRestarts()
+	s.now = s.now.Add(time.Minute)
+	s.nRestarts["snap.test-snap.svc1.service"] = "20"
+	s.mgr.CheckServiceRestarts()
+	c.Check(s.stopped, HasLen, 0)
+	c.Check(s.restarts(c)["svc1"].Restarts, Equals, 20)
+
+	// the global limit applies without a limit for the snap
+	s.state.Lock()
+	tr = config.NewTransaction(s.state)
+	tr.Set("core", "resilience.restart-limits.test-snap", "")
+	tr.Commit()
+	s.state.Unlock()
+
+	s.now = s.now.Add(time.Minute)
+	s.nRestarts["snap.test-snap.svc2.service"] = "3"
+	s.mgr.CheckServiceRestarts()
+	c.Check(s.stopped, DeepEquals, []string{"snap.test-snap.svc2.service"})
+	c.Check(s.warnings(), DeepEquals, []string{
+		`service "svc2" of snap "test-snap" restarted more than 2 times within 5m0s and was stopped, use 'snap start' to start it again`,
+	})
+}
+
+func (s *restartsSuite) TestForgetsRemovedServices(c *C) {
+	s.nRestarts["snap.test-snap.svc1.service"] = "1"
+	s.mgr.CheckServiceRestarts()
+	s.nRestarts["snap.test-snap.svc1.service"] = "2"
+	s.mgr.CheckServiceRestarts()
+	c.Check(s.restarts(c)["svc1"].Restarts, Equals, 1)
+
+	s.state.Lock()
+	snapstate.Set(s.state, "test-snap", nil)
+	s.state.Unlock()
+	s.mgr.CheckServiceRestarts()
+	c.Check(s.restarts(c)["svc1"].Restarts, Equals, 0)
+}
+
+func (s *restartsSuite) TestSingleSystemctlCall(c *C) {
+	s.mgr.CheckServiceRestarts()
+	s.mgr.CheckServiceRestarts()
+	c.Check(s.shows, DeepEquals, [][]string{
+		{"snap.test-snap.svc1.service", "snap.test-snap.svc2.service"},
+		{"snap.test-snap.svc1.service", "snap.test-snap.svc2.service"},
+	})
+}
+
+func (s *restartsSuite) TestWritesStateOnlyOnChange(c *C) {
+	s.mgr.CheckServiceRestarts()
+
+	// mark the stored restarts, the marker is gone once they are
+	// written again
+	marked := func() bool {
+		s.state.Lock()
+		defer s.state.Unlock()
+		var restarts map[string]map[string]interface{}
+		c.Assert(s.state.Get("service-restarts", &restarts), IsNil)
+		return restarts["test-snap.svc1"]["marker"] == true
+	}
+	s.state.Lock()
+	var restarts map[string]map[string]interface{}
+	c.Assert(s.state.Get("service-restarts", &restarts), IsNil)
+	restarts["test-snap.svc1"]["marker"] = true
+	s.state.Set("service-restarts", restarts)
+	s.state.Unlock()
+
+	// nothing changed
+	s.now = s.now.Add(time.Minute)
+	s.mgr.CheckServiceRestarts()
+	c.Check(marked(), Equals, true)
+
+	s.now = s.now.Add(time.Minute)
+	s.nRestarts["snap.test-snap.svc2.service"] = "1"
+	s.mgr.CheckServiceRestarts()
+	c.Check(marked(), Equals, false)
+	c.Check(s.restarts(c)["svc2"].Restarts, Equals, 1)
+}
+
+func (s *restartsSuite) TestParseRestartLimit(c *C) {
+	for _, t := range []struct {
+		in    string
+		limit *servicestate.RestartLimit
+		err   string
+	}{
+		{"5/10m", &servicestate.RestartLimit{Burst: 5, Period: 10 * time.Minute}, ""},
+		{"1/1h", &servicestate.RestartLimit{Burst: 1, Period: time.Hour}, ""},
+		{"none", nil, ""},
+		{"5", nil, `restart limit must be of the form <burst>/<period> or "none", got "5"`},
+		{"0/10m", nil, `restart limit burst must be a positive number, got "0"`},
+		{"x/10m", nil, `restart limit burst must be a positive number, got "x"`},
+		{"5/x", nil, `cannot parse restart limit period: time: invalid duration "?x"?`},
+		{"5/10s", nil, `restart limit period must be at least 1m0s, got 10s`},
+	} {
+		limit, err := servicestate.ParseRestartLimit(t.in)
+		if t.err != "" {
+			c.Check(err, ErrorMatches, t.err, Commentf(t.in))
+			continue
+		}
+		c.Check(err, IsNil, Commentf(t.in))
+		c.Check(limit, DeepEquals, t.limit, Commentf(t.in))
+	}
+}
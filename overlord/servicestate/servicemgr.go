@@ -48,6 +48,13 @@ type ServiceManager struct {
 	state *state.State
 
 	ensuredSnapSvcs bool
+
+	usage usageCollector
+	// groupOOMKills is the number of processes killed by the OOM killer
+	// in each quota group, as of the latest usage sample
+	groupOOMKills map[string]uint64
+
+	restarts restartWatcher
 }
 
 // Manager returns a new service manager.
@@ -56,6 +63,8 @@ func Manager(st *state.State, runner *state.TaskRunner) *ServiceManager {
 	m := &ServiceManager{
 		state: st,
 	}
+	m.usage.observe = m.warnOOMKills
+	m.restarts.check = m.checkServiceRestarts
 	// TODO: undo handler
 	runner.AddHandler("service-control", m.doServiceControl, nil)
 
@@ -199,6 +208,25 @@ func (m *ServiceManager) ensureSnapServicesUpdated() (err error) {
 	return nil
 }
 
+// StartUp implements StateStarterUp.StartUp.
+func (m *ServiceManager) StartUp() error {
+	m.usage.start()
+	m.restarts.start()
+
+	m.state.Lock()
+	defer m.state.Unlock()
+	if err := restoreNetworkQuotas(m.state); err != nil {
+		logger.Noticef("cannot restore network quotas: %v", err)
+	}
+	return nil
+}
+
+// Stop implements StateStopper.Stop.
+func (m *ServiceManager) Stop() {
+	m.usage.stop()
+	m.restarts.stop()
+}
+
 // Ensure implements StateManager.Ensure.
 func (m *ServiceManager) Ensure() error {
 	if err := m.ensureSnapServicesUpdated(); err != nil {
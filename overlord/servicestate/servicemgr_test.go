@@ -94,6 +94,7 @@ func (s *baseServiceMgrTestSuite) SetUpTest(c *C) {
 
 	err = s.o.StartUp()
 	c.Assert(err, IsNil)
+	s.AddCleanup(s.mgr.Stop)
 
 	// by default we are seeded
 	s.state.Lock()
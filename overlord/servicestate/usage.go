@@ -0,0 +1,349 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/snap/quota"
+)
+
+// ErrUsageUnavailable is returned when asking for the resource usage on
+// systems where it is not collected.
+var ErrUsageUnavailable = errors.New("resource usage is only collected with cgroup version 2")
+
+var (
+	usageSampleInterval = 10 * time.Second
+	// with the default interval, the history covers the last 5 minutes
+	usageHistoryLength = 30
+
+	cgroupVersion         = cgroup.Version
+	cgroupStatsOfAllSnaps = cgroup.StatsOfAllSnaps
+
+	timeNow = time.Now
+)
+
+type usageSample struct {
+	time time.Time
+	// stats is the usage of the running snap apps and hooks, by security tag
+	stats map[string]*cgroup.Stats
+}
+
+// usageCollector samples the resource usage of the apps and hooks of all
+// snaps periodically, keeping the latest samples in a ring buffer.
+type usageCollector struct {
+	mu      sync.Mutex
+	running bool
+	samples []*usageSample
+	next    int
+
+	// latest is the last sample taken, even when the history is not kept
+	latest *usageSample
+	// observe is called with the previous and the new sample, if set
+	observe func(prev, cur *usageSample)
+
+	started bool
+	tomb    tomb.Tomb
+}
+
+func (uc *usageCollector) start() {
+	if ver, err := cgroupVersion(); err != nil || ver != cgroup.V2 {
+		return
+	}
+	uc.mu.Lock()
+	uc.running = true
+	uc.samples = make([]*usageSample, 0, usageHistoryLength)
+	uc.mu.Unlock()
+
+	uc.started = true
+	uc.tomb.Go(uc.loop)
+}
+
+func (uc *usageCollector) stop() {
+	if !uc.started {
+		return
+	}
+	uc.tomb.Kill(nil)
+	uc.tomb.Wait()
+}
+
+func (uc *usageCollector) loop() error {
+	ticker := time.NewTicker(usageSampleInterval)
+	defer ticker.Stop()
+
+	uc.sample()
+	for {
+		select {
+		case <-ticker.C:
+			uc.sample()
+		case <-uc.tomb.Dying():
+			return nil
+		}
+	}
+}
+
+func (uc *usageCollector) sample() {
+	stats, err := cgroupStatsOfAllSnaps()
+	if err != nil {
+		logger.Debugf("cannot sample resource usage of snaps: %v", err)
+		return
+	}
+	cur := &usageSample{time: timeNow(), stats: stats}
+	prev := uc.latest
+	uc.latest = cur
+	uc.add(cur)
+	if uc.observe != nil {
+		uc.observe(prev, cur)
+	}
+}
+
+func (uc *usageCollector) add(sample *usageSample) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	if len(uc.samples) < usageHistoryLength {
+		uc.samples = append(uc.samples, sample)
+		return
+	}
+	uc.samples[uc.next] = sample
+	uc.next = (uc.next + 1) % usageHistoryLength
+}
+
+// history returns the samples kept, oldest first, or nil if the collector
+// is not running.
+func (uc *usageCollector) history() []*usageSample {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	if !uc.running {
+		return nil
+	}
+	samples := make([]*usageSample, 0, len(uc.samples))
+	samples = append(samples, uc.samples[uc.next:]...)
+	samples = append(samples, uc.samples[:uc.next]...)
+	return samples
+}
+
+// groupUsageMatcher returns whether the usage of the app or hook with the
+// given security tag is accounted to the group, which is the case for all
+// apps and hooks of the snaps in the group or its sub-groups, and for the
+// services in its service sub-groups.
+func groupUsageMatcher(grp *quota.Group, allGrps map[string]*quota.Group) func(tag naming.SecurityTag) bool {
+	snaps := make(map[string]bool)
+	services := make(map[string]bool)
+	var visit func(g *quota.Group)
+	visit = func(g *quota.Group) {
+		for _, snapName := range g.Snaps {
+			snaps[snapName] = true
+		}
+		for _, svc := range g.Services {
+			services[svc] = true
+		}
+		for _, name := range g.SubGroups {
+			if sub := allGrps[name]; sub != nil {
+				visit(sub)
+			}
+		}
+	}
+	visit(grp)
+
+	return func(tag naming.SecurityTag) bool {
+		if snaps[tag.InstanceName()] {
+			return true
+		}
+		if appTag, ok := tag.(naming.AppSecurityTag); ok {
+			return services[appTag.InstanceName()+"."+appTag.AppName()]
+		}
+		return false
+	}
+}
+
+// counterDelta returns how much a cumulative counter grew between two
+// samples; a counter that went down belongs to a cgroup that was created
+// anew in the meantime.
+func counterDelta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// resourceUsage sums up the usage in cur of the apps and hooks with the
+// given security tags, with the rates computed against prev, which may be
+// nil.
+func resourceUsage(prev, cur *usageSample, tags []string) client.QuotaResourceUsage {
+	var usage client.QuotaResourceUsage
+	var delta cgroup.Stats
+	for _, tag := range tags {
+		stats := cur.stats[tag]
+		if stats == nil {
+			continue
+		}
+		usage.Memory += quantity.Size(stats.MemoryCurrent)
+		usage.MemoryPeak += quantity.Size(stats.MemoryPeak)
+		usage.Tasks += int(stats.Pids)
+
+		if prev == nil {
+			continue
+		}
+		// apps that were not running at the time of the previous sample
+		// used all of their resources since then
+		prevStats := prev.stats[tag]
+		if prevStats == nil {
+			prevStats = &cgroup.Stats{}
+		}
+		delta.CPUUsage += time.Duration(counterDelta(uint64(prevStats.CPUUsage), uint64(stats.CPUUsage)))
+		delta.IOReadBytes += counterDelta(prevStats.IOReadBytes, stats.IOReadBytes)
+		delta.IOWriteBytes += counterDelta(prevStats.IOWriteBytes, stats.IOWriteBytes)
+		delta.IOReadOps += counterDelta(prevStats.IOReadOps, stats.IOReadOps)
+		delta.IOWriteOps += counterDelta(prevStats.IOWriteOps, stats.IOWriteOps)
+	}
+
+	if prev == nil {
+		return usage
+	}
+	elapsed := cur.time.Sub(prev.time)
+	if elapsed <= 0 {
+		return usage
+	}
+	seconds := elapsed.Seconds()
+	usage.CPUPercentage = float64(delta.CPUUsage) * 100 / float64(elapsed)
+	usage.IOReadRate = quantity.Size(float64(delta.IOReadBytes) / seconds)
+	usage.IOWriteRate = quantity.Size(float64(delta.IOWriteBytes) / seconds)
+	usage.IOReadOpsRate = float64(delta.IOReadOps) / seconds
+	usage.IOWriteOpsRate = float64(delta.IOWriteOps) / seconds
+	return usage
+}
+
+// matchingTags returns the sorted security tags in the sample for which
+// match returns true.
+func matchingTags(sample *usageSample, match func(tag naming.SecurityTag) bool) []string {
+	var tags []string
+	for tag := range sample.stats {
+		parsedTag, err := naming.ParseSecurityTag(tag)
+		if err != nil {
+			continue
+		}
+		if match(parsedTag) {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// QuotaGroupUsage returns the recent resource usage of the given quota group
+// and the latest usage of each of the apps and hooks accounted to it. The
+// state must be locked by the caller.
+func (m *ServiceManager) QuotaGroupUsage(name string) (*client.QuotaGroupUsage, error) {
+	allGrps, err := AllQuotas(m.state)
+	if err != nil {
+		return nil, err
+	}
+	grp := allGrps[name]
+	if grp == nil {
+		return nil, ErrQuotaNotFound
+	}
+
+	samples := m.usage.history()
+	if samples == nil {
+		return nil, ErrUsageUnavailable
+	}
+
+	match := groupUsageMatcher(grp, allGrps)
+	usage := &client.QuotaGroupUsage{GroupName: name}
+	var prev *usageSample
+	for _, sample := range samples {
+		usage.History = append(usage.History, client.QuotaUsageSample{
+			Time:               sample.time,
+			QuotaResourceUsage: resourceUsage(prev, sample, matchingTags(sample, match)),
+		})
+		prev = sample
+	}
+
+	if len(samples) == 0 {
+		return usage, nil
+	}
+	latest := samples[len(samples)-1]
+	prev = nil
+	if len(samples) > 1 {
+		prev = samples[len(samples)-2]
+	}
+	for _, tag := range matchingTags(latest, match) {
+		parsedTag, _ := naming.ParseSecurityTag(tag)
+		snapName := parsedTag.InstanceName()
+		usage.Apps = append(usage.Apps, client.QuotaAppUsage{
+			Snap:               snapName,
+			App:                strings.TrimPrefix(tag, "snap."+snapName+"."),
+			QuotaResourceUsage: resourceUsage(prev, latest, []string{tag}),
+		})
+	}
+	return usage, nil
+}
+
+// SnapsUsage returns the latest resource usage of each snap with running
+// apps or hooks, sorted by snap name. Unlike QuotaGroupUsage it does not
+// need the snaps to be in a quota group.
+func (m *ServiceManager) SnapsUsage() ([]client.SnapUsage, error) {
+	samples := m.usage.history()
+	if samples == nil {
+		return nil, ErrUsageUnavailable
+	}
+	if len(samples) == 0 {
+		return nil, nil
+	}
+	latest := samples[len(samples)-1]
+	var prev *usageSample
+	if len(samples) > 1 {
+		prev = samples[len(samples)-2]
+	}
+
+	tagsBySnap := make(map[string][]string)
+	for _, tag := range matchingTags(latest, func(naming.SecurityTag) bool { return true }) {
+		parsedTag, _ := naming.ParseSecurityTag(tag)
+		snapName := parsedTag.InstanceName()
+		tagsBySnap[snapName] = append(tagsBySnap[snapName], tag)
+	}
+	snapNames := make([]string, 0, len(tagsBySnap))
+	for snapName := range tagsBySnap {
+		snapNames = append(snapNames, snapName)
+	}
+	sort.Strings(snapNames)
+
+	usage := make([]client.SnapUsage, 0, len(snapNames))
+	for _, snapName := range snapNames {
+		usage = append(usage, client.SnapUsage{
+			Snap:               snapName,
+			QuotaResourceUsage: resourceUsage(prev, latest, tagsBySnap[snapName]),
+		})
+	}
+	return usage, nil
+}
@@ -0,0 +1,290 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/servicestate/servicestatetest"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/snap/quota"
+)
+
+type usageSuite struct {
+	baseServiceMgrTestSuite
+
+	stats []map[string]*cgroup.Stats
+	now   time.Time
+}
+
+var _ = Suite(&usageSuite{})
+
+func (s *usageSuite) SetUpTest(c *C) {
+	// the manager is started with cgroup v1, so that nothing is sampled in
+	// the background
+	restore := servicestate.MockUsageCollection(cgroup.V1, s.statsOfAllSnaps)
+	s.baseServiceMgrTestSuite.SetUpTest(c)
+	s.AddCleanup(restore)
+
+	servicestate.MockEnsuredSnapServices(s.mgr, true)
+
+	s.stats = nil
+	s.now = time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.AddCleanup(servicestate.MockTimeNow(func() time.Time { return s.now }))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	_, err := servicestatetest.PatchQuotas(s.state,
+		&quota.Group{
+			Name:        "foo",
+			MemoryLimit: quantity.SizeGiB,
+			Snaps:       []string{"snap-a"},
+			SubGroups:   []string{"foo-svc"},
+		},
+		&quota.Group{
+			Name:        "foo-svc",
+			MemoryLimit: quantity.SizeMiB,
+			ParentGroup: "foo",
+			Services:    []string{"snap-a.svc1"},
+		},
+		&quota.Group{
+			Name:        "bar",
+			MemoryLimit: quantity.SizeGiB,
+			Snaps:       []string{"snap-b"},
+		},
+	)
+	c.Assert(err, IsNil)
+}
+
+func (s *usageSuite) statsOfAllSnaps() (map[string]*cgroup.Stats, error) {
+	stats := s.stats[0]
+	s.stats = s.stats[1:]
+	return stats, nil
+}
+
+func (s *usageSuite) sampleTwice() {
+	s.stats = []map[string]*cgroup.Stats{
+		{
+			"snap.snap-a.svc1": {CPUUsage: time.Second, MemoryCurrent: 100, Pids: 1, IOReadBytes: 1000},
+			"snap.snap-a.app":  {},
+			"snap.snap-b.svc":  {MemoryCurrent: 5000, Pids: 4},
+		},
+		{
+			"snap.snap-a.svc1":           {CPUUsage: 3 * time.Second, MemoryCurrent: 200, Pids: 2, IOReadBytes: 11000, IOReadOps: 50},
+			"snap.snap-a.hook.configure": {CPUUsage: time.Second, MemoryCurrent: 10, Pids: 1},
+			"snap.snap-b.svc":            {MemoryCurrent: 5000, Pids: 4},
+		},
+	}
+	s.mgr.EnableUsageHistory()
+	s.mgr.SampleUsage()
+	s.now = s.now.Add(10 * time.Second)
+	s.mgr.SampleUsage()
+}
+
+func (s *usageSuite) TestQuotaGroupUsage(c *C) {
+	s.sampleTwice()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	usage, err := s.mgr.QuotaGroupUsage("foo")
+	c.Assert(err, IsNil)
+	c.Check(usage, DeepEquals, &client.QuotaGroupUsage{
+		GroupName: "foo",
+		Apps: []client.QuotaAppUsage{{
+			Snap: "snap-a",
+			App:  "hook.configure",
+			QuotaResourceUsage: client.QuotaResourceUsage{
+				CPUPercentage: 10,
+				Memory:        10,
+				Tasks:         1,
+			},
+		}, {
+			Snap: "snap-a",
+			App:  "svc1",
+			QuotaResourceUsage: client.QuotaResourceUsage{
+				CPUPercentage: 20,
+				Memory:        200,
+				IOReadRate:    1000,
+				IOReadOpsRate: 5,
+				Tasks:         2,
+			},
+		}},
+		History: []client.QuotaUsageSample{{
+			Time: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+			QuotaResourceUsage: client.QuotaResourceUsage{
+				Memory: 100,
+				Tasks:  1,
+			},
+		}, {
+			Time: time.Date(2023, 1, 2, 3, 4, 15, 0, time.UTC),
+			QuotaResourceUsage: client.QuotaResourceUsage{
+				CPUPercentage: 30,
+				Memory:        210,
+				IOReadRate:    1000,
+				IOReadOpsRate: 5,
+				Tasks:         3,
+			},
+		}},
+	})
+}
+
+func (s *usageSuite) TestQuotaGroupUsageServiceSubGroup(c *C) {
+	s.sampleTwice()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	usage, err := s.mgr.QuotaGroupUsage("foo-svc")
+	c.Assert(err, IsNil)
+	c.Assert(usage.Apps, HasLen, 1)
+	c.Check(usage.Apps[0].App, Equals, "svc1")
+	c.Assert(usage.History, HasLen, 2)
+	c.Check(usage.History[1].QuotaResourceUsage, DeepEquals, usage.Apps[0].QuotaResourceUsage)
+
+	usage, err = s.mgr.QuotaGroupUsage("bar")
+	c.Assert(err, IsNil)
+	c.Check(usage.Apps, DeepEquals, []client.QuotaAppUsage{{
+		Snap:               "snap-b",
+		App:                "svc",
+		QuotaResourceUsage: client.QuotaResourceUsage{Memory: 5000, Tasks: 4},
+	}})
+}
+
+func (s *usageSuite) TestQuotaGroupUsageRingBuffer(c *C) {
+	restore := servicestate.MockUsageHistoryLength(2)
+	defer restore()
+
+	s.stats = []map[string]*cgroup.Stats{
+		{"snap.snap-b.svc": {MemoryCurrent: 1}},
+		{"snap.snap-b.svc": {MemoryCurrent: 2}},
+		{"snap.snap-b.svc": {MemoryCurrent: 3}},
+	}
+	s.mgr.EnableUsageHistory()
+	for i := 0; i < 3; i++ {
+		s.mgr.SampleUsage()
+		s.now = s.now.Add(time.Second)
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	usage, err := s.mgr.QuotaGroupUsage("bar")
+	c.Assert(err, IsNil)
+	c.Assert(usage.History, HasLen, 2)
+	c.Check(usage.History[0].Memory, Equals, quantity.Size(2))
+	c.Check(usage.History[1].Memory, Equals, quantity.Size(3))
+}
+
+func (s *usageSuite) TestQuotaGroupUsageErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := s.mgr.QuotaGroupUsage("foo")
+	c.Check(err, Equals, servicestate.ErrUsageUnavailable)
+
+	s.mgr.EnableUsageHistory()
+	_, err = s.mgr.QuotaGroupUsage("unknown")
+	c.Check(err, Equals, servicestate.ErrQuotaNotFound)
+
+	usage, err := s.mgr.QuotaGroupUsage("foo")
+	c.Assert(err, IsNil)
+	c.Check(usage, DeepEquals, &client.QuotaGroupUsage{GroupName: "foo"})
+}
+
+func (s *usageSuite) TestUsageCollectorStartStop(c *C) {
+	sampled := make(chan struct{}, 1)
+	restore := servicestate.MockUsageCollection(cgroup.V2, func() (map[string]*cgroup.Stats, error) {
+		select {
+		case sampled <- struct{}{}:
+		default:
+		}
+		return map[string]*cgroup.Stats{"snap.snap-b.svc": {MemoryCurrent: 1}}, nil
+	})
+	defer restore()
+
+	c.Assert(s.mgr.StartUp(), IsNil)
+	select {
+	case <-sampled:
+	case <-time.After(5 * time.Second):
+		c.Fatal("usage was not sampled")
+	}
+	s.mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	usage, err := s.mgr.QuotaGroupUsage("bar")
+	c.Assert(err, IsNil)
+	c.Check(usage.History, HasLen, 1)
+}
+
+func (s *usageSuite) TestSnapsUsage(c *C) {
+	s.sampleTwice()
+
+	usage, err := s.mgr.SnapsUsage()
+	c.Assert(err, IsNil)
+	c.Check(usage, DeepEquals, []client.SnapUsage{{
+		Snap: "snap-a",
+		QuotaResourceUsage: client.QuotaResourceUsage{
+			CPUPercentage: 30,
+			Memory:        210,
+			IOReadRate:    1000,
+			IOReadOpsRate: 5,
+			Tasks:         3,
+		},
+	}, {
+		Snap:               "snap-b",
+		QuotaResourceUsage: client.QuotaResourceUsage{Memory: 5000, Tasks: 4},
+	}})
+}
+
+func (s *usageSuite) TestSnapsUsageWithoutQuotaGroups(c *C) {
+	s.state.Lock()
+	s.state.Set("quotas", nil)
+	s.state.Unlock()
+
+	s.stats = []map[string]*cgroup.Stats{
+		{"snap.snap-c.app": {MemoryCurrent: 42, Pids: 1}},
+	}
+	s.mgr.EnableUsageHistory()
+	s.mgr.SampleUsage()
+
+	usage, err := s.mgr.SnapsUsage()
+	c.Assert(err, IsNil)
+	c.Check(usage, DeepEquals, []client.SnapUsage{{
+		Snap:               "snap-c",
+		QuotaResourceUsage: client.QuotaResourceUsage{Memory: 42, Tasks: 1},
+	}})
+}
+
+func (s *usageSuite) TestSnapsUsageErrors(c *C) {
+	_, err := s.mgr.SnapsUsage()
+	c.Check(err, Equals, servicestate.ErrUsageUnavailable)
+
+	s.mgr.EnableUsageHistory()
+	usage, err := s.mgr.SnapsUsage()
+	c.Assert(err, IsNil)
+	c.Check(usage, HasLen, 0)
+}
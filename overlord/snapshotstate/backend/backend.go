@@ -302,12 +302,28 @@ func EstimateSnapshotSize(si *snap.Info, usernames []string, dirOpts *dirs.SnapD
 	return total, nil
 }
 
-// Save a snapshot
-func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, dynSnapshotOpts *snap.SnapshotOptions, dirOpts *dirs.SnapDirOptions) (*client.Snapshot, error) {
+// SaveFlags carries extra flags to drive save behavior.
+type SaveFlags struct {
+	// EncryptionKey, if set, is used to encrypt the snapshot data and
+	// metadata.
+	EncryptionKey *EncryptionKey
+	// Deduplicate stores the snapshot data in the chunk store shared by
+	// all snapshots, so only the data that changed since previous
+	// snapshots takes up space. Encrypted snapshots are never
+	// deduplicated.
+	Deduplicate bool
+}
+
+// Save a snapshot.
+func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, dynSnapshotOpts *snap.SnapshotOptions, dirOpts *dirs.SnapDirOptions, flags *SaveFlags) (*client.Snapshot, error) {
 	if err := os.MkdirAll(dirs.SnapshotsDir, 0700); err != nil {
 		return nil, err
 	}
 
+	if flags == nil {
+		flags = &SaveFlags{}
+	}
+
 	snapshot := &client.Snapshot{
 		SetID:    id,
 		Snap:     si.InstanceName(),
@@ -343,8 +359,32 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 	// if things worked, we'll commit (and Cancel becomes a NOP)
 	defer aw.Cancel()
 
-	w := zip.NewWriter(aw)
+	w := &snapshotWriter{Writer: zip.NewWriter(aw)}
 	defer w.Close() // note this does not close the file descriptor (that's done by hand on the atomic writer, above)
+
+	if key := flags.EncryptionKey; key != nil {
+		w.cipher, err = newSnapshotCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		encWriter, err := w.Create(encryptionName)
+		if err != nil {
+			return nil, err
+		}
+		if err := w.cipher.writeHeader(encWriter); err != nil {
+			return nil, err
+		}
+		// so the snapshot can be opened once saved
+		AddEncryptionKey(key)
+		snapshot.Encrypted = true
+	} else if flags.Deduplicate {
+		// keep the chunks from being pruned until the snapshot
+		// referencing them is committed
+		chunkStoreMu.RLock()
+		defer chunkStoreMu.RUnlock()
+		w.deduplicate = true
+	}
+
 	savingUserData := false
 	baseDataDir := snap.BaseDataDir(si.InstanceName())
 	if err := addSnapDirToZip(ctx, snapshot, w, "root", archiveName, baseDataDir, savingUserData, snapshotOptions.Exclude); err != nil {
@@ -364,7 +404,7 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 		}
 	}
 
-	metaWriter, err := w.Create(metadataName)
+	metaWriter, err := w.createMember(&zip.FileHeader{Name: metadataName, Method: zip.Deflate})
 	if err != nil {
 		return nil, err
 	}
@@ -374,12 +414,18 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 	if err := enc.Encode(snapshot); err != nil {
 		return nil, err
 	}
+	if err := metaWriter.Close(); err != nil {
+		return nil, err
+	}
 
-	hashWriter, err := w.Create(metaHashName)
+	hashWriter, err := w.createMember(&zip.FileHeader{Name: metaHashName, Method: zip.Deflate})
 	if err != nil {
 		return nil, err
 	}
 	fmt.Fprintf(hashWriter, "%x\n", hasher.Sum(nil))
+	if err := hashWriter.Close(); err != nil {
+		return nil, err
+	}
 	if err := w.Close(); err != nil {
 		return nil, err
 	}
@@ -400,7 +446,7 @@ var isTesting = snapdenv.Testing()
 // addSnapDirToZip adds the 'common' and the 'rev' revisioned dir under 'snapDir'
 // to the snapshot. If one doesn't exist, it's ignored. If none exists, the
 // operation is skipped.
-func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry, snapDir string, savingUserData bool, excludePaths []string) error {
+func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *snapshotWriter, username, entry, snapDir string, savingUserData bool, excludePaths []string) error {
 	paths, err := pathsForSnapshot(snapDir, snapshot)
 	if err != nil {
 		return err
@@ -446,17 +492,62 @@ func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writ
 	return addToZip(ctx, snapshot, w, username, entry, paths, expExcludePaths)
 }
 
+// snapshotWriter writes the members of a snapshot being saved.
+type snapshotWriter struct {
+	*zip.Writer
+
+	// cipher is set if the snapshot is encrypted
+	cipher *snapshotCipher
+	// deduplicate is set if the archives go to the chunk store
+	deduplicate bool
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// createMember adds a member to the snapshot, encrypting what's written to it
+// if the snapshot is encrypted. The returned writer must be closed when done.
+func (w *snapshotWriter) createMember(fh *zip.FileHeader) (io.WriteCloser, error) {
+	mw, err := w.CreateHeader(fh)
+	if err != nil {
+		return nil, err
+	}
+	if w.cipher == nil {
+		return nopWriteCloser{mw}, nil
+	}
+	return w.cipher.encryptingWriter(mw, fh.Name)
+}
+
 // addToZip adds 'paths' to the snapshot. tar will change into the paths' parent
 // directory before creating the archive so that parent dirs are not added.
-func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry string, paths []string, excludePaths []string) error {
-	archiveWriter, err := w.CreateHeader(&zip.FileHeader{Name: entry})
-	if err != nil {
-		return err
+func addToZip(ctx context.Context, snapshot *client.Snapshot, w *snapshotWriter, username, entry string, paths []string, excludePaths []string) error {
+	var sz osutil.Sizer
+	hasher := crypto.SHA3_384.New()
+
+	// the archive of a deduplicated snapshot goes to the chunk store,
+	// with the chunks compressed one by one instead of by tar so that
+	// unchanged data results in the same chunks
+	var chunker *chunkingWriter
+	var archiveWriter io.WriteCloser
+	compressArg := "--gzip"
+	if w.deduplicate {
+		chunker = newChunkingWriter(io.MultiWriter(hasher, &sz))
+		archiveWriter = chunker
+		compressArg = "--no-auto-compress"
+	} else {
+		var err error
+		archiveWriter, err = w.createMember(&zip.FileHeader{Name: entry})
+		if err != nil {
+			return err
+		}
 	}
 
 	tarArgs := []string{
 		"--create",
-		"--sparse", "--gzip",
+		"--sparse", compressArg,
 		"--format", "gnu",
 		"--anchored",
 		"--no-wildcards-match-slash",
@@ -472,11 +563,12 @@ func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, use
 		tarArgs = append(tarArgs, "--directory", parent, dir)
 	}
 
-	var sz osutil.Sizer
-	hasher := crypto.SHA3_384.New()
-
 	cmd := tarAsUser(username, tarArgs...)
-	cmd.Stdout = io.MultiWriter(archiveWriter, hasher, &sz)
+	if chunker != nil {
+		cmd.Stdout = chunker
+	} else {
+		cmd.Stdout = io.MultiWriter(archiveWriter, hasher, &sz)
+	}
 
 	// keep (at most) the last 5 non-empty lines of what 'tar' writes to stderr
 	// (those are the most likely contain the reason for fatal errors)
@@ -504,6 +596,21 @@ func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, use
 		}
 		return fmt.Errorf("tar failed: %v", err)
 	}
+	if err := archiveWriter.Close(); err != nil {
+		return err
+	}
+	if chunker != nil {
+		indexWriter, err := w.createMember(&zip.FileHeader{Name: entry + chunkIndexSuffix, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(indexWriter).Encode(&chunker.index); err != nil {
+			return err
+		}
+		if err := indexWriter.Close(); err != nil {
+			return err
+		}
+	}
 
 	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
 	snapshot.Size += sz.Size()
@@ -934,6 +1041,10 @@ type SnapshotExport struct {
 
 	// cached size, needs to be calculated with CalculateSize
 	size int64
+
+	// set once deduplicated snapshots were replaced with
+	// self-contained ones
+	rehydrated bool
 }
 
 // NewSnapshotExport will return a SnapshotExport structure. It must be
@@ -999,6 +1110,10 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 // so it should be called without any locks. The SnapshotExport
 // keeps the FDs open so even files moved/deleted will be found.
 func (se *SnapshotExport) Init() error {
+	if err := se.rehydrate(); err != nil {
+		return err
+	}
+
 	// Export once into a fake writer so that we can set the size
 	// of the export. This is then used to set the Content-Length
 	// in the response correctly.
@@ -1026,11 +1141,101 @@ func (se *SnapshotExport) Close() {
 	se.snapshotFiles = nil
 }
 
+// rehydrate replaces the deduplicated snapshots of the export with
+// self-contained copies, as the chunk store is not exported.
+func (se *SnapshotExport) rehydrate() error {
+	if se.rehydrated {
+		return nil
+	}
+	for i, f := range se.snapshotFiles {
+		rf, err := rehydrateSnapshot(f)
+		if err != nil {
+			return fmt.Errorf("cannot export snapshot %q: %v", path.Base(f.Name()), err)
+		}
+		if rf != nil {
+			f.Close()
+			se.snapshotFiles[i] = rf
+		}
+	}
+	se.rehydrated = true
+	return nil
+}
+
+// rehydrateSnapshot returns an anonymous file with a copy of the given
+// snapshot where the deduplicated archives are read back from the chunk
+// store. It returns nil if the snapshot is not deduplicated.
+func rehydrateSnapshot(f *os.File) (*os.File, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	arch, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+	deduplicated := false
+	for _, fh := range arch.File {
+		if strings.HasSuffix(fh.Name, chunkIndexSuffix) {
+			deduplicated = true
+			break
+		}
+	}
+	if !deduplicated {
+		return nil, nil
+	}
+
+	tmp, err := ioutil.TempFile(dirs.SnapshotsDir, ".export-")
+	if err != nil {
+		return nil, err
+	}
+	defer tmp.Close()
+	// only the descriptor is needed
+	if err := os.Remove(tmp.Name()); err != nil {
+		return nil, err
+	}
+
+	w := zip.NewWriter(tmp)
+	for _, fh := range arch.File {
+		if !strings.HasSuffix(fh.Name, chunkIndexSuffix) {
+			if err := w.Copy(fh); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		body, _, err := chunkedMember(f, strings.TrimSuffix(fh.Name, chunkIndexSuffix))
+		if err != nil {
+			return nil, err
+		}
+		mw, err := w.CreateHeader(&zip.FileHeader{Name: strings.TrimSuffix(fh.Name, chunkIndexSuffix)})
+		if err == nil {
+			_, err = io.Copy(mw, body)
+		}
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	// keep the name of the original snapshot, it's what's exported
+	fd, err := syscall.Dup(int(tmp.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("cannot duplicate descriptor: %v", err)
+	}
+	return os.NewFile(uintptr(fd), f.Name()), nil
+}
+
 type contentJSON struct {
 	ContentHash []byte `json:"content-hash"`
 }
 
 func (se *SnapshotExport) StreamTo(w io.Writer) error {
+	if err := se.rehydrate(); err != nil {
+		return err
+	}
+
 	// write out a tar
 	var files []string
 	tw := tar.NewWriter(w)
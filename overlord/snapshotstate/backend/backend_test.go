@@ -23,14 +23,17 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"os/exec"
 	"os/user"
@@ -50,6 +53,7 @@ import (
 	"github.com/snapcore/snapd/osutil/sys"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -451,7 +455,7 @@ func (s *snapshotSuite) TestIterSetIDoverride(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	cfg := map[string]interface{}{"some-setting": false}
 
-	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, uint64(12))
 
@@ -733,7 +737,7 @@ func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string) {
 		return statSnapshotOpts, nil
 	})()
 
-	shw, err := backend.Save(context.TODO(), shID, info, cfg, []string{"snapuser"}, dynSnapshotOpts, nil)
+	shw, err := backend.Save(context.TODO(), shID, info, cfg, []string{"snapuser"}, dynSnapshotOpts, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, shID)
 	c.Check(shw.Snap, check.Equals, info.InstanceName())
@@ -811,7 +815,7 @@ func (s *snapshotSuite) TestOpenSetIDoverride(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	cfg := map[string]interface{}{"some-setting": false}
 
-	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, uint64(12))
 
@@ -835,7 +839,7 @@ func (s *snapshotSuite) TestRestoreRoundtripDifferentRevision(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	shID := uint64(12)
 
-	shw, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.Revision, check.Equals, info.Revision)
 
@@ -1110,7 +1114,7 @@ func (s *snapshotSuite) TestImportDuplicated(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	shID := uint64(12)
 
-	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
@@ -1140,7 +1144,7 @@ func (s *snapshotSuite) TestImportExportRoundtrip(c *check.C) {
 	cfg := map[string]interface{}{"some-setting": false}
 	shID := uint64(12)
 
-	shw, err := backend.Save(ctx, shID, info, cfg, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(ctx, shID, info, cfg, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, shID)
 
@@ -1301,7 +1305,7 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 	}
 	// create a snapshot
 	shID := uint64(12)
-	_, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil)
+	_, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Check(err, check.IsNil)
 
 	// content.json + num_files + export.json + footer
@@ -1648,7 +1652,7 @@ func (s *snapshotSuite) TestSnapshotExportContentHash(c *check.C) {
 		Version: "v1.33",
 	}
 	shID := uint64(12)
-	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Check(err, check.IsNil)
 
 	// now export it
@@ -1670,10 +1674,473 @@ func (s *snapshotSuite) TestSnapshotExportContentHash(c *check.C) {
 		},
 		Version: "v1.33",
 	}
-	shw, err = backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err = backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Check(err, check.IsNil)
 
 	export3, err := backend.NewSnapshotExport(ctx, shw.SetID)
 	c.Assert(err, check.IsNil)
 	c.Check(export.ContentHash(), check.Not(check.DeepEquals), export3.ContentHash())
 }
+
+func (s *snapshotSuite) mockEncryptionKey(c *check.C, seed byte) *backend.EncryptionKey {
+	key, err := backend.NewEncryptionKey(bytes.Repeat([]byte{seed}, backend.EncryptionKeySize))
+	c.Assert(err, check.IsNil)
+	return key
+}
+
+func (s *snapshotSuite) TestNewEncryptionKey(c *check.C) {
+	_, err := backend.NewEncryptionKey([]byte("too short"))
+	c.Check(err, check.ErrorMatches, `invalid snapshot encryption key size 9, expected 32`)
+
+	key1 := s.mockEncryptionKey(c, 1)
+	key2 := s.mockEncryptionKey(c, 2)
+	c.Check(key1.ID(), check.HasLen, 32)
+	c.Check(key1.ID(), check.Not(check.Equals), key2.ID())
+	c.Check(key1.ID(), check.Equals, s.mockEncryptionKey(c, 1).ID())
+}
+
+func (s *snapshotSuite) TestEncryptDecrypt(c *check.C) {
+	key := s.mockEncryptionKey(c, 1)
+	for _, size := range []int{0, 1, backend.EncryptedChunkSize - 1, backend.EncryptedChunkSize, backend.EncryptedChunkSize + 1, 3*backend.EncryptedChunkSize + 42} {
+		data := bytes.Repeat([]byte{'x'}, size)
+		out, err := backend.EncryptDecrypt(key, data, nil)
+		c.Assert(err, check.IsNil, check.Commentf("size %d", size))
+		c.Check(out, check.DeepEquals, data[:len(out)], check.Commentf("size %d", size))
+		c.Check(out, check.HasLen, size)
+	}
+}
+
+func (s *snapshotSuite) TestEncryptDecryptTampered(c *check.C) {
+	key := s.mockEncryptionKey(c, 1)
+	data := bytes.Repeat([]byte{'x'}, 2*backend.EncryptedChunkSize+42)
+	// the size of an encrypted full chunk
+	chunk := backend.EncryptedChunkSize + 16
+
+	for _, t := range []struct {
+		mangle func([]byte) []byte
+		err    string
+	}{
+		{
+			mangle: func(b []byte) []byte { b[10] ^= 1; return b },
+			err:    "cannot decrypt snapshot member: .*",
+		}, {
+			// dropping the last chunk
+			mangle: func(b []byte) []byte { return b[:2*chunk] },
+			err:    "cannot decrypt snapshot member: .*",
+		}, {
+			// dropping a chunk in the middle
+			mangle: func(b []byte) []byte { return append(b[:chunk], b[2*chunk:]...) },
+			err:    "cannot decrypt snapshot member: .*",
+		}, {
+			mangle: func(b []byte) []byte { return b[:0] },
+			err:    "encrypted snapshot member is truncated",
+		},
+	} {
+		_, err := backend.EncryptDecrypt(key, append([]byte(nil), data...), t.mangle)
+		c.Check(err, check.ErrorMatches, t.err)
+	}
+}
+
+func (s *snapshotSuite) saveEncrypted(c *check.C, key *backend.EncryptionKey) *client.Snapshot {
+	s.restore = append(s.restore, backend.MockTarAsUser(func(username string, args ...string) *exec.Cmd {
+		return exec.Command(s.tarPath, args...)
+	}), backend.MockUsersForUsernames(func(usernames []string, _ *dirs.SnapDirOptions) ([]*user.User, error) {
+		return nil, nil
+	}))
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	cfg := map[string]interface{}{"password": "hunter2"}
+
+	shw, err := backend.Save(context.TODO(), 12, info, cfg, nil, nil, nil, &backend.SaveFlags{EncryptionKey: key})
+	c.Assert(err, check.IsNil)
+	c.Check(shw.Encrypted, check.Equals, true)
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz"})
+	return shw
+}
+
+func (s *snapshotSuite) TestEncryptedRoundtrip(c *check.C) {
+	key := s.mockEncryptionKey(c, 1)
+	defer backend.MockEncryptionKeys()()
+
+	shw := s.saveEncrypted(c, key)
+
+	// the metadata isn't readable without the key
+	zr, err := zip.OpenReader(backend.Filename(shw))
+	c.Assert(err, check.IsNil)
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if f.Name == "encryption.json" {
+			continue
+		}
+		rc, err := f.Open()
+		c.Assert(err, check.IsNil)
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		c.Assert(err, check.IsNil)
+		c.Check(bytes.Contains(data, []byte("hello-snap")), check.Equals, false, check.Commentf(f.Name))
+		c.Check(bytes.Contains(data, []byte("hunter2")), check.Equals, false, check.Commentf(f.Name))
+	}
+	zr.Close()
+	c.Check(names, check.DeepEquals, []string{"encryption.json", "archive.tgz", "meta.json", "meta.sha3_384"})
+
+	// saving made the key available
+	shs, err := backend.List(context.TODO(), 0, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(shs, check.HasLen, 1)
+	c.Assert(shs[0].Snapshots, check.HasLen, 1)
+	c.Check(shs[0].Snapshots[0].Encrypted, check.Equals, true)
+	c.Check(shs[0].Snapshots[0].Conf, check.DeepEquals, shw.Conf)
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Encrypted, check.Equals, true)
+	c.Check(shr.Size, check.Equals, shw.Size)
+	c.Check(shr.SHA3_384, check.DeepEquals, shw.SHA3_384)
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+
+	newroot := c.MkDir()
+	dirs.SetRootDir(newroot)
+	defer dirs.SetRootDir(s.root)
+
+	logger.SimpleSetup()
+	rs, err := shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(rs, check.NotNil)
+
+	si := snap.MinimalPlaceInfo("hello-snap", snap.R(42))
+	c.Check(filepath.Join(si.DataDir(), "foo"), testutil.FileEquals, "versioned system canary\n")
+	c.Check(filepath.Join(si.CommonDataDir(), "bar"), testutil.FileEquals, "common system canary\n")
+}
+
+func (s *snapshotSuite) TestEncryptedKeyNotAvailable(c *check.C) {
+	key := s.mockEncryptionKey(c, 1)
+	defer backend.MockEncryptionKeys()()
+
+	shw := s.saveEncrypted(c, key)
+
+	// a different key doesn't help
+	backend.MockEncryptionKeys(s.mockEncryptionKey(c, 2))
+
+	broken := fmt.Sprintf(`cannot open encrypted snapshot: snapshot encryption key %s is not available`, key.ID())
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Check(err, check.ErrorMatches, broken)
+	// the snapshot is known from its filename, so it can still be
+	// listed and forgotten
+	c.Assert(shr, check.NotNil)
+	c.Check(shr.Broken, check.Equals, broken)
+	c.Check(shr.SetID, check.Equals, uint64(12))
+	c.Check(shr.Snap, check.Equals, "hello-snap")
+	c.Check(shr.Version, check.Equals, "v1.33")
+	c.Check(shr.Revision, check.Equals, snap.R(42))
+	c.Check(shr.Encrypted, check.Equals, true)
+	c.Check(shr.Name(), check.Equals, backend.Filename(shw))
+
+	shs, err := backend.List(context.TODO(), 0, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(shs, check.HasLen, 1)
+	c.Assert(shs[0].Snapshots, check.HasLen, 1)
+	c.Check(shs[0].ID, check.Equals, uint64(12))
+	c.Check(shs[0].Snapshots[0].Snap, check.Equals, "hello-snap")
+	c.Check(shs[0].Snapshots[0].Broken, check.Equals, broken)
+
+	backend.AddEncryptionKey(key)
+	_, err = backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Check(err, check.IsNil)
+}
+
+func (s *snapshotSuite) TestEncryptedKeyNotAvailableInstanceName(c *check.C) {
+	defer backend.MockEncryptionKeys()()
+
+	fn := filepath.Join(dirs.SnapshotsDir, "7_hello-snap_foo_v1.33_x2.zip")
+	c.Assert(os.MkdirAll(dirs.SnapshotsDir, 0755), check.IsNil)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("encryption.json")
+	c.Assert(err, check.IsNil)
+	_, err = w.Write([]byte(`{"format":1,"cipher":"aes-256-gcm","key-id":"unknown","salt":"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}`))
+	c.Assert(err, check.IsNil)
+	c.Assert(zw.Close(), check.IsNil)
+	c.Assert(ioutil.WriteFile(fn, buf.Bytes(), 0644), check.IsNil)
+
+	shr, err := backend.Open(fn, backend.ExtractFnameSetID)
+	c.Check(err, check.ErrorMatches, "cannot open encrypted snapshot: snapshot encryption key unknown is not available")
+	c.Assert(shr, check.NotNil)
+	c.Check(shr.SetID, check.Equals, uint64(7))
+	c.Check(shr.Snap, check.Equals, "hello-snap_foo")
+	c.Check(shr.Version, check.Equals, "v1.33")
+	c.Check(shr.Revision, check.Equals, snap.R("x2"))
+}
+
+func (s *snapshotSuite) TestEncryptedWithPassphrase(c *check.C) {
+	defer backend.MockPassphraseScryptN(2)()
+	defer backend.MockEncryptionKeys()()
+
+	salt := bytes.Repeat([]byte{1}, 32)
+	key, err := backend.NewPassphraseEncryptionKey("correct horse battery staple", salt)
+	c.Assert(err, check.IsNil)
+	// the salt makes for a different key
+	other, err := backend.NewPassphraseEncryptionKey("correct horse battery staple", bytes.Repeat([]byte{2}, 32))
+	c.Assert(err, check.IsNil)
+	c.Check(other.ID(), check.Not(check.Equals), key.ID())
+
+	shw := s.saveEncrypted(c, key)
+
+	// the salt is recorded in the snapshot
+	zr, err := zip.OpenReader(backend.Filename(shw))
+	c.Assert(err, check.IsNil)
+	rc, err := zr.File[0].Open()
+	c.Assert(err, check.IsNil)
+	var header map[string]interface{}
+	c.Assert(json.NewDecoder(rc).Decode(&header), check.IsNil)
+	rc.Close()
+	zr.Close()
+	c.Check(header["passphrase-salt"], check.Equals, base64.StdEncoding.EncodeToString(salt))
+
+	// without the key nor the passphrase the snapshot cannot be opened
+	backend.MockEncryptionKeys()
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Check(err, check.ErrorMatches, "cannot open encrypted snapshot: .* is not available")
+	c.Check(shr.Broken, check.Not(check.Equals), "")
+
+	// the key is derived from the passphrase with the recorded salt
+	backend.AddEncryptionPassphrase("another passphrase")
+	backend.AddEncryptionPassphrase("correct horse battery staple")
+	c.Check(backend.EncryptionPassphrase(), check.Equals, "correct horse battery staple")
+	shr, err = backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Snap, check.Equals, "hello-snap")
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+}
+
+func (s *snapshotSuite) TestEncryptedCheckTampered(c *check.C) {
+	key := s.mockEncryptionKey(c, 1)
+	defer backend.MockEncryptionKeys()()
+
+	shw := s.saveEncrypted(c, key)
+
+	// rewrite the snapshot flipping a bit of the encrypted archive
+	fn := backend.Filename(shw)
+	zr, err := zip.OpenReader(fn)
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		c.Assert(err, check.IsNil)
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		c.Assert(err, check.IsNil)
+		if f.Name == "archive.tgz" {
+			data[len(data)/2] ^= 1
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: f.Method})
+		c.Assert(err, check.IsNil)
+		_, err = w.Write(data)
+		c.Assert(err, check.IsNil)
+	}
+	zr.Close()
+	c.Assert(zw.Close(), check.IsNil)
+	c.Assert(ioutil.WriteFile(fn, buf.Bytes(), 0600), check.IsNil)
+
+	shr, err := backend.Open(fn, backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(context.TODO(), nil), check.ErrorMatches, "cannot decrypt snapshot member: .*")
+}
+
+func (s *snapshotSuite) TestChunkingWriter(c *check.C) {
+	data := make([]byte, 16*1024*1024)
+	rand.New(rand.NewSource(42)).Read(data)
+
+	out, ids, err := backend.ChunkData(data)
+	c.Assert(err, check.IsNil)
+	c.Check(len(ids) > 4, check.Equals, true, check.Commentf("%d chunks", len(ids)))
+
+	// the chunks together are a valid gzip stream of the data
+	gz, err := gzip.NewReader(bytes.NewReader(out))
+	c.Assert(err, check.IsNil)
+	unpacked, err := ioutil.ReadAll(gz)
+	c.Assert(err, check.IsNil)
+	c.Check(bytes.Equal(unpacked, data), check.Equals, true)
+
+	// the chunks are in the store
+	for _, id := range ids {
+		c.Check(filepath.Join(dirs.SnapshotsDir, "chunks", id[:2], id), testutil.FilePresent)
+	}
+
+	// inserting data only changes the chunk where it's inserted
+	changed := append(append(append([]byte(nil), data[:5*1024*1024]...), "inserted"...), data[5*1024*1024:]...)
+	_, changedIDs, err := backend.ChunkData(changed)
+	c.Assert(err, check.IsNil)
+	shared := 0
+	for _, id := range changedIDs {
+		if strutil.ListContains(ids, id) {
+			shared++
+		}
+	}
+	c.Check(shared >= len(ids)-2, check.Equals, true, check.Commentf("only %d of %d chunks shared", shared, len(ids)))
+
+	// chunks are never bigger than the maximum
+	_, zeroIDs, err := backend.ChunkData(make([]byte, 2*backend.MaxChunkSize+backend.MinChunkSize))
+	c.Assert(err, check.IsNil)
+	c.Check(zeroIDs, check.HasLen, 3)
+}
+
+func (s *snapshotSuite) saveDeduplicated(c *check.C, setID uint64) *client.Snapshot {
+	s.restore = append(s.restore, backend.MockTarAsUser(func(username string, args ...string) *exec.Cmd {
+		return exec.Command(s.tarPath, args...)
+	}), backend.MockUsersForUsernames(func(usernames []string, _ *dirs.SnapDirOptions) ([]*user.User, error) {
+		return nil, nil
+	}))
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+
+	shw, err := backend.Save(context.TODO(), setID, info, nil, nil, nil, nil, &backend.SaveFlags{Deduplicate: true})
+	c.Assert(err, check.IsNil)
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz"})
+	return shw
+}
+
+func zipMemberNames(c *check.C, fn string) []string {
+	zr, err := zip.OpenReader(fn)
+	c.Assert(err, check.IsNil)
+	defer zr.Close()
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func chunkFiles(c *check.C) []string {
+	chunks, err := filepath.Glob(filepath.Join(dirs.SnapshotsDir, "chunks", "*", "*"))
+	c.Assert(err, check.IsNil)
+	return chunks
+}
+
+func (s *snapshotSuite) TestDeduplicatedRoundtrip(c *check.C) {
+	shw1 := s.saveDeduplicated(c, 12)
+	c.Check(zipMemberNames(c, backend.Filename(shw1)), check.DeepEquals, []string{"archive.tgz.chunks", "meta.json", "meta.sha3_384"})
+	chunks := chunkFiles(c)
+	c.Check(chunks, check.Not(check.HasLen), 0)
+
+	// the data didn't change, so no chunks were added
+	shw2 := s.saveDeduplicated(c, 13)
+	c.Check(chunkFiles(c), check.DeepEquals, chunks)
+	c.Check(shw2.SHA3_384, check.DeepEquals, shw1.SHA3_384)
+	c.Check(shw2.Size, check.Equals, shw1.Size)
+
+	shr, err := backend.Open(backend.Filename(shw2), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+
+	snapshotsDir := dirs.SnapshotsDir
+	newroot := c.MkDir()
+	dirs.SetRootDir(newroot)
+	defer dirs.SetRootDir(s.root)
+	// the chunk store is next to the snapshots
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapshotsDir), 0755), check.IsNil)
+	c.Assert(os.Symlink(snapshotsDir, dirs.SnapshotsDir), check.IsNil)
+
+	logger.SimpleSetup()
+	rs, err := shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(rs, check.NotNil)
+
+	si := snap.MinimalPlaceInfo("hello-snap", snap.R(42))
+	c.Check(filepath.Join(si.DataDir(), "foo"), testutil.FileEquals, "versioned system canary\n")
+	c.Check(filepath.Join(si.CommonDataDir(), "bar"), testutil.FileEquals, "common system canary\n")
+}
+
+func (s *snapshotSuite) TestDeduplicatedMissingChunk(c *check.C) {
+	shw := s.saveDeduplicated(c, 12)
+	for _, chunk := range chunkFiles(c) {
+		c.Assert(os.Remove(chunk), check.IsNil)
+	}
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(context.TODO(), nil), check.ErrorMatches, "cannot open snapshot chunk: .*")
+}
+
+func (s *snapshotSuite) TestDeduplicatedExportImport(c *check.C) {
+	ctx := context.TODO()
+	shw := s.saveDeduplicated(c, 12)
+
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	c.Assert(err, check.IsNil)
+	defer export.Close()
+	c.Assert(export.Init(), check.IsNil)
+	buf := bytes.NewBuffer(nil)
+	c.Assert(export.StreamTo(buf), check.IsNil)
+	c.Check(buf.Len(), check.Equals, int(export.Size()))
+	exported := buf.Bytes()
+
+	// the exported snapshot is the same set
+	_, err = backend.Import(ctx, 123, bytes.NewReader(exported), nil)
+	c.Assert(err, check.FitsTypeOf, backend.DuplicatedSnapshotImportError{})
+	c.Check(err.(backend.DuplicatedSnapshotImportError).SetID, check.Equals, uint64(12))
+
+	// and is self-contained
+	c.Assert(os.Remove(backend.Filename(shw)), check.IsNil)
+	_, err = backend.PruneChunks(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(chunkFiles(c), check.HasLen, 0)
+
+	names, err := backend.Import(ctx, 123, bytes.NewReader(exported), nil)
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"hello-snap"})
+
+	fn := filepath.Join(dirs.SnapshotsDir, "123_hello-snap_v1.33_42.zip")
+	c.Check(zipMemberNames(c, fn), check.DeepEquals, []string{"archive.tgz", "meta.json", "meta.sha3_384"})
+	shr, err := backend.Open(fn, backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.SHA3_384, check.DeepEquals, shw.SHA3_384)
+	c.Check(shr.Check(ctx, nil), check.IsNil)
+}
+
+func (s *snapshotSuite) TestPruneChunks(c *check.C) {
+	ctx := context.TODO()
+
+	// nothing to do without a chunk store
+	pruned, err := backend.PruneChunks(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(pruned, check.Equals, 0)
+
+	shw1 := s.saveDeduplicated(c, 12)
+	chunks1 := chunkFiles(c)
+
+	si := snap.MinimalPlaceInfo("hello-snap", snap.R(42))
+	c.Assert(ioutil.WriteFile(filepath.Join(si.DataDir(), "foo"), []byte("changed\n"), 0644), check.IsNil)
+	shw2 := s.saveDeduplicated(c, 13)
+	c.Check(len(chunkFiles(c)) > len(chunks1), check.Equals, true)
+
+	// all chunks are in use
+	pruned, err = backend.PruneChunks(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(pruned, check.Equals, 0)
+
+	// nothing is pruned if a snapshot can't be read
+	broken := filepath.Join(dirs.SnapshotsDir, "14_broken_1_1.zip")
+	c.Assert(ioutil.WriteFile(broken, []byte("not a zip"), 0600), check.IsNil)
+	c.Assert(os.Remove(backend.Filename(shw1)), check.IsNil)
+	_, err = backend.PruneChunks(ctx)
+	c.Assert(err, check.ErrorMatches, `cannot prune snapshot chunks: cannot read snapshot ".*/14_broken_1_1.zip": .*`)
+	c.Assert(os.Remove(broken), check.IsNil)
+
+	// only the chunks of the first snapshot are pruned
+	pruned, err = backend.PruneChunks(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(pruned, check.Equals, len(chunks1))
+
+	shr, err := backend.Open(backend.Filename(shw2), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(ctx, nil), check.IsNil)
+}
@@ -0,0 +1,376 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+const (
+	// chunksDirName is the directory, under the snapshots directory, of
+	// the content-addressed store of the chunks of deduplicated snapshots.
+	chunksDirName = "chunks"
+
+	// a deduplicated archive is stored as an index of its chunks, in a
+	// member named after the archive with this suffix
+	chunkIndexSuffix = ".chunks"
+	chunkIndexFormat = 1
+
+	// chunk boundaries are content-defined, so that the chunks of data
+	// that didn't change are the same across snapshots even if data was
+	// inserted or removed before them; chunks are ~1MiB on average
+	minChunkSize      = 256 * 1024
+	maxChunkSize      = 4 * 1024 * 1024
+	chunkBoundaryMask = 1<<20 - 1
+)
+
+var (
+	// chunkStoreMu is held for reading while saving deduplicated
+	// snapshots, and for writing while pruning the chunk store, so that
+	// the chunks of snapshots being saved are not pruned.
+	chunkStoreMu sync.RWMutex
+
+	// gearTable maps bytes to the random-looking values of the rolling
+	// hash used to find chunk boundaries; it must never change as
+	// otherwise chunks would no longer be shared with older snapshots.
+	gearTable [256]uint64
+)
+
+func init() {
+	for i := range gearTable {
+		h := sha256.Sum256([]byte(fmt.Sprintf("snapd snapshot chunk %d", i)))
+		gearTable[i] = binary.BigEndian.Uint64(h[:8])
+	}
+}
+
+func chunksDir() string {
+	return filepath.Join(dirs.SnapshotsDir, chunksDirName)
+}
+
+func chunkPath(id string) string {
+	return filepath.Join(chunksDir(), id[:2], id)
+}
+
+type chunkRef struct {
+	ID   string `json:"id"`
+	Size int64  `json:"size"`
+}
+
+type chunkIndex struct {
+	Format int        `json:"format"`
+	Chunks []chunkRef `json:"chunks"`
+}
+
+func (idx *chunkIndex) size() int64 {
+	var sz int64
+	for _, chunk := range idx.Chunks {
+		sz += chunk.Size
+	}
+	return sz
+}
+
+func isValidChunkID(id string) bool {
+	if len(id) != crypto.SHA3_384.Size()*2 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func readChunkIndex(r io.Reader) (*chunkIndex, error) {
+	var idx chunkIndex
+	if err := json.NewDecoder(r).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("cannot decode snapshot chunk index: %v", err)
+	}
+	if idx.Format != chunkIndexFormat {
+		return nil, fmt.Errorf("unsupported snapshot chunk index format %d", idx.Format)
+	}
+	for _, chunk := range idx.Chunks {
+		if !isValidChunkID(chunk.ID) {
+			return nil, fmt.Errorf("invalid snapshot chunk id %q", chunk.ID)
+		}
+	}
+	return &idx, nil
+}
+
+// chunkingWriter splits what's written to it in content-defined chunks,
+// compresses each of them and adds them to the chunk store unless they are
+// already there. The compressed chunks are also written to out, in order,
+// which together are a valid (multi-member) gzip stream of the data. It must
+// be closed to write out the last chunk.
+type chunkingWriter struct {
+	out   io.Writer
+	buf   []byte
+	hash  uint64
+	index chunkIndex
+}
+
+func newChunkingWriter(out io.Writer) *chunkingWriter {
+	return &chunkingWriter{
+		out:   out,
+		buf:   make([]byte, 0, maxChunkSize),
+		index: chunkIndex{Format: chunkIndexFormat},
+	}
+}
+
+// boundary returns how much of p belongs to the current chunk, and whether
+// the chunk ends there.
+func (cw *chunkingWriter) boundary(p []byte) (int, bool) {
+	size := len(cw.buf)
+	for i, b := range p {
+		cw.hash = (cw.hash << 1) + gearTable[b]
+		size++
+		if size >= maxChunkSize || (size >= minChunkSize && cw.hash&chunkBoundaryMask == 0) {
+			return i + 1, true
+		}
+	}
+	return len(p), false
+}
+
+func (cw *chunkingWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n, end := cw.boundary(p)
+		cw.buf = append(cw.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if end {
+			if err := cw.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (cw *chunkingWriter) flush() error {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(cw.buf); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	hasher := crypto.SHA3_384.New()
+	hasher.Write(compressed.Bytes())
+	id := fmt.Sprintf("%x", hasher.Sum(nil))
+	if err := storeChunk(id, compressed.Bytes()); err != nil {
+		return err
+	}
+	if _, err := cw.out.Write(compressed.Bytes()); err != nil {
+		return err
+	}
+
+	cw.index.Chunks = append(cw.index.Chunks, chunkRef{ID: id, Size: int64(compressed.Len())})
+	cw.buf = cw.buf[:0]
+	cw.hash = 0
+	return nil
+}
+
+func (cw *chunkingWriter) Close() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	return cw.flush()
+}
+
+func storeChunk(id string, data []byte) error {
+	p := chunkPath(id)
+	if osutil.FileExists(p) {
+		// deduplicated
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(p, data, 0600, 0); err != nil {
+		return fmt.Errorf("cannot store snapshot chunk: %v", err)
+	}
+	return nil
+}
+
+// chunkReader reads the data of the chunks of an index, in order.
+type chunkReader struct {
+	chunks []chunkRef
+	cur    *os.File
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if cr.cur == nil {
+			if len(cr.chunks) == 0 {
+				return 0, io.EOF
+			}
+			f, err := os.Open(chunkPath(cr.chunks[0].ID))
+			if err != nil {
+				return 0, fmt.Errorf("cannot open snapshot chunk: %v", err)
+			}
+			cr.cur = f
+			cr.chunks = cr.chunks[1:]
+		}
+		n, err := cr.cur.Read(p)
+		if err == io.EOF {
+			cr.cur.Close()
+			cr.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (cr *chunkReader) Close() error {
+	if cr.cur == nil {
+		return nil
+	}
+	err := cr.cur.Close()
+	cr.cur = nil
+	return err
+}
+
+// chunkedMember returns a reader for the data of the given member of a
+// deduplicated snapshot, and its size. If the member is not deduplicated, it
+// returns a nil reader.
+func chunkedMember(f *os.File, member string) (io.ReadCloser, int64, error) {
+	idxReader, _, err := zipMember(f, member+chunkIndexSuffix)
+	if err != nil {
+		// not deduplicated
+		return nil, -1, nil
+	}
+	defer idxReader.Close()
+	idx, err := readChunkIndex(idxReader)
+	if err != nil {
+		return nil, -1, err
+	}
+	return &chunkReader{chunks: idx.Chunks}, idx.size(), nil
+}
+
+// referencedChunks adds the chunks referenced by the given snapshot file to
+// the set.
+func referencedChunks(fn string, referenced map[string]bool) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	arch, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return err
+	}
+	for _, fh := range arch.File {
+		if filepath.Ext(fh.Name) != chunkIndexSuffix {
+			continue
+		}
+		r, err := fh.Open()
+		if err != nil {
+			return err
+		}
+		idx, err := readChunkIndex(r)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("%q: %v", fh.Name, err)
+		}
+		for _, chunk := range idx.Chunks {
+			referenced[chunk.ID] = true
+		}
+	}
+	return nil
+}
+
+// PruneChunks removes the chunks that are no longer referenced by any
+// snapshot from the chunk store. Nothing is removed if any of the snapshots
+// can't be read, as its chunks might still be needed.
+func PruneChunks(ctx context.Context) (pruned int, err error) {
+	chunkStoreMu.Lock()
+	defer chunkStoreMu.Unlock()
+
+	if exists, _, _ := osutil.DirExists(chunksDir()); !exists {
+		return 0, nil
+	}
+
+	snapshotFiles, err := filepathGlob(filepath.Join(dirs.SnapshotsDir, "*.zip"))
+	if err != nil {
+		return 0, err
+	}
+	referenced := make(map[string]bool)
+	for _, fn := range snapshotFiles {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if ok, _ := isSnapshotFilename(fn); !ok {
+			continue
+		}
+		if err := referencedChunks(fn, referenced); err != nil {
+			return 0, fmt.Errorf("cannot prune snapshot chunks: cannot read snapshot %q: %v", fn, err)
+		}
+	}
+
+	chunkFiles, err := filepathGlob(filepath.Join(chunksDir(), "*", "*"))
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	for _, p := range chunkFiles {
+		id := filepath.Base(p)
+		if referenced[id] {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pruned++
+	}
+	if len(errs) > 0 {
+		return pruned, newMultiError("cannot prune snapshot chunks", errs)
+	}
+	if pruned > 0 {
+		logger.Debugf("Pruned %d unused snapshot chunks.", pruned)
+	}
+	return pruned, nil
+}
@@ -0,0 +1,415 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// encryptionName is the archive member describing how the rest of
+	// the members of an encrypted snapshot are encrypted; it's the only
+	// member that isn't encrypted itself.
+	encryptionName = "encryption.json"
+
+	// EncryptionKeySize is the size, in bytes, of snapshot encryption keys.
+	EncryptionKeySize = 32
+
+	encryptionFormat = 1
+	encryptionCipher = "aes-256-gcm"
+
+	// members are encrypted in chunks of this size, each with its own
+	// authentication tag, so they can be streamed
+	encryptedChunkSize = 64 * 1024
+	encryptionOverhead = 16
+	encryptionSaltSize = 32
+)
+
+var (
+	randRead = rand.Read
+
+	// scrypt cost parameter used to derive keys from passphrases
+	passphraseScryptN = 1 << 15
+)
+
+// EncryptionKey is a key used to encrypt snapshots at rest.
+type EncryptionKey struct {
+	id  string
+	key []byte
+	// passphraseSalt is the salt the key was derived from a passphrase
+	// with, if it was
+	passphraseSalt []byte
+}
+
+// NewEncryptionKey returns an EncryptionKey for the given key material, which
+// must be EncryptionKeySize bytes long.
+func NewEncryptionKey(key []byte) (*EncryptionKey, error) {
+	if len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("invalid snapshot encryption key size %d, expected %d", len(key), EncryptionKeySize)
+	}
+	h := crypto.SHA3_384.New()
+	h.Write([]byte("snapd snapshot key id\x00"))
+	h.Write(key)
+	return &EncryptionKey{
+		id:  fmt.Sprintf("%x", h.Sum(nil)[:16]),
+		key: append([]byte(nil), key...),
+	}, nil
+}
+
+// ID returns a public identifier of the key, it's recorded in the snapshots
+// encrypted with it.
+func (k *EncryptionKey) ID() string {
+	return k.id
+}
+
+// passphraseKeys caches the keys derived from passphrases as deriving them
+// is slow by design, by passphrase and salt
+var passphraseKeys struct {
+	mu   sync.Mutex
+	keys map[[sha256.Size]byte]*EncryptionKey
+}
+
+// NewPassphraseEncryptionKey returns the EncryptionKey derived from the given
+// passphrase and salt. The salt is recorded in the snapshots encrypted with
+// the key, so the key can be derived again to open them.
+func NewPassphraseEncryptionKey(passphrase string, salt []byte) (*EncryptionKey, error) {
+	passphraseKeys.mu.Lock()
+	defer passphraseKeys.mu.Unlock()
+
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte{0})
+	h.Write([]byte(passphrase))
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	if key := passphraseKeys.keys[sum]; key != nil {
+		return key, nil
+	}
+	material, err := scrypt.Key([]byte(passphrase), salt, passphraseScryptN, 8, 1, EncryptionKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("cannot derive snapshot encryption key from passphrase: %v", err)
+	}
+	key, err := NewEncryptionKey(material)
+	if err != nil {
+		return nil, err
+	}
+	key.passphraseSalt = append([]byte(nil), salt...)
+	if passphraseKeys.keys == nil {
+		passphraseKeys.keys = make(map[[sha256.Size]byte]*EncryptionKey)
+	}
+	passphraseKeys.keys[sum] = key
+	return key, nil
+}
+
+var (
+	keyringMu sync.Mutex
+	keyring   = map[string]*EncryptionKey{}
+	// passphrases holds the passphrases snapshot keys may be derived
+	// from, the last one being the current one; they are only kept in
+	// memory
+	passphrases []string
+)
+
+// AddEncryptionKey makes the key available for opening the snapshots that were
+// encrypted with it.
+func AddEncryptionKey(key *EncryptionKey) {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	keyring[key.id] = key
+}
+
+// AddEncryptionPassphrase makes the passphrase available for opening the
+// snapshots that were encrypted with a key derived from it, and makes it the
+// one returned by EncryptionPassphrase. The passphrase is only kept in memory.
+func AddEncryptionPassphrase(passphrase string) {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	for i, p := range passphrases {
+		if p == passphrase {
+			passphrases = append(passphrases[:i], passphrases[i+1:]...)
+			break
+		}
+	}
+	passphrases = append(passphrases, passphrase)
+}
+
+// EncryptionPassphrase returns the passphrase added last, or an empty string
+// if none was added.
+func EncryptionPassphrase() string {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	if len(passphrases) == 0 {
+		return ""
+	}
+	return passphrases[len(passphrases)-1]
+}
+
+func encryptionKeyByID(id string) *EncryptionKey {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	return keyring[id]
+}
+
+// passphraseEncryptionKey returns the key with the given id derived from one
+// of the known passphrases with the given salt, or nil if there is none.
+func passphraseEncryptionKey(id string, salt []byte) (*EncryptionKey, error) {
+	keyringMu.Lock()
+	candidates := append([]string(nil), passphrases...)
+	keyringMu.Unlock()
+
+	for i := len(candidates) - 1; i >= 0; i-- {
+		key, err := NewPassphraseEncryptionKey(candidates[i], salt)
+		if err != nil {
+			return nil, err
+		}
+		if key.id == id {
+			AddEncryptionKey(key)
+			return key, nil
+		}
+	}
+	return nil, nil
+}
+
+type encryptionHeader struct {
+	Format int    `json:"format"`
+	Cipher string `json:"cipher"`
+	KeyID  string `json:"key-id"`
+	Salt   []byte `json:"salt"`
+	// PassphraseSalt is the salt the key was derived from a passphrase
+	// with, if it was.
+	PassphraseSalt []byte `json:"passphrase-salt,omitempty"`
+}
+
+// snapshotCipher encrypts and decrypts the members of a single snapshot;
+// each member is encrypted with its own key derived from the snapshot key,
+// the salt of the snapshot and the member name.
+type snapshotCipher struct {
+	header encryptionHeader
+	key    *EncryptionKey
+}
+
+func newSnapshotCipher(key *EncryptionKey) (*snapshotCipher, error) {
+	salt := make([]byte, encryptionSaltSize)
+	if _, err := randRead(salt); err != nil {
+		return nil, fmt.Errorf("cannot generate snapshot encryption salt: %v", err)
+	}
+	return &snapshotCipher{
+		header: encryptionHeader{
+			Format: encryptionFormat,
+			Cipher: encryptionCipher,
+			KeyID:  key.id,
+			Salt:   salt,
+
+			PassphraseSalt: key.passphraseSalt,
+		},
+		key: key,
+	}, nil
+}
+
+// readSnapshotCipher returns the cipher of an encrypted snapshot given its
+// encryption header.
+func readSnapshotCipher(r io.Reader) (*snapshotCipher, error) {
+	var header encryptionHeader
+	if err := json.NewDecoder(r).Decode(&header); err != nil {
+		return nil, fmt.Errorf("cannot decode snapshot encryption header: %v", err)
+	}
+	if header.Format != encryptionFormat || header.Cipher != encryptionCipher {
+		return nil, fmt.Errorf("unsupported snapshot encryption format %d (%q)", header.Format, header.Cipher)
+	}
+	if len(header.Salt) != encryptionSaltSize {
+		return nil, fmt.Errorf("invalid snapshot encryption salt")
+	}
+	key := encryptionKeyByID(header.KeyID)
+	if key == nil && len(header.PassphraseSalt) != 0 {
+		var err error
+		key, err = passphraseEncryptionKey(header.KeyID, header.PassphraseSalt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("snapshot encryption key %s is not available", header.KeyID)
+	}
+	return &snapshotCipher{header: header, key: key}, nil
+}
+
+func (c *snapshotCipher) writeHeader(w io.Writer) error {
+	return json.NewEncoder(w).Encode(&c.header)
+}
+
+func (c *snapshotCipher) memberAEAD(member string) (cipher.AEAD, error) {
+	kdf := hkdf.New(sha256.New, c.key.key, c.header.Salt, []byte("snapd snapshot member\x00"+member))
+	memberKey := make([]byte, EncryptionKeySize)
+	if _, err := io.ReadFull(kdf, memberKey); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(memberKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce of the n-th chunk of a member; the last chunk
+// is marked so that truncated members are detected.
+func chunkNonce(size int, n uint64, last bool) []byte {
+	nonce := make([]byte, size)
+	binary.BigEndian.PutUint64(nonce, n)
+	if last {
+		nonce[size-1] = 1
+	}
+	return nonce
+}
+
+// encryptingWriter encrypts what's written to it in chunks. It must be closed
+// to write out the last chunk.
+type encryptingWriter struct {
+	w    io.Writer
+	aead cipher.AEAD
+	buf  []byte
+	n    uint64
+}
+
+func (c *snapshotCipher) encryptingWriter(w io.Writer, member string) (io.WriteCloser, error) {
+	aead, err := c.memberAEAD(member)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{w: w, aead: aead, buf: make([]byte, 0, encryptedChunkSize)}, nil
+}
+
+func (ew *encryptingWriter) flush(last bool) error {
+	sealed := ew.aead.Seal(nil, chunkNonce(ew.aead.NonceSize(), ew.n, last), ew.buf, nil)
+	if _, err := ew.w.Write(sealed); err != nil {
+		return err
+	}
+	ew.n++
+	ew.buf = ew.buf[:0]
+	return nil
+}
+
+func (ew *encryptingWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if len(ew.buf) == encryptedChunkSize {
+			if err := ew.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(ew.buf[len(ew.buf):encryptedChunkSize], p)
+		ew.buf = ew.buf[:len(ew.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (ew *encryptingWriter) Close() error {
+	return ew.flush(true)
+}
+
+type decryptingReader struct {
+	r    *bufio.Reader
+	aead cipher.AEAD
+	buf  []byte
+	out  []byte
+	n    uint64
+	done bool
+}
+
+func (c *snapshotCipher) decryptingReader(r io.Reader, member string) (io.Reader, error) {
+	aead, err := c.memberAEAD(member)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{
+		r:    bufio.NewReader(r),
+		aead: aead,
+		buf:  make([]byte, encryptedChunkSize+encryptionOverhead),
+	}, nil
+}
+
+func (dr *decryptingReader) Read(p []byte) (int, error) {
+	for len(dr.out) == 0 {
+		if dr.done {
+			return 0, io.EOF
+		}
+		if err := dr.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, dr.out)
+	dr.out = dr.out[n:]
+	return n, nil
+}
+
+func (dr *decryptingReader) next() error {
+	n, err := io.ReadFull(dr.r, dr.buf)
+	switch err {
+	case nil:
+	case io.ErrUnexpectedEOF:
+		// a short chunk can only be the last one
+	case io.EOF:
+		return errors.New("encrypted snapshot member is truncated")
+	default:
+		return err
+	}
+	// a full chunk is the last one if nothing follows it
+	last := n < len(dr.buf)
+	if !last {
+		if _, err := dr.r.Peek(1); err == io.EOF {
+			last = true
+		}
+	}
+	plain, err := dr.aead.Open(dr.buf[:0], chunkNonce(dr.aead.NonceSize(), dr.n, last), dr.buf[:n], nil)
+	if err != nil {
+		return fmt.Errorf("cannot decrypt snapshot member: %v", err)
+	}
+	dr.n++
+	dr.out = plain
+	dr.done = last
+	return nil
+}
+
+// plaintextSize returns the size of the data of an encrypted member given
+// its encrypted size.
+func plaintextSize(encryptedSize int64) int64 {
+	const chunk = encryptedChunkSize + encryptionOverhead
+	chunks := (encryptedSize + chunk - 1) / chunk
+	if chunks == 0 {
+		// not even the last chunk is there
+		return -1
+	}
+	return encryptedSize - chunks*encryptionOverhead
+}
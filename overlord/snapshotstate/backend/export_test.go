@@ -20,6 +20,11 @@
 package backend
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"os/user"
@@ -39,10 +44,12 @@ var (
 	IsSnapshotFilename = isSnapshotFilename
 
 	NewMultiError = newMultiError
-
-	AddSnapDirToZip = addSnapDirToZip
 )
 
+func AddSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry, snapDir string, savingUserData bool, excludePaths []string) error {
+	return addSnapDirToZip(ctx, snapshot, &snapshotWriter{Writer: w}, username, entry, snapDir, savingUserData, excludePaths)
+}
+
 func MockIsTesting(newIsTesting bool) func() {
 	oldIsTesting := isTesting
 	isTesting = newIsTesting
@@ -155,3 +162,80 @@ func MockReadSnapshotYaml(f func(si *snap.Info) (*snap.SnapshotOptions, error))
 		snapReadSnapshotYaml = oldReadSnapshotYaml
 	}
 }
+
+func MockEncryptionKeys(keys ...*EncryptionKey) (restore func()) {
+	keyringMu.Lock()
+	old, oldPassphrases := keyring, passphrases
+	keyring = map[string]*EncryptionKey{}
+	passphrases = nil
+	keyringMu.Unlock()
+	for _, key := range keys {
+		AddEncryptionKey(key)
+	}
+	return func() {
+		keyringMu.Lock()
+		defer keyringMu.Unlock()
+		keyring, passphrases = old, oldPassphrases
+	}
+}
+
+func MockPassphraseScryptN(n int) (restore func()) {
+	r := testutil.Backup(&passphraseScryptN)
+	passphraseScryptN = n
+	return r
+}
+
+func EncryptDecrypt(key *EncryptionKey, data []byte, mangle func([]byte) []byte) ([]byte, error) {
+	sc, err := newSnapshotCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w, err := sc.encryptingWriter(&buf, "member")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	encrypted := buf.Bytes()
+	if int64(len(data)) != plaintextSize(int64(len(encrypted))) {
+		return nil, fmt.Errorf("unexpected plaintext size %d for %d bytes", plaintextSize(int64(len(encrypted))), len(data))
+	}
+	if mangle != nil {
+		encrypted = mangle(encrypted)
+	}
+	r, err := sc.decryptingReader(bytes.NewReader(encrypted), "member")
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+const EncryptedChunkSize = encryptedChunkSize
+
+// ChunkData returns what chunkingWriter writes out for data, and the ids of
+// the chunks it was split into.
+func ChunkData(data []byte) ([]byte, []string, error) {
+	var buf bytes.Buffer
+	cw := newChunkingWriter(&buf)
+	if _, err := cw.Write(data); err != nil {
+		return nil, nil, err
+	}
+	if err := cw.Close(); err != nil {
+		return nil, nil, err
+	}
+	var ids []string
+	for _, chunk := range cw.index.Chunks {
+		ids = append(ids, chunk.ID)
+	}
+	return buf.Bytes(), ids, nil
+}
+
+const (
+	MinChunkSize = minChunkSize
+	MaxChunkSize = maxChunkSize
+)
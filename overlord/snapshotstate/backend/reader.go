@@ -31,6 +31,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"syscall"
 
 	"github.com/snapcore/snapd/client"
@@ -51,6 +52,9 @@ const ExtractFnameSetID = 0
 type Reader struct {
 	*os.File
 	client.Snapshot
+
+	// cipher is set if the snapshot is encrypted
+	cipher *snapshotCipher
 }
 
 // Open a Snapshot given its full filename.
@@ -80,18 +84,37 @@ func Open(fn string, setID uint64) (reader *Reader, e error) {
 		File: f,
 	}
 
+	// if the snapshot is encrypted, so is its metadata
+	if encReader, _, err := zipMember(f, encryptionName); err == nil {
+		reader.cipher, err = readSnapshotCipher(encReader)
+		encReader.Close()
+		if err != nil {
+			// without the key only what the filename tells about
+			// the snapshot is known, but that's enough to list
+			// and forget it
+			if err := snapshotFromFilename(fn, setID, &reader.Snapshot); err != nil {
+				return nil, err
+			}
+			reader.Encrypted = true
+			reader.Broken = fmt.Sprintf("cannot open encrypted snapshot: %v", err)
+			return reader, errors.New(reader.Broken)
+		}
+	}
+
 	// first try to load the metadata itself
 	var sz osutil.Sizer
 	hasher := crypto.SHA3_384.New()
-	metaReader, metaSize, err := zipMember(f, metadataName)
+	metaReader, metaSize, err := reader.member(metadataName)
 	if err != nil {
 		// no metadata file -> nothing to do :-(
 		return nil, err
 	}
+	defer metaReader.Close()
 
 	if err := jsonutil.DecodeWithNumber(io.TeeReader(metaReader, io.MultiWriter(hasher, &sz)), &reader.Snapshot); err != nil {
 		return nil, err
 	}
+	reader.Encrypted = reader.cipher != nil
 
 	if setID == ExtractFnameSetID {
 		// set id from the filename has the authority and overrides the one from
@@ -121,11 +144,12 @@ func Open(fn string, setID uint64) (reader *Reader, e error) {
 
 	// grab the metadata hash
 	sz.Reset()
-	metaHashReader, metaHashSize, err := zipMember(f, metaHashName)
+	metaHashReader, metaHashSize, err := reader.member(metaHashName)
 	if err != nil {
 		reader.Broken = err.Error()
 		return reader, err
 	}
+	defer metaHashReader.Close()
 	metaHashBuf, err := ioutil.ReadAll(io.TeeReader(metaHashReader, &sz))
 	if err != nil {
 		reader.Broken = err.Error()
@@ -143,8 +167,63 @@ func Open(fn string, setID uint64) (reader *Reader, e error) {
 	return reader, nil
 }
 
+// snapshotFromFilename fills in the set id, snap name, version and revision of
+// the snapshot from its filename, as built by Filename. The set id is set to
+// the given one unless it's ExtractFnameSetID.
+func snapshotFromFilename(fn string, setID uint64, sh *client.Snapshot) error {
+	ok, fnameSetID := isSnapshotFilename(fn)
+	if !ok {
+		return fmt.Errorf("not a snapshot filename: %q", fn)
+	}
+	if setID == ExtractFnameSetID {
+		setID = fnameSetID
+	}
+	// "<sid>_<snapName>_<version>_<revision>.zip", the instance key of
+	// the snap name is also separated with an underscore
+	parts := strings.Split(strings.TrimSuffix(filepath.Base(fn), ".zip"), "_")
+	if len(parts) < 4 {
+		return fmt.Errorf("not a snapshot filename: %q", fn)
+	}
+	rev, err := snap.ParseRevision(parts[len(parts)-1])
+	if err != nil {
+		return fmt.Errorf("not a snapshot filename: %q", fn)
+	}
+	sh.SetID = setID
+	sh.Snap = strings.Join(parts[1:len(parts)-2], "_")
+	sh.Version = parts[len(parts)-2]
+	sh.Revision = rev
+	return nil
+}
+
+type memberReader struct {
+	io.Reader
+	io.Closer
+}
+
+// member returns a reader for the given archive member, decrypting it if
+// the snapshot is encrypted or reading it from the chunk store if it's
+// deduplicated, and the size of its (decrypted) data.
+func (r *Reader) member(name string) (body io.ReadCloser, sz int64, err error) {
+	if r.cipher == nil {
+		body, sz, err = chunkedMember(r.File, name)
+		if body != nil || err != nil {
+			return body, sz, err
+		}
+	}
+	body, sz, err = zipMember(r.File, name)
+	if err != nil || r.cipher == nil {
+		return body, sz, err
+	}
+	dec, err := r.cipher.decryptingReader(body, name)
+	if err != nil {
+		body.Close()
+		return nil, -1, err
+	}
+	return memberReader{Reader: dec, Closer: body}, plaintextSize(sz), nil
+}
+
 func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash) error {
-	body, reportedSize, err := zipMember(r.File, entry)
+	body, reportedSize, err := r.member(entry)
 	if err != nil {
 		return err
 	}
@@ -311,10 +390,11 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 
 		logger.Debugf("Restoring %q from %q into %q.", entry, r.Name(), tempdir)
 
-		body, expectedSize, err := zipMember(r.File, entry)
+		body, expectedSize, err := r.member(entry)
 		if err != nil {
 			return rs, err
 		}
+		defer body.Close()
 
 		expectedHash := r.SHA3_384[entry]
 
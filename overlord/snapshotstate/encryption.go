@@ -0,0 +1,272 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget/device"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	backendAddEncryptionKey           = backend.AddEncryptionKey
+	backendAddEncryptionPassphrase    = backend.AddEncryptionPassphrase
+	backendEncryptionPassphrase       = backend.EncryptionPassphrase
+	backendNewPassphraseEncryptionKey = backend.NewPassphraseEncryptionKey
+	randRead                          = rand.Read
+)
+
+// size of the salt keys are derived from passphrases with
+const passphraseSaltSize = 32
+
+// minimum length of the passphrase set with snapshots.encryption-passphrase
+const minEncryptionPassphraseLen = 8
+
+// ValidateEncryptionPassphrase checks that the passphrase can be set with
+// snapshots.encryption-passphrase.
+func ValidateEncryptionPassphrase(passphrase string) error {
+	if len(passphrase) < minEncryptionPassphraseLen {
+		return fmt.Errorf("snapshots.encryption-passphrase must be at least %d characters long", minEncryptionPassphraseLen)
+	}
+	return nil
+}
+
+type pendingPassphraseKey struct {
+	taskID string
+}
+
+// SetPendingEncryptionPassphrase keeps the passphrase set with
+// snapshots.encryption-passphrase by the configure task t in memory, until the
+// task commits the configuration and ApplyPendingEncryptionPassphrase is
+// called. The passphrase must never be stored in the state, so it must not be
+// part of the configuration patch of the task. The state must be locked by
+// the caller.
+func SetPendingEncryptionPassphrase(t *state.Task, passphrase string) {
+	t.State().Cache(pendingPassphraseKey{t.ID()}, passphrase)
+}
+
+// PendingEncryptionPassphrase returns the passphrase set by the configure task
+// t that was not applied yet, if any. The state must be locked by the caller.
+func PendingEncryptionPassphrase(t *state.Task) string {
+	passphrase, _ := t.State().Cached(pendingPassphraseKey{t.ID()}).(string)
+	return passphrase
+}
+
+// ApplyPendingEncryptionPassphrase makes the passphrase set by the configure
+// task t, if any, the one new snapshots are encrypted with when
+// snapshots.encryption is "passphrase". It must only be called once the
+// configuration of the task was committed. The state must be locked by the
+// caller.
+func ApplyPendingEncryptionPassphrase(t *state.Task) error {
+	passphrase := PendingEncryptionPassphrase(t)
+	if passphrase == "" {
+		return nil
+	}
+	t.State().Cache(pendingPassphraseKey{t.ID()}, nil)
+	if err := ValidateEncryptionPassphrase(passphrase); err != nil {
+		return err
+	}
+	backendAddEncryptionPassphrase(passphrase)
+	return nil
+}
+
+// sealedKeyFile is where the key used for "sealed" snapshot encryption is
+// kept; it's on ubuntu-save so it's protected by the keys sealed by secboot
+// on encrypted devices.
+func sealedKeyFile() string {
+	return filepath.Join(dirs.SnapDeviceSaveDir, "snapshots.key")
+}
+
+// passphraseSalt returns the salt the keys of this device are derived from
+// passphrases with, generating it first if needed and create is set. If the
+// salt doesn't exist and create is not set, it returns nil. The salt is
+// recorded in the snapshots, so it's not secret. The state must be locked by
+// the caller.
+func passphraseSalt(st *state.State, create bool) ([]byte, error) {
+	var salt []byte
+	err := st.Get("snapshots-passphrase-salt", &salt)
+	if err == nil || !errors.Is(err, state.ErrNoState) {
+		return salt, err
+	}
+	if !create {
+		return nil, nil
+	}
+	salt = make([]byte, passphraseSaltSize)
+	if _, err := randRead(salt); err != nil {
+		return nil, fmt.Errorf("cannot generate snapshot passphrase salt: %v", err)
+	}
+	st.Set("snapshots-passphrase-salt", salt)
+	return salt, nil
+}
+
+// passphraseEncryptionKey returns the key derived from the passphrase set with
+// snapshots.encryption-passphrase. The passphrase is only kept in memory, so
+// it must be set again after snapd restarts for new snapshots to be taken. If
+// it's not set and create is not set, it returns nil. The state must be locked
+// by the caller.
+func passphraseEncryptionKey(st *state.State, create bool) (*backend.EncryptionKey, error) {
+	passphrase := backendEncryptionPassphrase()
+	if passphrase == "" {
+		if !create {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot use snapshot encryption passphrase: snapshots.encryption-passphrase must be set again since snapd was restarted")
+	}
+	salt, err := passphraseSalt(st, create)
+	if err != nil || salt == nil {
+		return nil, err
+	}
+	return backendNewPassphraseEncryptionKey(passphrase, salt)
+}
+
+func keyFileEncryptionKey(keyFile string) (*backend.EncryptionKey, error) {
+	material, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot encryption key file: %v", err)
+	}
+	key, err := backend.NewEncryptionKey(material)
+	if err != nil {
+		return nil, fmt.Errorf("cannot use snapshot encryption key file %q: %v", keyFile, err)
+	}
+	return key, nil
+}
+
+// sealedEncryptionKey returns the key kept on ubuntu-save, generating it
+// first if needed and create is set. If the key doesn't exist and create is
+// not set, it returns nil.
+func sealedEncryptionKey(create bool) (*backend.EncryptionKey, error) {
+	if !device.HasEncryptedMarkerUnder(dirs.SnapFDEDir) {
+		return nil, fmt.Errorf("cannot use sealed snapshot encryption key: device data is not encrypted")
+	}
+	material, err := ioutil.ReadFile(sealedKeyFile())
+	if os.IsNotExist(err) && create {
+		material = make([]byte, backend.EncryptionKeySize)
+		if _, err := randRead(material); err != nil {
+			return nil, fmt.Errorf("cannot generate snapshot encryption key: %v", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(sealedKeyFile()), 0755); err != nil {
+			return nil, err
+		}
+		err = osutil.AtomicWriteFile(sealedKeyFile(), material, 0600, 0)
+	}
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot use sealed snapshot encryption key: %v", err)
+	}
+	return backend.NewEncryptionKey(material)
+}
+
+// coreConfig returns the given core option formatted as a string, or an
+// empty string if it's unset; options set with "snap set" are strings,
+// numbers or booleans depending on how they look.
+func coreConfig(tr *config.Transaction, key string) (string, error) {
+	var value interface{}
+	if err := tr.Get("core", key, &value); err != nil && !config.IsNoOption(err) {
+		return "", err
+	}
+	if value == nil {
+		return "", nil
+	}
+	return fmt.Sprintf("%v", value), nil
+}
+
+// configuredEncryptionKey returns the key from the source configured with
+// snapshots.encryption, or nil if encryption isn't configured. The state must
+// be locked by the caller.
+func configuredEncryptionKey(st *state.State, create bool) (*backend.EncryptionKey, error) {
+	tr := config.NewTransaction(st)
+	source, err := coreConfig(tr, "snapshots.encryption")
+	if err != nil {
+		return nil, err
+	}
+	switch source {
+	case "", "none":
+		return nil, nil
+	case "key-file":
+		keyFile, err := coreConfig(tr, "snapshots.encryption-key-file")
+		if err != nil {
+			return nil, err
+		}
+		return keyFileEncryptionKey(keyFile)
+	case "passphrase":
+		return passphraseEncryptionKey(st, create)
+	case "sealed":
+		return sealedEncryptionKey(create)
+	default:
+		return nil, fmt.Errorf("internal error: unknown snapshot encryption %q", source)
+	}
+}
+
+// encryptionKey returns the key new snapshots should be encrypted with. All
+// snapshots are encrypted if snapshots.encryption is set; otherwise, if
+// encryption was requested, the sealed key is used. The state must be locked
+// by the caller.
+func encryptionKey(st *state.State, encrypt bool) (*backend.EncryptionKey, error) {
+	key, err := configuredEncryptionKey(st, true)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil && encrypt {
+		if !device.HasEncryptedMarkerUnder(dirs.SnapFDEDir) {
+			return nil, fmt.Errorf("cannot encrypt snapshot: snapshots.encryption is not set and device data is not encrypted")
+		}
+		key, err = sealedEncryptionKey(true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// loadEncryptionKeys makes the keys of the encrypted snapshots available to
+// the backend, so it can open them. The state must be locked by the caller.
+func loadEncryptionKeys(st *state.State) {
+	key, err := configuredEncryptionKey(st, false)
+	if err != nil {
+		logger.Noticef("Cannot load snapshot encryption key: %v.", err)
+	}
+	if key != nil {
+		backendAddEncryptionKey(key)
+	}
+	// snapshots might have been encrypted with the sealed key when
+	// encryption was not configured
+	if device.HasEncryptedMarkerUnder(dirs.SnapFDEDir) {
+		key, err := sealedEncryptionKey(false)
+		if err != nil {
+			logger.Noticef("Cannot load sealed snapshot encryption key: %v.", err)
+		}
+		if key != nil {
+			backendAddEncryptionKey(key)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget/device"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func setCoreConfig(c *check.C, st *state.State, values map[string]interface{}) {
+	tr := config.NewTransaction(st)
+	for k, v := range values {
+		c.Assert(tr.Set("core", k, v), check.IsNil)
+	}
+	tr.Commit()
+}
+
+func writeEncryptionMarkers(c *check.C) {
+	c.Assert(os.MkdirAll(dirs.SnapFDEDir, 0755), check.IsNil)
+	c.Assert(os.MkdirAll(dirs.SnapFDEDirUnderSave(dirs.SnapSaveDir), 0755), check.IsNil)
+	c.Assert(device.WriteEncryptionMarkers(dirs.SnapFDEDir, dirs.SnapFDEDirUnderSave(dirs.SnapSaveDir), []byte("marker")), check.IsNil)
+}
+
+func (snapshotSuite) TestEncryptionKeyNotConfigured(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	key, err := snapshotstate.EncryptionKey(st, false)
+	c.Assert(err, check.IsNil)
+	c.Check(key, check.IsNil)
+
+	key, err = snapshotstate.EncryptionKey(st, true)
+	c.Assert(err, check.ErrorMatches, "cannot encrypt snapshot: snapshots.encryption is not set and device data is not encrypted")
+	c.Check(key, check.IsNil)
+
+	// and Save fails early
+	_, _, _, err = snapshotstate.Save(st, nil, nil, nil, &snapshotstate.SaveFlags{Encrypt: true})
+	c.Assert(err, check.ErrorMatches, "cannot encrypt snapshot: .*")
+}
+
+func (snapshotSuite) TestEncryptionKeyKeyFile(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	keyFile := filepath.Join(c.MkDir(), "key")
+	material := bytes.Repeat([]byte{42}, backend.EncryptionKeySize)
+	c.Assert(ioutil.WriteFile(keyFile, material, 0600), check.IsNil)
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.encryption":          "key-file",
+		"snapshots.encryption-key-file": keyFile,
+	})
+
+	expected, err := backend.NewEncryptionKey(material)
+	c.Assert(err, check.IsNil)
+
+	key, err := snapshotstate.EncryptionKey(st, false)
+	c.Assert(err, check.IsNil)
+	c.Check(key, check.DeepEquals, expected)
+
+	var added []*backend.EncryptionKey
+	defer snapshotstate.MockBackendAddEncryptionKey(func(key *backend.EncryptionKey) {
+		added = append(added, key)
+	})()
+	snapshotstate.LoadEncryptionKeys(st)
+	c.Check(added, check.DeepEquals, []*backend.EncryptionKey{expected})
+
+	// a key of the wrong size is refused
+	c.Assert(ioutil.WriteFile(keyFile, []byte("short"), 0600), check.IsNil)
+	_, err = snapshotstate.EncryptionKey(st, false)
+	c.Assert(err, check.ErrorMatches, `cannot use snapshot encryption key file ".*/key": invalid snapshot encryption key size 5, expected 32`)
+}
+
+func (snapshotSuite) TestEncryptionKeyPassphrase(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.encryption": "passphrase",
+	})
+
+	// the passphrase is only kept in memory
+	defer snapshotstate.MockBackendEncryptionPassphrase("")()
+	_, err := snapshotstate.EncryptionKey(st, false)
+	c.Assert(err, check.ErrorMatches, "cannot use snapshot encryption passphrase: snapshots.encryption-passphrase must be set again since snapd was restarted")
+	// but nothing is loaded
+	key, err := snapshotstate.ConfiguredEncryptionKey(st, false)
+	c.Assert(err, check.IsNil)
+	c.Check(key, check.IsNil)
+
+	defer snapshotstate.MockBackendEncryptionPassphrase("correct horse battery staple")()
+	defer snapshotstate.MockRandRead(func(b []byte) (int, error) {
+		for i := range b {
+			b[i] = 1
+		}
+		return len(b), nil
+	})()
+	key1, err := snapshotstate.EncryptionKey(st, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(key1, check.NotNil)
+
+	// the salt of the device was generated and kept
+	var salt []byte
+	c.Assert(st.Get("snapshots-passphrase-salt", &salt), check.IsNil)
+	c.Check(salt, check.DeepEquals, bytes.Repeat([]byte{1}, 32))
+	expected, err := backend.NewPassphraseEncryptionKey("correct horse battery staple", salt)
+	c.Assert(err, check.IsNil)
+	c.Check(key1.ID(), check.Equals, expected.ID())
+
+	// the same passphrase always results in the same key on the device
+	key2, err := snapshotstate.EncryptionKey(st, true)
+	c.Assert(err, check.IsNil)
+	c.Check(key2.ID(), check.Equals, key1.ID())
+
+	// but not with another salt
+	other, err := backend.NewPassphraseEncryptionKey("correct horse battery staple", bytes.Repeat([]byte{2}, 32))
+	c.Assert(err, check.IsNil)
+	c.Check(other.ID(), check.Not(check.Equals), key1.ID())
+
+	defer snapshotstate.MockBackendEncryptionPassphrase("another passphrase")()
+	key3, err := snapshotstate.EncryptionKey(st, false)
+	c.Assert(err, check.IsNil)
+	c.Check(key3.ID(), check.Not(check.Equals), key1.ID())
+}
+
+func (snapshotSuite) TestEncryptionKeySealed(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.encryption": "sealed",
+	})
+
+	_, err := snapshotstate.EncryptionKey(st, false)
+	c.Assert(err, check.ErrorMatches, "cannot use sealed snapshot encryption key: device data is not encrypted")
+
+	writeEncryptionMarkers(c)
+
+	var added []*backend.EncryptionKey
+	defer snapshotstate.MockBackendAddEncryptionKey(func(key *backend.EncryptionKey) {
+		added = append(added, key)
+	})()
+	// nothing to load before the key is created
+	snapshotstate.LoadEncryptionKeys(st)
+	c.Check(added, check.HasLen, 0)
+
+	key, err := snapshotstate.EncryptionKey(st, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(key, check.NotNil)
+
+	fi, err := os.Stat(filepath.Join(dirs.SnapDeviceSaveDir, "snapshots.key"))
+	c.Assert(err, check.IsNil)
+	c.Check(fi.Mode().Perm(), check.Equals, os.FileMode(0600))
+
+	snapshotstate.LoadEncryptionKeys(st)
+	c.Check(added, check.DeepEquals, []*backend.EncryptionKey{key, key})
+
+	// the sealed key is also used when encryption is requested but not
+	// configured
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.encryption": "none",
+	})
+	again, err := snapshotstate.EncryptionKey(st, true)
+	c.Assert(err, check.IsNil)
+	c.Check(again.ID(), check.Equals, key.ID())
+}
+
+func (snapshotSuite) TestValidateEncryptionPassphrase(c *check.C) {
+	err := snapshotstate.ValidateEncryptionPassphrase("short")
+	c.Check(err, check.ErrorMatches, "snapshots.encryption-passphrase must be at least 8 characters long")
+	c.Check(snapshotstate.ValidateEncryptionPassphrase("correct horse"), check.IsNil)
+}
+
+func (snapshotSuite) TestApplyPendingEncryptionPassphrase(c *check.C) {
+	var added []string
+	defer snapshotstate.MockBackendAddEncryptionPassphrase(func(passphrase string) {
+		added = append(added, passphrase)
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	t := st.NewTask("run-hook", "")
+	other := st.NewTask("run-hook", "")
+
+	// nothing to apply
+	c.Assert(snapshotstate.ApplyPendingEncryptionPassphrase(t), check.IsNil)
+	c.Check(added, check.HasLen, 0)
+
+	snapshotstate.SetPendingEncryptionPassphrase(t, "correct horse")
+	c.Check(snapshotstate.PendingEncryptionPassphrase(t), check.Equals, "correct horse")
+	c.Check(snapshotstate.PendingEncryptionPassphrase(other), check.Equals, "")
+	// the passphrase is only kept in memory
+	data, err := json.Marshal(st)
+	c.Assert(err, check.IsNil)
+	c.Check(strings.Contains(string(data), "correct horse"), check.Equals, false)
+
+	c.Assert(snapshotstate.ApplyPendingEncryptionPassphrase(other), check.IsNil)
+	c.Check(added, check.HasLen, 0)
+
+	c.Assert(snapshotstate.ApplyPendingEncryptionPassphrase(t), check.IsNil)
+	c.Check(added, check.DeepEquals, []string{"correct horse"})
+	c.Check(snapshotstate.PendingEncryptionPassphrase(t), check.Equals, "")
+
+	// it is only applied once
+	c.Assert(snapshotstate.ApplyPendingEncryptionPassphrase(t), check.IsNil)
+	c.Check(added, check.HasLen, 1)
+
+	snapshotstate.SetPendingEncryptionPassphrase(t, "short")
+	err = snapshotstate.ApplyPendingEncryptionPassphrase(t)
+	c.Check(err, check.ErrorMatches, "snapshots.encryption-passphrase must be at least 8 characters long")
+	c.Check(added, check.HasLen, 1)
+}
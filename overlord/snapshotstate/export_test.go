@@ -45,6 +45,7 @@ var (
 	CleanupRestore             = cleanupRestore
 	DoCheck                    = doCheck
 	DoForget                   = doForget
+	DoUpload                   = doUpload
 	SaveExpiration             = saveExpiration
 	ExpiredSnapshotSets        = expiredSnapshotSets
 	RemoveSnapshotState        = removeSnapshotState
@@ -215,3 +216,76 @@ func MockGetSnapDirOptions(f func(*state.State, string) (*dirs.SnapDirOptions, e
 		getSnapDirOpts = old
 	}
 }
+
+var (
+	EncryptionKey           = encryptionKey
+	ConfiguredEncryptionKey = configuredEncryptionKey
+	LoadEncryptionKeys      = loadEncryptionKeys
+)
+
+func MockRandRead(f func([]byte) (int, error)) (restore func()) {
+	old := randRead
+	randRead = f
+	return func() {
+		randRead = old
+	}
+}
+
+func MockBackendAddEncryptionKey(f func(*backend.EncryptionKey)) (restore func()) {
+	old := backendAddEncryptionKey
+	backendAddEncryptionKey = f
+	return func() {
+		backendAddEncryptionKey = old
+	}
+}
+
+func MockBackendAddEncryptionPassphrase(f func(string)) (restore func()) {
+	old := backendAddEncryptionPassphrase
+	backendAddEncryptionPassphrase = f
+	return func() {
+		backendAddEncryptionPassphrase = old
+	}
+}
+
+func MockBackendEncryptionPassphrase(passphrase string) (restore func()) {
+	old := backendEncryptionPassphrase
+	backendEncryptionPassphrase = func() string { return passphrase }
+	return func() {
+		backendEncryptionPassphrase = old
+	}
+}
+
+func MockBackendPruneChunks(f func(context.Context) (int, error)) (restore func()) {
+	old := backendPruneChunks
+	backendPruneChunks = f
+	return func() {
+		backendPruneChunks = old
+	}
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
+
+func ScheduledSnapshotsToForget(keepLast, keepDaily, keepWeekly int, sets map[uint64]time.Time) []uint64 {
+	r := &scheduledSnapshotsRetention{
+		keepLast:   keepLast,
+		keepDaily:  keepDaily,
+		keepWeekly: keepWeekly,
+	}
+	return r.setsToForget(sets)
+}
+
+type ExportStream = exportStream
+
+func MockExportForUpload(f func(ctx context.Context, st *state.State, setID uint64) (ExportStream, error)) (restore func()) {
+	old := exportForUpload
+	exportForUpload = f
+	return func() {
+		exportForUpload = old
+	}
+}
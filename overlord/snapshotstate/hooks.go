@@ -0,0 +1,60 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// SetupPreSnapshotHook returns a task running the pre-snapshot hook of the
+// snap, which should get its data in a consistent state, for instance by
+// flushing and pausing writes; the post-snapshot hook is run to undo it,
+// when saving the snapshot set fails.
+var SetupPreSnapshotHook = func(st *state.State, snapName string) *state.Task {
+	panic("internal error: snapshotstate.SetupPreSnapshotHook is unset")
+}
+
+// SetupPostSnapshotHook returns a task running the post-snapshot hook of the
+// snap, run once the data of all the snaps of the set was saved; its failure
+// is ignored.
+var SetupPostSnapshotHook = func(st *state.State, snapName string) *state.Task {
+	panic("internal error: snapshotstate.SetupPostSnapshotHook is unset")
+}
+
+// SetupPostRestoreHook returns a task running the post-restore hook of the
+// snap, run once its data was restored; its failure is ignored.
+var SetupPostRestoreHook = func(st *state.State, snapName string) *state.Task {
+	panic("internal error: snapshotstate.SetupPostRestoreHook is unset")
+}
+
+// snapHasHook returns whether the current revision of the snap has the
+// given hook. Errors are left for the snapshot tasks to report.
+func snapHasHook(st *state.State, snapName, hook string) bool {
+	info, err := snapstateCurrentInfo(st, snapName)
+	if err != nil {
+		return false
+	}
+	return hasHook(info, hook)
+}
+
+func hasHook(info *snap.Info, hook string) bool {
+	return info.Hooks[hook] != nil
+}
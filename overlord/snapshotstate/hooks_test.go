@@ -0,0 +1,221 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+func mockSnapshotHooks() (restore func()) {
+	oldPreSnapshot := snapshotstate.SetupPreSnapshotHook
+	oldPostSnapshot := snapshotstate.SetupPostSnapshotHook
+	oldPostRestore := snapshotstate.SetupPostRestoreHook
+	hookTask := func(hook string) func(*state.State, string) *state.Task {
+		return func(st *state.State, snapName string) *state.Task {
+			return st.NewTask("run-hook", fmt.Sprintf("%s hook of %q", hook, snapName))
+		}
+	}
+	snapshotstate.SetupPreSnapshotHook = func(st *state.State, snapName string) *state.Task {
+		t := hookTask("pre-snapshot")(st, snapName)
+		// like the real one, undone by running the post-snapshot hook
+		t.Set("undo-hook", fmt.Sprintf("post-snapshot hook of %q", snapName))
+		return t
+	}
+	snapshotstate.SetupPostSnapshotHook = hookTask("post-snapshot")
+	snapshotstate.SetupPostRestoreHook = hookTask("post-restore")
+	return func() {
+		snapshotstate.SetupPreSnapshotHook = oldPreSnapshot
+		snapshotstate.SetupPostSnapshotHook = oldPostSnapshot
+		snapshotstate.SetupPostRestoreHook = oldPostRestore
+	}
+}
+
+func (snapshotSuite) TestSaveRunsSnapshotHooks(c *check.C) {
+	defer mockSnapshotHooks()()
+	defer snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"a-snap": {Active: true},
+			"b-snap": {Active: true},
+		}, nil
+	})()
+	defer snapshotstate.MockSnapstateCurrentInfo(func(_ *state.State, name string) (*snap.Info, error) {
+		info := &snap.Info{SideInfo: snap.SideInfo{RealName: name}, Hooks: map[string]*snap.HookInfo{}}
+		if name == "a-snap" {
+			info.Hooks["pre-snapshot"] = &snap.HookInfo{Name: "pre-snapshot", Snap: info}
+			info.Hooks["post-snapshot"] = &snap.HookInfo{Name: "post-snapshot", Snap: info}
+		}
+		return info, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, _, ts, err := snapshotstate.Save(st, nil, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	tasks := ts.Tasks()
+	c.Assert(tasks, check.HasLen, 4)
+	c.Check(tasks[0].Summary(), check.Equals, `pre-snapshot hook of "a-snap"`)
+	c.Check(tasks[1].Summary(), check.Equals, `Save data of snap "a-snap" in snapshot set #1`)
+	c.Check(tasks[1].WaitTasks(), check.DeepEquals, []*state.Task{tasks[0]})
+	c.Check(tasks[2].Summary(), check.Equals, `post-snapshot hook of "a-snap"`)
+	// snaps without hooks are saved right away
+	c.Check(tasks[3].Summary(), check.Equals, `Save data of snap "b-snap" in snapshot set #1`)
+	c.Check(tasks[3].WaitTasks(), check.HasLen, 0)
+	// the post-snapshot hook runs once the whole set is saved
+	c.Check(tasks[2].WaitTasks(), check.DeepEquals, []*state.Task{tasks[1], tasks[3]})
+}
+
+func (snapshotSuite) TestSaveRunsPostSnapshotHooksOnceWhenSavingFails(c *check.C) {
+	defer mockSnapshotHooks()()
+	defer snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"a-snap": {Active: true},
+			"b-snap": {Active: true},
+		}, nil
+	})()
+	defer snapshotstate.MockSnapstateCurrentInfo(func(_ *state.State, name string) (*snap.Info, error) {
+		info := &snap.Info{SideInfo: snap.SideInfo{RealName: name}, Hooks: map[string]*snap.HookInfo{}}
+		info.Hooks["pre-snapshot"] = &snap.HookInfo{Name: "pre-snapshot", Snap: info}
+		info.Hooks["post-snapshot"] = &snap.HookInfo{Name: "post-snapshot", Snap: info}
+		return info, nil
+	})()
+
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	defer runner.Stop()
+
+	var hooks []string
+	runner.AddHandler("run-hook", func(t *state.Task, _ *tomb.Tomb) error {
+		st.Lock()
+		defer st.Unlock()
+		hooks = append(hooks, t.Summary())
+		return nil
+	}, func(t *state.Task, _ *tomb.Tomb) error {
+		st.Lock()
+		defer st.Unlock()
+		var undo string
+		if err := t.Get("undo-hook", &undo); err == nil {
+			hooks = append(hooks, undo)
+		}
+		return nil
+	})
+	bAttempts := 0
+	runner.AddHandler("save-snapshot", func(t *state.Task, _ *tomb.Tomb) error {
+		st.Lock()
+		defer st.Unlock()
+		if strings.Contains(t.Summary(), `"b-snap"`) {
+			// fail a few ensure rounds after a-snap was saved,
+			// when its post-snapshot hook could have run already
+			bAttempts++
+			if bAttempts < 4 {
+				return &state.Retry{}
+			}
+			return fmt.Errorf("cannot save b-snap")
+		}
+		return nil
+	}, func(*state.Task, *tomb.Tomb) error {
+		return nil
+	})
+
+	st.Lock()
+	defer st.Unlock()
+	_, _, ts, err := snapshotstate.Save(st, nil, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	chg := st.NewChange("save-snapshot", "...")
+	chg.AddAll(ts)
+
+	for i := 0; i < 20 && !chg.IsReady(); i++ {
+		st.Unlock()
+		runner.Ensure()
+		runner.Wait()
+		st.Lock()
+	}
+	c.Assert(chg.IsReady(), check.Equals, true)
+	c.Check(chg.Err(), check.ErrorMatches, `(?s).*cannot save b-snap.*`)
+
+	// each snap is resumed exactly once, by undoing its pre-snapshot hook
+	sort.Strings(hooks)
+	c.Check(hooks, check.DeepEquals, []string{
+		`post-snapshot hook of "a-snap"`,
+		`post-snapshot hook of "b-snap"`,
+		`pre-snapshot hook of "a-snap"`,
+		`pre-snapshot hook of "b-snap"`,
+	})
+}
+
+func (snapshotSuite) TestRestoreRunsPostRestoreHook(c *check.C) {
+	defer mockSnapshotHooks()()
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "yadda.zip"))
+	c.Assert(err, check.IsNil)
+	defer shotfile.Close()
+
+	sideInfo := &snap.SideInfo{RealName: "a-snap", Revision: snap.R(1)}
+	defer snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"a-snap": {
+				Active:   true,
+				Sequence: []*snap.SideInfo{sideInfo},
+				Current:  sideInfo.Revision,
+			},
+		}, nil
+	})()
+	snaptest.MockSnap(c, "{name: a-snap, version: v1, hooks: {post-restore: {}}}", sideInfo)
+
+	defer snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		for _, name := range []string{"a-snap", "b-snap"} {
+			c.Assert(f(&backend.Reader{
+				Snapshot: client.Snapshot{SetID: 42, Snap: name},
+				File:     shotfile,
+			}), check.IsNil)
+		}
+		return nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	found, ts, err := snapshotstate.Restore(st, 42, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(found, check.DeepEquals, []string{"a-snap", "b-snap"})
+	tasks := ts.Tasks()
+	c.Assert(tasks, check.HasLen, 4)
+	c.Check(tasks[2].Kind(), check.Equals, "cleanup-after-restore")
+	// the hook runs once all the data was restored
+	c.Check(tasks[3].Summary(), check.Equals, `post-restore hook of "a-snap"`)
+	c.Check(tasks[3].WaitTasks(), check.DeepEquals, []*state.Task{tasks[2]})
+}
@@ -0,0 +1,378 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+var (
+	// the longest time between scheduled snapshots, whatever the schedule
+	maxScheduledSnapshotInterval = 32 * 24 * time.Hour
+
+	// how many scheduled snapshot sets are kept if no retention rule
+	// is set
+	defaultScheduledSnapshotsKeepLast = 7
+
+	timeNow = time.Now
+)
+
+// scheduledSnapshotsRetention holds the rules deciding which scheduled
+// snapshot sets are kept; a set is kept if any of the rules keeps it.
+type scheduledSnapshotsRetention struct {
+	// keep the newest keepLast sets
+	keepLast int
+	// keep the newest set of each of the newest keepDaily days with sets
+	keepDaily int
+	// keep the newest set of each of the newest keepWeekly weeks with sets
+	keepWeekly int
+}
+
+// setsToForget returns, sorted, the ids of the sets that are not kept by any
+// of the retention rules, given the time each set was taken at.
+func (r *scheduledSnapshotsRetention) setsToForget(sets map[uint64]time.Time) []uint64 {
+	ids := make([]uint64, 0, len(sets))
+	for id := range sets {
+		ids = append(ids, id)
+	}
+	// newest first
+	sort.Slice(ids, func(i, j int) bool {
+		if sets[ids[i]].Equal(sets[ids[j]]) {
+			return ids[i] > ids[j]
+		}
+		return sets[ids[i]].After(sets[ids[j]])
+	})
+
+	kept := make(map[uint64]bool, len(ids))
+	keepNewestPer := func(n int, period func(time.Time) string) {
+		seen := make(map[string]bool, n)
+		for _, id := range ids {
+			p := period(sets[id])
+			if seen[p] {
+				continue
+			}
+			if len(seen) == n {
+				break
+			}
+			seen[p] = true
+			kept[id] = true
+		}
+	}
+	for i, id := range ids {
+		if i < r.keepLast {
+			kept[id] = true
+		}
+	}
+	keepNewestPer(r.keepDaily, func(t time.Time) string {
+		return t.Local().Format("2006-01-02")
+	})
+	keepNewestPer(r.keepWeekly, func(t time.Time) string {
+		year, week := t.Local().ISOWeek()
+		return fmt.Sprintf("%d-%d", year, week)
+	})
+
+	var forget []uint64
+	for _, id := range ids {
+		if !kept[id] {
+			forget = append(forget, id)
+		}
+	}
+	sort.Slice(forget, func(i, j int) bool { return forget[i] < forget[j] })
+	return forget
+}
+
+func scheduledSnapshotsRetentionFromConfig(tr *config.Transaction) (*scheduledSnapshotsRetention, error) {
+	var r scheduledSnapshotsRetention
+	for _, rule := range []struct {
+		key   string
+		value *int
+	}{
+		{"snapshots.scheduled.keep-last", &r.keepLast},
+		{"snapshots.scheduled.keep-daily", &r.keepDaily},
+		{"snapshots.scheduled.keep-weekly", &r.keepWeekly},
+	} {
+		str, err := coreConfig(tr, rule.key)
+		if err != nil {
+			return nil, err
+		}
+		if str == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(str, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %v", rule.key, err)
+		}
+		*rule.value = int(n)
+	}
+	if r.keepLast == 0 && r.keepDaily == 0 && r.keepWeekly == 0 {
+		r.keepLast = defaultScheduledSnapshotsKeepLast
+	}
+	return &r, nil
+}
+
+func snapNamesFromConfig(tr *config.Transaction, key string) ([]string, error) {
+	str, err := coreConfig(tr, key)
+	if err != nil {
+		return nil, err
+	}
+	return strutil.CommaSeparatedList(str), nil
+}
+
+// scheduledSnapNames returns the names of the snaps to save in scheduled
+// snapshot sets, as per snapshots.scheduled.include and
+// snapshots.scheduled.exclude.
+func scheduledSnapNames(st *state.State, tr *config.Transaction) ([]string, error) {
+	active, err := allActiveSnapNames(st)
+	if err != nil {
+		return nil, err
+	}
+	include, err := snapNamesFromConfig(tr, "snapshots.scheduled.include")
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := snapNamesFromConfig(tr, "snapshots.scheduled.exclude")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(active))
+	for _, name := range active {
+		if len(include) > 0 && !strutil.ListContains(include, name) {
+			continue
+		}
+		if strutil.ListContains(exclude, name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// saveScheduled records in the state that the given snapshot set was taken
+// following snapshots.schedule.
+// The state needs to be locked by the caller.
+func saveScheduled(st *state.State, setID uint64, scheduledTime time.Time) error {
+	var snapshots map[uint64]*json.RawMessage
+	err := st.Get("snapshots", &snapshots)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if snapshots == nil {
+		snapshots = make(map[uint64]*json.RawMessage)
+	}
+	data, err := json.Marshal(&snapshotState{
+		ScheduledTime: &scheduledTime,
+	})
+	if err != nil {
+		return err
+	}
+	raw := json.RawMessage(data)
+	snapshots[setID] = &raw
+	st.Set("snapshots", snapshots)
+	return nil
+}
+
+// scheduledSnapshotSets returns the snapshot sets taken following
+// snapshots.schedule, with the time they were taken at.
+// The state needs to be locked by the caller.
+func scheduledSnapshotSets(st *state.State) (map[uint64]time.Time, error) {
+	var snapshots map[uint64]*snapshotState
+	err := st.Get("snapshots", &snapshots)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+
+	sets := make(map[uint64]time.Time)
+	for setID, snapshotSet := range snapshots {
+		if snapshotSet.ScheduledTime != nil {
+			sets[setID] = *snapshotSet.ScheduledTime
+		}
+	}
+	return sets, nil
+}
+
+// scheduledSnapshotInFlight returns whether a scheduled snapshot set is
+// still being saved.
+func scheduledSnapshotInFlight(st *state.State) bool {
+	for _, chg := range st.Changes() {
+		if chg.Kind() == "save-snapshot" && !chg.Status().Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+func lastScheduledSnapshot(st *state.State) (time.Time, error) {
+	var last time.Time
+	err := st.Get("last-scheduled-snapshot", &last)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return time.Time{}, err
+	}
+	return last, nil
+}
+
+// ensureScheduledSnapshots saves snapshot sets as per snapshots.schedule and
+// forgets the scheduled sets no longer kept by the retention rules.
+func (mgr *SnapshotManager) ensureScheduledSnapshots() error {
+	st := mgr.state
+	st.Lock()
+	defer st.Unlock()
+
+	var seeded bool
+	err := st.Get("seeded", &seeded)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if !seeded {
+		return nil
+	}
+
+	tr := config.NewTransaction(st)
+	scheduleStr, err := coreConfig(tr, "snapshots.schedule")
+	if err != nil {
+		return err
+	}
+	if scheduleStr != mgr.lastSnapshotSchedule {
+		// the schedule has changed
+		mgr.nextScheduledSnapshot = time.Time{}
+		mgr.lastSnapshotSchedule = scheduleStr
+	}
+	if scheduleStr == "" {
+		return nil
+	}
+	schedule, err := timeutil.ParseSchedule(scheduleStr)
+	if err != nil {
+		return fmt.Errorf("cannot parse snapshots.schedule: %v", err)
+	}
+
+	// one set at a time
+	if scheduledSnapshotInFlight(st) {
+		return nil
+	}
+
+	if err := mgr.forgetScheduledSnapshots(tr); err != nil {
+		logger.Noticef("Cannot forget old scheduled snapshots: %v", err)
+	}
+
+	now := timeNow()
+	if mgr.nextScheduledSnapshot.IsZero() {
+		last, err := lastScheduledSnapshot(st)
+		if err != nil {
+			return err
+		}
+		if last.IsZero() {
+			// the first set is saved in the next window of the
+			// schedule
+			last = now
+			st.Set("last-scheduled-snapshot", last)
+		}
+		mgr.nextScheduledSnapshot = now.Add(timeutil.Next(schedule, last, maxScheduledSnapshotInterval))
+		logger.Debugf("Next scheduled snapshot set at %s.", mgr.nextScheduledSnapshot.Format(time.RFC3339))
+	}
+	if mgr.nextScheduledSnapshot.After(now) {
+		return nil
+	}
+
+	names, err := scheduledSnapNames(st, tr)
+	if err != nil {
+		return err
+	}
+	// try again in the next window whatever happens
+	st.Set("last-scheduled-snapshot", now)
+	mgr.nextScheduledSnapshot = time.Time{}
+	if len(names) == 0 {
+		logger.Noticef("No snaps to save in scheduled snapshot set.")
+		return nil
+	}
+
+	setID, saved, ts, err := Save(st, names, nil, nil, nil)
+	if err != nil {
+		logger.Noticef("Cannot save scheduled snapshot set: %v", err)
+		return nil
+	}
+	if err := saveScheduled(st, setID, now); err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("Save data of snaps %s in scheduled snapshot set #%d", strutil.Quoted(saved), setID)
+	chg := st.NewChange("save-snapshot", msg)
+	chg.AddAll(ts)
+	chg.Set("snap-names", saved)
+	chg.Set("api-data", map[string]interface{}{"snap-names": saved, "set-id": setID})
+	st.EnsureBefore(0)
+
+	return nil
+}
+
+// forgetScheduledSnapshots forgets the scheduled snapshot sets that are no
+// longer kept by the retention rules.
+// The state needs to be locked by the caller.
+func (mgr *SnapshotManager) forgetScheduledSnapshots(tr *config.Transaction) error {
+	sets, err := scheduledSnapshotSets(mgr.state)
+	if err != nil {
+		return err
+	}
+	retention, err := scheduledSnapshotsRetentionFromConfig(tr)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, setID := range retention.setsToForget(sets) {
+		if err := checkSnapshotConflict(mgr.state, setID, "forget-snapshot"); err != nil {
+			// being forgotten already
+			continue
+		}
+		snapNames, ts, err := Forget(mgr.state, setID, nil)
+		if err != nil {
+			if errors.Is(err, client.ErrSnapshotSetNotFound) {
+				// forgotten already
+				if err := removeSnapshotState(mgr.state, setID); err != nil {
+					return err
+				}
+				continue
+			}
+			// might be a conflict, retried on the next Ensure
+			errs = append(errs, fmt.Sprintf("set #%d: %v", setID, err))
+			continue
+		}
+		msg := fmt.Sprintf("Forget scheduled snapshot set #%d", setID)
+		chg := mgr.state.NewChange("forget-snapshot", msg)
+		chg.AddAll(ts)
+		chg.Set("snap-names", snapNames)
+		chg.Set("api-data", map[string]interface{}{"snap-names": snapNames, "set-id": setID})
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+func (snapshotSuite) TestScheduledSnapshotsToForget(c *check.C) {
+	day := func(d, h int) time.Time {
+		// 2023-01-02 is a Monday
+		return time.Date(2023, 1, 2+d, h, 0, 0, 0, time.Local)
+	}
+	sets := map[uint64]time.Time{
+		1: day(0, 1),
+		2: day(0, 2),
+		3: day(1, 1),
+		4: day(1, 2),
+		5: day(7, 1),
+		6: day(8, 1),
+		7: day(9, 1),
+		8: day(9, 2),
+	}
+
+	for _, tc := range []struct {
+		keepLast, keepDaily, keepWeekly int
+		forget                          []uint64
+	}{
+		{0, 0, 0, []uint64{1, 2, 3, 4, 5, 6, 7, 8}},
+		{3, 0, 0, []uint64{1, 2, 3, 4, 5}},
+		{10, 0, 0, nil},
+		// the newest set of each of the last 3 days with sets
+		{0, 3, 0, []uint64{1, 2, 3, 4, 7}},
+		// the newest set of each of the last 2 weeks with sets
+		{0, 0, 2, []uint64{1, 2, 3, 5, 6, 7}},
+		{1, 2, 2, []uint64{1, 2, 3, 5, 7}},
+	} {
+		forget := snapshotstate.ScheduledSnapshotsToForget(tc.keepLast, tc.keepDaily, tc.keepWeekly, sets)
+		c.Check(forget, check.DeepEquals, tc.forget, check.Commentf("%+v", tc))
+	}
+}
+
+func mockTimeNow(f func() time.Time) (restore func()) {
+	restoreTimeutil := timeutil.MockTimeNow(f)
+	restoreSnapshotstate := snapshotstate.MockTimeNow(f)
+	return func() {
+		restoreSnapshotstate()
+		restoreTimeutil()
+	}
+}
+
+func mockScheduledSnapshotSnaps(st *state.State) {
+	for _, name := range []string{"a-snap", "b-snap", "c-snap"} {
+		snapstate.Set(st, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: []*snap.SideInfo{
+				{RealName: name, Revision: snap.R(1)},
+			},
+			Current: snap.R(1),
+		})
+	}
+}
+
+func (snapshotSuite) TestEnsureScheduledSnapshots(c *check.C) {
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.Local)
+	defer mockTimeNow(func() time.Time { return now })()
+	var backendIterCalls int
+	defer snapshotstate.MockBackendIter(func(context.Context, func(*backend.Reader) error) error {
+		backendIterCalls++
+		return nil
+	})()
+
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	st.Lock()
+	defer st.Unlock()
+	st.Set("seeded", true)
+	mockScheduledSnapshotSnaps(st)
+
+	// nothing happens without a schedule
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	var last time.Time
+	c.Check(st.Get("last-scheduled-snapshot", &last), check.ErrorMatches, "no state entry for key.*")
+
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.schedule":          "11:00-11:30",
+		"snapshots.scheduled.exclude": "b-snap",
+	})
+
+	// the first set is saved in the next window
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	c.Assert(st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(last.Equal(now), check.Equals, true)
+
+	now = now.Add(2 * time.Hour)
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	chgs := st.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), check.Equals, "save-snapshot")
+	c.Check(chg.Summary(), check.Equals, `Save data of snaps "a-snap", "c-snap" in scheduled snapshot set #1`)
+	c.Check(chg.Tasks(), check.HasLen, 2)
+	c.Assert(st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(last.Equal(now), check.Equals, true)
+
+	var snapshots map[uint64]map[string]interface{}
+	c.Assert(st.Get("snapshots", &snapshots), check.IsNil)
+	c.Check(snapshots[1]["scheduled-time"], check.Equals, now.Format(time.RFC3339Nano))
+
+	// nothing else happens while the set is being saved, even in the
+	// next window
+	now = now.Add(23*time.Hour + 15*time.Minute)
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 1)
+
+	for _, t := range chg.Tasks() {
+		t.SetStatus(state.DoneStatus)
+	}
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	chgs = st.Changes()
+	c.Assert(chgs, check.HasLen, 2)
+	if chgs[0] == chg {
+		chgs[0], chgs[1] = chgs[1], chgs[0]
+	}
+	c.Check(chgs[0].Summary(), check.Equals, `Save data of snaps "a-snap", "c-snap" in scheduled snapshot set #2`)
+
+	// the expiration of automatic snapshots is unaffected
+	c.Check(backendIterCalls, check.Equals, 0)
+}
+
+func (snapshotSuite) TestEnsureScheduledSnapshotsInclude(c *check.C) {
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.Local)
+	defer mockTimeNow(func() time.Time { return now })()
+
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	st.Lock()
+	defer st.Unlock()
+	st.Set("seeded", true)
+	st.Set("last-scheduled-snapshot", now.Add(-24*time.Hour))
+	mockScheduledSnapshotSnaps(st)
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.schedule":          "00:00-23:59",
+		"snapshots.scheduled.include": "c-snap,not-installed",
+	})
+
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	chgs := st.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	c.Check(chgs[0].Summary(), check.Equals, `Save data of snaps "c-snap" in scheduled snapshot set #1`)
+}
+
+func (snapshotSuite) TestEnsureScheduledSnapshotsNotSeeded(c *check.C) {
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	st.Lock()
+	defer st.Unlock()
+	mockScheduledSnapshotSnaps(st)
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.schedule": "00:00-23:59",
+	})
+
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 0)
+}
+
+func (snapshotSuite) TestEnsureScheduledSnapshotsForgetsOldSets(c *check.C) {
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.Local)
+	defer mockTimeNow(func() time.Time { return now })()
+
+	dir := c.MkDir()
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	defer snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		for setID := uint64(1); setID <= 3; setID++ {
+			shotfile, err := os.Create(filepath.Join(dir, "foo.zip"))
+			c.Assert(err, check.IsNil)
+			files = append(files, shotfile)
+			c.Assert(f(&backend.Reader{
+				Snapshot: client.Snapshot{SetID: setID, Snap: "a-snap", SnapID: "a-id", Epoch: snap.Epoch{Read: []uint32{42}, Write: []uint32{17}}},
+				File:     shotfile,
+			}), check.IsNil)
+		}
+		return nil
+	})()
+
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	st.Lock()
+	defer st.Unlock()
+	st.Set("seeded", true)
+	// the next set isn't due yet
+	st.Set("last-scheduled-snapshot", now)
+	st.Set("snapshots", map[uint64]interface{}{
+		1: map[string]interface{}{"scheduled-time": now.Add(-72 * time.Hour)},
+		2: map[string]interface{}{"scheduled-time": now.Add(-48 * time.Hour)},
+		3: map[string]interface{}{"scheduled-time": now.Add(-24 * time.Hour)},
+		// not a scheduled set
+		4: map[string]interface{}{"expiry-time": "2037-02-12T12:50:00Z"},
+		// scheduled but gone already
+		5: map[string]interface{}{"scheduled-time": now.Add(-96 * time.Hour)},
+	})
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.schedule":            "11:00-11:30",
+		"snapshots.scheduled.keep-last": 2,
+	})
+
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	chgs := st.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	c.Check(chgs[0].Kind(), check.Equals, "forget-snapshot")
+	c.Check(chgs[0].Summary(), check.Equals, "Forget scheduled snapshot set #1")
+	tasks := chgs[0].Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "forget-snapshot")
+
+	var snapshots map[uint64]interface{}
+	c.Assert(st.Get("snapshots", &snapshots), check.IsNil)
+	c.Check(snapshots, check.HasLen, 4)
+	c.Check(snapshots[5], check.IsNil)
+
+	// the set is not forgotten twice
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 1)
+}
+
+func (snapshotSuite) TestListSetsScheduledFlag(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	st.Set("snapshots", map[uint64]interface{}{
+		1: map[string]interface{}{"scheduled-time": "2023-01-11T11:11:00Z"},
+		2: map[string]interface{}{"expiry-time": "2023-02-12T12:11:00Z"},
+	})
+
+	defer snapshotstate.MockBackendList(func(ctx context.Context, setID uint64, snapNames []string) ([]client.SnapshotSet, error) {
+		return []client.SnapshotSet{
+			{ID: 1, Snapshots: []*client.Snapshot{{Snap: "foo", SetID: 1}, {Snap: "bar", SetID: 1}}},
+			{ID: 2, Snapshots: []*client.Snapshot{{Snap: "baz", SetID: 2}}},
+			{ID: 3, Snapshots: []*client.Snapshot{{Snap: "baz", SetID: 3}}},
+		}, nil
+	})()
+
+	sets, err := snapshotstate.List(context.TODO(), st, 0, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(sets, check.HasLen, 3)
+	for _, sset := range sets {
+		for _, snapshot := range sset.Snapshots {
+			c.Check(snapshot.Scheduled, check.Equals, sset.ID == 1, check.Commentf("set #%d", sset.ID))
+			c.Check(snapshot.Auto, check.Equals, sset.ID == 2, check.Commentf("set #%d", sset.ID))
+		}
+	}
+}
@@ -52,6 +52,7 @@ var (
 	backendCleanup       = (*backend.RestoreState).Cleanup
 
 	backendCleanupAbandondedImports = backend.CleanupAbandondedImports
+	backendPruneChunks              = backend.PruneChunks
 
 	autoExpirationInterval = time.Hour * 24 // interval between forgetExpiredSnapshots runs as part of Ensure()
 
@@ -63,6 +64,11 @@ type SnapshotManager struct {
 	state *state.State
 
 	lastForgetExpiredSnapshotTime time.Time
+
+	// when the next set is due as per snapshots.schedule, and the
+	// schedule it was computed from
+	nextScheduledSnapshot time.Time
+	lastSnapshotSchedule  string
 }
 
 // Manager returns a new SnapshotManager
@@ -74,6 +80,7 @@ func Manager(st *state.State, runner *state.TaskRunner) *SnapshotManager {
 	runner.AddHandler("check-snapshot", doCheck, nil)
 	runner.AddHandler("restore-snapshot", doRestore, undoRestore)
 	runner.AddHandler("cleanup-after-restore", doCleanupAfterRestore, nil)
+	runner.AddHandler("upload-snapshot", doUpload, nil)
 
 	manager := &SnapshotManager{
 		state: st,
@@ -85,12 +92,16 @@ func Manager(st *state.State, runner *state.TaskRunner) *SnapshotManager {
 
 // Ensure is part of the overlord.StateManager interface.
 func (mgr *SnapshotManager) Ensure() error {
+	var err error
 	// process expired snapshots once a day.
 	if time.Now().After(mgr.lastForgetExpiredSnapshotTime.Add(autoExpirationInterval)) {
-		return mgr.forgetExpiredSnapshots()
+		err = mgr.forgetExpiredSnapshots()
+	}
+	if schedErr := mgr.ensureScheduledSnapshots(); err == nil {
+		err = schedErr
 	}
 
-	return nil
+	return err
 }
 
 func (mgr *SnapshotManager) StartUp() error {
@@ -101,6 +112,14 @@ func (mgr *SnapshotManager) StartUp() error {
 }
 
 func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
+	forgotten := false
+	defer func() {
+		// this runs once the state is unlocked
+		if forgotten {
+			pruneChunks()
+		}
+	}()
+
 	mgr.state.Lock()
 	defer mgr.state.Unlock()
 
@@ -113,6 +132,7 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 		return nil
 	}
 
+	loadEncryptionKeys(mgr.state)
 	err = backendIter(context.TODO(), func(r *backend.Reader) error {
 		// forget needs to conflict with check and restore
 		if err := checkSnapshotConflict(mgr.state, r.SetID, "export-snapshot",
@@ -132,6 +152,7 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 			if err := osRemove(r.Name()); err != nil {
 				return fmt.Errorf("cannot remove snapshot file %q: %v", r.Name(), err)
 			}
+			forgotten = true
 		}
 		return nil
 	})
@@ -149,8 +170,8 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 }
 
 func (SnapshotManager) affectedSnaps(t *state.Task) ([]string, error) {
-	if k := t.Kind(); k == "check-snapshot" || k == "forget-snapshot" {
-		// check and forget don't affect snaps
+	if k := t.Kind(); k == "check-snapshot" || k == "forget-snapshot" || k == "upload-snapshot" {
+		// check, forget and upload don't affect snaps
 		// (this could also be written k != save && k != restore, but it's safer this way around)
 		return nil, nil
 	}
@@ -170,6 +191,7 @@ type snapshotSetup struct {
 	Filename string                `json:"filename,omitempty"`
 	Current  snap.Revision         `json:"current"`
 	Auto     bool                  `json:"auto,omitempty"`
+	Encrypt  bool                  `json:"encrypt,omitempty"`
 }
 
 func filename(setID uint64, si *snap.Info) string {
@@ -184,17 +206,17 @@ func filename(setID uint64, si *snap.Info) string {
 
 // prepareSave does all the steps of doSave that require the state lock;
 // it has no real significance beyond making the lock handling simpler
-func prepareSave(task *state.Task) (snapshot *snapshotSetup, cur *snap.Info, cfg map[string]interface{}, err error) {
+func prepareSave(task *state.Task) (snapshot *snapshotSetup, cur *snap.Info, cfg map[string]interface{}, flags *backend.SaveFlags, err error) {
 	st := task.State()
 	st.Lock()
 	defer st.Unlock()
 
 	if err := task.Get("snapshot-setup", &snapshot); err != nil {
-		return nil, nil, nil, taskGetErrMsg(task, err, "snapshot")
+		return nil, nil, nil, nil, taskGetErrMsg(task, err, "snapshot")
 	}
 	cur, err = snapstateCurrentInfo(st, snapshot.Snap)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 	// updating snapshot-setup with the filename, for use in undo
 	snapshot.Filename = filename(snapshot.SetID, cur)
@@ -202,25 +224,38 @@ func prepareSave(task *state.Task) (snapshot *snapshotSetup, cur *snap.Info, cfg
 
 	cfg, err = unmarshalSnapConfig(st, snapshot.Snap)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
+	}
+
+	key, err := encryptionKey(st, snapshot.Encrypt)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	deduplicate, err := deduplicateSnapshots(st)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	flags = &backend.SaveFlags{
+		EncryptionKey: key,
+		Deduplicate:   deduplicate,
 	}
 
 	// this should be done last because of it modifies the state and the caller needs to undo this if other operation fails.
 	if snapshot.Auto {
 		expiration, err := AutomaticSnapshotExpiration(st)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 		if err := saveExpiration(st, snapshot.SetID, time.Now().Add(expiration)); err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 	}
 
-	return snapshot, cur, cfg, nil
+	return snapshot, cur, cfg, flags, nil
 }
 
 func doSave(task *state.Task, tomb *tomb.Tomb) error {
-	snapshot, cur, cfg, err := prepareSave(task)
+	snapshot, cur, cfg, flags, err := prepareSave(task)
 	if err != nil {
 		return err
 	}
@@ -233,7 +268,7 @@ func doSave(task *state.Task, tomb *tomb.Tomb) error {
 		return err
 	}
 
-	_, err = backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, snapshot.Options, opts)
+	_, err = backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, snapshot.Options, opts, flags)
 	if err != nil {
 		st.Lock()
 		defer st.Unlock()
@@ -253,6 +288,7 @@ func prepareRestore(task *state.Task) (snapshot *snapshotSetup, oldCfg map[strin
 	if err := task.Get("snapshot-setup", &snapshot); err != nil {
 		return nil, nil, nil, taskGetErrMsg(task, err, "snapshot")
 	}
+	loadEncryptionKeys(st)
 
 	oldCfg, err = unmarshalSnapConfig(st, snapshot.Snap)
 	if err != nil {
@@ -422,6 +458,9 @@ func doCheck(task *state.Task, tomb *tomb.Tomb) error {
 	st := task.State()
 	st.Lock()
 	err := task.Get("snapshot-setup", &snapshot)
+	if err == nil {
+		loadEncryptionKeys(st)
+	}
 	st.Unlock()
 	if err != nil {
 		return taskGetErrMsg(task, err, "snapshot")
@@ -458,7 +497,24 @@ func doForget(task *state.Task, _ *tomb.Tomb) error {
 		return fmt.Errorf("internal error: cannot remove state of snapshot set %d: %v", snapshot.SetID, err)
 	}
 
-	return osRemove(snapshot.Filename)
+	if err := osRemove(snapshot.Filename); err != nil {
+		return err
+	}
+
+	// pruning the chunk store is slow, don't hold the lock
+	st.Unlock()
+	defer st.Lock()
+	pruneChunks()
+	return nil
+}
+
+// pruneChunks removes the chunks no longer used by any snapshot from the
+// store of deduplicated snapshots. Failing to do so is not fatal, it will be
+// retried the next time a snapshot is forgotten.
+func pruneChunks() {
+	if _, err := backendPruneChunks(context.TODO()); err != nil {
+		logger.Noticef("Cannot prune snapshot chunks: %v.", err)
+	}
 }
 
 func delayedCrossMgrInit() {
@@ -468,7 +524,7 @@ func delayedCrossMgrInit() {
 	snapstate.EstimateSnapshotSize = EstimateSnapshotSize
 }
 
-func MockBackendSave(f func(context.Context, uint64, *snap.Info, map[string]interface{}, []string, *snap.SnapshotOptions, *dirs.SnapDirOptions) (*client.Snapshot, error)) (restore func()) {
+func MockBackendSave(f func(context.Context, uint64, *snap.Info, map[string]interface{}, []string, *snap.SnapshotOptions, *dirs.SnapDirOptions, *backend.SaveFlags) (*client.Snapshot, error)) (restore func()) {
 	old := backendSave
 	backendSave = f
 	return func() {
@@ -53,6 +53,8 @@ var (
 
 type snapshotState struct {
 	ExpiryTime time.Time `json:"expiry-time"`
+	// ScheduledTime is set for the sets saved following snapshots.schedule
+	ScheduledTime *time.Time `json:"scheduled-time,omitempty"`
 }
 
 func newSnapshotSetID(st *state.State) (uint64, error) {
@@ -151,6 +153,19 @@ func AutomaticSnapshotExpiration(st *state.State) (time.Duration, error) {
 	return defaultAutomaticSnapshotExpiration, nil
 }
 
+// deduplicateSnapshots returns whether snapshots are to be saved in the chunk
+// store shared by all snapshots, as set with snapshots.deduplicate.
+func deduplicateSnapshots(st *state.State) (bool, error) {
+	var deduplicate interface{}
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "snapshots.deduplicate", &deduplicate); err != nil && !config.IsNoOption(err) {
+		return false, err
+	}
+	// the option is validated as "true" or "false" but can be either
+	// a boolean or a string
+	return fmt.Sprintf("%v", deduplicate) == "true", nil
+}
+
 // saveExpiration saves expiration date of the given snapshot set, in the state.
 // The state needs to be locked by the caller.
 func saveExpiration(st *state.State, setID uint64, expiryTime time.Time) error {
@@ -207,6 +222,10 @@ func expiredSnapshotSets(st *state.State, cutoffTime time.Time) (map[uint64]bool
 
 	expired := make(map[uint64]bool)
 	for setID, snapshotSet := range snapshots {
+		// only automatic snapshots expire
+		if snapshotSet.ExpiryTime.IsZero() {
+			continue
+		}
 		if snapshotSet.ExpiryTime.Before(cutoffTime) {
 			expired[setID] = true
 		}
@@ -310,6 +329,7 @@ func checkSnapshotConflict(st *state.State, setID uint64, conflictingKinds ...st
 // List valid snapshots.
 // Note that the state must be locked by the caller.
 func List(ctx context.Context, st *state.State, setID uint64, snapNames []string) ([]client.SnapshotSet, error) {
+	loadEncryptionKeys(st)
 	sets, err := backendList(ctx, setID, snapNames)
 	if err != nil {
 		return nil, err
@@ -320,15 +340,20 @@ func List(ctx context.Context, st *state.State, setID uint64, snapNames []string
 		return nil, err
 	}
 
-	// decorate all snapshots with "auto" flag if we have expiry time set for them.
+	// decorate all snapshots with "auto" flag if we have expiry time set for them,
+	// and with "scheduled" flag if they were saved following snapshots.schedule.
 	for _, sset := range sets {
-		// at the moment we only keep records with expiry time so checking non-zero
-		// expiry-time is not strictly necessary, but it makes it future-proof in case
-		// we add more attributes to these entries.
-		if snapshotState, ok := snapshots[sset.ID]; ok && !snapshotState.ExpiryTime.IsZero() {
-			for _, snapshot := range sset.Snapshots {
+		snapshotState, ok := snapshots[sset.ID]
+		if !ok {
+			continue
+		}
+		for _, snapshot := range sset.Snapshots {
+			if !snapshotState.ExpiryTime.IsZero() {
 				snapshot.Auto = true
 			}
+			if snapshotState.ScheduledTime != nil {
+				snapshot.Scheduled = true
+			}
 		}
 	}
 
@@ -338,6 +363,7 @@ func List(ctx context.Context, st *state.State, setID uint64, snapNames []string
 // Import a given snapshot ID from an exported snapshot
 func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, snapNames []string, err error) {
 	st.Lock()
+	loadEncryptionKeys(st)
 	setID, err = newSnapshotSetID(st)
 	// note, this is a new set id which is not exposed yet, no need to mark it
 	// for conflicts via snapshotOp. Also, since we're keeping state lock while
@@ -378,9 +404,26 @@ func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, sn
 	return setID, snapNames, nil
 }
 
+// SaveFlags are flags for Save.
+type SaveFlags struct {
+	// Encrypt requests the snapshots to be encrypted, with the sealed key
+	// if snapshots.encryption is not set.
+	Encrypt bool
+}
+
 // Save creates a taskset for taking snapshots of snaps' data.
 // Note that the state must be locked by the caller.
-func Save(st *state.State, instanceNames []string, users []string, options map[string]*snap.SnapshotOptions) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+func Save(st *state.State, instanceNames []string, users []string, options map[string]*snap.SnapshotOptions, flags *SaveFlags) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+	if flags == nil {
+		flags = &SaveFlags{}
+	}
+	if flags.Encrypt {
+		// fail early if encryption isn't possible
+		if _, err := encryptionKey(st, true); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
 	if len(instanceNames) == 0 {
 		instanceNames, err = allActiveSnapNames(st)
 		if err != nil {
@@ -410,6 +453,8 @@ func Save(st *state.State, instanceNames []string, users []string, options map[s
 	}
 
 	ts = state.NewTaskSet()
+	saves := state.NewTaskSet()
+	var postSnapshotHooks []*state.Task
 
 	for _, name := range instanceNames {
 		desc := fmt.Sprintf("Save data of snap %q in snapshot set #%d", name, setID)
@@ -420,9 +465,15 @@ func Save(st *state.State, instanceNames []string, users []string, options map[s
 			Snap:    name,
 			Users:   users,
 			Options: options[name],
+			Encrypt: flags.Encrypt,
 		}
 
 		task.Set("snapshot-setup", &snapshot)
+		if snapHasHook(st, name, "pre-snapshot") {
+			preSnapshotHook := SetupPreSnapshotHook(st, name)
+			ts.AddTask(preSnapshotHook)
+			task.WaitFor(preSnapshotHook)
+		}
 		// Here, note that a snapshot set behaves as a unit: it either
 		// succeeds, or fails, as a whole; we don't use lanes, to have
 		// some snaps' snapshot succeed and not others in a single set.
@@ -435,6 +486,32 @@ func Save(st *state.State, instanceNames []string, users []string, options map[s
 		// Also note we aren't promising this behaviour; we can change
 		// it if we find it to be wrong.
 		ts.AddTask(task)
+		saves.AddTask(task)
+		if snapHasHook(st, name, "post-snapshot") {
+			postSnapshotHook := SetupPostSnapshotHook(st, name)
+			ts.AddTask(postSnapshotHook)
+			postSnapshotHooks = append(postSnapshotHooks, postSnapshotHook)
+		}
+	}
+	// the post-snapshot hooks run once the whole set is saved: if saving
+	// any snap fails, none of them runs and the set is undone instead,
+	// which runs the post-snapshot hooks as undo of the pre-snapshot ones
+	for _, postSnapshotHook := range postSnapshotHooks {
+		postSnapshotHook.WaitAll(saves)
+	}
+
+	targetURL, err := coreConfig(config.NewTransaction(st), "snapshots.target")
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if targetURL != "" {
+		// the set is uploaded once fully saved; problems with the
+		// target are reported then, without failing the save
+		desc := fmt.Sprintf("Upload snapshot set #%d to the snapshot target", setID)
+		upload := st.NewTask("upload-snapshot", desc)
+		upload.Set("snapshot-setup", &snapshotSetup{SetID: setID})
+		upload.WaitAll(ts)
+		ts.AddTask(upload)
 	}
 
 	return setID, instanceNames, ts, nil
@@ -470,6 +547,7 @@ func AutomaticSnapshot(st *state.State, snapName string) (ts *state.TaskSet, err
 // Restore creates a taskset for restoring a snapshot's data.
 // Note that the state must be locked by the caller.
 func Restore(st *state.State, setID uint64, snapNames []string, users []string) (snapsFound []string, ts *state.TaskSet, err error) {
+	loadEncryptionKeys(st)
 	summaries, err := snapSummariesInSnapshotSet(setID, snapNames)
 	if err != nil {
 		return nil, nil, err
@@ -491,6 +569,7 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 	}
 
 	ts = state.NewTaskSet()
+	var postRestoreHooks []*state.Task
 
 	for _, summary := range summaries {
 		var current snap.Revision
@@ -509,6 +588,9 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 				return nil, nil, fmt.Errorf(tpl, summary.snap, info.SnapID, summary.snapID)
 			}
 			current = snapst.Current
+			if hasHook(info, "post-restore") {
+				postRestoreHooks = append(postRestoreHooks, SetupPostRestoreHook(st, summary.snap))
+			}
 		}
 
 		desc := fmt.Sprintf("Restore data of snap %q from snapshot set #%d", summary.snap, setID)
@@ -533,6 +615,12 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 		task := st.NewTask("cleanup-after-restore", desc)
 		task.WaitAll(ts)
 		ts.AddTask(task)
+
+		// the snaps can migrate their data once all of it is restored
+		for _, hook := range postRestoreHooks {
+			hook.WaitFor(task)
+			ts.AddTask(hook)
+		}
 	}
 
 	return snapsFound, ts, nil
@@ -546,6 +634,7 @@ func Check(st *state.State, setID uint64, snapNames []string, users []string) (s
 		return nil, nil, err
 	}
 
+	loadEncryptionKeys(st)
 	summaries, err := snapSummariesInSnapshotSet(setID, snapNames)
 	if err != nil {
 		return nil, nil, err
@@ -578,6 +667,7 @@ func Forget(st *state.State, setID uint64, snapNames []string) (snapsFound []str
 		return nil, nil, err
 	}
 
+	loadEncryptionKeys(st)
 	summaries, err := snapSummariesInSnapshotSet(setID, snapNames)
 	if err != nil {
 		return nil, nil, err
@@ -636,6 +726,7 @@ func Export(ctx context.Context, st *state.State, setID uint64) (se *backend.Sna
 	}
 
 	setSnapshotOpInProgress(st, setID, "export-snapshot")
+	loadEncryptionKeys(st)
 	se, err = backendNewSnapshotExport(ctx, setID)
 	if err != nil {
 		UnsetSnapshotOpInProgress(st, setID)
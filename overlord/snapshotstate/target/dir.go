@@ -0,0 +1,119 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package target
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// dirTarget stores snapshots in a local directory, usually a mounted
+// network share or removable drive.
+type dirTarget struct {
+	dir string
+}
+
+func (t *dirTarget) String() string {
+	return t.dir
+}
+
+// contextReader stops reading once the context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+func (t *dirTarget) Upload(ctx context.Context, name string, r io.Reader, size int64) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.dir, 0700); err != nil {
+		return fmt.Errorf("cannot create snapshot target directory: %v", err)
+	}
+	af, err := osutil.NewAtomicFile(filepath.Join(t.dir, name), 0600, 0, osutil.NoChown, osutil.NoChown)
+	if err != nil {
+		return fmt.Errorf("cannot upload snapshot: %v", err)
+	}
+	// Cancel is a no-op once committed
+	defer af.Cancel()
+
+	if _, err := io.Copy(af, &lengthCheckingReader{r: &contextReader{ctx: ctx, r: r}, left: size}); err != nil {
+		return err
+	}
+	if err := af.Commit(); err != nil {
+		return fmt.Errorf("cannot upload snapshot: %v", err)
+	}
+	return nil
+}
+
+func (t *dirTarget) Open(ctx context.Context, name string) (io.ReadCloser, int64, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(filepath.Join(t.dir, name))
+	if err != nil {
+		return nil, 0, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, fi.Size(), nil
+}
+
+func (t *dirTarget) List(ctx context.Context) ([]Object, error) {
+	entries, err := ioutil.ReadDir(t.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	objects := make([]Object, 0, len(entries))
+	for _, fi := range entries {
+		if !fi.Mode().IsRegular() || ValidateName(fi.Name()) != nil {
+			// includes incomplete uploads
+			continue
+		}
+		objects = append(objects, Object{Name: fi.Name(), Size: fi.Size(), Time: fi.ModTime()})
+	}
+	return objects, nil
+}
+
+func (t *dirTarget) Remove(ctx context.Context, name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(t.dir, name))
+}
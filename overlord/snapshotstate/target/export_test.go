@@ -0,0 +1,48 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package target
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+var SignS3Request = signS3Request
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
+
+// MockSFTPDial makes SFTP targets use the given connection to the SFTP
+// subsystem, and returns the address the target would connect to.
+func MockSFTPDial(f func(addr string) (io.ReadWriteCloser, error)) (restore func()) {
+	old := sftpDial
+	sftpDial = func(ctx context.Context, t *sftpTarget) (io.ReadWriteCloser, error) {
+		return f(t.addr)
+	}
+	return func() {
+		sftpDial = old
+	}
+}
@@ -0,0 +1,373 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package target
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+
+	// the largest object that can be uploaded in a single request
+	maxS3ObjectSize = 5 * 1024 * 1024 * 1024
+
+	// the hash of an empty payload, and the marker of payloads that are
+	// not part of the signature
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+)
+
+var (
+	s3HTTPClient = &http.Client{}
+
+	timeNow = time.Now
+)
+
+type bucketPath struct {
+	bucket string
+	// the prefix of the names of objects, empty or ending in a slash
+	prefix string
+}
+
+func bucketAndPrefix(p string) bucketPath {
+	parts := strings.SplitN(strings.Trim(p, "/"), "/", 2)
+	bp := bucketPath{bucket: parts[0]}
+	if len(parts) == 2 && parts[1] != "" {
+		bp.prefix = strings.TrimSuffix(parts[1], "/") + "/"
+	}
+	return bp
+}
+
+// s3Target stores snapshots in an S3-compatible bucket, accessed with
+// path-style requests signed with AWS signature version 4.
+type s3Target struct {
+	scheme string
+	host   string
+	bucketPath
+
+	accessKey string
+	secretKey string
+	region    string
+}
+
+func newS3Target(u *url.URL, cfg *Config) (*s3Target, error) {
+	accessKey, secretKey, err := readS3Credentials(cfg.S3CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	t := &s3Target{
+		scheme:     "https",
+		host:       u.Host,
+		bucketPath: bucketAndPrefix(u.Path),
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		region:     cfg.Region,
+	}
+	if u.Scheme == "s3+http" {
+		t.scheme = "http"
+	}
+	if t.region == "" {
+		t.region = defaultS3Region
+	}
+	return t, nil
+}
+
+// readS3Credentials reads the access key and the secret key from the
+// credentials file, which must not be accessible by other users as it holds
+// the secret key.
+func readS3Credentials(path string) (accessKey, secretKey string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read snapshot target S3 credentials: %v", err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read snapshot target S3 credentials: %v", err)
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		return "", "", fmt.Errorf("cannot use snapshot target S3 credentials file %q: it must not be accessible by other users", path)
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read snapshot target S3 credentials: %v", err)
+	}
+	l := strings.SplitN(strings.TrimSpace(string(data)), ":", 2)
+	if len(l) != 2 || l[0] == "" || l[1] == "" {
+		return "", "", fmt.Errorf("cannot parse snapshot target S3 credentials file %q: must hold the access key and the secret key separated by a colon", path)
+	}
+	return l[0], l[1], nil
+}
+
+func (t *s3Target) String() string {
+	return fmt.Sprintf("%s://%s/%s/%s", t.scheme, t.host, t.bucket, t.prefix)
+}
+
+// s3Escape escapes s as required in canonical requests, keeping slashes if
+// asked to.
+func s3Escape(s string, keepSlash bool) string {
+	var buf strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z', b >= '0' && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			buf.WriteByte(b)
+		case b == '/' && keepSlash:
+			buf.WriteByte(b)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", b)
+		}
+	}
+	return buf.String()
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// signS3Request adds the AWS signature version 4 of the request to it. All
+// the headers of the request are signed; its query must be in canonical
+// form already.
+func signS3Request(req *http.Request, accessKey, secretKey, region, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for k, vs := range req.Header {
+		values := make([]string, len(vs))
+		for i, v := range vs {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[strings.ToLower(k)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", k, headers[k])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func s3ResponseError(rsp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(rsp.Body, 64*1024))
+	var e s3Error
+	if xml.Unmarshal(body, &e) == nil && e.Code != "" {
+		return fmt.Errorf("%s: %s", e.Code, e.Message)
+	}
+	return fmt.Errorf("unexpected status %q", rsp.Status)
+}
+
+func (t *s3Target) do(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64) (*http.Response, error) {
+	p := "/" + t.bucket + "/"
+	if key != "" {
+		p += t.prefix + key
+	}
+	u := &url.URL{
+		Scheme:   t.scheme,
+		Host:     t.host,
+		Path:     p,
+		RawPath:  s3Escape(p, true),
+		RawQuery: canonicalQuery(query),
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+		// the payload is streamed, it can't be hashed beforehand
+		payloadHash = unsignedPayload
+	}
+	signS3Request(req, t.accessKey, t.secretKey, t.region, payloadHash, timeNow())
+	return s3HTTPClient.Do(req)
+}
+
+func (t *s3Target) Upload(ctx context.Context, name string, r io.Reader, size int64) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if size > maxS3ObjectSize {
+		return fmt.Errorf("cannot upload snapshot: snapshots larger than %d bytes cannot be uploaded to S3 targets", int64(maxS3ObjectSize))
+	}
+	rsp, err := t.do(ctx, "PUT", name, nil, &lengthCheckingReader{r: r, left: size}, size)
+	if err != nil {
+		return fmt.Errorf("cannot upload snapshot: %v", err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cannot upload snapshot: %v", s3ResponseError(rsp))
+	}
+	return nil
+}
+
+func (t *s3Target) Open(ctx context.Context, name string) (io.ReadCloser, int64, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, 0, err
+	}
+	rsp, err := t.do(ctx, "GET", name, nil, nil, 0)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot download snapshot: %v", err)
+	}
+	if rsp.StatusCode != http.StatusOK {
+		defer rsp.Body.Close()
+		return nil, 0, fmt.Errorf("cannot download snapshot: %v", s3ResponseError(rsp))
+	}
+	return rsp.Body, rsp.ContentLength, nil
+}
+
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+func (t *s3Target) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	query := url.Values{
+		"list-type": {"2"},
+		"prefix":    {t.prefix},
+	}
+	for {
+		rsp, err := t.do(ctx, "GET", "", query, nil, 0)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list snapshot target: %v", err)
+		}
+		var result s3ListResult
+		if rsp.StatusCode != http.StatusOK {
+			err = s3ResponseError(rsp)
+		} else {
+			err = xml.NewDecoder(rsp.Body).Decode(&result)
+		}
+		rsp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot list snapshot target: %v", err)
+		}
+
+		for _, content := range result.Contents {
+			name := strings.TrimPrefix(content.Key, t.prefix)
+			if ValidateName(name) != nil {
+				continue
+			}
+			objects = append(objects, Object{Name: name, Size: content.Size, Time: content.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	return objects, nil
+}
+
+func (t *s3Target) Remove(ctx context.Context, name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	rsp, err := t.do(ctx, "DELETE", name, nil, nil, 0)
+	if err != nil {
+		return fmt.Errorf("cannot remove snapshot from target: %v", err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusNoContent && rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cannot remove snapshot from target: %v", s3ResponseError(rsp))
+	}
+	return nil
+}
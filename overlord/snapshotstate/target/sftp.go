@@ -0,0 +1,287 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package target
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"path"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const defaultSSHPort = "22"
+
+// sftpTarget stores snapshots in a directory of an SFTP server.
+type sftpTarget struct {
+	user string
+	addr string
+	dir  string
+
+	auth    ssh.AuthMethod
+	hostKey ssh.PublicKey
+}
+
+func newSFTPTarget(u *url.URL, cfg *Config) (*sftpTarget, error) {
+	key, err := ioutil.ReadFile(cfg.SSHKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot target SSH key: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse snapshot target SSH key: %v", err)
+	}
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.SSHHostKey))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse snapshot target SSH host key: %v", err)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultSSHPort)
+	}
+	dir := path.Clean(u.Path)
+	if u.Path == "" {
+		// relative to the home directory of the user
+		dir = "."
+	}
+	return &sftpTarget{
+		user:    u.User.Username(),
+		addr:    addr,
+		dir:     dir,
+		auth:    ssh.PublicKeys(signer),
+		hostKey: hostKey,
+	}, nil
+}
+
+func (t *sftpTarget) String() string {
+	if t.dir == "." {
+		return fmt.Sprintf("sftp://%s@%s", t.user, t.addr)
+	}
+	return fmt.Sprintf("sftp://%s@%s%s", t.user, t.addr, t.dir)
+}
+
+// sftpSession is the SFTP subsystem of an SSH connection.
+type sftpSession struct {
+	io.Reader
+	io.WriteCloser
+
+	client  *ssh.Client
+	session *ssh.Session
+}
+
+func (s *sftpSession) Close() error {
+	s.WriteCloser.Close()
+	s.session.Close()
+	return s.client.Close()
+}
+
+var sftpDial = func(ctx context.Context, t *sftpTarget) (io.ReadWriteCloser, error) {
+	config := &ssh.ClientConfig{
+		User:            t.user,
+		Auth:            []ssh.AuthMethod{t.auth},
+		HostKeyCallback: ssh.FixedHostKey(t.hostKey),
+		Timeout:         30 * time.Second,
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, t.addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	session, err := client.NewSession()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	stdin, err := session.StdinPipe()
+	if err == nil {
+		var stdout io.Reader
+		stdout, err = session.StdoutPipe()
+		if err == nil {
+			err = session.RequestSubsystem("sftp")
+		}
+		if err == nil {
+			return &sftpSession{Reader: stdout, WriteCloser: stdin, client: client, session: session}, nil
+		}
+	}
+	session.Close()
+	client.Close()
+	return nil, err
+}
+
+// connect opens an SFTP session to the server; the session is closed when
+// the context is done.
+func (t *sftpTarget) connect(ctx context.Context) (*sftpClient, error) {
+	conn, err := sftpDial(ctx, t)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to snapshot target: %v", err)
+	}
+	c, err := newSFTPClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot connect to snapshot target: %v", err)
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-c.closed:
+		}
+	}()
+	return c, nil
+}
+
+func (t *sftpTarget) Upload(ctx context.Context, name string, r io.Reader, size int64) (err error) {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	c, err := t.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.mkdirAll(t.dir); err != nil {
+		return fmt.Errorf("cannot create snapshot target directory: %v", err)
+	}
+	// upload to a temporary file first, so that the snapshot is never
+	// seen incomplete
+	tmp := path.Join(t.dir, name+".part~")
+	h, err := c.open(tmp, sftpFlagWrite|sftpFlagCreate|sftpFlagTruncate)
+	if err != nil {
+		return fmt.Errorf("cannot upload snapshot: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			c.remove(tmp)
+		}
+	}()
+	if err := c.writeFrom(h, &lengthCheckingReader{r: r, left: size}); err != nil {
+		c.closeHandle(h)
+		return fmt.Errorf("cannot upload snapshot: %v", err)
+	}
+	if err := c.closeHandle(h); err != nil {
+		return fmt.Errorf("cannot upload snapshot: %v", err)
+	}
+	dst := path.Join(t.dir, name)
+	// the rename of SFTP version 3 doesn't replace existing files
+	if err := c.remove(dst); err != nil && !isSFTPNotExist(err) {
+		return fmt.Errorf("cannot upload snapshot: %v", err)
+	}
+	if err := c.rename(tmp, dst); err != nil {
+		return fmt.Errorf("cannot upload snapshot: %v", err)
+	}
+	return nil
+}
+
+// sftpFileReader reads a remote file, closing the session with it.
+type sftpFileReader struct {
+	c      *sftpClient
+	h      string
+	offset uint64
+}
+
+func (fr *sftpFileReader) Read(p []byte) (int, error) {
+	n, err := fr.c.readAt(fr.h, p, fr.offset)
+	fr.offset += uint64(n)
+	return n, err
+}
+
+func (fr *sftpFileReader) Close() error {
+	fr.c.closeHandle(fr.h)
+	return fr.c.Close()
+}
+
+func (t *sftpTarget) Open(ctx context.Context, name string) (io.ReadCloser, int64, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, 0, err
+	}
+	c, err := t.connect(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	h, err := c.open(path.Join(t.dir, name), sftpFlagRead)
+	if err != nil {
+		c.Close()
+		return nil, 0, fmt.Errorf("cannot download snapshot: %v", err)
+	}
+	attrs, err := c.fstat(h)
+	if err != nil {
+		c.closeHandle(h)
+		c.Close()
+		return nil, 0, fmt.Errorf("cannot download snapshot: %v", err)
+	}
+	return &sftpFileReader{c: c, h: h}, int64(attrs.size), nil
+}
+
+func (t *sftpTarget) List(ctx context.Context) ([]Object, error) {
+	c, err := t.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	entries, err := c.readDir(t.dir)
+	if err != nil {
+		if isSFTPNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot list snapshot target: %v", err)
+	}
+	var objects []Object
+	for _, entry := range entries {
+		if !entry.attrs.isRegular() || ValidateName(entry.name) != nil {
+			continue
+		}
+		objects = append(objects, Object{
+			Name: entry.name,
+			Size: int64(entry.attrs.size),
+			Time: time.Unix(int64(entry.attrs.mtime), 0),
+		})
+	}
+	return objects, nil
+}
+
+func (t *sftpTarget) Remove(ctx context.Context, name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	c, err := t.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.remove(path.Join(t.dir, name)); err != nil {
+		return fmt.Errorf("cannot remove snapshot from target: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,354 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package target_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapshotstate/target"
+	"github.com/snapcore/snapd/testutil"
+)
+
+// fakeSFTPServer serves the subset of SFTP version 3 used by SFTP targets,
+// from a local directory.
+type fakeSFTPServer struct {
+	c    *check.C
+	root string
+	conn net.Conn
+
+	handles map[string]*os.File
+	dirs    map[string][]os.FileInfo
+	next    int
+}
+
+type sftpPacket struct {
+	b []byte
+}
+
+func (p *sftpPacket) u32() uint32 {
+	v := binary.BigEndian.Uint32(p.b)
+	p.b = p.b[4:]
+	return v
+}
+
+func (p *sftpPacket) u64() uint64 {
+	v := binary.BigEndian.Uint64(p.b)
+	p.b = p.b[8:]
+	return v
+}
+
+func (p *sftpPacket) str() string {
+	n := p.u32()
+	s := string(p.b[:n])
+	p.b = p.b[n:]
+	return s
+}
+
+type sftpResponse []byte
+
+func (r *sftpResponse) u32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	*r = append(*r, b[:]...)
+}
+
+func (r *sftpResponse) u64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	*r = append(*r, b[:]...)
+}
+
+func (r *sftpResponse) str(s string) {
+	r.u32(uint32(len(s)))
+	*r = append(*r, s...)
+}
+
+func (r *sftpResponse) attrs(fi os.FileInfo) {
+	r.u32(0x1 | 0x4 | 0x8)
+	r.u64(uint64(fi.Size()))
+	mode := uint32(fi.Mode().Perm())
+	if fi.Mode().IsRegular() {
+		mode |= 0100000
+	} else if fi.IsDir() {
+		mode |= 040000
+	}
+	r.u32(mode)
+	r.u32(uint32(fi.ModTime().Unix()))
+	r.u32(uint32(fi.ModTime().Unix()))
+}
+
+func (f *fakeSFTPServer) path(p string) string {
+	return filepath.Join(f.root, filepath.Clean("/"+p))
+}
+
+func (f *fakeSFTPServer) send(typ byte, id uint32, rsp sftpResponse) {
+	var pkt sftpResponse
+	pkt.u32(uint32(len(rsp) + 5))
+	pkt = append(pkt, typ)
+	pkt.u32(id)
+	pkt = append(pkt, rsp...)
+	_, err := f.conn.Write(pkt)
+	f.c.Assert(err, check.IsNil)
+}
+
+func (f *fakeSFTPServer) status(id uint32, err error) {
+	var rsp sftpResponse
+	switch {
+	case err == nil:
+		rsp.u32(0)
+	case err == io.EOF:
+		rsp.u32(1)
+	case os.IsNotExist(err):
+		rsp.u32(2)
+	default:
+		rsp.u32(4)
+	}
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	rsp.str(msg)
+	rsp.str("")
+	f.send(101, id, rsp)
+}
+
+func (f *fakeSFTPServer) handle(id uint32, h interface{}) {
+	f.next++
+	name := fmt.Sprint(f.next)
+	switch h := h.(type) {
+	case *os.File:
+		f.handles[name] = h
+	case []os.FileInfo:
+		f.dirs[name] = h
+	}
+	var rsp sftpResponse
+	rsp.str(name)
+	f.send(102, id, rsp)
+}
+
+func (f *fakeSFTPServer) serve() {
+	defer f.conn.Close()
+	for {
+		var hdr [5]byte
+		if _, err := io.ReadFull(f.conn, hdr[:]); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(hdr[:4])-1)
+		if _, err := io.ReadFull(f.conn, body); err != nil {
+			return
+		}
+		p := &sftpPacket{b: body}
+		if hdr[4] == 1 {
+			// init
+			var rsp sftpResponse
+			rsp.u32(3)
+			var pkt sftpResponse
+			pkt.u32(uint32(len(rsp) + 1))
+			pkt = append(pkt, 2)
+			pkt = append(pkt, rsp...)
+			f.conn.Write(pkt)
+			continue
+		}
+		id := p.u32()
+		switch hdr[4] {
+		case 3:
+			// open
+			name := f.path(p.str())
+			pflags := p.u32()
+			flags := os.O_RDONLY
+			if pflags&0x2 != 0 {
+				flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			}
+			fd, err := os.OpenFile(name, flags, 0600)
+			if err != nil {
+				f.status(id, err)
+				continue
+			}
+			f.handle(id, fd)
+		case 4:
+			// close
+			h := p.str()
+			if fd := f.handles[h]; fd != nil {
+				fd.Close()
+			}
+			delete(f.handles, h)
+			delete(f.dirs, h)
+			f.status(id, nil)
+		case 5:
+			// read
+			fd := f.handles[p.str()]
+			offset := p.u64()
+			buf := make([]byte, p.u32())
+			n, err := fd.ReadAt(buf, int64(offset))
+			if n == 0 {
+				f.status(id, err)
+				continue
+			}
+			var rsp sftpResponse
+			rsp.str(string(buf[:n]))
+			f.send(103, id, rsp)
+		case 6:
+			// write
+			fd := f.handles[p.str()]
+			offset := p.u64()
+			_, err := fd.WriteAt([]byte(p.str()), int64(offset))
+			f.status(id, err)
+		case 8, 17:
+			// fstat, stat
+			var fi os.FileInfo
+			var err error
+			if hdr[4] == 8 {
+				fi, err = f.handles[p.str()].Stat()
+			} else {
+				fi, err = os.Stat(f.path(p.str()))
+			}
+			if err != nil {
+				f.status(id, err)
+				continue
+			}
+			var rsp sftpResponse
+			rsp.attrs(fi)
+			f.send(105, id, rsp)
+		case 11:
+			// opendir
+			entries, err := ioutil.ReadDir(f.path(p.str()))
+			if err != nil {
+				f.status(id, err)
+				continue
+			}
+			f.handle(id, entries)
+		case 12:
+			// readdir, one entry at a time
+			h := p.str()
+			entries := f.dirs[h]
+			if len(entries) == 0 {
+				f.status(id, io.EOF)
+				continue
+			}
+			f.dirs[h] = entries[1:]
+			var rsp sftpResponse
+			rsp.u32(1)
+			rsp.str(entries[0].Name())
+			rsp.str("-rw------- " + entries[0].Name())
+			rsp.attrs(entries[0])
+			f.send(104, id, rsp)
+		case 13:
+			// remove
+			f.status(id, os.Remove(f.path(p.str())))
+		case 14:
+			// mkdir
+			f.status(id, os.Mkdir(f.path(p.str()), 0700))
+		case 18:
+			// rename, without replacing
+			oldpath := f.path(p.str())
+			newpath := f.path(p.str())
+			if _, err := os.Stat(newpath); err == nil {
+				f.status(id, fmt.Errorf("file exists"))
+				continue
+			}
+			f.status(id, os.Rename(oldpath, newpath))
+		default:
+			f.c.Fatalf("unexpected SFTP request %d", hdr[4])
+		}
+	}
+}
+
+func (s *targetSuite) writeSSHKey(c *check.C) string {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, check.IsNil)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	c.Assert(err, check.IsNil)
+	keyFile := filepath.Join(c.MkDir(), "id_ed25519")
+	c.Assert(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600), check.IsNil)
+	return keyFile
+}
+
+func (s *targetSuite) TestSFTPTarget(c *check.C) {
+	root := c.MkDir()
+	var dialed []string
+	restore := target.MockSFTPDial(func(addr string) (io.ReadWriteCloser, error) {
+		dialed = append(dialed, addr)
+		client, server := net.Pipe()
+		fake := &fakeSFTPServer{
+			c:       c,
+			root:    root,
+			conn:    server,
+			handles: make(map[string]*os.File),
+			dirs:    make(map[string][]os.FileInfo),
+		}
+		go fake.serve()
+		return client, nil
+	})
+	defer restore()
+
+	hostPub, _, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, check.IsNil)
+	sshHostPub, err := ssh.NewPublicKey(hostPub)
+	c.Assert(err, check.IsNil)
+
+	tgt, err := target.New(&target.Config{
+		URL:        "sftp://backup@backup.example.com/srv/snapshots/device",
+		SSHKeyFile: s.writeSSHKey(c),
+		SSHHostKey: strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshHostPub))),
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(tgt.String(), check.Equals, "sftp://backup@backup.example.com:22/srv/snapshots/device")
+
+	checkTarget(c, tgt)
+
+	c.Check(dialed[0], check.Equals, "backup.example.com:22")
+	entries, err := ioutil.ReadDir(filepath.Join(root, "srv/snapshots/device"))
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 1)
+	c.Check(entries[0].Name(), check.Equals, "two.tar")
+	c.Check(filepath.Join(root, "srv/snapshots/device/two.tar"), testutil.FileEquals, "TWO!")
+}
+
+func (s *targetSuite) TestSFTPTargetBadKeys(c *check.C) {
+	keyFile := filepath.Join(c.MkDir(), "key")
+	c.Assert(ioutil.WriteFile(keyFile, []byte("not a key"), 0600), check.IsNil)
+	_, err := target.New(&target.Config{
+		URL:        "sftp://backup@host/srv",
+		SSHKeyFile: keyFile,
+		SSHHostKey: "ssh-ed25519 AAAA",
+	})
+	c.Check(err, check.ErrorMatches, "cannot parse snapshot target SSH key: .*")
+
+	_, err = target.New(&target.Config{
+		URL:        "sftp://backup@host/srv",
+		SSHKeyFile: s.writeSSHKey(c),
+		SSHHostKey: "not a host key",
+	})
+	c.Check(err, check.ErrorMatches, "cannot parse snapshot target SSH host key: .*")
+}
@@ -0,0 +1,503 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package target
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+)
+
+// This is a minimal client of version 3 of the SFTP protocol, as described
+// in draft-ietf-secsh-filexfer-02 and implemented by OpenSSH, supporting
+// what's needed to store snapshots. Requests are sent one at a time.
+
+const (
+	sftpProtocolVersion = 3
+
+	sftpPacketInit    = 1
+	sftpPacketVersion = 2
+	sftpPacketOpen    = 3
+	sftpPacketClose   = 4
+	sftpPacketRead    = 5
+	sftpPacketWrite   = 6
+	sftpPacketFstat   = 8
+	sftpPacketOpendir = 11
+	sftpPacketReaddir = 12
+	sftpPacketRemove  = 13
+	sftpPacketMkdir   = 14
+	sftpPacketStat    = 17
+	sftpPacketRename  = 18
+	sftpPacketStatus  = 101
+	sftpPacketHandle  = 102
+	sftpPacketData    = 103
+	sftpPacketName    = 104
+	sftpPacketAttrs   = 105
+
+	sftpFlagRead     = 0x01
+	sftpFlagWrite    = 0x02
+	sftpFlagCreate   = 0x08
+	sftpFlagTruncate = 0x10
+
+	sftpStatusOK         = 0
+	sftpStatusEOF        = 1
+	sftpStatusNoSuchFile = 2
+
+	sftpAttrSize        = 0x00000001
+	sftpAttrUIDGID      = 0x00000002
+	sftpAttrPermissions = 0x00000004
+	sftpAttrACModTime   = 0x00000008
+	sftpAttrExtended    = 0x80000000
+
+	// the most data read or written per request; servers must support
+	// packets of at least 34000 bytes
+	sftpMaxData = 32 * 1024
+	// the largest packet accepted from servers
+	sftpMaxPacket = 256 * 1024
+)
+
+var errMalformedSFTPPacket = errors.New("malformed SFTP packet")
+
+type sftpStatusError struct {
+	code uint32
+	msg  string
+}
+
+func (e *sftpStatusError) Error() string {
+	if e.msg == "" {
+		return fmt.Sprintf("SFTP error %d", e.code)
+	}
+	return fmt.Sprintf("%s (SFTP error %d)", e.msg, e.code)
+}
+
+func isSFTPNotExist(err error) bool {
+	var statusErr *sftpStatusError
+	return errors.As(err, &statusErr) && statusErr.code == sftpStatusNoSuchFile
+}
+
+type sftpAttrs struct {
+	flags uint32
+	size  uint64
+	perms uint32
+	mtime uint32
+}
+
+func (a *sftpAttrs) isRegular() bool {
+	return a.flags&sftpAttrPermissions != 0 && a.perms&0170000 == 0100000
+}
+
+type sftpEncoder []byte
+
+func (e *sftpEncoder) u8(v byte) {
+	*e = append(*e, v)
+}
+
+func (e *sftpEncoder) u32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	*e = append(*e, b[:]...)
+}
+
+func (e *sftpEncoder) u64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	*e = append(*e, b[:]...)
+}
+
+func (e *sftpEncoder) str(s []byte) {
+	e.u32(uint32(len(s)))
+	*e = append(*e, s...)
+}
+
+type sftpDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *sftpDecoder) take(n int) []byte {
+	if d.err != nil || n > len(d.b) {
+		d.err = errMalformedSFTPPacket
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *sftpDecoder) u32() uint32 {
+	if b := d.take(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *sftpDecoder) u64() uint64 {
+	if b := d.take(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *sftpDecoder) str() []byte {
+	return d.take(int(d.u32()))
+}
+
+func (d *sftpDecoder) attrs() sftpAttrs {
+	var a sftpAttrs
+	a.flags = d.u32()
+	if a.flags&sftpAttrSize != 0 {
+		a.size = d.u64()
+	}
+	if a.flags&sftpAttrUIDGID != 0 {
+		d.u32()
+		d.u32()
+	}
+	if a.flags&sftpAttrPermissions != 0 {
+		a.perms = d.u32()
+	}
+	if a.flags&sftpAttrACModTime != 0 {
+		d.u32()
+		a.mtime = d.u32()
+	}
+	if a.flags&sftpAttrExtended != 0 {
+		for n := d.u32(); n > 0 && d.err == nil; n-- {
+			d.str()
+			d.str()
+		}
+	}
+	return a
+}
+
+type sftpClient struct {
+	conn   io.ReadWriteCloser
+	nextID uint32
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newSFTPClient(conn io.ReadWriteCloser) (*sftpClient, error) {
+	c := &sftpClient{conn: conn, closed: make(chan struct{})}
+	var init sftpEncoder
+	init.u32(sftpProtocolVersion)
+	if err := c.sendPacket(sftpPacketInit, init); err != nil {
+		return nil, err
+	}
+	typ, d, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpPacketVersion {
+		return nil, fmt.Errorf("unexpected SFTP packet type %d", typ)
+	}
+	if version := d.u32(); d.err != nil || version < sftpProtocolVersion {
+		return nil, fmt.Errorf("unsupported SFTP server version %d", version)
+	}
+	return c, nil
+}
+
+func (c *sftpClient) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+func (c *sftpClient) sendPacket(typ byte, payload []byte) error {
+	var pkt sftpEncoder
+	pkt.u32(uint32(len(payload) + 1))
+	pkt.u8(typ)
+	pkt = append(pkt, payload...)
+	_, err := c.conn.Write(pkt)
+	return err
+}
+
+func (c *sftpClient) readPacket() (byte, *sftpDecoder, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(c.conn, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(hdr[:4])
+	if length < 1 || length > sftpMaxPacket {
+		return 0, nil, errMalformedSFTPPacket
+	}
+	payload := make([]byte, length-1)
+	if _, err := io.ReadFull(c.conn, payload); err != nil {
+		return 0, nil, err
+	}
+	return hdr[4], &sftpDecoder{b: payload}, nil
+}
+
+// request sends a request and returns the type and the payload of its
+// response, after the request id.
+func (c *sftpClient) request(typ byte, build func(e *sftpEncoder)) (byte, *sftpDecoder, error) {
+	c.nextID++
+	id := c.nextID
+	var req sftpEncoder
+	req.u32(id)
+	build(&req)
+	if err := c.sendPacket(typ, req); err != nil {
+		return 0, nil, err
+	}
+	rtyp, d, err := c.readPacket()
+	if err != nil {
+		return 0, nil, err
+	}
+	if rid := d.u32(); d.err != nil || rid != id {
+		return 0, nil, errMalformedSFTPPacket
+	}
+	return rtyp, d, nil
+}
+
+func statusError(typ byte, d *sftpDecoder) error {
+	if typ != sftpPacketStatus {
+		return fmt.Errorf("unexpected SFTP packet type %d", typ)
+	}
+	code := d.u32()
+	msg := d.str()
+	if d.err != nil {
+		return d.err
+	}
+	if code == sftpStatusOK {
+		return nil
+	}
+	return &sftpStatusError{code: code, msg: string(msg)}
+}
+
+// expect returns the error of responses that are not of the given type.
+func expect(want byte, typ byte, d *sftpDecoder) error {
+	if typ == want {
+		return nil
+	}
+	if err := statusError(typ, d); err != nil {
+		return err
+	}
+	return fmt.Errorf("unexpected SFTP status in response")
+}
+
+func (c *sftpClient) statusRequest(typ byte, build func(e *sftpEncoder)) error {
+	rtyp, d, err := c.request(typ, build)
+	if err != nil {
+		return err
+	}
+	return statusError(rtyp, d)
+}
+
+func (c *sftpClient) handleRequest(typ byte, build func(e *sftpEncoder)) (string, error) {
+	rtyp, d, err := c.request(typ, build)
+	if err != nil {
+		return "", err
+	}
+	if err := expect(sftpPacketHandle, rtyp, d); err != nil {
+		return "", err
+	}
+	h := d.str()
+	return string(h), d.err
+}
+
+func (c *sftpClient) open(p string, pflags uint32) (string, error) {
+	return c.handleRequest(sftpPacketOpen, func(e *sftpEncoder) {
+		e.str([]byte(p))
+		e.u32(pflags)
+		if pflags&sftpFlagCreate != 0 {
+			e.u32(sftpAttrPermissions)
+			e.u32(0600)
+		} else {
+			e.u32(0)
+		}
+	})
+}
+
+func (c *sftpClient) closeHandle(h string) error {
+	return c.statusRequest(sftpPacketClose, func(e *sftpEncoder) {
+		e.str([]byte(h))
+	})
+}
+
+func (c *sftpClient) writeFrom(h string, r io.Reader) error {
+	buf := make([]byte, sftpMaxData)
+	var offset uint64
+	for {
+		n, rerr := io.ReadFull(r, buf)
+		if n > 0 {
+			err := c.statusRequest(sftpPacketWrite, func(e *sftpEncoder) {
+				e.str([]byte(h))
+				e.u64(offset)
+				e.str(buf[:n])
+			})
+			if err != nil {
+				return err
+			}
+			offset += uint64(n)
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+}
+
+func (c *sftpClient) readAt(h string, p []byte, offset uint64) (int, error) {
+	if len(p) > sftpMaxData {
+		p = p[:sftpMaxData]
+	}
+	typ, d, err := c.request(sftpPacketRead, func(e *sftpEncoder) {
+		e.str([]byte(h))
+		e.u64(offset)
+		e.u32(uint32(len(p)))
+	})
+	if err != nil {
+		return 0, err
+	}
+	if typ == sftpPacketStatus {
+		err := statusError(typ, d)
+		if statusErr, ok := err.(*sftpStatusError); ok && statusErr.code == sftpStatusEOF {
+			return 0, io.EOF
+		}
+		if err == nil {
+			err = fmt.Errorf("unexpected SFTP status in response")
+		}
+		return 0, err
+	}
+	if err := expect(sftpPacketData, typ, d); err != nil {
+		return 0, err
+	}
+	data := d.str()
+	if d.err != nil || len(data) > len(p) {
+		return 0, errMalformedSFTPPacket
+	}
+	return copy(p, data), nil
+}
+
+func (c *sftpClient) fstat(h string) (sftpAttrs, error) {
+	typ, d, err := c.request(sftpPacketFstat, func(e *sftpEncoder) {
+		e.str([]byte(h))
+	})
+	if err != nil {
+		return sftpAttrs{}, err
+	}
+	if err := expect(sftpPacketAttrs, typ, d); err != nil {
+		return sftpAttrs{}, err
+	}
+	attrs := d.attrs()
+	return attrs, d.err
+}
+
+func (c *sftpClient) stat(p string) (sftpAttrs, error) {
+	typ, d, err := c.request(sftpPacketStat, func(e *sftpEncoder) {
+		e.str([]byte(p))
+	})
+	if err != nil {
+		return sftpAttrs{}, err
+	}
+	if err := expect(sftpPacketAttrs, typ, d); err != nil {
+		return sftpAttrs{}, err
+	}
+	attrs := d.attrs()
+	return attrs, d.err
+}
+
+func (c *sftpClient) mkdir(p string) error {
+	return c.statusRequest(sftpPacketMkdir, func(e *sftpEncoder) {
+		e.str([]byte(p))
+		e.u32(sftpAttrPermissions)
+		e.u32(0700)
+	})
+}
+
+func (c *sftpClient) mkdirAll(dir string) error {
+	if _, err := c.stat(dir); err == nil || !isSFTPNotExist(err) {
+		return err
+	}
+	if parent := path.Dir(dir); parent != dir {
+		if err := c.mkdirAll(parent); err != nil {
+			return err
+		}
+	}
+	return c.mkdir(dir)
+}
+
+func (c *sftpClient) remove(p string) error {
+	return c.statusRequest(sftpPacketRemove, func(e *sftpEncoder) {
+		e.str([]byte(p))
+	})
+}
+
+func (c *sftpClient) rename(oldpath, newpath string) error {
+	return c.statusRequest(sftpPacketRename, func(e *sftpEncoder) {
+		e.str([]byte(oldpath))
+		e.str([]byte(newpath))
+	})
+}
+
+type sftpDirEntry struct {
+	name  string
+	attrs sftpAttrs
+}
+
+func (c *sftpClient) readDir(dir string) ([]sftpDirEntry, error) {
+	h, err := c.handleRequest(sftpPacketOpendir, func(e *sftpEncoder) {
+		e.str([]byte(dir))
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeHandle(h)
+
+	var entries []sftpDirEntry
+	for {
+		typ, d, err := c.request(sftpPacketReaddir, func(e *sftpEncoder) {
+			e.str([]byte(h))
+		})
+		if err != nil {
+			return nil, err
+		}
+		if typ == sftpPacketStatus {
+			err := statusError(typ, d)
+			if statusErr, ok := err.(*sftpStatusError); ok && statusErr.code == sftpStatusEOF {
+				return entries, nil
+			}
+			if err == nil {
+				err = fmt.Errorf("unexpected SFTP status in response")
+			}
+			return nil, err
+		}
+		if err := expect(sftpPacketName, typ, d); err != nil {
+			return nil, err
+		}
+		for n := d.u32(); n > 0 && d.err == nil; n-- {
+			name := d.str()
+			// the long name is for humans
+			d.str()
+			attrs := d.attrs()
+			entries = append(entries, sftpDirEntry{name: string(name), attrs: attrs})
+		}
+		if d.err != nil {
+			return nil, d.err
+		}
+	}
+}
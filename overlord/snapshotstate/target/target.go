@@ -0,0 +1,180 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package target implements the off-device locations exported snapshot
+// sets are uploaded to, and imported back from: a local (usually mounted)
+// directory, an SFTP server or an S3-compatible bucket.
+package target
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Object is a file stored on a target.
+type Object struct {
+	Name string
+	Size int64
+	Time time.Time
+}
+
+// Target is a location snapshots can be stored to. Objects are flat, their
+// names must be valid as per ValidateName.
+type Target interface {
+	// Upload stores size bytes read from r as the named object,
+	// replacing any existing one. A failed upload doesn't leave a
+	// partial object behind.
+	Upload(ctx context.Context, name string, r io.Reader, size int64) error
+	// Open returns a reader for the named object, and its size.
+	Open(ctx context.Context, name string) (io.ReadCloser, int64, error)
+	// List returns the objects on the target.
+	List(ctx context.Context) ([]Object, error)
+	// Remove removes the named object.
+	Remove(ctx context.Context, name string) error
+	// String returns a description of the target without credentials.
+	String() string
+}
+
+// Config is the configuration of a target.
+type Config struct {
+	// URL is one of:
+	//   file:///path (or just /path) for a local directory,
+	//   sftp://user@host[:port]/path for an SFTP server,
+	//   s3://host[:port]/bucket[/prefix] for an S3-compatible bucket,
+	//   s3+http://host[:port]/bucket[/prefix] for the same over plain
+	//   HTTP.
+	URL string
+
+	// SSHKeyFile is the private key used to log into SFTP servers.
+	SSHKeyFile string
+	// SSHHostKey is the public key of the SFTP server, in authorized_keys
+	// format.
+	SSHHostKey string
+
+	// S3CredentialsFile holds the credentials for S3 buckets, the access
+	// key and the secret key separated by a colon. It must only be
+	// accessible by its owner.
+	S3CredentialsFile string
+	// Region is the region of S3 buckets, us-east-1 by default.
+	Region string
+}
+
+func (cfg *Config) parseURL() (*url.URL, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot target: %v", err)
+	}
+	switch u.Scheme {
+	case "", "file":
+		if u.Host != "" || !filepath.IsAbs(u.Path) {
+			return nil, fmt.Errorf("invalid snapshot target %q: must be an absolute path", cfg.URL)
+		}
+	case "sftp":
+		if u.Host == "" || u.User == nil || u.User.Username() == "" {
+			return nil, fmt.Errorf("invalid snapshot target %q: must specify a user and a host", cfg.URL)
+		}
+		if _, ok := u.User.Password(); ok {
+			return nil, fmt.Errorf("invalid snapshot target %q: passwords are not supported, use a key", cfg.URL)
+		}
+		if cfg.SSHKeyFile == "" || cfg.SSHHostKey == "" {
+			return nil, fmt.Errorf("invalid snapshot target %q: SFTP targets need an SSH key and the host key of the server", cfg.URL)
+		}
+	case "s3", "s3+http":
+		if u.Host == "" || bucketAndPrefix(u.Path).bucket == "" {
+			return nil, fmt.Errorf("invalid snapshot target %q: must specify a host and a bucket", cfg.URL)
+		}
+		if cfg.S3CredentialsFile == "" {
+			return nil, fmt.Errorf("invalid snapshot target %q: S3 targets need a credentials file", cfg.URL)
+		}
+	default:
+		return nil, fmt.Errorf("invalid snapshot target %q: unsupported scheme %q", cfg.URL, u.Scheme)
+	}
+	return u, nil
+}
+
+// Validate checks the configuration, without accessing the target or any
+// of the files it refers to.
+func (cfg *Config) Validate() error {
+	_, err := cfg.parseURL()
+	return err
+}
+
+// New returns the target described by the configuration. The target is
+// only accessed when used.
+func New(cfg *Config) (Target, error) {
+	u, err := cfg.parseURL()
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "", "file":
+		return &dirTarget{dir: path.Clean(u.Path)}, nil
+	case "sftp":
+		return newSFTPTarget(u, cfg)
+	default:
+		return newS3Target(u, cfg)
+	}
+}
+
+// ValidateName checks that name can be used as the name of an object.
+func ValidateName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, "/\\") || strings.HasSuffix(name, "~") {
+		return fmt.Errorf("invalid snapshot target object name %q", name)
+	}
+	return nil
+}
+
+// lengthCheckingReader fails if the data read from r isn't of the expected
+// size.
+type lengthCheckingReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (lr *lengthCheckingReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	lr.left -= int64(n)
+	if lr.left < 0 {
+		return 0, fmt.Errorf("cannot upload snapshot: more data than expected")
+	}
+	if err == io.EOF && lr.left > 0 {
+		return n, fmt.Errorf("cannot upload snapshot: %d bytes missing", lr.left)
+	}
+	if err == nil && lr.left == 0 {
+		// readers limited to the expected size, as done by HTTP
+		// clients, would never find out about extra data
+		var extra [1]byte
+		m, perr := lr.r.Read(extra[:])
+		if m > 0 {
+			// the last of the data is held back so that it's never
+			// sent in full
+			return 0, fmt.Errorf("cannot upload snapshot: more data than expected")
+		}
+		if perr != nil {
+			err = perr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,242 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate/target"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// ErrNoSnapshotTarget is returned when accessing the snapshot target while
+// snapshots.target is not set.
+var ErrNoSnapshotTarget = errors.New("snapshots.target is not set")
+
+var (
+	targetNew = target.New
+
+	exportForUpload = func(ctx context.Context, st *state.State, setID uint64) (exportStream, error) {
+		se, err := Export(ctx, st, setID)
+		if err != nil {
+			return nil, err
+		}
+		return se, nil
+	}
+)
+
+// exportStream is what is needed of a SnapshotExport to upload it.
+type exportStream interface {
+	Init() error
+	Size() int64
+	StreamTo(w io.Writer) error
+	Close()
+}
+
+// configuredTarget returns the target set with snapshots.target, or nil if
+// it's not set. The state must be locked by the caller.
+func configuredTarget(st *state.State) (target.Target, error) {
+	tr := config.NewTransaction(st)
+	var cfg target.Config
+	for _, opt := range []struct {
+		key   string
+		value *string
+	}{
+		{"snapshots.target", &cfg.URL},
+		{"snapshots.target-ssh-key-file", &cfg.SSHKeyFile},
+		{"snapshots.target-ssh-host-key", &cfg.SSHHostKey},
+		{"snapshots.target-s3-credentials-file", &cfg.S3CredentialsFile},
+		{"snapshots.target-region", &cfg.Region},
+	} {
+		value, err := coreConfig(tr, opt.key)
+		if err != nil {
+			return nil, err
+		}
+		*opt.value = value
+	}
+	if cfg.URL == "" {
+		return nil, nil
+	}
+	return targetNew(&cfg)
+}
+
+const targetObjectTimeFormat = "20060102T150405Z"
+
+var targetObjectRegexp = regexp.MustCompile(`^snapshot-([0-9]+)-([0-9]{8}T[0-9]{6}Z)\.tar$`)
+
+// targetObjectName returns the name the given snapshot set is uploaded as;
+// set IDs are only unique on a device, the time keeps uploads of sets with
+// the same ID apart.
+func targetObjectName(setID uint64, t time.Time) string {
+	return fmt.Sprintf("snapshot-%d-%s.tar", setID, t.UTC().Format(targetObjectTimeFormat))
+}
+
+// parseTargetObjectName returns the ID of the uploaded snapshot set, or 0 if
+// the object wasn't uploaded by snapd.
+func parseTargetObjectName(name string) uint64 {
+	m := targetObjectRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return 0
+	}
+	setID, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return setID
+}
+
+// doUpload uploads the snapshot set saved by the other tasks of the change
+// to the configured target. Failing to upload does not fail the change, as
+// that would forget the set that was saved fine.
+func doUpload(task *state.Task, tomb *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var snapshot snapshotSetup
+	if err := task.Get("snapshot-setup", &snapshot); err != nil {
+		return taskGetErrMsg(task, err, "snapshot")
+	}
+
+	tgt, err := configuredTarget(st)
+	if err != nil {
+		uploadFailed(task, snapshot.SetID, err)
+		return nil
+	}
+	if tgt == nil {
+		// snapshots.target was unset in the meantime
+		return nil
+	}
+
+	ctx := tomb.Context(nil)
+	se, err := exportForUpload(ctx, st, snapshot.SetID)
+	if err != nil {
+		uploadFailed(task, snapshot.SetID, err)
+		return nil
+	}
+	defer UnsetSnapshotOpInProgress(st, snapshot.SetID)
+
+	name := targetObjectName(snapshot.SetID, timeNow())
+	st.Unlock()
+	err = uploadExport(ctx, tgt, name, se)
+	st.Lock()
+	if err != nil {
+		uploadFailed(task, snapshot.SetID, err)
+		return nil
+	}
+	task.Logf("Uploaded snapshot set #%d to %s as %q.", snapshot.SetID, tgt, name)
+	return nil
+}
+
+func uploadFailed(task *state.Task, setID uint64, err error) {
+	task.Logf("Cannot upload snapshot set #%d: %v", setID, err)
+	task.State().Warnf("Cannot upload snapshot set #%d to the snapshot target: %v", setID, err)
+}
+
+// uploadExport streams the export to the target. It must be called without
+// the state lock held.
+func uploadExport(ctx context.Context, tgt target.Target, name string, se exportStream) error {
+	defer se.Close()
+	if err := se.Init(); err != nil {
+		return err
+	}
+	pr, pw := io.Pipe()
+	streamed := make(chan struct{})
+	go func() {
+		defer close(streamed)
+		pw.CloseWithError(se.StreamTo(pw))
+	}()
+	err := tgt.Upload(ctx, name, pr, se.Size())
+	// stops the export if the upload bailed out early
+	pr.CloseWithError(io.ErrClosedPipe)
+	<-streamed
+	return err
+}
+
+// TargetSnapshots lists the exported snapshot sets on the configured target,
+// oldest first.
+func TargetSnapshots(ctx context.Context, st *state.State) ([]client.RemoteSnapshot, error) {
+	st.Lock()
+	tgt, err := configuredTarget(st)
+	st.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if tgt == nil {
+		return nil, ErrNoSnapshotTarget
+	}
+
+	objects, err := tgt.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]client.RemoteSnapshot, 0, len(objects))
+	for _, obj := range objects {
+		snapshots = append(snapshots, client.RemoteSnapshot{
+			Name:  obj.Name,
+			SetID: parseTargetObjectName(obj.Name),
+			Size:  obj.Size,
+			Time:  obj.Time,
+		})
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if !snapshots[i].Time.Equal(snapshots[j].Time) {
+			return snapshots[i].Time.Before(snapshots[j].Time)
+		}
+		return snapshots[i].Name < snapshots[j].Name
+	})
+	return snapshots, nil
+}
+
+// ImportFromTarget imports the named exported snapshot set from the
+// configured target, as Import does. The state must not be locked by the
+// caller.
+func ImportFromTarget(ctx context.Context, st *state.State, name string) (setID uint64, snapNames []string, err error) {
+	if err := target.ValidateName(name); err != nil {
+		return 0, nil, err
+	}
+	st.Lock()
+	tgt, err := configuredTarget(st)
+	st.Unlock()
+	if err != nil {
+		return 0, nil, err
+	}
+	if tgt == nil {
+		return 0, nil, ErrNoSnapshotTarget
+	}
+
+	r, _, err := tgt.Open(ctx, name)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer r.Close()
+	return Import(ctx, st, r)
+}
@@ -0,0 +1,218 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type fakeExportStream struct {
+	data   string
+	closed bool
+}
+
+func (se *fakeExportStream) Init() error { return nil }
+
+func (se *fakeExportStream) Size() int64 { return int64(len(se.data)) }
+
+func (se *fakeExportStream) StreamTo(w io.Writer) error {
+	_, err := io.WriteString(w, se.data)
+	return err
+}
+
+func (se *fakeExportStream) Close() { se.closed = true }
+
+func (snapshotSuite) TestSaveWithTarget(c *check.C) {
+	defer snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"a-snap": {Active: true},
+			"b-snap": {Active: true},
+		}, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.target": "/media/backup",
+	})
+
+	setID, _, ts, err := snapshotstate.Save(st, nil, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	tasks := ts.Tasks()
+	c.Assert(tasks, check.HasLen, 3)
+	upload := tasks[2]
+	c.Check(upload.Kind(), check.Equals, "upload-snapshot")
+	c.Check(upload.Summary(), check.Equals, "Upload snapshot set #1 to the snapshot target")
+	c.Check(upload.WaitTasks(), check.DeepEquals, tasks[:2])
+	var snapshot map[string]interface{}
+	c.Assert(upload.Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot["set-id"], check.Equals, float64(setID))
+}
+
+func (snapshotSuite) TestDoUpload(c *check.C) {
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	defer snapshotstate.MockTimeNow(func() time.Time { return now })()
+	se := &fakeExportStream{data: "exported snapshot set"}
+	defer snapshotstate.MockExportForUpload(func(_ context.Context, _ *state.State, setID uint64) (snapshotstate.ExportStream, error) {
+		c.Check(setID, check.Equals, uint64(42))
+		return se, nil
+	})()
+
+	dir := filepath.Join(c.MkDir(), "backup")
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.target": dir,
+	})
+
+	task := st.NewTask("upload-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{"set-id": 42})
+	st.Unlock()
+	err := snapshotstate.DoUpload(task, &tomb.Tomb{})
+	st.Lock()
+	c.Assert(err, check.IsNil)
+	c.Check(se.closed, check.Equals, true)
+	c.Check(filepath.Join(dir, "snapshot-42-20230301T100000Z.tar"), testutil.FileEquals, "exported snapshot set")
+	c.Check(strings.Join(task.Log(), "\n"), check.Matches, `.* Uploaded snapshot set #42 to .*/backup as "snapshot-42-20230301T100000Z.tar".`)
+	c.Check(st.AllWarnings(), check.HasLen, 0)
+}
+
+func (snapshotSuite) TestDoUploadNoTarget(c *check.C) {
+	defer snapshotstate.MockExportForUpload(func(context.Context, *state.State, uint64) (snapshotstate.ExportStream, error) {
+		c.Fatal("unexpected export")
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	task := st.NewTask("upload-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{"set-id": 42})
+	st.Unlock()
+	err := snapshotstate.DoUpload(task, &tomb.Tomb{})
+	st.Lock()
+	c.Assert(err, check.IsNil)
+	c.Check(task.Log(), check.HasLen, 0)
+}
+
+func (snapshotSuite) TestDoUploadFailureWarns(c *check.C) {
+	defer snapshotstate.MockExportForUpload(func(context.Context, *state.State, uint64) (snapshotstate.ExportStream, error) {
+		return nil, errors.New("no snapshot data found for 42")
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.target": c.MkDir(),
+	})
+
+	task := st.NewTask("upload-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{"set-id": 42})
+	st.Unlock()
+	err := snapshotstate.DoUpload(task, &tomb.Tomb{})
+	st.Lock()
+	// the saved set is kept
+	c.Assert(err, check.IsNil)
+	c.Check(strings.Join(task.Log(), "\n"), check.Matches, `.* Cannot upload snapshot set #42: no snapshot data found for 42`)
+	warnings := st.AllWarnings()
+	c.Assert(warnings, check.HasLen, 1)
+	c.Check(warnings[0].String(), check.Equals, "Cannot upload snapshot set #42 to the snapshot target: no snapshot data found for 42")
+}
+
+func (snapshotSuite) TestTargetSnapshots(c *check.C) {
+	dir := c.MkDir()
+	for i, name := range []string{"snapshot-2-20230302T000000Z.tar", "snapshot-1-20230301T000000Z.tar", "other.tar", ".hidden"} {
+		fn := filepath.Join(dir, name)
+		c.Assert(ioutil.WriteFile(fn, []byte("data"), 0600), check.IsNil)
+		mtime := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(3-i) * time.Hour)
+		c.Assert(os.Chtimes(fn, mtime, mtime), check.IsNil)
+	}
+
+	st := state.New(nil)
+	_, err := snapshotstate.TargetSnapshots(context.TODO(), st)
+	c.Check(err, check.Equals, snapshotstate.ErrNoSnapshotTarget)
+	st.Lock()
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.target": dir,
+	})
+	st.Unlock()
+
+	snapshots, err := snapshotstate.TargetSnapshots(context.TODO(), st)
+	c.Assert(err, check.IsNil)
+	c.Assert(snapshots, check.HasLen, 3)
+	for i, expected := range []client.RemoteSnapshot{
+		{Name: "other.tar", Size: 4},
+		{Name: "snapshot-1-20230301T000000Z.tar", SetID: 1, Size: 4},
+		{Name: "snapshot-2-20230302T000000Z.tar", SetID: 2, Size: 4},
+	} {
+		c.Check(snapshots[i].Name, check.Equals, expected.Name)
+		c.Check(snapshots[i].SetID, check.Equals, expected.SetID)
+		c.Check(snapshots[i].Size, check.Equals, expected.Size)
+	}
+}
+
+func (snapshotSuite) TestImportFromTarget(c *check.C) {
+	dir := c.MkDir()
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "snapshot-1-20230301T000000Z.tar"), []byte("exported snapshot set"), 0600), check.IsNil)
+	defer snapshotstate.MockBackendImport(func(_ context.Context, setID uint64, r io.Reader, _ *backend.ImportFlags) ([]string, error) {
+		data, err := ioutil.ReadAll(r)
+		c.Assert(err, check.IsNil)
+		c.Check(string(data), check.Equals, "exported snapshot set")
+		return []string{"a-snap"}, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.target": dir,
+	})
+	st.Unlock()
+
+	setID, names, err := snapshotstate.ImportFromTarget(context.TODO(), st, "snapshot-1-20230301T000000Z.tar")
+	c.Assert(err, check.IsNil)
+	c.Check(setID, check.Equals, uint64(1))
+	c.Check(names, check.DeepEquals, []string{"a-snap"})
+
+	_, _, err = snapshotstate.ImportFromTarget(context.TODO(), st, "missing.tar")
+	c.Check(err, check.ErrorMatches, `open .*/missing.tar: no such file or directory`)
+	_, _, err = snapshotstate.ImportFromTarget(context.TODO(), st, "../escape.tar")
+	c.Check(err, check.ErrorMatches, `invalid snapshot target object name "../escape.tar"`)
+}
@@ -646,6 +646,7 @@ func (m *autoRefresh) launchAutoRefresh(overrideDelay bool) error {
 	}
 
 	chg := m.state.NewChange("auto-refresh", msg)
+	chg.SetPriority(state.LowPriority)
 	for _, ts := range updateTss.Refresh {
 		chg.AddAll(ts)
 	}
@@ -672,6 +673,7 @@ func createPreDownloadChange(st *state.State, updateTss *UpdateTaskSets) (bool,
 
 		chgSummary := fmt.Sprintf(i18n.G("Pre-download %s for auto-refresh"), strutil.Quoted(snapNames))
 		preDlChg := st.NewChange("pre-download", chgSummary)
+		preDlChg.SetPriority(state.LowPriority)
 		for _, ts := range updateTss.PreDownload {
 			preDlChg.AddAll(ts)
 		}
@@ -824,6 +824,7 @@ func AutoRefreshForGatingSnap(st *state.State, gatingSnap string) error {
 	// is not treated as a full auto-refresh.
 
 	chg := st.NewChange("auto-refresh", msg)
+	chg.SetPriority(state.LowPriority)
 	for _, ts := range tasksets {
 		chg.AddAll(ts)
 	}
@@ -1747,6 +1747,51 @@ func (s *autorefreshGatingSuite) TestAutoRefreshPhase1ConflictsFilteredOut(c *C)
 	c.Check(candidates["snap-c"], NotNil)
 }
 
+func (s *autorefreshGatingSuite) TestAutoRefreshPhase1HeldChangeConflicts(c *C) {
+	s.store.refreshedSnaps = []*snap.Info{{
+		Architectures: []string{"all"},
+		SnapType:      snap.TypeApp,
+		SideInfo: snap.SideInfo{
+			RealName: "snap-a",
+			Revision: snap.R(8),
+		},
+	}, {
+		Architectures: []string{"all"},
+		SnapType:      snap.TypeBase,
+		SideInfo: snap.SideInfo{
+			RealName: "snap-c",
+			Revision: snap.R(5),
+		},
+	}}
+
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	mockInstalledSnap(c, s.state, snapAyaml, useHook)
+	mockInstalledSnap(c, s.state, snapCyaml, noHook)
+
+	// a change of snap-c is held until tomorrow
+	heldChange := st.NewChange("refresh-snap", "")
+	heldTask := st.NewTask("prerequisites", "")
+	heldTask.Set("snap-setup", snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "snap-c", Revision: snap.R(4)}})
+	heldChange.AddTask(heldTask)
+	heldChange.SetNotBefore(time.Now().Add(24 * time.Hour))
+
+	restore := snapstatetest.MockDeviceModel(DefaultModel())
+	defer restore()
+
+	logbuf, restoreLogger := logger.MockLogger()
+	defer restoreLogger()
+
+	// snap-c is left out of the auto-refresh until the held change
+	// is done
+	names, _, err := snapstate.AutoRefreshPhase1(context.TODO(), st, "")
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"snap-a"})
+	c.Check(logbuf.String(), testutil.Contains, `cannot refresh snap "snap-c": snap "snap-c" has "refresh-snap" change in progress`)
+}
+
 func (s *autorefreshGatingSuite) TestAutoRefreshPhase1NoHooks(c *C) {
 	s.store.refreshedSnaps = []*snap.Info{{
 		Architectures: []string{"all"},
@@ -2602,6 +2647,7 @@ func (s *autorefreshGatingSuite) TestAutoRefreshForGatingSnap(c *C) {
 	c.Assert(changes, HasLen, 1)
 	chg := changes[0]
 	c.Assert(chg.Kind(), Equals, "auto-refresh")
+	c.Check(chg.Priority(), Equals, state.LowPriority)
 	c.Check(chg.Summary(), Equals, `Auto-refresh snaps "base-snap-b", "snap-b"`)
 	var snapNames []string
 	var apiData map[string]interface{}
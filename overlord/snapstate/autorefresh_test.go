@@ -1119,6 +1119,7 @@ func (s *autoRefreshTestSuite) TestAutoRefreshCreatesBothRefreshAndPreDownload(c
 	checkPreDownloadChange(c, chgs[1], "foo", snap.R(8))
 
 	c.Assert(chgs[0].Kind(), Equals, "auto-refresh")
+	c.Check(chgs[0].Priority(), Equals, state.LowPriority)
 	var names []string
 	err = chgs[0].Get("snap-names", &names)
 	c.Assert(err, IsNil)
@@ -1127,6 +1128,7 @@ func (s *autoRefreshTestSuite) TestAutoRefreshCreatesBothRefreshAndPreDownload(c
 
 func checkPreDownloadChange(c *C, chg *state.Change, name string, rev snap.Revision) {
 	c.Assert(chg.Kind(), Equals, "pre-download")
+	c.Check(chg.Priority(), Equals, state.LowPriority)
 	c.Assert(chg.Summary(), Equals, fmt.Sprintf(`Pre-download "%s" for auto-refresh`, name))
 	c.Assert(chg.Tasks(), HasLen, 1)
 	task := chg.Tasks()[0]
@@ -115,6 +115,7 @@ var (
 	DefaultRefreshSchedule = defaultRefreshScheduleStr
 	DoInstall              = doInstall
 	UserFromUserID         = userFromUserID
+	DownloadArchitecture   = downloadArchitecture
 	ValidateFeatureFlags   = validateFeatureFlags
 	ResolveChannel         = resolveChannel
 
@@ -483,3 +484,9 @@ func MockCgroupMonitorSnapEnded(f func(string, chan<- string) error) func() {
 func SetRestoredMonitoring(snapmgr *SnapManager, value bool) {
 	snapmgr.autoRefresh.restoredMonitoring = value
 }
+
+func MockRefreshHealthCheckInterval(d time.Duration) (restore func()) {
+	r := testutil.Backup(&refreshHealthCheckInterval)
+	refreshHealthCheckInterval = d
+	return r
+}
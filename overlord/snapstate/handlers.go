@@ -686,7 +686,7 @@ func (m *SnapManager) undoPrepareSnap(t *state.Task, _ *tomb.Tomb) error {
 func installInfoUnlocked(st *state.State, snapsup *SnapSetup, deviceCtx DeviceContext) (store.SnapActionResult, error) {
 	st.Lock()
 	defer st.Unlock()
-	opts := &RevisionOptions{Channel: snapsup.Channel, CohortKey: snapsup.CohortKey, Revision: snapsup.Revision()}
+	opts := &RevisionOptions{Channel: snapsup.Channel, CohortKey: snapsup.CohortKey, Revision: snapsup.Revision(), Architectures: snapsup.Architectures}
 	return installInfo(context.TODO(), st, snapsup.InstanceName(), opts, snapsup.UserID, Flags{}, deviceCtx)
 }
 
@@ -755,6 +755,7 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 	dlOpts := &store.DownloadOptions{
 		IsAutoRefresh: snapsup.IsAutoRefresh,
 		RateLimit:     rate,
+		Architecture:  snapsup.DownloadArchitecture,
 	}
 	if snapsup.DownloadInfo == nil {
 		var storeInfo store.SnapActionResult
@@ -765,6 +766,7 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 		if err != nil {
 			return err
 		}
+		dlOpts.Architecture = downloadArchitecture(storeInfo.Info, snapsup.Architectures)
 		timings.Run(perfTimings, "download", fmt.Sprintf("download snap %q", snapsup.SnapName()), func(timings.Measurer) {
 			err = theStore.Download(tomb.Context(nil), snapsup.SnapName(), targetFn, &storeInfo.DownloadInfo, meter, user, dlOpts)
 		})
@@ -838,6 +840,7 @@ func (m *SnapManager) doPreDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 		// pre-downloads are only triggered in auto-refreshes
 		IsAutoRefresh: true,
 		RateLimit:     autoRefreshRateLimited(st),
+		Architecture:  snapsup.DownloadArchitecture,
 	}
 
 	perfTimings := state.TimingsForTask(t)
@@ -867,6 +870,18 @@ func (m *SnapManager) doPreDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 		return nil
 	}
 
+	// downloads of the same snap revision held back while this one ran
+	// will use the downloaded snap, so there's no need for a new change
+	downloadTasks, err := findTasksMatchingKindAndSnap(st, "download-snap", snapsup.InstanceName(), snapsup.Revision())
+	if err != nil {
+		return err
+	}
+	for _, dlTask := range downloadTasks {
+		if !dlTask.Status().Ready() {
+			return nil
+		}
+	}
+
 	_, snapst, err := snapSetupAndState(t)
 	if err != nil {
 		return err
@@ -1927,6 +1942,8 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) (err error) {
 	snapst.Classic = snapsup.Classic
 	oldCohortKey := snapst.CohortKey
 	snapst.CohortKey = snapsup.CohortKey
+	oldArchitectures := snapst.Architectures
+	snapst.Architectures = snapsup.Architectures
 	if snapsup.Required { // set only on install and left alone on refresh
 		snapst.Required = true
 	}
@@ -2074,6 +2091,7 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) (err error) {
 	t.Set("old-candidate-index", oldCandidateIndex)
 	t.Set("old-refresh-inhibited-time", oldRefreshInhibitedTime)
 	t.Set("old-cohort-key", oldCohortKey)
+	t.Set("old-architectures", oldArchitectures)
 	t.Set("old-last-refresh-time", oldLastRefreshTime)
 	t.Set("old-revs-before-cand", oldRevsBeforeCand)
 	if snapsup.Revert {
@@ -2486,6 +2504,10 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	if err := t.Get("old-cohort-key", &oldCohortKey); err != nil && !errors.Is(err, state.ErrNoState) {
 		return err
 	}
+	var oldArchitectures []string
+	if err := t.Get("old-architectures", &oldArchitectures); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
 	var oldRevsBeforeCand []snap.Revision
 	if err := t.Get("old-revs-before-cand", &oldRevsBeforeCand); err != nil && !errors.Is(err, state.ErrNoState) {
 		return err
@@ -2539,6 +2561,7 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	snapst.RefreshInhibitedTime = oldRefreshInhibitedTime
 	snapst.LastRefreshTime = oldLastRefreshTime
 	snapst.CohortKey = oldCohortKey
+	snapst.Architectures = oldArchitectures
 
 	if isRevert {
 		var oldRevertStatus map[int]RevertStatus
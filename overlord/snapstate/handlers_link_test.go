@@ -196,6 +196,78 @@ func (s *linkSnapSuite) TestDoLinkSnapSuccessWithCohort(c *C) {
 	c.Check(snapstate.AuxStoreInfoFilename("foo-id"), testutil.FilePresent)
 }
 
+func (s *linkSnapSuite) TestDoLinkSnapSuccessWithArchitectures(c *C) {
+	s.state.Lock()
+	t := s.state.NewTask("link-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			Revision: snap.R(33),
+			SnapID:   "foo-id",
+		},
+		Channel:       "beta",
+		Architectures: []string{"arm64", "armhf"},
+	})
+	s.state.NewChange("sample", "...").AddTask(t)
+
+	s.state.Unlock()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var snapst snapstate.SnapState
+	err := snapstate.Get(s.state, "foo", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(snapst.Architectures, DeepEquals, []string{"arm64", "armhf"})
+}
+
+func (s *linkSnapSuite) TestDoUndoLinkSnapRestoresArchitectures(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	si1 := &snap.SideInfo{
+		RealName: "foo",
+		Revision: snap.R(1),
+	}
+	si2 := &snap.SideInfo{
+		RealName: "foo",
+		Revision: snap.R(2),
+	}
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Sequence:      []*snap.SideInfo{si1},
+		Current:       si1.Revision,
+		Architectures: []string{"armhf"},
+	})
+	t := s.state.NewTask("link-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo:      si2,
+		Channel:       "beta",
+		Architectures: []string{"arm64"},
+	})
+	chg := s.state.NewChange("sample", "...")
+	chg.AddTask(t)
+
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitFor(t)
+	chg.AddTask(terr)
+
+	s.state.Unlock()
+
+	for i := 0; i < 6; i++ {
+		s.se.Ensure()
+		s.se.Wait()
+	}
+
+	s.state.Lock()
+	var snapst snapstate.SnapState
+	err := snapstate.Get(s.state, "foo", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(t.Status(), Equals, state.UndoneStatus)
+	c.Check(snapst.Architectures, DeepEquals, []string{"armhf"})
+}
+
 func (s *linkSnapSuite) TestDoLinkSnapSuccessNoUserID(c *C) {
 	s.state.Lock()
 	t := s.state.NewTask("link-snap", "test")
@@ -0,0 +1,251 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// PlannedSnap describes what a set of tasks would do to a snap.
+type PlannedSnap struct {
+	InstanceName string
+	// Action is one of "install", "refresh" or "remove".
+	Action   string
+	Revision snap.Revision
+	// CurrentRevision is the installed revision of a refreshed snap.
+	CurrentRevision snap.Revision
+	Channel         string
+	Type            snap.Type
+	// DownloadSize is the size of the snap to download, zero if it is
+	// not downloaded.
+	DownloadSize int64
+	// Info is the info of the installed or refreshed revision, as found
+	// from the store, the snap file or the revision already on disk. It
+	// is nil if it is not known before the snap is downloaded.
+	Info *snap.Info
+}
+
+// Plan describes what a set of tasks would do if they were run.
+type Plan struct {
+	Snaps []*PlannedSnap
+	// Prerequisites are the bases and default content providers that
+	// are not installed and would be installed along.
+	Prerequisites []string
+	// RestartedServices are the services of the refreshed snaps that
+	// would be restarted.
+	RestartedServices []string
+	// RebootRequired is set if the system would need to reboot.
+	RebootRequired bool
+	// DownloadSize is the total size of the snaps to download.
+	DownloadSize int64
+}
+
+// PlanTaskSets returns what the task sets, built but not added to a change,
+// would do if they were run. The info of the revisions they would install is
+// only known if they were built after StartPlanning or is already on disk. The prerequisites the tasks would install are
+// found from the snaps to install, as they are only resolved when running
+// the tasks.
+func PlanTaskSets(st *state.State, tss []*state.TaskSet) (*Plan, error) {
+	var snapNames []string
+	setups := make(map[string]*SnapSetup)
+	kinds := make(map[string][]string)
+	// the tasks of a snap refer to the task holding the setup of the
+	// snap, which is not accessible through the state until the tasks
+	// are added to a change
+	setupTasks := make(map[string]string)
+	for _, ts := range tss {
+		for _, t := range ts.Tasks() {
+			var snapsup SnapSetup
+			err := t.Get("snap-setup", &snapsup)
+			if err != nil && !errors.Is(err, state.ErrNoState) {
+				return nil, err
+			}
+			if err == nil {
+				name := snapsup.InstanceName()
+				if setups[name] == nil {
+					snapNames = append(snapNames, name)
+					setups[name] = &snapsup
+				}
+				setupTasks[t.ID()] = name
+			}
+		}
+	}
+	for _, ts := range tss {
+		for _, t := range ts.Tasks() {
+			name, ok := setupTasks[t.ID()]
+			if !ok {
+				var id string
+				if err := t.Get("snap-setup-task", &id); err != nil {
+					continue
+				}
+				if name, ok = setupTasks[id]; !ok {
+					continue
+				}
+			}
+			kinds[name] = append(kinds[name], t.Kind())
+		}
+	}
+
+	deviceCtx, err := DeviceCtxFromState(st, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	var prereqs []string
+	for _, name := range snapNames {
+		snapsup := setups[name]
+		var snapst SnapState
+		if err := Get(st, name, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+			return nil, err
+		}
+
+		planned := &PlannedSnap{
+			InstanceName: name,
+			Revision:     snapsup.Revision(),
+			Channel:      snapsup.Channel,
+			Type:         snapsup.Type,
+		}
+		switch {
+		case strutil.ListContains(kinds[name], "discard-snap"):
+			planned.Action = "remove"
+		case !strutil.ListContains(kinds[name], "link-snap") && !strutil.ListContains(kinds[name], "switch-snap-channel"):
+			// neither installed, refreshed nor removed
+			continue
+		case snapst.IsInstalled():
+			planned.Action = "refresh"
+			planned.CurrentRevision = snapst.Current
+		default:
+			planned.Action = "install"
+		}
+		if planned.Action != "remove" {
+			if snapsup.DownloadInfo != nil && strutil.ListContains(kinds[name], "download-snap") {
+				planned.DownloadSize = snapsup.DownloadInfo.Size
+				plan.DownloadSize += planned.DownloadSize
+			}
+			prereqs = append(prereqs, snapPrerequisites(snapsup)...)
+			planned.Info = plannedInfo(st, snapsup, &snapst)
+			if !boot.Participant(snap.MinimalPlaceInfo(name, planned.Revision), planned.Type, deviceCtx).IsTrivial() {
+				plan.RebootRequired = true
+			}
+		}
+		if planned.Action == "refresh" && snapst.Active {
+			info, err := snapst.CurrentInfo()
+			if err != nil {
+				return nil, err
+			}
+			for _, app := range info.Services() {
+				plan.RestartedServices = append(plan.RestartedServices, fmt.Sprintf("%s.%s", name, app.Name))
+			}
+		}
+		plan.Snaps = append(plan.Snaps, planned)
+	}
+
+	for _, name := range prereqs {
+		if setups[name] != nil || strutil.ListContains(plan.Prerequisites, name) {
+			continue
+		}
+		installed, err := isInstalled(st, name)
+		if err != nil {
+			return nil, err
+		}
+		if name == "core16" && !installed {
+			// the core snap provides everything needed for core16
+			installed, err = isInstalled(st, "core")
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !installed {
+			plan.Prerequisites = append(plan.Prerequisites, name)
+		}
+	}
+	sort.Strings(plan.Prerequisites)
+	sort.Strings(plan.RestartedServices)
+	return plan, nil
+}
+
+type planningKey struct{}
+
+// StartPlanning makes the task sets built until the returned function is
+// called keep the info of the revisions they would install, for PlanTaskSets
+// to use, as only the setup of the snaps is saved with the tasks. It must
+// only be used for task sets that are planned and discarded, not added to a
+// change.
+func StartPlanning(st *state.State) (stop func()) {
+	st.Cache(planningKey{}, make(map[string]*snap.Info))
+	return func() {
+		st.Cache(planningKey{}, nil)
+	}
+}
+
+// keepPlannedInfo keeps the info of the revision that tasks built for a snap
+// would install, if the tasks are being built for planning.
+func keepPlannedInfo(st *state.State, info *snap.Info) {
+	if infos, ok := st.Cached(planningKey{}).(map[string]*snap.Info); ok {
+		infos[info.InstanceName()] = info
+	}
+}
+
+// plannedInfo returns the info of the revision the tasks built with the setup
+// would install, or nil if it is not known.
+func plannedInfo(st *state.State, snapsup *SnapSetup, snapst *SnapState) *snap.Info {
+	if infos, ok := st.Cached(planningKey{}).(map[string]*snap.Info); ok {
+		if info := infos[snapsup.InstanceName()]; info != nil && info.Revision == snapsup.Revision() {
+			return info
+		}
+	}
+	// the revision is already on disk
+	if i := snapst.LastIndex(snapsup.Revision()); i >= 0 {
+		info, err := readInfo(snapsup.InstanceName(), snapst.Sequence[i], errorOnBroken)
+		if err != nil {
+			logger.Noticef("cannot read info of %q revision %s to plan its tasks: %v", snapsup.InstanceName(), snapsup.Revision(), err)
+			return nil
+		}
+		return info
+	}
+	return nil
+}
+
+// snapPrerequisites returns the base and the default content providers the
+// snap needs, as installed by the prerequisites task.
+func snapPrerequisites(snapsup *SnapSetup) []string {
+	switch snapsup.Type {
+	case snap.TypeOS, snap.TypeBase, snap.TypeKernel, snap.TypeGadget, snap.TypeSnapd:
+		return nil
+	}
+	base := defaultCoreSnapName
+	if snapsup.Base != "" {
+		base = snapsup.Base
+	}
+	var prereqs []string
+	if base != "none" {
+		prereqs = append(prereqs, base)
+	}
+	return append(prereqs, snapsup.Prereq...)
+}
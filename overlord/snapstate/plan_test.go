@@ -0,0 +1,180 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) TestPlanTaskSets(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "services-snap", SnapID: "services-snap-id", Revision: snap.R(7)},
+		},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", Revision: snap.R(11)},
+		},
+		Current:  snap.R(11),
+		SnapType: "app",
+	})
+
+	defer snapstate.StartPlanning(s.state)()
+
+	installTs, err := snapstate.Install(context.Background(), s.state, "some-snap", &snapstate.RevisionOptions{Channel: "channel-for-base/stable"}, 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	refreshTs, err := snapstate.Update(s.state, "services-snap", &snapstate.RevisionOptions{Channel: "stable"}, 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	removeTs, err := snapstate.Remove(s.state, "foo", snap.R(0), nil)
+	c.Assert(err, IsNil)
+
+	plan, err := snapstate.PlanTaskSets(s.state, []*state.TaskSet{installTs, refreshTs, removeTs})
+	c.Assert(err, IsNil)
+	c.Assert(plan.Snaps, HasLen, 3)
+	// the info of the new revisions is the one from the store
+	for _, planned := range plan.Snaps[:2] {
+		c.Assert(planned.Info, NotNil)
+		c.Check(planned.Info.InstanceName(), Equals, planned.InstanceName)
+		c.Check(planned.Info.Revision, Equals, planned.Revision)
+		planned.Info = nil
+	}
+	c.Check(plan.Snaps, DeepEquals, []*snapstate.PlannedSnap{{
+		InstanceName: "some-snap",
+		Action:       "install",
+		Revision:     snap.R(11),
+		Channel:      "channel-for-base/stable",
+		Type:         snap.TypeApp,
+		DownloadSize: 5,
+	}, {
+		InstanceName:    "services-snap",
+		Action:          "refresh",
+		Revision:        snap.R(11),
+		CurrentRevision: snap.R(7),
+		Channel:         "stable",
+		Type:            snap.TypeApp,
+	}, {
+		InstanceName: "foo",
+		Action:       "remove",
+		Revision:     snap.R(11),
+		Type:         snap.TypeApp,
+	}})
+	// core is installed by the test setup
+	c.Check(plan.Prerequisites, DeepEquals, []string{"some-base"})
+	c.Check(plan.RestartedServices, DeepEquals, []string{"services-snap.svc1", "services-snap.svc2", "services-snap.svc3"})
+	c.Check(plan.RebootRequired, Equals, false)
+	c.Check(plan.DownloadSize, Equals, int64(5))
+}
+
+func (s *snapmgrTestSuite) TestPlanTaskSetsInfoOnlyKeptWhilePlanning(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// task sets built for a real change do not keep the info
+	ts, err := snapstate.Install(context.Background(), s.state, "some-snap", nil, 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	plan, err := snapstate.PlanTaskSets(s.state, []*state.TaskSet{ts})
+	c.Assert(err, IsNil)
+	c.Assert(plan.Snaps, HasLen, 1)
+	c.Check(plan.Snaps[0].Info, IsNil)
+	s.state.DiscardTasks(ts.Tasks())
+
+	stop := snapstate.StartPlanning(s.state)
+	ts, err = snapstate.Install(context.Background(), s.state, "some-snap", nil, 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	plan, err = snapstate.PlanTaskSets(s.state, []*state.TaskSet{ts})
+	c.Assert(err, IsNil)
+	c.Assert(plan.Snaps, HasLen, 1)
+	c.Assert(plan.Snaps[0].Info, NotNil)
+	c.Check(plan.Snaps[0].Info.Revision, Equals, snap.R(11))
+
+	// and the info is dropped once planning is done
+	stop()
+	plan, err = snapstate.PlanTaskSets(s.state, []*state.TaskSet{ts})
+	c.Assert(err, IsNil)
+	c.Check(plan.Snaps[0].Info, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestPlanTaskSetsKernelNeedsReboot(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	r := snapstatetest.MockDeviceModel(DefaultModel())
+	defer r()
+
+	snapstate.Set(s.state, "kernel", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "kernel", SnapID: "kernel-id", Revision: snap.R(7)},
+		},
+		Current:  snap.R(7),
+		SnapType: "kernel",
+	})
+
+	ts, err := snapstate.Update(s.state, "kernel", &snapstate.RevisionOptions{Channel: "stable"}, 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	plan, err := snapstate.PlanTaskSets(s.state, []*state.TaskSet{ts})
+	c.Assert(err, IsNil)
+	c.Assert(plan.Snaps, HasLen, 1)
+	c.Check(plan.Snaps[0].Action, Equals, "refresh")
+	c.Check(plan.Snaps[0].CurrentRevision, Equals, snap.R(7))
+	c.Check(plan.Prerequisites, HasLen, 0)
+	c.Check(plan.RebootRequired, Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestPlanTaskSetsInfoOfRevisionOnDisk(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si7 := &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}
+	si11 := &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(11)}
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si7, si11},
+		Current:  snap.R(11),
+		SnapType: "app",
+	})
+
+	ts, err := snapstate.Revert(s.state, "some-snap", snapstate.Flags{}, "")
+	c.Assert(err, IsNil)
+
+	plan, err := snapstate.PlanTaskSets(s.state, []*state.TaskSet{ts})
+	c.Assert(err, IsNil)
+	c.Assert(plan.Snaps, HasLen, 1)
+	info := plan.Snaps[0].Info
+	c.Assert(info, NotNil)
+	c.Check(info.InstanceName(), Equals, "some-snap")
+	c.Check(info.Revision, Equals, snap.R(7))
+}
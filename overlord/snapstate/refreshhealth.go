@@ -0,0 +1,193 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// refreshHealthCheckInterval is how often the health of a refreshed snap is
+// checked during the grace period after the refresh.
+var refreshHealthCheckInterval = 10 * time.Second
+
+// SnapHealthError returns an error describing the problem if the given
+// revision of the snap reported an error health, or nil otherwise.
+var SnapHealthError = func(st *state.State, snapName string, rev snap.Revision) error {
+	panic("internal error: snapstate.SnapHealthError is unset")
+}
+
+// refreshHealthGracePeriod returns the period after a refresh during which
+// the snap is reverted if it reports an error health, as configured by
+// refresh.health-grace-period. Zero means that the health of refreshed
+// snaps is not monitored.
+func refreshHealthGracePeriod(st *state.State) (time.Duration, error) {
+	var gracePeriodStr string
+	if err := config.NewTransaction(st).GetMaybe("core", "refresh.health-grace-period", &gracePeriodStr); err != nil {
+		return 0, err
+	}
+	if gracePeriodStr == "" {
+		return 0, nil
+	}
+	gracePeriod, err := time.ParseDuration(gracePeriodStr)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse refresh.health-grace-period: %v", err)
+	}
+	return gracePeriod, nil
+}
+
+// checkRefreshHealthTask returns a task starting to monitor the health of the
+// snap after a refresh, or nil if refresh.health-grace-period is not set. Only
+// application snaps are monitored, reverting other types of snaps may
+// require a reboot.
+func checkRefreshHealthTask(st *state.State, snapsup *SnapSetup) (*state.Task, error) {
+	if snapsup.Type != snap.TypeApp {
+		return nil, nil
+	}
+	gracePeriod, err := refreshHealthGracePeriod(st)
+	if err != nil || gracePeriod <= 0 {
+		return nil, err
+	}
+	t := st.NewTask("check-refresh-health", fmt.Sprintf(i18n.G("Monitor health of snap %q (%s) for %s after refresh"), snapsup.InstanceName(), snapsup.Revision(), gracePeriod))
+	t.Set("grace-period", gracePeriod)
+	return t, nil
+}
+
+// refreshHealthWatch is a refreshed snap whose health is monitored.
+type refreshHealthWatch struct {
+	Revision snap.Revision `json:"revision"`
+	Until    time.Time     `json:"until"`
+}
+
+func refreshHealthWatches(st *state.State) (map[string]*refreshHealthWatch, error) {
+	var watches map[string]*refreshHealthWatch
+	if err := st.Get("refresh-health-watches", &watches); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	return watches, nil
+}
+
+// doCheckRefreshHealth starts monitoring the health of the refreshed snap
+// for the grace period. The monitoring happens outside of the refresh
+// change, so that the snap can be operated on meanwhile, see
+// ensureRefreshHealth.
+func (m *SnapManager) doCheckRefreshHealth(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, err := TaskSnapSetup(t)
+	if err != nil {
+		return err
+	}
+	var gracePeriod time.Duration
+	if err := t.Get("grace-period", &gracePeriod); err != nil {
+		return err
+	}
+	watches, err := refreshHealthWatches(st)
+	if err != nil {
+		return err
+	}
+	if watches == nil {
+		watches = make(map[string]*refreshHealthWatch)
+	}
+	watches[snapsup.InstanceName()] = &refreshHealthWatch{
+		Revision: snapsup.Revision(),
+		Until:    timeNow().Add(gracePeriod),
+	}
+	st.Set("refresh-health-watches", watches)
+	st.EnsureBefore(0)
+	return nil
+}
+
+// ensureRefreshHealth reverts the refreshed snaps monitored by
+// doCheckRefreshHealth that report an error health within their grace
+// period. The revert blocks the revision, so that it is not refreshed to
+// again.
+func (m *SnapManager) ensureRefreshHealth() error {
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	watches, err := refreshHealthWatches(st)
+	if err != nil || len(watches) == 0 {
+		return err
+	}
+
+	now := timeNow()
+	next := refreshHealthCheckInterval
+	for snapName, watch := range watches {
+		var snapst SnapState
+		if err := Get(st, snapName, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+			return err
+		}
+		if !snapst.IsInstalled() || snapst.Current != watch.Revision {
+			// removed, reverted or refreshed again meanwhile
+			delete(watches, snapName)
+			continue
+		}
+
+		if healthErr := SnapHealthError(st, snapName, watch.Revision); healthErr != nil {
+			ts, err := Revert(st, snapName, Flags{}, "")
+			if errors.Is(err, &ChangeConflictError{}) {
+				// try again once the other change is done
+				logger.Debugf("cannot revert snap %q reporting an error health yet: %v", snapName, err)
+				continue
+			}
+			delete(watches, snapName)
+			if err != nil {
+				st.Warnf("snap %q reported an error health after the refresh to revision %s but cannot be reverted: %v", snapName, watch.Revision, err)
+				continue
+			}
+			st.Warnf("snap %q reported an error health after the refresh to revision %s and is being reverted to its previous revision: %v", snapName, watch.Revision, healthErr)
+			chg := st.NewChange("revert-snap", fmt.Sprintf(i18n.G("Revert %q snap reporting an error health after refresh"), snapName))
+			chg.AddAll(ts)
+			chg.Set("snap-names", []string{snapName})
+			continue
+		}
+
+		left := watch.Until.Sub(now)
+		if left <= 0 {
+			logger.Noticef("Snap %q remained healthy after the refresh to revision %s.", snapName, watch.Revision)
+			delete(watches, snapName)
+			continue
+		}
+		if left < next {
+			next = left
+		}
+	}
+
+	if len(watches) == 0 {
+		st.Set("refresh-health-watches", nil)
+		return nil
+	}
+	st.Set("refresh-health-watches", watches)
+	st.EnsureBefore(next)
+	return nil
+}
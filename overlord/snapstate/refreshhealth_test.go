@@ -0,0 +1,278 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type refreshHealthSuite struct {
+	snapmgrBaseTest
+
+	now          time.Time
+	healthErrors map[snap.Revision]error
+}
+
+var _ = Suite(&refreshHealthSuite{})
+
+func (s *refreshHealthSuite) SetUpTest(c *C) {
+	s.snapmgrBaseTest.SetUpTest(c)
+
+	// every check of the health happens a minute later
+	s.now = time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.AddCleanup(snapstate.MockTimeNow(func() time.Time {
+		s.now = s.now.Add(time.Minute)
+		return s.now
+	}))
+	s.AddCleanup(snapstate.MockRefreshHealthCheckInterval(time.Millisecond))
+
+	s.healthErrors = make(map[snap.Revision]error)
+	s.AddCleanup(testutil.Backup(&snapstate.SnapHealthError))
+	snapstate.SnapHealthError = func(st *state.State, snapName string, rev snap.Revision) error {
+		c.Check(snapName, Equals, "some-snap")
+		return s.healthErrors[rev]
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{{
+			RealName: "some-snap",
+			SnapID:   "some-snap-id",
+			Revision: snap.R(7),
+		}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+}
+
+func (s *refreshHealthSuite) setGracePeriod(c *C, gracePeriod string) {
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.health-grace-period", gracePeriod), IsNil)
+	tr.Commit()
+}
+
+func (s *refreshHealthSuite) refresh(c *C) *state.Change {
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.Update(s.state, "some-snap", &snapstate.RevisionOptions{Channel: "some-channel"}, s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+	return chg
+}
+
+func findTask(chg *state.Change, kind string) *state.Task {
+	for _, t := range chg.Tasks() {
+		if t.Kind() == kind {
+			return t
+		}
+	}
+	return nil
+}
+
+func (s *refreshHealthSuite) TestRefreshWithoutGracePeriod(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.refresh(c)
+	c.Check(findTask(chg, "check-refresh-health"), IsNil)
+}
+
+func (s *refreshHealthSuite) TestRefreshHealthCheckTask(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setGracePeriod(c, "5m")
+	chg := s.refresh(c)
+	t := findTask(chg, "check-refresh-health")
+	c.Assert(t, NotNil)
+	c.Check(t.Summary(), Equals, `Monitor health of snap "some-snap" (11) for 5m0s after refresh`)
+	c.Assert(t.WaitTasks(), HasLen, 1)
+	c.Check(t.WaitTasks()[0].Kind(), Equals, "run-hook")
+
+	snapsup, err := snapstate.TaskSnapSetup(t)
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Revision(), Equals, snap.R(11))
+}
+
+func changeOfKind(st *state.State, kind string) *state.Change {
+	for _, chg := range st.Changes() {
+		if chg.Kind() == kind {
+			return chg
+		}
+	}
+	return nil
+}
+
+func (s *refreshHealthSuite) TestRefreshHealthyKeepsRevision(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setGracePeriod(c, "5m")
+	// the previous revision reporting an error does not matter
+	s.healthErrors[snap.R(7)] = errors.New("broken")
+	chg := s.refresh(c)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+	c.Check(changeOfKind(s.state, "revert-snap"), IsNil)
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Current, Equals, snap.R(11))
+	c.Check(s.state.AllWarnings(), HasLen, 0)
+
+	// the snap is not monitored anymore once the grace period is over
+	s.healthErrors[snap.R(11)] = errors.New("broken")
+	s.settle(c)
+	c.Check(changeOfKind(s.state, "revert-snap"), IsNil)
+	c.Check(s.state.AllWarnings(), HasLen, 0)
+}
+
+func (s *refreshHealthSuite) TestRefreshErrorHealthReverts(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setGracePeriod(c, "5m")
+	s.healthErrors[snap.R(11)] = errors.New("cannot connect to database")
+	chg := s.refresh(c)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	// the refresh is done, the snap is reverted by a change of its own
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+	revertChg := changeOfKind(s.state, "revert-snap")
+	c.Assert(revertChg, NotNil)
+	c.Check(revertChg.Summary(), Equals, `Revert "some-snap" snap reporting an error health after refresh`)
+	c.Assert(revertChg.Status(), Equals, state.DoneStatus, Commentf("%v", revertChg.Err()))
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Active, Equals, true)
+	c.Check(snapst.Current, Equals, snap.R(7))
+	// the reverted revision is not refreshed to again
+	c.Check(snapst.Block(), DeepEquals, []snap.Revision{snap.R(11)})
+
+	warns := s.state.AllWarnings()
+	c.Assert(warns, HasLen, 1)
+	c.Check(warns[0].String(), Equals, `snap "some-snap" reported an error health after the refresh to revision 11 and is being reverted to its previous revision: cannot connect to database`)
+}
+
+func (s *refreshHealthSuite) TestRefreshHealthMonitoredOutsideOfChange(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setGracePeriod(c, "1h")
+	defer snapstate.MockRefreshHealthCheckInterval(time.Hour)()
+	chg := s.refresh(c)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	// the refresh change does not stay open for the grace period, so the
+	// snap can be operated on meanwhile
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+	c.Check(snapstate.CheckChangeConflict(s.state, "some-snap", nil), IsNil)
+
+	// the health is still monitored
+	s.healthErrors[snap.R(11)] = errors.New("broken")
+	s.state.Unlock()
+	err := s.snapmgr.Ensure()
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	c.Check(changeOfKind(s.state, "revert-snap"), NotNil)
+}
+
+func (s *refreshHealthSuite) TestRefreshHealthWaitsForConflictingChange(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setGracePeriod(c, "1h")
+	defer snapstate.MockRefreshHealthCheckInterval(time.Hour)()
+	chg := s.refresh(c)
+
+	defer s.se.Stop()
+	s.settle(c)
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+
+	// another change operates on the snap
+	other := s.state.NewChange("other", "...")
+	t := s.state.NewTask("foo", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: "some-snap", Revision: snap.R(11)},
+	})
+	other.AddTask(t)
+
+	s.healthErrors[snap.R(11)] = errors.New("broken")
+	s.state.Unlock()
+	err := s.snapmgr.Ensure()
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	c.Check(changeOfKind(s.state, "revert-snap"), IsNil)
+
+	// the snap is reverted once the other change is done
+	t.SetStatus(state.DoneStatus)
+	s.state.Unlock()
+	err = s.snapmgr.Ensure()
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	c.Check(changeOfKind(s.state, "revert-snap"), NotNil)
+}
+
+func (s *refreshHealthSuite) TestRefreshHealthNotMonitoredAfterManualRevert(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setGracePeriod(c, "1h")
+	defer snapstate.MockRefreshHealthCheckInterval(time.Hour)()
+	chg := s.refresh(c)
+
+	defer s.se.Stop()
+	s.settle(c)
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+
+	revert := s.state.NewChange("revert-snap", "...")
+	ts, err := snapstate.Revert(s.state, "some-snap", snapstate.Flags{}, "")
+	c.Assert(err, IsNil)
+	revert.AddAll(ts)
+	s.settle(c)
+	c.Assert(revert.Status(), Equals, state.DoneStatus, Commentf("%v", revert.Err()))
+
+	// the reverted to revision is not monitored
+	s.healthErrors[snap.R(7)] = errors.New("broken")
+	s.state.Unlock()
+	err = s.snapmgr.Ensure()
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	c.Check(s.state.Changes(), HasLen, 2)
+	c.Check(s.state.AllWarnings(), HasLen, 0)
+}
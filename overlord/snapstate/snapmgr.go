@@ -85,6 +85,13 @@ type SnapSetup struct {
 
 	CohortKey string `json:"cohort-key,omitempty"`
 
+	// Architectures lists the acceptable architectures of the snap in
+	// order of preference, if other than just the device architecture.
+	Architectures []string `json:"architectures,omitempty"`
+	// DownloadArchitecture is the architecture of the chosen snap build
+	// out of Architectures, it is passed on to the store on download.
+	DownloadArchitecture string `json:"download-architecture,omitempty"`
+
 	// FIXME: implement rename of this as suggested in
 	//  https://github.com/snapcore/snapd/pull/4103#discussion_r169569717
 	//
@@ -138,6 +145,11 @@ type SnapSetup struct {
 
 	// DisabledExposedHome is set if ~/Snap should not be used as $HOME.
 	DisableExposedHome bool `json:"disable-exposed-home,omitempty"`
+
+	// info is the info of the revision to install, as found from the
+	// store or the snap file. It is not saved with the tasks and is only
+	// used to plan them, see StartPlanning.
+	info *snap.Info
 }
 
 func (snapsup *SnapSetup) InstanceName() string {
@@ -222,6 +234,11 @@ type SnapState struct {
 	InstanceKey string `json:"instance-key,omitempty"`
 	CohortKey   string `json:"cohort-key,omitempty"`
 
+	// Architectures lists the acceptable architectures of the snap in
+	// order of preference as requested on install, they are used for
+	// refreshes too.
+	Architectures []string `json:"architectures,omitempty"`
+
 	// RefreshInhibitedime records the time when the refresh was first
 	// attempted but inhibited because the snap was busy. This value is
 	// reset on each successful refresh.
@@ -520,6 +537,7 @@ func Manager(st *state.State, runner *state.TaskRunner) (*SnapManager, error) {
 	runner.AddHandler("switch-snap-channel", m.doSwitchSnapChannel, nil)
 	runner.AddHandler("toggle-snap-flags", m.doToggleSnapFlags, nil)
 	runner.AddHandler("check-rerefresh", m.doCheckReRefresh, nil)
+	runner.AddHandler("check-refresh-health", m.doCheckRefreshHealth, nil)
 	runner.AddHandler("conditional-auto-refresh", m.doConditionalAutoRefresh, nil)
 
 	// FIXME: drop the task entirely after a while
@@ -554,6 +572,10 @@ func Manager(st *state.State, runner *state.TaskRunner) (*SnapManager, error) {
 
 	// control serialisation
 	runner.AddBlocked(m.blockedTask)
+	// download one snap at a time, so that a download for a change of a
+	// higher priority is not slowed down by the ones of background work,
+	// including auto-refresh pre-downloads, which it preempts instead
+	runner.SetConcurrencyLimit(1, "download-snap", "pre-download-snap")
 
 	RegisterAffectedSnapsByKind("conditional-auto-refresh", conditionalAutoRefreshAffectedSnaps)
 
@@ -611,9 +633,34 @@ func (m *SnapManager) blockedTask(cand *state.Task, running []*state.Task) bool
 		}
 	}
 
+	// A download waits for a running pre-download of the same snap
+	// revision instead of preempting it, as it would only wait for it
+	// again once the pre-download is retried.
+	if cand.Kind() == "download-snap" {
+		for _, t := range running {
+			if t.Kind() == "pre-download-snap" && sameSnapRevision(cand, t) {
+				return true
+			}
+		}
+	}
+
 	return false
 }
 
+// sameSnapRevision returns whether both tasks are about the same revision
+// of the same snap instance.
+func sameSnapRevision(t1, t2 *state.Task) bool {
+	snapsup1, err := TaskSnapSetup(t1)
+	if err != nil {
+		return false
+	}
+	snapsup2, err := TaskSnapSetup(t2)
+	if err != nil {
+		return false
+	}
+	return snapsup1.InstanceName() == snapsup2.InstanceName() && snapsup1.Revision() == snapsup2.Revision()
+}
+
 // NextRefresh returns the time the next update of the system's snaps
 // will be attempted.
 // The caller should be holding the state lock.
@@ -1154,6 +1201,7 @@ func (m *SnapManager) Ensure() error {
 		m.localInstallCleanup(),
 		m.ensureVulnerableSnapConfineVersionsRemovedOnClassic(),
 		m.ensureMountsUpdated(),
+		m.ensureRefreshHealth(),
 	}
 
 	//FIXME: use firstErr helper
@@ -149,26 +149,34 @@ func (ins installSnapInfo) SnapSetupForUpdate(st *state.State, params updatePara
 		return nil, nil, err
 	}
 
+	architectures, err := preferredArchitectures(st, revnoOpts.Architectures)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	providerContentAttrs := defaultProviderContentAttrs(st, update)
 	snapsup := SnapSetup{
-		Base:               update.Base,
-		Prereq:             getKeys(providerContentAttrs),
-		PrereqContentAttrs: providerContentAttrs,
-		Channel:            revnoOpts.Channel,
-		CohortKey:          revnoOpts.CohortKey,
-		UserID:             snapUserID,
-		Flags:              flags.ForSnapSetup(),
-		DownloadInfo:       &update.DownloadInfo,
-		SideInfo:           &update.SideInfo,
-		Type:               update.Type(),
-		PlugsOnly:          len(update.Slots) == 0,
-		InstanceKey:        update.InstanceKey,
+		Base:                 update.Base,
+		Prereq:               getKeys(providerContentAttrs),
+		PrereqContentAttrs:   providerContentAttrs,
+		Channel:              revnoOpts.Channel,
+		CohortKey:            revnoOpts.CohortKey,
+		Architectures:        revnoOpts.Architectures,
+		DownloadArchitecture: downloadArchitecture(update, architectures),
+		UserID:               snapUserID,
+		Flags:                flags.ForSnapSetup(),
+		DownloadInfo:         &update.DownloadInfo,
+		SideInfo:             &update.SideInfo,
+		Type:                 update.Type(),
+		PlugsOnly:            len(update.Slots) == 0,
+		InstanceKey:          update.InstanceKey,
 		auxStoreInfo: auxStoreInfo{
 			Media: update.Media,
 			// XXX we store this for the benefit of old snapd
 			Website: update.Website(),
 		},
 		ExpectedProvenance: update.SnapProvenance,
+		info:               update,
 	}
 	snapsup.IgnoreRunning = globalFlags.IgnoreRunning
 	return &snapsup, snapst, nil
@@ -210,6 +218,7 @@ func (i pathInfo) SnapSetupForUpdate(st *state.State, params updateParamsFunc, _
 		Type:               i.Type(),
 		PlugsOnly:          len(i.Slots) == 0,
 		InstanceKey:        i.InstanceKey,
+		info:               update,
 	}
 	return &snapsup, snapst, nil
 }
@@ -341,6 +350,9 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	// NB: we should strive not to need or propagate deviceCtx
 	// here, the resulting effects/changes were not pleasant at
 	// one point
+	if snapsup.info != nil {
+		keepPlannedInfo(st, snapsup.info)
+	}
 	tr := config.NewTransaction(st)
 	experimentalRefreshAppAwareness, err := features.Flag(tr, features.RefreshAppAwareness)
 	if err != nil && !config.IsNoOption(err) {
@@ -716,6 +728,20 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	healthCheck.WaitAll(ts)
 	ts.AddTask(healthCheck)
 
+	if runRefreshHooks {
+		// revert the snap if it reports an error health within the
+		// grace period
+		refreshHealth, err := checkRefreshHealthTask(st, snapsup)
+		if err != nil {
+			return nil, err
+		}
+		if refreshHealth != nil {
+			refreshHealth.Set("snap-setup-task", prepare.ID())
+			refreshHealth.WaitFor(healthCheck)
+			ts.AddTask(refreshHealth)
+		}
+	}
+
 	return ts, nil
 }
 
@@ -1176,6 +1202,7 @@ func InstallPath(st *state.State, si *snap.SideInfo, path, instanceName, channel
 		Type:               info.Type(),
 		PlugsOnly:          len(info.Slots) == 0,
 		InstanceKey:        info.InstanceKey,
+		info:               info,
 	}
 
 	ts, err := doInstall(st, &snapst, snapsup, instFlags, "", inUseFor(deviceCtx))
@@ -1261,6 +1288,11 @@ func InstallWithDeviceContext(ctx context.Context, st *state.State, name string,
 		return nil, err
 	}
 
+	architectures, err := preferredArchitectures(st, opts.Architectures)
+	if err != nil {
+		return nil, err
+	}
+
 	providerContentAttrs := defaultProviderContentAttrs(st, info)
 	snapsup := &SnapSetup{
 		Channel:            opts.Channel,
@@ -1279,8 +1311,11 @@ func InstallWithDeviceContext(ctx context.Context, st *state.State, name string,
 			// XXX we store this for the benefit of old snapd
 			Website: info.Website(),
 		},
-		CohortKey:          opts.CohortKey,
-		ExpectedProvenance: info.SnapProvenance,
+		CohortKey:            opts.CohortKey,
+		Architectures:        opts.Architectures,
+		DownloadArchitecture: downloadArchitecture(info, architectures),
+		ExpectedProvenance:   info.SnapProvenance,
+		info:                 info,
 	}
 
 	if sar.RedirectChannel != "" {
@@ -1379,10 +1414,18 @@ func InstallMany(st *state.State, names []string, revOpts []*RevisionOptions, us
 		return nil, nil, err
 	}
 
-	names = strutil.Deduplicate(names)
-
 	toInstall := make([]string, 0, len(names))
-	for _, name := range names {
+	revOptsByName := make(map[string]*RevisionOptions, len(names))
+	var toInstallRevOpts []*RevisionOptions
+	for i, name := range names {
+		if _, ok := revOptsByName[name]; ok {
+			continue
+		}
+		revOptsByName[name] = &RevisionOptions{}
+		if revOpts != nil && revOpts[i] != nil {
+			revOptsByName[name] = revOpts[i]
+		}
+
 		var snapst SnapState
 		err := Get(st, name, &snapst)
 		if err != nil && !errors.Is(err, state.ErrNoState) {
@@ -1397,6 +1440,9 @@ func InstallMany(st *state.State, names []string, revOpts []*RevisionOptions, us
 		}
 
 		toInstall = append(toInstall, name)
+		if revOpts != nil {
+			toInstallRevOpts = append(toInstallRevOpts, revOptsByName[name])
+		}
 	}
 
 	user, err := userFromUserID(st, userID)
@@ -1404,7 +1450,7 @@ func InstallMany(st *state.State, names []string, revOpts []*RevisionOptions, us
 		return nil, nil, err
 	}
 
-	installs, err := installCandidates(st, toInstall, revOpts, "stable", user)
+	installs, err := installCandidates(st, toInstall, toInstallRevOpts, "stable", user)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -1447,20 +1493,29 @@ func InstallMany(st *state.State, names []string, revOpts []*RevisionOptions, us
 			channel = sar.RedirectChannel
 		}
 
+		revOpt := revOptsByName[info.InstanceName()]
+		architectures, err := preferredArchitectures(st, revOpt.Architectures)
+		if err != nil {
+			return nil, nil, err
+		}
+
 		providerContentAttrs := defaultProviderContentAttrs(st, info)
 		snapsup := &SnapSetup{
-			Channel:            channel,
-			Base:               info.Base,
-			Prereq:             getKeys(providerContentAttrs),
-			PrereqContentAttrs: providerContentAttrs,
-			UserID:             userID,
-			Flags:              validatedFlags.ForSnapSetup(),
-			DownloadInfo:       &info.DownloadInfo,
-			SideInfo:           &info.SideInfo,
-			Type:               info.Type(),
-			PlugsOnly:          len(info.Slots) == 0,
-			InstanceKey:        info.InstanceKey,
-			ExpectedProvenance: info.SnapProvenance,
+			Channel:              channel,
+			Base:                 info.Base,
+			Prereq:               getKeys(providerContentAttrs),
+			PrereqContentAttrs:   providerContentAttrs,
+			UserID:               userID,
+			Flags:                validatedFlags.ForSnapSetup(),
+			DownloadInfo:         &info.DownloadInfo,
+			SideInfo:             &info.SideInfo,
+			Type:                 info.Type(),
+			PlugsOnly:            len(info.Slots) == 0,
+			InstanceKey:          info.InstanceKey,
+			ExpectedProvenance:   info.SnapProvenance,
+			Architectures:        revOpt.Architectures,
+			DownloadArchitecture: downloadArchitecture(info, architectures),
+			info:                 info,
 		}
 
 		ts, err := doInstall(st, &snapst, snapsup, 0, "", inUseFor(deviceCtx))
@@ -1686,6 +1741,13 @@ func updateManyFiltered(ctx context.Context, st *state.State, names []string, re
 		return nil, nil, err
 	}
 
+	architecturesByName := make(map[string][]string, len(revOpts))
+	for i, opts := range revOpts {
+		if opts != nil && len(opts.Architectures) > 0 {
+			architecturesByName[names[i]] = opts.Architectures
+		}
+	}
+
 	names = strutil.Deduplicate(names)
 
 	refreshOpts := &store.RefreshOptions{IsAutoRefresh: flags.IsAutoRefresh}
@@ -1718,10 +1780,15 @@ func updateManyFiltered(ctx context.Context, st *state.State, names []string, re
 
 	params := func(update *snap.Info) (*RevisionOptions, Flags, *SnapState) {
 		snapst := stateByInstanceName[update.InstanceName()]
-		// setting options to what's in state as multi-refresh doesn't let you change these
+		// setting options to what's in state as multi-refresh doesn't let
+		// you change these, except for the architectures
 		opts := &RevisionOptions{
-			Channel:   snapst.TrackingChannel,
-			CohortKey: snapst.CohortKey,
+			Channel:       snapst.TrackingChannel,
+			CohortKey:     snapst.CohortKey,
+			Architectures: snapst.Architectures,
+		}
+		if architectures, ok := architecturesByName[update.InstanceName()]; ok {
+			opts.Architectures = architectures
 		}
 		return opts, snapst.Flags, snapst
 	}
@@ -2288,6 +2355,9 @@ type RevisionOptions struct {
 	ValidationSets []snapasserts.ValidationSetKey
 	CohortKey      string
 	LeaveCohort    bool
+	// Architectures lists the acceptable architectures of the snap in
+	// order of preference, the device architecture is used if empty.
+	Architectures []string
 }
 
 // Update initiates a change updating a snap.
@@ -2350,6 +2420,10 @@ func UpdateWithDeviceContext(st *state.State, name string, opts *RevisionOptions
 	if opts.LeaveCohort {
 		opts.CohortKey = ""
 	}
+	if len(opts.Architectures) == 0 {
+		// default to the architectures the snap was installed with
+		opts.Architectures = snapst.Architectures
+	}
 
 	// TODO: make flags be per revision to avoid this logic (that
 	//       leaves corner cases all over the place)
@@ -3225,6 +3299,10 @@ func canRemove(st *state.State, si *snap.Info, snapst *SnapState, removeAll bool
 type RemoveFlags struct {
 	// Remove the snap without creating snapshot data
 	Purge bool
+	// Only build the tasks to find out what the removal would do; the
+	// automatic snapshot, which allocates a snapshot set ID in the
+	// state, is left out
+	DryRun bool
 }
 
 // Remove returns a set of tasks for removing snap.
@@ -3358,7 +3436,7 @@ func removeTasks(st *state.State, name string, revision snap.Revision, flags *Re
 	}
 
 	// 'purge' flag disables automatic snapshot for given remove op
-	if flags == nil || !flags.Purge {
+	if flags == nil || !(flags.Purge || flags.DryRun) {
 		if tp, _ := snapst.Type(); tp == snap.TypeApp && removeAll {
 			ts, err := AutomaticSnapshot(st, name)
 			if err == nil {
@@ -3572,6 +3650,8 @@ func RevertToRevision(st *state.State, name string, rev snap.Revision, flags Fla
 		Type:        info.Type(),
 		PlugsOnly:   len(info.Slots) == 0,
 		InstanceKey: snapst.InstanceKey,
+		// keep refreshing for the same architectures
+		Architectures: snapst.Architectures,
 	}
 	return doInstall(st, &snapst, snapsup, 0, fromChange, nil)
 }
@@ -307,6 +307,84 @@ func (s *snapmgrTestSuite) TestInstallCohortTasks(c *C) {
 	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
 }
 
+func (s *snapmgrTestSuite) TestInstallWithArchitectures(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	opts := &snapstate.RevisionOptions{Channel: "some-channel", Architectures: []string{"arm64", "armhf"}}
+	ts, err := snapstate.Install(context.Background(), s.state, "some-snap", opts, 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	snapsup, err := snapstate.TaskSnapSetup(ts.Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Architectures, DeepEquals, []string{"arm64", "armhf"})
+
+	op := s.fakeBackend.ops.First("storesvc-snap-action:action")
+	c.Assert(op, NotNil)
+	c.Check(op.action.Architectures, DeepEquals, []string{"arm64", "armhf"})
+}
+
+func (s *snapmgrTestSuite) TestInstallWithConfiguredArchitectures(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "store.architectures", "arm64,armhf")
+	tr.Commit()
+
+	ts, err := snapstate.Install(context.Background(), s.state, "some-snap", &snapstate.RevisionOptions{Channel: "some-channel"}, 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	snapsup, err := snapstate.TaskSnapSetup(ts.Tasks()[0])
+	c.Assert(err, IsNil)
+	// the configured architectures are not kept with the snap
+	c.Check(snapsup.Architectures, IsNil)
+	c.Check(snapsup.DownloadArchitecture, Equals, "arm64")
+
+	op := s.fakeBackend.ops.First("storesvc-snap-action:action")
+	c.Assert(op, NotNil)
+	c.Check(op.action.Architectures, DeepEquals, []string{"arm64", "armhf"})
+}
+
+func (s *snapmgrTestSuite) TestInstallWithArchitecturesRunThrough(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "install a snap")
+	opts := &snapstate.RevisionOptions{Channel: "some-channel", Architectures: []string{"arm64", "armhf"}}
+	ts, err := snapstate.Install(context.Background(), s.state, "some-snap", opts, s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), IsNil)
+	// the snap is built for all architectures, the most preferred one
+	// is used for the download
+	c.Check(s.fakeStore.downloads, DeepEquals, []fakeDownload{{
+		macaroon: s.user.StoreMacaroon,
+		name:     "some-snap",
+		target:   filepath.Join(dirs.SnapBlobDir, "some-snap_11.snap"),
+		opts:     &store.DownloadOptions{Architecture: "arm64"},
+	}})
+}
+
+func (s *snapmgrTestSuite) TestDownloadArchitecture(c *C) {
+	for _, t := range []struct {
+		snapArchitectures []string
+		architectures     []string
+		expected          string
+	}{
+		{[]string{"all"}, nil, ""},
+		{[]string{"armhf"}, nil, ""},
+		{[]string{"all"}, []string{"arm64", "armhf"}, "arm64"},
+		{[]string{"armhf"}, []string{"arm64", "armhf"}, "armhf"},
+		{[]string{"armhf", "arm64"}, []string{"arm64", "armhf"}, "arm64"},
+	} {
+		info := &snap.Info{Architectures: t.snapArchitectures}
+		c.Check(snapstate.DownloadArchitecture(info, t.architectures), Equals, t.expected, Commentf("%v %v", t.snapArchitectures, t.architectures))
+	}
+}
+
 func (s *snapmgrTestSuite) TestInstallWithDeviceContext(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -3498,6 +3576,47 @@ func (s *snapmgrTestSuite) TestInstallMany(c *C) {
 	}
 }
 
+func (s *snapmgrTestSuite) TestInstallManyWithArchitectures(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "store.architectures", "arm64")
+	tr.Commit()
+
+	revOpts := []*snapstate.RevisionOptions{
+		{Architectures: []string{"armhf"}},
+		{},
+	}
+	installed, tts, err := snapstate.InstallMany(s.state, []string{"one", "two"}, revOpts, 0, nil)
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 2)
+	c.Check(installed, DeepEquals, []string{"one", "two"})
+
+	architectures := make(map[string][]string)
+	for _, op := range s.fakeBackend.ops {
+		if op.op == "storesvc-snap-action:action" {
+			architectures[op.action.InstanceName] = op.action.Architectures
+		}
+	}
+	c.Check(architectures, DeepEquals, map[string][]string{
+		"one": {"armhf"},
+		"two": {"arm64"},
+	})
+
+	snapsup, err := snapstate.TaskSnapSetup(tts[0].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Architectures, DeepEquals, []string{"armhf"})
+	c.Check(snapsup.DownloadArchitecture, Equals, "armhf")
+	snapsup, err = snapstate.TaskSnapSetup(tts[1].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Architectures, IsNil)
+	c.Check(snapsup.DownloadArchitecture, Equals, "arm64")
+
+	// only revisions for validation sets skip the enforced validation sets
+	c.Check(s.fakeBackend.ops.First("storesvc-snap-action:action").action.ValidationSets, IsNil)
+}
+
 func (s *snapmgrTestSuite) TestInstallManyDevMode(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -4046,6 +4046,7 @@ func (s *snapmgrTestSuite) TestEnsureRefreshesWithUpdate(c *C) {
 	c.Assert(s.state.Changes(), HasLen, 1)
 	chg := s.state.Changes()[0]
 	c.Check(chg.Kind(), Equals, "auto-refresh")
+	c.Check(chg.Priority(), Equals, state.LowPriority)
 	c.Check(chg.IsReady(), Equals, false)
 	s.verifyRefreshLast(c)
 
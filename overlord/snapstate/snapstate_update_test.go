@@ -2759,6 +2759,108 @@ func (s *snapmgrTestSuite) TestUpdateNoStoreResultsWithChannelChange(c *C) {
 	c.Assert(err, Equals, snapstate.ErrMissingExpectedResult)
 }
 
+func (s *snapmgrTestSuite) TestUpdateKeepsArchitectures(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:          true,
+		TrackingChannel: "latest/stable",
+		Sequence:        []*snap.SideInfo{si},
+		Current:         si.Revision,
+		SnapType:        "app",
+		Architectures:   []string{"arm64", "armhf"},
+	})
+
+	ts, err := snapstate.Update(s.state, "some-snap", nil, 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	snapsup, err := snapstate.TaskSnapSetup(ts.Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Architectures, DeepEquals, []string{"arm64", "armhf"})
+
+	op := s.fakeBackend.ops.First("storesvc-snap-action:action")
+	c.Assert(op, NotNil)
+	c.Check(op.action.Architectures, DeepEquals, []string{"arm64", "armhf"})
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyKeepsArchitectures(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:          true,
+		TrackingChannel: "latest/stable",
+		Sequence:        []*snap.SideInfo{si},
+		Current:         si.Revision,
+		SnapType:        "app",
+		Architectures:   []string{"armhf"},
+	})
+
+	names, tss, err := snapstate.UpdateMany(context.Background(), s.state, nil, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"some-snap"})
+
+	op := s.fakeBackend.ops.First("storesvc-snap-action:action")
+	c.Assert(op, NotNil)
+	c.Check(op.action.Architectures, DeepEquals, []string{"armhf"})
+
+	snapsup, err := snapstate.TaskSnapSetup(tss[0].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Architectures, DeepEquals, []string{"armhf"})
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyWithArchitectures(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "store.architectures", "arm64,armhf")
+	tr.Commit()
+
+	for _, name := range []string{"some-snap", "some-other-snap"} {
+		si := &snap.SideInfo{RealName: name, SnapID: name + "-id", Revision: snap.R(7)}
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active:          true,
+			TrackingChannel: "latest/stable",
+			Sequence:        []*snap.SideInfo{si},
+			Current:         si.Revision,
+			SnapType:        "app",
+		})
+	}
+
+	revOpts := []*snapstate.RevisionOptions{{Architectures: []string{"armhf"}}, {}}
+	names, tss, err := snapstate.UpdateMany(context.Background(), s.state, []string{"some-snap", "some-other-snap"}, revOpts, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(names, testutil.DeepUnsortedMatches, []string{"some-snap", "some-other-snap"})
+
+	architectures := make(map[string][]string)
+	for _, op := range s.fakeBackend.ops {
+		if op.op == "storesvc-snap-action:action" {
+			architectures[op.action.InstanceName] = op.action.Architectures
+		}
+	}
+	// the configured architectures are used unless others are asked for
+	c.Check(architectures, DeepEquals, map[string][]string{
+		"some-snap":       {"armhf"},
+		"some-other-snap": {"arm64", "armhf"},
+	})
+
+	for _, ts := range tss {
+		snapsup, err := snapstate.TaskSnapSetup(ts.Tasks()[0])
+		if err != nil {
+			continue
+		}
+		switch snapsup.InstanceName() {
+		case "some-snap":
+			c.Check(snapsup.Architectures, DeepEquals, []string{"armhf"})
+		case "some-other-snap":
+			c.Check(snapsup.Architectures, IsNil)
+		}
+	}
+}
+
 func (s *snapmgrTestSuite) TestUpdateSameRevisionSwitchesChannel(c *C) {
 	si := snap.SideInfo{
 		RealName: "some-snap",
@@ -9284,28 +9386,16 @@ func (s *snapmgrTestSuite) TestDownloadTaskWaitsForPreDownload(c *C) {
 
 			c.Assert(s.o.TaskRunner().Ensure(), IsNil)
 
-			for i := 0; i < 5; i++ {
-				<-time.After(time.Second)
-				s.state.Lock()
-				atTime := dlTask.AtTime()
-				s.state.Unlock()
-				if atTime.IsZero() {
-					continue
-				}
-
-				s.state.Lock()
-				defer s.state.Unlock()
-
-				// the download task registers itself w/ the pre-download and retries
-				c.Assert(atTime.Equal(now.Add(2*time.Minute)), Equals, true)
-				var taskIDs []string
-				c.Assert(preDlTask.Get("waiting-tasks", &taskIDs), IsNil)
-				c.Assert(taskIDs, DeepEquals, []string{dlTask.ID()})
-				return
-			}
-
-			c.Fatal("download task hasn't run")
+			// the download task waits for the pre-download instead
+			// of preempting it
+			s.state.Lock()
+			defer s.state.Unlock()
+			c.Check(dlTask.Status(), Equals, state.DoStatus)
+			return
 		case 2:
+			s.state.Lock()
+			defer s.state.Unlock()
+			c.Check(preDlTask.Status(), Equals, state.DoneStatus)
 			return
 		default:
 			c.Fatal("only expected 2 calls to the store")
@@ -9321,6 +9411,96 @@ func (s *snapmgrTestSuite) TestDownloadTaskWaitsForPreDownload(c *C) {
 	c.Check(monitored, Equals, false)
 }
 
+func (s *snapmgrTestSuite) TestDownloadTaskPreemptsPreDownload(c *C) {
+	now := time.Now()
+	restore := state.MockTime(now)
+	defer restore()
+
+	restore = snapstate.MockRefreshAppsCheck(func(info *snap.Info) error {
+		return nil
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	si := &snap.SideInfo{
+		RealName: "foo",
+		SnapID:   "foo-id",
+		Revision: snap.R(1),
+	}
+	snaptest.MockSnap(c, `name: foo`, si)
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+
+	preDlChg := s.state.NewChange("pre-download", "pre-download change")
+	preDlChg.SetPriority(state.LowPriority)
+	preDlTask := s.state.NewTask("pre-download-snap", "pre-download task")
+	preDlTask.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			Revision: snap.R(2),
+		},
+		Flags: snapstate.Flags{IsAutoRefresh: true},
+	})
+	preDlTask.Set("refresh-info", &userclient.PendingSnapRefreshInfo{InstanceName: "foo"})
+	preDlChg.AddTask(preDlTask)
+
+	dlChg := s.state.NewChange("install", "install change")
+	dlTask := s.state.NewTask("download-snap", "download task")
+	dlTask.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "bar",
+			Revision: snap.R(3),
+		},
+	})
+	// wait until the pre-download is running
+	dlTask.At(now.Add(time.Hour))
+	dlChg.AddTask(dlTask)
+
+	var downloadCalls int
+	s.fakeStore.downloadCallback = func() {
+		downloadCalls++
+
+		s.state.Lock()
+		defer s.state.Unlock()
+		switch downloadCalls {
+		case 1:
+			// the pre-download is interrupted once preempted
+			s.fakeStore.downloadError = map[string]error{"foo": errors.New("download interrupted")}
+			// schedule download to run while pre-download is running
+			dlTask.At(time.Time{})
+
+			s.state.Unlock()
+			c.Assert(s.o.TaskRunner().Ensure(), IsNil)
+			s.state.Lock()
+
+			// the download waits for the pre-download to stop
+			c.Check(dlTask.Status(), Equals, state.DoStatus)
+		case 2:
+			// the preempted pre-download is retried afterwards
+			c.Check(preDlTask.Status(), Equals, state.DoingStatus)
+			delete(s.fakeStore.downloadError, "foo")
+		case 3:
+			c.Check(dlTask.Status(), Equals, state.DoneStatus)
+		default:
+			c.Fatal("only expected 3 calls to the store")
+		}
+	}
+
+	s.settle(c)
+
+	c.Assert(downloadCalls, Equals, 3)
+	c.Assert(s.fakeStore.downloads, HasLen, 3)
+	c.Check(s.fakeStore.downloads[0].name, Equals, "foo")
+	c.Check(s.fakeStore.downloads[1].name, Equals, "bar")
+	c.Check(s.fakeStore.downloads[2].name, Equals, "foo")
+	c.Check(dlTask.Status(), Equals, state.DoneStatus)
+	c.Check(preDlTask.Status(), Equals, state.DoneStatus)
+}
+
 func (s *snapmgrTestSuite) TestPreDownloadTaskTriggersAutoRefreshIfSoftCheckOk(c *C) {
 	var softChecked bool
 	restore := snapstate.MockRefreshAppsCheck(func(info *snap.Info) error {
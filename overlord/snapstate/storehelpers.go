@@ -29,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/naming"
@@ -230,6 +231,57 @@ func setActionValidationSetsAndRequiredRevision(action *store.SnapAction, valset
 	}
 }
 
+// downloadArchitecture returns the first of the acceptable architectures
+// the snap was built for, or "" if the store architecture is to be used.
+func downloadArchitecture(info *snap.Info, architectures []string) string {
+	if info == nil || len(architectures) == 0 {
+		return ""
+	}
+	for _, a := range architectures {
+		if strutil.ListContains(info.Architectures, a) {
+			return a
+		}
+	}
+	// built for "all" or for none of them (the store checked the latter)
+	return architectures[0]
+}
+
+// forValidationSets returns whether the revision options select a revision
+// to enforce validation sets, which are then not checked against the other
+// enforced ones.
+func (opts *RevisionOptions) forValidationSets() bool {
+	return opts != nil && (!opts.Revision.Unset() || len(opts.ValidationSets) > 0)
+}
+
+// forValidationSets returns whether any of the revision options select a
+// revision to enforce validation sets.
+func forValidationSets(revOpts []*RevisionOptions) bool {
+	for _, opts := range revOpts {
+		if opts.forValidationSets() {
+			return true
+		}
+	}
+	return false
+}
+
+// preferredArchitectures returns the acceptable architectures of a snap in
+// order of preference: the given ones, or else the ones set with the
+// store.architectures core option. If neither are set the store picks builds
+// for the device architecture.
+func preferredArchitectures(st *state.State, architectures []string) ([]string, error) {
+	if len(architectures) > 0 {
+		return architectures, nil
+	}
+	var value string
+	if err := config.NewTransaction(st).Get("core", "store.architectures", &value); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	if value == "" {
+		return nil, nil
+	}
+	return strutil.CommaSeparatedList(value), nil
+}
+
 func installInfo(ctx context.Context, st *state.State, name string, revOpts *RevisionOptions, userID int, flags Flags, deviceCtx DeviceContext) (store.SnapActionResult, error) {
 	curSnaps, err := currentSnaps(st)
 	if err != nil {
@@ -246,9 +298,15 @@ func installInfo(ctx context.Context, st *state.State, name string, revOpts *Rev
 		return store.SnapActionResult{}, err
 	}
 
+	architectures, err := preferredArchitectures(st, revOpts.Architectures)
+	if err != nil {
+		return store.SnapActionResult{}, err
+	}
+
 	action := &store.SnapAction{
-		Action:       "install",
-		InstanceName: name,
+		Action:        "install",
+		InstanceName:  name,
+		Architectures: architectures,
 	}
 
 	if flags.IgnoreValidation {
@@ -340,13 +398,19 @@ func updateInfo(st *state.State, snapst *SnapState, opts *RevisionOptions, userI
 		storeFlags = store.SnapActionEnforceValidation
 	}
 
+	architectures, err := preferredArchitectures(st, opts.Architectures)
+	if err != nil {
+		return nil, err
+	}
+
 	action := &store.SnapAction{
 		Action:       "refresh",
 		InstanceName: curInfo.InstanceName(),
 		SnapID:       curInfo.SnapID,
 		// the desired channel
-		Channel: opts.Channel,
-		Flags:   storeFlags,
+		Channel:       opts.Channel,
+		Flags:         storeFlags,
+		Architectures: architectures,
 	}
 
 	if len(opts.ValidationSets) > 0 {
@@ -480,12 +544,18 @@ func updateToRevisionInfo(st *state.State, snapst *SnapState, revOpts *RevisionO
 		return nil, err
 	}
 
+	architectures, err := preferredArchitectures(st, revOpts.Architectures)
+	if err != nil {
+		return nil, err
+	}
+
 	action := &store.SnapAction{
 		Action:       "refresh",
 		SnapID:       curInfo.SnapID,
 		InstanceName: curInfo.InstanceName(),
 		// the desired revision
-		Revision: revOpts.Revision,
+		Revision:      revOpts.Revision,
+		Architectures: architectures,
 	}
 
 	var requiredRevision snap.Revision
@@ -620,6 +690,11 @@ func refreshCandidates(ctx context.Context, st *state.State, names []string, rev
 		}
 	}
 
+	revOptsByName := make(map[string]*RevisionOptions, len(revOpts))
+	for i, opts := range revOpts {
+		revOptsByName[names[i]] = opts
+	}
+
 	sort.Strings(names)
 
 	var fallbackID int
@@ -636,20 +711,19 @@ func refreshCandidates(ctx context.Context, st *state.State, names []string, rev
 	nCands := 0
 
 	var enforcedSets *snapasserts.ValidationSets
-	var revOptsByName map[string]*RevisionOptions
 
 	// if refreshing to specific revision to enforce a new validation set, we've
 	// already checked against other enforced sets
-	if revOpts == nil {
+	if !forValidationSets(revOpts) {
 		enforcedSets, err = EnforcedValidationSets(st)
 		if err != nil {
 			return nil, nil, nil, err
 		}
-	} else {
-		revOptsByName = make(map[string]*RevisionOptions, len(revOpts))
-		for i, opts := range revOpts {
-			revOptsByName[names[i]] = opts
-		}
+	}
+
+	configuredArchitectures, err := preferredArchitectures(st, nil)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
 	addCand := func(installed *store.CurrentSnap, snapst *SnapState) error {
@@ -668,19 +742,28 @@ func refreshCandidates(ctx context.Context, st *state.State, names []string, rev
 			return nil
 		}
 
+		revOpts := revOptsByName[installed.InstanceName]
+		architectures := snapst.Architectures
+		if revOpts != nil && len(revOpts.Architectures) > 0 {
+			architectures = revOpts.Architectures
+		}
+		if len(architectures) == 0 {
+			architectures = configuredArchitectures
+		}
+
 		action := &store.SnapAction{
-			Action:       "refresh",
-			SnapID:       installed.SnapID,
-			InstanceName: installed.InstanceName,
+			Action:        "refresh",
+			SnapID:        installed.SnapID,
+			InstanceName:  installed.InstanceName,
+			Architectures: architectures,
 		}
 
 		if !snapst.IgnoreValidation {
 			var requiredValsets []snapasserts.ValidationSetKey
 			var requiredRevision snap.Revision
 
-			if revOpts != nil {
-				opts := revOptsByName[installed.InstanceName]
-				requiredValsets, requiredRevision = opts.ValidationSets, opts.Revision
+			if revOpts.forValidationSets() {
+				requiredValsets, requiredRevision = revOpts.ValidationSets, revOpts.Revision
 			} else if enforcedSets != nil {
 				requiredValsets, requiredRevision, err = enforcedSets.CheckPresenceRequired(naming.Snap(installed.InstanceName))
 				// note, this errors out the entire refresh
@@ -795,7 +878,7 @@ func installCandidates(st *state.State, names []string, revOpts []*RevisionOptio
 	// if installing a specific revision, we may be trying to enforce a validation
 	// set so don't check against current ones.
 	var enforcedSets *snapasserts.ValidationSets
-	if revOpts == nil {
+	if !forValidationSets(revOpts) {
 		enforcedSets, err = EnforcedValidationSets(st)
 		if err != nil {
 			return nil, err
@@ -804,19 +887,30 @@ func installCandidates(st *state.State, names []string, revOpts []*RevisionOptio
 
 	actions := make([]*store.SnapAction, len(names))
 	for i, name := range names {
+		revOpt := &RevisionOptions{}
+		if revOpts != nil {
+			revOpt = revOpts[i]
+		}
+
+		architectures, err := preferredArchitectures(st, revOpt.Architectures)
+		if err != nil {
+			return nil, err
+		}
+
 		action := &store.SnapAction{
 			Action:       "install",
 			InstanceName: name,
 			// the desired channel
-			Channel: channel,
+			Channel:       channel,
+			Architectures: architectures,
 		}
 
 		var requiredValSets []snapasserts.ValidationSetKey
 		var requiredRevision snap.Revision
 
-		if revOpts != nil {
-			requiredValSets = revOpts[i].ValidationSets
-			requiredRevision = revOpts[i].Revision
+		if revOpt.forValidationSets() {
+			requiredValSets = revOpt.ValidationSets
+			requiredRevision = revOpt.Revision
 		} else if enforcedSets != nil {
 			// check for invalid presence first to have a list of sets where it's invalid
 			invalidForValSets, err := enforcedSets.CheckPresenceInvalid(naming.Snap(name))
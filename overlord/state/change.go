@@ -114,6 +114,44 @@ func (s Status) String() string {
 	panic(fmt.Sprintf("internal error: unknown task status code: %d", s))
 }
 
+// Priority is the priority of a change. The tasks of changes with a higher
+// priority are started first by the TaskRunner, and may preempt running tasks
+// of changes with a lower priority.
+type Priority int
+
+// Admitted priority values for changes.
+const (
+	// LowPriority is for background work, such as auto-refreshes.
+	LowPriority Priority = -1
+	// NormalPriority is the default priority of changes.
+	NormalPriority Priority = 0
+	// HighPriority is for changes a user is waiting for.
+	HighPriority Priority = 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case LowPriority:
+		return "low"
+	case NormalPriority:
+		return "normal"
+	case HighPriority:
+		return "high"
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
+// ParsePriority returns the priority with the given name, one of "low",
+// "normal" or "high".
+func ParsePriority(name string) (Priority, error) {
+	for _, p := range []Priority{LowPriority, NormalPriority, HighPriority} {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	return NormalPriority, fmt.Errorf("invalid change priority %q, expected one of: low, normal, high", name)
+}
+
 // Change represents a tracked modification to the system state.
 //
 // The Change provides both the justification for individual tasks
@@ -135,6 +173,9 @@ type Change struct {
 	taskIDs []string
 	ready   chan struct{}
 
+	priority  Priority
+	notBefore time.Time
+
 	spawnTime time.Time
 	readyTime time.Time
 }
@@ -167,6 +208,9 @@ type marshalledChange struct {
 	Data    map[string]*json.RawMessage `json:"data,omitempty"`
 	TaskIDs []string                    `json:"task-ids,omitempty"`
 
+	Priority  Priority   `json:"priority,omitempty"`
+	NotBefore *time.Time `json:"not-before,omitempty"`
+
 	SpawnTime time.Time  `json:"spawn-time"`
 	ReadyTime *time.Time `json:"ready-time,omitempty"`
 }
@@ -178,6 +222,10 @@ func (c *Change) MarshalJSON() ([]byte, error) {
 	if !c.readyTime.IsZero() {
 		readyTime = &c.readyTime
 	}
+	var notBefore *time.Time
+	if !c.notBefore.IsZero() {
+		notBefore = &c.notBefore
+	}
 	return json.Marshal(marshalledChange{
 		ID:      c.id,
 		Kind:    c.kind,
@@ -187,6 +235,9 @@ func (c *Change) MarshalJSON() ([]byte, error) {
 		Data:    c.data,
 		TaskIDs: c.taskIDs,
 
+		Priority:  c.priority,
+		NotBefore: notBefore,
+
 		SpawnTime: c.spawnTime,
 		ReadyTime: readyTime,
 	})
@@ -214,6 +265,10 @@ func (c *Change) UnmarshalJSON(data []byte) error {
 	c.data = custData
 	c.taskIDs = unmarshalled.TaskIDs
 	c.ready = make(chan struct{})
+	c.priority = unmarshalled.Priority
+	if unmarshalled.NotBefore != nil {
+		c.notBefore = *unmarshalled.NotBefore
+	}
 	c.spawnTime = unmarshalled.SpawnTime
 	if unmarshalled.ReadyTime != nil {
 		c.readyTime = *unmarshalled.ReadyTime
@@ -243,6 +298,39 @@ func (c *Change) Summary() string {
 	return c.summary
 }
 
+// Priority returns the priority of the change.
+func (c *Change) Priority() Priority {
+	c.state.reading()
+	return c.priority
+}
+
+// SetPriority sets the priority of the change.
+func (c *Change) SetPriority(p Priority) {
+	c.state.writing()
+	c.priority = p
+}
+
+// NotBefore returns the time before which the change is held, or the zero
+// time if it was not scheduled.
+func (c *Change) NotBefore() time.Time {
+	c.state.reading()
+	return c.notBefore
+}
+
+// SetNotBefore holds the change until the given time, by making the
+// TaskRunner not start any of its tasks before then. It must be called after
+// all the tasks were added to the change. The held change is not ready, so
+// its tasks are still considered by conflict checks until it is done.
+func (c *Change) SetNotBefore(t time.Time) {
+	c.state.writing()
+	c.notBefore = t
+	for _, tid := range c.taskIDs {
+		if task := c.state.tasks[tid]; task != nil && task.Status() == DoStatus {
+			task.At(t)
+		}
+	}
+}
+
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (c *Change) Set(key string, value interface{}) {
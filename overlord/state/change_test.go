@@ -20,6 +20,7 @@
 package state_test
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"sort"
@@ -74,6 +75,69 @@ func (cs *changeSuite) TestStatusString(c *C) {
 	}
 }
 
+func (cs *changeSuite) TestPriority(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "...")
+	c.Check(chg.Priority(), Equals, state.NormalPriority)
+	chg.SetPriority(state.HighPriority)
+	c.Check(chg.Priority(), Equals, state.HighPriority)
+
+	data, err := chg.MarshalJSON()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Matches, `.*"priority":1.*`)
+
+	data, err = st.MarshalJSON()
+	c.Assert(err, IsNil)
+	st2, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	st2.Lock()
+	defer st2.Unlock()
+	c.Check(st2.Change(chg.ID()).Priority(), Equals, state.HighPriority)
+}
+
+func (cs *changeSuite) TestParsePriority(c *C) {
+	for _, p := range []state.Priority{state.LowPriority, state.NormalPriority, state.HighPriority} {
+		parsed, err := state.ParsePriority(p.String())
+		c.Assert(err, IsNil)
+		c.Check(parsed, Equals, p)
+	}
+	_, err := state.ParsePriority("urgent")
+	c.Check(err, ErrorMatches, `invalid change priority "urgent", expected one of: low, normal, high`)
+}
+
+func (cs *changeSuite) TestNotBefore(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "1...")
+	t2 := st.NewTask("link", "2...")
+	t2.SetStatus(state.DoneStatus)
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	c.Check(chg.NotBefore().IsZero(), Equals, true)
+
+	notBefore := time.Now().Add(time.Hour)
+	chg.SetNotBefore(notBefore)
+	c.Check(chg.NotBefore().Equal(notBefore), Equals, true)
+	c.Check(t1.AtTime().Equal(notBefore), Equals, true)
+	// tasks that already ran are left alone
+	c.Check(t2.AtTime().IsZero(), Equals, true)
+
+	data, err := st.MarshalJSON()
+	c.Assert(err, IsNil)
+	st2, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	st2.Lock()
+	defer st2.Unlock()
+	c.Check(st2.Change(chg.ID()).NotBefore().Equal(notBefore), Equals, true)
+	c.Check(st2.Task(t1.ID()).AtTime().Equal(notBefore), Equals, true)
+}
+
 func (cs *changeSuite) TestGetSet(c *C) {
 	st := state.New(nil)
 	st.Lock()
@@ -73,3 +73,15 @@ var (
 	ErrNoWarningExpireAfter = errNoWarningExpireAfter
 	ErrNoWarningRepeatAfter = errNoWarningRepeatAfter
 )
+
+func MockMaxNotices(n int) (restore func()) {
+	old := maxNotices
+	maxNotices = n
+	return func() {
+		maxNotices = old
+	}
+}
+
+func MockNoticeOccurred(n *Notice, occurred time.Time) {
+	n.occurred = occurred
+}
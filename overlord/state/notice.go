@@ -0,0 +1,330 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	// NoticeExpireAfter is how long notices are kept after they occurred.
+	NoticeExpireAfter = time.Hour * 24 * 7
+	// maxNotices is the maximum number of notices kept, the oldest are
+	// dropped first.
+	maxNotices = 1000
+)
+
+// NoticeType is the type of event recorded by a notice.
+type NoticeType string
+
+const (
+	// SnapConfigNotice records a change to the configuration of a snap. The
+	// notice key is the snap's instance name.
+	SnapConfigNotice NoticeType = "snap-config"
+	// AspectNotice records a change to the data of an aspect bundle. The
+	// notice key is "<account>/<bundle>".
+	AspectNotice NoticeType = "aspect"
+)
+
+func (t NoticeType) valid() bool {
+	switch t {
+	case SnapConfigNotice, AspectNotice:
+		return true
+	}
+	return false
+}
+
+type jsonNotice struct {
+	ID          string     `json:"id"`
+	Type        NoticeType `json:"type"`
+	Key         string     `json:"key"`
+	Occurred    time.Time  `json:"occurred"`
+	ChangeID    string     `json:"change-id,omitempty"`
+	ChangedKeys []string   `json:"changed-keys,omitempty"`
+}
+
+// Notice records an event, such as a configuration change, that clients can
+// be notified about.
+type Notice struct {
+	// id is a sequence number, notices are ordered by it
+	id         int
+	noticeType NoticeType
+	// key identifies what the notice is about, e.g., a snap name
+	key      string
+	occurred time.Time
+	// changeID is the ID of the change that caused the event, if any
+	changeID string
+	// changedKeys are the keys affected by the event, e.g., the
+	// configuration options that changed
+	changedKeys []string
+}
+
+// ID returns the unique identifier of the notice.
+func (n *Notice) ID() string {
+	return strconv.Itoa(n.id)
+}
+
+// Type returns the type of the notice.
+func (n *Notice) Type() NoticeType {
+	return n.noticeType
+}
+
+// Key returns what the notice is about, its meaning depends on the type.
+func (n *Notice) Key() string {
+	return n.key
+}
+
+// Occurred returns the time the event happened.
+func (n *Notice) Occurred() time.Time {
+	return n.occurred
+}
+
+// ChangeID returns the ID of the change that caused the event, if any.
+func (n *Notice) ChangeID() string {
+	return n.changeID
+}
+
+// ChangedKeys returns the keys affected by the event.
+func (n *Notice) ChangedKeys() []string {
+	return n.changedKeys
+}
+
+func (n *Notice) String() string {
+	return fmt.Sprintf("Notice %d (%s:%s)", n.id, n.noticeType, n.key)
+}
+
+func (n *Notice) expiredBefore(t time.Time) bool {
+	return n.occurred.Add(NoticeExpireAfter).Before(t)
+}
+
+func (n *Notice) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonNotice{
+		ID:          n.ID(),
+		Type:        n.noticeType,
+		Key:         n.key,
+		Occurred:    n.occurred,
+		ChangeID:    n.changeID,
+		ChangedKeys: n.changedKeys,
+	})
+}
+
+func (n *Notice) UnmarshalJSON(data []byte) error {
+	var jn jsonNotice
+	if err := json.Unmarshal(data, &jn); err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(jn.ID)
+	if err != nil {
+		return fmt.Errorf("invalid notice ID %q", jn.ID)
+	}
+
+	n.id = id
+	n.noticeType = jn.Type
+	n.key = jn.Key
+	n.occurred = jn.Occurred
+	n.changeID = jn.ChangeID
+	n.changedKeys = jn.ChangedKeys
+	return nil
+}
+
+// AddNoticeOptions holds optional details of a notice.
+type AddNoticeOptions struct {
+	// ChangeID is the ID of the change that caused the event.
+	ChangeID string
+	// ChangedKeys are the keys affected by the event.
+	ChangedKeys []string
+}
+
+// AddNotice records a notice of the given type and key, waking up anyone
+// waiting for it in WaitNotices. It returns the new notice's ID.
+func (s *State) AddNotice(noticeType NoticeType, key string, opts *AddNoticeOptions) string {
+	s.writing()
+
+	if !noticeType.valid() || key == "" {
+		// programming error!
+		logger.Panicf("internal error, please report: attempted to add invalid notice (type %q, key %q)", noticeType, key)
+	}
+	if opts == nil {
+		opts = &AddNoticeOptions{}
+	}
+
+	s.lastNoticeId++
+	notice := &Notice{
+		id:          s.lastNoticeId,
+		noticeType:  noticeType,
+		key:         key,
+		occurred:    time.Now().UTC(),
+		changeID:    opts.ChangeID,
+		changedKeys: opts.ChangedKeys,
+	}
+	s.notices = append(s.notices, notice)
+	if len(s.notices) > maxNotices {
+		s.notices = s.notices[len(s.notices)-maxNotices:]
+	}
+
+	// wake up the waiters
+	if s.noticesAdded != nil {
+		close(s.noticesAdded)
+		s.noticesAdded = nil
+	}
+
+	return notice.ID()
+}
+
+// NoticeFilter selects notices. A nil filter or zero fields match everything.
+type NoticeFilter struct {
+	// Types selects notices of any of these types.
+	Types []NoticeType
+	// Keys selects notices with any of these keys.
+	Keys []string
+	// After selects notices added after the notice with this ID.
+	After string
+}
+
+func (f *NoticeFilter) matches(n *Notice, after int) bool {
+	if n.id <= after {
+		return false
+	}
+	if f == nil {
+		return true
+	}
+	if len(f.Types) > 0 && !noticeTypeIn(n.noticeType, f.Types) {
+		return false
+	}
+	if len(f.Keys) > 0 && !strutil.ListContains(f.Keys, n.key) {
+		return false
+	}
+	return true
+}
+
+func noticeTypeIn(typ NoticeType, types []NoticeType) bool {
+	for _, t := range types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Notices returns the unexpired notices that match the filter, ordered by ID.
+func (s *State) Notices(filter *NoticeFilter) ([]*Notice, error) {
+	s.reading()
+
+	after := 0
+	if filter != nil && filter.After != "" {
+		var err error
+		after, err = strconv.Atoi(filter.After)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notice ID %q", filter.After)
+		}
+	}
+
+	now := time.Now()
+	var notices []*Notice
+	for _, n := range s.notices {
+		if n.expiredBefore(now) || !filter.matches(n, after) {
+			continue
+		}
+		notices = append(notices, n)
+	}
+
+	return notices, nil
+}
+
+// WaitNotices returns the notices that match the filter, waiting until there
+// is at least one or the context is done. The state lock is released while
+// waiting and is held again on return.
+func (s *State) WaitNotices(ctx context.Context, filter *NoticeFilter) ([]*Notice, error) {
+	for {
+		notices, err := s.Notices(filter)
+		if err != nil || len(notices) > 0 {
+			return notices, err
+		}
+
+		if s.noticesAdded == nil {
+			s.noticesAdded = make(chan struct{})
+		}
+		added := s.noticesAdded
+
+		s.Unlock()
+		select {
+		case <-added:
+			s.Lock()
+		case <-ctx.Done():
+			s.Lock()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				// timing out is an expected outcome
+				return nil, nil
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// pruneNotices removes expired notices.
+func (s *State) pruneNotices(now time.Time) {
+	kept := s.notices[:0]
+	for _, n := range s.notices {
+		if n.expiredBefore(now) {
+			s.writing()
+			continue
+		}
+		kept = append(kept, n)
+	}
+	s.notices = kept
+}
+
+// flattenNotices returns the unexpired notices, for serialising.
+// Call with the lock held.
+func (s *State) flattenNotices() []*Notice {
+	now := time.Now()
+	flat := make([]*Notice, 0, len(s.notices))
+	for _, n := range s.notices {
+		if n.expiredBefore(now) {
+			continue
+		}
+		flat = append(flat, n)
+	}
+	return flat
+}
+
+// unflattenNotices replaces the notices with the given ones, ignoring expired
+// ones. Call with the lock held.
+func (s *State) unflattenNotices(flat []*Notice) {
+	now := time.Now()
+	s.notices = make([]*Notice, 0, len(flat))
+	for _, n := range flat {
+		if n.expiredBefore(now) {
+			continue
+		}
+		s.notices = append(s.notices, n)
+	}
+	sort.Slice(s.notices, func(i, j int) bool { return s.notices[i].id < s.notices[j].id })
+}
@@ -0,0 +1,259 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type noticesSuite struct{}
+
+var _ = check.Suite(&noticesSuite{})
+
+func noticeIDs(notices []*state.Notice) []string {
+	ids := make([]string, 0, len(notices))
+	for _, n := range notices {
+		ids = append(ids, n.ID())
+	}
+	return ids
+}
+
+func (noticesSuite) TestAddNotice(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	id := st.AddNotice(state.SnapConfigNotice, "foo", &state.AddNoticeOptions{
+		ChangeID:    "42",
+		ChangedKeys: []string{"a.b", "c"},
+	})
+	c.Check(id, check.Equals, "1")
+	id = st.AddNotice(state.AspectNotice, "system/network", nil)
+	c.Check(id, check.Equals, "2")
+
+	notices, err := st.Notices(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(notices, check.HasLen, 2)
+
+	n := notices[0]
+	c.Check(n.ID(), check.Equals, "1")
+	c.Check(n.Type(), check.Equals, state.SnapConfigNotice)
+	c.Check(n.Key(), check.Equals, "foo")
+	c.Check(n.ChangeID(), check.Equals, "42")
+	c.Check(n.ChangedKeys(), check.DeepEquals, []string{"a.b", "c"})
+	c.Check(time.Since(n.Occurred()) < time.Minute, check.Equals, true)
+	c.Check(n.String(), check.Equals, "Notice 1 (snap-config:foo)")
+
+	n = notices[1]
+	c.Check(n.Type(), check.Equals, state.AspectNotice)
+	c.Check(n.Key(), check.Equals, "system/network")
+	c.Check(n.ChangeID(), check.Equals, "")
+	c.Check(n.ChangedKeys(), check.IsNil)
+}
+
+func (noticesSuite) TestAddNoticeInvalid(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	c.Check(func() { st.AddNotice("foo", "bar", nil) }, check.PanicMatches, `internal error, please report: attempted to add invalid notice \(type "foo", key "bar"\)`)
+	c.Check(func() { st.AddNotice(state.SnapConfigNotice, "", nil) }, check.PanicMatches, `internal error, please report: attempted to add invalid notice .*`)
+}
+
+func (noticesSuite) TestNoticesFilter(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	st.AddNotice(state.SnapConfigNotice, "foo", nil)
+	st.AddNotice(state.AspectNotice, "system/network", nil)
+	st.AddNotice(state.SnapConfigNotice, "bar", nil)
+	st.AddNotice(state.SnapConfigNotice, "foo", nil)
+
+	for _, t := range []struct {
+		filter *state.NoticeFilter
+		ids    []string
+	}{
+		{nil, []string{"1", "2", "3", "4"}},
+		{&state.NoticeFilter{}, []string{"1", "2", "3", "4"}},
+		{&state.NoticeFilter{Types: []state.NoticeType{state.SnapConfigNotice}}, []string{"1", "3", "4"}},
+		{&state.NoticeFilter{Types: []state.NoticeType{state.AspectNotice, state.SnapConfigNotice}}, []string{"1", "2", "3", "4"}},
+		{&state.NoticeFilter{Keys: []string{"foo", "system/network"}}, []string{"1", "2", "4"}},
+		{&state.NoticeFilter{After: "2"}, []string{"3", "4"}},
+		{&state.NoticeFilter{After: "2", Keys: []string{"foo"}}, []string{"4"}},
+		{&state.NoticeFilter{After: "4"}, []string{}},
+	} {
+		notices, err := st.Notices(t.filter)
+		c.Assert(err, check.IsNil)
+		c.Check(noticeIDs(notices), check.DeepEquals, t.ids, check.Commentf("%+v", t.filter))
+	}
+
+	_, err := st.Notices(&state.NoticeFilter{After: "x"})
+	c.Check(err, check.ErrorMatches, `invalid notice ID "x"`)
+}
+
+func (noticesSuite) TestNoticesLimit(c *check.C) {
+	restore := state.MockMaxNotices(2)
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	st.AddNotice(state.SnapConfigNotice, "foo", nil)
+	st.AddNotice(state.SnapConfigNotice, "bar", nil)
+	st.AddNotice(state.SnapConfigNotice, "baz", nil)
+
+	notices, err := st.Notices(nil)
+	c.Assert(err, check.IsNil)
+	c.Check(noticeIDs(notices), check.DeepEquals, []string{"2", "3"})
+}
+
+func (noticesSuite) TestNoticesExpire(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	st.AddNotice(state.SnapConfigNotice, "foo", nil)
+	st.AddNotice(state.SnapConfigNotice, "bar", nil)
+
+	notices, err := st.Notices(nil)
+	c.Assert(err, check.IsNil)
+	state.MockNoticeOccurred(notices[0], time.Now().Add(-state.NoticeExpireAfter-time.Minute))
+
+	notices, err = st.Notices(nil)
+	c.Assert(err, check.IsNil)
+	c.Check(noticeIDs(notices), check.DeepEquals, []string{"2"})
+
+	// expired notices aren't serialised
+	buf, err := json.Marshal(st)
+	c.Assert(err, check.IsNil)
+	var data map[string]json.RawMessage
+	c.Assert(json.Unmarshal(buf, &data), check.IsNil)
+	var serialised []map[string]interface{}
+	c.Assert(json.Unmarshal(data["notices"], &serialised), check.IsNil)
+	c.Assert(serialised, check.HasLen, 1)
+	c.Check(serialised[0]["id"], check.Equals, "2")
+
+	// and are pruned
+	st.Prune(time.Now(), time.Hour, time.Hour, 100)
+	notices, err = st.Notices(nil)
+	c.Assert(err, check.IsNil)
+	c.Check(noticeIDs(notices), check.DeepEquals, []string{"2"})
+}
+
+func (noticesSuite) TestNoticesRoundTrip(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	st.AddNotice(state.SnapConfigNotice, "foo", &state.AddNoticeOptions{
+		ChangeID:    "3",
+		ChangedKeys: []string{"a"},
+	})
+	st.AddNotice(state.AspectNotice, "system/network", nil)
+	buf, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	st2, err := state.ReadState(nil, bytes.NewReader(buf))
+	c.Assert(err, check.IsNil)
+	st2.Lock()
+	defer st2.Unlock()
+
+	notices, err := st2.Notices(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(notices, check.HasLen, 2)
+	c.Check(notices[0].ID(), check.Equals, "1")
+	c.Check(notices[0].Type(), check.Equals, state.SnapConfigNotice)
+	c.Check(notices[0].Key(), check.Equals, "foo")
+	c.Check(notices[0].ChangeID(), check.Equals, "3")
+	c.Check(notices[0].ChangedKeys(), check.DeepEquals, []string{"a"})
+	c.Check(notices[1].Key(), check.Equals, "system/network")
+
+	// IDs keep increasing after reloading
+	c.Check(st2.AddNotice(state.SnapConfigNotice, "bar", nil), check.Equals, "3")
+}
+
+func (noticesSuite) TestWaitNoticesExisting(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	st.AddNotice(state.SnapConfigNotice, "foo", nil)
+
+	notices, err := st.WaitNotices(context.Background(), nil)
+	c.Assert(err, check.IsNil)
+	c.Check(noticeIDs(notices), check.DeepEquals, []string{"1"})
+}
+
+func (noticesSuite) TestWaitNoticesNew(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	st.AddNotice(state.SnapConfigNotice, "foo", nil)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		st.Lock()
+		defer st.Unlock()
+		// not matching the filter
+		st.AddNotice(state.SnapConfigNotice, "bar", nil)
+		st.AddNotice(state.AspectNotice, "system/network", nil)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	notices, err := st.WaitNotices(ctx, &state.NoticeFilter{
+		Types: []state.NoticeType{state.AspectNotice},
+		After: "1",
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(noticeIDs(notices), check.DeepEquals, []string{"3"})
+}
+
+func (noticesSuite) TestWaitNoticesTimeout(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	notices, err := st.WaitNotices(ctx, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(notices, check.HasLen, 0)
+}
+
+func (noticesSuite) TestWaitNoticesCancelled(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := st.WaitNotices(ctx, nil)
+	c.Assert(err, check.Equals, context.Canceled)
+}
@@ -87,18 +87,26 @@ type State struct {
 	lastTaskId   int
 	lastChangeId int
 	lastLaneId   int
+	lastNoticeId int
 
 	backend  Backend
 	data     customData
 	changes  map[string]*Change
 	tasks    map[string]*Task
 	warnings map[string]*Warning
+	notices  []*Notice
+
+	// noticesAdded is closed when a notice is added, to wake up the
+	// WaitNotices callers
+	noticesAdded chan struct{}
 
 	modified bool
 
 	cache map[interface{}]interface{}
 
 	pendingChangeByAttr map[string]func(*Change) bool
+
+	prunedChangeHandlers []func(*Change)
 }
 
 // New returns a new empty state.
@@ -149,10 +157,12 @@ type marshalledState struct {
 	Changes  map[string]*Change          `json:"changes"`
 	Tasks    map[string]*Task            `json:"tasks"`
 	Warnings []*Warning                  `json:"warnings,omitempty"`
+	Notices  []*Notice                   `json:"notices,omitempty"`
 
 	LastChangeId int `json:"last-change-id"`
 	LastTaskId   int `json:"last-task-id"`
 	LastLaneId   int `json:"last-lane-id"`
+	LastNoticeId int `json:"last-notice-id,omitempty"`
 }
 
 // MarshalJSON makes State a json.Marshaller
@@ -163,10 +173,12 @@ func (s *State) MarshalJSON() ([]byte, error) {
 		Changes:  s.changes,
 		Tasks:    s.tasks,
 		Warnings: s.flattenWarnings(),
+		Notices:  s.flattenNotices(),
 
 		LastTaskId:   s.lastTaskId,
 		LastChangeId: s.lastChangeId,
 		LastLaneId:   s.lastLaneId,
+		LastNoticeId: s.lastNoticeId,
 	})
 }
 
@@ -182,9 +194,11 @@ func (s *State) UnmarshalJSON(data []byte) error {
 	s.changes = unmarshalled.Changes
 	s.tasks = unmarshalled.Tasks
 	s.unflattenWarnings(unmarshalled.Warnings)
+	s.unflattenNotices(unmarshalled.Notices)
 	s.lastChangeId = unmarshalled.LastChangeId
 	s.lastTaskId = unmarshalled.LastTaskId
 	s.lastLaneId = unmarshalled.LastLaneId
+	s.lastNoticeId = unmarshalled.LastNoticeId
 	// backlink state again
 	for _, t := range s.tasks {
 		t.state = s
@@ -380,6 +394,48 @@ func (s *State) TaskCount() int {
 	return len(s.tasks)
 }
 
+// DiscardTasks removes tasks that were never linked to a change, for example
+// because they were only created to find out what an operation would do.
+// Tasks linked to a change are left alone.
+func (s *State) DiscardTasks(tasks []*Task) {
+	s.writing()
+	discarded := make(map[string]bool, len(tasks))
+	for _, t := range tasks {
+		if t.change == "" {
+			discarded[t.id] = true
+		}
+	}
+	for id := range discarded {
+		t := s.tasks[id]
+		if t == nil {
+			continue
+		}
+		// drop the references to the task from the tasks it was
+		// ordered with
+		for _, other := range s.tasksIn(t.waitTasks) {
+			if other != nil && !discarded[other.id] {
+				other.haltTasks = removeID(other.haltTasks, id)
+			}
+		}
+		for _, other := range s.tasksIn(t.haltTasks) {
+			if other != nil && !discarded[other.id] {
+				other.waitTasks = removeID(other.waitTasks, id)
+			}
+		}
+		delete(s.tasks, id)
+	}
+}
+
+func removeID(ids []string, id string) []string {
+	out := ids[:0]
+	for _, other := range ids {
+		if other != id {
+			out = append(out, other)
+		}
+	}
+	return out
+}
+
 func (s *State) tasksIn(tids []string) []*Task {
 	res := make([]*Task, len(tids))
 	for i, tid := range tids {
@@ -395,17 +451,23 @@ func (s *State) RegisterPendingChangeByAttr(attr string, f func(*Change) bool) {
 	s.pendingChangeByAttr[attr] = f
 }
 
+// RegisterPrunedChangeHandler registers a function that Prune invokes with
+// every ready change before removing it, for example to archive it.
+func (s *State) RegisterPrunedChangeHandler(f func(*Change)) {
+	s.prunedChangeHandlers = append(s.prunedChangeHandlers, f)
+}
+
 // Prune does several cleanup tasks to the in-memory state:
 //
 //   - it removes changes that became ready for more than pruneWait and aborts
-//     tasks spawned for more than abortWait unless prevented by predicates
-//     registered with RegisterPendingChangeByAttr.
+//     tasks spawned, or held with Change.SetNotBefore, for more than abortWait
+//     unless prevented by predicates registered with RegisterPendingChangeByAttr.
 //
 //   - it removes tasks unlinked to changes after pruneWait. When there are more
 //     changes than the limit set via "maxReadyChanges" those changes in ready
 //     state will also removed even if they are below the pruneWait duration.
 //
-//   - it removes expired warnings.
+//   - it removes expired warnings and notices.
 func (s *State) Prune(startOfOperation time.Time, pruneWait, abortWait time.Duration, maxReadyChanges int) {
 	now := time.Now()
 	pruneLimit := now.Add(-pruneWait)
@@ -432,6 +494,7 @@ func (s *State) Prune(startOfOperation time.Time, pruneWait, abortWait time.Dura
 			delete(s.warnings, k)
 		}
 	}
+	s.pruneNotices(now)
 
 NextChange:
 	for _, chg := range changes {
@@ -440,6 +503,10 @@ NextChange:
 		if spawnTime.Before(startOfOperation) {
 			spawnTime = startOfOperation
 		}
+		// scheduled changes only count as spawned once they may start
+		if notBefore := chg.NotBefore(); spawnTime.Before(notBefore) {
+			spawnTime = notBefore
+		}
 		if readyTime.IsZero() {
 			if spawnTime.Before(pruneLimit) && len(chg.Tasks()) == 0 {
 				chg.Abort()
@@ -457,6 +524,9 @@ NextChange:
 		// change old or we have too many changes
 		if readyTime.Before(pruneLimit) || readyChangesCount > maxReadyChanges {
 			s.writing()
+			for _, f := range s.prunedChangeHandlers {
+				f(chg)
+			}
 			for _, t := range chg.Tasks() {
 				delete(s.tasks, t.ID())
 			}
@@ -686,6 +686,29 @@ func (ss *stateSuite) TestNewTaskAndTasks(c *C) {
 	}
 }
 
+func (ss *stateSuite) TestDiscardTasks(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("check", "...")
+	chg.AddTask(t1)
+
+	t2 := st.NewTask("download", "...")
+	t3 := st.NewTask("link", "...")
+	t2.WaitFor(t1)
+	t3.WaitFor(t2)
+	c.Check(st.TaskCount(), Equals, 3)
+
+	st.DiscardTasks([]*state.Task{t1, t2, t3})
+	// the task linked to a change is kept
+	c.Check(st.TaskCount(), Equals, 1)
+	c.Check(st.Task(t1.ID()), Equals, t1)
+	c.Check(t1.HaltTasks(), HasLen, 0)
+	c.Check(chg.Tasks(), DeepEquals, []*state.Task{t1})
+}
+
 func (ss *stateSuite) TestTaskNoTask(c *C) {
 	st := state.New(nil)
 	st.Lock()
@@ -956,6 +979,38 @@ func (ss *stateSuite) TestPruneMaxChangesHappy(c *C) {
 	})
 }
 
+func (ss *stateSuite) TestPruneCallsPrunedChangeHandlers(c *C) {
+	st := state.New(&fakeStateBackend{})
+	st.Lock()
+	defer st.Unlock()
+
+	now := time.Now()
+	pruneWait := 1 * time.Hour
+	abortWait := 3 * time.Hour
+
+	var pruned []string
+	st.RegisterPrunedChangeHandler(func(chg *state.Change) {
+		// the change still has its tasks
+		c.Check(chg.Tasks(), HasLen, 1)
+		pruned = append(pruned, chg.Kind())
+	})
+
+	for i, age := range []time.Duration{2 * time.Hour, time.Minute} {
+		chg := st.NewChange(fmt.Sprintf("chg%d", i), "...")
+		t := st.NewTask("foo", "...")
+		chg.AddTask(t)
+		t.SetStatus(state.DoneStatus)
+		state.MockChangeTimes(chg, now.Add(-age), now.Add(-age))
+	}
+	// not ready changes are not pruned
+	chg := st.NewChange("not-ready", "...")
+	chg.AddTask(st.NewTask("foo", "..."))
+
+	st.Prune(now.Add(-24*time.Hour), pruneWait, abortWait, 100)
+	c.Check(pruned, DeepEquals, []string{"chg0"})
+	c.Check(st.Changes(), HasLen, 2)
+}
+
 func (ss *stateSuite) TestPruneMaxChangesSomeNotReady(c *C) {
 	st := state.New(&fakeStateBackend{})
 	st.Lock()
@@ -1039,6 +1094,33 @@ func (ss *stateSuite) TestPruneHonorsStartOperationTime(c *C) {
 	c.Check(chg.Status(), Equals, state.HoldStatus)
 }
 
+func (ss *stateSuite) TestPruneHonorsNotBefore(c *C) {
+	st := state.New(&fakeStateBackend{})
+	st.Lock()
+	defer st.Unlock()
+
+	now := time.Now()
+	pruneWait := 1 * time.Hour
+	abortWait := 3 * time.Hour
+
+	chg := st.NewChange("change", "...")
+	t := st.NewTask("foo", "")
+	chg.AddTask(t)
+	// change spawned 10h ago, held until 2h ago
+	state.MockChangeTimes(chg, now.Add(-10*time.Hour), time.Time{})
+	chg.SetNotBefore(now.Add(-2 * time.Hour))
+
+	// not aborted as it could only start 2h ago
+	st.Prune(now.Add(-24*time.Hour), pruneWait, abortWait, 100)
+	c.Assert(st.Changes(), HasLen, 1)
+	c.Check(chg.Status(), Equals, state.DoStatus)
+
+	chg.SetNotBefore(now.Add(-4 * time.Hour))
+	st.Prune(now.Add(-24*time.Hour), pruneWait, abortWait, 100)
+	c.Assert(st.Changes(), HasLen, 1)
+	c.Check(chg.Status(), Equals, state.HoldStatus)
+}
+
 func (ss *stateSuite) TestReadStateInitsTransientMapFields(c *C) {
 	st, err := state.ReadState(nil, bytes.NewBufferString("{}"))
 	c.Assert(err, IsNil)
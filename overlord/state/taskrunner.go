@@ -20,12 +20,14 @@
 package state
 
 import (
+	"sort"
 	"sync"
 	"time"
 
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/strutil"
 )
 
 // HandlerFunc is the type of function for the handlers
@@ -79,6 +81,8 @@ type TaskRunner struct {
 	blocked     []blockedFunc
 	someBlocked bool
 
+	concurrencyLimits []concurrencyLimit
+
 	// optional callback executed on task errors
 	taskErrorCallback func(err error)
 
@@ -86,6 +90,11 @@ type TaskRunner struct {
 	tombs map[string]*tomb.Tomb
 }
 
+type concurrencyLimit struct {
+	kinds []string
+	limit int
+}
+
 type handlerPair struct {
 	do, undo HandlerFunc
 }
@@ -183,6 +192,86 @@ func (r *TaskRunner) AddBlocked(pred func(t *Task, running []*Task) bool) {
 	r.blocked = append(r.blocked, pred)
 }
 
+// SetConcurrencyLimit sets the maximum number of tasks of the given kinds,
+// counted together, that can run at the same time.
+//
+// A task held back by the limit preempts a running task of a change with a
+// lower priority: the running task is stopped through its tomb and run again
+// later as if it returned a Retry. The handlers of the tasks of the given
+// kinds must be able to be stopped midway and run again.
+func (r *TaskRunner) SetConcurrencyLimit(limit int, kinds ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.concurrencyLimits = append(r.concurrencyLimits, concurrencyLimit{kinds: kinds, limit: limit})
+}
+
+func taskPriority(t *Task) Priority {
+	if chg := t.Change(); chg != nil {
+		return chg.Priority()
+	}
+	return NormalPriority
+}
+
+// overConcurrencyLimit returns whether running task t would exceed one of
+// the concurrency limits. If so, it preempts a running task of a lower
+// priority which counts towards the limit, if there is one.
+func (r *TaskRunner) overConcurrencyLimit(t *Task, running []*Task) bool {
+	for _, cl := range r.concurrencyLimits {
+		if !strutil.ListContains(cl.kinds, t.Kind()) {
+			continue
+		}
+		var limited []*Task
+		preempting := false
+		for _, rt := range running {
+			if rt.Status().Ready() || !strutil.ListContains(cl.kinds, rt.Kind()) {
+				continue
+			}
+			limited = append(limited, rt)
+			if tb := r.tombs[rt.ID()]; tb != nil && !tb.Alive() {
+				preempting = true
+			}
+		}
+		if len(limited) < cl.limit {
+			continue
+		}
+		if !preempting {
+			// only one task is preempted at a time, the next
+			// Ensure considers the waiting tasks again
+			r.preemptLowerPriority(t, limited)
+		}
+		return true
+	}
+	return false
+}
+
+func (r *TaskRunner) preemptLowerPriority(t *Task, running []*Task) {
+	priority := taskPriority(t)
+	var victim *Task
+	for _, rt := range running {
+		if rt.Status() != DoingStatus || taskPriority(rt) >= priority {
+			continue
+		}
+		if victim == nil || taskPriority(rt) < taskPriority(victim) {
+			victim = rt
+		}
+	}
+	if victim == nil {
+		return
+	}
+	tb := r.tombs[victim.ID()]
+	if tb == nil {
+		// started by this Ensure, nothing to preempt yet
+		return
+	}
+	logger.Noticef("Preempting task %s %q in favor of task %s %q of higher priority", victim.ID(), victim.Summary(), t.ID(), t.Summary())
+	tb.Kill(errPreempted)
+}
+
+// errPreempted is the reason a preempted task is stopped with, the task is
+// run again later unless its handler completed anyway.
+var errPreempted = &Retry{}
+
 // run must be called with the state lock in place
 func (r *TaskRunner) run(t *Task) {
 	var handler HandlerFunc
@@ -217,7 +306,8 @@ func (r *TaskRunner) run(t *Task) {
 		// use tomb.Err uniformly to consider both it or a
 		// overriding previous Kill reason.
 		t0 := time.Now()
-		tomb.Kill(handler(t, tomb))
+		handlerErr := handler(t, tomb)
+		tomb.Kill(handlerErr)
 		t1 := time.Now()
 
 		// Locks must be acquired in the same order everywhere.
@@ -236,6 +326,11 @@ func (r *TaskRunner) run(t *Task) {
 		}
 
 		err := tomb.Err()
+		if err == errPreempted && handlerErr == nil {
+			// the handler completed before it noticed it was
+			// preempted, there is nothing to run again
+			err = nil
+		}
 		switch err.(type) {
 		case nil:
 			// we are ok
@@ -397,10 +492,16 @@ func (r *TaskRunner) Ensure() error {
 		}
 	}
 
+	// consider the tasks of changes with a higher priority first
+	tasks := r.state.Tasks()
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return taskPriority(tasks[i]) > taskPriority(tasks[j])
+	})
+
 	ensureTime := timeNow()
 	nextTaskTime := time.Time{}
 ConsiderTasks:
-	for _, t := range r.state.Tasks() {
+	for _, t := range tasks {
 		handlers := r.handlerPair(t)
 		if handlers.do == nil {
 			// Handled by a different runner instance.
@@ -467,6 +568,10 @@ ConsiderTasks:
 				continue ConsiderTasks
 			}
 		}
+		if r.overConcurrencyLimit(t, running) {
+			r.someBlocked = true
+			continue
+		}
 
 		logger.Debugf("Running task %s on %s: %s", t.ID(), t.Status(), t.Summary())
 		r.run(t)
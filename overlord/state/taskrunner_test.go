@@ -1328,3 +1328,227 @@ func (ts *taskRunnerSuite) TestErrorCallbackNotCalled(c *C) {
 	c.Check(t1.Status(), Equals, state.DoneStatus)
 	c.Check(called, Equals, false)
 }
+
+func (ts *taskRunnerSuite) TestConcurrencyLimitHonorsPriorities(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	var ran []string
+	r.AddHandler("download", func(t *state.Task, _ *tomb.Tomb) error {
+		st.Lock()
+		defer st.Unlock()
+		ran = append(ran, t.Change().Kind())
+		return nil
+	}, nil)
+	r.AddHandler("other", func(t *state.Task, _ *tomb.Tomb) error { return nil }, nil)
+	r.SetConcurrencyLimit(1, "download")
+
+	st.Lock()
+	var others []*state.Task
+	for _, p := range []state.Priority{state.LowPriority, state.NormalPriority, state.HighPriority} {
+		chg := st.NewChange(p.String(), "...")
+		chg.SetPriority(p)
+		chg.AddTask(st.NewTask("download", "..."))
+		other := st.NewTask("other", "...")
+		chg.AddTask(other)
+		others = append(others, other)
+	}
+	st.Unlock()
+
+	for i := 0; i < 3; i++ {
+		r.Ensure()
+		r.Wait()
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(ran, DeepEquals, []string{"high", "normal", "low"})
+	// tasks of other kinds were not limited
+	for _, t := range others {
+		c.Check(t.Status(), Equals, state.DoneStatus)
+	}
+}
+
+func (ts *taskRunnerSuite) TestConcurrencyLimitPreemptsLowerPriority(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	started := make(chan bool, 1)
+	lowRuns := 0
+	r.AddHandler("download", func(t *state.Task, tb *tomb.Tomb) error {
+		st.Lock()
+		priority := t.Change().Priority()
+		if priority == state.LowPriority {
+			lowRuns++
+		}
+		first := lowRuns == 1
+		st.Unlock()
+		if priority == state.LowPriority && first {
+			started <- true
+			<-tb.Dying()
+			return errors.New("download interrupted")
+		}
+		return nil
+	}, nil)
+	r.SetConcurrencyLimit(1, "download", "pre-download")
+
+	st.Lock()
+	lowChg := st.NewChange("auto-refresh", "...")
+	lowChg.SetPriority(state.LowPriority)
+	low := st.NewTask("download", "...")
+	lowChg.AddTask(low)
+	st.Unlock()
+
+	r.Ensure()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		c.Fatal("low priority task did not start")
+	}
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("download", "...")
+	chg.AddTask(t)
+	st.Unlock()
+
+	// the low priority task is stopped to let the other one run
+	r.Ensure()
+	r.Wait()
+	st.Lock()
+	c.Check(low.Status(), Equals, state.DoingStatus)
+	c.Check(t.Status(), Equals, state.DoStatus)
+	st.Unlock()
+
+	r.Ensure()
+	r.Wait()
+	st.Lock()
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(low.Status(), Equals, state.DoingStatus)
+	st.Unlock()
+
+	// and the low priority task runs again
+	r.Ensure()
+	r.Wait()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(low.Status(), Equals, state.DoneStatus)
+	c.Check(lowRuns, Equals, 2)
+}
+
+func (ts *taskRunnerSuite) TestConcurrencyLimitDoesNotPreemptSamePriority(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	release := make(chan bool)
+	started := make(chan bool, 1)
+	r.AddHandler("download", func(t *state.Task, tb *tomb.Tomb) error {
+		started <- true
+		select {
+		case <-release:
+			return nil
+		case <-tb.Dying():
+			return errors.New("download interrupted")
+		}
+	}, nil)
+	r.SetConcurrencyLimit(1, "download")
+
+	st.Lock()
+	chg1 := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "...")
+	chg1.AddTask(t1)
+	st.Unlock()
+
+	r.Ensure()
+	<-started
+
+	st.Lock()
+	chg2 := st.NewChange("install", "...")
+	t2 := st.NewTask("download", "...")
+	chg2.AddTask(t2)
+	st.Unlock()
+
+	r.Ensure()
+	c.Check(started, HasLen, 0)
+	close(release)
+	r.Wait()
+
+	st.Lock()
+	c.Check(t1.Status(), Equals, state.DoneStatus)
+	c.Check(t2.Status(), Equals, state.DoStatus)
+	st.Unlock()
+
+	r.Ensure()
+	r.Wait()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(t2.Status(), Equals, state.DoneStatus)
+}
+
+func (ts *taskRunnerSuite) TestConcurrencyLimitPreemptedTaskCompletes(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	started := make(chan bool, 1)
+	lowRuns := 0
+	r.AddHandler("download", func(t *state.Task, tb *tomb.Tomb) error {
+		st.Lock()
+		priority := t.Change().Priority()
+		if priority == state.LowPriority {
+			lowRuns++
+		}
+		st.Unlock()
+		if priority == state.LowPriority {
+			started <- true
+			// the download completes even though it was
+			// asked to stop
+			<-tb.Dying()
+		}
+		return nil
+	}, nil)
+	r.SetConcurrencyLimit(1, "download")
+
+	st.Lock()
+	lowChg := st.NewChange("auto-refresh", "...")
+	lowChg.SetPriority(state.LowPriority)
+	low := st.NewTask("download", "...")
+	lowChg.AddTask(low)
+	st.Unlock()
+
+	r.Ensure()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		c.Fatal("low priority task did not start")
+	}
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("download", "...")
+	chg.AddTask(t)
+	st.Unlock()
+
+	r.Ensure()
+	r.Wait()
+	st.Lock()
+	// the preempted task is done and not run again
+	c.Check(low.Status(), Equals, state.DoneStatus)
+	c.Check(t.Status(), Equals, state.DoStatus)
+	st.Unlock()
+
+	r.Ensure()
+	r.Wait()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(low.Status(), Equals, state.DoneStatus)
+	c.Check(lowRuns, Equals, 1)
+}
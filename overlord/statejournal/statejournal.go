@@ -0,0 +1,250 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package statejournal implements the format of the state journal, an
+// append-only journal of the entries of the state that changed with every
+// checkpoint.
+//
+// Every record of the journal is a line with the CRC32 checksum of the
+// record followed by the record in JSON. The journal always starts with a
+// record holding the whole state.
+package statejournal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/strutil"
+)
+
+// Record is a record of the state journal.
+type Record struct {
+	// Full is set for the record holding the whole state.
+	Full bool `json:"full,omitempty"`
+	// Set holds the entries that were added or changed.
+	Set map[string]json.RawMessage `json:"set,omitempty"`
+	// Delete holds the keys of the entries that were removed.
+	Delete []string `json:"delete,omitempty"`
+	// StateFileSum is the checksum of the state file written along the
+	// record holding the whole state.
+	StateFileSum string `json:"state-file-sum,omitempty"`
+}
+
+// Journal is what was read from a state journal.
+type Journal struct {
+	// Entries are the entries of the state after replaying the records.
+	Entries map[string]json.RawMessage
+	// Size is the size of the records that were read, FullSize the
+	// size of the record holding the whole state it starts with.
+	Size     int64
+	FullSize int64
+	// StateFileSum is the checksum of the state file written along the
+	// record holding the whole state.
+	StateFileSum string
+	// Dropped is why the records after Size were dropped, if they were,
+	// which happens to a record left incomplete by a crash.
+	Dropped error
+}
+
+// CorruptedError is returned by Read when the journal does not start with a
+// readable record holding the whole state, so that no state can be read from
+// it.
+type CorruptedError struct {
+	reason string
+}
+
+func (e *CorruptedError) Error() string {
+	return "cannot read the state journal: " + e.reason
+}
+
+// Read reads the journal from r and replays its records. Records that
+// cannot be read at the end of the journal are dropped.
+func Read(r io.Reader) (*Journal, error) {
+	j := &Journal{}
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("cannot read the state journal: %v", err)
+		}
+		if len(line) == 0 {
+			break
+		}
+		rec, decodeErr := DecodeRecord(line)
+		if decodeErr != nil {
+			j.Dropped = decodeErr
+			break
+		}
+		if j.Entries == nil {
+			if !rec.Full {
+				return nil, &CorruptedError{reason: "journal does not start with the whole state"}
+			}
+			j.Entries = make(map[string]json.RawMessage, len(rec.Set))
+			j.FullSize = int64(len(line))
+			j.StateFileSum = rec.StateFileSum
+		}
+		for key, entry := range rec.Set {
+			j.Entries[key] = entry
+		}
+		for _, key := range rec.Delete {
+			delete(j.Entries, key)
+		}
+		j.Size += int64(len(line))
+	}
+	if j.Entries == nil {
+		return nil, &CorruptedError{reason: "journal is empty or corrupted"}
+	}
+	return j, nil
+}
+
+// StateFileSum returns the checksum of the state file recorded in the
+// record holding the whole state.
+func StateFileSum(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// EncodeRecord returns the line of the journal holding the record.
+func EncodeRecord(rec *Record) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode state journal record: %v", err)
+	}
+	return []byte(fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(payload), payload)), nil
+}
+
+// DecodeRecord returns the record held by the line of the journal.
+func DecodeRecord(line []byte) (*Record, error) {
+	if len(line) < 10 || line[len(line)-1] != '\n' || line[8] != ' ' {
+		return nil, errors.New("incomplete record")
+	}
+	sum, err := strconv.ParseUint(string(line[:8]), 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid record checksum: %v", err)
+	}
+	payload := line[9 : len(line)-1]
+	if crc32.ChecksumIEEE(payload) != uint32(sum) {
+		return nil, errors.New("record checksum mismatch")
+	}
+	var rec Record
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return nil, fmt.Errorf("cannot decode record: %v", err)
+	}
+	return &rec, nil
+}
+
+// the parts of the serialized state that are collections of entries
+// journaled individually, the lists have their elements identified by the
+// given field
+var (
+	stateEntryMaps  = []string{"data", "changes", "tasks"}
+	stateEntryLists = map[string]string{"warnings": "message", "notices": "id"}
+)
+
+// SplitState splits the serialized state into its entries: one per data
+// key, change, task, warning and notice, and one per any other top-level
+// field. The keys of the entries of collections are the collection name and
+// the entry id separated by a slash.
+func SplitState(data []byte) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("cannot split state into journal entries: %v", err)
+	}
+	entries := make(map[string]json.RawMessage)
+	for name, field := range fields {
+		if strutil.ListContains(stateEntryMaps, name) {
+			var m map[string]json.RawMessage
+			if err := json.Unmarshal(field, &m); err != nil {
+				return nil, fmt.Errorf("cannot split state %s into journal entries: %v", name, err)
+			}
+			for id, entry := range m {
+				entries[name+"/"+id] = entry
+			}
+			continue
+		}
+		if idField, ok := stateEntryLists[name]; ok {
+			var l []map[string]json.RawMessage
+			if err := json.Unmarshal(field, &l); err != nil {
+				return nil, fmt.Errorf("cannot split state %s into journal entries: %v", name, err)
+			}
+			for _, elem := range l {
+				var id string
+				if err := json.Unmarshal(elem[idField], &id); err != nil {
+					return nil, fmt.Errorf("cannot split state %s into journal entries: invalid %s: %v", name, idField, err)
+				}
+				entry, err := json.Marshal(elem)
+				if err != nil {
+					return nil, err
+				}
+				entries[name+"/"+id] = entry
+			}
+			continue
+		}
+		entries[name] = field
+	}
+	return entries, nil
+}
+
+// JoinState serializes the state from its entries, as split by SplitState.
+func JoinState(entries map[string]json.RawMessage) ([]byte, error) {
+	fields := make(map[string]interface{})
+	for _, name := range stateEntryMaps {
+		fields[name] = make(map[string]json.RawMessage)
+	}
+	lists := make(map[string][]string)
+	for key, entry := range entries {
+		l := strings.SplitN(key, "/", 2)
+		if len(l) == 1 {
+			fields[key] = entry
+			continue
+		}
+		name, id := l[0], l[1]
+		if _, ok := stateEntryLists[name]; ok {
+			lists[name] = append(lists[name], id)
+			continue
+		}
+		m, ok := fields[name].(map[string]json.RawMessage)
+		if !ok {
+			return nil, fmt.Errorf("cannot read the state journal: unknown entry %q", key)
+		}
+		m[id] = entry
+	}
+	for name, ids := range lists {
+		// keep the lists in a stable order, numerically for notices
+		sort.Slice(ids, func(i, j int) bool {
+			if len(ids[i]) != len(ids[j]) {
+				return len(ids[i]) < len(ids[j])
+			}
+			return ids[i] < ids[j]
+		})
+		l := make([]json.RawMessage, len(ids))
+		for i, id := range ids {
+			l[i] = entries[name+"/"+id]
+		}
+		fields[name] = l
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package statejournal_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/statejournal"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type statejournalSuite struct{}
+
+var _ = Suite(&statejournalSuite{})
+
+var stateJSON = []byte(`{"data":{"seeded":true},"changes":{"1":{"id":"1"}},"tasks":{},"last-change-id":1,"notices":[{"id":"2"},{"id":"10"}]}`)
+
+func encode(c *C, recs ...*statejournal.Record) []byte {
+	var buf bytes.Buffer
+	for _, rec := range recs {
+		line, err := statejournal.EncodeRecord(rec)
+		c.Assert(err, IsNil)
+		buf.Write(line)
+	}
+	return buf.Bytes()
+}
+
+func (s *statejournalSuite) TestSplitJoinState(c *C) {
+	entries, err := statejournal.SplitState(stateJSON)
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 5)
+	c.Check(string(entries["data/seeded"]), Equals, "true")
+	c.Check(string(entries["changes/1"]), Equals, `{"id":"1"}`)
+	c.Check(string(entries["notices/10"]), Equals, `{"id":"10"}`)
+
+	data, err := statejournal.JoinState(entries)
+	c.Assert(err, IsNil)
+	var joined, orig map[string]interface{}
+	c.Assert(json.Unmarshal(data, &joined), IsNil)
+	c.Assert(json.Unmarshal(stateJSON, &orig), IsNil)
+	c.Check(joined, DeepEquals, orig)
+}
+
+func (s *statejournalSuite) TestRead(c *C) {
+	entries, err := statejournal.SplitState(stateJSON)
+	c.Assert(err, IsNil)
+	full := encode(c, &statejournal.Record{Full: true, Set: entries, StateFileSum: "sum"})
+	data := append(full, encode(c, &statejournal.Record{
+		Set:    map[string]json.RawMessage{"data/seeded": json.RawMessage("false")},
+		Delete: []string{"changes/1"},
+	})...)
+
+	j, err := statejournal.Read(bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	c.Check(j.Dropped, IsNil)
+	c.Check(j.Size, Equals, int64(len(data)))
+	c.Check(j.FullSize, Equals, int64(len(full)))
+	c.Check(j.StateFileSum, Equals, "sum")
+	c.Check(string(j.Entries["data/seeded"]), Equals, "false")
+	c.Check(j.Entries["changes/1"], IsNil)
+}
+
+func (s *statejournalSuite) TestReadDropsIncompleteRecord(c *C) {
+	full := encode(c, &statejournal.Record{Full: true, Set: map[string]json.RawMessage{"last-change-id": json.RawMessage("1")}})
+	data := append(full, []byte("0badc0de {\"set\":")...)
+
+	j, err := statejournal.Read(bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	c.Check(j.Dropped, ErrorMatches, "incomplete record")
+	c.Check(j.Size, Equals, int64(len(full)))
+	c.Check(string(j.Entries["last-change-id"]), Equals, "1")
+}
+
+func (s *statejournalSuite) TestReadErrors(c *C) {
+	_, err := statejournal.Read(bytes.NewReader(nil))
+	c.Check(err, ErrorMatches, "cannot read the state journal: journal is empty or corrupted")
+
+	data := encode(c, &statejournal.Record{Set: map[string]json.RawMessage{"last-change-id": json.RawMessage("1")}})
+	_, err = statejournal.Read(bytes.NewReader(data))
+	c.Check(err, ErrorMatches, "cannot read the state journal: journal does not start with the whole state")
+}
+
+func (s *statejournalSuite) TestDecodeRecordChecksumMismatch(c *C) {
+	line := encode(c, &statejournal.Record{Delete: []string{"changes/1"}})
+	line[0] ^= 1
+	_, err := statejournal.DecodeRecord(line)
+	c.Check(err, ErrorMatches, "record checksum mismatch|invalid record checksum: .*")
+}
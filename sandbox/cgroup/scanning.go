@@ -78,6 +78,14 @@ type InstancePathsOptions struct {
 // The return value is a snapshot of the cgroup paths
 
 func InstancePathsOfSnap(snapInstanceName string, options InstancePathsOptions) ([]string, error) {
+	return instancePaths(func(tag naming.SecurityTag) bool {
+		return tag.InstanceName() == snapInstanceName
+	}, options)
+}
+
+// instancePaths returns the list of active cgroup paths of the snap apps
+// and hooks for which match returns true, see InstancePathsOfSnap.
+func instancePaths(match func(tag naming.SecurityTag) bool, options InstancePathsOptions) ([]string, error) {
 	var cgroupPathToScan string
 	var pathList []string
 
@@ -137,7 +145,7 @@ func InstancePathsOfSnap(snapInstanceName string, options InstancePathsOptions)
 		if parsedTag == nil {
 			return nil
 		}
-		if parsedTag.InstanceName() != snapInstanceName {
+		if !match(parsedTag) {
 			return nil
 		}
 		if options.ReturnCGroupPath {
@@ -0,0 +1,216 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/snap/naming"
+)
+
+// Stats is the resource usage accounted to one or more cgroups at some
+// point in time. The cpu, io and OOM kill values are cumulative since the
+// cgroups were created.
+type Stats struct {
+	CPUUsage      time.Duration
+	MemoryCurrent uint64
+	MemoryPeak    uint64
+	IOReadBytes   uint64
+	IOWriteBytes  uint64
+	IOReadOps     uint64
+	IOWriteOps    uint64
+	Pids          uint64
+	// OOMKills is the number of processes killed by the OOM killer.
+	OOMKills uint64
+}
+
+// Add accumulates the usage in other into s.
+func (s *Stats) Add(other *Stats) {
+	s.CPUUsage += other.CPUUsage
+	s.MemoryCurrent += other.MemoryCurrent
+	s.MemoryPeak += other.MemoryPeak
+	s.IOReadBytes += other.IOReadBytes
+	s.IOWriteBytes += other.IOWriteBytes
+	s.IOReadOps += other.IOReadOps
+	s.IOWriteOps += other.IOWriteOps
+	s.Pids += other.Pids
+	s.OOMKills += other.OOMKills
+}
+
+// readSingleValue reads a cgroup file holding a single number. A missing
+// file, which is the case when the controller is not enabled for the
+// cgroup, reads as zero.
+func readSingleValue(path string) (uint64, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	text := strings.TrimSpace(string(data))
+	if text == "max" {
+		return 0, nil
+	}
+	value, err := strconv.ParseUint(text, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %s: %v", path, err)
+	}
+	return value, nil
+}
+
+// readKeyedValues calls fn for every "key value" pair on each line of the
+// given cgroup file. A missing file is not an error.
+func readKeyedValues(path string, fn func(key string, value uint64)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if !strings.Contains(fields[1], "=") {
+			// flat keyed, as in cpu.stat: "usage_usec 1234"
+			value, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("cannot parse %s: %v", path, err)
+			}
+			fn(fields[0], value)
+			continue
+		}
+		// nested keyed, as in io.stat: "8:0 rbytes=1 wbytes=2 ..."
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			value, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("cannot parse %s: %v", path, err)
+			}
+			fn(kv[0], value)
+		}
+	}
+	return scanner.Err()
+}
+
+// ReadStats returns the resource usage of the cgroup at the given path of
+// the unified hierarchy.
+func ReadStats(cgroupPath string) (*Stats, error) {
+	var stats Stats
+	var err error
+
+	err = readKeyedValues(filepath.Join(cgroupPath, "cpu.stat"), func(key string, value uint64) {
+		if key == "usage_usec" {
+			stats.CPUUsage = time.Duration(value) * time.Microsecond
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = readKeyedValues(filepath.Join(cgroupPath, "io.stat"), func(key string, value uint64) {
+		switch key {
+		case "rbytes":
+			stats.IOReadBytes += value
+		case "wbytes":
+			stats.IOWriteBytes += value
+		case "rios":
+			stats.IOReadOps += value
+		case "wios":
+			stats.IOWriteOps += value
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = readKeyedValues(filepath.Join(cgroupPath, "memory.events"), func(key string, value uint64) {
+		if key == "oom_kill" {
+			stats.OOMKills = value
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range []struct {
+		file  string
+		value *uint64
+	}{
+		{"memory.current", &stats.MemoryCurrent},
+		// only available since linux 5.19
+		{"memory.peak", &stats.MemoryPeak},
+		{"pids.current", &stats.Pids},
+	} {
+		if *v.value, err = readSingleValue(filepath.Join(cgroupPath, v.file)); err != nil {
+			return nil, err
+		}
+	}
+	return &stats, nil
+}
+
+// StatsOfAllSnaps returns the resource usage of the running apps and hooks
+// of all snaps, grouped by security tag. It requires the unified hierarchy.
+//
+// The return value is a snapshot, and processes that ran and exited since
+// the previous call are not accounted for.
+func StatsOfAllSnaps() (map[string]*Stats, error) {
+	ver, err := Version()
+	if err != nil {
+		return nil, err
+	}
+	if ver != V2 {
+		return nil, fmt.Errorf("cannot read resource usage with cgroup version %d", ver)
+	}
+
+	paths, err := instancePaths(func(naming.SecurityTag) bool { return true }, InstancePathsOptions{
+		ReturnCGroupPath: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	statsByTag := make(map[string]*Stats)
+	for _, path := range paths {
+		stats, err := ReadStats(path)
+		if err != nil {
+			return nil, err
+		}
+		tag := securityTagFromCgroupPath(path).String()
+		if statsByTag[tag] == nil {
+			statsByTag[tag] = &Stats{}
+		}
+		statsByTag[tag].Add(stats)
+	}
+	return statsByTag, nil
+}
@@ -0,0 +1,144 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cgroup_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type statsSuite struct {
+	testutil.BaseTest
+	rootDir string
+}
+
+var _ = Suite(&statsSuite{})
+
+func (s *statsSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.rootDir = c.MkDir()
+	dirs.SetRootDir(s.rootDir)
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+}
+
+func (s *statsSuite) mockCgroup(c *C, dir string, files map[string]string) string {
+	path := filepath.Join(s.rootDir, "/sys/fs/cgroup", dir)
+	c.Assert(os.MkdirAll(path, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(path, "cgroup.procs"), []byte("1\n"), 0644), IsNil)
+	for name, content := range files {
+		c.Assert(ioutil.WriteFile(filepath.Join(path, name), []byte(content), 0644), IsNil)
+	}
+	return path
+}
+
+func (s *statsSuite) TestReadStats(c *C) {
+	path := s.mockCgroup(c, "system.slice/snap.foo.svc.service", map[string]string{
+		"cpu.stat":       "usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\n",
+		"memory.current": "4096\n",
+		"memory.peak":    "8192\n",
+		"pids.current":   "3\n",
+		"memory.events":  "low 0\nhigh 12\nmax 4\noom 2\noom_kill 1\noom_group_kill 0\n",
+		"io.stat": "8:0 rbytes=100 wbytes=200 rios=1 wios=2 dbytes=0 dios=0\n" +
+			"8:16 rbytes=10 wbytes=20 rios=3 wios=4 dbytes=0 dios=0\n",
+	})
+
+	stats, err := cgroup.ReadStats(path)
+	c.Assert(err, IsNil)
+	c.Check(stats, DeepEquals, &cgroup.Stats{
+		CPUUsage:      1500 * time.Millisecond,
+		MemoryCurrent: 4096,
+		MemoryPeak:    8192,
+		IOReadBytes:   110,
+		IOWriteBytes:  220,
+		IOReadOps:     4,
+		IOWriteOps:    6,
+		Pids:          3,
+		OOMKills:      1,
+	})
+}
+
+func (s *statsSuite) TestReadStatsMissingControllers(c *C) {
+	path := s.mockCgroup(c, "system.slice/snap.foo.svc.service", map[string]string{
+		"cpu.stat": "usage_usec 10\n",
+	})
+
+	stats, err := cgroup.ReadStats(path)
+	c.Assert(err, IsNil)
+	c.Check(stats, DeepEquals, &cgroup.Stats{CPUUsage: 10 * time.Microsecond})
+}
+
+func (s *statsSuite) TestReadStatsBadData(c *C) {
+	path := s.mockCgroup(c, "system.slice/snap.foo.svc.service", map[string]string{
+		"memory.current": "lots\n",
+	})
+
+	_, err := cgroup.ReadStats(path)
+	c.Assert(err, ErrorMatches, `cannot parse .*/memory.current: .*`)
+}
+
+func (s *statsSuite) TestStatsOfAllSnaps(c *C) {
+	restore := cgroup.MockVersion(cgroup.V2, nil)
+	defer restore()
+
+	s.mockCgroup(c, "system.slice/snap.foo.svc.service", map[string]string{
+		"memory.current": "100\n",
+		"pids.current":   "1\n",
+	})
+	s.mockCgroup(c, "user.slice/user-1000.slice/user@1000.service/app.slice/snap.foo.app.1234-5678.scope", map[string]string{
+		"memory.current": "10\n",
+		"pids.current":   "1\n",
+	})
+	s.mockCgroup(c, "user.slice/user-1000.slice/user@1000.service/app.slice/snap.foo.app.abcd-efgh.scope", map[string]string{
+		"memory.current": "20\n",
+		"pids.current":   "2\n",
+	})
+	s.mockCgroup(c, "system.slice/snap.bar.svc.service", map[string]string{
+		"memory.current": "1000\n",
+	})
+
+	s.mockCgroup(c, "system.slice/snapd.service", map[string]string{
+		"memory.current": "5000\n",
+	})
+
+	stats, err := cgroup.StatsOfAllSnaps()
+	c.Assert(err, IsNil)
+	c.Check(stats, DeepEquals, map[string]*cgroup.Stats{
+		"snap.foo.svc": {MemoryCurrent: 100, Pids: 1},
+		"snap.foo.app": {MemoryCurrent: 30, Pids: 3},
+		"snap.bar.svc": {MemoryCurrent: 1000},
+	})
+}
+
+func (s *statsSuite) TestStatsOfAllSnapsCgroupV1(c *C) {
+	restore := cgroup.MockVersion(cgroup.V1, nil)
+	defer restore()
+
+	_, err := cgroup.StatsOfAllSnaps()
+	c.Assert(err, ErrorMatches, "cannot read resource usage with cgroup version 1")
+}
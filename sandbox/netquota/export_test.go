@@ -0,0 +1,53 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netquota
+
+import (
+	"github.com/snapcore/snapd/testutil"
+)
+
+var (
+	ParseKernelVersion = parseKernelVersion
+	Ruleset            = ruleset
+)
+
+func MockCgroupVersion(f func() (int, error)) (restore func()) {
+	r := testutil.Backup(&cgroupVersion)
+	cgroupVersion = f
+	return r
+}
+
+func MockKernelVersion(f func() string) (restore func()) {
+	r := testutil.Backup(&kernelVersion)
+	kernelVersion = f
+	return r
+}
+
+func MockNftLookPath(f func() error) (restore func()) {
+	r := testutil.Backup(&nftLookPath)
+	nftLookPath = f
+	return r
+}
+
+func MockNftLoad(f func(script []byte) error) (restore func()) {
+	r := testutil.Backup(&nftLoad)
+	nftLoad = f
+	return r
+}
@@ -0,0 +1,176 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package netquota enforces network bandwidth limits on cgroups.
+//
+// The limits are implemented with a dedicated nftables table, with rules
+// matching the traffic of the sockets owned by processes in a cgroup of the
+// unified hierarchy and dropping what goes over the allowed rate. Dropping
+// packets makes TCP senders back off, so in practice the traffic is shaped
+// close to the limit.
+package netquota
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+)
+
+// tableName is the name of the nftables table holding the rules of all
+// limits, which is always replaced as a whole.
+const tableName = "snapd-quotas"
+
+var (
+	cgroupVersion = cgroup.Version
+	kernelVersion = osutil.KernelVersion
+
+	nftLookPath = func() error {
+		_, err := exec.LookPath("nft")
+		return err
+	}
+	nftLoad = func(script []byte) error {
+		cmd := exec.Command("nft", "-f", "-")
+		cmd.Stdin = bytes.NewReader(script)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return osutil.OutputErr(output, err)
+		}
+		return nil
+	}
+)
+
+// Limit is the network bandwidth limit of a cgroup and all of its
+// descendants. A zero bandwidth means no limit in that direction.
+type Limit struct {
+	// CgroupPath is the path of the cgroup relative to the root of the
+	// unified hierarchy, e.g. "snap.foo.slice".
+	CgroupPath string
+	// IngressBandwidth and EgressBandwidth are the maximum number of bytes
+	// per second received and sent.
+	IngressBandwidth uint64
+	EgressBandwidth  uint64
+}
+
+// parseKernelVersion returns the major and minor numbers of a kernel
+// release string such as "5.15.0-56-generic".
+func parseKernelVersion(release string) (major, minor int, err error) {
+	fields := strings.SplitN(release, ".", 3)
+	if len(fields) < 2 {
+		return 0, 0, fmt.Errorf("unexpected kernel release %q", release)
+	}
+	major, err = strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected kernel release %q", release)
+	}
+	minorStr := fields[1]
+	if idx := strings.IndexFunc(minorStr, func(r rune) bool { return r < '0' || r > '9' }); idx >= 0 {
+		minorStr = minorStr[:idx]
+	}
+	minor, err = strconv.Atoi(minorStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected kernel release %q", release)
+	}
+	return major, minor, nil
+}
+
+// CheckSupport returns an error if network limits cannot be enforced on
+// this system.
+func CheckSupport() error {
+	ver, err := cgroupVersion()
+	if err != nil {
+		return err
+	}
+	if ver != cgroup.V2 {
+		return fmt.Errorf("cgroup version %d is not supported", ver)
+	}
+
+	// matching sockets by their cgroup v2 is available since linux 5.7
+	major, minor, err := parseKernelVersion(kernelVersion())
+	if err != nil {
+		return err
+	}
+	if major < 5 || (major == 5 && minor < 7) {
+		return fmt.Errorf("kernel version %d.%d is too old, at least 5.7 is required", major, minor)
+	}
+
+	if err := nftLookPath(); err != nil {
+		return fmt.Errorf("nft is not available: %v", err)
+	}
+	return nil
+}
+
+// cgroupLevel returns the depth of the cgroup path in the hierarchy, as
+// expected by the socket cgroupv2 expression.
+func cgroupLevel(path string) int {
+	return len(strings.Split(strings.Trim(path, "/"), "/"))
+}
+
+func writeChain(buf *bytes.Buffer, name string, limits []Limit, bandwidth func(l *Limit) uint64) {
+	fmt.Fprintf(buf, "\tchain %s {\n", name)
+	fmt.Fprintf(buf, "\t\ttype filter hook %s priority filter; policy accept;\n", name)
+	for i := range limits {
+		l := &limits[i]
+		if bandwidth(l) == 0 {
+			continue
+		}
+		path := strings.Trim(l.CgroupPath, "/")
+		fmt.Fprintf(buf, "\t\tsocket cgroupv2 level %d %q limit rate over %d bytes/second drop\n",
+			cgroupLevel(path), path, bandwidth(l))
+	}
+	fmt.Fprintf(buf, "\t}\n")
+}
+
+// ruleset returns the nft script replacing the table with the rules for the
+// given limits. Without limits the table is just removed.
+func ruleset(limits []Limit) []byte {
+	buf := &bytes.Buffer{}
+	// declaring the table first makes the deletion work even when it does
+	// not exist yet
+	fmt.Fprintf(buf, "table inet %s\n", tableName)
+	fmt.Fprintf(buf, "delete table inet %s\n", tableName)
+	if len(limits) == 0 {
+		return buf.Bytes()
+	}
+
+	sorted := make([]Limit, len(limits))
+	copy(sorted, limits)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CgroupPath < sorted[j].CgroupPath })
+
+	fmt.Fprintf(buf, "table inet %s {\n", tableName)
+	writeChain(buf, "input", sorted, func(l *Limit) uint64 { return l.IngressBandwidth })
+	writeChain(buf, "output", sorted, func(l *Limit) uint64 { return l.EgressBandwidth })
+	fmt.Fprintf(buf, "}\n")
+	return buf.Bytes()
+}
+
+// Apply replaces the network limits currently enforced with the given
+// ones, atomically. The cgroups of the limits must exist, as nft resolves
+// them when loading the rules, which is also why the limits need applying
+// again when the cgroups are created anew.
+func Apply(limits []Limit) error {
+	if err := nftLoad(ruleset(limits)); err != nil {
+		return fmt.Errorf("cannot apply network limits: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netquota_test
+
+import (
+	"errors"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/sandbox/netquota"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type netquotaSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&netquotaSuite{})
+
+func (s *netquotaSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.AddCleanup(netquota.MockCgroupVersion(func() (int, error) { return cgroup.V2, nil }))
+	s.AddCleanup(netquota.MockKernelVersion(func() string { return "5.15.0-56-generic" }))
+	s.AddCleanup(netquota.MockNftLookPath(func() error { return nil }))
+	s.AddCleanup(netquota.MockNftLoad(func([]byte) error {
+		c.Fatalf("unexpected call to nft")
+		return nil
+	}))
+}
+
+func (s *netquotaSuite) TestParseKernelVersion(c *C) {
+	for _, t := range []struct {
+		release      string
+		major, minor int
+		err          string
+	}{
+		{"5.15.0-56-generic", 5, 15, ""},
+		{"5.7", 5, 7, ""},
+		{"6.1.0-rc3", 6, 1, ""},
+		{"4.19+", 4, 19, ""},
+		{"unknown", 0, 0, `unexpected kernel release "unknown"`},
+		{"x.1", 0, 0, `unexpected kernel release "x.1"`},
+		{"5.y", 0, 0, `unexpected kernel release "5.y"`},
+	} {
+		major, minor, err := netquota.ParseKernelVersion(t.release)
+		if t.err != "" {
+			c.Check(err, ErrorMatches, t.err, Commentf(t.release))
+			continue
+		}
+		c.Assert(err, IsNil, Commentf(t.release))
+		c.Check(major, Equals, t.major, Commentf(t.release))
+		c.Check(minor, Equals, t.minor, Commentf(t.release))
+	}
+}
+
+func (s *netquotaSuite) TestCheckSupport(c *C) {
+	c.Check(netquota.CheckSupport(), IsNil)
+
+	restore := netquota.MockKernelVersion(func() string { return "5.4.0-100-generic" })
+	c.Check(netquota.CheckSupport(), ErrorMatches, `kernel version 5.4 is too old, at least 5.7 is required`)
+	restore()
+
+	restore = netquota.MockNftLookPath(func() error { return errors.New("not found") })
+	c.Check(netquota.CheckSupport(), ErrorMatches, `nft is not available: not found`)
+	restore()
+
+	restore = netquota.MockCgroupVersion(func() (int, error) { return cgroup.V1, nil })
+	c.Check(netquota.CheckSupport(), ErrorMatches, `cgroup version 1 is not supported`)
+	restore()
+
+	restore = netquota.MockCgroupVersion(func() (int, error) { return 0, errors.New("cgroup error") })
+	c.Check(netquota.CheckSupport(), ErrorMatches, `cgroup error`)
+	restore()
+}
+
+func (s *netquotaSuite) TestApply(c *C) {
+	var scripts []string
+	restore := netquota.MockNftLoad(func(script []byte) error {
+		scripts = append(scripts, string(script))
+		return nil
+	})
+	defer restore()
+
+	err := netquota.Apply([]netquota.Limit{
+		{CgroupPath: "snap.foo.slice/snap.foo-bar.slice", EgressBandwidth: 1000},
+		{CgroupPath: "/snap.foo.slice", IngressBandwidth: 2000, EgressBandwidth: 3000},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(netquota.Apply(nil), IsNil)
+
+	c.Check(scripts, DeepEquals, []string{`table inet snapd-quotas
+delete table inet snapd-quotas
+table inet snapd-quotas {
+	chain input {
+		type filter hook input priority filter; policy accept;
+		socket cgroupv2 level 1 "snap.foo.slice" limit rate over 2000 bytes/second drop
+	}
+	chain output {
+		type filter hook output priority filter; policy accept;
+		socket cgroupv2 level 1 "snap.foo.slice" limit rate over 3000 bytes/second drop
+		socket cgroupv2 level 2 "snap.foo.slice/snap.foo-bar.slice" limit rate over 1000 bytes/second drop
+	}
+}
+`, `table inet snapd-quotas
+delete table inet snapd-quotas
+`})
+}
+
+func (s *netquotaSuite) TestApplyError(c *C) {
+	restore := netquota.MockNftLoad(func(script []byte) error {
+		return errors.New("Error: cgroupv2 path fails: No such file or directory")
+	})
+	defer restore()
+
+	err := netquota.Apply([]netquota.Limit{{CgroupPath: "snap.foo.slice", EgressBandwidth: 1000}})
+	c.Assert(err, ErrorMatches, `cannot apply network limits: Error: cgroupv2 path fails: No such file or directory`)
+}
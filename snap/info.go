@@ -962,6 +962,82 @@ type TimerInfo struct {
 	Timer string
 }
 
+// HealthProbeAction is the action taken when the health probe of a service
+// keeps failing.
+type HealthProbeAction string
+
+const (
+	// HealthProbeActionNone only reports the failure in the health of the
+	// snap.
+	HealthProbeActionNone HealthProbeAction = ""
+	// HealthProbeActionRestart also restarts the service.
+	HealthProbeActionRestart HealthProbeAction = "restart"
+)
+
+// HTTPProbe describes an HTTP GET request used as a health probe.
+type HTTPProbe struct {
+	// Address is a port, a host and port or the path of a unix socket,
+	// in the same format as the listen-stream of sockets.
+	Address string
+	// Path is the path requested.
+	Path string
+}
+
+// HealthProbeInfo provides information on the health probe of an
+// application. Exactly one of HTTP, TCP or Command is set.
+type HealthProbeInfo struct {
+	App *AppInfo
+
+	HTTP *HTTPProbe
+	// TCP is the address a connection is made to, a port or a host and
+	// port.
+	TCP string
+	// Command is run in the sandbox of the application and must exit
+	// with status 0.
+	Command string
+
+	// Interval is the time between two runs of the probe.
+	Interval timeout.Timeout
+	// Timeout is the time after which the probe is considered failed.
+	Timeout timeout.Timeout
+	// Threshold is the number of consecutive failures after which the
+	// application is considered unhealthy.
+	Threshold int
+	OnFailure HealthProbeAction
+}
+
+// Defaults for the health probes of applications.
+const (
+	DefaultHealthProbeInterval  = 30 * time.Second
+	DefaultHealthProbeTimeout   = 10 * time.Second
+	DefaultHealthProbeThreshold = 3
+)
+
+// EffectiveInterval returns the interval of the probe, or the default.
+func (probe *HealthProbeInfo) EffectiveInterval() time.Duration {
+	if probe.Interval == 0 {
+		return DefaultHealthProbeInterval
+	}
+	return time.Duration(probe.Interval)
+}
+
+// EffectiveTimeout returns the timeout of the probe, or the default.
+func (probe *HealthProbeInfo) EffectiveTimeout() time.Duration {
+	if probe.Timeout == 0 {
+		return DefaultHealthProbeTimeout
+	}
+	return time.Duration(probe.Timeout)
+}
+
+// EffectiveThreshold returns the failure threshold of the probe, or the
+// default.
+func (probe *HealthProbeInfo) EffectiveThreshold() int {
+	if probe.Threshold == 0 {
+		return DefaultHealthProbeThreshold
+	}
+	return probe.Threshold
+}
+
 // StopModeType is the type for the "stop-mode:" of a snap app
 type StopModeType string
 
@@ -1037,6 +1113,8 @@ type AppInfo struct {
 
 	Timer *TimerInfo
 
+	HealthProbe *HealthProbeInfo
+
 	Autostart string
 }
 
@@ -109,6 +109,8 @@ type appYaml struct {
 
 	Timer string `yaml:"timer,omitempty"`
 
+	HealthProbe *healthProbeYaml `yaml:"health-probe,omitempty"`
+
 	Autostart string `yaml:"autostart,omitempty"`
 }
 
@@ -129,6 +131,21 @@ type layoutYaml struct {
 	Symlink  string `yaml:"symlink,omitempty"`
 }
 
+type httpProbeYaml struct {
+	Address string `yaml:"address,omitempty"`
+	Path    string `yaml:"path,omitempty"`
+}
+
+type healthProbeYaml struct {
+	HTTP      *httpProbeYaml    `yaml:"http,omitempty"`
+	TCP       string            `yaml:"tcp,omitempty"`
+	Command   string            `yaml:"command,omitempty"`
+	Interval  timeout.Timeout   `yaml:"interval,omitempty"`
+	Timeout   timeout.Timeout   `yaml:"timeout,omitempty"`
+	Threshold int               `yaml:"threshold,omitempty"`
+	OnFailure HealthProbeAction `yaml:"on-failure,omitempty"`
+}
+
 type socketsYaml struct {
 	ListenStream string      `yaml:"listen-stream,omitempty"`
 	SocketMode   os.FileMode `yaml:"socket-mode,omitempty"`
@@ -468,6 +485,23 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) error {
 				Timer: yApp.Timer,
 			}
 		}
+		if yProbe := yApp.HealthProbe; yProbe != nil {
+			app.HealthProbe = &HealthProbeInfo{
+				App:       app,
+				TCP:       yProbe.TCP,
+				Command:   yProbe.Command,
+				Interval:  yProbe.Interval,
+				Timeout:   yProbe.Timeout,
+				Threshold: yProbe.Threshold,
+				OnFailure: yProbe.OnFailure,
+			}
+			if yProbe.HTTP != nil {
+				app.HealthProbe.HTTP = &HTTPProbe{
+					Address: yProbe.HTTP.Address,
+					Path:    yProbe.HTTP.Path,
+				}
+			}
+		}
 		// collect all common IDs
 		if app.CommonID != "" {
 			snap.CommonIDs = append(snap.CommonIDs, app.CommonID)
@@ -1867,6 +1867,50 @@ apps:
 	c.Check(app.Timer, DeepEquals, &snap.TimerInfo{App: app, Timer: "mon,10:00-12:00"})
 }
 
+func (s *YamlSuite) TestSnapYamlAppHealthProbe(c *C) {
+	y := []byte(`name: wat
+version: 42
+apps:
+ foo:
+   daemon: simple
+   health-probe:
+     http:
+       address: 8080
+       path: /healthz
+     interval: 10s
+     timeout: 1s
+     threshold: 5
+     on-failure: restart
+ bar:
+   daemon: simple
+   health-probe:
+     command: bin/check
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	app := info.Apps["foo"]
+	c.Check(app.HealthProbe, DeepEquals, &snap.HealthProbeInfo{
+		App:       app,
+		HTTP:      &snap.HTTPProbe{Address: "8080", Path: "/healthz"},
+		Interval:  timeout.Timeout(10 * time.Second),
+		Timeout:   timeout.Timeout(time.Second),
+		Threshold: 5,
+		OnFailure: snap.HealthProbeActionRestart,
+	})
+	c.Check(app.HealthProbe.EffectiveInterval(), Equals, 10*time.Second)
+	c.Check(app.HealthProbe.EffectiveTimeout(), Equals, time.Second)
+	c.Check(app.HealthProbe.EffectiveThreshold(), Equals, 5)
+
+	app = info.Apps["bar"]
+	c.Check(app.HealthProbe, DeepEquals, &snap.HealthProbeInfo{
+		App:     app,
+		Command: "bin/check",
+	})
+	c.Check(app.HealthProbe.EffectiveInterval(), Equals, 30*time.Second)
+	c.Check(app.HealthProbe.EffectiveTimeout(), Equals, 10*time.Second)
+	c.Check(app.HealthProbe.EffectiveThreshold(), Equals, 3)
+}
+
 func (s *YamlSuite) TestSnapYamlAppAutostart(c *C) {
 	yAutostart := []byte(`name: wat
 version: 42
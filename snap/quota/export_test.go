@@ -68,3 +68,9 @@ func MockRuntimeNumCPU(mock func() int) (restore func()) {
 	runtimeNumCPU = mock
 	return r
 }
+
+func MockNetquotaCheckSupport(f func() error) (restore func()) {
+	r := testutil.Backup(&netquotaCheckSupport)
+	netquotaCheckSupport = f
+	return r
+}
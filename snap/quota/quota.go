@@ -83,6 +83,26 @@ type GroupQuotaJournal struct {
 	RatePeriod time.Duration `json:"rate-period,omitempty"`
 }
 
+// GroupQuotaIO contains the block I/O limits for the group. The limits apply to
+// the device backing the snap data directories. A zero value in any of the
+// fields means no limit. Sub-groups are additionally bounded by the limits of
+// their parents by the kernel.
+type GroupQuotaIO struct {
+	ReadBandwidth  quantity.Size `json:"read-bandwidth,omitempty"`
+	WriteBandwidth quantity.Size `json:"write-bandwidth,omitempty"`
+	ReadIOPS       int           `json:"read-iops,omitempty"`
+	WriteIOPS      int           `json:"write-iops,omitempty"`
+	Weight         int           `json:"weight,omitempty"`
+}
+
+// GroupQuotaNetwork contains the network bandwidth limits for the group. A
+// zero value in either of the fields means no limit. The traffic of
+// sub-groups is accounted to their parents as well.
+type GroupQuotaNetwork struct {
+	IngressBandwidth quantity.Size `json:"ingress-bandwidth,omitempty"`
+	EgressBandwidth  quantity.Size `json:"egress-bandwidth,omitempty"`
+}
+
 // Group is a quota group of snaps, services or sub-groups that are all subject
 // to specific resource quotas. The only quota resource types currently
 // supported is memory, but this can be expanded in the future.
@@ -124,6 +144,28 @@ type Group struct {
 	// journald.
 	JournalLimit *GroupQuotaJournal `json:"journal-limit,omitempty"`
 
+	// IOLimit is the limits that apply to the block I/O done by the
+	// processes in the group.
+	IOLimit *GroupQuotaIO `json:"io-limit,omitempty"`
+
+	// NetworkLimit is the limits that apply to the network traffic of the
+	// processes in the group.
+	NetworkLimit *GroupQuotaNetwork `json:"network-limit,omitempty"`
+
+	// MemoryHighLimit is the soft limit of memory for the processes in the
+	// group. Above it the processes are throttled and their memory is
+	// reclaimed aggressively, but the oom-killer is not invoked.
+	MemoryHighLimit quantity.Size `json:"memory-high-limit,omitempty"`
+
+	// MemorySwapLimit is the maximum amount of swap the processes in the
+	// group may use. It is a pointer as a limit of zero disables swap.
+	MemorySwapLimit *quantity.Size `json:"memory-swap-limit,omitempty"`
+
+	// OOMPolicy is the action taken on the services of the group when the
+	// oom-killer kills one of their processes. When unset, the policy of the
+	// parent group applies.
+	OOMPolicy OOMPolicy `json:"oom-policy,omitempty"`
+
 	// ParentGroup is the the parent group that this group is a child of. If it
 	// is empty, then this is a "root" quota group.
 	ParentGroup string `json:"parent-group,omitempty"`
@@ -192,6 +234,40 @@ func (grp *Group) GetQuotaResources() Resources {
 			resourcesBuilder.WithJournalRate(grp.JournalLimit.RateCount, grp.JournalLimit.RatePeriod)
 		}
 	}
+	if grp.IOLimit != nil {
+		if grp.IOLimit.ReadBandwidth != 0 {
+			resourcesBuilder.WithIOReadBandwidth(grp.IOLimit.ReadBandwidth)
+		}
+		if grp.IOLimit.WriteBandwidth != 0 {
+			resourcesBuilder.WithIOWriteBandwidth(grp.IOLimit.WriteBandwidth)
+		}
+		if grp.IOLimit.ReadIOPS != 0 {
+			resourcesBuilder.WithIOReadIOPS(grp.IOLimit.ReadIOPS)
+		}
+		if grp.IOLimit.WriteIOPS != 0 {
+			resourcesBuilder.WithIOWriteIOPS(grp.IOLimit.WriteIOPS)
+		}
+		if grp.IOLimit.Weight != 0 {
+			resourcesBuilder.WithIOWeight(grp.IOLimit.Weight)
+		}
+	}
+	if grp.NetworkLimit != nil {
+		if grp.NetworkLimit.IngressBandwidth != 0 {
+			resourcesBuilder.WithNetworkIngressBandwidth(grp.NetworkLimit.IngressBandwidth)
+		}
+		if grp.NetworkLimit.EgressBandwidth != 0 {
+			resourcesBuilder.WithNetworkEgressBandwidth(grp.NetworkLimit.EgressBandwidth)
+		}
+	}
+	if grp.MemoryHighLimit != 0 {
+		resourcesBuilder.WithMemoryHighLimit(grp.MemoryHighLimit)
+	}
+	if grp.MemorySwapLimit != nil {
+		resourcesBuilder.WithMemorySwapLimit(*grp.MemorySwapLimit)
+	}
+	if grp.OOMPolicy != "" {
+		resourcesBuilder.WithOOMPolicy(grp.OOMPolicy)
+	}
 	return resourcesBuilder.Build()
 }
 
@@ -409,6 +485,18 @@ func (grp *Group) GetCPUSetQuota() []int {
 	return nil
 }
 
+// GetOOMPolicy returns the OOM policy in effect for the services of this
+// group, which includes the case where the policy is inherited from a parent
+// group. An empty policy is returned if none of the groups has one set.
+func (grp *Group) GetOOMPolicy() OOMPolicy {
+	for g := grp; g != nil; g = g.parentGroup {
+		if g.OOMPolicy != "" {
+			return g.OOMPolicy
+		}
+	}
+	return ""
+}
+
 // GetLocalCPUQuota returns the final calculated count and percentage of the
 // current CPU quota for the group. This does not return any inherited CPU quota, but
 // it does take any inherited CPU set into account to adjust in the case of a relative
@@ -764,6 +852,14 @@ func (grp *Group) UpdateQuotaLimits(resourceLimits Resources) error {
 		return err
 	}
 
+	// some limits are validated against others, which may already be set
+	// for the group, so validate the limits resulting from the update
+	resultingLimits := currentLimits.clone()
+	resultingLimits.changeInternal(resourceLimits)
+	if err := resultingLimits.Validate(); err != nil {
+		return err
+	}
+
 	if err := grp.validateQuotasFit(resourceLimits); err != nil {
 		return err
 	}
@@ -799,9 +895,74 @@ func (grp *Group) UpdateQuotaLimits(resourceLimits Resources) error {
 			grp.JournalLimit.RatePeriod = resourceLimits.Journal.Rate.Period
 		}
 	}
+	if resourceLimits.IO != nil {
+		grp.IOLimit = updateIOLimit(grp.IOLimit, resourceLimits.IO)
+	}
+	if resourceLimits.Network != nil {
+		grp.NetworkLimit = updateNetworkLimit(grp.NetworkLimit, resourceLimits.Network)
+	}
+	if resourceLimits.MemoryHigh != nil {
+		grp.MemoryHighLimit = resourceLimits.MemoryHigh.Limit
+	}
+	if resourceLimits.MemorySwap != nil {
+		swapLimit := resourceLimits.MemorySwap.Limit
+		grp.MemorySwapLimit = &swapLimit
+	}
+	if resourceLimits.OOMPolicy != nil {
+		grp.OOMPolicy = resourceLimits.OOMPolicy.Policy
+	}
 	return nil
 }
 
+// updateIOLimit returns the io limit resulting from applying the given limits
+// on top of current, which may be nil. It returns nil if all the io limits
+// end up removed.
+func updateIOLimit(current *GroupQuotaIO, limits *ResourceIO) *GroupQuotaIO {
+	var updated GroupQuotaIO
+	if current != nil {
+		updated = *current
+	}
+	if limits.ReadBandwidth != nil {
+		updated.ReadBandwidth = *limits.ReadBandwidth
+	}
+	if limits.WriteBandwidth != nil {
+		updated.WriteBandwidth = *limits.WriteBandwidth
+	}
+	if limits.ReadIOPS != nil {
+		updated.ReadIOPS = *limits.ReadIOPS
+	}
+	if limits.WriteIOPS != nil {
+		updated.WriteIOPS = *limits.WriteIOPS
+	}
+	if limits.Weight != nil {
+		updated.Weight = *limits.Weight
+	}
+	if updated == (GroupQuotaIO{}) {
+		return nil
+	}
+	return &updated
+}
+
+// updateNetworkLimit returns the network limit resulting from applying the
+// given limits on top of current, which may be nil. It returns nil if all the
+// network limits end up removed.
+func updateNetworkLimit(current *GroupQuotaNetwork, limits *ResourceNetwork) *GroupQuotaNetwork {
+	var updated GroupQuotaNetwork
+	if current != nil {
+		updated = *current
+	}
+	if limits.IngressBandwidth != nil {
+		updated.IngressBandwidth = *limits.IngressBandwidth
+	}
+	if limits.EgressBandwidth != nil {
+		updated.EgressBandwidth = *limits.EgressBandwidth
+	}
+	if updated == (GroupQuotaNetwork{}) {
+		return nil
+	}
+	return &updated
+}
+
 func (grp *Group) validate() error {
 	if err := naming.ValidateQuotaGroup(grp.Name); err != nil {
 		return err
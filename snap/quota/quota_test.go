@@ -1569,6 +1569,115 @@ func (ts *quotaTestSuite) TestJournalQuotasUpdatesCorrectly(c *C) {
 	c.Check(grp1.JournalLimit.RatePeriod, Equals, time.Microsecond*5)
 }
 
+func (ts *quotaTestSuite) TestIOQuotasUpdatesCorrectly(c *C) {
+	grp1, err := quota.NewGroup("groot1", quota.NewResourcesBuilder().WithIOReadBandwidth(10*quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Assert(grp1.IOLimit, NotNil)
+	c.Check(grp1.IOLimit, DeepEquals, &quota.GroupQuotaIO{ReadBandwidth: 10 * quantity.SizeMiB})
+
+	// limits not given are kept
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithIOWriteIOPS(100).WithIOWeight(50).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.IOLimit, DeepEquals, &quota.GroupQuotaIO{
+		ReadBandwidth: 10 * quantity.SizeMiB,
+		WriteIOPS:     100,
+		Weight:        50,
+	})
+	c.Check(grp1.GetQuotaResources(), DeepEquals, quota.NewResourcesBuilder().
+		WithIOReadBandwidth(10*quantity.SizeMiB).WithIOWriteIOPS(100).WithIOWeight(50).Build())
+
+	// a zero limit removes it
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithIOReadBandwidth(0).WithIOWeight(0).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.IOLimit, DeepEquals, &quota.GroupQuotaIO{WriteIOPS: 100})
+
+	// and the io limit is gone once all of them are removed
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).WithIOWriteIOPS(0).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.IOLimit, IsNil)
+	c.Check(grp1.GetQuotaResources(), DeepEquals, quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build())
+}
+
+func (ts *quotaTestSuite) TestNetworkQuotasUpdatesCorrectly(c *C) {
+	grp1, err := quota.NewGroup("groot1", quota.NewResourcesBuilder().WithNetworkEgressBandwidth(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.NetworkLimit, DeepEquals, &quota.GroupQuotaNetwork{EgressBandwidth: quantity.SizeMiB})
+
+	// limits not given are kept
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithNetworkIngressBandwidth(2 * quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.NetworkLimit, DeepEquals, &quota.GroupQuotaNetwork{
+		IngressBandwidth: 2 * quantity.SizeMiB,
+		EgressBandwidth:  quantity.SizeMiB,
+	})
+	c.Check(grp1.GetQuotaResources(), DeepEquals, quota.NewResourcesBuilder().
+		WithNetworkIngressBandwidth(2*quantity.SizeMiB).WithNetworkEgressBandwidth(quantity.SizeMiB).Build())
+
+	// a zero limit removes it
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithNetworkEgressBandwidth(0).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.NetworkLimit, DeepEquals, &quota.GroupQuotaNetwork{IngressBandwidth: 2 * quantity.SizeMiB})
+
+	// and the network limit is gone once all of them are removed
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithThreadLimit(32).WithNetworkIngressBandwidth(0).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.NetworkLimit, IsNil)
+}
+
+func (ts *quotaTestSuite) TestMemoryPressureQuotasUpdatesCorrectly(c *C) {
+	grp1, err := quota.NewGroup("groot1", quota.NewResourcesBuilder().
+		WithMemoryLimit(quantity.SizeGiB).WithMemoryHighLimit(512*quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.MemoryHighLimit, Equals, 512*quantity.SizeMiB)
+	c.Check(grp1.MemorySwapLimit, IsNil)
+	c.Check(grp1.OOMPolicy, Equals, quota.OOMPolicy(""))
+
+	// disabling swap is kept apart from having no swap limit
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().
+		WithMemorySwapLimit(0).WithOOMPolicy(quota.OOMPolicyRestartService).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.MemoryHighLimit, Equals, 512*quantity.SizeMiB)
+	c.Assert(grp1.MemorySwapLimit, NotNil)
+	c.Check(*grp1.MemorySwapLimit, Equals, quantity.Size(0))
+	c.Check(grp1.OOMPolicy, Equals, quota.OOMPolicyRestartService)
+	c.Check(grp1.GetQuotaResources(), DeepEquals, quota.NewResourcesBuilder().
+		WithMemoryLimit(quantity.SizeGiB).WithMemoryHighLimit(512*quantity.SizeMiB).
+		WithMemorySwapLimit(0).WithOOMPolicy(quota.OOMPolicyRestartService).Build())
+}
+
+func (ts *quotaTestSuite) TestMemoryHighLimitValidatedAgainstMemoryLimit(c *C) {
+	grp1, err := quota.NewGroup("groot1", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+
+	// the memory limit of the group is taken into account even if it is
+	// not part of the update
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithMemoryHighLimit(2 * quantity.SizeGiB).Build())
+	c.Assert(err, ErrorMatches, `memory high limit 2147483648 must be lower than the memory limit 1073741824`)
+	c.Check(grp1.MemoryHighLimit, Equals, quantity.Size(0))
+
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithMemoryHighLimit(512 * quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.MemoryHighLimit, Equals, 512*quantity.SizeMiB)
+}
+
+func (ts *quotaTestSuite) TestGetOOMPolicyInherited(c *C) {
+	grp1, err := quota.NewGroup("groot1", quota.NewResourcesBuilder().
+		WithMemoryLimit(quantity.SizeGiB).WithOOMPolicy(quota.OOMPolicyKillGroup).Build())
+	c.Assert(err, IsNil)
+	sub, err := grp1.NewSubGroup("sub", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(sub.GetOOMPolicy(), Equals, quota.OOMPolicyKillGroup)
+
+	err = sub.UpdateQuotaLimits(quota.NewResourcesBuilder().WithOOMPolicy(quota.OOMPolicyKillOne).Build())
+	c.Assert(err, IsNil)
+	c.Check(sub.GetOOMPolicy(), Equals, quota.OOMPolicyKillOne)
+	c.Check(grp1.GetOOMPolicy(), Equals, quota.OOMPolicyKillGroup)
+
+	grp2, err := quota.NewGroup("groot2", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp2.GetOOMPolicy(), Equals, quota.OOMPolicy(""))
+}
+
 func (ts *quotaTestSuite) TestServiceMapEmptyOnEmptyGroup(c *C) {
 	rootGrp, err := quota.NewGroup("myroot", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build())
 	c.Assert(err, IsNil)
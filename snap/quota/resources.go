@@ -25,6 +25,7 @@ import (
 
 	"github.com/snapcore/snapd/gadget/quantity"
 	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/sandbox/netquota"
 )
 
 var (
@@ -32,6 +33,8 @@ var (
 	cgroupVerErr error
 
 	cgroupCheckMemoryCgroupErr error
+
+	netquotaCheckSupport = netquota.CheckSupport
 )
 
 func init() {
@@ -75,6 +78,69 @@ type ResourceJournal struct {
 	Rate *ResourceJournalRate `json:"rate,omitempty"`
 }
 
+// ResourceIO represents the block I/O quotas. A nil value in any of the
+// fields means that no limit is set for it, but at least one of them must be
+// given. When changing the limits of a group, nil fields are left unchanged
+// and a zero value removes the limit.
+type ResourceIO struct {
+	// ReadBandwidth and WriteBandwidth are the maximum number of bytes per
+	// second that may be read or written.
+	ReadBandwidth  *quantity.Size `json:"read-bandwidth,omitempty"`
+	WriteBandwidth *quantity.Size `json:"write-bandwidth,omitempty"`
+	// ReadIOPS and WriteIOPS are the maximum number of read or write
+	// operations per second.
+	ReadIOPS  *int `json:"read-iops,omitempty"`
+	WriteIOPS *int `json:"write-iops,omitempty"`
+	// Weight is the relative share of I/O the group gets when the device is
+	// contended.
+	Weight *int `json:"weight,omitempty"`
+}
+
+// ResourceNetwork represents the network bandwidth quotas. A nil value in
+// either of the fields means that no limit is set for it, but at least one of
+// them must be given. When changing the limits of a group, nil fields are left
+// unchanged and a zero value removes the limit.
+type ResourceNetwork struct {
+	// IngressBandwidth and EgressBandwidth are the maximum number of bytes
+	// per second that may be received or sent.
+	IngressBandwidth *quantity.Size `json:"ingress-bandwidth,omitempty"`
+	EgressBandwidth  *quantity.Size `json:"egress-bandwidth,omitempty"`
+}
+
+// ResourceMemoryHigh represents the soft memory limit of a group. When the
+// processes of the group go over it, they are throttled and the kernel
+// reclaims their memory aggressively, but they are not killed.
+type ResourceMemoryHigh struct {
+	Limit quantity.Size `json:"limit"`
+}
+
+// ResourceMemorySwap represents the maximum amount of swap the processes of a
+// group may use. A zero limit disables swapping for the group.
+type ResourceMemorySwap struct {
+	Limit quantity.Size `json:"limit"`
+}
+
+// OOMPolicy is the action taken on the services of a group when the kernel
+// OOM killer kills one of their processes.
+type OOMPolicy string
+
+const (
+	// OOMPolicyKillOne only kills the process chosen by the OOM killer,
+	// the rest of the service is left running.
+	OOMPolicyKillOne OOMPolicy = "kill-one"
+	// OOMPolicyKillGroup kills all the processes of the service the killed
+	// process belongs to.
+	OOMPolicyKillGroup OOMPolicy = "kill-group"
+	// OOMPolicyRestartService stops the service the killed process belongs
+	// to and restarts it.
+	OOMPolicyRestartService OOMPolicy = "restart-service"
+)
+
+// ResourceOOMPolicy represents the OOM policy of the services of a group.
+type ResourceOOMPolicy struct {
+	Policy OOMPolicy `json:"policy"`
+}
+
 // Resources are built up of multiple quota limits. Each quota limit is a pointer
 // value to indicate that their presence may be optional, and because we want to detect
 // whenever someone changes a limit to '0' explicitly.
@@ -84,6 +150,12 @@ type Resources struct {
 	CPUSet  *ResourceCPUSet  `json:"cpu-set,omitempty"`
 	Threads *ResourceThreads `json:"thread,omitempty"`
 	Journal *ResourceJournal `json:"journal,omitempty"`
+	IO      *ResourceIO      `json:"io,omitempty"`
+	Network *ResourceNetwork `json:"network,omitempty"`
+
+	MemoryHigh *ResourceMemoryHigh `json:"memory-high,omitempty"`
+	MemorySwap *ResourceMemorySwap `json:"memory-swap,omitempty"`
+	OOMPolicy  *ResourceOOMPolicy  `json:"oom-policy,omitempty"`
 }
 
 const (
@@ -99,6 +171,10 @@ const (
 	// usage, but we have selected 64kB to protect against ridiculously small values.
 	journalLimitMin = 64 * quantity.SizeKiB
 	journalLimitMax = 4 * quantity.SizeGiB
+
+	// The range of weights accepted by systemd for IOWeight.
+	ioWeightMin = 1
+	ioWeightMax = 10000
 )
 
 func (qr *Resources) validateMemoryQuota() error {
@@ -186,6 +262,49 @@ func (qr *Resources) validateJournalQuota() error {
 	return nil
 }
 
+func (qr *Resources) validateIOQuota() error {
+	if qr.IO.ReadBandwidth == nil && qr.IO.WriteBandwidth == nil &&
+		qr.IO.ReadIOPS == nil && qr.IO.WriteIOPS == nil && qr.IO.Weight == nil {
+		return fmt.Errorf("io quota must have at least one limit set")
+	}
+	if (qr.IO.ReadIOPS != nil && *qr.IO.ReadIOPS < 0) || (qr.IO.WriteIOPS != nil && *qr.IO.WriteIOPS < 0) {
+		return fmt.Errorf("invalid io quota with a negative iops limit")
+	}
+	if qr.IO.Weight != nil && *qr.IO.Weight != 0 && (*qr.IO.Weight < ioWeightMin || *qr.IO.Weight > ioWeightMax) {
+		return fmt.Errorf("invalid io weight %d: weight must be between %d and %d",
+			*qr.IO.Weight, ioWeightMin, ioWeightMax)
+	}
+	return nil
+}
+
+func (qr *Resources) validateNetworkQuota() error {
+	if qr.Network.IngressBandwidth == nil && qr.Network.EgressBandwidth == nil {
+		return fmt.Errorf("network quota must have at least one limit set")
+	}
+	return nil
+}
+
+func (qr *Resources) validateMemoryHighQuota() error {
+	if qr.MemoryHigh.Limit <= memoryLimitMin {
+		return fmt.Errorf("memory high limit %d is too small: size must be larger than %s",
+			qr.MemoryHigh.Limit, memoryLimitMin.IECString())
+	}
+	if qr.Memory != nil && qr.MemoryHigh.Limit >= qr.Memory.Limit {
+		return fmt.Errorf("memory high limit %d must be lower than the memory limit %d",
+			qr.MemoryHigh.Limit, qr.Memory.Limit)
+	}
+	return nil
+}
+
+func (qr *Resources) validateOOMPolicyQuota() error {
+	switch qr.OOMPolicy.Policy {
+	case OOMPolicyKillOne, OOMPolicyKillGroup, OOMPolicyRestartService:
+		return nil
+	}
+	return fmt.Errorf("invalid oom policy %q: must be one of %q, %q or %q", qr.OOMPolicy.Policy,
+		OOMPolicyKillOne, OOMPolicyKillGroup, OOMPolicyRestartService)
+}
+
 // CheckFeatureRequirements checks if the current system meets the
 // requirements for the given resource request.
 //
@@ -199,6 +318,42 @@ func (qr *Resources) CheckFeatureRequirements() error {
 			return fmt.Errorf("cannot use CPU set with cgroup version %d", cgroupVer)
 		}
 	}
+	// the io controller is only available with cgroup v2, systemd ignores
+	// the IO* settings on v1
+	if qr.IO != nil {
+		if cgroupVerErr != nil {
+			return cgroupVerErr
+		}
+		if cgroupVer < 2 {
+			return fmt.Errorf("cannot use io quota with cgroup version %d", cgroupVer)
+		}
+	}
+	// MemoryHigh and MemorySwapMax are only supported by the unified
+	// hierarchy
+	if qr.MemoryHigh != nil {
+		if cgroupVerErr != nil {
+			return cgroupVerErr
+		}
+		if cgroupVer < 2 {
+			return fmt.Errorf("cannot use memory high limit with cgroup version %d", cgroupVer)
+		}
+	}
+	if qr.MemorySwap != nil {
+		if cgroupVerErr != nil {
+			return cgroupVerErr
+		}
+		if cgroupVer < 2 {
+			return fmt.Errorf("cannot use memory swap limit with cgroup version %d", cgroupVer)
+		}
+	}
+	if (qr.MemoryHigh != nil || qr.MemorySwap != nil) && cgroupCheckMemoryCgroupErr != nil {
+		return fmt.Errorf("cannot use memory quota: %v", cgroupCheckMemoryCgroupErr)
+	}
+	if qr.Network != nil {
+		if err := netquotaCheckSupport(); err != nil {
+			return fmt.Errorf("cannot use network quota: %v", err)
+		}
+	}
 	if qr.Memory != nil && cgroupCheckMemoryCgroupErr != nil {
 		return fmt.Errorf("cannot use memory quota: %v", cgroupCheckMemoryCgroupErr)
 	}
@@ -251,6 +406,30 @@ func (qr *Resources) Validate() error {
 			return err
 		}
 	}
+
+	if qr.IO != nil {
+		if err := qr.validateIOQuota(); err != nil {
+			return err
+		}
+	}
+
+	if qr.Network != nil {
+		if err := qr.validateNetworkQuota(); err != nil {
+			return err
+		}
+	}
+
+	if qr.MemoryHigh != nil {
+		if err := qr.validateMemoryHighQuota(); err != nil {
+			return err
+		}
+	}
+
+	if qr.OOMPolicy != nil {
+		if err := qr.validateOOMPolicyQuota(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -347,6 +526,12 @@ func (qr *Resources) ValidateChange(newLimits Resources) error {
 		// rate-limit for the group, overriding the journal default which is 10000/30s
 	}
 
+	// The memory high limit may be changed freely, as the processes are
+	// only throttled when going over it, but it cannot be removed
+	if qr.MemoryHigh != nil && newLimits.MemoryHigh != nil && newLimits.MemoryHigh.Limit == 0 {
+		return fmt.Errorf("cannot remove memory high limit from quota group")
+	}
+
 	return nil
 }
 
@@ -374,6 +559,23 @@ func (qr *Resources) clone() Resources {
 			resourcesCopy.Journal.Rate = &ResourceJournalRate{Count: qr.Journal.Rate.Count, Period: qr.Journal.Rate.Period}
 		}
 	}
+	if qr.IO != nil {
+		ioCopy := *qr.IO
+		resourcesCopy.IO = &ioCopy
+	}
+	if qr.Network != nil {
+		networkCopy := *qr.Network
+		resourcesCopy.Network = &networkCopy
+	}
+	if qr.MemoryHigh != nil {
+		resourcesCopy.MemoryHigh = &ResourceMemoryHigh{Limit: qr.MemoryHigh.Limit}
+	}
+	if qr.MemorySwap != nil {
+		resourcesCopy.MemorySwap = &ResourceMemorySwap{Limit: qr.MemorySwap.Limit}
+	}
+	if qr.OOMPolicy != nil {
+		resourcesCopy.OOMPolicy = &ResourceOOMPolicy{Policy: qr.OOMPolicy.Policy}
+	}
 	return resourcesCopy
 }
 
@@ -402,6 +604,62 @@ func (qr *Resources) changeInternal(newLimits Resources) {
 			qr.Journal.Rate = newLimits.Journal.Rate
 		}
 	}
+	if newLimits.IO != nil {
+		qr.IO = mergeIOLimits(qr.IO, newLimits.IO)
+	}
+	if newLimits.Network != nil {
+		qr.Network = mergeNetworkLimits(qr.Network, newLimits.Network)
+	}
+	if newLimits.MemoryHigh != nil {
+		qr.MemoryHigh = newLimits.MemoryHigh
+	}
+	if newLimits.MemorySwap != nil {
+		qr.MemorySwap = newLimits.MemorySwap
+	}
+	if newLimits.OOMPolicy != nil {
+		qr.OOMPolicy = newLimits.OOMPolicy
+	}
+}
+
+// mergeIOLimits returns the io limits resulting from applying the limits
+// given in newLimits on top of current, which may be nil.
+func mergeIOLimits(current, newLimits *ResourceIO) *ResourceIO {
+	merged := &ResourceIO{}
+	if current != nil {
+		*merged = *current
+	}
+	if newLimits.ReadBandwidth != nil {
+		merged.ReadBandwidth = newLimits.ReadBandwidth
+	}
+	if newLimits.WriteBandwidth != nil {
+		merged.WriteBandwidth = newLimits.WriteBandwidth
+	}
+	if newLimits.ReadIOPS != nil {
+		merged.ReadIOPS = newLimits.ReadIOPS
+	}
+	if newLimits.WriteIOPS != nil {
+		merged.WriteIOPS = newLimits.WriteIOPS
+	}
+	if newLimits.Weight != nil {
+		merged.Weight = newLimits.Weight
+	}
+	return merged
+}
+
+// mergeNetworkLimits returns the network limits resulting from applying the
+// limits given in newLimits on top of current, which may be nil.
+func mergeNetworkLimits(current, newLimits *ResourceNetwork) *ResourceNetwork {
+	merged := &ResourceNetwork{}
+	if current != nil {
+		*merged = *current
+	}
+	if newLimits.IngressBandwidth != nil {
+		merged.IngressBandwidth = newLimits.IngressBandwidth
+	}
+	if newLimits.EgressBandwidth != nil {
+		merged.EgressBandwidth = newLimits.EgressBandwidth
+	}
+	return merged
 }
 
 // Change updates the current quota limits with the new limits. Additional verification
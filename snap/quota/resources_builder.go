@@ -49,6 +49,36 @@ type ResourcesBuilder struct {
 	JournalRateCountLimit  int
 	JournalRatePeriodLimit time.Duration
 	JournalRateSet         bool
+
+	IOReadBandwidth    quantity.Size
+	IOReadBandwidthSet bool
+
+	IOWriteBandwidth    quantity.Size
+	IOWriteBandwidthSet bool
+
+	IOReadIOPS    int
+	IOReadIOPSSet bool
+
+	IOWriteIOPS    int
+	IOWriteIOPSSet bool
+
+	IOWeight    int
+	IOWeightSet bool
+
+	NetworkIngressBandwidth    quantity.Size
+	NetworkIngressBandwidthSet bool
+
+	NetworkEgressBandwidth    quantity.Size
+	NetworkEgressBandwidthSet bool
+
+	MemoryHighLimit    quantity.Size
+	MemoryHighLimitSet bool
+
+	MemorySwapLimit    quantity.Size
+	MemorySwapLimitSet bool
+
+	OOMPolicy    OOMPolicy
+	OOMPolicySet bool
 }
 
 func (rb *ResourcesBuilder) WithMemoryLimit(limit quantity.Size) *ResourcesBuilder {
@@ -99,6 +129,66 @@ func (rb *ResourcesBuilder) WithJournalRate(count int, period time.Duration) *Re
 	return rb
 }
 
+func (rb *ResourcesBuilder) WithIOReadBandwidth(limit quantity.Size) *ResourcesBuilder {
+	rb.IOReadBandwidth = limit
+	rb.IOReadBandwidthSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithIOWriteBandwidth(limit quantity.Size) *ResourcesBuilder {
+	rb.IOWriteBandwidth = limit
+	rb.IOWriteBandwidthSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithIOReadIOPS(limit int) *ResourcesBuilder {
+	rb.IOReadIOPS = limit
+	rb.IOReadIOPSSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithIOWriteIOPS(limit int) *ResourcesBuilder {
+	rb.IOWriteIOPS = limit
+	rb.IOWriteIOPSSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithIOWeight(weight int) *ResourcesBuilder {
+	rb.IOWeight = weight
+	rb.IOWeightSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithNetworkIngressBandwidth(limit quantity.Size) *ResourcesBuilder {
+	rb.NetworkIngressBandwidth = limit
+	rb.NetworkIngressBandwidthSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithNetworkEgressBandwidth(limit quantity.Size) *ResourcesBuilder {
+	rb.NetworkEgressBandwidth = limit
+	rb.NetworkEgressBandwidthSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithMemoryHighLimit(limit quantity.Size) *ResourcesBuilder {
+	rb.MemoryHighLimit = limit
+	rb.MemoryHighLimitSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithMemorySwapLimit(limit quantity.Size) *ResourcesBuilder {
+	rb.MemorySwapLimit = limit
+	rb.MemorySwapLimitSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithOOMPolicy(policy OOMPolicy) *ResourcesBuilder {
+	rb.OOMPolicy = policy
+	rb.OOMPolicySet = true
+	return rb
+}
+
 func (rb *ResourcesBuilder) Build() Resources {
 	var quotaResources Resources
 	if rb.MemoryLimitSet {
@@ -136,6 +226,55 @@ func (rb *ResourcesBuilder) Build() Resources {
 			}
 		}
 	}
+	if rb.IOReadBandwidthSet || rb.IOWriteBandwidthSet || rb.IOReadIOPSSet || rb.IOWriteIOPSSet || rb.IOWeightSet {
+		quotaResources.IO = &ResourceIO{}
+		if rb.IOReadBandwidthSet {
+			limit := rb.IOReadBandwidth
+			quotaResources.IO.ReadBandwidth = &limit
+		}
+		if rb.IOWriteBandwidthSet {
+			limit := rb.IOWriteBandwidth
+			quotaResources.IO.WriteBandwidth = &limit
+		}
+		if rb.IOReadIOPSSet {
+			limit := rb.IOReadIOPS
+			quotaResources.IO.ReadIOPS = &limit
+		}
+		if rb.IOWriteIOPSSet {
+			limit := rb.IOWriteIOPS
+			quotaResources.IO.WriteIOPS = &limit
+		}
+		if rb.IOWeightSet {
+			weight := rb.IOWeight
+			quotaResources.IO.Weight = &weight
+		}
+	}
+	if rb.NetworkIngressBandwidthSet || rb.NetworkEgressBandwidthSet {
+		quotaResources.Network = &ResourceNetwork{}
+		if rb.NetworkIngressBandwidthSet {
+			limit := rb.NetworkIngressBandwidth
+			quotaResources.Network.IngressBandwidth = &limit
+		}
+		if rb.NetworkEgressBandwidthSet {
+			limit := rb.NetworkEgressBandwidth
+			quotaResources.Network.EgressBandwidth = &limit
+		}
+	}
+	if rb.MemoryHighLimitSet {
+		quotaResources.MemoryHigh = &ResourceMemoryHigh{
+			Limit: rb.MemoryHighLimit,
+		}
+	}
+	if rb.MemorySwapLimitSet {
+		quotaResources.MemorySwap = &ResourceMemorySwap{
+			Limit: rb.MemorySwapLimit,
+		}
+	}
+	if rb.OOMPolicySet {
+		quotaResources.OOMPolicy = &ResourceOOMPolicy{
+			Policy: rb.OOMPolicy,
+		}
+	}
 	return quotaResources
 }
 
@@ -50,6 +50,13 @@ func (s *resourcesTestSuite) TestQuotaValidationFails(c *C) {
 		{quota.NewResourcesBuilder().WithJournalRate(0, 1).Build(), `journal quota must have a period of at least 1 microsecond \(minimum resolution\)`},
 		{quota.NewResourcesBuilder().WithJournalRate(1, time.Nanosecond).Build(), `journal quota must have a period of at least 1 microsecond \(minimum resolution\)`},
 		{quota.NewResourcesBuilder().WithJournalSize(0).Build(), `journal size quota must have a limit set`},
+		{quota.Resources{IO: &quota.ResourceIO{}}, `io quota must have at least one limit set`},
+		{quota.NewResourcesBuilder().WithIOWriteIOPS(-1).Build(), `invalid io quota with a negative iops limit`},
+		{quota.NewResourcesBuilder().WithIOWeight(10001).Build(), `invalid io weight 10001: weight must be between 1 and 10000`},
+		{quota.Resources{Network: &quota.ResourceNetwork{}}, `network quota must have at least one limit set`},
+		{quota.NewResourcesBuilder().WithMemoryHighLimit(5 * quantity.SizeKiB).Build(), `memory high limit 5120 is too small: size must be larger than 640 KiB`},
+		{quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).WithMemoryHighLimit(quantity.SizeMiB).Build(), `memory high limit 1048576 must be lower than the memory limit 1048576`},
+		{quota.NewResourcesBuilder().WithOOMPolicy("kill-all").Build(), `invalid oom policy "kill-all": must be one of "kill-one", "kill-group" or "restart-service"`},
 	}
 
 	for _, t := range tests {
@@ -84,6 +91,55 @@ func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsCgroupv1Err(c *
 	c.Check(bad.CheckFeatureRequirements(), ErrorMatches, "some cgroup detection error")
 }
 
+func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsIO(c *C) {
+	io := quota.NewResourcesBuilder().WithIOReadBandwidth(quantity.SizeMiB).Build()
+
+	r := quota.MockCgroupVer(1)
+	defer r()
+	c.Check(io.CheckFeatureRequirements(), ErrorMatches, "cannot use io quota with cgroup version 1")
+
+	quota.MockCgroupVer(2)
+	c.Check(io.CheckFeatureRequirements(), IsNil)
+
+	restore := quota.MockCgroupVerErr(fmt.Errorf("some cgroup detection error"))
+	defer restore()
+	c.Check(io.CheckFeatureRequirements(), ErrorMatches, "some cgroup detection error")
+}
+
+func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsNetwork(c *C) {
+	network := quota.NewResourcesBuilder().WithNetworkEgressBandwidth(quantity.SizeMiB).Build()
+
+	restore := quota.MockNetquotaCheckSupport(func() error { return nil })
+	defer restore()
+	c.Check(network.CheckFeatureRequirements(), IsNil)
+
+	quota.MockNetquotaCheckSupport(func() error { return fmt.Errorf("nft is not available") })
+	c.Check(network.CheckFeatureRequirements(), ErrorMatches, "cannot use network quota: nft is not available")
+
+	// other quotas are not affected
+	memory := quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build()
+	c.Check(memory.CheckFeatureRequirements(), IsNil)
+}
+
+func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsMemoryPressure(c *C) {
+	high := quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeMiB).Build()
+	swap := quota.NewResourcesBuilder().WithMemorySwapLimit(0).Build()
+
+	r := quota.MockCgroupVer(1)
+	defer r()
+	c.Check(high.CheckFeatureRequirements(), ErrorMatches, "cannot use memory high limit with cgroup version 1")
+	c.Check(swap.CheckFeatureRequirements(), ErrorMatches, "cannot use memory swap limit with cgroup version 1")
+
+	quota.MockCgroupVer(2)
+	c.Check(high.CheckFeatureRequirements(), IsNil)
+	c.Check(swap.CheckFeatureRequirements(), IsNil)
+
+	// the oom policy is handled by systemd and works with both versions
+	quota.MockCgroupVer(1)
+	policy := quota.NewResourcesBuilder().WithOOMPolicy(quota.OOMPolicyKillGroup).Build()
+	c.Check(policy.CheckFeatureRequirements(), IsNil)
+}
+
 func (s *resourcesTestSuite) TestQuotaValidationPasses(c *C) {
 	tests := []struct {
 		limits quota.Resources
@@ -95,6 +151,12 @@ func (s *resourcesTestSuite) TestQuotaValidationPasses(c *C) {
 		{quota.NewResourcesBuilder().WithJournalSize(quantity.SizeMiB).Build()},
 		{quota.NewResourcesBuilder().WithJournalRate(1, time.Microsecond).Build()},
 		{quota.NewResourcesBuilder().WithJournalNamespace().Build()},
+		{quota.NewResourcesBuilder().WithIOReadBandwidth(quantity.SizeMiB).WithIOWriteIOPS(100).Build()},
+		{quota.NewResourcesBuilder().WithIOWeight(100).Build()},
+		{quota.NewResourcesBuilder().WithNetworkIngressBandwidth(quantity.SizeMiB).Build()},
+		{quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).WithMemoryHighLimit(quantity.SizeMiB).Build()},
+		{quota.NewResourcesBuilder().WithMemorySwapLimit(0).Build()},
+		{quota.NewResourcesBuilder().WithOOMPolicy(quota.OOMPolicyRestartService).Build()},
 	}
 
 	for _, t := range tests {
@@ -205,6 +267,16 @@ func (s *resourcesTestSuite) TestQuotaChangeValidationFails(c *C) {
 			quota.NewResourcesBuilder().WithJournalSize(5 * quantity.SizeGiB).Build(),
 			`journal size quota must be smaller than 4 GiB`,
 		},
+		{
+			quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithMemoryHighLimit(0).Build(),
+			`cannot remove memory high limit from quota group`,
+		},
+		{
+			quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build(),
+			quota.NewResourcesBuilder().WithMemoryHighLimit(2 * quantity.SizeGiB).Build(),
+			`memory high limit 2147483648 must be lower than the memory limit 1073741824`,
+		},
 	}
 
 	for _, t := range tests {
@@ -303,6 +375,41 @@ func (s *resourcesTestSuite) TestQuotaChangeValidationPasses(c *C) {
 			quota.NewResourcesBuilder().WithJournalNamespace().Build(),
 			quota.NewResourcesBuilder().WithCPUCount(4).WithCPUPercentage(25).WithJournalNamespace().Build(),
 		},
+		{
+			quota.NewResourcesBuilder().WithIOReadBandwidth(quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithIOReadBandwidth(quantity.SizeKiB).WithIOWeight(10).Build(),
+			quota.NewResourcesBuilder().WithIOReadBandwidth(quantity.SizeKiB).WithIOWeight(10).Build(),
+		},
+		{
+			quota.NewResourcesBuilder().WithIOReadBandwidth(quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithIOWriteIOPS(20).Build(),
+			quota.NewResourcesBuilder().WithIOReadBandwidth(quantity.SizeMiB).WithIOWriteIOPS(20).Build(),
+		},
+		{
+			quota.NewResourcesBuilder().WithNetworkIngressBandwidth(quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithNetworkEgressBandwidth(quantity.SizeKiB).Build(),
+			quota.NewResourcesBuilder().WithNetworkIngressBandwidth(quantity.SizeMiB).WithNetworkEgressBandwidth(quantity.SizeKiB).Build(),
+		},
+		{
+			quota.NewResourcesBuilder().WithIOReadBandwidth(quantity.SizeMiB).WithIOWriteIOPS(20).Build(),
+			quota.NewResourcesBuilder().WithIOReadBandwidth(0).Build(),
+			quota.NewResourcesBuilder().WithIOReadBandwidth(0).WithIOWriteIOPS(20).Build(),
+		},
+		{
+			quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).WithNetworkEgressBandwidth(quantity.SizeKiB).Build(),
+			quota.NewResourcesBuilder().WithNetworkEgressBandwidth(0).Build(),
+			quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).WithNetworkEgressBandwidth(0).Build(),
+		},
+		{
+			quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).WithMemoryHighLimit(quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithMemoryHighLimit(800 * quantity.SizeKiB).WithMemorySwapLimit(0).Build(),
+			quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).WithMemoryHighLimit(800 * quantity.SizeKiB).WithMemorySwapLimit(0).Build(),
+		},
+		{
+			quota.NewResourcesBuilder().WithOOMPolicy(quota.OOMPolicyKillOne).Build(),
+			quota.NewResourcesBuilder().WithOOMPolicy(quota.OOMPolicyKillGroup).Build(),
+			quota.NewResourcesBuilder().WithOOMPolicy(quota.OOMPolicyKillGroup).Build(),
+		},
 	}
 
 	for _, t := range tests {
@@ -831,7 +831,67 @@ func ValidateApp(app *AppInfo) error {
 		return fmt.Errorf(`"install-mode" cannot be used for %q, only for services`, app.Name)
 	}
 
-	return validateAppTimer(app)
+	if err := validateAppTimer(app); err != nil {
+		return err
+	}
+
+	return validateAppHealthProbe(app)
+}
+
+func validateAppHealthProbe(app *AppInfo) error {
+	probe := app.HealthProbe
+	if probe == nil {
+		return nil
+	}
+
+	if app.DaemonScope != SystemDaemon {
+		return errors.New("health-probe is only applicable to system services")
+	}
+
+	kinds := 0
+	if probe.HTTP != nil {
+		kinds++
+		if !strings.HasPrefix(probe.HTTP.Path, "/") {
+			return fmt.Errorf("health-probe http path must start with a slash, got %q", probe.HTTP.Path)
+		}
+		// the address follows the rules of listen-stream
+		if err := validateSocketAddr(&SocketInfo{App: app}, "health-probe http address", probe.HTTP.Address); err != nil {
+			return err
+		}
+	}
+	if probe.TCP != "" {
+		kinds++
+		if err := validateSocketAddrNet(&SocketInfo{App: app}, "health-probe tcp", probe.TCP); err != nil {
+			return err
+		}
+	}
+	if probe.Command != "" {
+		kinds++
+		if err := validateField("health-probe command", probe.Command, appContentWhitelist); err != nil {
+			return err
+		}
+	}
+	if kinds != 1 {
+		return errors.New("health-probe must define exactly one of http, tcp or command")
+	}
+
+	if probe.Interval < 0 || probe.Timeout < 0 {
+		return errors.New("health-probe interval and timeout cannot be negative")
+	}
+	if probe.EffectiveTimeout() > probe.EffectiveInterval() {
+		return fmt.Errorf("health-probe timeout %s cannot be longer than its interval %s", probe.EffectiveTimeout(), probe.EffectiveInterval())
+	}
+	if probe.Threshold < 0 {
+		return errors.New("health-probe threshold cannot be negative")
+	}
+
+	switch probe.OnFailure {
+	case HealthProbeActionNone, HealthProbeActionRestart:
+		// valid
+	default:
+		return fmt.Errorf(`health-probe "on-failure" field contains invalid value %q`, probe.OnFailure)
+	}
+	return nil
 }
 
 // ValidatePathVariables ensures that given path contains only $SNAP, $SNAP_DATA or $SNAP_COMMON.
@@ -1646,6 +1646,162 @@ apps:
 	}
 }
 
+func (s *YamlSuite) TestValidateAppHealthProbe(c *C) {
+	meta := []byte(`
+name: foo
+version: 1.0
+`)
+	tcs := []struct {
+		name string
+		desc string
+		err  string
+	}{{
+		name: "http on a port",
+		desc: `
+    daemon: simple
+    health-probe:
+      http:
+        address: 8080
+        path: /healthz
+      interval: 10s
+      timeout: 2s
+      threshold: 5
+      on-failure: restart
+`,
+	}, {
+		name: "http on a unix socket",
+		desc: `
+    daemon: simple
+    health-probe:
+      http:
+        address: $SNAP_DATA/api.socket
+        path: /
+`,
+	}, {
+		name: "tcp",
+		desc: `
+    daemon: simple
+    health-probe:
+      tcp: 127.0.0.1:5432
+`,
+	}, {
+		name: "command",
+		desc: `
+    daemon: simple
+    health-probe:
+      command: bin/check --quick
+`,
+	}, {
+		name: "not a service",
+		desc: `
+    health-probe:
+      tcp: 8080
+`,
+		err: `health-probe is only applicable to system services`,
+	}, {
+		name: "user service",
+		desc: `
+    daemon: simple
+    daemon-scope: user
+    health-probe:
+      tcp: 8080
+`,
+		err: `health-probe is only applicable to system services`,
+	}, {
+		name: "no probe",
+		desc: `
+    daemon: simple
+    health-probe:
+      interval: 10s
+`,
+		err: `health-probe must define exactly one of http, tcp or command`,
+	}, {
+		name: "two probes",
+		desc: `
+    daemon: simple
+    health-probe:
+      tcp: 8080
+      command: bin/check
+`,
+		err: `health-probe must define exactly one of http, tcp or command`,
+	}, {
+		name: "bad http path",
+		desc: `
+    daemon: simple
+    health-probe:
+      http:
+        address: 8080
+        path: healthz
+`,
+		err: `health-probe http path must start with a slash, got "healthz"`,
+	}, {
+		name: "bad http socket",
+		desc: `
+    daemon: simple
+    health-probe:
+      http:
+        address: /run/api.socket
+        path: /
+`,
+		err: `invalid "health-probe http address": system daemon sockets must have a prefix of .*`,
+	}, {
+		name: "bad tcp host",
+		desc: `
+    daemon: simple
+    health-probe:
+      tcp: 10.0.0.1:80
+`,
+		err: `invalid "health-probe tcp" address "10.0.0.1", must be one of: 127.0.0.1, \[::1\], \[::\]`,
+	}, {
+		name: "bad command",
+		desc: `
+    daemon: simple
+    health-probe:
+      command: bin/check;reboot
+`,
+		err: `app description field 'health-probe command' contains illegal "bin/check;reboot" .*`,
+	}, {
+		name: "timeout longer than interval",
+		desc: `
+    daemon: simple
+    health-probe:
+      tcp: 8080
+      interval: 5s
+`,
+		err: `health-probe timeout 10s cannot be longer than its interval 5s`,
+	}, {
+		name: "negative threshold",
+		desc: `
+    daemon: simple
+    health-probe:
+      tcp: 8080
+      threshold: -1
+`,
+		err: `health-probe threshold cannot be negative`,
+	}, {
+		name: "bad on-failure",
+		desc: `
+    daemon: simple
+    health-probe:
+      tcp: 8080
+      on-failure: reboot
+`,
+		err: `health-probe "on-failure" field contains invalid value "reboot"`,
+	}}
+	for _, tc := range tcs {
+		c.Logf("trying %q", tc.name)
+		info, err := InfoFromSnapYaml(append(meta, []byte("apps:\n  foo:"+tc.desc)...))
+		c.Assert(err, IsNil)
+
+		err = Validate(info)
+		if tc.err != "" {
+			c.Assert(err, ErrorMatches, `invalid definition of application "foo": `+tc.err)
+		} else {
+			c.Assert(err, IsNil)
+		}
+	}
+}
+
 func (s *ValidateSuite) TestValidateOsCannotHaveBase(c *C) {
 	info, err := InfoFromSnapYaml([]byte(`name: foo
 version: 1.0
@@ -81,6 +81,17 @@ func (e *RevisionNotAvailableError) Error() string {
 	return "no snap revision available as specified"
 }
 
+// ArchitectureNotAvailableError is returned when the store offers a snap
+// that cannot run on any of the requested architectures.
+type ArchitectureNotAvailableError struct {
+	Snap          string
+	Architectures []string
+}
+
+func (e *ArchitectureNotAvailableError) Error() string {
+	return fmt.Sprintf("snap %q is not available for architecture %s", e.Snap, strings.Join(e.Architectures, " or "))
+}
+
 // DownloadError represents a download error
 type DownloadError struct {
 	Code int
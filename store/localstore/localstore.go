@@ -0,0 +1,866 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package localstore implements a snap store backed by a local directory of
+// snap files and assertions, for installing and refreshing snaps without
+// network access.
+//
+// The directory holds the .snap files to serve together with any number of
+// .assert files containing assertion streams. Only snaps with a matching
+// snap-revision assertion are served, the snap ID and revision are taken from
+// it. All other assertions, e.g. snap declarations and account keys, are also
+// served from the streams.
+//
+// The snaps are all released to the latest/stable channel, asking for them
+// on other tracks or on branches fails like asking the store for a channel
+// the snap is not released to.
+package localstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/channel"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/snap/snapfile"
+	"github.com/snapcore/snapd/store"
+)
+
+// assertionStreamScheme prefixes the assertion stream "URLs" handed out by
+// SnapAction, they are resolved by DownloadAssertions.
+const assertionStreamScheme = "local-assertion:"
+
+var errNotSupported = errors.New("not supported by the local store")
+
+var snapfileOpen = snapfile.Open
+
+// Store serves snaps and assertions from a local directory.
+type Store struct {
+	dir string
+
+	mu sync.Mutex
+	// files caches the details of the snap files read so far, by path
+	files map[string]*snapFile
+}
+
+// snapFile holds the details of a snap file that are expensive to compute.
+type snapFile struct {
+	size    int64
+	modTime time.Time
+	digest  string
+	yaml    []byte
+}
+
+// localSnap is a snap file with its matching snap-revision assertion.
+type localSnap struct {
+	path   string
+	file   *snapFile
+	rev    *asserts.SnapRevision
+	name   string
+	pubID  string
+	arches []string
+}
+
+// catalog is a snapshot of the contents of the store directory.
+type catalog struct {
+	snaps      []*localSnap
+	assertions asserts.Backstore
+}
+
+// New returns a store serving the snaps and assertions in dir.
+func New(dir string) *Store {
+	return &Store{
+		dir:   dir,
+		files: make(map[string]*snapFile),
+	}
+}
+
+// Dir returns the directory the store serves from.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// load reads the store directory. The assertions are read every time, the
+// snap files only when they changed since they were last read.
+func (s *Store) load() (*catalog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bs := asserts.NewMemoryBackstore()
+	assertFiles, err := filepath.Glob(filepath.Join(s.dir, "*.assert"))
+	if err != nil {
+		return nil, err
+	}
+	for _, fn := range assertFiles {
+		if err := addAssertions(bs, fn); err != nil {
+			return nil, err
+		}
+	}
+
+	snapPaths, err := filepath.Glob(filepath.Join(s.dir, "*.snap"))
+	if err != nil {
+		return nil, err
+	}
+	cat := &catalog{assertions: bs}
+	seen := make(map[string]bool, len(snapPaths))
+	for _, path := range snapPaths {
+		seen[path] = true
+		sf, err := s.snapFile(path)
+		if err != nil {
+			logger.Noticef("cannot read local store snap %q: %v", path, err)
+			continue
+		}
+		ls, err := newLocalSnap(path, sf, bs)
+		if err != nil {
+			logger.Debugf("ignoring local store snap %q: %v", path, err)
+			continue
+		}
+		cat.snaps = append(cat.snaps, ls)
+	}
+	for path := range s.files {
+		if !seen[path] {
+			delete(s.files, path)
+		}
+	}
+
+	return cat, nil
+}
+
+func addAssertions(bs asserts.Backstore, fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := asserts.NewDecoder(f)
+	for {
+		a, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read assertions from %q: %v", fn, err)
+		}
+		// keep the most recent revision
+		if err := bs.Put(a.Type(), a); err != nil {
+			if _, ok := err.(*asserts.RevisionError); !ok {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Store) snapFile(path string) (*snapFile, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if sf := s.files[path]; sf != nil && sf.size == fi.Size() && sf.modTime.Equal(fi.ModTime()) {
+		return sf, nil
+	}
+
+	digest, _, err := asserts.SnapFileSHA3_384(path)
+	if err != nil {
+		return nil, err
+	}
+	container, err := snapfileOpen(path)
+	if err != nil {
+		return nil, err
+	}
+	yaml, err := container.ReadFile("meta/snap.yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	sf := &snapFile{
+		size:    fi.Size(),
+		modTime: fi.ModTime(),
+		digest:  digest,
+		yaml:    yaml,
+	}
+	s.files[path] = sf
+	return sf, nil
+}
+
+func newLocalSnap(path string, sf *snapFile, bs asserts.Backstore) (*localSnap, error) {
+	info, err := snap.InfoFromSnapYaml(sf.yaml)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := bs.Get(asserts.SnapRevisionType, []string{sf.digest, info.Provenance()}, asserts.SnapRevisionType.MaxSupportedFormat())
+	if err != nil {
+		return nil, fmt.Errorf("no snap-revision assertion: %v", err)
+	}
+	rev := a.(*asserts.SnapRevision)
+
+	ls := &localSnap{
+		path:   path,
+		file:   sf,
+		rev:    rev,
+		name:   info.SnapName(),
+		pubID:  rev.DeveloperID(),
+		arches: info.Architectures,
+	}
+	if a, err := bs.Get(asserts.SnapDeclarationType, []string{release.Series, rev.SnapID()}, asserts.SnapDeclarationType.MaxSupportedFormat()); err == nil {
+		decl := a.(*asserts.SnapDeclaration)
+		ls.name = decl.SnapName()
+		ls.pubID = decl.PublisherID()
+	}
+	return ls, nil
+}
+
+func (ls *localSnap) revision() snap.Revision {
+	return snap.R(ls.rev.SnapRevision())
+}
+
+// archPreference returns the index of the first of the architectures the snap
+// supports, or -1 if it supports none.
+func (ls *localSnap) archPreference(architectures []string) int {
+	for i, dpkgArch := range architectures {
+		for _, a := range ls.arches {
+			if a == "all" || a == dpkgArch {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// info returns a new snap.Info for the snap, callers are free to modify it.
+func (ls *localSnap) info(channelName string) (*snap.Info, error) {
+	info, err := snap.InfoFromSnapYaml(ls.file.yaml)
+	if err != nil {
+		return nil, err
+	}
+	info.SideInfo = snap.SideInfo{
+		RealName: ls.name,
+		SnapID:   ls.rev.SnapID(),
+		Revision: ls.revision(),
+		Channel:  channelName,
+	}
+	info.Publisher = snap.StoreAccount{ID: ls.pubID}
+	info.DownloadInfo = snap.DownloadInfo{
+		DownloadURL: ls.path,
+		Size:        ls.file.size,
+		Sha3_384:    ls.file.digest,
+	}
+	info.Channels = map[string]*snap.ChannelSnapInfo{
+		servedChannel.Full(): {
+			Revision:    info.Revision,
+			Confinement: info.Confinement,
+			Version:     info.Version,
+			Channel:     servedChannel.Full(),
+			Epoch:       info.Epoch,
+			Size:        info.Size,
+			ReleasedAt:  ls.rev.Timestamp(),
+		},
+	}
+	info.Tracks = []string{servedChannel.Track}
+	return info, nil
+}
+
+// find returns the snaps matching the predicate for any of the architectures,
+// or the architecture of the system if none are given. Snaps for preferred
+// architectures come first, then the most recent revisions.
+func (c *catalog) find(architectures []string, match func(*localSnap) bool) []*localSnap {
+	if len(architectures) == 0 {
+		architectures = []string{arch.DpkgArchitecture()}
+	}
+	var found []*localSnap
+	prefs := make(map[*localSnap]int)
+	for _, ls := range c.snaps {
+		if pref := ls.archPreference(architectures); pref >= 0 && match(ls) {
+			found = append(found, ls)
+			prefs[ls] = pref
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if prefs[found[i]] != prefs[found[j]] {
+			return prefs[found[i]] < prefs[found[j]]
+		}
+		return found[j].revision().N < found[i].revision().N
+	})
+	return found
+}
+
+func (c *catalog) byName(name string, architectures []string) []*localSnap {
+	return c.find(architectures, func(ls *localSnap) bool { return ls.name == name })
+}
+
+func (c *catalog) bySnapID(snapID string, architectures []string) []*localSnap {
+	return c.find(architectures, func(ls *localSnap) bool { return ls.rev.SnapID() == snapID })
+}
+
+// pick returns the given revision among the candidates, or the most recent
+// one if the revision is unset.
+func pick(candidates []*localSnap, rev snap.Revision) *localSnap {
+	for _, ls := range candidates {
+		if rev.Unset() || ls.revision() == rev {
+			return ls
+		}
+	}
+	return nil
+}
+
+// servedChannel is the channel the snaps of the store directory are released
+// to, the other risks of the latest track follow it like empty channels
+// follow stable in the store.
+var servedChannel = channel.Channel{Name: "stable", Track: "latest", Risk: "stable"}
+
+// checkChannel returns an error listing the channels the snap is available
+// in, if the channel is not one the store directory serves snaps from.
+func (ls *localSnap) checkChannel(action, channelName string) error {
+	ch, err := channel.Parse(channelName, "")
+	if err == nil && ch.Track == "" && ch.Branch == "" {
+		return nil
+	}
+	var releases []channel.Channel
+	for _, a := range ls.arches {
+		if a == "all" {
+			a = arch.DpkgArchitecture()
+		}
+		rel := servedChannel
+		rel.Architecture = a
+		releases = append(releases, rel)
+	}
+	return &store.RevisionNotAvailableError{Action: action, Channel: channelName, Releases: releases}
+}
+
+func defaultChannel(channelName string) string {
+	if channelName == "" {
+		return "stable"
+	}
+	return channelName
+}
+
+// EnsureDeviceSession is a no-op, the local store needs no device session.
+func (s *Store) EnsureDeviceSession() error {
+	return nil
+}
+
+// SnapInfo returns the most recent revision of the named snap.
+func (s *Store) SnapInfo(ctx context.Context, spec store.SnapSpec, user *auth.UserState) (*snap.Info, error) {
+	cat, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	ls := pick(cat.byName(spec.Name, spec.Architectures), snap.Revision{})
+	if ls == nil {
+		return nil, store.ErrSnapNotFound
+	}
+	return ls.info("stable")
+}
+
+// SnapExists checks whether the named snap is available.
+func (s *Store) SnapExists(ctx context.Context, spec store.SnapSpec, user *auth.UserState) (naming.SnapRef, *channel.Channel, error) {
+	info, err := s.SnapInfo(ctx, spec, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch := servedChannel
+	ch.Architecture = arch.DpkgArchitecture()
+	return info, &ch, nil
+}
+
+// Find returns the most recent revision of the snaps whose name or summary
+// match the search query.
+func (s *Store) Find(ctx context.Context, search *store.Search, user *auth.UserState) ([]*snap.Info, error) {
+	if search.Private {
+		return nil, store.ErrUnauthenticated
+	}
+
+	cat, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	query := strings.ToLower(strings.TrimSpace(search.Query))
+	names := make(map[string]bool)
+	var infos []*snap.Info
+	for _, ls := range cat.find(search.Architectures, func(*localSnap) bool { return true }) {
+		if names[ls.name] {
+			// only the most recent revision
+			continue
+		}
+		info, err := ls.info("stable")
+		if err != nil {
+			return nil, err
+		}
+		var matches bool
+		switch {
+		case query == "":
+			matches = true
+		case search.Prefix:
+			matches = strings.HasPrefix(ls.name, query)
+		default:
+			matches = strings.Contains(ls.name, query) || strings.Contains(strings.ToLower(info.Summary()), query)
+		}
+		if !matches {
+			continue
+		}
+		names[ls.name] = true
+		infos = append(infos, info)
+	}
+	if len(infos) == 0 {
+		return nil, store.ErrSnapNotFound
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].SnapName() < infos[j].SnapName() })
+	return infos, nil
+}
+
+// SnapAction resolves install, download and refresh actions and assertion
+// queries against the store directory.
+func (s *Store) SnapAction(ctx context.Context, currentSnaps []*store.CurrentSnap, actions []*store.SnapAction, assertQuery store.AssertionQuery, user *auth.UserState, opts *store.RefreshOptions) ([]store.SnapActionResult, []store.AssertionResult, error) {
+	cat, err := s.load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	curSnaps := make(map[string]*store.CurrentSnap, len(currentSnaps))
+	for _, cur := range currentSnaps {
+		curSnaps[cur.InstanceName] = cur
+	}
+
+	refreshErrors := make(map[string]error)
+	installErrors := make(map[string]error)
+	downloadErrors := make(map[string]error)
+	var otherErrors []error
+
+	var sars []store.SnapActionResult
+	for _, a := range actions {
+		var ls *localSnap
+		var channelName string
+		switch a.Action {
+		case "install", "download":
+			snapName, _ := snap.SplitInstanceName(a.InstanceName)
+			channelName = defaultChannel(a.Channel)
+			ls = pick(cat.byName(snapName, a.Architectures), a.Revision)
+			if ls == nil {
+				err := notAvailable(a.Action, channelName, a.Revision)
+				if a.Action == "install" {
+					installErrors[a.InstanceName] = err
+				} else {
+					downloadErrors[a.InstanceName] = err
+				}
+				continue
+			}
+			if a.Revision.Unset() {
+				if err := ls.checkChannel(a.Action, channelName); err != nil {
+					if a.Action == "install" {
+						installErrors[a.InstanceName] = err
+					} else {
+						downloadErrors[a.InstanceName] = err
+					}
+					continue
+				}
+			}
+		case "refresh":
+			cur := curSnaps[a.InstanceName]
+			if cur == nil {
+				otherErrors = append(otherErrors, fmt.Errorf("internal error: refresh of %q without current snap information", a.InstanceName))
+				continue
+			}
+			channelName = a.Channel
+			if channelName == "" {
+				channelName = cur.TrackingChannel
+			}
+			channelName = defaultChannel(channelName)
+			ls = pick(cat.bySnapID(cur.SnapID, a.Architectures), a.Revision)
+			if ls == nil {
+				refreshErrors[a.InstanceName] = notAvailable(a.Action, channelName, a.Revision)
+				continue
+			}
+			if a.Revision.Unset() {
+				if err := ls.checkChannel(a.Action, channelName); err != nil {
+					refreshErrors[a.InstanceName] = err
+					continue
+				}
+			}
+			rev := ls.revision()
+			// only a revision that was asked for explicitly can
+			// take the snap back to an older revision
+			upToDate := rev.N <= cur.Revision.N
+			if !a.Revision.Unset() {
+				upToDate = rev == cur.Revision
+			}
+			if upToDate || isBlocked(rev, cur.Block) {
+				refreshErrors[a.InstanceName] = store.ErrNoUpdateAvailable
+				continue
+			}
+		default:
+			return nil, nil, fmt.Errorf("internal error: unsupported action %q", a.Action)
+		}
+
+		info, err := ls.info(channelName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if a.Action != "download" {
+			_, info.InstanceKey = snap.SplitInstanceName(a.InstanceName)
+		}
+		sars = append(sars, store.SnapActionResult{Info: info})
+	}
+
+	var ars []store.AssertionResult
+	if assertQuery != nil {
+		ars, err = resolveAssertions(cat.assertions, assertQuery)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(refreshErrors)+len(installErrors)+len(downloadErrors)+len(otherErrors) != 0 {
+		// normalize empty maps
+		if len(refreshErrors) == 0 {
+			refreshErrors = nil
+		}
+		if len(installErrors) == 0 {
+			installErrors = nil
+		}
+		if len(downloadErrors) == 0 {
+			downloadErrors = nil
+		}
+		return sars, ars, &store.SnapActionError{
+			NoResults: len(sars) == 0 && len(ars) == 0,
+			Refresh:   refreshErrors,
+			Install:   installErrors,
+			Download:  downloadErrors,
+			Other:     otherErrors,
+		}
+	}
+	return sars, ars, nil
+}
+
+func notAvailable(action, channelName string, rev snap.Revision) error {
+	if rev.Unset() && action != "refresh" {
+		return store.ErrSnapNotFound
+	}
+	return &store.RevisionNotAvailableError{Action: action, Channel: channelName}
+}
+
+func isBlocked(rev snap.Revision, block []snap.Revision) bool {
+	for _, r := range block {
+		if r == rev {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveAssertions returns stream "URLs" for the assertions in the query
+// that have a more recent revision or sequence point in the store directory.
+func resolveAssertions(bs asserts.Backstore, assertQuery store.AssertionQuery) ([]store.AssertionResult, error) {
+	toResolve, toResolveSeq, err := assertQuery.ToResolve()
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make(map[asserts.Grouping][]string)
+	for grouping, ats := range toResolve {
+		for _, at := range ats {
+			a, err := bs.Get(at.Type, at.PrimaryKey, at.Type.MaxSupportedFormat())
+			if errors.Is(err, &asserts.NotFoundError{}) {
+				headers, _ := asserts.HeadersFromPrimaryKey(at.Type, at.PrimaryKey)
+				if err := assertQuery.AddError(&asserts.NotFoundError{Type: at.Type, Headers: headers}, &at.Ref); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if a.Revision() > at.Revision {
+				urls[grouping] = append(urls[grouping], assertionStreamURL(a.Ref()))
+			}
+		}
+	}
+	for grouping, ats := range toResolveSeq {
+		for _, at := range ats {
+			a, err := seqFormingAssertion(bs, at.Type, at.SequenceKey, pinnedSequence(at))
+			if errors.Is(err, &asserts.NotFoundError{}) {
+				if err := assertQuery.AddSequenceError(err, at); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			seq := a.(asserts.SequenceMember).Sequence()
+			if seq > at.Sequence || (seq == at.Sequence && a.Revision() > at.Revision) {
+				urls[grouping] = append(urls[grouping], assertionStreamURL(a.Ref()))
+			}
+		}
+	}
+
+	groupings := make([]string, 0, len(urls))
+	for grouping := range urls {
+		groupings = append(groupings, string(grouping))
+	}
+	sort.Strings(groupings)
+	ars := make([]store.AssertionResult, 0, len(groupings))
+	for _, grouping := range groupings {
+		ars = append(ars, store.AssertionResult{
+			Grouping:   asserts.Grouping(grouping),
+			StreamURLs: urls[asserts.Grouping(grouping)],
+		})
+	}
+	return ars, nil
+}
+
+func pinnedSequence(at *asserts.AtSequence) int {
+	if at.Pinned {
+		return at.Sequence
+	}
+	return 0
+}
+
+func assertionStreamURL(ref *asserts.Ref) string {
+	return assertionStreamScheme + strings.Join(append([]string{ref.Type.Name}, ref.PrimaryKey...), "/")
+}
+
+func refFromStreamURL(u string) (*asserts.Ref, error) {
+	if !strings.HasPrefix(u, assertionStreamScheme) {
+		return nil, fmt.Errorf("invalid local assertions stream URL %q", u)
+	}
+	parts := strings.Split(strings.TrimPrefix(u, assertionStreamScheme), "/")
+	assertType := asserts.Type(parts[0])
+	if assertType == nil || !assertType.AcceptablePrimaryKey(parts[1:]) {
+		return nil, fmt.Errorf("invalid local assertions stream URL %q", u)
+	}
+	return &asserts.Ref{Type: assertType, PrimaryKey: parts[1:]}, nil
+}
+
+// Sections returns no sections, the local store has none.
+func (s *Store) Sections(ctx context.Context, user *auth.UserState) ([]string, error) {
+	return nil, nil
+}
+
+// Categories returns no categories, the local store has none.
+func (s *Store) Categories(ctx context.Context, user *auth.UserState) ([]store.CategoryDetails, error) {
+	return nil, nil
+}
+
+// WriteCatalogs writes the names of the available snaps and their commands.
+func (s *Store) WriteCatalogs(ctx context.Context, names io.Writer, adder store.SnapAdder) error {
+	cat, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, ls := range cat.find(nil, func(*localSnap) bool { return true }) {
+		if seen[ls.name] {
+			continue
+		}
+		seen[ls.name] = true
+		info, err := ls.info("stable")
+		if err != nil {
+			return err
+		}
+		commands := make([]string, 0, len(info.Apps))
+		for _, app := range info.Apps {
+			if app.IsService() {
+				continue
+			}
+			commands = append(commands, app.Name)
+		}
+		sort.Strings(commands)
+		if err := adder.AddSnap(ls.name, info.Version, info.Summary(), commands); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(names, ls.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Download copies the snap file from the store directory to targetPath.
+func (s *Store) Download(ctx context.Context, name, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState, dlOpts *store.DownloadOptions) error {
+	if err := s.checkDownloadPath(downloadInfo.DownloadURL); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+
+	if err := osutil.CopyFile(downloadInfo.DownloadURL, targetPath, osutil.CopyFlagOverwrite); err != nil {
+		return fmt.Errorf("cannot download snap %q: %v", name, err)
+	}
+
+	digest, size, err := asserts.SnapFileSHA3_384(targetPath)
+	if err == nil && (digest != downloadInfo.Sha3_384 || int64(size) != downloadInfo.Size) {
+		err = fmt.Errorf("cannot download snap %q: file %q changed since it was looked up", name, downloadInfo.DownloadURL)
+	}
+	if err != nil {
+		if dlOpts == nil || !dlOpts.LeavePartialOnError {
+			os.Remove(targetPath)
+		}
+		return err
+	}
+	return nil
+}
+
+// DownloadStream streams the snap file from the store directory.
+func (s *Store) DownloadStream(ctx context.Context, name string, downloadInfo *snap.DownloadInfo, resume int64, user *auth.UserState) (io.ReadCloser, int, error) {
+	if err := s.checkDownloadPath(downloadInfo.DownloadURL); err != nil {
+		return nil, 0, err
+	}
+
+	f, err := os.Open(downloadInfo.DownloadURL)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot download snap %q: %v", name, err)
+	}
+	status := http.StatusOK
+	if resume > 0 {
+		if _, err := f.Seek(resume, io.SeekStart); err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		status = http.StatusPartialContent
+	}
+	return f, status, nil
+}
+
+func (s *Store) checkDownloadPath(path string) error {
+	if filepath.Dir(path) != filepath.Clean(s.dir) || !strings.HasSuffix(path, ".snap") {
+		return fmt.Errorf("cannot download %q: not a snap of the local store", path)
+	}
+	return nil
+}
+
+// Assertion returns the assertion with the given type and primary key.
+func (s *Store) Assertion(assertType *asserts.AssertionType, primaryKey []string, user *auth.UserState) (asserts.Assertion, error) {
+	cat, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	a, err := cat.assertions.Get(assertType, primaryKey, assertType.MaxSupportedFormat())
+	if errors.Is(err, &asserts.NotFoundError{}) {
+		// best-effort
+		headers, _ := asserts.HeadersFromPrimaryKey(assertType, primaryKey)
+		return nil, &asserts.NotFoundError{Type: assertType, Headers: headers}
+	}
+	return a, err
+}
+
+// SeqFormingAssertion returns the sequence-forming assertion with the given
+// sequence key and sequence point, or the latest one if sequence is not
+// positive.
+func (s *Store) SeqFormingAssertion(assertType *asserts.AssertionType, sequenceKey []string, sequence int, user *auth.UserState) (asserts.Assertion, error) {
+	if !assertType.SequenceForming() {
+		return nil, fmt.Errorf("internal error: requested non sequence-forming assertion type %q", assertType.Name)
+	}
+	cat, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return seqFormingAssertion(cat.assertions, assertType, sequenceKey, sequence)
+}
+
+func seqFormingAssertion(bs asserts.Backstore, assertType *asserts.AssertionType, sequenceKey []string, sequence int) (asserts.Assertion, error) {
+	maxFormat := assertType.MaxSupportedFormat()
+	var a asserts.Assertion
+	var err error
+	if sequence > 0 {
+		key := append(append([]string(nil), sequenceKey...), fmt.Sprintf("%d", sequence))
+		a, err = bs.Get(assertType, key, maxFormat)
+	} else {
+		a, err = bs.SequenceMemberAfter(assertType, sequenceKey, -1, maxFormat)
+	}
+	if errors.Is(err, &asserts.NotFoundError{}) {
+		headers, _ := asserts.HeadersFromSequenceKey(assertType, sequenceKey)
+		return nil, &asserts.NotFoundError{Type: assertType, Headers: headers}
+	}
+	return a, err
+}
+
+// DownloadAssertions adds the assertions referred to by the stream URLs
+// returned by SnapAction to the batch.
+func (s *Store) DownloadAssertions(streamURLs []string, b *asserts.Batch, user *auth.UserState) error {
+	cat, err := s.load()
+	if err != nil {
+		return err
+	}
+	for _, u := range streamURLs {
+		ref, err := refFromStreamURL(u)
+		if err != nil {
+			return err
+		}
+		a, err := cat.assertions.Get(ref.Type, ref.PrimaryKey, ref.Type.MaxSupportedFormat())
+		if err != nil {
+			return err
+		}
+		if err := b.Add(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SuggestedCurrency returns no currency, the local store sells nothing.
+func (s *Store) SuggestedCurrency() string {
+	return ""
+}
+
+func (s *Store) Buy(options *client.BuyOptions, user *auth.UserState) (*client.BuyResult, error) {
+	return nil, fmt.Errorf("cannot buy snaps: %v", errNotSupported)
+}
+
+func (s *Store) ReadyToBuy(*auth.UserState) error {
+	return fmt.Errorf("cannot buy snaps: %v", errNotSupported)
+}
+
+// ConnectivityCheck reports whether the store directory is accessible.
+func (s *Store) ConnectivityCheck() (map[string]bool, error) {
+	return map[string]bool{s.dir: osutil.IsDirectory(s.dir)}, nil
+}
+
+func (s *Store) CreateCohorts(context.Context, []string) (map[string]string, error) {
+	return nil, fmt.Errorf("cannot create cohorts: %v", errNotSupported)
+}
+
+func (s *Store) LoginUser(username, password, otp string) (string, string, error) {
+	return "", "", fmt.Errorf("cannot log in: %v", errNotSupported)
+}
+
+func (s *Store) UserInfo(email string) (*store.User, error) {
+	return nil, fmt.Errorf("cannot get user information: %v", errNotSupported)
+}
@@ -0,0 +1,538 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package localstore_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/channel"
+	"github.com/snapcore/snapd/snap/snapdir"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/localstore"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ snapstate.StoreService = (*localstore.Store)(nil)
+
+type localstoreSuite struct {
+	testutil.BaseTest
+
+	dir          string
+	storeSigning *assertstest.StoreStack
+	dev1Acct     *asserts.Account
+	// snapDirs maps snap file paths to the unpacked snaps served in their
+	// stead, as building real snap files needs mksquashfs
+	snapDirs map[string]string
+
+	store *localstore.Store
+}
+
+var _ = Suite(&localstoreSuite{})
+
+func (s *localstoreSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+
+	s.dir = c.MkDir()
+	s.storeSigning = assertstest.NewStoreStack("can0nical", nil)
+	s.dev1Acct = assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
+	s.writeAssertions(c, "accounts.assert", s.storeSigning.StoreAccountKey(""), s.dev1Acct)
+
+	s.snapDirs = make(map[string]string)
+	s.AddCleanup(localstore.MockSnapfileOpen(func(path string) (snap.Container, error) {
+		unpacked, ok := s.snapDirs[path]
+		if !ok {
+			return nil, fmt.Errorf("unexpected snap %q", path)
+		}
+		return snapdir.New(unpacked), nil
+	}))
+
+	s.store = localstore.New(s.dir)
+}
+
+func (s *localstoreSuite) writeAssertions(c *C, name string, as ...asserts.Assertion) {
+	f, err := os.Create(filepath.Join(s.dir, name))
+	c.Assert(err, IsNil)
+	defer f.Close()
+	enc := asserts.NewEncoder(f)
+	for _, a := range as {
+		c.Assert(enc.Encode(a), IsNil)
+	}
+}
+
+func (s *localstoreSuite) addDeclaration(c *C, name, snapID string) asserts.Assertion {
+	decl, err := s.storeSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+		"series":       "16",
+		"snap-id":      snapID,
+		"snap-name":    name,
+		"publisher-id": s.dev1Acct.AccountID(),
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.writeAssertions(c, name+".assert", decl)
+	return decl
+}
+
+// addSnap puts a snap revision in the store directory, with a snap-revision
+// assertion if snapID is set.
+func (s *localstoreSuite) addSnap(c *C, snapYaml, snapID string, rev int) string {
+	info, err := snap.InfoFromSnapYaml([]byte(snapYaml))
+	c.Assert(err, IsNil)
+
+	unpacked := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(unpacked, "meta"), 0755), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(unpacked, "meta", "snap.yaml"), []byte(snapYaml), 0644), IsNil)
+
+	path := filepath.Join(s.dir, fmt.Sprintf("%s_%d.snap", info.SnapName(), rev))
+	c.Assert(os.WriteFile(path, []byte(fmt.Sprintf("hsqs-%s-%d", info.SnapName(), rev)), 0644), IsNil)
+	s.snapDirs[path] = unpacked
+
+	if snapID == "" {
+		return path
+	}
+
+	digest, size, err := asserts.SnapFileSHA3_384(path)
+	c.Assert(err, IsNil)
+	snapRev, err := s.storeSigning.Sign(asserts.SnapRevisionType, map[string]interface{}{
+		"snap-id":       snapID,
+		"snap-sha3-384": digest,
+		"snap-size":     fmt.Sprintf("%d", size),
+		"snap-revision": fmt.Sprintf("%d", rev),
+		"developer-id":  s.dev1Acct.AccountID(),
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.writeAssertions(c, fmt.Sprintf("%s_%d.assert", info.SnapName(), rev), snapRev)
+	return path
+}
+
+const fooYaml = `name: foo
+version: 1.0
+summary: The foo snap
+apps:
+  foo:
+    command: bin/foo
+  foo-svc:
+    command: bin/foo-svc
+    daemon: simple
+`
+
+func (s *localstoreSuite) TestSnapInfo(c *C) {
+	s.addDeclaration(c, "foo", "foo-id")
+	s.addSnap(c, fooYaml, "foo-id", 1)
+	path := s.addSnap(c, fooYaml, "foo-id", 2)
+
+	info, err := s.store.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.SnapName(), Equals, "foo")
+	c.Check(info.SnapID, Equals, "foo-id")
+	c.Check(info.Revision, Equals, snap.R(2))
+	c.Check(info.Version, Equals, "1.0")
+	c.Check(info.Publisher.ID, Equals, s.dev1Acct.AccountID())
+	c.Check(info.DownloadURL, Equals, path)
+	c.Check(info.Size, Equals, int64(len("hsqs-foo-2")))
+	c.Check(info.Sha3_384, Not(Equals), "")
+	c.Check(info.Channels["latest/stable"].Revision, Equals, snap.R(2))
+}
+
+func (s *localstoreSuite) TestSnapInfoNotFound(c *C) {
+	// snaps without a snap-revision assertion are not served
+	s.addSnap(c, "name: bar\nversion: 1\n", "", 1)
+	// and neither are snaps for other architectures
+	s.addSnap(c, "name: baz\nversion: 1\narchitectures: [no-such-arch]\n", "baz-id", 1)
+
+	for _, name := range []string{"bar", "baz", "missing"} {
+		_, err := s.store.SnapInfo(context.Background(), store.SnapSpec{Name: name}, nil)
+		c.Check(err, Equals, store.ErrSnapNotFound, Commentf(name))
+	}
+}
+
+func (s *localstoreSuite) TestSnapActionArchitectures(c *C) {
+	s.addSnap(c, "name: foo\nversion: 1\narchitectures: [arch-a]\n", "foo-id", 1)
+	s.addSnap(c, "name: foo\nversion: 2\narchitectures: [arch-b]\n", "foo-id", 2)
+
+	for _, t := range []struct {
+		archs []string
+		rev   snap.Revision
+	}{
+		{[]string{"arch-a", "arch-b"}, snap.R(1)},
+		{[]string{"arch-b", "arch-a"}, snap.R(2)},
+		{[]string{"arch-c", "arch-a"}, snap.R(1)},
+	} {
+		sars, _, err := s.store.SnapAction(context.Background(), nil, []*store.SnapAction{
+			{Action: "install", InstanceName: "foo", Architectures: t.archs},
+		}, nil, nil, nil)
+		c.Assert(err, IsNil)
+		c.Assert(sars, HasLen, 1)
+		c.Check(sars[0].Info.Revision, Equals, t.rev, Commentf("%v", t.archs))
+	}
+
+	_, err := s.store.SnapInfo(context.Background(), store.SnapSpec{Name: "foo", Architectures: []string{"arch-c"}}, nil)
+	c.Check(err, Equals, store.ErrSnapNotFound)
+}
+
+func (s *localstoreSuite) TestSnapInfoNoDeclaration(c *C) {
+	s.addSnap(c, fooYaml, "foo-id", 1)
+
+	// without a declaration the name comes from the snap itself
+	info, err := s.store.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.SnapID, Equals, "foo-id")
+	c.Check(info.Revision, Equals, snap.R(1))
+}
+
+func (s *localstoreSuite) TestFind(c *C) {
+	s.addDeclaration(c, "foo", "foo-id")
+	s.addSnap(c, fooYaml, "foo-id", 1)
+	s.addSnap(c, fooYaml, "foo-id", 2)
+	s.addSnap(c, "name: bar\nversion: 1\nsummary: Not foo\n", "bar-id", 3)
+
+	for _, t := range []struct {
+		search *store.Search
+		names  []string
+	}{
+		{&store.Search{Query: ""}, []string{"bar", "foo"}},
+		{&store.Search{Query: "fo"}, []string{"bar", "foo"}},
+		{&store.Search{Query: "fo", Prefix: true}, []string{"foo"}},
+		{&store.Search{Query: "BAR"}, []string{"bar"}},
+	} {
+		infos, err := s.store.Find(context.Background(), t.search, nil)
+		c.Assert(err, IsNil)
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.SnapName())
+			if info.SnapName() == "foo" {
+				c.Check(info.Revision, Equals, snap.R(2))
+			}
+		}
+		c.Check(names, DeepEquals, t.names, Commentf("%+v", t.search))
+	}
+
+	_, err := s.store.Find(context.Background(), &store.Search{Query: "nope"}, nil)
+	c.Check(err, Equals, store.ErrSnapNotFound)
+
+	_, err = s.store.Find(context.Background(), &store.Search{Private: true}, nil)
+	c.Check(err, Equals, store.ErrUnauthenticated)
+}
+
+func (s *localstoreSuite) TestSnapActionInstall(c *C) {
+	s.addDeclaration(c, "foo", "foo-id")
+	s.addSnap(c, fooYaml, "foo-id", 1)
+	s.addSnap(c, fooYaml, "foo-id", 2)
+
+	sars, _, err := s.store.SnapAction(context.Background(), nil, []*store.SnapAction{
+		{Action: "install", InstanceName: "foo"},
+		{Action: "install", InstanceName: "foo_inst", Revision: snap.R(1)},
+		{Action: "download", InstanceName: "foo", Channel: "edge"},
+	}, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(sars, HasLen, 3)
+	c.Check(sars[0].Info.InstanceName(), Equals, "foo")
+	c.Check(sars[0].Info.Revision, Equals, snap.R(2))
+	c.Check(sars[0].Info.Channel, Equals, "stable")
+	c.Check(sars[1].Info.InstanceName(), Equals, "foo_inst")
+	c.Check(sars[1].Info.Revision, Equals, snap.R(1))
+	c.Check(sars[2].Info.Revision, Equals, snap.R(2))
+	c.Check(sars[2].Info.Channel, Equals, "edge")
+}
+
+func (s *localstoreSuite) TestSnapActionInstallErrors(c *C) {
+	s.addSnap(c, fooYaml, "foo-id", 1)
+
+	sars, _, err := s.store.SnapAction(context.Background(), nil, []*store.SnapAction{
+		{Action: "install", InstanceName: "missing"},
+		{Action: "install", InstanceName: "foo", Revision: snap.R(7)},
+		{Action: "download", InstanceName: "other"},
+	}, nil, nil, nil)
+	c.Check(sars, HasLen, 0)
+	c.Assert(err, FitsTypeOf, &store.SnapActionError{})
+	c.Check(err, DeepEquals, &store.SnapActionError{
+		NoResults: true,
+		Install: map[string]error{
+			"missing": store.ErrSnapNotFound,
+			"foo":     &store.RevisionNotAvailableError{Action: "install", Channel: "stable"},
+		},
+		Download: map[string]error{
+			"other": store.ErrSnapNotFound,
+		},
+	})
+}
+
+func (s *localstoreSuite) TestSnapActionOtherChannels(c *C) {
+	s.addDeclaration(c, "foo", "foo-id")
+	s.addSnap(c, fooYaml, "foo-id", 1)
+	s.addSnap(c, fooYaml, "foo-id", 2)
+
+	current := []*store.CurrentSnap{
+		{InstanceName: "foo", SnapID: "foo-id", Revision: snap.R(1), TrackingChannel: "2.0/stable"},
+	}
+	sars, _, err := s.store.SnapAction(context.Background(), current, []*store.SnapAction{
+		{Action: "install", InstanceName: "foo_a", Channel: "latest/beta"},
+		{Action: "install", InstanceName: "foo_b", Channel: "2.0/stable"},
+		{Action: "install", InstanceName: "foo_c", Channel: "2.0", Revision: snap.R(1)},
+		{Action: "download", InstanceName: "foo", Channel: "stable/hotfix"},
+		{Action: "refresh", InstanceName: "foo", SnapID: "foo-id"},
+	}, nil, nil, nil)
+	c.Assert(sars, HasLen, 2)
+	c.Check(sars[0].Info.InstanceName(), Equals, "foo_a")
+	c.Check(sars[0].Info.Revision, Equals, snap.R(2))
+	c.Check(sars[1].Info.InstanceName(), Equals, "foo_c")
+	c.Check(sars[1].Info.Revision, Equals, snap.R(1))
+
+	releases := []channel.Channel{
+		{Architecture: arch.DpkgArchitecture(), Name: "stable", Track: "latest", Risk: "stable"},
+	}
+	c.Check(err, DeepEquals, &store.SnapActionError{
+		Install: map[string]error{
+			"foo_b": &store.RevisionNotAvailableError{Action: "install", Channel: "2.0/stable", Releases: releases},
+		},
+		Download: map[string]error{
+			"foo": &store.RevisionNotAvailableError{Action: "download", Channel: "stable/hotfix", Releases: releases},
+		},
+		Refresh: map[string]error{
+			"foo": &store.RevisionNotAvailableError{Action: "refresh", Channel: "2.0/stable", Releases: releases},
+		},
+	})
+}
+
+func (s *localstoreSuite) TestSnapActionRefresh(c *C) {
+	s.addDeclaration(c, "foo", "foo-id")
+	s.addSnap(c, fooYaml, "foo-id", 1)
+	s.addSnap(c, fooYaml, "foo-id", 2)
+	s.addSnap(c, "name: bar\nversion: 1\n", "bar-id", 5)
+	s.addSnap(c, "name: baz\nversion: 1\n", "baz-id", 3)
+
+	current := []*store.CurrentSnap{
+		{InstanceName: "foo", SnapID: "foo-id", Revision: snap.R(1), TrackingChannel: "latest/stable"},
+		{InstanceName: "bar", SnapID: "bar-id", Revision: snap.R(5)},
+		{InstanceName: "baz", SnapID: "baz-id", Revision: snap.R(1), Block: []snap.Revision{snap.R(3)}},
+	}
+	sars, _, err := s.store.SnapAction(context.Background(), current, []*store.SnapAction{
+		{Action: "refresh", InstanceName: "foo", SnapID: "foo-id"},
+		{Action: "refresh", InstanceName: "bar", SnapID: "bar-id"},
+		{Action: "refresh", InstanceName: "baz", SnapID: "baz-id"},
+	}, nil, nil, nil)
+	c.Assert(sars, HasLen, 1)
+	c.Check(sars[0].Info.InstanceName(), Equals, "foo")
+	c.Check(sars[0].Info.Revision, Equals, snap.R(2))
+	c.Check(sars[0].Info.Channel, Equals, "latest/stable")
+	c.Check(err, DeepEquals, &store.SnapActionError{
+		Refresh: map[string]error{
+			"bar": store.ErrNoUpdateAvailable,
+			"baz": store.ErrNoUpdateAvailable,
+		},
+	})
+}
+
+func (s *localstoreSuite) TestSnapActionRefreshNoDowngrade(c *C) {
+	s.addDeclaration(c, "foo", "foo-id")
+	s.addSnap(c, fooYaml, "foo-id", 1)
+	s.addSnap(c, fooYaml, "foo-id", 2)
+
+	// the directory only holds older revisions than the current one
+	current := []*store.CurrentSnap{
+		{InstanceName: "foo", SnapID: "foo-id", Revision: snap.R(3), TrackingChannel: "latest/stable"},
+	}
+	sars, _, err := s.store.SnapAction(context.Background(), current, []*store.SnapAction{
+		{Action: "refresh", InstanceName: "foo", SnapID: "foo-id"},
+	}, nil, nil, nil)
+	c.Check(sars, HasLen, 0)
+	c.Check(err, DeepEquals, &store.SnapActionError{
+		NoResults: true,
+		Refresh: map[string]error{
+			"foo": store.ErrNoUpdateAvailable,
+		},
+	})
+
+	// unless an older revision is asked for explicitly
+	sars, _, err = s.store.SnapAction(context.Background(), current, []*store.SnapAction{
+		{Action: "refresh", InstanceName: "foo", SnapID: "foo-id", Revision: snap.R(1)},
+	}, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(sars, HasLen, 1)
+	c.Check(sars[0].Info.Revision, Equals, snap.R(1))
+}
+
+type fakeAssertQuery struct {
+	toResolve    map[asserts.Grouping][]*asserts.AtRevision
+	toResolveSeq map[asserts.Grouping][]*asserts.AtSequence
+	errors       []error
+}
+
+func (q *fakeAssertQuery) ToResolve() (map[asserts.Grouping][]*asserts.AtRevision, map[asserts.Grouping][]*asserts.AtSequence, error) {
+	return q.toResolve, q.toResolveSeq, nil
+}
+
+func (q *fakeAssertQuery) AddError(err error, ref *asserts.Ref) error {
+	q.errors = append(q.errors, err)
+	return nil
+}
+
+func (q *fakeAssertQuery) AddSequenceError(err error, atSeq *asserts.AtSequence) error {
+	q.errors = append(q.errors, err)
+	return nil
+}
+
+func (q *fakeAssertQuery) AddGroupingError(err error, grouping asserts.Grouping) error {
+	q.errors = append(q.errors, err)
+	return nil
+}
+
+func (s *localstoreSuite) TestSnapActionAssertions(c *C) {
+	decl := s.addDeclaration(c, "foo", "foo-id")
+
+	q := &fakeAssertQuery{
+		toResolve: map[asserts.Grouping][]*asserts.AtRevision{
+			"g1": {
+				{Ref: *decl.Ref(), Revision: asserts.RevisionNotKnown},
+				{Ref: *s.dev1Acct.Ref(), Revision: s.dev1Acct.Revision()},
+				{Ref: asserts.Ref{Type: asserts.SnapDeclarationType, PrimaryKey: []string{"16", "missing-id"}}, Revision: asserts.RevisionNotKnown},
+			},
+		},
+	}
+	_, ars, err := s.store.SnapAction(context.Background(), nil, nil, q, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(ars, HasLen, 1)
+	c.Check(ars[0].Grouping, Equals, asserts.Grouping("g1"))
+	// the account is up to date
+	c.Assert(ars[0].StreamURLs, HasLen, 1)
+	c.Assert(q.errors, HasLen, 1)
+	c.Check(q.errors[0], ErrorMatches, `snap-declaration \(missing-id; series:16\) not found`)
+
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   s.storeSigning.Trusted,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(db.Add(s.storeSigning.StoreAccountKey("")), IsNil)
+	c.Assert(db.Add(s.dev1Acct), IsNil)
+
+	b := asserts.NewBatch(nil)
+	c.Assert(s.store.DownloadAssertions(ars[0].StreamURLs, b, nil), IsNil)
+	c.Assert(b.CommitTo(db, nil), IsNil)
+	_, err = db.Find(asserts.SnapDeclarationType, map[string]string{"series": "16", "snap-id": "foo-id"})
+	c.Check(err, IsNil)
+
+	err = s.store.DownloadAssertions([]string{"https://example.com/assertions"}, b, nil)
+	c.Check(err, ErrorMatches, `invalid local assertions stream URL "https://example.com/assertions"`)
+}
+
+func (s *localstoreSuite) TestAssertion(c *C) {
+	decl := s.addDeclaration(c, "foo", "foo-id")
+
+	a, err := s.store.Assertion(asserts.SnapDeclarationType, []string{"16", "foo-id"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(a.Ref(), DeepEquals, decl.Ref())
+
+	_, err = s.store.Assertion(asserts.SnapDeclarationType, []string{"16", "missing-id"}, nil)
+	c.Check(err, DeepEquals, &asserts.NotFoundError{
+		Type:    asserts.SnapDeclarationType,
+		Headers: map[string]string{"series": "16", "snap-id": "missing-id"},
+	})
+}
+
+func (s *localstoreSuite) TestSeqFormingAssertionNotFound(c *C) {
+	_, err := s.store.SeqFormingAssertion(asserts.ValidationSetType, []string{"16", "account-id", "set"}, 0, nil)
+	c.Check(err, DeepEquals, &asserts.NotFoundError{
+		Type:    asserts.ValidationSetType,
+		Headers: map[string]string{"series": "16", "account-id": "account-id", "name": "set"},
+	})
+
+	_, err = s.store.SeqFormingAssertion(asserts.SnapDeclarationType, []string{"16"}, 0, nil)
+	c.Check(err, ErrorMatches, `internal error: requested non sequence-forming assertion type "snap-declaration"`)
+}
+
+func (s *localstoreSuite) TestDownload(c *C) {
+	s.addSnap(c, fooYaml, "foo-id", 1)
+	info, err := s.store.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+
+	target := filepath.Join(c.MkDir(), "sub", "foo_1.snap")
+	err = s.store.Download(context.Background(), "foo", target, &info.DownloadInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(target, testutil.FileEquals, "hsqs-foo-1")
+
+	// the file changed after the lookup
+	c.Assert(os.WriteFile(info.DownloadURL, []byte("hsqs-foo-X"), 0644), IsNil)
+	err = s.store.Download(context.Background(), "foo", target, &info.DownloadInfo, nil, nil, nil)
+	c.Check(err, ErrorMatches, `cannot download snap "foo": file ".*/foo_1.snap" changed since it was looked up`)
+	c.Check(target, testutil.FileAbsent)
+}
+
+func (s *localstoreSuite) TestDownloadStream(c *C) {
+	s.addSnap(c, fooYaml, "foo-id", 1)
+	info, err := s.store.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+
+	r, status, err := s.store.DownloadStream(context.Background(), "foo", &info.DownloadInfo, 5, nil)
+	c.Assert(err, IsNil)
+	defer r.Close()
+	c.Check(status, Equals, http.StatusPartialContent)
+	data, err := io.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "foo-1")
+}
+
+func (s *localstoreSuite) TestDownloadOutsideStore(c *C) {
+	for _, path := range []string{"/etc/passwd", filepath.Join(s.dir, "foo.assert"), filepath.Join(s.dir, "sub", "foo.snap")} {
+		err := s.store.Download(context.Background(), "foo", filepath.Join(c.MkDir(), "foo.snap"), &snap.DownloadInfo{DownloadURL: path}, nil, nil, nil)
+		c.Check(err, ErrorMatches, fmt.Sprintf("cannot download %q: not a snap of the local store", path))
+		_, _, err = s.store.DownloadStream(context.Background(), "foo", &snap.DownloadInfo{DownloadURL: path}, 0, nil)
+		c.Check(err, ErrorMatches, fmt.Sprintf("cannot download %q: not a snap of the local store", path))
+	}
+}
+
+func (s *localstoreSuite) TestNotSupported(c *C) {
+	_, err := s.store.Buy(nil, nil)
+	c.Check(err, ErrorMatches, "cannot buy snaps: not supported by the local store")
+	c.Check(s.store.ReadyToBuy(nil), ErrorMatches, "cannot buy snaps: not supported by the local store")
+	_, err = s.store.CreateCohorts(context.Background(), []string{"foo"})
+	c.Check(err, ErrorMatches, "cannot create cohorts: not supported by the local store")
+	_, _, err = s.store.LoginUser("user", "pass", "")
+	c.Check(err, ErrorMatches, "cannot log in: not supported by the local store")
+	_, err = s.store.UserInfo("user@example.com")
+	c.Check(err, ErrorMatches, "cannot get user information: not supported by the local store")
+}
+
+func (s *localstoreSuite) TestConnectivityCheck(c *C) {
+	status, err := s.store.ConnectivityCheck()
+	c.Assert(err, IsNil)
+	c.Check(status, DeepEquals, map[string]bool{s.dir: true})
+
+	status, err = localstore.New(filepath.Join(s.dir, "missing")).ConnectivityCheck()
+	c.Assert(err, IsNil)
+	c.Check(status, DeepEquals, map[string]bool{filepath.Join(s.dir, "missing"): false})
+}
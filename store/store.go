@@ -592,6 +592,10 @@ type requestOptions struct {
 	//  - deviceAuthCustomStoreOnly: should be provided only in case
 	//    of a custom store
 	DeviceAuthNeed deviceAuthNeed
+
+	// Architecture overrides the store architecture sent with the
+	// request, if set.
+	Architecture string
 }
 
 func (r *requestOptions) addHeader(k, v string) {
@@ -809,7 +813,11 @@ func (s *Store) newRequest(ctx context.Context, reqOptions *requestOptions, user
 
 	req.Header.Set("User-Agent", s.userAgent)
 	req.Header.Set("Accept", reqOptions.Accept)
-	req.Header.Set(hdrSnapDeviceArchitecture[reqOptions.APILevel], s.architecture)
+	architecture := s.architecture
+	if reqOptions.Architecture != "" {
+		architecture = reqOptions.Architecture
+	}
+	req.Header.Set(hdrSnapDeviceArchitecture[reqOptions.APILevel], architecture)
 	req.Header.Set(hdrSnapDeviceSeries[reqOptions.APILevel], s.series)
 	req.Header.Set(hdrSnapClassic[reqOptions.APILevel], strconv.FormatBool(release.OnClassic))
 	req.Header.Set("Snap-Device-Capabilities", "default-tracks")
@@ -949,13 +957,25 @@ func mustBuy(paid bool, bought bool) bool {
 // A SnapSpec describes a single snap wanted from SnapInfo
 type SnapSpec struct {
 	Name string
+	// Architectures optionally lists the acceptable architectures in
+	// order of preference, the store architecture is used if empty.
+	Architectures []string
+}
+
+// architecturesOrDefault returns the given architectures or, if none, the
+// store architecture.
+func (s *Store) architecturesOrDefault(architectures []string) []string {
+	if len(architectures) == 0 {
+		return []string{s.architecture}
+	}
+	return architectures
 }
 
 // SnapInfo returns the snap.Info for the store-hosted snap matching the given spec, or an error.
 func (s *Store) SnapInfo(ctx context.Context, snapSpec SnapSpec, user *auth.UserState) (*snap.Info, error) {
 	fields := strings.Join(s.infoFields, ",")
 
-	si, resp, err := s.snapInfo(ctx, snapSpec.Name, fields, user)
+	si, resp, err := s.snapInfoForArchitectures(ctx, snapSpec, fields, user)
 	if err != nil {
 		return nil, err
 	}
@@ -975,16 +995,29 @@ func (s *Store) SnapInfo(ctx context.Context, snapSpec SnapSpec, user *auth.User
 	return info, nil
 }
 
-func (s *Store) snapInfo(ctx context.Context, snapName string, fields string, user *auth.UserState) (*storeInfo, *http.Response, error) {
+// snapInfoForArchitectures returns the store details of the snap for the
+// first of the spec's architectures the snap is available for.
+func (s *Store) snapInfoForArchitectures(ctx context.Context, snapSpec SnapSpec, fields string, user *auth.UserState) (si *storeInfo, resp *http.Response, err error) {
+	for _, architecture := range s.architecturesOrDefault(snapSpec.Architectures) {
+		si, resp, err = s.snapInfo(ctx, snapSpec.Name, architecture, fields, user)
+		if err != ErrSnapNotFound {
+			break
+		}
+	}
+	return si, resp, err
+}
+
+func (s *Store) snapInfo(ctx context.Context, snapName, architecture, fields string, user *auth.UserState) (*storeInfo, *http.Response, error) {
 	query := url.Values{}
 	query.Set("fields", fields)
-	query.Set("architecture", s.architecture)
+	query.Set("architecture", architecture)
 
 	u := s.endpointURL(path.Join(snapInfoEndpPath, snapName), query)
 	reqOptions := &requestOptions{
-		Method:   "GET",
-		URL:      u,
-		APILevel: apiV2Endps,
+		Method:       "GET",
+		URL:          u,
+		APILevel:     apiV2Endps,
+		Architecture: architecture,
 	}
 
 	var remote storeInfo
@@ -1012,7 +1045,7 @@ func (s *Store) SnapExists(ctx context.Context, snapSpec SnapSpec, user *auth.Us
 	// request the minimal amount information
 	fields := "channel-map"
 
-	si, _, err := s.snapInfo(ctx, snapSpec.Name, fields, user)
+	si, _, err := s.snapInfoForArchitectures(ctx, snapSpec, fields, user)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -1032,6 +1065,9 @@ type Search struct {
 	Category string
 	Private  bool
 	Scope    string
+	// Architectures optionally lists the acceptable architectures in
+	// order of preference, the store architecture is used if empty.
+	Architectures []string
 }
 
 // Find finds  (installable) snaps from the store, matching the
@@ -1053,9 +1089,37 @@ func (s *Store) Find(ctx context.Context, search *Search, user *auth.UserState)
 		return nil, ErrBadQuery
 	}
 
+	architectures := s.architecturesOrDefault(search.Architectures)
+	if len(architectures) == 1 {
+		return s.find(ctx, search, architectures[0], user)
+	}
+
+	// merge the results, a snap is listed for the first architecture
+	// it is available for
+	var snaps []*snap.Info
+	seen := make(map[string]bool)
+	for _, architecture := range architectures {
+		found, err := s.find(ctx, search, architecture, user)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range found {
+			if seen[info.SnapID] {
+				continue
+			}
+			seen[info.SnapID] = true
+			snaps = append(snaps, info)
+		}
+	}
+	return snaps, nil
+}
+
+func (s *Store) find(ctx context.Context, search *Search, architecture string, user *auth.UserState) ([]*snap.Info, error) {
+	searchTerm := strings.TrimSpace(search.Query)
+
 	q := url.Values{}
 	q.Set("fields", strings.Join(s.findFields, ","))
-	q.Set("architecture", s.architecture)
+	q.Set("architecture", architecture)
 
 	if search.Private {
 		q.Set("private", "true")
@@ -1092,10 +1156,11 @@ func (s *Store) Find(ctx context.Context, search *Search, user *auth.UserState)
 
 	u := s.endpointURL(findEndpPath, q)
 	reqOptions := &requestOptions{
-		Method:   "GET",
-		URL:      u,
-		Accept:   jsonContentType,
-		APILevel: apiV2Endps,
+		Method:       "GET",
+		URL:          u,
+		Accept:       jsonContentType,
+		APILevel:     apiV2Endps,
+		Architecture: architecture,
 	}
 
 	var searchData searchV2Results
@@ -34,7 +34,7 @@ import (
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/snap"
-"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/strutil"
 )
 
 type RefreshOptions struct {
@@ -102,6 +102,10 @@ type SnapAction struct {
 	// ValidationSets is an optional array of validation set primary keys
 	// (relevant for install and refresh actions).
 	ValidationSets []snapasserts.ValidationSetKey
+	// Architectures optionally lists the acceptable architectures of
+	// the snap in order of preference, the store architecture is
+	// assumed if empty.
+	Architectures []string
 }
 
 func isValidAction(action string) bool {
@@ -123,6 +127,9 @@ type snapActionJSON struct {
 	Revision         int    `json:"revision,omitempty"`
 	CohortKey        string `json:"cohort-key,omitempty"`
 	IgnoreValidation *bool  `json:"ignore-validation,omitempty"`
+	// Architectures lists the acceptable architectures in order of
+	// preference, the server picks the first one it has a build for
+	Architectures []string `json:"architectures,omitempty"`
 
 	// NOTE the store needs an epoch (even if null) for the "install" and "download"
 	// actions, to know the client handles epochs at all.  "refresh" actions should
@@ -392,6 +399,7 @@ func (s *Store) snapAction(ctx context.Context, currentSnaps []*CurrentSnap, act
 			CohortKey:        a.CohortKey,
 			ValidationSets:   valsetKeyComponents,
 			IgnoreValidation: ignoreValidation,
+			Architectures:    a.Architectures,
 		}
 		if !a.Revision.Unset() {
 			a.Channel = ""
@@ -536,7 +544,9 @@ func (s *Store) snapAction(ctx context.Context, currentSnaps []*CurrentSnap, act
 		Data:        jsonData,
 		APILevel:    apiV2Endps,
 	}
- reqOptions.addHeader("Syncloud-Architecture", arch.DpkgArchitecture())
+	// the architecture is also sent per action, this is kept for servers
+	// that only look at the header
+	reqOptions.addHeader("Syncloud-Architecture", s.architecture)
 
 	if opts.IsAutoRefresh {
 		logger.Debugf("Auto-refresh; adding header Snap-Refresh-Reason: scheduled")
@@ -622,6 +632,22 @@ func (s *Store) snapAction(ctx context.Context, currentSnaps []*CurrentSnap, act
 
 		snapInfo.Channel = res.EffectiveChannel
 
+		if a := actionForResult(res, installs, downloads, refreshes); a != nil && !supportsArchitecture(snapInfo, a.Architectures) {
+			err := &ArchitectureNotAvailableError{
+				Snap:          snapInfo.SnapName(),
+				Architectures: a.Architectures,
+			}
+			switch res.Result {
+			case "install":
+				installErrors[a.InstanceName] = err
+			case "download":
+				downloadErrors[a.InstanceName] = err
+			default:
+				refreshErrors[a.InstanceName] = err
+			}
+			continue
+		}
+
 		var instanceName string
 		if res.Result == "refresh" {
 			cur := curSnaps[res.InstanceKey]
@@ -677,6 +703,33 @@ func (s *Store) snapAction(ctx context.Context, currentSnaps []*CurrentSnap, act
 	return sars, ars, nil
 }
 
+// actionForResult returns the action a snap result is for, if any.
+func actionForResult(res *snapActionResult, installs, downloads, refreshes map[string]*SnapAction) *SnapAction {
+	switch res.Result {
+	case "install":
+		return installs[res.InstanceKey]
+	case "download":
+		return downloads[res.InstanceKey]
+	case "refresh":
+		return refreshes[res.InstanceKey]
+	}
+	return nil
+}
+
+// supportsArchitecture checks whether the snap can run on any of the given
+// architectures, an empty list accepts any snap.
+func supportsArchitecture(info *snap.Info, architectures []string) bool {
+	if len(architectures) == 0 || len(info.Architectures) == 0 {
+		return true
+	}
+	for _, a := range info.Architectures {
+		if a == "all" || strutil.ListContains(architectures, a) {
+			return true
+		}
+	}
+	return false
+}
+
 func findRev(needle snap.Revision, haystack []snap.Revision) bool {
 	for _, r := range haystack {
 		if needle == r {
@@ -1315,6 +1315,94 @@ func (s *storeActionSuite) testSnapActionGet(action, cohort, redirectChannel str
 	c.Assert(results[0].RedirectChannel, Equals, redirectChannel)
 }
 
+func (s *storeActionSuite) TestSnapActionArchitectures(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertRequest(c, r, "POST", snapActionPath)
+		c.Check(r.Header.Get("Syncloud-Architecture"), Equals, arch.DpkgArchitecture())
+
+		jsonReq, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		var req struct {
+			Actions []map[string]interface{} `json:"actions"`
+		}
+		c.Assert(json.Unmarshal(jsonReq, &req), IsNil)
+		c.Assert(req.Actions, HasLen, 2)
+		c.Check(req.Actions[0]["architectures"], DeepEquals, []interface{}{"arm64", "armhf"})
+		c.Check(req.Actions[1]["architectures"], DeepEquals, []interface{}{"arm64"})
+
+		io.WriteString(w, `{
+  "results": [{
+     "result": "install",
+     "instance-key": "install-1",
+     "snap-id": "buPKUD3TKqCOgLEjjHx5kSiCpIs5cMuQ",
+     "name": "hello-world",
+     "snap": {
+       "snap-id": "buPKUD3TKqCOgLEjjHx5kSiCpIs5cMuQ",
+       "name": "hello-world",
+       "revision": 26,
+       "version": "6.1",
+       "architectures": ["armhf"],
+       "publisher": {
+          "id": "canonical",
+          "username": "canonical",
+          "display-name": "Canonical"
+       }
+     }
+  }, {
+     "result": "install",
+     "instance-key": "install-2",
+     "snap-id": "xidididididididididididididididid",
+     "name": "other",
+     "snap": {
+       "snap-id": "xidididididididididididididididid",
+       "name": "other",
+       "revision": 3,
+       "version": "1",
+       "architectures": ["amd64"],
+       "publisher": {
+          "id": "canonical",
+          "username": "canonical",
+          "display-name": "Canonical"
+       }
+     }
+  }]
+}`)
+	}))
+
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	mockServerURL, _ := url.Parse(mockServer.URL)
+	cfg := store.Config{
+		StoreBaseURL: mockServerURL,
+	}
+	dauthCtx := &testDauthContext{c: c, device: s.device}
+	sto := store.New(&cfg, dauthCtx)
+
+	results, _, err := sto.SnapAction(s.ctx, nil,
+		[]*store.SnapAction{
+			{
+				Action:        "install",
+				InstanceName:  "hello-world",
+				Architectures: []string{"arm64", "armhf"},
+			}, {
+				Action:        "install",
+				InstanceName:  "other",
+				Architectures: []string{"arm64"},
+			},
+		}, nil, nil, nil)
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].InstanceName(), Equals, "hello-world")
+	c.Check(results[0].Architectures, DeepEquals, []string{"armhf"})
+	// a build for the wrong architecture is rejected
+	c.Check(err, DeepEquals, &store.SnapActionError{
+		Install: map[string]error{
+			"other": &store.ArchitectureNotAvailableError{Snap: "other", Architectures: []string{"arm64"}},
+		},
+	})
+	c.Check(err.(*store.SnapActionError).Install["other"], ErrorMatches, `snap "other" is not available for architecture arm64`)
+}
+
 func (s *storeActionSuite) TestSnapActionInstallAmend(c *C) {
 	// this is what amend would look like
 	restore := release.MockOnClassic(false)
@@ -185,6 +185,9 @@ type DownloadOptions struct {
 	RateLimit           int64
 	IsAutoRefresh       bool
 	LeavePartialOnError bool
+	// Architecture is the architecture of the snap being downloaded,
+	// if it differs from the store architecture.
+	Architecture string
 }
 
 // Download downloads the snap addressed by download info and returns its
@@ -309,6 +312,9 @@ func downloadReqOpts(storeURL *url.URL, cdnHeader string, opts *DownloadOptions)
 	if opts != nil && opts.IsAutoRefresh {
 		reqOptions.ExtraHeaders["Snap-Refresh-Reason"] = "scheduled"
 	}
+	if opts != nil {
+		reqOptions.Architecture = opts.Architecture
+	}
 
 	return &reqOptions
 }
@@ -883,6 +883,30 @@ func (s *storeDownloadSuite) TestDownloadTimeout(c *C) {
 	defer mockServer.Close()
 }
 
+func (s *storeDownloadSuite) TestDownloadArchitecture(c *C) {
+	content := []byte("snap content")
+	h := crypto.SHA3_384.New()
+	h.Write(content)
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("X-Ubuntu-Architecture"), Equals, "armhf")
+		w.Write(content)
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	snap := &snap.Info{}
+	snap.RealName = "foo"
+	snap.DownloadURL = mockServer.URL
+	snap.Sha3_384 = fmt.Sprintf("%x", h.Sum(nil))
+	snap.Size = int64(len(content))
+
+	targetFn := filepath.Join(c.MkDir(), "foo_1.0_armhf.snap")
+	err := s.store.Download(s.ctx, "foo", targetFn, &snap.DownloadInfo, nil, nil, &store.DownloadOptions{Architecture: "armhf"})
+	c.Assert(err, IsNil)
+	c.Check(targetFn, testutil.FileEquals, content)
+}
+
 func (s *storeDownloadSuite) TestTransferSpeedMonitoringWriterHappy(c *C) {
 	origCtx := context.TODO()
 	w, ctx := store.NewTransferSpeedMonitoringWriterAndContext(origCtx, 50*time.Millisecond, 1)
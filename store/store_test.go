@@ -1496,6 +1496,47 @@ func (s *storeTestSuite) TestInfoDefaultChannelIsStable(c *C) {
 	c.Check(result.Channel, Equals, "stable")
 }
 
+func (s *storeTestSuite) TestInfoArchitecturesFallback(c *C) {
+	var archs []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertRequest(c, r, "GET", infoPathPattern)
+		arch := r.URL.Query().Get("architecture")
+		c.Check(r.Header.Get("Snap-Device-Architecture"), Equals, arch)
+		archs = append(archs, arch)
+		if arch == "arm64" {
+			w.WriteHeader(404)
+			return
+		}
+		w.WriteHeader(200)
+		io.WriteString(w, mockInfoJSON)
+	}))
+
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	mockServerURL, _ := url.Parse(mockServer.URL)
+	cfg := store.Config{
+		StoreBaseURL: mockServerURL,
+	}
+	sto := store.New(&cfg, nil)
+
+	spec := store.SnapSpec{
+		Name:          "hello-world",
+		Architectures: []string{"arm64", "armhf", "i386"},
+	}
+	result, err := sto.SnapInfo(s.ctx, spec, nil)
+	c.Assert(err, IsNil)
+	c.Check(result.SnapID, Equals, helloWorldSnapID)
+	// the first available architecture wins
+	c.Check(archs, DeepEquals, []string{"arm64", "armhf"})
+
+	archs = nil
+	spec.Architectures = []string{"arm64"}
+	_, err = sto.SnapInfo(s.ctx, spec, nil)
+	c.Check(err, Equals, store.ErrSnapNotFound)
+	c.Check(archs, DeepEquals, []string{"arm64"})
+}
+
 func (s *storeTestSuite) TestInfo500(c *C) {
 	var n = 0
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -2889,6 +2930,33 @@ func (s *storeTestSuite) TestFindV2ErrorList(c *C) {
 	c.Check(err, ErrorMatches, `api error occurred`)
 }
 
+func (s *storeTestSuite) TestFindArchitectures(c *C) {
+	var archs []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertRequest(c, r, "GET", findPath)
+		arch := r.URL.Query().Get("architecture")
+		c.Check(r.Header.Get("Snap-Device-Architecture"), Equals, arch)
+		archs = append(archs, arch)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		io.WriteString(w, mockSearchJSONv2)
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	mockServerURL, _ := url.Parse(mockServer.URL)
+	cfg := store.Config{
+		StoreBaseURL: mockServerURL,
+	}
+	sto := store.New(&cfg, nil)
+	snaps, err := sto.Find(s.ctx, &store.Search{Query: "hello", Architectures: []string{"arm64", "armhf"}}, nil)
+	c.Assert(err, IsNil)
+	c.Check(archs, DeepEquals, []string{"arm64", "armhf"})
+	// snaps found for several architectures are listed once
+	c.Assert(snaps, HasLen, 1)
+	c.Check(snaps[0].SnapID, Equals, helloWorldSnapID)
+}
+
 func (s *storeTestSuite) TestFindFailures(c *C) {
 	// bad query check is done early in Find(), so the test covers both search
 	// v1 & v2
@@ -112,6 +112,10 @@ func (s *emulation) CurrentTasksCount(unit string) (uint64, error) {
 	return 0, &notImplementedError{"CurrentTasksCount"}
 }
 
+func (s *emulation) RestartStatus(units []string) ([]*RestartStatus, error) {
+	return nil, &notImplementedError{"RestartStatus"}
+}
+
 func (s *emulation) IsEnabled(service string) (bool, error) {
 	return false, &notImplementedError{"IsEnabled"}
 }
@@ -411,6 +411,10 @@ type Systemd interface {
 	// threads if enabled, etc) part of the unit, which can be a service or a
 	// slice.
 	CurrentTasksCount(unit string) (uint64, error)
+	// RestartStatus returns whether the given services are active and
+	// the number of times systemd restarted them automatically since they
+	// were last started explicitly, using a single systemctl call.
+	RestartStatus(units []string) ([]*RestartStatus, error)
 	// Run a command
 	Run(command []string, opts *RunOptions) ([]byte, error)
 }
@@ -880,6 +884,80 @@ func (s *systemd) CurrentTasksCount(unit string) (uint64, error) {
 	return tasksCount, nil
 }
 
+// RestartStatus is the activity and the automatic restart counter of a
+// service unit.
+type RestartStatus struct {
+	Name     string
+	Active   bool
+	Restarts uint64
+}
+
+var restartStatusProperties = []string{"Id", "ActiveState", "NRestarts"}
+
+func (s *systemd) RestartStatus(units []string) ([]*RestartStatus, error) {
+	if len(units) == 0 {
+		return nil, nil
+	}
+	cmd := append([]string{"show", "--property=" + strings.Join(restartStatusProperties, ",")}, units...)
+	out, err := s.systemctl(cmd...)
+	if err != nil {
+		return nil, osutil.OutputErr(out, err)
+	}
+
+	sts := make([]*RestartStatus, 0, len(units))
+	cur := &RestartStatus{}
+	seen := map[string]bool{}
+	for _, bs := range statusregex.FindAllSubmatch(out, -1) {
+		if len(bs[0]) == 0 {
+			// units are separated by an empty line, in the order
+			// they were asked for
+			if len(sts) >= len(units) {
+				return nil, fmt.Errorf("cannot get restart status: got more results than expected")
+			}
+			for _, k := range restartStatusProperties {
+				if !seen[k] {
+					if k == "NRestarts" {
+						// the counter is only available since systemd 235
+						return nil, fmt.Errorf("restart count unavailable")
+					}
+					return nil, fmt.Errorf("cannot get restart status of unit %q: missing %s in ‘systemctl show’ output", units[len(sts)], k)
+				}
+			}
+			cur.Name = units[len(sts)]
+			sts = append(sts, cur)
+			cur = &RestartStatus{}
+			seen = map[string]bool{}
+			continue
+		}
+		if len(bs[3]) > 0 {
+			return nil, fmt.Errorf("cannot get restart status: bad line %q in ‘systemctl show’ output", bs[3])
+		}
+		k := string(bs[1])
+		v := string(bs[2])
+		switch k {
+		case "Id":
+		case "ActiveState":
+			cur.Active = v == "active" || v == "reloading"
+		case "NRestarts":
+			if v == "[not set]" {
+				return nil, fmt.Errorf("restart count unavailable")
+			}
+			cur.Restarts, err = strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid property value from systemd for NRestarts: cannot parse %q as an integer", v)
+			}
+		default:
+			return nil, fmt.Errorf("cannot get restart status: unexpected field %q in ‘systemctl show’ output", k)
+		}
+		seen[k] = true
+	}
+
+	if len(sts) != len(units) {
+		return nil, fmt.Errorf("cannot get restart status: expected %d results, got %d", len(units), len(sts))
+	}
+	return sts, nil
+}
+
 func (s *systemd) CurrentMemoryUsage(unit string) (quantity.Size, error) {
 	memBytes, err := s.getPropertyUintValue(unit, "MemoryCurrent")
 	if err != nil && err != errNotSet {
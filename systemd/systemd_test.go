@@ -2246,6 +2246,60 @@ func (s *SystemdTestSuite) TestCurrentUsageFamilyHappy(c *C) {
 	})
 }
 
+func (s *SystemdTestSuite) TestRestartStatus(c *C) {
+	s.outs = [][]byte{
+		[]byte(`
+Id=foo.service
+ActiveState=active
+NRestarts=3
+
+Id=bar.service
+ActiveState=failed
+NRestarts=0
+`[1:]),
+	}
+	sysd := New(SystemMode, s.rep)
+	sts, err := sysd.RestartStatus([]string{"foo.service", "bar.service"})
+	c.Assert(err, IsNil)
+	c.Check(sts, DeepEquals, []*RestartStatus{
+		{Name: "foo.service", Active: true, Restarts: 3},
+		{Name: "bar.service", Active: false, Restarts: 0},
+	})
+	// a single call for all units
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"show", "--property=Id,ActiveState,NRestarts", "foo.service", "bar.service"},
+	})
+}
+
+func (s *SystemdTestSuite) TestRestartStatusNoUnits(c *C) {
+	sysd := New(SystemMode, s.rep)
+	sts, err := sysd.RestartStatus(nil)
+	c.Assert(err, IsNil)
+	c.Check(sts, HasLen, 0)
+	c.Check(s.argses, HasLen, 0)
+}
+
+func (s *SystemdTestSuite) TestRestartStatusErrors(c *C) {
+	for _, t := range []struct {
+		out string
+		err string
+	}{
+		// systemd older than 235
+		{"Id=foo.service\nActiveState=active\n", "restart count unavailable"},
+		{"Id=foo.service\nActiveState=active\nNRestarts=[not set]\n", "restart count unavailable"},
+		{"Id=foo.service\nActiveState=active\nNRestarts=blah\n", `invalid property value from systemd for NRestarts: cannot parse "blah" as an integer`},
+		{"Id=foo.service\nNRestarts=1\n", `cannot get restart status of unit "foo.service": missing ActiveState in ‘systemctl show’ output`},
+		{"Id=foo.service\nActiveState=active\nNRestarts=1\nPotato=yes\n", `cannot get restart status: unexpected field "Potato" in ‘systemctl show’ output`},
+		{"Id=foo.service\nActiveState=active\nNRestarts=1\n\nId=bar.service\nActiveState=active\nNRestarts=1\n", `cannot get restart status: got more results than expected`},
+	} {
+		s.outs = [][]byte{[]byte(t.out)}
+		s.i = 0
+		sysd := New(SystemMode, s.rep)
+		_, err := sysd.RestartStatus([]string{"foo.service"})
+		c.Check(err, ErrorMatches, t.err, Commentf("%q", t.out))
+	}
+}
+
 func (s *SystemdTestSuite) TestInactiveEnterTimestampZero(c *C) {
 	s.outs = [][]byte{
 		[]byte(`InactiveEnterTimestamp=`),
@@ -123,10 +123,18 @@ MemoryAccounting=true
 		valuesTemplate := `MemoryMax=%[1]d
 # for compatibility with older versions of systemd
 MemoryLimit=%[1]d
-
 `
 		fmt.Fprintf(buf, valuesTemplate, grp.MemoryLimit)
 	}
+	if grp.MemoryHighLimit != 0 {
+		fmt.Fprintf(buf, "MemoryHigh=%d\n", grp.MemoryHighLimit)
+	}
+	if grp.MemorySwapLimit != nil {
+		fmt.Fprintf(buf, "MemorySwapMax=%d\n", *grp.MemorySwapLimit)
+	}
+	if grp.MemoryLimit != 0 || grp.MemoryHighLimit != 0 || grp.MemorySwapLimit != nil {
+		buf.WriteString("\n")
+	}
 	return buf.String()
 }
 
@@ -143,6 +151,37 @@ TasksAccounting=true
 	return buf.String()
 }
 
+func formatIOGroupSlice(grp *quota.Group) string {
+	if grp.IOLimit == nil {
+		return ""
+	}
+	header := `
+# Always enable io accounting, so the following io quota options have an effect
+IOAccounting=true
+`
+	buf := bytes.NewBufferString(header)
+
+	// The limits are applied to the block device backing the snap data
+	// directories, systemd resolves it from the given path.
+	device := dirs.SnapDataDir
+	if grp.IOLimit.ReadBandwidth != 0 {
+		fmt.Fprintf(buf, "IOReadBandwidthMax=%s %d\n", device, grp.IOLimit.ReadBandwidth)
+	}
+	if grp.IOLimit.WriteBandwidth != 0 {
+		fmt.Fprintf(buf, "IOWriteBandwidthMax=%s %d\n", device, grp.IOLimit.WriteBandwidth)
+	}
+	if grp.IOLimit.ReadIOPS != 0 {
+		fmt.Fprintf(buf, "IOReadIOPSMax=%s %d\n", device, grp.IOLimit.ReadIOPS)
+	}
+	if grp.IOLimit.WriteIOPS != 0 {
+		fmt.Fprintf(buf, "IOWriteIOPSMax=%s %d\n", device, grp.IOLimit.WriteIOPS)
+	}
+	if grp.IOLimit.Weight != 0 {
+		fmt.Fprintf(buf, "IOWeight=%d\n", grp.IOLimit.Weight)
+	}
+	return buf.String()
+}
+
 // generateGroupSliceFile generates a systemd slice unit definition for the
 // specified quota group.
 func generateGroupSliceFile(grp *quota.Group) []byte {
@@ -151,6 +190,7 @@ func generateGroupSliceFile(grp *quota.Group) []byte {
 	cpuOptions := formatCpuGroupSlice(grp)
 	memoryOptions := formatMemoryGroupSlice(grp)
 	taskOptions := formatTaskGroupSlice(grp)
+	ioOptions := formatIOGroupSlice(grp)
 	template := `[Unit]
 Description=Slice for snap quota group %[1]s
 Before=slices.target
@@ -160,7 +200,7 @@ X-Snappy=yes
 `
 
 	fmt.Fprintf(&buf, template, grp.Name)
-	fmt.Fprint(&buf, cpuOptions, memoryOptions, taskOptions)
+	fmt.Fprint(&buf, cpuOptions, memoryOptions, taskOptions, ioOptions)
 	return buf.Bytes()
 }
 
@@ -1161,6 +1201,20 @@ func genServiceNames(snap *snap.Info, appNames []string) []string {
 	return names
 }
 
+// systemdOOMPolicy returns the value of the OOMPolicy= service setting
+// implementing the given quota group OOM policy.
+func systemdOOMPolicy(policy quota.OOMPolicy) string {
+	switch policy {
+	case quota.OOMPolicyKillOne:
+		return "continue"
+	case quota.OOMPolicyKillGroup:
+		return "kill"
+	case quota.OOMPolicyRestartService:
+		return "stop"
+	}
+	return ""
+}
+
 func genServiceFile(appInfo *snap.AppInfo, opts *generateSnapServicesOptions) ([]byte, error) {
 	if opts == nil {
 		opts = &generateSnapServicesOptions{}
@@ -1283,6 +1337,9 @@ Slice={{.SliceUnit}}
 {{- if .LogNamespace}}
 LogNamespace={{.LogNamespace}}
 {{- end}}
+{{- if .OOMPolicy}}
+OOMPolicy={{.OOMPolicy}}
+{{- end}}
 {{- if not (or .App.Sockets .App.Timer .App.ActivatesOn) }}
 
 [Install]
@@ -1356,6 +1413,7 @@ WantedBy={{.ServicesTarget}}
 		InterfaceServiceSnippets string
 		SliceUnit                string
 		LogNamespace             string
+		OOMPolicy                string
 
 		Home    string
 		EnvVars string
@@ -1403,6 +1461,16 @@ WantedBy={{.ServicesTarget}}
 		if opts.QuotaGroup.JournalQuotaSet() {
 			wrapperData.LogNamespace = opts.QuotaGroup.JournalNamespaceName()
 		}
+		wrapperData.OOMPolicy = systemdOOMPolicy(opts.QuotaGroup.GetOOMPolicy())
+		// systemd only stops the service when the oom-killer kills one of
+		// its processes, restarting it depends on the restart condition
+		if opts.QuotaGroup.GetOOMPolicy() == quota.OOMPolicyRestartService && appInfo.Daemon != "oneshot" {
+			switch wrapperData.Restart {
+			case snap.RestartOnFailure.String(), snap.RestartOnAbnormal.String(), snap.RestartAlways.String():
+			default:
+				wrapperData.Restart = snap.RestartOnFailure.String()
+			}
+		}
 	}
 
 	// Add extra "After" targets
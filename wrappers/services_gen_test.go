@@ -1109,6 +1109,85 @@ WantedBy=multi-user.target
 `, mountUnitPrefix, mountUnitPrefix))
 }
 
+func (s *servicesWrapperGenSuite) TestQuotaGroupOOMPolicy(c *C) {
+	service := &snap.AppInfo{
+		Snap: &snap.Info{
+			SuggestedName: "snap",
+			Version:       "0.3.4",
+			SideInfo:      snap.SideInfo{Revision: snap.R(44)},
+		},
+		Name:        "app",
+		Command:     "bin/foo start",
+		Daemon:      "simple",
+		DaemonScope: snap.SystemDaemon,
+		RestartCond: snap.RestartNever,
+	}
+
+	grp, err := quota.NewGroup("foo", quota.NewResourcesBuilder().
+		WithMemoryLimit(quantity.SizeGiB).WithOOMPolicy(quota.OOMPolicyRestartService).Build())
+	c.Assert(err, IsNil)
+
+	opts := &wrappers.GenerateSnapServicesOptions{QuotaGroup: grp}
+	generatedWrapper, err := wrappers.GenerateSnapServiceFile(service, opts)
+	c.Assert(err, IsNil)
+
+	// the service is restarted even though its restart condition is "never"
+	c.Check(string(generatedWrapper), testutil.Contains, "\nRestart=on-failure\n")
+	c.Check(string(generatedWrapper), testutil.Contains, "\nSlice=snap.foo.slice\nOOMPolicy=stop\n")
+
+	// oneshot services are never restarted
+	service.Daemon = "oneshot"
+	generatedWrapper, err = wrappers.GenerateSnapServiceFile(service, opts)
+	c.Assert(err, IsNil)
+	c.Check(string(generatedWrapper), testutil.Contains, "\nRestart=no\n")
+	c.Check(string(generatedWrapper), testutil.Contains, "\nOOMPolicy=stop\n")
+}
+
+func (s *servicesWrapperGenSuite) TestQuotaGroupOOMPolicyInheritParent(c *C) {
+	service := &snap.AppInfo{
+		Snap: &snap.Info{
+			SuggestedName: "snap",
+			Version:       "0.3.4",
+			SideInfo:      snap.SideInfo{Revision: snap.R(44)},
+		},
+		Name:        "app",
+		Command:     "bin/foo start",
+		Daemon:      "simple",
+		DaemonScope: snap.SystemDaemon,
+		RestartCond: snap.RestartAlways,
+	}
+
+	testCases := []struct {
+		topPolicy      quota.OOMPolicy
+		subPolicy      quota.OOMPolicy
+		expectedPolicy string
+	}{
+		{quota.OOMPolicyKillGroup, "", "OOMPolicy=kill"},
+		{quota.OOMPolicyKillGroup, quota.OOMPolicyKillOne, "OOMPolicy=continue"},
+		{quota.OOMPolicyKillOne, quota.OOMPolicyRestartService, "OOMPolicy=stop"},
+	}
+
+	for _, t := range testCases {
+		grp, err := quota.NewGroup("foo", quota.NewResourcesBuilder().
+			WithMemoryLimit(quantity.SizeGiB).WithOOMPolicy(t.topPolicy).Build())
+		c.Assert(err, IsNil)
+		subResources := quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB / 2)
+		if t.subPolicy != "" {
+			subResources.WithOOMPolicy(t.subPolicy)
+		}
+		sub, err := grp.NewSubGroup("foosub", subResources.Build())
+		c.Assert(err, IsNil)
+		sub.Services = []string{"snap.app"}
+
+		opts := &wrappers.GenerateSnapServicesOptions{QuotaGroup: sub}
+		generatedWrapper, err := wrappers.GenerateSnapServiceFile(service, opts)
+		c.Assert(err, IsNil)
+		c.Check(string(generatedWrapper), testutil.Contains, t.expectedPolicy+"\n")
+		// an explicit restart condition is kept
+		c.Check(string(generatedWrapper), testutil.Contains, "Restart=always\n")
+	}
+}
+
 func (s *servicesWrapperGenSuite) TestQuotaGroupLogNamespaceInheritParent(c *C) {
 	service := &snap.AppInfo{
 		Snap: &snap.Info{
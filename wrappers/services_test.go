@@ -430,6 +430,104 @@ TasksAccounting=true
 	c.Assert(svcFile, testutil.FileEquals, svcContent)
 }
 
+func (s *servicesTestSuite) TestEnsureSnapServicesWithIOQuotas(c *C) {
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+
+	resourceLimits := quota.NewResourcesBuilder().
+		WithIOReadBandwidth(10 * quantity.SizeMiB).
+		WithIOWriteBandwidth(5 * quantity.SizeMiB).
+		WithIOReadIOPS(1000).
+		WithIOWriteIOPS(500).
+		WithIOWeight(50).
+		Build()
+	grp, err := quota.NewGroup("foogroup", resourceLimits)
+	c.Assert(err, IsNil)
+
+	m := map[*snap.Info]*wrappers.SnapServiceOptions{
+		info: {QuotaGroup: grp},
+	}
+
+	sliceContent := fmt.Sprintf(`[Unit]
+Description=Slice for snap quota group foogroup
+Before=slices.target
+X-Snappy=yes
+
+[Slice]
+# Always enable cpu accounting, so the following cpu quota options have an effect
+CPUAccounting=true
+
+# Always enable memory accounting otherwise the MemoryMax setting does nothing.
+MemoryAccounting=true
+# Always enable task accounting in order to be able to count the processes/
+# threads, etc for a slice
+TasksAccounting=true
+
+# Always enable io accounting, so the following io quota options have an effect
+IOAccounting=true
+IOReadBandwidthMax=%[1]s 10485760
+IOWriteBandwidthMax=%[1]s 5242880
+IOReadIOPSMax=%[1]s 1000
+IOWriteIOPSMax=%[1]s 500
+IOWeight=50
+`, dirs.SnapDataDir)
+
+	err = wrappers.EnsureSnapServices(m, nil, nil, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
+
+	sliceFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.foogroup.slice")
+	c.Assert(sliceFile, testutil.FileEquals, sliceContent)
+}
+
+func (s *servicesTestSuite) TestEnsureSnapServicesWithMemoryPressureQuotas(c *C) {
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+
+	resourceLimits := quota.NewResourcesBuilder().
+		WithMemoryLimit(quantity.SizeGiB).
+		WithMemoryHighLimit(512 * quantity.SizeMiB).
+		WithMemorySwapLimit(0).
+		Build()
+	grp, err := quota.NewGroup("foogroup", resourceLimits)
+	c.Assert(err, IsNil)
+
+	m := map[*snap.Info]*wrappers.SnapServiceOptions{
+		info: {QuotaGroup: grp},
+	}
+
+	sliceContent := `[Unit]
+Description=Slice for snap quota group foogroup
+Before=slices.target
+X-Snappy=yes
+
+[Slice]
+# Always enable cpu accounting, so the following cpu quota options have an effect
+CPUAccounting=true
+
+# Always enable memory accounting otherwise the MemoryMax setting does nothing.
+MemoryAccounting=true
+MemoryMax=1073741824
+# for compatibility with older versions of systemd
+MemoryLimit=1073741824
+MemoryHigh=536870912
+MemorySwapMax=0
+
+# Always enable task accounting in order to be able to count the processes/
+# threads, etc for a slice
+TasksAccounting=true
+`
+
+	err = wrappers.EnsureSnapServices(m, nil, nil, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
+
+	sliceFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.foogroup.slice")
+	c.Assert(sliceFile, testutil.FileEquals, sliceContent)
+}
+
 func (s *servicesTestSuite) TestEnsureSnapServicesWithZeroCpuCountAndCpuSetQuotas(c *C) {
 	// Another special case, if the cpu count is zero it needs to automatically scale as the
 	// previous test, but only up the maximum allowed provided in the cpu-set. So in this test